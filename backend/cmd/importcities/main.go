@@ -0,0 +1,90 @@
+// Command importcities bootstraps city metadata entries from open data.
+//
+// Usage:
+//
+//	DATA_DIR=./data-override go run ./cmd/importcities -csv census.csv
+//	DATA_DIR=./data-override go run ./cmd/importcities -osm "Kelowna,Moncton" -dry-run
+//	TENANTS_FILE=tenants.json go run ./cmd/importcities -tenant ns-tourism -csv census.csv
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/joshndala/cantrip/services"
+)
+
+func main() {
+	csvPath := flag.String("csv", "", "Statistics Canada census CSV export to import")
+	osmCities := flag.String("osm", "", "comma-separated city names to look up on OpenStreetMap")
+	overwrite := flag.Bool("overwrite", false, "update population, coordinates and neighborhoods of existing cities")
+	dryRun := flag.Bool("dry-run", false, "report what would change without writing the metadata")
+	tenantID := flag.String("tenant", "", "import into a tenant's data directory, from TENANTS_FILE")
+	flag.Parse()
+
+	if *csvPath == "" && *osmCities == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	importCtx := context.Background()
+	if *tenantID != "" {
+		if err := services.LoadTenants(); err != nil {
+			log.Fatalf("Failed to load tenants: %v", err)
+		}
+		tenant, err := services.TenantByID(*tenantID)
+		if err != nil {
+			log.Fatalf("Failed to find tenant: %v", err)
+		}
+		importCtx = services.WithTenant(importCtx, tenant)
+	}
+
+	var records []services.CityImportRecord
+
+	if *csvPath != "" {
+		file, err := os.Open(*csvPath)
+		if err != nil {
+			log.Fatalf("Failed to open CSV: %v", err)
+		}
+		csvRecords, err := services.ParseStatCanCSV(file)
+		file.Close()
+		if err != nil {
+			log.Fatalf("Failed to parse CSV: %v", err)
+		}
+		records = append(records, csvRecords...)
+	}
+
+	if *osmCities != "" {
+		for _, name := range strings.Split(*osmCities, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			record, err := services.FetchOSMCity(ctx, name)
+			cancel()
+			if err != nil {
+				log.Printf("Skipping %s: %v", name, err)
+				continue
+			}
+			records = append(records, record)
+		}
+	}
+
+	result, err := services.ImportCities(importCtx, records, services.CityImportOptions{
+		Overwrite: *overwrite,
+		DryRun:    *dryRun,
+	})
+	if err != nil {
+		log.Fatalf("Import failed: %v", err)
+	}
+
+	output, _ := json.MarshalIndent(result, "", "  ")
+	os.Stdout.Write(append(output, '\n'))
+}
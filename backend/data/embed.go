@@ -0,0 +1,9 @@
+// Package data embeds the default seed datasets into the binary
+package data
+
+import "embed"
+
+// Seed holds the default JSON datasets shipped with the backend
+//
+//go:embed *.json
+var Seed embed.FS
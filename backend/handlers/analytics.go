@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joshndala/cantrip/services"
+)
+
+// AnalyticsOptOutHeader lets a client opt out of usage analytics, as do DNT and Sec-GPC
+const AnalyticsOptOutHeader = "X-Analytics-Opt-Out"
+
+// ExperimentSubjectHeader carries a random ID an anonymous client keeps, so it stays in the
+// same experiment variants and its events can be joined to its exposures
+const ExperimentSubjectHeader = "X-Experiment-Subject"
+
+// ExperimentsHeader tags a response with the experiment variants that shaped it
+const ExperimentsHeader = "X-Experiments"
+
+// analyticsOptedOut reports whether the caller asked not to be counted
+func analyticsOptedOut(c *gin.Context) bool {
+	return c.GetHeader(AnalyticsOptOutHeader) == "true" || c.GetHeader("DNT") == "1" || c.GetHeader("Sec-GPC") == "1"
+}
+
+// analyticsSubject is the caller's anonymous ID: the hashed user, or for anonymous callers
+// the hashed ExperimentSubjectHeader
+func analyticsSubject(c *gin.Context) string {
+	if id := services.AnonymousID(requestActor(c)); id != "" {
+		return id
+	}
+	if client := c.GetHeader(ExperimentSubjectHeader); client != "" {
+		return services.AnonymousID("client:" + client)
+	}
+	return ""
+}
+
+// trackEvent records an anonymous usage event for the caller unless they opted out. Events
+// carry the caller's experiment variants so external sinks can break them down by variant.
+func trackEvent(c *gin.Context, name string, properties map[string]interface{}) {
+	if analyticsOptedOut(c) {
+		return
+	}
+	subject := analyticsSubject(c)
+	if variants := services.AssignedVariants(services.WithExperimentSubject(c.Request.Context(), subject)); len(variants) > 0 {
+		tagged := map[string]interface{}{"experiments": variants}
+		for key, value := range properties {
+			tagged[key] = value
+		}
+		properties = tagged
+	}
+	services.TrackEvent(name, subject, properties)
+}
+
+// joinExperiments assigns the caller to the given experiments for the rest of the request,
+// logs their exposure and tags the response with the variants. Callers without an
+// anonymous ID and experiments that are off are left out and get the control.
+func joinExperiments(c *gin.Context, experimentIDs ...string) map[string]string {
+	subject := analyticsSubject(c)
+	if subject == "" {
+		return nil
+	}
+	c.Request = c.Request.WithContext(services.WithExperimentSubject(c.Request.Context(), subject))
+
+	enabled := services.AssignedVariants(c.Request.Context())
+	variants := map[string]string{}
+	var pairs []string
+	for _, id := range experimentIDs {
+		variant, ok := enabled[id]
+		if !ok {
+			continue
+		}
+		variants[id] = variant
+		pairs = append(pairs, id+"="+variant)
+		trackEvent(c, services.EventExperimentExposure, map[string]interface{}{"experiment": id, "variant": variant})
+	}
+	if len(pairs) > 0 {
+		c.Header(ExperimentsHeader, strings.Join(pairs, ", "))
+	}
+	return variants
+}
+
+// GetAnalyticsStatsHandler reports aggregated usage between ?from and ?to (admin only)
+func GetAnalyticsStatsHandler(c *gin.Context) {
+	from, ok := queryDayBound(c, "from", false)
+	if !ok {
+		return
+	}
+	to, ok := queryDayBound(c, "to", true)
+	if !ok {
+		return
+	}
+	if !from.IsZero() && !to.IsZero() && !to.After(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must be after from"})
+		return
+	}
+
+	stats, err := services.GetAnalyticsStats(from, to)
+	if err != nil {
+		if errors.Is(err, services.ErrAnalyticsStoreUnavailable) {
+			c.JSON(http.StatusNotImplemented, gin.H{"error": "Analytics stats are only available with ANALYTICS_SINK=file"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute analytics stats: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// GetExperimentResultsHandler compares experiment variants by what exposed visitors did
+// next, between ?from and ?to (admin only)
+func GetExperimentResultsHandler(c *gin.Context) {
+	from, ok := queryDayBound(c, "from", false)
+	if !ok {
+		return
+	}
+	to, ok := queryDayBound(c, "to", true)
+	if !ok {
+		return
+	}
+
+	results, err := services.GetExperimentResults(from, to)
+	if err != nil {
+		if errors.Is(err, services.ErrAnalyticsStoreUnavailable) {
+			c.JSON(http.StatusNotImplemented, gin.H{"error": "Experiment results are only available with ANALYTICS_SINK=file"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute experiment results: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"experiments": results})
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joshndala/cantrip/pagination"
+	"github.com/joshndala/cantrip/services"
+)
+
+// tripForAttachments loads the trip an attachment request addresses; writes require the
+// trip owner when the trip has one
+func tripForAttachments(c *gin.Context, write bool) (*services.ItineraryResponse, bool) {
+	itinerary, err := services.GetItinerary(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Trip not found"})
+		return nil, false
+	}
+	if write && itinerary.OwnerID != "" && itinerary.OwnerID != requestActor(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the trip owner can change attachments"})
+		return nil, false
+	}
+	return itinerary, true
+}
+
+// UploadAttachmentHandler stores a ticket, reservation or photo with a trip. The multipart
+// form carries the file and an optional kind.
+func UploadAttachmentHandler(c *gin.Context) {
+	itinerary, ok := tripForAttachments(c, true)
+	if !ok {
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	if file.Size > services.MaxAttachmentBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Attachment is larger than 10 MB"})
+		return
+	}
+	opened, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read upload"})
+		return
+	}
+	defer opened.Close()
+	data, err := io.ReadAll(opened)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read upload"})
+		return
+	}
+
+	attachment, err := services.SaveAttachment(c.Request.Context(), itinerary.ID, requestActor(c), file.Filename, c.PostForm("kind"), data)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidAttachment) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store attachment"})
+		return
+	}
+
+	recordAudit(c, services.AuditActionCreate, "attachment", attachment.ID, nil, attachment)
+	c.JSON(http.StatusCreated, attachment)
+}
+
+// ListAttachmentsHandler lists a trip's attachments, newest first
+func ListAttachmentsHandler(c *gin.Context) {
+	itinerary, ok := tripForAttachments(c, false)
+	if !ok {
+		return
+	}
+
+	params, ok := parsePage(c, attachmentPageOptions)
+	if !ok {
+		return
+	}
+
+	attachments, err := services.ListAttachments(c.Request.Context(), itinerary.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list attachments"})
+		return
+	}
+	attachments, page := pagination.Apply(attachments, params, attachmentPageKeys)
+
+	c.JSON(http.StatusOK, gin.H{
+		"trip_id":     itinerary.ID,
+		"attachments": attachments,
+		"total":       page.Total,
+		"pagination":  page,
+	})
+}
+
+// DownloadAttachmentHandler streams an attachment, with Range support
+func DownloadAttachmentHandler(c *gin.Context) {
+	itinerary, ok := tripForAttachments(c, false)
+	if !ok {
+		return
+	}
+
+	attachment, content, err := services.OpenAttachment(c.Request.Context(), itinerary.ID, c.Param("attachmentId"))
+	if err != nil {
+		if errors.Is(err, services.ErrDocumentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+			return
+		}
+		if errors.Is(err, services.ErrAttachmentQuarantined) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Attachment is quarantined", "scan_status": attachment.ScanStatus})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open attachment"})
+		return
+	}
+	defer content.Close()
+
+	c.Header("Content-Type", attachment.ContentType)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}))
+	http.ServeContent(c.Writer, c.Request, attachment.Filename, attachment.UploadedAt, content)
+}
+
+// DeleteAttachmentHandler removes an attachment from a trip
+func DeleteAttachmentHandler(c *gin.Context) {
+	itinerary, ok := tripForAttachments(c, true)
+	if !ok {
+		return
+	}
+
+	attachment, err := services.DeleteAttachment(c.Request.Context(), itinerary.ID, c.Param("attachmentId"))
+	if err != nil {
+		if errors.Is(err, services.ErrDocumentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete attachment"})
+		return
+	}
+
+	recordAudit(c, services.AuditActionDelete, "attachment", attachment.ID, attachment, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Attachment deleted successfully"})
+}
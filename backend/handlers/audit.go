@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joshndala/cantrip/pagination"
+	"github.com/joshndala/cantrip/services"
+	"github.com/joshndala/cantrip/utils"
+)
+
+// UserIDHeader identifies the caller for auditing
+const UserIDHeader = "X-User-ID"
+
+// requestActor returns the identity performing the request
+func requestActor(c *gin.Context) string {
+	if userID := c.GetHeader(UserIDHeader); userID != "" {
+		return userID
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		return userID
+	}
+	return "anonymous"
+}
+
+// recordAudit records a data-modifying operation without failing the request
+func recordAudit(c *gin.Context, action, resourceType, resourceID string, before, after interface{}) {
+	if err := services.RecordAudit(c.Request.Context(), requestActor(c), action, resourceType, resourceID, before, after); err != nil {
+		utils.LogError("Failed to record audit entry", err)
+	}
+}
+
+// GetAuditLogHandler queries the audit log (admin only)
+func GetAuditLogHandler(c *gin.Context) {
+	filter := services.AuditFilter{
+		Actor:        c.Query("actor"),
+		Action:       c.Query("action"),
+		ResourceType: c.Query("resource_type"),
+		ResourceID:   c.Query("resource_id"),
+	}
+
+	var ok bool
+	if filter.Since, ok = queryDayBound(c, "since", false); !ok {
+		return
+	}
+	if filter.Until, ok = queryDayBound(c, "until", true); !ok {
+		return
+	}
+
+	params, ok := parsePage(c, auditPageOptions)
+	if !ok {
+		return
+	}
+
+	entries, err := services.QueryAuditLog(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query audit log"})
+		return
+	}
+	entries, page := pagination.Apply(entries, params, auditPageKeys)
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries":    entries,
+		"total":      page.Total,
+		"pagination": page,
+	})
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joshndala/cantrip/services"
+)
+
+// ImportBookingsRequest is the JSON form of a booking import
+type ImportBookingsRequest struct {
+	Text string `json:"text"` // pasted confirmation email
+}
+
+// ImportBookingsHandler adds flights and hotels from booking confirmations to a trip.
+// Accepts a multipart "file" upload (.ics or .pdf), a JSON body with pasted text,
+// or a raw text/calendar, application/pdf or text/plain body.
+func ImportBookingsHandler(c *gin.Context) {
+	id := c.Param("id")
+
+	itinerary, err := services.GetItinerary(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Itinerary not found"})
+		return
+	}
+	if itinerary.OwnerID != "" && itinerary.OwnerID != requestActor(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the trip owner can import bookings"})
+		return
+	}
+
+	var filename, text string
+	var data []byte
+
+	contentType := c.ContentType()
+	switch {
+	case strings.HasPrefix(contentType, "multipart/"):
+		file, err := c.FormFile("file")
+		if err != nil {
+			text = c.PostForm("text")
+			break
+		}
+		opened, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read upload"})
+			return
+		}
+		defer opened.Close()
+		filename = file.Filename
+		data, err = io.ReadAll(opened)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read upload"})
+			return
+		}
+	case contentType == "application/json":
+		var req ImportBookingsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		text = req.Text
+	default:
+		data, err = io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body too large"})
+			return
+		}
+		switch contentType {
+		case "text/calendar":
+			filename = "import.ics"
+		case "application/pdf":
+			filename = "import.pdf"
+		}
+	}
+
+	if len(data) == 0 && strings.TrimSpace(text) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide a booking file or pasted confirmation text"})
+		return
+	}
+
+	result, err := services.ImportBookings(c.Request.Context(), id, filename, data, text)
+	if err != nil {
+		if respondVersionConflict(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrNoBookingsFound) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import bookings: " + err.Error()})
+		return
+	}
+
+	recordAudit(c, services.AuditActionUpdate, "itinerary", id, itinerary, result.Itinerary)
+	resyncCalendarInBackground(c, result.Itinerary)
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joshndala/cantrip/models"
+	"github.com/joshndala/cantrip/services"
+)
+
+// calendarSyncTimeout bounds background resyncs after an itinerary changes
+const calendarSyncTimeout = 2 * time.Minute
+
+// ConnectCalendarHandler returns the Google consent URL for linking the caller's calendar
+func ConnectCalendarHandler(c *gin.Context) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	authURL, err := services.CalendarAuthURL(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"auth_url": authURL})
+}
+
+// CalendarCallbackHandler completes the OAuth flow from Google's redirect
+func CalendarCallbackHandler(c *gin.Context) {
+	if reason := c.Query("error"); reason != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Google authorization failed: " + reason})
+		return
+	}
+
+	state, code := c.Query("state"), c.Query("code")
+	if state == "" || code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "state and code are required"})
+		return
+	}
+
+	connection, err := services.CompleteCalendarAuth(c.Request.Context(), state, code)
+	if err != nil {
+		if errors.Is(err, services.ErrCalendarNotConfigured) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Google Calendar connected",
+		"user_id":      connection.UserID,
+		"connected_at": connection.ConnectedAt,
+	})
+}
+
+// DisconnectCalendarHandler removes the caller's Google token
+func DisconnectCalendarHandler(c *gin.Context) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	if err := services.DisconnectCalendar(c.Request.Context(), userID); err != nil {
+		if errors.Is(err, services.ErrDocumentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Google Calendar is not connected"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disconnect calendar: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Google Calendar disconnected"})
+}
+
+// SyncItineraryCalendarHandler pushes an itinerary to the owner's Google Calendar
+func SyncItineraryCalendarHandler(c *gin.Context) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	itinerary, err := services.GetItinerary(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Itinerary not found"})
+		return
+	}
+	if itinerary.OwnerID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the trip owner can sync it to a calendar"})
+		return
+	}
+
+	result, err := services.SyncItineraryCalendar(c.Request.Context(), itinerary)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrCalendarNotConnected):
+			c.JSON(http.StatusConflict, gin.H{"error": "Connect Google Calendar first"})
+		case errors.Is(err, services.ErrCalendarNotConfigured):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to sync calendar: " + err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// UnlinkItineraryCalendarHandler deletes an itinerary's trip calendar
+func UnlinkItineraryCalendarHandler(c *gin.Context) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	state, err := services.GetCalendarSync(c.Request.Context(), c.Param("id"))
+	if err != nil || state.UserID != userID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Itinerary is not synced to a calendar"})
+		return
+	}
+
+	if err := services.UnlinkItineraryCalendar(c.Request.Context(), state.ItineraryID); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to remove trip calendar: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Trip calendar removed"})
+}
+
+// resyncCalendarInBackground keeps a linked trip calendar up to date without delaying the response
+func resyncCalendarInBackground(c *gin.Context, itinerary *services.ItineraryResponse) {
+	// Keep the request's tenant but not its deadline
+	parent := context.WithoutCancel(c.Request.Context())
+	go func() {
+		ctx, cancel := context.WithTimeout(parent, calendarSyncTimeout)
+		defer cancel()
+		services.ResyncItineraryCalendar(ctx, itinerary)
+	}()
+}
+
+// SubscribeAgendaFeedHandler creates the caller's daily agenda feed for a trip, returning a
+// webcal:// URL for calendar apps and, with daily_email, emailing each day's agenda at 7am
+func SubscribeAgendaFeedHandler(c *gin.Context) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	itinerary, err := services.GetItinerary(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Itinerary not found"})
+		return
+	}
+	if itinerary.OwnerID != "" && itinerary.OwnerID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the trip owner can subscribe to its agenda"})
+		return
+	}
+
+	var req services.AgendaFeedInput
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.DailyEmail && services.GetMailer() == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Email delivery is not configured"})
+		return
+	}
+
+	feed, err := services.SubscribeAgendaFeed(c.Request.Context(), itinerary, userID, req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidAgendaFeed) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to subscribe: " + err.Error()})
+		return
+	}
+
+	feedURL := publicBaseURL(c) + "/agenda/" + feed.Token + ".ics"
+	c.JSON(http.StatusOK, gin.H{
+		"feed":       feed,
+		"feed_url":   feedURL,
+		"webcal_url": "webcal://" + strings.SplitN(feedURL, "://", 2)[1],
+	})
+}
+
+// UnsubscribeAgendaFeedHandler deletes the caller's agenda feed for a trip
+func UnsubscribeAgendaFeedHandler(c *gin.Context) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	if err := services.UnsubscribeAgendaFeed(c.Request.Context(), c.Param("id"), userID); err != nil {
+		if errors.Is(err, services.ErrAgendaFeedNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not subscribed to this trip's agenda"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unsubscribe: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Agenda feed removed"})
+}
+
+// AgendaFeedHandler serves a trip's agenda for today, in the trip city's timezone, as an
+// iCalendar feed. ?date=YYYY-MM-DD previews another day.
+func AgendaFeedHandler(c *gin.Context) {
+	feed, itinerary, err := services.ResolveAgendaFeed(c.Request.Context(), strings.TrimSuffix(c.Param("token"), ".ics"))
+	if errors.Is(err, services.ErrAgendaFeedNotFound) {
+		c.String(http.StatusNotFound, "Agenda feed not found")
+		return
+	}
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Failed to load agenda feed")
+		return
+	}
+
+	date, err := models.NormalizeDate(c.Query("date"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "date: "+err.Error())
+		return
+	}
+	if date == "" {
+		date = services.TripToday(c.Request.Context(), itinerary)
+	}
+	agenda := services.BuildDailyAgenda(c.Request.Context(), itinerary, date)
+
+	// Calendar apps poll; keep caches short so edits show up on the next refresh
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Header("Content-Disposition", `inline; filename="agenda.ics"`)
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", services.RenderAgendaICS(feed, itinerary, agenda))
+}
@@ -1,81 +1,320 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/joshndala/cantrip/models"
+	"github.com/joshndala/cantrip/pagination"
 	"github.com/joshndala/cantrip/services"
+	"github.com/joshndala/cantrip/utils"
 )
 
-type ChatRequest struct {
-	Message   string `json:"message" binding:"required"`
-	SessionID string `json:"session_id"`
-	UserID    string `json:"user_id,omitempty"`
+// wantsSpeech reports whether the caller asked for the reply as audio
+func wantsSpeech(c *gin.Context, flag bool) bool {
+	return flag || c.Query("tts") == "true"
+}
+
+// speakReply attaches the spoken reply; synthesis failures leave the text reply as it is
+func speakReply(c *gin.Context, response *services.ChatResponse, language string) {
+	audio, err := services.SpeakReply(c.Request.Context(), response.Response, language)
+	if err != nil {
+		utils.LogError("Failed to synthesize chat reply", err)
+		return
+	}
+	response.Audio = audio
+}
+
+// respondChatThrottled tells a session blocked for repeated harmful messages when to retry
+func respondChatThrottled(c *gin.Context, wait time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "This session is temporarily blocked after repeated policy violations"})
+}
+
+// respondChatError maps a failed chat turn to its status
+func respondChatError(c *gin.Context, err error) {
+	var throttled *services.ThrottleError
+	if errors.As(err, &throttled) {
+		respondChatThrottled(c, throttled.RetryAfter)
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process message"})
 }
 
 // ChatHandler handles conversational interactions
 func ChatHandler(c *gin.Context) {
-	var req ChatRequest
+	var req models.ChatRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	// Get or create conversation session
-	session, err := services.GetOrCreateSession(req.SessionID, req.UserID)
+	session, err := services.GetOrCreateSession(c.Request.Context(), req.SessionID, req.UserID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to manage session"})
 		return
 	}
 
 	// Process message with AI agent
-	response, err := services.ProcessChatMessage(req.Message, session)
+	response, err := services.ProcessChatMessage(c.Request.Context(), req.Message, session)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process message"})
+		respondChatError(c, err)
 		return
 	}
 
-	// Update session with new message
-	err = services.UpdateSession(session.SessionID, req.Message, response.Response)
+	// Update session with new message; blocked messages are kept out of the history
+	if response.Moderated != services.ModerationInput {
+		response.Turn, err = services.UpdateSession(c.Request.Context(), session.SessionID, req.Message, response.Response, response.Cards)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update session"})
+			return
+		}
+	}
+
+	if wantsSpeech(c, req.TTS) {
+		speakReply(c, response, req.Language)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// VoiceChatHandler transcribes a recorded question and answers it like a typed message.
+// The multipart form carries the audio file and optional session_id, user_id and language.
+func VoiceChatHandler(c *gin.Context) {
+	file, err := c.FormFile("audio")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "audio is required"})
+		return
+	}
+	if file.Size > services.MaxVoiceBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Recording is larger than 10 MB"})
+		return
+	}
+	opened, err := file.Open()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update session"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read upload"})
+		return
+	}
+	defer opened.Close()
+	data, err := io.ReadAll(opened)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read upload"})
 		return
 	}
 
-	c.JSON(http.StatusOK, response)
+	transcript, err := services.TranscribeAudio(c.Request.Context(), services.AudioInput{
+		Data:     data,
+		Filename: file.Filename,
+		Language: c.PostForm("language"),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidAudio):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrNoSpeechRecognized):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "No speech was recognized in the recording"})
+		case errors.Is(err, services.ErrSpeechUnavailable):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Voice input is not available"})
+		default:
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to transcribe recording"})
+		}
+		return
+	}
+
+	session, err := services.GetOrCreateSession(c.Request.Context(), c.PostForm("session_id"), c.PostForm("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to manage session"})
+		return
+	}
+
+	response, err := services.ProcessChatMessage(c.Request.Context(), transcript.Text, session)
+	if err != nil {
+		respondChatError(c, err)
+		return
+	}
+
+	if response.Moderated != services.ModerationInput {
+		if response.Turn, err = services.UpdateSession(c.Request.Context(), session.SessionID, transcript.Text, response.Response, response.Cards); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update session"})
+			return
+		}
+	}
+
+	// Reply in the language the question was asked in
+	if wantsSpeech(c, c.PostForm("tts") == "true") {
+		speakReply(c, response, c.PostForm("language"))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"transcript": transcript,
+		"response":   response,
+	})
 }
 
-// ChatStreamHandler handles streaming conversational interactions
+// ChatStreamHandler handles streaming conversational interactions. Every event carries an
+// ID; a client that reconnects with the same request and a Last-Event-ID header resumes
+// the reply after that event instead of sending the message again.
 func ChatStreamHandler(c *gin.Context) {
-	var req ChatRequest
+	var req models.ChatRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	if lastEventID := c.GetHeader("Last-Event-ID"); lastEventID != "" {
+		stream, received, err := services.ResumeChatStream(c.Request.Context(), req.SessionID, lastEventID)
+		if err != nil {
+			c.JSON(http.StatusGone, gin.H{"error": "Stream is no longer available, send the message again"})
+			return
+		}
+		followChatStream(c, stream, received)
+		return
+	}
+
 	// Get or create conversation session
-	session, err := services.GetOrCreateSession(req.SessionID, req.UserID)
+	session, err := services.GetOrCreateSession(c.Request.Context(), req.SessionID, req.UserID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to manage session"})
 		return
 	}
 
+	// Throttled sessions are refused before the event stream starts
+	if wait := services.ChatThrottleRemaining(session.SessionID); wait > 0 {
+		respondChatThrottled(c, wait)
+		return
+	}
+
+	// Call streaming LangGraph agent; the reply keeps generating if the client drops
+	stream := services.StartChatStream(c.Request.Context(), session, services.ChatStreamRequest{
+		Message:  req.Message,
+		Speak:    wantsSpeech(c, req.TTS),
+		Language: req.Language,
+	})
+	followChatStream(c, stream, 0)
+}
+
+// followChatStream sends a stream's events after the first skip as server-sent events
+func followChatStream(c *gin.Context, stream *services.ChatStream, skip int) {
 	// Set headers for Server-Sent Events
 	c.Header("Content-Type", "text/event-stream")
 	c.Header("Cache-Control", "no-cache")
 	c.Header("Connection", "keep-alive")
 	c.Header("Access-Control-Allow-Origin", "*")
-	c.Header("Access-Control-Allow-Headers", "Cache-Control")
+	c.Header("Access-Control-Allow-Headers", "Cache-Control, Last-Event-ID")
 
-	// Call streaming LangGraph agent
-	err = services.ProcessChatMessageStream(req.Message, session, c)
-	if err != nil {
-		// Send error as SSE
-		fmt.Fprintf(c.Writer, "data: %s\n\n", `{"type":"error","content":"Failed to process message"}`)
+	// Streams can outlive the server write timeout, so lift the deadline for this response
+	http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	// Reconnect quickly; the missed events are replayed
+	fmt.Fprint(c.Writer, "retry: 1000\n\n")
+	c.Writer.Flush()
+
+	stream.Follow(c.Request.Context(), skip, func(event services.ChatStreamEvent) error {
+		if _, err := fmt.Fprintf(c.Writer, "id: %s\ndata: %s\n\n", event.ID, event.Data); err != nil {
+			return err
+		}
 		c.Writer.Flush()
+		return nil
+	})
+}
+
+// GetChatAudioHandler streams a synthesized chat reply
+func GetChatAudioHandler(c *gin.Context) {
+	content, info, err := services.OpenSpeechAudio(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Audio not found"})
+		return
+	}
+	defer content.Close()
+
+	c.Header("Content-Type", info.ContentType)
+	c.Header("Cache-Control", "public, max-age=86400")
+	http.ServeContent(c.Writer, c.Request, "", info.Updated, content)
+}
+
+// ChatFeedbackHandler records a thumbs up or down, with an optional comment, on one reply
+func ChatFeedbackHandler(c *gin.Context) {
+	var req services.ChatFeedbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.UserID == "" {
+		req.UserID = c.GetHeader(UserIDHeader)
+	}
+
+	feedback, err := services.SubmitChatFeedback(c.Request.Context(), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidFeedback):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrDocumentNotFound), errors.Is(err, services.ErrTurnNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "No reply found for that session and turn"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save feedback"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, feedback)
+}
+
+// GetChatFeedbackHandler reports feedback on chat replies (admin only): totals since
+// ?since, and the feedback itself, filtered by ?rating, newest first
+func GetChatFeedbackHandler(c *gin.Context) {
+	params, ok := parsePage(c, feedbackPageOptions)
+	if !ok {
+		return
+	}
+	rating := c.Query("rating")
+	if rating != "" && rating != services.FeedbackUp && rating != services.FeedbackDown {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "rating must be up or down"})
+		return
+	}
+
+	stats, err := services.GetChatFeedbackStats(c.Request.Context(), params.Since)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute feedback stats: " + err.Error()})
+		return
+	}
+	feedback, err := services.ListChatFeedback(c.Request.Context(), rating)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list feedback: " + err.Error()})
+		return
+	}
+
+	feedback, page := pagination.Apply(feedback, params, feedbackPageKeys)
+	c.JSON(http.StatusOK, gin.H{"stats": stats, "feedback": feedback, "pagination": page})
+}
+
+// GetModerationIncidentsHandler lists flagged and blocked chat messages (admin only),
+// newest first, optionally only one ?action
+func GetModerationIncidentsHandler(c *gin.Context) {
+	params, ok := parsePage(c, incidentPageOptions)
+	if !ok {
+		return
+	}
+	action := c.Query("action")
+	if action != "" && action != services.ModerationFlag && action != services.ModerationBlock {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "action must be flag or block"})
 		return
 	}
+
+	incidents, err := services.ListModerationIncidents(c.Request.Context(), action)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list moderation incidents: " + err.Error()})
+		return
+	}
+
+	incidents, page := pagination.Apply(incidents, params, incidentPageKeys)
+	c.JSON(http.StatusOK, gin.H{"incidents": incidents, "pagination": page})
 }
 
 // GetConversationHistory returns the conversation history for a session
@@ -86,18 +325,79 @@ func GetConversationHistory(c *gin.Context) {
 		return
 	}
 
-	history, err := services.GetConversationHistory(sessionID)
+	history, err := services.GetConversationHistory(c.Request.Context(), sessionID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get conversation history"})
 		return
 	}
 
+	// Older messages are compacted into a summary once the conversation grows long
+	memory, _ := services.GetConversationMemory(c.Request.Context(), sessionID)
+
 	c.JSON(http.StatusOK, gin.H{
 		"session_id": sessionID,
 		"history":    history,
+		"memory":     memory,
 	})
 }
 
+// ExportConversationHandler exports a session's transcript, with the itineraries generated
+// along the way, as ?format=pdf (the default), md or txt
+func ExportConversationHandler(c *gin.Context) {
+	format := c.DefaultQuery("format", services.TranscriptFormatPDF)
+	if format != services.TranscriptFormatPDF && format != services.TranscriptFormatMarkdown && format != services.TranscriptFormatText {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be pdf, md or txt"})
+		return
+	}
+
+	transcript, err := services.GetChatTranscript(c.Request.Context(), c.Param("session_id"))
+	if err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get conversation history"})
+		return
+	}
+
+	switch format {
+	case services.TranscriptFormatMarkdown:
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "transcript_" + transcript.SessionID + ".md"}))
+		c.Data(http.StatusOK, "text/markdown; charset=utf-8", services.RenderTranscriptMarkdown(transcript))
+	case services.TranscriptFormatText:
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "transcript_" + transcript.SessionID + ".txt"}))
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", services.RenderTranscriptText(transcript))
+	default:
+		// Rendered on the pool like any other PDF
+		metadata, err := generatePDF(c.Request.Context(), PDFRequest{Type: "transcript", ID: transcript.SessionID, Format: "pdf"})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate PDF"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"pdf_id":  metadata.ID,
+			"pdf_url": metadata.DownloadURL,
+			"message": "Transcript PDF generated successfully",
+		})
+	}
+}
+
+// ListSessionsHandler lists the caller's chat sessions, most recently active first
+func ListSessionsHandler(c *gin.Context) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	params, ok := parsePage(c, sessionPageOptions)
+	if !ok {
+		return
+	}
+
+	sessions, page := pagination.Apply(services.ListSessions(c.Request.Context(), userID), params, sessionPageKeys)
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions, "pagination": page})
+}
+
 // ClearConversation clears the conversation history
 func ClearConversation(c *gin.Context) {
 	sessionID := c.Param("session_id")
@@ -106,7 +406,7 @@ func ClearConversation(c *gin.Context) {
 		return
 	}
 
-	err := services.ClearConversation(sessionID)
+	err := services.ClearConversation(c.Request.Context(), sessionID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear conversation"})
 		return
@@ -123,7 +423,7 @@ func GetConversationSuggestions(c *gin.Context) {
 		return
 	}
 
-	suggestions, err := services.GetConversationSuggestions(sessionID)
+	suggestions, err := services.GetConversationSuggestions(c.Request.Context(), sessionID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get suggestions"})
 		return
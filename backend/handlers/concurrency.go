@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joshndala/cantrip/services"
+)
+
+// formatETag renders a document version as a strong ETag
+func formatETag(version int) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// setETag advertises the version a client must send back in If-Match
+func setETag(c *gin.Context, version int) {
+	c.Header("ETag", formatETag(version))
+}
+
+// notModified answers a conditional GET whose If-None-Match matches the current version
+func notModified(c *gin.Context, version int) bool {
+	for _, tag := range strings.Split(c.GetHeader("If-None-Match"), ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == formatETag(version) || tag == "*" {
+			setETag(c, version)
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// expectedVersion returns the version a write is based on, from the If-Match header or
+// the body's version field. Writes without either are unconditional.
+func expectedVersion(c *gin.Context, bodyVersion *int) (int, bool, error) {
+	if header := strings.TrimSpace(c.GetHeader("If-Match")); header != "" && header != "*" {
+		tag := strings.Trim(strings.TrimPrefix(header, "W/"), `"`)
+		version, err := strconv.Atoi(tag)
+		if err != nil {
+			return 0, false, fmt.Errorf("If-Match must be an ETag returned by this API")
+		}
+		return version, true, nil
+	}
+	if bodyVersion != nil {
+		return *bodyVersion, true, nil
+	}
+	return 0, false, nil
+}
+
+// respondVersionConflict answers a stale write with 409 and the current document.
+// It returns false when err is not a version conflict.
+func respondVersionConflict(c *gin.Context, err error) bool {
+	var conflict *services.VersionConflictError
+	if !errors.As(err, &conflict) {
+		return false
+	}
+
+	setETag(c, conflict.Current)
+	c.JSON(http.StatusConflict, gin.H{
+		"error":            "The " + conflict.Resource + " was changed by someone else; merge with the current version and retry",
+		"expected_version": conflict.Expected,
+		"current_version":  conflict.Current,
+		"current":          conflict.Document,
+	})
+	return true
+}
+
+// respondMissingDocument answers a conditional write whose document couldn't be loaded:
+// 404 when it doesn't exist or is in the trash, 500 when storage failed
+func respondMissingDocument(c *gin.Context, resource string, err error) {
+	if errors.Is(err, services.ErrDocumentNotFound) || errors.Is(err, services.ErrInTrash) {
+		c.JSON(http.StatusNotFound, gin.H{"error": resource + " not found"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load " + strings.ToLower(resource)})
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joshndala/cantrip/services"
+)
+
+// DataStateHeader carries the data state of responses whose body is a bare array
+const DataStateHeader = "X-Data-State"
+
+// setDataStateHeader tags a bare-array response with whether it's backed by the city's data
+func setDataStateHeader(c *gin.Context, status services.DataStatus) {
+	c.Header(DataStateHeader, string(status.State))
+}
+
+// respondUnsupportedCity answers with a 404 naming supported cities to try instead
+func respondUnsupportedCity(c *gin.Context, status services.DataStatus) {
+	c.JSON(http.StatusNotFound, gin.H{
+		"error":  status.City + " is not a supported city",
+		"status": status,
+	})
+}
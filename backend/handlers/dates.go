@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/joshndala/cantrip/models"
+)
+
+func init() {
+	// Binding rules such as required look at the day a models.Date holds
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterCustomTypeFunc(func(field reflect.Value) interface{} {
+			return field.Interface().(models.Date).Time
+		}, models.Date{})
+	}
+}
+
+// queryDate reads a date query parameter given as YYYY-MM-DD or an RFC3339 timestamp and
+// returns it as YYYY-MM-DD, or "" when it's absent. Anything else is answered with a 400.
+func queryDate(c *gin.Context, key string) (string, bool) {
+	date, err := models.NormalizeDate(c.Query(key))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": key + ": " + err.Error()})
+		return "", false
+	}
+	return date, true
+}
+
+// queryDayBound reads a date query parameter bounding a time range, given as YYYY-MM-DD or
+// an RFC3339 timestamp, as the midnight UTC starting that day, or the one after it for an
+// end bound so the whole day is included. It's zero when the parameter is absent, and
+// anything else is answered with a 400.
+func queryDayBound(c *gin.Context, key string, end bool) (time.Time, bool) {
+	value := c.Query(key)
+	if value == "" {
+		return time.Time{}, true
+	}
+	date, err := models.ParseDate(value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": key + ": " + err.Error()})
+		return time.Time{}, false
+	}
+	if end {
+		return date.AddDate(0, 0, 1), true
+	}
+	return date.Time, true
+}
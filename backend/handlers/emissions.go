@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joshndala/cantrip/services"
+)
+
+// GetTripEmissionsHandler estimates a trip's carbon footprint. Query parameters: origin
+// (city or airport code) and mode (flight, car, rail or bus) add the journey from home,
+// one_way=true counts it once, and alternatives=true suggests lower-carbon options.
+func GetTripEmissionsHandler(c *gin.Context) {
+	itinerary, err := services.GetItinerary(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Itinerary not found"})
+		return
+	}
+
+	emissions, err := services.EstimateTripEmissions(c.Request.Context(), itinerary, services.EmissionsQuery{
+		Origin:       c.Query("origin"),
+		Mode:         c.Query("mode"),
+		OneWay:       c.Query("one_way") == "true",
+		Alternatives: c.Query("alternatives") == "true",
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidEmissionsQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to estimate emissions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, emissions)
+}
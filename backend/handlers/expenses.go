@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joshndala/cantrip/pagination"
+	"github.com/joshndala/cantrip/services"
+)
+
+// tripForExpenses loads the trip an expense request addresses; only the trip owner may
+// see or log its spending when the trip has one
+func tripForExpenses(c *gin.Context) (*services.ItineraryResponse, bool) {
+	itinerary, err := services.GetItinerary(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Trip not found"})
+		return nil, false
+	}
+	if itinerary.OwnerID != "" && itinerary.OwnerID != requestActor(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the trip owner can manage its expenses"})
+		return nil, false
+	}
+	return itinerary, true
+}
+
+// AddExpenseHandler logs an expense against a trip. A JSON body gives the amount and
+// category; a multipart form may instead carry a receipt photo, which is read with OCR
+// for the amount, vendor and date. The response includes the updated budget report.
+func AddExpenseHandler(c *gin.Context) {
+	itinerary, ok := tripForExpenses(c)
+	if !ok {
+		return
+	}
+
+	var req services.ExpenseInput
+	var receipt *services.ReceiptUpload
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		if err := c.ShouldBind(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if file, err := c.FormFile("receipt"); err == nil {
+			if file.Size > services.MaxAttachmentBytes {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Receipt is larger than 10 MB"})
+				return
+			}
+			opened, err := file.Open()
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read upload"})
+				return
+			}
+			defer opened.Close()
+			data, err := io.ReadAll(opened)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read upload"})
+				return
+			}
+			receipt = &services.ReceiptUpload{Filename: file.Filename, Data: data}
+		}
+	} else if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	expense, report, err := services.AddExpense(c.Request.Context(), itinerary, requestActor(c), req, receipt)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidExpense) || errors.Is(err, services.ErrInvalidAttachment) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log expense: " + err.Error()})
+		return
+	}
+
+	recordAudit(c, services.AuditActionCreate, "expense", expense.ID, nil, expense)
+	c.JSON(http.StatusCreated, gin.H{"expense": expense, "budget": report})
+}
+
+// ListExpensesHandler lists a trip's expenses with spending against the budget by category
+func ListExpensesHandler(c *gin.Context) {
+	itinerary, ok := tripForExpenses(c)
+	if !ok {
+		return
+	}
+
+	params, ok := parsePage(c, expensePageOptions)
+	if !ok {
+		return
+	}
+
+	// The budget report covers every expense, not only the page
+	expenses, report, err := services.ListExpenses(c.Request.Context(), itinerary)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list expenses: " + err.Error()})
+		return
+	}
+	expenses, page := pagination.Apply(expenses, params, expensePageKeys)
+
+	c.JSON(http.StatusOK, gin.H{"expenses": expenses, "budget": report, "total": page.Total, "pagination": page})
+}
+
+// DeleteExpenseHandler removes an expense and its receipt photo
+func DeleteExpenseHandler(c *gin.Context) {
+	itinerary, ok := tripForExpenses(c)
+	if !ok {
+		return
+	}
+
+	expense, err := services.DeleteExpense(c.Request.Context(), itinerary.ID, c.Param("expenseId"))
+	if err != nil {
+		if errors.Is(err, services.ErrDocumentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Expense not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete expense: " + err.Error()})
+		return
+	}
+
+	recordAudit(c, services.AuditActionDelete, "expense", expense.ID, expense, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Expense deleted"})
+}
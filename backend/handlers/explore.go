@@ -1,60 +1,80 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/joshndala/cantrip/models"
 	"github.com/joshndala/cantrip/services"
 )
 
-type ExploreRequest struct {
-	Mood      string   `json:"mood" binding:"required"`
-	City      string   `json:"city" binding:"required"`
-	Budget    float64  `json:"budget"`
-	Duration  int      `json:"duration"` // in days
-	Interests []string `json:"interests"`
-	Season    string   `json:"season"`
-}
-
 type ExploreResponse struct {
-	Suggestions []services.TripSuggestion `json:"suggestions"`
-	Weather     services.WeatherInfo      `json:"weather"`
-	Events      []services.Event          `json:"events"`
+	Suggestions  []services.TripSuggestion `json:"suggestions"`
+	Weather      *services.WeatherInfo     `json:"weather,omitempty"` // unset when no source answered
+	Events       []services.Event          `json:"events"`
+	Experiments  map[string]string         `json:"experiments,omitempty"`  // variants that ranked the suggestions
+	Status       services.DataStatus       `json:"status"`                 // what the response is based on
+	Destinations []services.ReachableCity  `json:"destinations,omitempty"` // cities in reach of the origin
 }
 
 // ExploreHandler handles mood and place-based trip suggestions
 func ExploreHandler(c *gin.Context) {
-	var req ExploreRequest
+	var req models.ExploreRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Get weather information
-	weather, err := services.GetWeather(req.City)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get weather data"})
+	// An origin narrows the trip to cities within a drive, the nearest standing in for a missing city
+	destinations, ok := exploreReach(c, &req.City, services.ReachQuery{
+		Origin:        req.Origin,
+		MaxDriveHours: req.MaxDriveHours,
+		MaxDistanceKm: req.MaxDistanceKm,
+	})
+	if !ok {
 		return
 	}
 
+	trackEvent(c, services.EventMoodSelected, map[string]interface{}{"mood": strings.ToLower(req.Mood), "city": req.City, "source": "explore"})
+	experiments := joinExperiments(c, services.ExperimentSuggestionRanking)
+
+	// Get weather information, going on without it rather than failing the request
+	status := services.CityDataStatus(c.Request.Context(), req.City)
+	weather, hasWeather := exploreWeather(c, req.City, &status)
+
 	// Get events and attractions
-	events, err := services.GetEvents(req.City, req.Mood, req.Interests)
+	events, err := services.GetEvents(c.Request.Context(), req.City, req.Mood, req.Interests)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get events data"})
-		return
+		status.Degrade("Events are unavailable right now")
+		events = []services.Event{}
 	}
 
-	// Generate trip suggestions based on mood and interests
-	suggestions, err := services.GenerateTripSuggestions(req.Mood, req.City, req.Budget, req.Duration, req.Interests, weather)
+	// Generate trip suggestions based on mood and interests, reusing a recent set
+	suggestions, err := services.GetCachedSuggestions(c.Request.Context(), services.SuggestionQuery{
+		Mood:      req.Mood,
+		City:      req.City,
+		Budget:    req.Budget,
+		Duration:  req.Duration,
+		Interests: req.Interests,
+		Eco:       req.Eco,
+	}, weather)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate suggestions"})
 		return
 	}
 
 	response := ExploreResponse{
-		Suggestions: suggestions,
-		Weather:     weather,
-		Events:      events,
+		Suggestions:  suggestions,
+		Events:       events,
+		Experiments:  experiments,
+		Status:       status,
+		Destinations: destinations,
+	}
+	if hasWeather {
+		response.Weather = &weather
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -65,21 +85,113 @@ func GetExploreByMood(c *gin.Context) {
 	mood := c.Param("mood")
 	city := c.Query("city")
 
-	if city == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "City parameter is required"})
+	reach := services.ReachQuery{Origin: c.Query("origin")}
+	for key, limit := range map[string]*float64{"max_drive_hours": &reach.MaxDriveHours, "max_distance_km": &reach.MaxDistanceKm} {
+		if value := c.Query(key); value != "" {
+			var err error
+			if *limit, err = strconv.ParseFloat(value, 64); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + key + " parameter"})
+				return
+			}
+		}
+	}
+	destinations, ok := exploreReach(c, &city, reach)
+	if !ok {
 		return
 	}
 
+	trackEvent(c, services.EventMoodSelected, map[string]interface{}{"mood": strings.ToLower(mood), "city": city, "source": "mood"})
+	experiments := joinExperiments(c, services.ExperimentSuggestionRanking)
+
+	var budget float64
+	if budgetStr := c.Query("budget"); budgetStr != "" {
+		var err error
+		if budget, err = strconv.ParseFloat(budgetStr, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid budget parameter"})
+			return
+		}
+	}
+
+	status := services.CityDataStatus(c.Request.Context(), city)
+	weather, _ := exploreWeather(c, city, &status)
+
 	// Get cached suggestions or generate new ones
-	suggestions, err := services.GetCachedSuggestions(mood, city)
+	suggestions, err := services.GetCachedSuggestions(c.Request.Context(), services.SuggestionQuery{Mood: mood, City: city, Budget: budget}, weather)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get suggestions"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"mood":        mood,
-		"city":        city,
-		"suggestions": suggestions,
+		"mood":         mood,
+		"city":         city,
+		"suggestions":  suggestions,
+		"experiments":  experiments,
+		"status":       status,
+		"destinations": destinations,
 	})
 }
+
+// exploreReach lists the supported cities within a drive of the query's origin, if it has
+// one, and puts the nearest in city when none was asked for. It answers with a 400 when
+// the origin or limits are invalid, no city is given or in reach, or the given city is out
+// of reach.
+func exploreReach(c *gin.Context, city *string, query services.ReachQuery) ([]services.ReachableCity, bool) {
+	if query.Origin == "" {
+		if *city == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "city or origin is required"})
+			return nil, false
+		}
+		return nil, true
+	}
+
+	destinations, err := services.CitiesInReach(c.Request.Context(), query)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidReachQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find cities in reach: " + err.Error()})
+		return nil, false
+	}
+	if destinations == nil {
+		destinations = []services.ReachableCity{}
+	}
+
+	if *city == "" {
+		if len(destinations) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "no supported cities are within reach of " + query.Origin, "destinations": destinations})
+			return nil, false
+		}
+		*city = destinations[0].Name
+		return destinations, true
+	}
+
+	// A city we have no coordinates for can't be measured, and is explored as it is, as is
+	// the origin itself
+	if services.CityDataStatus(c.Request.Context(), *city).State != services.DataFound ||
+		services.NormalizeCityName(*city) == services.NormalizeCityName(query.Origin) {
+		return destinations, true
+	}
+	for _, destination := range destinations {
+		if services.NormalizeCityName(destination.Name) == services.NormalizeCityName(*city) {
+			return destinations, true
+		}
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": *city + " is not within reach of " + query.Origin, "destinations": destinations})
+	return nil, false
+}
+
+// exploreWeather gets a city's weather for suggestions, noting on status when it's an
+// estimate or missing. Suggestions don't need it, so a failure doesn't fail the request.
+func exploreWeather(c *gin.Context, city string, status *services.DataStatus) (services.WeatherInfo, bool) {
+	weather, err := services.GetWeather(c.Request.Context(), city)
+	if err != nil {
+		status.Degrade("Weather is unavailable for " + city + " right now")
+		return services.WeatherInfo{}, false
+	}
+	if weather.Estimated {
+		status.Degrade("Weather is based on seasonal averages rather than a live reading")
+	}
+	return weather, true
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joshndala/cantrip/pagination"
+	"github.com/joshndala/cantrip/services"
+)
+
+// IngestGuidesRequest is the JSON form of a guide ingest: each article is markdown opening
+// with YAML frontmatter
+type IngestGuidesRequest struct {
+	Articles []string `json:"articles" binding:"required"`
+}
+
+var (
+	guidePageOptions = pagination.Options{SortFields: []string{"published", "title"}, Descending: true}
+	guidePageKeys    = pagination.Keys[services.Guide]{
+		ID: func(g services.Guide) string { return g.ID },
+		Fields: map[string]func(services.Guide) string{
+			"published": func(g services.Guide) string { return g.Published },
+			"title":     func(g services.Guide) string { return strings.ToLower(g.Title) },
+		},
+		Time: func(g services.Guide) time.Time { return g.UpdatedAt },
+	}
+)
+
+// IngestGuidesHandler stores curated guide articles (admin only). Articles arrive as a JSON
+// list, as markdown files in a multipart form, or as one markdown body. Each is reported
+// on its own, so a batch with one bad article still stores the rest.
+func IngestGuidesHandler(c *gin.Context) {
+	var articles []string
+
+	contentType := c.ContentType()
+	switch {
+	case strings.HasPrefix(contentType, "multipart/"):
+		form, err := c.MultipartForm()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read upload"})
+			return
+		}
+		for _, file := range form.File["files"] {
+			opened, err := file.Open()
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read upload"})
+				return
+			}
+			data, err := io.ReadAll(io.LimitReader(opened, services.MaxGuideBytes+1))
+			opened.Close()
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read upload"})
+				return
+			}
+			articles = append(articles, string(data))
+		}
+	case contentType == "application/json":
+		var req IngestGuidesRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		articles = req.Articles
+	default:
+		data, err := io.ReadAll(io.LimitReader(c.Request.Body, services.MaxGuideBytes+1))
+		if err != nil {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body too large"})
+			return
+		}
+		if len(data) > 0 {
+			articles = append(articles, string(data))
+		}
+	}
+
+	if len(articles) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide at least one markdown article"})
+		return
+	}
+
+	results, err := services.IngestGuides(c.Request.Context(), articles)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to ingest guides: " + err.Error()})
+		return
+	}
+
+	ingested := 0
+	for _, result := range results {
+		if result.Error == "" {
+			ingested++
+			action := services.AuditActionUpdate
+			if result.Created {
+				action = services.AuditActionCreate
+			}
+			recordAudit(c, action, "guide", result.ID, nil, result)
+		}
+	}
+
+	status := http.StatusOK
+	if ingested == 0 {
+		status = http.StatusUnprocessableEntity
+	}
+	c.JSON(status, gin.H{"ingested": ingested, "results": results})
+}
+
+// ListGuidesHandler lists a city's guides without their bodies, newest first. ?tag narrows
+// the list.
+func ListGuidesHandler(c *gin.Context) {
+	params, ok := parsePage(c, guidePageOptions)
+	if !ok {
+		return
+	}
+
+	guides, err := services.ListGuides(c.Request.Context(), c.Param("city"), c.Query("tag"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list guides: " + err.Error()})
+		return
+	}
+	guides, page := pagination.Apply(guides, params, guidePageKeys)
+
+	c.JSON(http.StatusOK, gin.H{"city": c.Param("city"), "guides": guides, "total": page.Total, "pagination": page})
+}
+
+// GetGuideHandler returns a guide with its markdown body
+func GetGuideHandler(c *gin.Context) {
+	guide, err := services.GetGuide(c.Request.Context(), c.Param("city"), c.Param("slug"))
+	if err != nil {
+		if errors.Is(err, services.ErrDocumentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Guide not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get guide: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, guide)
+}
+
+// DeleteGuideHandler removes a guide (admin only)
+func DeleteGuideHandler(c *gin.Context) {
+	id := c.Param("id")
+	if err := services.DeleteGuide(c.Request.Context(), id); err != nil {
+		if errors.Is(err, services.ErrDocumentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Guide not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete guide: " + err.Error()})
+		return
+	}
+
+	recordAudit(c, services.AuditActionDelete, "guide", id, nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Guide deleted"})
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joshndala/cantrip/services"
+)
+
+// GetImagePreviewHandler serves a resized preview of a stored image, ?size=thumb (default)
+// or medium
+func GetImagePreviewHandler(c *gin.Context) {
+	preview, err := services.GetImagePreview(c.Request.Context(), c.Param("id"), c.DefaultQuery("size", services.ImageSizeThumb))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidImageSize):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrDocumentNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load image"})
+		}
+		return
+	}
+	defer preview.Content.Close()
+
+	// Previews of an image never change, so clients and CDNs can keep them
+	c.Header("Content-Type", preview.ContentType)
+	c.Header("Cache-Control", "public, max-age=604800")
+	http.ServeContent(c.Writer, c.Request, "", preview.ModTime, preview.Content)
+}
@@ -1,122 +1,71 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
-	"time"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/joshndala/cantrip/models"
+	"github.com/joshndala/cantrip/pagination"
 	"github.com/joshndala/cantrip/services"
+	"github.com/joshndala/cantrip/utils"
 )
 
-type ItineraryRequest struct {
-	City          string    `json:"city" binding:"required"`
-	StartDate     time.Time `json:"start_date" binding:"required"`
-	EndDate       time.Time `json:"end_date" binding:"required"`
-	Interests     []string  `json:"interests"`
-	Budget        float64   `json:"budget"`
-	GroupSize     int       `json:"group_size"`
-	Pace          string    `json:"pace"`          // "relaxed", "moderate", "intense"
-	Accommodation string    `json:"accommodation"` // "budget", "mid-range", "luxury"
-}
-
-type ItineraryResponse struct {
-	ID        string    `json:"id"`
-	City      string    `json:"city"`
-	StartDate time.Time `json:"start_date"`
-	EndDate   time.Time `json:"end_date"`
-	Days      []DayPlan `json:"days"`
-	TotalCost float64   `json:"total_cost"`
-	Summary   string    `json:"summary"`
-	CreatedAt time.Time `json:"created_at"`
-	PDFURL    string    `json:"pdf_url,omitempty"`
-}
-
-type DayPlan struct {
-	Day           int         `json:"day"`
-	Date          time.Time   `json:"date"`
-	Activities    []Activity  `json:"activities"`
-	Meals         []Meal      `json:"meals"`
-	Accommodation string      `json:"accommodation"`
-	Transport     []Transport `json:"transport"`
-	Notes         string      `json:"notes"`
-}
-
-type Activity struct {
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Location    string    `json:"location"`
-	StartTime   time.Time `json:"start_time"`
-	EndTime     time.Time `json:"end_time"`
-	Cost        float64   `json:"cost"`
-	Category    string    `json:"category"`
-	BookingURL  string    `json:"booking_url,omitempty"`
-}
-
-type Meal struct {
-	Type        string    `json:"type"` // breakfast, lunch, dinner, snack
-	Name        string    `json:"name"`
-	Location    string    `json:"location"`
-	Time        time.Time `json:"time"`
-	Cost        float64   `json:"cost"`
-	Cuisine     string    `json:"cuisine"`
-	Reservation bool      `json:"reservation"`
-}
-
-type Transport struct {
-	Type      string    `json:"type"` // walking, public, taxi, rental
-	From      string    `json:"from"`
-	To        string    `json:"to"`
-	StartTime time.Time `json:"start_time"`
-	EndTime   time.Time `json:"end_time"`
-	Cost      float64   `json:"cost"`
-	Duration  int       `json:"duration"` // in minutes
-}
-
 // CreateItineraryHandler generates a complete itinerary using LangGraph agent
 func CreateItineraryHandler(c *gin.Context) {
-	var req ItineraryRequest
+	var req models.ItineraryRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Validate dates
-	if req.StartDate.Before(time.Now()) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Start date cannot be in the past"})
-		return
-	}
-
-	if req.EndDate.Before(req.StartDate) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "End date must be after start date"})
+	// Trips start no earlier than today where they're going
+	if err := services.CheckTripDates(c.Request.Context(), req.City, req.StartDate, req.EndDate, ""); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Convert handler request to services request
-	servicesReq := services.ItineraryRequest{
-		City:          req.City,
-		StartDate:     req.StartDate.Format("2006-01-02"),
-		EndDate:       req.EndDate.Format("2006-01-02"),
-		Interests:     req.Interests,
-		Budget:        req.Budget,
-		GroupSize:     req.GroupSize,
-		Pace:          req.Pace,
-		Accommodation: req.Accommodation,
-	}
+	// The agent plans with calendar dates
+	servicesReq := services.NewAIItineraryRequest(req)
 
 	// Call LangGraph agent to generate itinerary
-	itinerary, err := services.GenerateItinerary(servicesReq)
+	itinerary, err := services.GenerateItinerary(c.Request.Context(), servicesReq)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate itinerary: " + err.Error()})
 		return
 	}
 
+	// Remember who owns the trip so they can be notified about it
+	if actor := requestActor(c); actor != "anonymous" {
+		itinerary.OwnerID = actor
+	}
+
 	// Save itinerary to cache/database
-	err = services.SaveItinerary(itinerary)
+	err = services.SaveItinerary(c.Request.Context(), itinerary)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save itinerary"})
 		return
 	}
 
+	// An anonymized profile of the trip feeds recommendations for similar travelers
+	if err := services.RecordTripProfile(c.Request.Context(), itinerary, req.Interests); err != nil {
+		utils.LogError("Failed to record trip profile", err)
+	}
+
+	recordAudit(c, services.AuditActionCreate, "itinerary", itinerary.ID, nil, itinerary)
+	trackEvent(c, services.EventItineraryGenerated, map[string]interface{}{
+		"city":       req.City,
+		"days":       req.Days(),
+		"interests":  req.Interests,
+		"group_size": req.GroupSize,
+		"pace":       req.Pace,
+		"transport":  req.Transport,
+		"eco":        req.Eco,
+	})
+
+	setETag(c, itinerary.Revision)
 	c.JSON(http.StatusOK, itinerary)
 }
 
@@ -128,12 +77,16 @@ func GetItineraryHandler(c *gin.Context) {
 		return
 	}
 
-	itinerary, err := services.GetItinerary(id)
+	itinerary, err := services.GetItinerary(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Itinerary not found"})
 		return
 	}
 
+	if notModified(c, itinerary.Revision) {
+		return
+	}
+	setETag(c, itinerary.Revision)
 	c.JSON(http.StatusOK, itinerary)
 }
 
@@ -145,44 +98,149 @@ func UpdateItineraryHandler(c *gin.Context) {
 		return
 	}
 
-	var req ItineraryRequest
+	var req models.ItineraryRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Convert handler request to services request
-	servicesReq := services.ItineraryRequest{
-		City:          req.City,
-		StartDate:     req.StartDate.Format("2006-01-02"),
-		EndDate:       req.EndDate.Format("2006-01-02"),
-		Interests:     req.Interests,
-		Budget:        req.Budget,
-		GroupSize:     req.GroupSize,
-		Pace:          req.Pace,
-		Accommodation: req.Accommodation,
+	// The agent plans with calendar dates
+	servicesReq := services.NewAIItineraryRequest(req)
+
+	expected, conditional, err := expectedVersion(c, req.Version)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
+	// Keep the previous version for the audit trail
+	previous, err := services.GetItinerary(c.Request.Context(), id)
+
+	// Reject edits of missing or trashed itineraries and stale edits before spending time
+	// on regeneration
+	if err != nil {
+		respondMissingDocument(c, "Itinerary", err)
+		return
+	}
+	if conditional && previous.Revision != expected {
+		respondVersionConflict(c, &services.VersionConflictError{Resource: "itinerary", ID: id, Expected: expected, Current: previous.Revision, Document: previous})
+		return
+	}
+
+	// An ongoing trip can be edited as long as its start date stays put
+	if err := services.CheckTripDates(c.Request.Context(), req.City, req.StartDate, req.EndDate, services.TripStartDate(previous)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Imported bookings stay fixed when the itinerary is regenerated
+	servicesReq.Anchors = previous.Anchors
+
 	// Regenerate itinerary with updated parameters
-	itinerary, err := services.GenerateItinerary(servicesReq)
+	itinerary, err := services.GenerateItinerary(c.Request.Context(), servicesReq)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update itinerary"})
 		return
 	}
 
-	// Preserve the original ID (this would need proper type assertion in real implementation)
-	// itinerary.ID = id
+	// Preserve the original ID and owner
+	itinerary.ID = id
+	itinerary.OwnerID = previous.OwnerID
 
-	// Save updated itinerary
-	err = services.SaveItinerary(itinerary)
+	// Save updated itinerary, re-checking the version in case of a concurrent edit
+	if conditional {
+		err = services.SaveItineraryIfRevision(c.Request.Context(), itinerary, expected)
+	} else {
+		err = services.ReplaceItinerary(c.Request.Context(), itinerary)
+	}
 	if err != nil {
+		if respondVersionConflict(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrDocumentNotFound) || errors.Is(err, services.ErrInTrash) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Itinerary not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save updated itinerary"})
 		return
 	}
 
+	recordAudit(c, services.AuditActionUpdate, "itinerary", id, previous, itinerary)
+	resyncCalendarInBackground(c, itinerary)
+
+	setETag(c, itinerary.Revision)
 	c.JSON(http.StatusOK, itinerary)
 }
 
+// RecalculateItineraryRequest changes the trip parameters that only affect cost
+type RecalculateItineraryRequest struct {
+	GroupSize     int    `json:"group_size"`
+	Currency      string `json:"currency"`      // ISO 4217 code, e.g. "USD"
+	Accommodation string `json:"accommodation"` // "budget", "mid-range", "luxury"
+	Version       *int   `json:"version,omitempty"`
+}
+
+// RecalculateItineraryHandler reprices an itinerary for a new group size, currency or
+// accommodation tier. The schedule is kept as is and the agent is not called.
+func RecalculateItineraryHandler(c *gin.Context) {
+	id := c.Param("id")
+
+	var req RecalculateItineraryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.GroupSize == 0 && req.Currency == "" && req.Accommodation == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group_size, currency or accommodation is required"})
+		return
+	}
+
+	expected, conditional, err := expectedVersion(c, req.Version)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	previous, err := services.GetItinerary(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Itinerary not found"})
+		return
+	}
+	if !conditional {
+		expected = previous.Revision
+	}
+
+	itinerary, breakdown, err := services.RecalculateItineraryCosts(c.Request.Context(), previous, services.CostRecalculation{
+		GroupSize:     req.GroupSize,
+		Currency:      req.Currency,
+		Accommodation: req.Accommodation,
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCostInput) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to recalculate costs: " + err.Error()})
+		return
+	}
+
+	if err := services.SaveItineraryIfRevision(c.Request.Context(), itinerary, expected); err != nil {
+		if respondVersionConflict(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save itinerary"})
+		return
+	}
+
+	recordAudit(c, services.AuditActionUpdate, "itinerary", id, previous, itinerary)
+
+	setETag(c, itinerary.Revision)
+	c.JSON(http.StatusOK, gin.H{
+		"itinerary": itinerary,
+		"costs":     breakdown,
+	})
+}
+
 // DeleteItineraryHandler deletes an itinerary
 func DeleteItineraryHandler(c *gin.Context) {
 	id := c.Param("id")
@@ -191,11 +249,132 @@ func DeleteItineraryHandler(c *gin.Context) {
 		return
 	}
 
-	err := services.DeleteItinerary(id)
+	previous, err := services.GetItinerary(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Itinerary not found"})
+		return
+	}
+
+	err = services.DeleteItinerary(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete itinerary"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Itinerary deleted successfully"})
+	recordAudit(c, services.AuditActionDelete, "itinerary", id, previous, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Itinerary moved to trash"})
+}
+
+// DeleteItineraryBatchHandler moves several itineraries to the trash
+func DeleteItineraryBatchHandler(c *gin.Context) {
+	var req BatchIDsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(req.IDs) == 0 || len(req.IDs) > services.MaxBatchSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("ids must contain between 1 and %d items", services.MaxBatchSize)})
+		return
+	}
+
+	results := services.RunBatch(len(req.IDs), services.DefaultBatchWorkers, func(i int) services.BatchResult {
+		id := req.IDs[i]
+		result := services.BatchResult{ID: id}
+
+		previous, err := services.GetItinerary(c.Request.Context(), id)
+		if err != nil {
+			result.Status = services.BatchStatusError
+			result.Error = "Itinerary not found"
+			return result
+		}
+
+		if err := services.DeleteItinerary(c.Request.Context(), id); err != nil {
+			result.Status = services.BatchStatusError
+			result.Error = "Failed to delete itinerary"
+			return result
+		}
+
+		recordAudit(c, services.AuditActionDelete, "itinerary", id, previous, nil)
+
+		result.Status = services.BatchStatusOK
+		return result
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": results,
+		"summary": services.BatchSummary(results),
+	})
+}
+
+// ListItineraryRevisionsHandler lists the saved revisions of an itinerary
+func ListItineraryRevisionsHandler(c *gin.Context) {
+	id := c.Param("id")
+	itinerary, err := services.GetItinerary(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Itinerary not found"})
+		return
+	}
+
+	params, ok := parsePage(c, revisionPageOptions)
+	if !ok {
+		return
+	}
+
+	revisions, err := services.ListItineraryRevisions(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list revisions: " + err.Error()})
+		return
+	}
+	revisions, page := pagination.Apply(revisions, params, revisionPageKeys)
+
+	c.JSON(http.StatusOK, gin.H{
+		"itinerary_id": id,
+		"current":      itinerary.Revision,
+		"revisions":    revisions,
+		"pagination":   page,
+	})
+}
+
+// GetItineraryDiffHandler compares two revisions of an itinerary.
+// to defaults to the current revision and from to the one before it.
+func GetItineraryDiffHandler(c *gin.Context) {
+	id := c.Param("id")
+	itinerary, err := services.GetItinerary(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Itinerary not found"})
+		return
+	}
+
+	to := itinerary.Revision
+	if value := c.Query("to"); value != "" {
+		if to, err = strconv.Atoi(value); err != nil || to < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a positive revision number"})
+			return
+		}
+	}
+	from := to - 1
+	if value := c.Query("from"); value != "" {
+		if from, err = strconv.Atoi(value); err != nil || from < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a positive revision number"})
+			return
+		}
+	}
+	if from < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Itinerary has no earlier revision to compare"})
+		return
+	}
+
+	diff, err := services.DiffItineraryRevisions(c.Request.Context(), id, from, to)
+	if err != nil {
+		if errors.Is(err, services.ErrRevisionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute diff: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, diff)
 }
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joshndala/cantrip/pagination"
+	"github.com/joshndala/cantrip/services"
+)
+
+// tripForJournal loads the trip a journal request addresses; writes require the trip
+// owner when the trip has one
+func tripForJournal(c *gin.Context, write bool) (*services.ItineraryResponse, bool) {
+	itinerary, err := services.GetItinerary(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Trip not found"})
+		return nil, false
+	}
+	if write && itinerary.OwnerID != "" && itinerary.OwnerID != requestActor(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the trip owner can change the journal"})
+		return nil, false
+	}
+	return itinerary, true
+}
+
+// respondJournalError maps journal errors to responses
+func respondJournalError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidJournalEntry), errors.Is(err, services.ErrInvalidAttachment):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDocumentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Journal entry not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action})
+	}
+}
+
+// CreateJournalEntryHandler adds a note about one day of a trip
+func CreateJournalEntryHandler(c *gin.Context) {
+	itinerary, ok := tripForJournal(c, true)
+	if !ok {
+		return
+	}
+
+	var req services.JournalEntryInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry, err := services.CreateJournalEntry(c.Request.Context(), itinerary, requestActor(c), req)
+	if err != nil {
+		respondJournalError(c, err, "save journal entry")
+		return
+	}
+
+	recordAudit(c, services.AuditActionCreate, "journal_entry", entry.ID, nil, entry)
+	c.JSON(http.StatusCreated, entry)
+}
+
+// ListJournalEntriesHandler lists a trip's journal in day order; ?day= lists one day
+func ListJournalEntriesHandler(c *gin.Context) {
+	itinerary, ok := tripForJournal(c, false)
+	if !ok {
+		return
+	}
+
+	day := 0
+	if raw := c.Query("day"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "day must be a positive integer"})
+			return
+		}
+		day = n
+	}
+	params, ok := parsePage(c, journalPageOptions)
+	if !ok {
+		return
+	}
+
+	entries, err := services.ListJournalEntries(c.Request.Context(), itinerary.ID, day)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list journal entries"})
+		return
+	}
+	entries, page := pagination.Apply(entries, params, journalPageKeys)
+
+	c.JSON(http.StatusOK, gin.H{
+		"trip_id":    itinerary.ID,
+		"entries":    entries,
+		"total":      page.Total,
+		"pagination": page,
+	})
+}
+
+// GetJournalEntryHandler returns one journal entry
+func GetJournalEntryHandler(c *gin.Context) {
+	itinerary, ok := tripForJournal(c, false)
+	if !ok {
+		return
+	}
+
+	entry, err := services.GetJournalEntry(c.Request.Context(), itinerary.ID, c.Param("entryId"))
+	if err != nil {
+		respondJournalError(c, err, "get journal entry")
+		return
+	}
+	c.JSON(http.StatusOK, entry)
+}
+
+// UpdateJournalEntryHandler replaces a journal entry's day, title, text and photos
+func UpdateJournalEntryHandler(c *gin.Context) {
+	itinerary, ok := tripForJournal(c, true)
+	if !ok {
+		return
+	}
+
+	var req services.JournalEntryInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	previous, err := services.GetJournalEntry(c.Request.Context(), itinerary.ID, c.Param("entryId"))
+	if err != nil {
+		respondJournalError(c, err, "get journal entry")
+		return
+	}
+	entry, err := services.UpdateJournalEntry(c.Request.Context(), itinerary, previous.ID, req)
+	if err != nil {
+		respondJournalError(c, err, "save journal entry")
+		return
+	}
+
+	recordAudit(c, services.AuditActionUpdate, "journal_entry", entry.ID, previous, entry)
+	c.JSON(http.StatusOK, entry)
+}
+
+// DeleteJournalEntryHandler removes a journal entry; its photos stay attached to the trip
+func DeleteJournalEntryHandler(c *gin.Context) {
+	itinerary, ok := tripForJournal(c, true)
+	if !ok {
+		return
+	}
+
+	entry, err := services.DeleteJournalEntry(c.Request.Context(), itinerary.ID, c.Param("entryId"))
+	if err != nil {
+		respondJournalError(c, err, "delete journal entry")
+		return
+	}
+
+	recordAudit(c, services.AuditActionDelete, "journal_entry", entry.ID, entry, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Journal entry deleted successfully"})
+}
+
+// UploadJournalPhotoHandler attaches a photo to the trip and adds it to a journal entry.
+// The multipart form carries the file.
+func UploadJournalPhotoHandler(c *gin.Context) {
+	itinerary, ok := tripForJournal(c, true)
+	if !ok {
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	if file.Size > services.MaxAttachmentBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Photo is larger than 10 MB"})
+		return
+	}
+	opened, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read upload"})
+		return
+	}
+	defer opened.Close()
+	data, err := io.ReadAll(opened)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read upload"})
+		return
+	}
+
+	entry, err := services.AddJournalPhoto(c.Request.Context(), itinerary, c.Param("entryId"), requestActor(c), file.Filename, data)
+	if err != nil {
+		respondJournalError(c, err, "store photo")
+		return
+	}
+
+	recordAudit(c, services.AuditActionUpdate, "journal_entry", entry.ID, nil, entry)
+	c.JSON(http.StatusCreated, entry)
+}
+
+// ExportMemoriesHandler exports a post-trip memories PDF of each day's plan with the
+// journal written about it. ?journal=false leaves out the entries and ?photos=false
+// their photos.
+func ExportMemoriesHandler(c *gin.Context) {
+	itinerary, ok := tripForJournal(c, false)
+	if !ok {
+		return
+	}
+
+	metadata, err := generatePDF(c.Request.Context(), PDFRequest{
+		Type:          "memories",
+		ID:            itinerary.ID,
+		Format:        "pdf",
+		IncludeImages: c.DefaultQuery("photos", "true") != "false",
+		Customization: map[string]interface{}{"journal": c.DefaultQuery("journal", "true") != "false"},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate PDF"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"pdf_id":  metadata.ID,
+		"pdf_url": metadata.DownloadURL,
+		"message": "Memories PDF generated successfully",
+	})
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joshndala/cantrip/pagination"
+	"github.com/joshndala/cantrip/services"
+)
+
+// requireUser returns the caller's user ID, responding with 401 when none was provided
+func requireUser(c *gin.Context) (string, bool) {
+	userID := requestActor(c)
+	if userID == "anonymous" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": UserIDHeader + " header is required"})
+		return "", false
+	}
+	return userID, true
+}
+
+// ListNotificationsHandler lists the caller's notifications, newest first
+func ListNotificationsHandler(c *gin.Context) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	params, ok := parsePage(c, notificationPageOptions)
+	if !ok {
+		return
+	}
+
+	notifications, err := services.ListNotifications(c.Request.Context(), userID, c.Query("unread") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list notifications: " + err.Error()})
+		return
+	}
+	notifications, page := pagination.Apply(notifications, params, notificationPageKeys)
+
+	c.JSON(http.StatusOK, gin.H{
+		"notifications": notifications,
+		"total":         page.Total,
+		"pagination":    page,
+	})
+}
+
+// MarkNotificationReadHandler marks one of the caller's notifications as read
+func MarkNotificationReadHandler(c *gin.Context) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	notification, err := services.MarkNotificationRead(c.Request.Context(), userID, c.Param("id"))
+	if err != nil {
+		if errors.Is(err, services.ErrDocumentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, notification)
+}
+
+// RegisterDeviceRequest is the body of a push token registration
+type RegisterDeviceRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Platform string `json:"platform" binding:"required"` // android, ios, web
+}
+
+// RegisterDeviceHandler registers a push notification token for the caller
+func RegisterDeviceHandler(c *gin.Context) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	var req RegisterDeviceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	device, err := services.RegisterDevice(c.Request.Context(), userID, req.Token, req.Platform)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, device)
+}
+
+// UnregisterDeviceHandler removes one of the caller's push tokens
+func UnregisterDeviceHandler(c *gin.Context) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	if err := services.UnregisterDevice(c.Request.Context(), userID, c.Param("token")); err != nil {
+		if errors.Is(err, services.ErrDocumentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unregister device: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Device unregistered"})
+}
+
+// ListDevicesHandler lists the caller's registered devices
+func ListDevicesHandler(c *gin.Context) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	params, ok := parsePage(c, devicePageOptions)
+	if !ok {
+		return
+	}
+
+	devices, err := services.ListDevices(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list devices: " + err.Error()})
+		return
+	}
+	devices, page := pagination.Apply(devices, params, devicePageKeys)
+
+	c.JSON(http.StatusOK, gin.H{"devices": devices, "total": page.Total, "pagination": page})
+}
+
+// GetNotificationPreferencesHandler returns the caller's notification preferences
+func GetNotificationPreferencesHandler(c *gin.Context) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, services.GetNotificationPreferences(c.Request.Context(), userID))
+}
+
+// UpdateNotificationPreferencesHandler replaces the caller's notification preferences
+func UpdateNotificationPreferencesHandler(c *gin.Context) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	// Start from the current preferences so omitted channels keep their setting
+	prefs := services.GetNotificationPreferences(c.Request.Context(), userID)
+	if err := c.ShouldBindJSON(&prefs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	prefs.UserID = userID
+
+	updated, err := services.UpdateNotificationPreferences(c.Request.Context(), prefs)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
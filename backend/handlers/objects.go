@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joshndala/cantrip/services"
+)
+
+// GetLocalObjectHandler serves an object from the local object store through a signed
+// URL, the development stand-in for GCS signed URLs
+func GetLocalObjectHandler(c *gin.Context) {
+	store := services.GetLocalObjectStore()
+	if store == nil || services.GetGCSClient() != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Local object store is not enabled"})
+		return
+	}
+
+	objectName := strings.TrimPrefix(c.Param("object"), "/")
+	query := c.Request.URL.Query()
+	file, err := store.OpenSigned(objectName, query)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidObjectSignature):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrObjectNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Object not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open object"})
+		}
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open object"})
+		return
+	}
+
+	if contentType := query.Get("response-content-type"); contentType != "" {
+		c.Header("Content-Type", contentType)
+	}
+	if disposition := query.Get("response-content-disposition"); disposition != "" {
+		c.Header("Content-Disposition", disposition)
+	}
+	http.ServeContent(c.Writer, c.Request, path.Base(objectName), info.ModTime(), file)
+}
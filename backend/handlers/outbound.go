@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joshndala/cantrip/services"
+)
+
+// AffiliateSecretHeader authenticates conversion postbacks from affiliate networks
+const AffiliateSecretHeader = "X-Affiliate-Secret"
+
+// ConversionRequest is an affiliate network conversion postback
+type ConversionRequest struct {
+	ClickID  string  `json:"click_id"`
+	Revenue  float64 `json:"revenue"`
+	Currency string  `json:"currency"`
+}
+
+// OutboundRedirectHandler records a click-through and redirects to the provider's booking page
+func OutboundRedirectHandler(c *gin.Context) {
+	eventID := c.Param("event_id")
+
+	destination, err := services.RecordClickThrough(c.Request.Context(), eventID, requestActor(c), c.GetHeader("Referer"))
+	if err != nil {
+		if errors.Is(err, services.ErrDocumentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Unknown event link"})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to resolve booking link: " + err.Error()})
+		return
+	}
+
+	// Booking pages must not be cached as the click ID differs per visit
+	c.Header("Cache-Control", "no-store")
+	c.Redirect(http.StatusFound, destination)
+}
+
+// ConversionPostbackHandler records a conversion reported by an affiliate network
+func ConversionPostbackHandler(c *gin.Context) {
+	secret := os.Getenv("AFFILIATE_POSTBACK_SECRET")
+	if secret == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Conversion postbacks are not enabled"})
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(c.GetHeader(AffiliateSecretHeader)), []byte(secret)) != 1 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid affiliate secret"})
+		return
+	}
+
+	var req ConversionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.RecordConversion(c.Request.Context(), c.Param("event_id"), req.ClickID, req.Revenue, req.Currency); err != nil {
+		if errors.Is(err, services.ErrDocumentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Unknown event link"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record conversion: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"status": "recorded"})
+}
+
+// GetOutboundStatsHandler reports click-through and conversion stats (admin only)
+func GetOutboundStatsHandler(c *gin.Context) {
+	since, ok := queryDayBound(c, "since", false)
+	if !ok {
+		return
+	}
+
+	stats, err := services.GetOutboundStats(c.Request.Context(), since, c.Query("provider"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute outbound stats: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
@@ -1,92 +1,68 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/joshndala/cantrip/models"
 	"github.com/joshndala/cantrip/services"
+	"github.com/joshndala/cantrip/utils"
 )
 
-type PackingRequest struct {
-	Destination  string   `json:"destination" binding:"required"`
-	StartDate    string   `json:"start_date" binding:"required"`
-	EndDate      string   `json:"end_date" binding:"required"`
-	Activities   []string `json:"activities"`
-	Weather      string   `json:"weather"`
-	GroupSize    int      `json:"group_size"`
-	AgeGroup     string   `json:"age_group"` // "adult", "child", "senior"
-	SpecialNeeds []string `json:"special_needs"`
-	BaggageType  string   `json:"baggage_type"` // "carry-on", "checked", "both"
-}
-
-type PackingResponse struct {
-	ID          string               `json:"id"`
-	Destination string               `json:"destination"`
-	Categories  []PackingCategory    `json:"categories"`
-	TotalItems  int                  `json:"total_items"`
-	Notes       []string             `json:"notes"`
-	Weather     services.WeatherInfo `json:"weather"`
-}
-
-type PackingCategory struct {
-	Name     string        `json:"name"`
-	Items    []PackingItem `json:"items"`
-	Priority string        `json:"priority"` // "essential", "recommended", "optional"
-	Weight   float64       `json:"weight"`   // in kg
-}
-
-type PackingItem struct {
-	Name      string  `json:"name"`
-	Quantity  int     `json:"quantity"`
-	Essential bool    `json:"essential"`
-	Notes     string  `json:"notes"`
-	Category  string  `json:"category"`
-	Weight    float64 `json:"weight"` // in kg
-	Volume    float64 `json:"volume"` // in liters
-}
-
 // GeneratePackingListHandler creates a personalized packing list
 func GeneratePackingListHandler(c *gin.Context) {
-	var req PackingRequest
+	var req models.PackingRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	if err := services.CheckTripDates(c.Request.Context(), req.Destination, req.StartDate, req.EndDate, ""); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Get weather forecast for the destination
-	weather, err := services.GetWeather(req.Destination)
+	weather, err := services.GetWeather(c.Request.Context(), req.Destination)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get weather data"})
 		return
 	}
 
-	// Convert to services.PackingRequest
-	serviceReq := services.PackingRequest{
-		Destination:  req.Destination,
-		StartDate:    req.StartDate,
-		EndDate:      req.EndDate,
-		Activities:   req.Activities,
-		Weather:      req.Weather,
-		GroupSize:    req.GroupSize,
-		AgeGroup:     req.AgeGroup,
-		SpecialNeeds: req.SpecialNeeds,
-		BaggageType:  req.BaggageType,
+	// Map free-text activities such as "backcountry camping" to packing rule categories
+	matches, err := services.ResolvePackingActivities(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to classify activities"})
+		return
 	}
 
 	// Generate packing list based on destination, weather, and activities
-	packingList, err := services.GeneratePackingList(serviceReq, weather)
+	packingList, err := services.GeneratePackingList(c.Request.Context(), req, weather)
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidTravelers) || errors.Is(err, services.ErrInvalidTripDates) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate packing list"})
 		return
 	}
+	packingList.ActivityMatches = matches
 
 	// Save packing list to cache
-	err = services.SavePackingList(packingList)
+	err = services.SavePackingList(c.Request.Context(), &packingList)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save packing list"})
 		return
 	}
 
+	recordAudit(c, services.AuditActionCreate, "packing", packingList.ID, nil, packingList)
+	scheduleRefillReminders(c, packingList)
+
+	setETag(c, packingList.Version)
 	c.JSON(http.StatusOK, packingList)
 }
 
@@ -98,12 +74,16 @@ func GetPackingListHandler(c *gin.Context) {
 		return
 	}
 
-	packingList, err := services.GetPackingList(id)
+	packingList, err := services.GetPackingList(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Packing list not found"})
 		return
 	}
 
+	if notModified(c, packingList.Version) {
+		return
+	}
+	setETag(c, packingList.Version)
 	c.JSON(http.StatusOK, packingList)
 }
 
@@ -115,51 +95,160 @@ func UpdatePackingListHandler(c *gin.Context) {
 		return
 	}
 
-	var req PackingRequest
+	var req models.PackingRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	expected, conditional, err := expectedVersion(c, req.Version)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Keep the previous version for the audit trail; missing or trashed lists aren't recreated
+	previous, err := services.GetPackingList(c.Request.Context(), id)
+	if err != nil {
+		respondMissingDocument(c, "Packing list", err)
+		return
+	}
+	if conditional && previous.Version != expected {
+		respondVersionConflict(c, &services.VersionConflictError{Resource: "packing list", ID: id, Expected: expected, Current: previous.Version, Document: previous})
+		return
+	}
+
+	// A list can keep the start date it was saved with once that has passed, so it stays
+	// editable until its trip is over; any other start must be today or later
+	if err := services.CheckTripDates(c.Request.Context(), req.Destination, req.StartDate, req.EndDate, previous.StartDate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Get updated weather data
-	weather, err := services.GetWeather(req.Destination)
+	weather, err := services.GetWeather(c.Request.Context(), req.Destination)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get weather data"})
 		return
 	}
 
-	// Convert to services.PackingRequest
-	serviceReq := services.PackingRequest{
-		Destination:  req.Destination,
-		StartDate:    req.StartDate,
-		EndDate:      req.EndDate,
-		Activities:   req.Activities,
-		Weather:      req.Weather,
-		GroupSize:    req.GroupSize,
-		AgeGroup:     req.AgeGroup,
-		SpecialNeeds: req.SpecialNeeds,
-		BaggageType:  req.BaggageType,
+	// Map free-text activities such as "backcountry camping" to packing rule categories
+	matches, err := services.ResolvePackingActivities(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to classify activities"})
+		return
 	}
 
 	// Regenerate packing list
-	packingList, err := services.GeneratePackingList(serviceReq, weather)
+	packingList, err := services.GeneratePackingList(c.Request.Context(), req, weather)
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidTravelers) || errors.Is(err, services.ErrInvalidTripDates) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update packing list"})
 		return
 	}
+	packingList.ActivityMatches = matches
 
 	packingList.ID = id // Preserve the original ID
 
-	// Save updated packing list
-	err = services.SavePackingList(packingList)
+	// Save updated packing list, re-checking the version in case of a concurrent edit
+	if conditional {
+		err = services.SavePackingListIfVersion(c.Request.Context(), &packingList, expected)
+	} else {
+		err = services.ReplacePackingList(c.Request.Context(), &packingList)
+	}
 	if err != nil {
+		if respondVersionConflict(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrDocumentNotFound) || errors.Is(err, services.ErrInTrash) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Packing list not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save updated packing list"})
 		return
 	}
 
+	recordAudit(c, services.AuditActionUpdate, "packing", id, previous, packingList)
+	scheduleRefillReminders(c, packingList)
+
+	setETag(c, packingList.Version)
 	c.JSON(http.StatusOK, packingList)
 }
 
+// DeletePackingListHandler moves a packing list to the trash
+func DeletePackingListHandler(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Packing list ID is required"})
+		return
+	}
+
+	previous, err := services.GetPackingList(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Packing list not found"})
+		return
+	}
+
+	if err := services.DeletePackingList(c.Request.Context(), id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete packing list"})
+		return
+	}
+
+	recordAudit(c, services.AuditActionDelete, "packing", id, previous, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Packing list moved to trash"})
+}
+
+// BatchIDsRequest lists IDs for a bulk operation
+type BatchIDsRequest struct {
+	IDs []string `json:"ids" binding:"required"`
+}
+
+// DeletePackingListBatchHandler moves several packing lists to the trash
+func DeletePackingListBatchHandler(c *gin.Context) {
+	var req BatchIDsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(req.IDs) == 0 || len(req.IDs) > services.MaxBatchSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("ids must contain between 1 and %d items", services.MaxBatchSize)})
+		return
+	}
+
+	results := services.RunBatch(len(req.IDs), services.DefaultBatchWorkers, func(i int) services.BatchResult {
+		id := req.IDs[i]
+		result := services.BatchResult{ID: id}
+
+		previous, err := services.GetPackingList(c.Request.Context(), id)
+		if err != nil {
+			result.Status = services.BatchStatusError
+			result.Error = "Packing list not found"
+			return result
+		}
+
+		if err := services.DeletePackingList(c.Request.Context(), id); err != nil {
+			result.Status = services.BatchStatusError
+			result.Error = "Failed to delete packing list"
+			return result
+		}
+
+		recordAudit(c, services.AuditActionDelete, "packing", id, previous, nil)
+
+		result.Status = services.BatchStatusOK
+		return result
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": results,
+		"summary": services.BatchSummary(results),
+	})
+}
+
 // GetPackingSuggestionsHandler returns packing suggestions for a destination
 func GetPackingSuggestionsHandler(c *gin.Context) {
 	destination := c.Query("destination")
@@ -171,7 +260,7 @@ func GetPackingSuggestionsHandler(c *gin.Context) {
 		return
 	}
 
-	suggestions, err := services.GetPackingSuggestions(destination, season, activities)
+	suggestions, err := services.GetPackingSuggestions(c.Request.Context(), destination, season, activities)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get packing suggestions"})
 		return
@@ -185,7 +274,10 @@ func GetPackingSuggestionsHandler(c *gin.Context) {
 	})
 }
 
-// ExportPackingListHandler exports packing list as PDF
+// ExportPackingListHandler exports packing list as PDF. For group lists, ?traveler=<name>
+// exports one traveler's items and ?traveler=shared the items the group shares.
+// ?layout=checklist prints checkboxes per traveler, and with ?format=html returns the
+// checklist as a printable page instead of a PDF.
 func ExportPackingListHandler(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
@@ -193,21 +285,349 @@ func ExportPackingListHandler(c *gin.Context) {
 		return
 	}
 
-	packingList, err := services.GetPackingList(id)
+	packingList, err := services.GetPackingList(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Packing list not found"})
 		return
 	}
 
+	layout := c.Query("layout")
+	if layout != "" && layout != services.PackingLayoutChecklist {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "layout must be checklist"})
+		return
+	}
+	format := c.DefaultQuery("format", "pdf")
+	if format != "pdf" && format != "html" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be pdf or html"})
+		return
+	}
+	if format == "html" && layout != services.PackingLayoutChecklist {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "HTML export requires layout=checklist"})
+		return
+	}
+
+	if format == "html" {
+		checklist, err := services.PackingListChecklist(packingList, c.Query("traveler"))
+		if err != nil {
+			if errors.Is(err, services.ErrDocumentNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build checklist"})
+			return
+		}
+		page, err := services.RenderPackingChecklistHTML(checklist)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render checklist"})
+			return
+		}
+		c.Data(http.StatusOK, "text/html; charset=utf-8", page)
+		return
+	}
+
+	customization := map[string]interface{}{}
+	if traveler := c.Query("traveler"); traveler != "" {
+		customization["traveler"] = traveler
+	}
+	if layout != "" {
+		customization["layout"] = layout
+	}
+
 	// Generate PDF
-	pdfURL, err := services.GeneratePackingListPDF(packingList.ID, "pdf", true, nil)
+	metadata, err := services.GeneratePackingListPDF(c.Request.Context(), packingList.ID, "pdf", true, customization)
 	if err != nil {
+		if errors.Is(err, services.ErrDocumentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate PDF"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"pdf_url": pdfURL,
+		"pdf_id":  metadata.ID,
+		"pdf_url": metadata.DownloadURL,
 		"message": "Packing list PDF generated successfully",
 	})
 }
+
+// ImportPackingListHandler merges a previous packing list (CSV or CanTrip PDF) into an
+// existing list given by packing_id, or saves it as a new list.
+func ImportPackingListHandler(c *gin.Context) {
+	var filename string
+	var data []byte
+
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+			return
+		}
+		opened, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read upload"})
+			return
+		}
+		defer opened.Close()
+		filename = file.Filename
+		if data, err = io.ReadAll(opened); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read upload"})
+			return
+		}
+	} else {
+		var err error
+		if data, err = io.ReadAll(c.Request.Body); err != nil {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body too large"})
+			return
+		}
+		if c.ContentType() == "application/pdf" {
+			filename = "import.pdf"
+		}
+	}
+	if len(data) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload a CSV or PDF packing list"})
+		return
+	}
+
+	packingID := c.DefaultPostForm("packing_id", c.Query("packing_id"))
+	destination := c.DefaultPostForm("destination", c.Query("destination"))
+
+	var before interface{}
+	if packingID != "" {
+		previous, err := services.GetPackingList(c.Request.Context(), packingID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Packing list not found"})
+			return
+		}
+		before = previous
+	}
+
+	result, err := services.ImportPackingList(c.Request.Context(), filename, data, packingID, destination)
+	if err != nil {
+		if respondVersionConflict(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrNoPackingItems) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to import packing list: " + err.Error()})
+		return
+	}
+
+	if before != nil {
+		recordAudit(c, services.AuditActionUpdate, "packing", packingID, before, result.PackingList)
+	} else {
+		recordAudit(c, services.AuditActionCreate, "packing", result.PackingList.ID, nil, result.PackingList)
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// scheduleRefillReminders queues a packing list's medication refill reminders for the
+// caller. Anonymous lists still show the reminders but nothing is sent.
+func scheduleRefillReminders(c *gin.Context, packingList services.PackingResponse) {
+	userID := requestActor(c)
+	if userID == "anonymous" {
+		return
+	}
+	if err := services.ScheduleRefillReminders(c.Request.Context(), userID, packingList); err != nil {
+		utils.LogError("Failed to schedule refill reminders for "+packingList.ID, err)
+	}
+}
+
+// ClassifyActivitiesHandler shows how free-text activity descriptions map to packing rule categories
+func ClassifyActivitiesHandler(c *gin.Context) {
+	var req struct {
+		Descriptions []string `json:"descriptions" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	matches, err := services.ClassifyActivities(c.Request.Context(), req.Descriptions)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to classify activities"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"matches":    matches,
+		"activities": services.CanonicalActivities(matches),
+	})
+}
+
+// GetBaggagePoliciesHandler lists the airline baggage allowances used by the baggage check
+func GetBaggagePoliciesHandler(c *gin.Context) {
+	policies, err := services.ListBaggagePolicies(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load baggage policies"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"airlines": policies.Airlines,
+		"currency": policies.Currency,
+		"note":     policies.Note,
+	})
+}
+
+// CheckBaggageHandler compares a packing list's estimated weight and volume with an
+// airline fare class, e.g. ?airline=westjet&fare_class=econo
+func CheckBaggageHandler(c *gin.Context) {
+	airline := c.Query("airline")
+	fareClass := c.Query("fare_class")
+	if airline == "" || fareClass == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "airline and fare_class are required"})
+		return
+	}
+
+	packingList, err := services.GetPackingList(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Packing list not found"})
+		return
+	}
+
+	check, err := services.CheckBaggage(c.Request.Context(), packingList, airline, fareClass)
+	if err != nil {
+		if errors.Is(err, services.ErrUnknownAirline) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check baggage: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, check)
+}
+
+// templateUser returns the caller's user ID for template lookups, or "" for anonymous callers
+func templateUser(c *gin.Context) string {
+	if userID := requestActor(c); userID != "anonymous" {
+		return userID
+	}
+	return ""
+}
+
+// ListPackingTemplatesHandler lists the curated packing templates and the caller's own
+func ListPackingTemplatesHandler(c *gin.Context) {
+	templates, err := services.ListPackingTemplates(c.Request.Context(), templateUser(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list packing templates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"templates": templates,
+		"total":     len(templates),
+	})
+}
+
+// GetPackingTemplateHandler returns a single packing template
+func GetPackingTemplateHandler(c *gin.Context) {
+	template, err := services.GetPackingTemplate(c.Request.Context(), templateUser(c), c.Param("templateId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Packing template not found"})
+		return
+	}
+	c.JSON(http.StatusOK, template)
+}
+
+// InstantiatePackingTemplateHandler creates a packing list from a template for a destination
+func InstantiatePackingTemplateHandler(c *gin.Context) {
+	template, err := services.GetPackingTemplate(c.Request.Context(), templateUser(c), c.Param("templateId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Packing template not found"})
+		return
+	}
+
+	var req services.PackingTemplateOptions
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	weather, err := services.GetWeather(c.Request.Context(), req.Destination)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get weather data"})
+		return
+	}
+
+	packingList, err := services.InstantiatePackingTemplate(c.Request.Context(), *template, req, weather)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidTemplateOptions) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create packing list from template"})
+		return
+	}
+
+	if err := services.SavePackingList(c.Request.Context(), &packingList); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save packing list"})
+		return
+	}
+
+	recordAudit(c, services.AuditActionCreate, "packing", packingList.ID, nil, packingList)
+
+	setETag(c, packingList.Version)
+	c.JSON(http.StatusOK, packingList)
+}
+
+// SavePackingTemplateHandler saves a packing list as one of the caller's templates
+func SavePackingTemplateHandler(c *gin.Context) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	var req services.SavePackingTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	packingList, err := services.GetPackingList(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Packing list not found"})
+		return
+	}
+
+	template, err := services.SavePackingListAsTemplate(c.Request.Context(), userID, packingList, req)
+	if err != nil {
+		if errors.Is(err, services.ErrNoPackingItems) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save packing template"})
+		return
+	}
+
+	recordAudit(c, services.AuditActionCreate, "packing_template", template.ID, nil, template)
+	c.JSON(http.StatusCreated, template)
+}
+
+// DeletePackingTemplateHandler deletes one of the caller's templates
+func DeletePackingTemplateHandler(c *gin.Context) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("templateId")
+	if err := services.DeletePackingTemplate(c.Request.Context(), userID, id); err != nil {
+		switch {
+		case errors.Is(err, services.ErrCuratedTemplate):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrDocumentNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Packing template not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete packing template"})
+		}
+		return
+	}
+
+	recordAudit(c, services.AuditActionDelete, "packing_template", id, nil, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Packing template deleted"})
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joshndala/cantrip/pagination"
+	"github.com/joshndala/cantrip/services"
+)
+
+// parsePage reads paging parameters, responding with 400 when they are invalid
+func parsePage(c *gin.Context, opts pagination.Options) (pagination.Params, bool) {
+	params, err := pagination.Parse(c.Request.URL.Query(), opts)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return params, false
+	}
+	return params, true
+}
+
+// setPageHeaders mirrors a page in headers for endpoints that return a bare array
+func setPageHeaders(c *gin.Context, page pagination.Page) {
+	c.Header("X-Total-Count", strconv.Itoa(page.Total))
+	if page.NextCursor != "" {
+		c.Header("X-Next-Cursor", page.NextCursor)
+	}
+}
+
+// Paging options and sort keys for each list endpoint
+var (
+	pdfPageOptions = pagination.Options{SortFields: []string{"created_at", "size", "filename"}, Descending: true}
+	pdfPageKeys    = pagination.Keys[services.PDFMetadata]{
+		ID: func(p services.PDFMetadata) string { return p.ID },
+		Fields: map[string]func(services.PDFMetadata) string{
+			"created_at": func(p services.PDFMetadata) string { return pagination.TimeKey(p.CreatedAt) },
+			"size":       func(p services.PDFMetadata) string { return pagination.NumberKey(float64(p.Size)) },
+			"filename":   func(p services.PDFMetadata) string { return p.Filename },
+		},
+		Time: func(p services.PDFMetadata) time.Time { return p.CreatedAt },
+	}
+
+	// Events default to the largest page, as callers used to get every event in one
+	// response; past MaxLimit, has_more and next_cursor lead to the rest
+	eventPageOptions = pagination.Options{SortFields: []string{"date", "price", "name", "rating"}, DefaultLimit: pagination.MaxLimit}
+	eventPageKeys    = pagination.Keys[services.Event]{
+		ID: func(e services.Event) string { return e.ID + "|" + e.Name + "|" + e.Date },
+		Fields: map[string]func(services.Event) string{
+			"date":   func(e services.Event) string { return e.Date + " " + e.Time },
+			"price":  func(e services.Event) string { return pagination.NumberKey(e.Price) },
+			"name":   func(e services.Event) string { return e.Name },
+			"rating": func(e services.Event) string { return pagination.NumberKey(e.Rating) },
+		},
+	}
+
+	notificationPageOptions = pagination.Options{SortFields: []string{"created_at"}, Descending: true}
+	notificationPageKeys    = pagination.Keys[services.Notification]{
+		ID: func(n services.Notification) string { return n.ID },
+		Fields: map[string]func(services.Notification) string{
+			"created_at": func(n services.Notification) string { return pagination.TimeKey(n.CreatedAt) },
+		},
+		Time: func(n services.Notification) time.Time { return n.CreatedAt },
+	}
+
+	devicePageOptions = pagination.Options{SortFields: []string{"last_seen_at", "created_at"}, Descending: true}
+	devicePageKeys    = pagination.Keys[services.Device]{
+		ID: func(d services.Device) string { return d.ID },
+		Fields: map[string]func(services.Device) string{
+			"last_seen_at": func(d services.Device) string { return pagination.TimeKey(d.LastSeenAt) },
+			"created_at":   func(d services.Device) string { return pagination.TimeKey(d.CreatedAt) },
+		},
+		Time: func(d services.Device) time.Time { return d.CreatedAt },
+	}
+
+	watchPageOptions = pagination.Options{SortFields: []string{"created_at", "event_date", "last_price"}}
+	watchPageKeys    = pagination.Keys[services.EventWatch]{
+		ID: func(w services.EventWatch) string { return w.ID },
+		Fields: map[string]func(services.EventWatch) string{
+			"created_at": func(w services.EventWatch) string { return pagination.TimeKey(w.CreatedAt) },
+			"event_date": func(w services.EventWatch) string { return w.EventDate },
+			"last_price": func(w services.EventWatch) string { return pagination.NumberKey(w.LastPrice) },
+		},
+		Time: func(w services.EventWatch) time.Time { return w.CreatedAt },
+	}
+
+	trashPageOptions = pagination.Options{SortFields: []string{"deleted_at", "purge_at"}, Descending: true}
+	trashPageKeys    = pagination.Keys[services.TrashItem]{
+		ID: func(t services.TrashItem) string { return t.Type + "/" + t.ID },
+		Fields: map[string]func(services.TrashItem) string{
+			"deleted_at": func(t services.TrashItem) string { return pagination.TimeKey(t.DeletedAt) },
+			"purge_at":   func(t services.TrashItem) string { return pagination.TimeKey(t.PurgeAt) },
+		},
+		Time: func(t services.TrashItem) time.Time { return t.DeletedAt },
+	}
+
+	feedbackPageOptions = pagination.Options{SortFields: []string{"updated_at"}, Descending: true}
+	feedbackPageKeys    = pagination.Keys[services.ChatFeedback]{
+		ID: func(f services.ChatFeedback) string { return f.ID },
+		Fields: map[string]func(services.ChatFeedback) string{
+			"updated_at": func(f services.ChatFeedback) string { return pagination.TimeKey(f.UpdatedAt) },
+		},
+		Time: func(f services.ChatFeedback) time.Time { return f.UpdatedAt },
+	}
+
+	incidentPageOptions = pagination.Options{SortFields: []string{"timestamp"}, Descending: true}
+	incidentPageKeys    = pagination.Keys[services.ModerationIncident]{
+		ID: func(i services.ModerationIncident) string { return i.ID },
+		Fields: map[string]func(services.ModerationIncident) string{
+			"timestamp": func(i services.ModerationIncident) string { return pagination.TimeKey(i.Timestamp) },
+		},
+		Time: func(i services.ModerationIncident) time.Time { return i.Timestamp },
+	}
+
+	userTipPageOptions = pagination.Options{SortFields: []string{"created_at"}, Descending: true}
+	userTipPageKeys    = pagination.Keys[services.UserTip]{
+		ID: func(t services.UserTip) string { return t.ID },
+		Fields: map[string]func(services.UserTip) string{
+			"created_at": func(t services.UserTip) string { return pagination.TimeKey(t.CreatedAt) },
+		},
+		Time: func(t services.UserTip) time.Time { return t.CreatedAt },
+	}
+
+	reviewPageOptions = pagination.Options{SortFields: []string{"updated_at", "rating"}, Descending: true}
+	reviewPageKeys    = pagination.Keys[services.Review]{
+		ID: func(r services.Review) string { return r.ID },
+		Fields: map[string]func(services.Review) string{
+			"updated_at": func(r services.Review) string { return pagination.TimeKey(r.UpdatedAt) },
+			"rating":     func(r services.Review) string { return pagination.NumberKey(float64(r.Rating)) },
+		},
+		Time: func(r services.Review) time.Time { return r.UpdatedAt },
+	}
+
+	revisionPageOptions = pagination.Options{SortFields: []string{"revision"}}
+	revisionPageKeys    = pagination.Keys[services.ItineraryRevisionSummary]{
+		ID: func(r services.ItineraryRevisionSummary) string { return pagination.NumberKey(float64(r.Revision)) },
+		Fields: map[string]func(services.ItineraryRevisionSummary) string{
+			"revision": func(r services.ItineraryRevisionSummary) string { return pagination.NumberKey(float64(r.Revision)) },
+		},
+		Time: func(r services.ItineraryRevisionSummary) time.Time { return r.SavedAt },
+	}
+
+	sessionPageOptions = pagination.Options{SortFields: []string{"last_updated", "created_at"}, Descending: true}
+	sessionPageKeys    = pagination.Keys[services.SessionSummary]{
+		ID: func(s services.SessionSummary) string { return s.SessionID },
+		Fields: map[string]func(services.SessionSummary) string{
+			"last_updated": func(s services.SessionSummary) string { return pagination.TimeKey(s.LastUpdated) },
+			"created_at":   func(s services.SessionSummary) string { return pagination.TimeKey(s.CreatedAt) },
+		},
+		Time: func(s services.SessionSummary) time.Time { return s.LastUpdated },
+	}
+
+	// The audit log's since and until are read as whole days by the handler, so entries
+	// have no Time here
+	auditPageOptions = pagination.Options{SortFields: []string{"timestamp"}, Descending: true}
+	auditPageKeys    = pagination.Keys[services.AuditEntry]{
+		ID: func(e services.AuditEntry) string { return e.ID },
+		Fields: map[string]func(services.AuditEntry) string{
+			"timestamp": func(e services.AuditEntry) string { return pagination.TimeKey(e.Timestamp) },
+		},
+	}
+
+	attachmentPageOptions = pagination.Options{SortFields: []string{"uploaded_at", "size", "filename"}, Descending: true}
+	attachmentPageKeys    = pagination.Keys[services.Attachment]{
+		ID: func(a services.Attachment) string { return a.ID },
+		Fields: map[string]func(services.Attachment) string{
+			"uploaded_at": func(a services.Attachment) string { return pagination.TimeKey(a.UploadedAt) },
+			"size":        func(a services.Attachment) string { return pagination.NumberKey(float64(a.Size)) },
+			"filename":    func(a services.Attachment) string { return a.Filename },
+		},
+		Time: func(a services.Attachment) time.Time { return a.UploadedAt },
+	}
+
+	// Entries read in trip order: by day, then as they were written
+	journalPageOptions = pagination.Options{SortFields: []string{"day", "created_at", "updated_at"}}
+	journalPageKeys    = pagination.Keys[services.JournalEntry]{
+		ID: func(e services.JournalEntry) string { return e.ID },
+		Fields: map[string]func(services.JournalEntry) string{
+			"day": func(e services.JournalEntry) string {
+				return pagination.NumberKey(float64(e.Day)) + pagination.TimeKey(e.CreatedAt)
+			},
+			"created_at": func(e services.JournalEntry) string { return pagination.TimeKey(e.CreatedAt) },
+			"updated_at": func(e services.JournalEntry) string { return pagination.TimeKey(e.UpdatedAt) },
+		},
+		Time: func(e services.JournalEntry) time.Time { return e.CreatedAt },
+	}
+
+	expensePageOptions = pagination.Options{SortFields: []string{"date", "amount", "created_at"}, Descending: true}
+	expensePageKeys    = pagination.Keys[services.Expense]{
+		ID: func(e services.Expense) string { return e.ID },
+		Fields: map[string]func(services.Expense) string{
+			"date":       func(e services.Expense) string { return e.Date + pagination.TimeKey(e.CreatedAt) },
+			"amount":     func(e services.Expense) string { return pagination.NumberKey(e.TripAmount) },
+			"created_at": func(e services.Expense) string { return pagination.TimeKey(e.CreatedAt) },
+		},
+		Time: func(e services.Expense) time.Time { return e.CreatedAt },
+	}
+)
@@ -1,15 +1,20 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/joshndala/cantrip/pagination"
 	"github.com/joshndala/cantrip/services"
 )
 
 type PDFRequest struct {
-	Type          string                 `json:"type" binding:"required"` // "itinerary", "packing", "tips"
+	Type          string                 `json:"type" binding:"required"` // "itinerary", "packing", "tips", "transcript", "memories", "summary"
 	ID            string                 `json:"id" binding:"required"`
 	Format        string                 `json:"format"` // "pdf", "html"
 	IncludeImages bool                   `json:"include_images"`
@@ -17,6 +22,7 @@ type PDFRequest struct {
 }
 
 type PDFResponse struct {
+	ID          string `json:"id"`
 	URL         string `json:"url"`
 	Filename    string `json:"filename"`
 	Size        int64  `json:"size"` // in bytes
@@ -32,35 +38,29 @@ func GeneratePDFHandler(c *gin.Context) {
 		return
 	}
 
-	var pdfURL string
-	var err error
-
-	switch req.Type {
-	case "itinerary":
-		pdfURL, err = services.GenerateItineraryPDF(req.ID, req.Format, req.IncludeImages, req.Customization)
-	case "packing":
-		pdfURL, err = services.GeneratePackingListPDF(req.ID, req.Format, req.IncludeImages, req.Customization)
-	case "tips":
-		pdfURL, err = services.GenerateTipsPDF(req.ID, req.Format, req.IncludeImages, req.Customization)
-	default:
+	if !isValidPDFType(req.Type) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid PDF type"})
 		return
 	}
 
+	metadata, err := generatePDF(c.Request.Context(), req)
 	if err != nil {
+		if respondRenderBusy(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrInvalidPDFOptions) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate PDF: " + err.Error()})
 		return
 	}
 
-	// Get file metadata
-	metadata, err := services.GetPDFMetadata(pdfURL)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get PDF metadata"})
-		return
-	}
+	recordAudit(c, services.AuditActionCreate, "pdf", req.ID, nil, metadata)
 
 	response := PDFResponse{
-		URL:         pdfURL,
+		ID:          metadata.ID,
+		URL:         metadata.DownloadURL,
 		Filename:    metadata.Filename,
 		Size:        metadata.Size,
 		ExpiresAt:   metadata.ExpiresAt.Format("2006-01-02T15:04:05Z"),
@@ -70,32 +70,141 @@ func GeneratePDFHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-// DownloadPDFHandler serves PDF files for download
-func DownloadPDFHandler(c *gin.Context) {
-	id := c.Param("id")
-	format := c.Query("format")
-	if format == "" {
-		format = "pdf"
+// PDFBatchRequest requests several PDFs at once
+type PDFBatchRequest struct {
+	Requests []PDFRequest `json:"requests" binding:"required"`
+	Workers  int          `json:"workers"`
+}
+
+// GeneratePDFBatchHandler generates multiple PDFs with a bounded worker pool
+func GeneratePDFBatchHandler(c *gin.Context) {
+	var req PDFBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
+	if len(req.Requests) == 0 || len(req.Requests) > services.MaxBatchSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("requests must contain between 1 and %d items", services.MaxBatchSize)})
+		return
+	}
+
+	workers := req.Workers
+	if workers <= 0 || workers > services.DefaultBatchWorkers {
+		workers = services.DefaultBatchWorkers
+	}
+
+	results := services.RunBatch(len(req.Requests), workers, func(i int) services.BatchResult {
+		item := req.Requests[i]
+		result := services.BatchResult{ID: item.ID}
+
+		if item.ID == "" || !isValidPDFType(item.Type) {
+			result.Status = services.BatchStatusError
+			result.Error = "Invalid PDF type or missing ID"
+			return result
+		}
+
+		metadata, err := generatePDF(c.Request.Context(), item)
+		if err != nil {
+			result.Status = services.BatchStatusError
+			result.Error = "Failed to generate PDF: " + err.Error()
+			return result
+		}
+
+		recordAudit(c, services.AuditActionCreate, "pdf", item.ID, nil, metadata)
+
+		result.Status = services.BatchStatusOK
+		result.Result = gin.H{"id": metadata.ID, "url": metadata.DownloadURL}
+		return result
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": results,
+		"summary": services.BatchSummary(results),
+	})
+}
+
+// isValidPDFType checks whether the requested document type is supported
+func isValidPDFType(pdfType string) bool {
+	switch pdfType {
+	case "itinerary", "packing", "tips", "transcript", "memories", "summary":
+		return true
+	default:
+		return false
+	}
+}
+
+// generatePDF queues generation for the requested type on the render pool
+func generatePDF(ctx context.Context, req PDFRequest) (*services.PDFMetadata, error) {
+	var metadata *services.PDFMetadata
+	err := services.GetRenderPool().Do(ctx, "pdf_"+req.Type, func(ctx context.Context) error {
+		var err error
+		switch req.Type {
+		case "itinerary":
+			metadata, err = services.GenerateItineraryPDF(ctx, req.ID, req.Format, req.IncludeImages, req.Customization)
+		case "packing":
+			metadata, err = services.GeneratePackingListPDF(ctx, req.ID, req.Format, req.IncludeImages, req.Customization)
+		case "tips":
+			metadata, err = services.GenerateTipsPDF(ctx, req.ID, req.Format, req.IncludeImages, req.Customization)
+		case "memories":
+			metadata, err = services.GenerateMemoriesPDF(ctx, req.ID, req.Format, req.IncludeImages, req.Customization)
+		case "summary":
+			metadata, err = services.GenerateTripSummaryPDF(ctx, req.ID, req.Format, req.IncludeImages, req.Customization)
+		case "transcript":
+			metadata, err = services.GenerateTranscriptPDF(ctx, req.ID, req.Format, req.IncludeImages, req.Customization)
+		default:
+			err = fmt.Errorf("invalid PDF type: %s", req.Type)
+		}
+		return err
+	})
+	return metadata, err
+}
+
+// GetRenderPoolStatsHandler reports the render pool's queue depth and throughput
+func GetRenderPoolStatsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, services.GetRenderPool().Stats())
+}
+
+// respondRenderBusy answers 503 with Retry-After when the render queue is full
+func respondRenderBusy(c *gin.Context, err error) bool {
+	var busy *services.RenderQueueFullError
+	if !errors.As(err, &busy) {
+		return false
+	}
+	c.Header("Retry-After", strconv.Itoa(int(busy.RetryAfter.Seconds())))
+	c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Too many documents are being generated, try again shortly"})
+	return true
+}
+
+// DownloadPDFHandler streams a PDF for download. Range requests resume or fetch part of
+// the file, and GCS copies are proxied without buffering them in memory.
+func DownloadPDFHandler(c *gin.Context) {
+	id := c.Param("id")
 	if id == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "File ID is required"})
 		return
 	}
 
-	// Get file from GCS
-	fileData, filename, err := services.DownloadPDF(id, format)
+	file, err := services.OpenPDF(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		if errors.Is(err, services.ErrPDFNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open PDF"})
 		return
 	}
+	defer file.Content.Close()
 
-	// Set headers for file download
-	c.Header("Content-Disposition", "attachment; filename="+filename)
-	c.Header("Content-Type", "application/pdf")
-	c.Header("Content-Length", strconv.Itoa(len(fileData)))
+	// Resumed downloads continue one already counted
+	if rangeHeader := c.GetHeader("Range"); rangeHeader == "" || strings.HasPrefix(rangeHeader, "bytes=0-") {
+		trackEvent(c, services.EventPDFDownloaded, map[string]interface{}{"type": file.Type})
+	}
 
-	c.Data(http.StatusOK, "application/pdf", fileData)
+	// ServeContent sets Content-Length and handles Range and If-Range
+	c.Header("Content-Disposition", "attachment; filename="+file.Filename)
+	c.Header("Content-Type", "application/pdf")
+	http.ServeContent(c.Writer, c.Request, file.Filename, file.ModTime, file.Content)
 }
 
 // GetPDFStatusHandler checks the status of PDF generation
@@ -106,7 +215,7 @@ func GetPDFStatusHandler(c *gin.Context) {
 		return
 	}
 
-	status, err := services.GetPDFStatus(id)
+	status, err := services.GetPDFStatus(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
 		return
@@ -123,12 +232,16 @@ func DeletePDFHandler(c *gin.Context) {
 		return
 	}
 
-	err := services.DeletePDF(id)
+	previous, _ := services.GetPDFMetadata(c.Request.Context(), id)
+
+	err := services.DeletePDF(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete PDF"})
 		return
 	}
 
+	recordAudit(c, services.AuditActionDelete, "pdf", id, previous, nil)
+
 	c.JSON(http.StatusOK, gin.H{"message": "PDF deleted successfully"})
 }
 
@@ -140,15 +253,68 @@ func ListPDFsHandler(c *gin.Context) {
 		return
 	}
 
-	pdfs, err := services.ListUserPDFs(userID)
+	params, ok := parsePage(c, pdfPageOptions)
+	if !ok {
+		return
+	}
+
+	pdfs, err := services.ListUserPDFs(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list PDFs"})
+		return
+	}
+	if typ := c.Query("type"); typ != "" {
+		pdfs = filterPDFsByType(pdfs, typ)
+	}
+	pdfs, page := pagination.Apply(pdfs, params, pdfPageKeys)
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_id":    userID,
+		"pdfs":       pdfs,
+		"pagination": page,
+	})
+}
+
+// filterPDFsByType keeps PDFs of one document type
+func filterPDFsByType(pdfs []services.PDFMetadata, typ string) []services.PDFMetadata {
+	filtered := []services.PDFMetadata{}
+	for _, pdf := range pdfs {
+		if pdf.Type == typ {
+			filtered = append(filtered, pdf)
+		}
+	}
+	return filtered
+}
+
+// ListSourcePDFsHandler lists the PDFs generated from one itinerary, packing list or tips
+// document, newest first. ?version=N limits them to one version of the document.
+func ListSourcePDFsHandler(c *gin.Context) {
+	sourceType := c.Param("type")
+	if !isValidPDFType(sourceType) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid PDF type"})
+		return
+	}
+
+	version := -1
+	if raw := c.Query("version"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "version must be a non-negative integer"})
+			return
+		}
+		version = n
+	}
+
+	pdfs, err := services.FindPDFs(c.Request.Context(), sourceType, c.Param("id"), version)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list PDFs"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"user_id": userID,
-		"pdfs":    pdfs,
+		"type": sourceType,
+		"id":   c.Param("id"),
+		"pdfs": pdfs,
 	})
 }
 
@@ -165,7 +331,7 @@ func SharePDFHandler(c *gin.Context) {
 		expiryHours = "24" // Default 24 hours
 	}
 
-	shareURL, err := services.CreateShareableLink(id, expiryHours)
+	shareURL, err := services.CreateShareableLink(c.Request.Context(), id, expiryHours)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create shareable link"})
 		return
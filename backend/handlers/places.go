@@ -1,10 +1,13 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/joshndala/cantrip/pagination"
 	"github.com/joshndala/cantrip/services"
 )
 
@@ -13,21 +16,31 @@ func GetEventsHandler(c *gin.Context) {
 	city := c.Query("city")
 	mood := c.Query("mood")
 	interests := c.QueryArray("interests")
-	date := c.Query("date")
+	date, ok := queryDate(c, "date")
+	if !ok {
+		return
+	}
 
 	if city == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "city parameter is required"})
 		return
 	}
 
-	events, err := services.GetEvents(city, mood, interests)
+	params, ok := parsePage(c, eventPageOptions)
+	if !ok {
+		return
+	}
+
+	events, err := services.GetEvents(c.Request.Context(), city, mood, interests)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get events: " + err.Error()})
 		return
 	}
+	setDataStateHeader(c, services.CityDataStatus(c.Request.Context(), city))
 
-	// Filter events by date if provided
+	// Filter events by date if provided, including festivals ticket APIs don't list yet
 	if date != "" {
+		events = services.MergeFestivalEvents(c.Request.Context(), events, city, date, date)
 		events = services.FilterEventsByDate(events, date)
 	}
 
@@ -36,9 +49,69 @@ func GetEventsHandler(c *gin.Context) {
 		events = []services.Event{}
 	}
 
+	// The body stays a bare array; paging details travel in headers
+	events, page := pagination.Apply(events, params, eventPageKeys)
+	setPageHeaders(c, page)
+
 	c.JSON(http.StatusOK, events)
 }
 
+// GetFestivalsHandler lists recurring festivals expected in a date range, optionally for one city.
+// Query parameters: start and end (YYYY-MM-DD or RFC3339) and city.
+func GetFestivalsHandler(c *gin.Context) {
+	city := c.Query("city")
+	start, ok := queryDate(c, "start")
+	if !ok {
+		return
+	}
+	end, ok := queryDate(c, "end")
+	if !ok {
+		return
+	}
+
+	if start == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start parameter is required"})
+		return
+	}
+
+	festivals, err := services.FindFestivals(c.Request.Context(), city, start, end)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidFestivalQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get festivals: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, festivals)
+}
+
+// GetNightlifeHandler lists a city's bars, clubs and late-night food grouped by neighborhood.
+// Query parameters: type (bar, club, live_music, late_food) and open_at (HH:MM).
+func GetNightlifeHandler(c *gin.Context) {
+	city := c.Param("city")
+	if city == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "city is required"})
+		return
+	}
+
+	guide, err := services.GetNightlife(c.Request.Context(), city, c.Query("type"), c.Query("open_at"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidNightlifeQuery):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrNoNightlife):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get nightlife: " + err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, guide)
+}
+
 // GenerateTripSuggestionsHandler generates trip suggestions for a city
 func GenerateTripSuggestionsHandler(c *gin.Context) {
 	city := c.Query("city")
@@ -46,6 +119,7 @@ func GenerateTripSuggestionsHandler(c *gin.Context) {
 	interests := c.QueryArray("interests")
 	budgetStr := c.Query("budget")
 	durationStr := c.Query("duration")
+	eco := c.Query("eco") == "true"
 
 	if city == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "city parameter is required"})
@@ -74,17 +148,252 @@ func GenerateTripSuggestionsHandler(c *gin.Context) {
 	}
 
 	// Get weather info for the city to pass to trip suggestions
-	weather, err := services.GetWeather(city)
+	status := services.CityDataStatus(c.Request.Context(), city)
+	weather, _ := exploreWeather(c, city, &status)
+
+	suggestions, err := services.GetCachedSuggestions(c.Request.Context(), services.SuggestionQuery{
+		Mood:      mood,
+		City:      city,
+		Budget:    budget,
+		Duration:  duration,
+		Interests: interests,
+		Eco:       eco,
+	}, weather)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get weather for trip suggestions: " + err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate trip suggestions: " + err.Error()})
 		return
 	}
+	setDataStateHeader(c, status)
 
-	suggestions, err := services.GenerateTripSuggestions(mood, city, budget, duration, interests, weather)
+	c.JSON(http.StatusOK, suggestions)
+}
+
+// ListCitiesHandler lists the supported cities with what their data covers.
+// Query parameters: province.
+func ListCitiesHandler(c *gin.Context) {
+	cities, err := services.ListSupportedCities(c.Request.Context(), c.Query("province"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate trip suggestions: " + err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list cities: " + err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, suggestions)
+	c.JSON(http.StatusOK, gin.H{"cities": cities})
+}
+
+// GetNeighborhoodsHandler lists the neighborhoods of a city with their characteristics
+func GetNeighborhoodsHandler(c *gin.Context) {
+	city := c.Param("city")
+	vibe := c.Query("vibe")
+
+	if city == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "city is required"})
+		return
+	}
+
+	neighborhoods, err := services.GetNeighborhoods(c.Request.Context(), city, vibe)
+	if err != nil {
+		if status := services.CityDataStatus(c.Request.Context(), city); status.State == services.DataUnsupportedCity {
+			respondUnsupportedCity(c, status)
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"city":          city,
+		"vibe":          vibe,
+		"neighborhoods": neighborhoods,
+	})
+}
+
+// GetAttractionsHandler lists a city's attractions with crowd levels and the best time to visit
+func GetAttractionsHandler(c *gin.Context) {
+	city := c.Param("city")
+	date, ok := queryDate(c, "date")
+	if !ok {
+		return
+	}
+
+	attractions, err := services.GetAttractionDetails(c.Request.Context(), city, date)
+	if err != nil {
+		if strings.Contains(err.Error(), "invalid date") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if status := services.CityDataStatus(c.Request.Context(), city); status.State == services.DataUnsupportedCity {
+			respondUnsupportedCity(c, status)
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"city":        city,
+		"attractions": attractions,
+	})
+}
+
+// WatchEventRequest is the body of an event watch request
+type WatchEventRequest struct {
+	City        string  `json:"city"`         // needed when the event is not in recent search results
+	TargetPrice float64 `json:"target_price"` // optional price to be alerted at
+}
+
+// WatchEventHandler tracks an event's price and availability for the caller
+func WatchEventHandler(c *gin.Context) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	var req WatchEventRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.TargetPrice < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "target_price must not be negative"})
+		return
+	}
+
+	watch, err := services.WatchEvent(c.Request.Context(), userID, c.Param("id"), req.City, req.TargetPrice)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrDocumentNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrEventNotWatchable):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to watch event: " + err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, watch)
+}
+
+// UnwatchEventHandler stops tracking an event for the caller
+func UnwatchEventHandler(c *gin.Context) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	if err := services.UnwatchEvent(c.Request.Context(), userID, c.Param("id")); err != nil {
+		if errors.Is(err, services.ErrDocumentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unwatch event: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Event unwatched"})
+}
+
+// ListEventWatchesHandler lists the events the caller is tracking
+func ListEventWatchesHandler(c *gin.Context) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	params, ok := parsePage(c, watchPageOptions)
+	if !ok {
+		return
+	}
+
+	watches, err := services.ListEventWatches(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list watched events: " + err.Error()})
+		return
+	}
+	watches, page := pagination.Apply(watches, params, watchPageKeys)
+
+	c.JSON(http.StatusOK, gin.H{"watches": watches, "total": page.Total, "pagination": page})
+}
+
+// ImportCitiesRequest is the body of an admin city import
+type ImportCitiesRequest struct {
+	Records   []services.CityImportRecord `json:"records"`    // records prepared from open data
+	OSMCities []string                    `json:"osm_cities"` // city names to look up on OpenStreetMap
+	Overwrite bool                        `json:"overwrite"`
+	DryRun    bool                        `json:"dry_run"`
+}
+
+// ImportCitiesHandler bootstraps city metadata entries from open data (admin only)
+func ImportCitiesHandler(c *gin.Context) {
+	var req ImportCitiesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(req.Records) == 0 && len(req.OSMCities) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "records or osm_cities is required"})
+		return
+	}
+
+	records := req.Records
+	var lookupErrors []string
+	for _, name := range req.OSMCities {
+		record, err := services.FetchOSMCity(c.Request.Context(), name)
+		if err != nil {
+			lookupErrors = append(lookupErrors, err.Error())
+			continue
+		}
+		records = append(records, record)
+	}
+
+	result, err := services.ImportCities(c.Request.Context(), records, services.CityImportOptions{
+		Overwrite: req.Overwrite,
+		DryRun:    req.DryRun,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import cities: " + err.Error()})
+		return
+	}
+	result.Problems = append(lookupErrors, result.Problems...)
+
+	recordAudit(c, services.AuditActionUpdate, "city_metadata", services.CityMetadataDataset, nil, result)
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ReloadCitiesHandler re-reads the city metadata after it is replaced in DATA_DIR
+func ReloadCitiesHandler(c *gin.Context) {
+	index, err := services.ReloadCityIndex(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reload cities: " + err.Error()})
+		return
+	}
+
+	recordAudit(c, services.AuditActionUpdate, "city_metadata", services.CityMetadataDataset, nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"cities":    len(index.Metadata().Cities),
+		"provinces": index.Provinces(),
+	})
+}
+
+// ReloadRulesHandler recompiles rules.json right away instead of waiting for the next check.
+// Rules that don't compile are reported and the previous ones stay in effect.
+func ReloadRulesHandler(c *gin.Context) {
+	rules, err := services.ReloadRules(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Failed to reload rules: " + err.Error()})
+		return
+	}
+
+	recordAudit(c, services.AuditActionUpdate, "rules", services.RulesDataset, nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"source":    rules.Source,
+		"loaded_at": rules.LoadedAt,
+		"moods":     len(rules.Moods),
+	})
 }
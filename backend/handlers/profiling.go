@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"net/http/pprof"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProfileHandler serves the runtime profiles of net/http/pprof under the admin group: the
+// index at /admin/debug/pprof/, then e.g. /admin/debug/pprof/heap or
+// /admin/debug/pprof/profile?seconds=30. Profiles are fetched with the admin key and
+// opened locally, e.g. `go tool pprof cpu.pprof`.
+func ProfileHandler(c *gin.Context) {
+	switch name := strings.Trim(c.Param("profile"), "/"); name {
+	case "":
+		pprof.Index(c.Writer, c.Request)
+	case "cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		// Named profiles: heap, goroutine, allocs, block, mutex and threadcreate
+		pprof.Handler(name).ServeHTTP(c.Writer, c.Request)
+	}
+}
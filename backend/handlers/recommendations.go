@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joshndala/cantrip/services"
+	"github.com/joshndala/cantrip/utils"
+)
+
+// maxRecommendationLimit bounds ?limit on recommendations
+const maxRecommendationLimit = 50
+
+// RateTripRequest rates activities from a trip's plan from 1 to 5
+type RateTripRequest struct {
+	Ratings map[string]float64 `json:"ratings" binding:"required"`
+}
+
+// GetRecommendationsHandler lists what travelers with similar interests enjoyed in a city.
+// ?interests= may repeat; ?season defaults to the current one.
+func GetRecommendationsHandler(c *gin.Context) {
+	city := c.Query("city")
+	if city == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "city parameter is required"})
+		return
+	}
+
+	season := strings.ToLower(c.Query("season"))
+	if season != "" && !utils.Contains([]string{"spring", "summer", "fall", "winter"}, season) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "season must be spring, summer, fall or winter"})
+		return
+	}
+
+	limit := services.DefaultRecommendationLimit
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxRecommendationLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(maxRecommendationLimit)})
+			return
+		}
+		limit = n
+	}
+
+	recommendations, err := services.RecommendActivities(c.Request.Context(), services.RecommendationQuery{
+		City:      city,
+		Interests: c.QueryArray("interests"),
+		Season:    season,
+		Limit:     limit,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get recommendations: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"city":            city,
+		"recommendations": recommendations,
+	})
+}
+
+// RateTripHandler records how the traveler liked activities from the trip's plan, which
+// feeds recommendations for similar travelers
+func RateTripHandler(c *gin.Context) {
+	itinerary, err := services.GetItinerary(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Trip not found"})
+		return
+	}
+	if itinerary.OwnerID != "" && itinerary.OwnerID != requestActor(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the trip owner can rate it"})
+		return
+	}
+
+	var req RateTripRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	profile, err := services.RateTripActivities(c.Request.Context(), itinerary, req.Ratings)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidTripRating) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save ratings"})
+		return
+	}
+
+	recordAudit(c, services.AuditActionUpdate, "trip_ratings", itinerary.ID, nil, req.Ratings)
+
+	c.JSON(http.StatusOK, gin.H{
+		"trip_id": itinerary.ID,
+		"ratings": profile.Ratings,
+	})
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joshndala/cantrip/pagination"
+	"github.com/joshndala/cantrip/services"
+)
+
+// RemoveReviewRequest is the body of a review takedown
+type RemoveReviewRequest struct {
+	Reason string `json:"reason"`
+}
+
+// SubmitReviewHandler records the caller's rating and review of an attraction. The
+// attraction is identified by the id GET /places/attractions/:city lists for it.
+func SubmitReviewHandler(c *gin.Context) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	var req services.ReviewSubmission
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	review, err := services.SubmitReview(c.Request.Context(), userID, c.Param("city"), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUnknownAttraction):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Attraction not found"})
+		case errors.Is(err, services.ErrInvalidReview), errors.Is(err, services.ErrReviewRejected):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrTooManyReviews):
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit review: " + err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, review)
+}
+
+// ListReviewsHandler lists an attraction's published reviews with its aggregate rating
+func ListReviewsHandler(c *gin.Context) {
+	params, ok := parsePage(c, reviewPageOptions)
+	if !ok {
+		return
+	}
+
+	id := c.Param("city")
+	reviews, rating, err := services.ListAttractionReviews(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, services.ErrUnknownAttraction) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Attraction not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list reviews: " + err.Error()})
+		return
+	}
+	reviews, page := pagination.Apply(reviews, params, reviewPageKeys)
+
+	c.JSON(http.StatusOK, gin.H{
+		"attraction_id": id,
+		"rating":        rating,
+		"reviews":       reviews,
+		"pagination":    page,
+	})
+}
+
+// RemoveReviewHandler takes a review down (admin only), with an optional reason
+func RemoveReviewHandler(c *gin.Context) {
+	var req RemoveReviewRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	id := c.Param("id")
+	review, err := services.RemoveReview(c.Request.Context(), id, requestActor(c), req.Reason)
+	if err != nil {
+		if errors.Is(err, services.ErrDocumentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove review: " + err.Error()})
+		return
+	}
+
+	recordAudit(c, services.AuditActionDelete, "review", id, nil, review)
+
+	c.JSON(http.StatusOK, review)
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joshndala/cantrip/services"
+)
+
+// SemanticSearchHandler finds attractions, events, tips and more by meaning rather than
+// keywords, e.g. ?q=quiet places to read near the water in Victoria. Optional ?city, ?kind
+// and ?limit narrow the results; a city named in q is used when ?city is absent.
+func SemanticSearchHandler(c *gin.Context) {
+	limit := services.DefaultSemanticResults
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > services.MaxSemanticResults {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(services.MaxSemanticResults)})
+			return
+		}
+		limit = n
+	}
+
+	response, err := services.SemanticSearch(c.Request.Context(), services.SemanticQuery{
+		Text:  c.Query("q"),
+		City:  c.Query("city"),
+		Kind:  c.Query("kind"),
+		Limit: limit,
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidSemanticQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// AutocompleteHandler completes city and attraction names as they're typed, e.g.
+// ?q=tor&type=city. Optional ?limit caps the results; queries under two characters
+// complete to nothing.
+func AutocompleteHandler(c *gin.Context) {
+	limit := services.DefaultCompletions
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > services.MaxCompletions {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(services.MaxCompletions)})
+			return
+		}
+		limit = n
+	}
+
+	completions, err := services.Autocomplete(c.Request.Context(), c.Query("q"), c.Query("type"), limit)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCompletionQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to autocomplete: " + err.Error()})
+		return
+	}
+
+	// Clients send a request per keystroke; let them reuse answers for a minute
+	c.Header("Cache-Control", "private, max-age=60")
+	c.JSON(http.StatusOK, gin.H{
+		"query":   c.Query("q"),
+		"results": completions,
+	})
+}
+
+// ReindexSearchHandler re-embeds search entries that changed since the index was built,
+// for example after a dataset is replaced in DATA_DIR
+func ReindexSearchHandler(c *gin.Context) {
+	stats, err := services.ReindexSemanticSearch(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reindex search: " + err.Error()})
+		return
+	}
+
+	recordAudit(c, services.AuditActionUpdate, "search_index", stats.Provider, nil, stats)
+
+	c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joshndala/cantrip/services"
+	"github.com/joshndala/cantrip/utils"
+)
+
+// PublicBaseURLEnv names the externally visible origin used in share links, e.g.
+// https://cantrip.example; without it links use the request's own host
+const PublicBaseURLEnv = "PUBLIC_BASE_URL"
+
+// publicBaseURL is the origin share links and Open Graph URLs are built on
+func publicBaseURL(c *gin.Context) string {
+	if base := os.Getenv(PublicBaseURLEnv); base != "" {
+		return strings.TrimSuffix(base, "/")
+	}
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+// CreateTripShareHandler creates a read-only public link to a trip
+func CreateTripShareHandler(c *gin.Context) {
+	itinerary, err := services.GetItinerary(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Trip not found"})
+		return
+	}
+	if itinerary.OwnerID != "" && itinerary.OwnerID != requestActor(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the trip owner can share it"})
+		return
+	}
+
+	var req services.TripShareInput
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	share, err := services.CreateTripShare(c.Request.Context(), itinerary, requestActor(c), req)
+	if errors.Is(err, services.ErrInvalidShare) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to share trip"})
+		return
+	}
+
+	recordAudit(c, services.AuditActionCreate, "trip_share", share.Token, nil, share)
+	c.JSON(http.StatusCreated, gin.H{
+		"share":     share,
+		"share_url": publicBaseURL(c) + "/share/trip/" + share.Token,
+	})
+}
+
+// RevokeTripShareHandler deletes a trip's share link, so it stops resolving
+func RevokeTripShareHandler(c *gin.Context) {
+	itinerary, err := services.GetItinerary(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Trip not found"})
+		return
+	}
+	if itinerary.OwnerID != "" && itinerary.OwnerID != requestActor(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the trip owner can revoke its share links"})
+		return
+	}
+
+	share, err := services.RevokeTripShare(c.Request.Context(), itinerary.ID, c.Param("token"))
+	if errors.Is(err, services.ErrDocumentNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke share link"})
+		return
+	}
+
+	recordAudit(c, services.AuditActionDelete, "trip_share", share.Token, share, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Share link revoked successfully"})
+}
+
+// SharedTripPageHandler serves a shared trip as a server-rendered page whose Open Graph
+// tags let the link unfurl in messaging apps
+func SharedTripPageHandler(c *gin.Context) {
+	share, itinerary, err := services.ResolveTripShare(c.Request.Context(), c.Param("token"))
+	if errors.Is(err, services.ErrShareNotFound) {
+		c.Data(http.StatusNotFound, "text/html; charset=utf-8", []byte("<!DOCTYPE html><title>Trip not found</title><p>This trip is no longer shared.</p>"))
+		return
+	}
+	if err != nil {
+		utils.LogError("Failed to resolve trip share", err)
+		c.Data(http.StatusInternalServerError, "text/html; charset=utf-8", []byte("<!DOCTYPE html><title>Error</title><p>Something went wrong.</p>"))
+		return
+	}
+
+	page, err := services.RenderSharedTripHTML(services.BuildSharedTripPage(share, itinerary, publicBaseURL(c)))
+	if err != nil {
+		utils.LogError("Failed to render shared trip", err)
+		c.Data(http.StatusInternalServerError, "text/html; charset=utf-8", []byte("<!DOCTYPE html><title>Error</title><p>Something went wrong.</p>"))
+		return
+	}
+
+	// Unfurlers cache pages; keep them short-lived so edits and revocations show up
+	c.Header("Cache-Control", "public, max-age=300")
+	c.Header("X-Robots-Tag", "noindex")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", page)
+}
+
+// GetEmbedTripHandler returns a shared trip's card for third-party sites to embed
+func GetEmbedTripHandler(c *gin.Context) {
+	share, itinerary, err := services.ResolveTripShare(c.Request.Context(), c.Param("token"))
+	if errors.Is(err, services.ErrShareNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shared trip not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load shared trip"})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	if notModified(c, itinerary.Revision) {
+		return
+	}
+	setETag(c, itinerary.Revision)
+	c.JSON(http.StatusOK, services.BuildEmbedTrip(share, itinerary, publicBaseURL(c)))
+}
@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joshndala/cantrip/services"
+)
+
+// GetTenantHandler returns the tenant serving the request and its branding, so one client
+// build can dress itself for each deployment
+func GetTenantHandler(c *gin.Context) {
+	tenant := services.TenantFromContext(c.Request.Context())
+	c.JSON(http.StatusOK, gin.H{
+		"id":       tenant.ID,
+		"name":     tenant.Name,
+		"branding": tenant.Branding,
+	})
+}
@@ -1,10 +1,17 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/joshndala/cantrip/pagination"
 	"github.com/joshndala/cantrip/services"
+	"github.com/joshndala/cantrip/utils"
 )
 
 type TipsRequest struct {
@@ -38,21 +45,21 @@ func GetTravelTipsHandler(c *gin.Context) {
 	}
 
 	// Get travel tips for the destination
-	tips, err := services.GetTravelTips(req.Destination, req.Category, req.Topics)
+	tips, err := services.GetTravelTips(c.Request.Context(), req.Destination, req.Category, req.Topics)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get travel tips"})
 		return
 	}
 
 	// Get emergency information
-	emergency, err := services.GetEmergencyInfo(req.Destination)
+	emergency, err := services.GetEmergencyInfo(c.Request.Context(), req.Destination)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get emergency information"})
 		return
 	}
 
 	// Get language information
-	language, err := services.GetLanguageInfo(req.Destination)
+	language, err := services.GetLanguageInfo(c.Request.Context(), req.Destination)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get language information"})
 		return
@@ -77,7 +84,7 @@ func GetCulturalTipsHandler(c *gin.Context) {
 		return
 	}
 
-	tips, err := services.GetCulturalTips(destination)
+	tips, err := services.GetCulturalTips(c.Request.Context(), destination)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get cultural tips"})
 		return
@@ -97,7 +104,7 @@ func GetTippingGuideHandler(c *gin.Context) {
 		return
 	}
 
-	tippingGuide, err := services.GetTippingGuide(destination)
+	tippingGuide, err := services.GetTippingGuide(c.Request.Context(), destination)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get tipping guide"})
 		return
@@ -117,7 +124,7 @@ func GetSafetyTipsHandler(c *gin.Context) {
 		return
 	}
 
-	safetyTips, err := services.GetSafetyTips(destination)
+	safetyTips, err := services.GetSafetyTips(c.Request.Context(), destination)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get safety tips"})
 		return
@@ -129,6 +136,171 @@ func GetSafetyTipsHandler(c *gin.Context) {
 	})
 }
 
+// GetHealthTipsHandler returns health and travel insurance tips for a destination
+func GetHealthTipsHandler(c *gin.Context) {
+	destination := c.Param("destination")
+	if destination == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Destination parameter is required"})
+		return
+	}
+
+	healthTips, err := services.GetHealthTips(c.Request.Context(), destination)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get health tips"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"destination": destination,
+		"health_tips": healthTips,
+	})
+}
+
+// GetInsuranceInfoHandler compares travel insurance plans for a trip. Query parameters:
+// duration (days, required), ages (comma-separated, required), trip_cost, destination,
+// plan (emergency_medical, trip_cancellation or all_inclusive) and activities.
+func GetInsuranceInfoHandler(c *gin.Context) {
+	query := services.InsuranceQuery{
+		Destination: c.Query("destination"),
+		PlanType:    c.Query("plan"),
+	}
+
+	duration, err := strconv.Atoi(c.Query("duration"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "duration must be a number of days"})
+		return
+	}
+	query.Duration = duration
+
+	for _, value := range strings.Split(c.Query("ages"), ",") {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+		age, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ages must be comma-separated whole numbers"})
+			return
+		}
+		query.Ages = append(query.Ages, age)
+	}
+
+	if value := c.Query("trip_cost"); value != "" {
+		if query.TripCost, err = strconv.ParseFloat(value, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "trip_cost must be a number"})
+			return
+		}
+	}
+
+	if value := c.Query("activities"); value != "" {
+		matches, err := services.ClassifyActivities(c.Request.Context(), strings.Split(value, ","))
+		if err == nil {
+			query.Activities = services.CanonicalActivities(matches)
+		}
+	}
+
+	comparison, err := services.CompareInsurance(c.Request.Context(), query)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidInsuranceQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compare insurance plans"})
+		return
+	}
+
+	c.JSON(http.StatusOK, comparison)
+}
+
+// GetConnectivityTipsHandler returns SIM, eSIM, coverage and Wi-Fi tips for a destination.
+// esim=true lists only carriers that sell eSIMs.
+func GetConnectivityTipsHandler(c *gin.Context) {
+	destination := c.Param("destination")
+	if destination == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Destination parameter is required"})
+		return
+	}
+
+	info, err := services.GetConnectivityInfo(c.Request.Context(), destination, c.Query("esim") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get connectivity tips"})
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+// GetDrivingInfoHandler returns a destination's driving rules, with the tire laws in effect
+// between the optional start_date and end_date query parameters
+func GetDrivingInfoHandler(c *gin.Context) {
+	destination := c.Param("destination")
+	if destination == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Destination parameter is required"})
+		return
+	}
+
+	startDate, ok := queryDate(c, "start_date")
+	if !ok {
+		return
+	}
+	endDate, ok := queryDate(c, "end_date")
+	if !ok {
+		return
+	}
+
+	advisory, err := services.GetDrivingAdvisory(c.Request.Context(), destination, startDate, endDate)
+	if err != nil {
+		if errors.Is(err, services.ErrUnknownProvince) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No driving rules for this destination"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get driving info"})
+		return
+	}
+
+	c.JSON(http.StatusOK, advisory)
+}
+
+// GetOfflineBundleHandler returns everything a visitor needs offline for a destination
+func GetOfflineBundleHandler(c *gin.Context) {
+	destination := c.Param("destination")
+	if destination == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Destination parameter is required"})
+		return
+	}
+
+	bundle, err := services.GetOfflineBundle(c.Request.Context(), destination)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build offline bundle"})
+		return
+	}
+
+	// ?format=zip packs the bundle with a trip's attachments for offline use
+	if c.Query("format") == "zip" {
+		tripID := c.Query("trip_id")
+		if tripID != "" {
+			if _, err := services.GetItinerary(c.Request.Context(), tripID); err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Trip not found"})
+				return
+			}
+		}
+
+		// Archives are built on the render pool; headers wait until a worker starts, so a
+		// full queue can still be answered with a 503
+		filename := "cantrip-offline-" + strings.ReplaceAll(strings.ToLower(destination), " ", "-") + ".zip"
+		err := services.GetRenderPool().Do(c.Request.Context(), "offline_zip", func(ctx context.Context) error {
+			c.Header("Content-Type", "application/zip")
+			c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+			return services.WriteOfflineZip(ctx, c.Writer, bundle, tripID)
+		})
+		if err != nil && !respondRenderBusy(c, err) {
+			utils.LogError("Failed to write offline bundle archive", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, bundle)
+}
+
 // GetLocalCustomsHandler returns local customs and etiquette
 func GetLocalCustomsHandler(c *gin.Context) {
 	destination := c.Param("destination")
@@ -137,7 +309,7 @@ func GetLocalCustomsHandler(c *gin.Context) {
 		return
 	}
 
-	customs, err := services.GetLocalCustoms(destination)
+	customs, err := services.GetLocalCustoms(c.Request.Context(), destination)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get local customs"})
 		return
@@ -157,7 +329,7 @@ func GetEmergencyInfoHandler(c *gin.Context) {
 		return
 	}
 
-	emergency, err := services.GetEmergencyInfo(destination)
+	emergency, err := services.GetEmergencyInfo(c.Request.Context(), destination)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get emergency info"})
 		return
@@ -177,7 +349,7 @@ func GetLanguageInfoHandler(c *gin.Context) {
 		return
 	}
 
-	language, err := services.GetLanguageInfo(destination)
+	language, err := services.GetLanguageInfo(c.Request.Context(), destination)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get language info"})
 		return
@@ -188,3 +360,122 @@ func GetLanguageInfoHandler(c *gin.Context) {
 		"language":    language,
 	})
 }
+
+// ReviewTipRequest is the body of a tip rejection
+type ReviewTipRequest struct {
+	Reason string `json:"reason"` // shown to the author
+}
+
+// SubmitTipHandler queues a traveler's tip for a destination for review
+func SubmitTipHandler(c *gin.Context) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	var req services.TipSubmission
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tip, err := services.SubmitTip(c.Request.Context(), userID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidTip), errors.Is(err, services.ErrTipNotAllowed):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrTooManyPendingTips):
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit tip: " + err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, tip)
+}
+
+// ListMyTipsHandler lists the caller's submitted tips and their review status
+func ListMyTipsHandler(c *gin.Context) {
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	params, ok := parsePage(c, userTipPageOptions)
+	if !ok {
+		return
+	}
+
+	tips, err := services.ListUserTips(c.Request.Context(), services.UserTipFilter{AuthorID: userID, Status: c.Query("status")})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list tips: " + err.Error()})
+		return
+	}
+	tips, page := pagination.Apply(tips, params, userTipPageKeys)
+
+	c.JSON(http.StatusOK, gin.H{"tips": tips, "pagination": page})
+}
+
+// ListSubmittedTipsHandler lists submitted tips for review (admin only), pending ones by
+// default. ?status=approved or rejected shows reviewed tips; ?flagged=true only those the
+// moderation rules flagged.
+func ListSubmittedTipsHandler(c *gin.Context) {
+	params, ok := parsePage(c, userTipPageOptions)
+	if !ok {
+		return
+	}
+
+	status := c.DefaultQuery("status", services.TipStatusPending)
+	if status != services.TipStatusPending && status != services.TipStatusApproved && status != services.TipStatusRejected {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be pending, approved or rejected"})
+		return
+	}
+
+	tips, err := services.ListUserTips(c.Request.Context(), services.UserTipFilter{Status: status, FlaggedOnly: c.Query("flagged") == "true"})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list tips: " + err.Error()})
+		return
+	}
+	tips, page := pagination.Apply(tips, params, userTipPageKeys)
+
+	c.JSON(http.StatusOK, gin.H{"tips": tips, "pagination": page})
+}
+
+// ApproveTipHandler publishes a pending tip (admin only)
+func ApproveTipHandler(c *gin.Context) {
+	reviewTip(c, true, "")
+}
+
+// RejectTipHandler declines a pending tip (admin only), with an optional reason for the author
+func RejectTipHandler(c *gin.Context) {
+	var req ReviewTipRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	reviewTip(c, false, req.Reason)
+}
+
+// reviewTip records an admin's decision on a tip
+func reviewTip(c *gin.Context, approve bool, reason string) {
+	id := c.Param("id")
+	tip, err := services.ReviewTip(c.Request.Context(), id, requestActor(c), approve, reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrDocumentNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Tip not found"})
+		case errors.Is(err, services.ErrTipAlreadyReviewed):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review tip: " + err.Error()})
+		}
+		return
+	}
+
+	recordAudit(c, services.AuditActionUpdate, "user_tip", id, nil, tip)
+
+	c.JSON(http.StatusOK, tip)
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joshndala/cantrip/services"
+)
+
+// SelectRentalRequest picks a rental offer for an itinerary
+type SelectRentalRequest struct {
+	OfferID string `json:"offer_id" binding:"required"`
+	RoofBox bool   `json:"roof_box"`
+	DailyKm int    `json:"daily_km"` // estimated driving per day for the fuel estimate
+	Version *int   `json:"version,omitempty"`
+}
+
+// SearchRentalsHandler lists rental cars for a city and dates, cheapest first.
+// Query parameters: city, start and end (YYYY-MM-DD or RFC3339), and an optional minimum seats.
+func SearchRentalsHandler(c *gin.Context) {
+	start, ok := queryDate(c, "start")
+	if !ok {
+		return
+	}
+	end, ok := queryDate(c, "end")
+	if !ok {
+		return
+	}
+	query := services.RentalQuery{City: c.Query("city"), StartDate: start, EndDate: end}
+	if value := c.Query("seats"); value != "" {
+		seats, err := strconv.Atoi(value)
+		if err != nil || seats < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "seats must be a positive number"})
+			return
+		}
+		query.Seats = seats
+	}
+
+	result, err := services.SearchRentals(c.Request.Context(), query)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRentalQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to search rentals"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// SelectRentalHandler adds a rental car to an itinerary, estimating fuel and repricing
+// the trip with the rental in place of transit passes
+func SelectRentalHandler(c *gin.Context) {
+	id := c.Param("id")
+
+	var req SelectRentalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	expected, conditional, err := expectedVersion(c, req.Version)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	previous, err := services.GetItinerary(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Itinerary not found"})
+		return
+	}
+	if !conditional {
+		expected = previous.Revision
+	}
+
+	itinerary, err := services.SelectRental(c.Request.Context(), previous, services.RentalSelectionRequest{
+		OfferID: req.OfferID,
+		RoofBox: req.RoofBox,
+		DailyKm: req.DailyKm,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrRentalNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidRentalQuery):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to select rental: " + err.Error()})
+		}
+		return
+	}
+
+	if err := services.SaveItineraryIfRevision(c.Request.Context(), itinerary, expected); err != nil {
+		if respondVersionConflict(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save itinerary"})
+		return
+	}
+
+	recordAudit(c, services.AuditActionUpdate, "itinerary", id, previous, itinerary)
+
+	setETag(c, itinerary.Revision)
+	c.JSON(http.StatusOK, itinerary)
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joshndala/cantrip/pagination"
+	"github.com/joshndala/cantrip/services"
+)
+
+// ListTrashHandler lists soft-deleted itineraries and packing lists
+func ListTrashHandler(c *gin.Context) {
+	itemType := c.Query("type")
+	if itemType != "" && itemType != "itinerary" && itemType != "packing" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be 'itinerary' or 'packing'"})
+		return
+	}
+
+	params, ok := parsePage(c, trashPageOptions)
+	if !ok {
+		return
+	}
+
+	items, err := services.ListTrash(c.Request.Context(), itemType)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list trash"})
+		return
+	}
+	items, page := pagination.Apply(items, params, trashPageKeys)
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":          items,
+		"pagination":     page,
+		"retention_days": int(services.TrashRetention.Hours() / 24),
+	})
+}
+
+// RestoreItineraryHandler restores an itinerary from the trash
+func RestoreItineraryHandler(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Itinerary ID is required"})
+		return
+	}
+
+	itinerary, err := services.RestoreItinerary(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Itinerary not found in trash"})
+		return
+	}
+
+	recordAudit(c, services.AuditActionRestore, "itinerary", id, nil, nil)
+
+	c.JSON(http.StatusOK, itinerary)
+}
+
+// RestorePackingListHandler restores a packing list from the trash
+func RestorePackingListHandler(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Packing list ID is required"})
+		return
+	}
+
+	packingList, err := services.RestorePackingList(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Packing list not found in trash"})
+		return
+	}
+
+	recordAudit(c, services.AuditActionRestore, "packing", id, nil, nil)
+
+	c.JSON(http.StatusOK, packingList)
+}
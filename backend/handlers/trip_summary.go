@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joshndala/cantrip/services"
+)
+
+// CompileTripSummaryHandler compiles a post-trip recap of planned against actual
+// activities and spending, the distance traveled and the journal's photos. It returns the
+// recap as JSON along with a shareable PDF of it; ?photos=false leaves the photos out of
+// the PDF.
+func CompileTripSummaryHandler(c *gin.Context) {
+	itinerary, err := services.GetItinerary(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Trip not found"})
+		return
+	}
+	if itinerary.OwnerID != "" && itinerary.OwnerID != requestActor(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the trip owner can compile its summary"})
+		return
+	}
+
+	var req services.TripSummaryRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	summary, err := services.CompileTripSummary(c.Request.Context(), itinerary, req)
+	if errors.Is(err, services.ErrInvalidTripSummary) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compile trip summary"})
+		return
+	}
+
+	metadata, err := generatePDF(c.Request.Context(), PDFRequest{
+		Type:          "summary",
+		ID:            itinerary.ID,
+		Format:        "pdf",
+		IncludeImages: c.DefaultQuery("photos", "true") != "false",
+	})
+	if err != nil {
+		if !respondRenderBusy(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate PDF"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"summary": summary,
+		"pdf_id":  metadata.ID,
+		"pdf_url": metadata.DownloadURL,
+	})
+}
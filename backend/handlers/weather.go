@@ -15,8 +15,12 @@ func GetWeatherHandler(c *gin.Context) {
 		return
 	}
 
-	weather, err := services.GetWeather(city)
+	weather, err := services.GetWeather(c.Request.Context(), city)
 	if err != nil {
+		if status := services.CityDataStatus(c.Request.Context(), city); status.State == services.DataUnsupportedCity {
+			respondUnsupportedCity(c, status)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get weather: " + err.Error()})
 		return
 	}
@@ -27,16 +31,26 @@ func GetWeatherHandler(c *gin.Context) {
 // GetWeatherForecastHandler gets weather forecast for a city and date range
 func GetWeatherForecastHandler(c *gin.Context) {
 	city := c.Query("city")
-	startDate := c.Query("start_date")
-	endDate := c.Query("end_date")
+	startDate, ok := queryDate(c, "start_date")
+	if !ok {
+		return
+	}
+	endDate, ok := queryDate(c, "end_date")
+	if !ok {
+		return
+	}
 
 	if city == "" || startDate == "" || endDate == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "city, start_date, and end_date parameters are required"})
 		return
 	}
 
-	forecast, err := services.GetWeatherForecast(city, startDate, endDate)
+	forecast, err := services.GetWeatherForecast(c.Request.Context(), city, startDate, endDate)
 	if err != nil {
+		if status := services.CityDataStatus(c.Request.Context(), city); status.State == services.DataUnsupportedCity {
+			respondUnsupportedCity(c, status)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get weather forecast: " + err.Error()})
 		return
 	}
@@ -47,16 +61,26 @@ func GetWeatherForecastHandler(c *gin.Context) {
 // GetWeatherForecastWithNotesHandler gets weather forecast with helpful notes
 func GetWeatherForecastWithNotesHandler(c *gin.Context) {
 	city := c.Query("city")
-	startDate := c.Query("start_date")
-	endDate := c.Query("end_date")
+	startDate, ok := queryDate(c, "start_date")
+	if !ok {
+		return
+	}
+	endDate, ok := queryDate(c, "end_date")
+	if !ok {
+		return
+	}
 
 	if city == "" || startDate == "" || endDate == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "city, start_date, and end_date parameters are required"})
 		return
 	}
 
-	forecast, notes, err := services.GetWeatherForecastWithNotes(city, startDate, endDate)
+	forecast, notes, err := services.GetWeatherForecastWithNotes(c.Request.Context(), city, startDate, endDate)
 	if err != nil {
+		if status := services.CityDataStatus(c.Request.Context(), city); status.State == services.DataUnsupportedCity {
+			respondUnsupportedCity(c, status)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get weather forecast: " + err.Error()})
 		return
 	}
@@ -66,3 +90,9 @@ func GetWeatherForecastWithNotesHandler(c *gin.Context) {
 		"notes":    notes,
 	})
 }
+
+// GetUpstreamDedupeStatsHandler reports how many weather and event lookups shared another
+// request's upstream call (admin only)
+func GetUpstreamDedupeStatsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, services.GetUpstreamDedupeStats())
+}
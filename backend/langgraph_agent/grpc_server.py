@@ -0,0 +1,105 @@
+"""
+gRPC server for the cantrip.agent.v1.Agent service (backend/proto/cantrip/agent/v1/agent.proto)
+
+Every method takes and returns a google.protobuf.Struct holding the same fields as the
+matching JSON route, so the handlers are registered generically rather than generated.
+"""
+
+import asyncio
+import json
+import logging
+from typing import Any, AsyncIterator, Awaitable, Callable, Dict, Tuple, Type
+
+import grpc
+from fastapi import HTTPException
+from google.protobuf import json_format, struct_pb2
+from pydantic import BaseModel, ValidationError
+
+logger = logging.getLogger(__name__)
+
+SERVICE_NAME = "cantrip.agent.v1.Agent"
+DEFAULT_PORT = 50051
+
+UnaryMethods = Dict[str, Tuple[Type[BaseModel], Callable[[Any], Awaitable[Dict[str, Any]]]]]
+StreamMethods = Dict[str, Tuple[Type[BaseModel], Callable[[Any], AsyncIterator[Dict[str, Any]]]]]
+
+
+def to_struct(data: Dict[str, Any]) -> struct_pb2.Struct:
+    """Convert a JSON-style result into a Struct, stringifying values JSON can't carry"""
+    return json_format.ParseDict(json.loads(json.dumps(data, default=str)), struct_pb2.Struct())
+
+
+def http_status_code(status_code: int) -> grpc.StatusCode:
+    """The gRPC code for an HTTP status, matching how the backend reads the JSON routes"""
+    if status_code in (400, 422):
+        return grpc.StatusCode.INVALID_ARGUMENT
+    if status_code == 404:
+        return grpc.StatusCode.NOT_FOUND
+    if status_code in (405, 501):
+        return grpc.StatusCode.UNIMPLEMENTED
+    if status_code in (408, 504):
+        return grpc.StatusCode.DEADLINE_EXCEEDED
+    if status_code in (429, 502, 503):
+        return grpc.StatusCode.UNAVAILABLE
+    if 400 <= status_code < 500:
+        return grpc.StatusCode.FAILED_PRECONDITION
+    return grpc.StatusCode.INTERNAL
+
+
+async def parse_request(model: Type[BaseModel], request: struct_pb2.Struct, context) -> BaseModel:
+    """Validate a Struct request against the route's model, aborting with INVALID_ARGUMENT"""
+    try:
+        return model(**json_format.MessageToDict(request))
+    except (ValidationError, TypeError) as e:
+        message = str(e)
+    await context.abort(grpc.StatusCode.INVALID_ARGUMENT, message)
+
+
+def unary_handler(name: str, model: Type[BaseModel], handler) -> grpc.RpcMethodHandler:
+    """Serve a JSON route as a unary method, bounded by the caller's deadline"""
+    async def call(request: struct_pb2.Struct, context) -> struct_pb2.Struct:
+        payload = await parse_request(model, request, context)
+        try:
+            result = await asyncio.wait_for(handler(payload), timeout=context.time_remaining())
+            return to_struct(result)
+        except asyncio.TimeoutError:
+            code, message = grpc.StatusCode.DEADLINE_EXCEEDED, f"{name} exceeded its deadline"
+        except HTTPException as e:
+            code, message = http_status_code(e.status_code), str(e.detail)
+        except Exception as e:
+            logger.error(f"Error handling gRPC call {name}: {str(e)}")
+            code, message = grpc.StatusCode.INTERNAL, str(e)
+        await context.abort(code, message)
+
+    return grpc.unary_unary_rpc_method_handler(
+        call,
+        request_deserializer=struct_pb2.Struct.FromString,
+        response_serializer=struct_pb2.Struct.SerializeToString,
+    )
+
+
+def stream_handler(model: Type[BaseModel], chunks) -> grpc.RpcMethodHandler:
+    """Serve a chunk generator as a server-streaming method"""
+    async def call(request: struct_pb2.Struct, context) -> AsyncIterator[struct_pb2.Struct]:
+        payload = await parse_request(model, request, context)
+        async for chunk in chunks(payload):
+            yield to_struct(chunk)
+
+    return grpc.unary_stream_rpc_method_handler(
+        call,
+        request_deserializer=struct_pb2.Struct.FromString,
+        response_serializer=struct_pb2.Struct.SerializeToString,
+    )
+
+
+async def start_agent_server(unary: UnaryMethods, streams: StreamMethods, port: int = DEFAULT_PORT) -> grpc.aio.Server:
+    """Start serving the Agent service; methods missing from both maps answer UNIMPLEMENTED"""
+    handlers = {name: unary_handler(name, model, handler) for name, (model, handler) in unary.items()}
+    handlers.update({name: stream_handler(model, chunks) for name, (model, chunks) in streams.items()})
+
+    server = grpc.aio.server()
+    server.add_generic_rpc_handlers((grpc.method_handlers_generic_handler(SERVICE_NAME, handlers),))
+    server.add_insecure_port(f"[::]:{port}")
+    await server.start()
+    logger.info(f"Serving {SERVICE_NAME} over gRPC on port {port}")
+    return server
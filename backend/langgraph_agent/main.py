@@ -25,6 +25,8 @@ from tools.events import EventsTool
 from tools.attractions import AttractionsTool
 from tools.planner import PlanningTool
 from eval.phoenix_adapter import PhoenixAdapter
+from grpc_server import DEFAULT_PORT as DEFAULT_GRPC_PORT, start_agent_server
+import tasks
 
 # Configure logging
 logging.basicConfig(
@@ -79,6 +81,22 @@ class PackingRequest(BaseModel):
     age_group: str = "adult"
     special_needs: List[str] = []
 
+class ParseBookingsRequest(BaseModel):
+    text: str
+    source: str = "email"
+    city: str = ""
+    timezone: str = ""
+
+class ClassifyActivitiesRequest(BaseModel):
+    descriptions: List[str]
+    categories: List[str]
+
+class SummarizeConversationRequest(BaseModel):
+    summary: str = ""
+    messages: List[Dict[str, Any]] = []
+    trip_details: Dict[str, Any] = {}
+    max_length: int = 1200
+
 @app.get("/health")
 async def health_check():
     """Health check endpoint"""
@@ -213,59 +231,60 @@ async def chat_endpoint(request: ChatRequest):
         logger.error(f"Error processing chat message: {str(e)}")
         raise HTTPException(status_code=500, detail=str(e))
 
+async def chat_stream_chunks(request: ChatRequest):
+    """Yield the chat reply as token chunks, then a done chunk, or an error chunk on failure"""
+    try:
+        logger.info(f"Processing streaming chat message for session {request.session_id}")
+        
+        # Prepare the input for the graph
+        graph_input = {
+            "message": request.message,
+            "session_id": request.session_id,
+            "context": request.context,
+            "history": request.history,
+            "task": "chat"
+        }
+        
+        # Run the graph
+        result = await travel_graph.run(graph_input)
+        response_text = result.get("response", "I'm here to help with your travel planning!")
+        
+        # Stream the response word by word
+        words = response_text.split()
+        for i, word in enumerate(words):
+            # Create a chunk with the word and metadata
+            yield {
+                "type": "token",
+                "content": word + (" " if i < len(words) - 1 else ""),
+                "session_id": request.session_id,
+                "intent": result.get("intent", "general"),
+                "confidence": result.get("confidence", 0.8),
+                "timestamp": result.get("timestamp")
+            }
+            await asyncio.sleep(0.05)  # Small delay to simulate streaming
+        
+        # Send final metadata
+        yield {
+            "type": "done",
+            "suggestions": result.get("suggestions", []),
+            "data": result.get("data", {}),
+            "session_id": request.session_id
+        }
+        
+    except Exception as e:
+        logger.error(f"Error processing streaming chat message: {str(e)}")
+        yield {
+            "type": "error",
+            "content": "I'm sorry, I'm having trouble connecting right now. Please try again in a moment.",
+            "session_id": request.session_id
+        }
+
 @app.post("/chat/stream")
 async def chat_stream_endpoint(request: ChatRequest):
     """Handle streaming conversational chat with the travel agent"""
     async def generate_stream():
-        try:
-            logger.info(f"Processing streaming chat message for session {request.session_id}")
-            
-            # Prepare the input for the graph
-            graph_input = {
-                "message": request.message,
-                "session_id": request.session_id,
-                "context": request.context,
-                "history": request.history,
-                "task": "chat"
-            }
-            
-            # Run the graph
-            result = await travel_graph.run(graph_input)
-            response_text = result.get("response", "I'm here to help with your travel planning!")
-            
-            # Stream the response word by word
-            words = response_text.split()
-            for i, word in enumerate(words):
-                # Create a chunk with the word and metadata
-                chunk = {
-                    "type": "token",
-                    "content": word + (" " if i < len(words) - 1 else ""),
-                    "session_id": request.session_id,
-                    "intent": result.get("intent", "general"),
-                    "confidence": result.get("confidence", 0.8),
-                    "timestamp": result.get("timestamp")
-                }
-                
-                yield f"data: {json.dumps(chunk)}\n\n"
-                await asyncio.sleep(0.05)  # Small delay to simulate streaming
-            
-            # Send final metadata
-            final_chunk = {
-                "type": "done",
-                "suggestions": result.get("suggestions", []),
-                "data": result.get("data", {}),
-                "session_id": request.session_id
-            }
-            yield f"data: {json.dumps(final_chunk)}\n\n"
-            
-        except Exception as e:
-            logger.error(f"Error processing streaming chat message: {str(e)}")
-            error_chunk = {
-                "type": "error",
-                "content": "I'm sorry, I'm having trouble connecting right now. Please try again in a moment.",
-                "session_id": request.session_id
-            }
-            yield f"data: {json.dumps(error_chunk)}\n\n"
+        async for chunk in chat_stream_chunks(request):
+            yield f"data: {json.dumps(chunk)}\n\n"
     
     return StreamingResponse(
         generate_stream(),
@@ -327,6 +346,73 @@ async def generate_packing_list(request: PackingRequest):
         logger.error(f"Error generating packing list: {str(e)}")
         raise HTTPException(status_code=500, detail=str(e))
 
+@app.post("/parse-bookings")
+async def parse_bookings(request: ParseBookingsRequest):
+    """Extract flight and hotel bookings from confirmation text"""
+    try:
+        logger.info(f"Parsing bookings from {request.source}")
+        bookings = await tasks.parse_bookings(request.text, request.source, request.city, request.timezone)
+        return {"bookings": bookings}
+    except Exception as e:
+        logger.error(f"Error parsing bookings: {str(e)}")
+        raise HTTPException(status_code=500, detail=str(e))
+
+@app.post("/classify-activities")
+async def classify_activities(request: ClassifyActivitiesRequest):
+    """Map free-text activities to packing activity categories"""
+    if not request.categories:
+        raise HTTPException(status_code=400, detail="categories are required")
+    try:
+        logger.info(f"Classifying {len(request.descriptions)} activities")
+        matches = await tasks.classify_activities(request.descriptions, request.categories)
+        return {"matches": matches}
+    except Exception as e:
+        logger.error(f"Error classifying activities: {str(e)}")
+        raise HTTPException(status_code=500, detail=str(e))
+
+@app.post("/summarize-conversation")
+async def summarize_conversation(request: SummarizeConversationRequest):
+    """Fold older chat messages into the session's running summary"""
+    try:
+        logger.info(f"Summarizing {len(request.messages)} chat messages")
+        summary = await tasks.summarize_conversation(request.summary, request.messages, request.trip_details, request.max_length)
+        return {"summary": summary}
+    except Exception as e:
+        logger.error(f"Error summarizing conversation: {str(e)}")
+        raise HTTPException(status_code=500, detail=str(e))
+
+# gRPC agent contract (cantrip.agent.v1), served beside the HTTP routes
+AGENT_GRPC_PORT = int(os.getenv("AGENT_GRPC_PORT", DEFAULT_GRPC_PORT))
+
+# Contract methods and the request model / handler each one maps to
+CONTRACT_METHODS = {
+    "GenerateItinerary": (ItineraryRequest, generate_itinerary),
+    "ExploreDestination": (ExploreRequest, explore_destination),
+    "Chat": (ChatRequest, chat_endpoint),
+    "GeneratePackingList": (PackingRequest, generate_packing_list),
+    "ParseBookings": (ParseBookingsRequest, parse_bookings),
+    "ClassifyActivities": (ClassifyActivitiesRequest, classify_activities),
+    "SummarizeConversation": (SummarizeConversationRequest, summarize_conversation),
+}
+
+CONTRACT_STREAM_METHODS = {
+    "ChatStream": (ChatRequest, chat_stream_chunks),
+}
+
+grpc_server = None
+
+@app.on_event("startup")
+async def start_grpc_server():
+    """Start the gRPC contract server alongside FastAPI"""
+    global grpc_server
+    grpc_server = await start_agent_server(CONTRACT_METHODS, CONTRACT_STREAM_METHODS, AGENT_GRPC_PORT)
+
+@app.on_event("shutdown")
+async def stop_grpc_server():
+    """Stop the gRPC contract server, letting in-flight calls finish"""
+    if grpc_server is not None:
+        await grpc_server.stop(grace=5)
+
 @app.get("/tools/recommendations")
 async def get_recommendations(city: str, category: str = "all"):
     """Get recommendations for a specific city and category"""
@@ -0,0 +1,147 @@
+"""
+Structured tasks the backend asks the agent for alongside planning and chat: extracting
+bookings from confirmation text, classifying free-text activities and summarizing long
+conversations. Each asks the model for JSON and checks it against the contract shapes.
+"""
+
+import json
+import logging
+import re
+from datetime import datetime
+from typing import Any, Dict, List, Optional
+
+from langchain_core.messages import HumanMessage, SystemMessage
+
+from model_router import model_router
+
+logger = logging.getLogger(__name__)
+
+BOOKING_TYPES = ("flight", "hotel")
+
+
+def parse_json_reply(text: str) -> Any:
+    """Read a JSON reply, with or without a markdown code fence around it"""
+    text = text.strip()
+    fenced = re.search(r"```(?:json)?\s*(.*?)```", text, re.DOTALL)
+    if fenced:
+        text = fenced.group(1).strip()
+    return json.loads(text)
+
+
+async def ask_json(agent_type: str, system_prompt: str, user_prompt: str) -> Any:
+    """Ask the agent's model for a JSON answer"""
+    llm = model_router.get_model_for_agent(agent_type, prompt_text=user_prompt)
+    reply = await llm.ainvoke([SystemMessage(content=system_prompt), HumanMessage(content=user_prompt)])
+    try:
+        return parse_json_reply(reply.content)
+    except ValueError as e:
+        raise ValueError(f"model reply for {agent_type} was not JSON: {e}")
+
+
+def parse_timestamp(value: Any) -> Optional[str]:
+    """Normalize an ISO 8601 timestamp with an offset to RFC 3339, or None when it isn't one"""
+    if not isinstance(value, str) or not value:
+        return None
+    try:
+        parsed = datetime.fromisoformat(value.replace("Z", "+00:00"))
+    except ValueError:
+        return None
+    if parsed.tzinfo is None:
+        return None
+    return parsed.isoformat()
+
+
+async def parse_bookings(text: str, source: str, city: str = "", timezone: str = "") -> List[Dict[str, Any]]:
+    """Extract flights and hotel stays from confirmation text"""
+    system_prompt = (
+        "You extract travel bookings from confirmation emails and calendar entries. "
+        "Reply with JSON only: {\"bookings\": [...]}. Each booking has type (flight or hotel), "
+        "title, confirmation, provider, flight_number, origin, destination, address, "
+        "start and end. start is the departure or check-in and end the arrival or check-out, "
+        "both ISO 8601 timestamps with a UTC offset. Leave out anything that isn't a flight "
+        "or hotel, and use empty strings for fields the text doesn't give."
+    )
+    user_prompt = f"""
+    Local times without an offset are in {timezone or "the traveler's timezone"}{f", near {city}" if city else ""}.
+    Source: {source}
+
+    {text}
+    """
+    result = await ask_json("bookings", system_prompt, user_prompt)
+    raw = result.get("bookings", []) if isinstance(result, dict) else result
+
+    bookings = []
+    for booking in raw if isinstance(raw, list) else []:
+        if not isinstance(booking, dict) or booking.get("type") not in BOOKING_TYPES:
+            continue
+        start = parse_timestamp(booking.get("start"))
+        if start is None:
+            continue
+        bookings.append({
+            "type": booking["type"],
+            "title": str(booking.get("title") or ""),
+            "confirmation": str(booking.get("confirmation") or ""),
+            "provider": str(booking.get("provider") or ""),
+            "flight_number": str(booking.get("flight_number") or ""),
+            "origin": str(booking.get("origin") or ""),
+            "destination": str(booking.get("destination") or ""),
+            "address": str(booking.get("address") or ""),
+            "start": start,
+            "end": parse_timestamp(booking.get("end")) or start,
+            "source": source,
+        })
+    return bookings
+
+
+async def classify_activities(descriptions: List[str], categories: List[str]) -> List[Dict[str, Any]]:
+    """Map each description to the packing activity categories it involves, in request order"""
+    system_prompt = (
+        "You map travel activities to packing categories. Reply with JSON only: "
+        "{\"matches\": [{\"input\": ..., \"activities\": [...], \"confidence\": 0.0-1.0}]}, one "
+        "match per description in the order given. Only use these categories: "
+        + ", ".join(categories)
+        + ". Give an empty list when none apply."
+    )
+    user_prompt = "\n".join(f"{i + 1}. {description}" for i, description in enumerate(descriptions))
+    result = await ask_json("classifier", system_prompt, user_prompt)
+    raw = result.get("matches", []) if isinstance(result, dict) else result
+    raw = raw if isinstance(raw, list) else []
+
+    matches = []
+    for i, description in enumerate(descriptions):
+        match = raw[i] if i < len(raw) and isinstance(raw[i], dict) else {}
+        activities = [a for a in match.get("activities") or [] if a in categories]
+        try:
+            confidence = min(max(float(match.get("confidence", 0.7)), 0.0), 1.0)
+        except (TypeError, ValueError):
+            confidence = 0.7
+        matches.append({
+            "input": description,
+            "activities": list(dict.fromkeys(activities)),
+            "source": "agent",
+            "confidence": confidence if activities else 0.0,
+        })
+    return matches
+
+
+async def summarize_conversation(summary: str, messages: List[Dict[str, Any]], trip_details: Dict[str, Any], max_length: int) -> str:
+    """Fold older chat messages into the running summary, keeping the trip details"""
+    system_prompt = (
+        "You keep a short running summary of a travel planning conversation. Merge the "
+        "previous summary with the new messages, keep every destination, date, budget, "
+        "traveler and interest mentioned, and drop small talk. Reply with JSON only: "
+        f"{{\"summary\": ...}}, at most {max_length} characters."
+    )
+    transcript = "\n".join(f"{m.get('role', 'user')}: {m.get('message', '')}" for m in messages)
+    user_prompt = f"""
+    Previous summary:
+    {summary or "(none)"}
+
+    Trip details: {json.dumps(trip_details)}
+
+    New messages:
+    {transcript}
+    """
+    result = await ask_json("summary", system_prompt, user_prompt)
+    text = result.get("summary", "") if isinstance(result, dict) else ""
+    return str(text).strip()[:max_length] if max_length > 0 else str(text).strip()
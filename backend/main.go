@@ -1,19 +1,84 @@
 package main
 
 import (
+	"context"
 	"log"
+	"net/http"
+	"os"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/joshndala/cantrip/middleware"
 	"github.com/joshndala/cantrip/router"
+	"github.com/joshndala/cantrip/services"
+)
+
+// HTTP server timeouts to protect against slow clients
+const (
+	serverReadHeaderTimeout = 10 * time.Second
+	serverReadTimeout       = 30 * time.Second
+	serverWriteTimeout      = 120 * time.Second
+	serverIdleTimeout       = 120 * time.Second
+	serverMaxHeaderBytes    = 1 << 20 // 1 MB
 )
 
 func main() {
+	// Serve the tenants in TENANTS_FILE, if any
+	if err := services.LoadTenants(); err != nil {
+		log.Fatalf("Tenant configuration failed: %v", err)
+	}
+
+	for _, tenant := range services.Tenants() {
+		ctx := services.WithTenant(context.Background(), tenant)
+
+		// Fail fast if seed datasets are missing
+		if err := services.CheckDatasets(ctx); err != nil {
+			log.Fatalf("Dataset check failed for tenant %s: %v", tenant.ID, err)
+		}
+		for name, source := range services.DatasetSources(ctx) {
+			log.Printf("Dataset %s loaded from %s for tenant %s", name, source, tenant.ID)
+		}
+
+		// Validate dataset schema versions and structure
+		warnings, err := services.ValidateDatasets(ctx)
+		for _, warning := range warnings {
+			log.Printf("Dataset warning for tenant %s: %s", tenant.ID, warning)
+		}
+		if err != nil {
+			if os.Getenv("DATA_VALIDATION") != services.DataValidationWarn {
+				log.Fatalf("Dataset validation failed for tenant %s (set DATA_VALIDATION=%s to start degraded): %v", tenant.ID, services.DataValidationWarn, err)
+			}
+			log.Printf("Starting degraded, dataset validation failed for tenant %s: %v", tenant.ID, err)
+		}
+
+		// Index city metadata once rather than parsing it per lookup
+		if _, err := services.GetCityIndex(ctx); err != nil {
+			log.Printf("City index not built for tenant %s: %v", tenant.ID, err)
+		}
+	}
+
+	// Store documents and PDFs in GCS when a bucket is configured
+	if os.Getenv("GCS_BUCKET_NAME") != "" {
+		if err := services.InitializeGCS(); err != nil {
+			log.Printf("GCS disabled, using local storage: %v", err)
+		} else if err := services.ApplyGCSLifecycle(context.Background(), services.GCSLifecycleFromEnv()); err != nil {
+			log.Printf("GCS lifecycle not applied: %v", err)
+		}
+	}
+
+	// Export traces over OTLP when a collector is configured
+	if _, err := services.InitTracing(context.Background()); err != nil {
+		log.Printf("Tracing disabled: %v", err)
+	}
+
 	// Set Gin mode
 	gin.SetMode(gin.ReleaseMode)
 
-	// Create router
-	r := gin.Default()
+	// Create router; panics are recovered and reported rather than only logged
+	r := gin.New()
+	r.Use(gin.Logger())
+	r.Use(middleware.Recovery())
 
 	// Disable automatic trailing slash redirect to prevent CORS issues
 	r.RedirectTrailingSlash = false
@@ -22,12 +87,23 @@ func main() {
 	config := cors.DefaultConfig()
 	config.AllowOrigins = []string{"http://localhost:3000", "http://127.0.0.1:3000"}
 	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "HEAD", "PATCH"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Authorization", "Accept", "Cache-Control", "X-Requested-With"}
+	config.AllowHeaders = []string{"Origin", "Content-Type", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Authorization", "Accept", "Cache-Control", "X-Requested-With", "If-Match", "If-None-Match", "X-Analytics-Opt-Out", "X-Experiment-Subject", middleware.TenantHeader, middleware.APIKeyHeader, "traceparent", "tracestate", "Last-Event-ID"}
+	config.ExposeHeaders = []string{"ETag", middleware.TraceIDHeader, "X-Experiments", "X-Data-State"}
 	config.AllowCredentials = true
 	config.MaxAge = 12 * 3600 // 12 hours
 	config.AllowWildcard = true
 
-	r.Use(cors.New(config))
+	// Embed routes have their own, stricter policy for third-party sites
+	r.Use(middleware.ExceptEmbed(cors.New(config)))
+
+	// Trace each request, continuing the caller's trace from traceparent
+	r.Use(middleware.Tracing())
+
+	// Reject oversized request bodies
+	r.Use(middleware.MaxBodySize(middleware.DefaultMaxBodyBytes))
+
+	// Resolve the tenant, check its API key and enforce its quota
+	r.Use(middleware.Tenant())
 
 	// Additional CORS middleware for debugging
 	r.Use(func(c *gin.Context) {
@@ -38,9 +114,34 @@ func main() {
 	// Setup routes
 	router.SetupRoutes(r)
 
+	// Permanently remove trashed items past their retention period
+	services.StartTrashPurger(6 * time.Hour)
+
+	// Re-check watched events for price drops and sell-outs
+	services.StartEventWatchPoller(30 * time.Minute)
+
+	// Alert trip owners when the forecast for an upcoming trip changes
+	services.StartTripWeatherMonitor(3 * time.Hour)
+
+	// Send medication refill and travel insurance reminders ahead of departure
+	services.StartReminderScheduler(1 * time.Hour)
+
+	// Warm the agent's connection pool and keep probing its health
+	services.StartAgentWarmup(context.Background())
+
 	// Start server
+	srv := &http.Server{
+		Addr:              ":8080",
+		Handler:           r,
+		ReadHeaderTimeout: serverReadHeaderTimeout,
+		ReadTimeout:       serverReadTimeout,
+		WriteTimeout:      serverWriteTimeout,
+		IdleTimeout:       serverIdleTimeout,
+		MaxHeaderBytes:    serverMaxHeaderBytes,
+	}
+
 	log.Println("Starting CanTrip API server on port 8080...")
-	if err := r.Run(":8080"); err != nil {
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		log.Fatal("Failed to start server:", err)
 	}
 }
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminKeyHeader carries the admin API key
+const AdminKeyHeader = "X-Admin-Key"
+
+// RequireAdmin restricts a route group to callers presenting ADMIN_API_KEY.
+// When no key is configured, admin routes are disabled entirely.
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminKey := os.Getenv("ADMIN_API_KEY")
+		if adminKey == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin API is not enabled"})
+			return
+		}
+
+		provided := c.GetHeader(AdminKeyHeader)
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(adminKey)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid admin key"})
+			return
+		}
+
+		c.Next()
+	}
+}
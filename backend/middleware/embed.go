@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joshndala/cantrip/utils"
+)
+
+// EmbedPathPrefix holds the routes third-party sites read, outside the app's CORS policy
+const EmbedPathPrefix = "/api/v1/embed/"
+
+// EmbedAllowedOriginsEnv lists the sites allowed to embed, comma-separated, or "*" for any
+// site. No other site may when it is unset.
+const EmbedAllowedOriginsEnv = "EMBED_ALLOWED_ORIGINS"
+
+// ExceptEmbed runs the app's CORS handler everywhere but the embed routes, which answer
+// with EmbedCORS instead
+func ExceptEmbed(handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if strings.HasPrefix(c.Request.URL.Path, EmbedPathPrefix) {
+			c.Next()
+			return
+		}
+		handler(c)
+	}
+}
+
+// EmbedCORS lets the sites in EMBED_ALLOWED_ORIGINS read embed routes: GET only, without
+// credentials or request headers beyond the CORS-safelisted ones. Preflights are answered
+// here, and requests from any other origin are refused.
+func EmbedCORS() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin != "" {
+			origins := strings.Split(os.Getenv(EmbedAllowedOriginsEnv), ",")
+			for i := range origins {
+				origins[i] = strings.TrimSpace(origins[i])
+			}
+			allowed := "*"
+			if !utils.Contains(origins, "*") {
+				if !utils.Contains(origins, origin) {
+					c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Origin is not allowed to embed trips"})
+					return
+				}
+				allowed = origin
+				c.Header("Vary", "Origin")
+			}
+			c.Header("Access-Control-Allow-Origin", allowed)
+			c.Header("Access-Control-Allow-Methods", "GET, OPTIONS")
+			c.Header("Access-Control-Expose-Headers", "ETag")
+			c.Header("Access-Control-Max-Age", "86400")
+		}
+
+		if c.Request.Method == http.MethodOptions {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.AbortWithStatusJSON(http.StatusMethodNotAllowed, gin.H{"error": "Embed routes are read-only"})
+			return
+		}
+		c.Next()
+	}
+}
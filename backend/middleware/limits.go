@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Default request limits
+const (
+	DefaultMaxBodyBytes   = 1 << 20 // 1 MB
+	DefaultHandlerTimeout = 30 * time.Second
+	LookupHandlerTimeout  = 15 * time.Second // endpoints that only call weather or event APIs
+)
+
+// routeBodyLimits holds larger body limits for upload routes, keyed by route pattern
+var routeBodyLimits sync.Map // full path -> int64
+
+// AllowBodySize raises the body limit for one route pattern, such as
+// "/api/v1/trips/:id/attachments", above the MaxBodySize default
+func AllowBodySize(fullPath string, maxBytes int64) {
+	routeBodyLimits.Store(fullPath, maxBytes)
+}
+
+// MaxBodySize rejects requests whose body exceeds maxBytes, or the route's AllowBodySize limit
+func MaxBodySize(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := maxBytes
+		if override, ok := routeBodyLimits.Load(c.FullPath()); ok {
+			limit = override.(int64)
+		}
+
+		// Reject early when the client declares an oversized body
+		if c.Request.ContentLength > limit {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body too large"})
+			return
+		}
+
+		// Enforce the limit for chunked or undeclared bodies while reading
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
+
+// Timeout bounds the time a handler may spend on a request.
+// The deadline is attached to the request context as the request's budget: upstream
+// calls made with c.Request.Context(), as every agent, storage and API call is, get what
+// remains of it, capped by their own timeouts, and are cancelled when it runs out, so
+// the handler returns soon after. Work that doesn't take the context, such as local
+// computation, isn't interrupted. If the handler overran without writing a response, a
+// 504 is returned once it does.
+func Timeout(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && !c.Writer.Written() {
+			c.AbortWithStatusJSON(http.StatusGatewayTimeout, gin.H{"error": "Request timed out"})
+		}
+	}
+}
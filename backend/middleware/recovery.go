@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"syscall"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joshndala/cantrip/services"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxPanicFrames bounds the stack frames sent with a report
+const maxPanicFrames = 64
+
+// Recovery turns a panic in a handler into a 500 JSON response and reports it, with its
+// stack, through the configured error reporter. The response carries the report ID so a
+// user can quote it. Panics from a client hanging up are dropped without a report.
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if isBrokenConnection(recovered) {
+				c.Abort()
+				return
+			}
+
+			errorID := services.ReportPanic(panicReport(c, recovered))
+			if c.Writer.Written() {
+				// A streamed response can't be replaced; the client sees it cut short
+				c.Abort()
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error":    "Internal server error",
+				"error_id": errorID,
+			})
+		}()
+		c.Next()
+	}
+}
+
+// panicReport describes a panic and the request it interrupted
+func panicReport(c *gin.Context, recovered interface{}) services.ErrorReport {
+	message := fmt.Sprint(recovered)
+	if err, ok := recovered.(error); ok {
+		message = err.Error()
+	}
+
+	report := services.ErrorReport{
+		Message:   message,
+		Type:      fmt.Sprintf("%T", recovered),
+		Frames:    panicFrames(),
+		Stack:     string(debug.Stack()),
+		Method:    c.Request.Method,
+		URL:       requestURL(c.Request),
+		Route:     c.FullPath(),
+		UserAgent: c.Request.UserAgent(),
+		UserID:    c.GetHeader("X-User-ID"),
+	}
+	if spanContext := trace.SpanContextFromContext(c.Request.Context()); spanContext.IsValid() {
+		report.TraceID = spanContext.TraceID().String()
+	}
+	return report
+}
+
+// panicFrames lists the calls that led to the panic, innermost first. A panic re-raised by
+// other middleware passes through runtime.gopanic again, so the frames start after the last
+// of those, at the code that first panicked.
+func panicFrames() []services.StackFrame {
+	pcs := make([]uintptr, maxPanicFrames)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var stack []services.StackFrame
+	for {
+		frame, more := frames.Next()
+		if frame.Function == "runtime.gopanic" {
+			stack = nil
+		} else if !strings.HasPrefix(frame.Function, "runtime.") {
+			stack = append(stack, services.StackFrame{Function: frame.Function, File: frame.File, Line: frame.Line})
+		}
+		if !more {
+			break
+		}
+	}
+	return stack
+}
+
+// requestURL rebuilds the request URL without its query, which may carry tokens
+func requestURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.Path
+}
+
+// isBrokenConnection reports whether a panic came from writing to a client that went away
+func isBrokenConnection(recovered interface{}) bool {
+	err, ok := recovered.(error)
+	if !ok {
+		return false
+	}
+	return errors.Is(err, http.ErrAbortHandler) || errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET)
+}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joshndala/cantrip/services"
+)
+
+// TenantHeader picks a tenant by ID, for clients that share a hostname
+const TenantHeader = "X-Tenant-ID"
+
+// APIKeyHeader carries a tenant API key
+const APIKeyHeader = "X-API-Key"
+
+// keylessPaths are reached by browsers following links or redirects, or loading media
+// into img and audio tags, none of which can add an API key: health and version probes,
+// signed object URLs, share pages and embeds, the Google consent redirect, agenda feeds
+// polled by calendar apps, outbound booking links, image previews and chat audio. Their
+// tokens, signatures or random IDs are their credentials.
+var keylessPaths = []string{
+	"/api/v1/health",
+	"/api/v1/version",
+	"/api/v1/objects/",
+	EmbedPathPrefix,
+	"/api/v1/calendar/google/callback",
+	"/api/v1/admin/", // guarded by the admin key instead
+	"/api/v1/out/",   // conversion postbacks are guarded by the affiliate secret
+	"/api/v1/images/",
+	"/api/v1/chat/audio/",
+	"/agenda/",
+	"/share/",
+}
+
+// Tenant resolves the tenant a request belongs to from TenantHeader or the hostname, checks
+// its API key and counts the request against its quota. Handlers reach the tenant through
+// the request context, which scopes storage and datasets to it.
+func Tenant() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenant := services.TenantByHost(c.Request.Host)
+		if id := c.GetHeader(TenantHeader); id != "" {
+			var err error
+			tenant, err = services.TenantByID(id)
+			if errors.Is(err, services.ErrUnknownTenant) {
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Unknown tenant"})
+				return
+			}
+		}
+
+		if tenant.RequiresAPIKey() && c.Request.Method != http.MethodOptions && !keyless(c.Request.URL.Path) {
+			if !tenant.CheckAPIKey(c.GetHeader(APIKeyHeader)) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+				return
+			}
+		}
+
+		if !strings.HasPrefix(c.Request.URL.Path, "/api/v1/health") {
+			if ok, wait := services.AllowTenantRequest(tenant, time.Now()); !ok {
+				c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Tenant request quota exceeded"})
+				return
+			}
+		}
+
+		c.Request = c.Request.WithContext(services.WithTenant(c.Request.Context(), tenant))
+		c.Next()
+	}
+}
+
+// keyless reports whether a path is served without a tenant API key
+func keyless(path string) bool {
+	for _, prefix := range keylessPaths {
+		if path == prefix || strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
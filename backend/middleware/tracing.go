@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TraceIDHeader returns the request's trace ID so a slow response can be looked up
+const TraceIDHeader = "X-Trace-ID"
+
+// tracerName is the instrumentation scope of server spans
+const tracerName = "github.com/joshndala/cantrip/middleware"
+
+// Tracing starts a server span per request, continuing the caller's trace when the request
+// carries a traceparent header. Spans are named by route pattern, such as
+// "POST /api/v1/itinerary/", so requests for different IDs group together.
+func Tracing() gin.HandlerFunc {
+	tracer := otel.Tracer(tracerName)
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+		route := c.FullPath()
+		name := c.Request.Method + " " + route
+		if route == "" {
+			name = c.Request.Method // unmatched paths would make a span name per URL
+		}
+		ctx, span := tracer.Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", c.Request.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", c.Request.URL.Path),
+				attribute.String("client.address", c.ClientIP()),
+				attribute.String("user_agent.original", c.Request.UserAgent()),
+			),
+		)
+		defer func() {
+			// Mark the span before Recovery, further out, turns the panic into a 500
+			if recovered := recover(); recovered != nil {
+				span.SetStatus(codes.Error, "panic")
+				span.SetAttributes(attribute.Int("http.response.status_code", http.StatusInternalServerError))
+				span.End()
+				panic(recovered)
+			}
+			span.End()
+		}()
+
+		if span.SpanContext().IsSampled() {
+			c.Header(TraceIDHeader, span.SpanContext().TraceID().String())
+		}
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		if len(c.Errors) > 0 {
+			span.RecordError(c.Errors.Last())
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package models
+
+// ChatRequest is the body of the chat and chat stream calls
+type ChatRequest struct {
+	Message   string `json:"message" binding:"required"`
+	SessionID string `json:"session_id"`
+	UserID    string `json:"user_id,omitempty"`
+	TTS       bool   `json:"tts,omitempty"`      // also speak the reply, as does ?tts=true
+	Language  string `json:"language,omitempty"` // voice language for tts, such as "fr-CA"
+}
+
+// ChatResponse represents the AI agent's response
+type ChatResponse struct {
+	Response    string                 `json:"response"`
+	SessionID   string                 `json:"session_id"`
+	Intent      string                 `json:"intent"`
+	Confidence  float64                `json:"confidence"`
+	Suggestions []string               `json:"suggestions,omitempty"`
+	Data        map[string]interface{} `json:"data,omitempty"`
+	Intents     []string               `json:"intents,omitempty"`   // every intent the reply covers
+	Cards       []ChatCard             `json:"cards"`               // typed views of Data
+	Audio       *ChatAudio             `json:"audio,omitempty"`     // the reply spoken, when requested
+	Turn        int                    `json:"turn,omitempty"`      // identifies the reply for feedback
+	Moderated   string                 `json:"moderated,omitempty"` // input or output when a refusal replaced the reply
+	Timestamp   string                 `json:"timestamp"`
+}
+
+// ChatCard is a typed, renderable piece of an agent reply. Exactly one of Weather, Event
+// or Itinerary is set, matching Type.
+type ChatCard struct {
+	Type      string                `json:"type"`
+	Version   int                   `json:"version"`
+	Weather   *WeatherCard          `json:"weather,omitempty"`
+	Event     *EventCard            `json:"event,omitempty"`
+	Itinerary *ItineraryPreviewCard `json:"itinerary,omitempty"`
+	Actions   []CardAction          `json:"actions,omitempty"`
+}
+
+// CardAction is a button on a card
+type CardAction struct {
+	Label   string `json:"label"`
+	Kind    string `json:"kind"`
+	URL     string `json:"url,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// WeatherCard shows current conditions and, when the agent sent one, a forecast
+type WeatherCard struct {
+	City        string            `json:"city"`
+	Temperature float64           `json:"temperature"`
+	Condition   string            `json:"condition"`
+	Humidity    int               `json:"humidity"`
+	WindSpeed   float64           `json:"wind_speed"`
+	Forecast    []WeatherForecast `json:"forecast,omitempty"`
+}
+
+// EventCard shows one event
+type EventCard struct {
+	ID         string  `json:"id,omitempty"`
+	Name       string  `json:"name"`
+	Date       string  `json:"date"`
+	Time       string  `json:"time,omitempty"`
+	Location   string  `json:"location"`
+	Category   string  `json:"category,omitempty"`
+	Price      float64 `json:"price"`
+	BookingURL string  `json:"booking_url,omitempty"`
+}
+
+// ItineraryPreviewCard summarizes a generated itinerary
+type ItineraryPreviewCard struct {
+	ID         string   `json:"id,omitempty"`
+	City       string   `json:"city"`
+	StartDate  string   `json:"start_date,omitempty"`
+	EndDate    string   `json:"end_date,omitempty"`
+	Days       int      `json:"days"`
+	TotalCost  float64  `json:"total_cost,omitempty"`
+	Highlights []string `json:"highlights,omitempty"`
+}
+
+// ChatAudio points a chat reply at its spoken version
+type ChatAudio struct {
+	URL         string `json:"url"`
+	ContentType string `json:"content_type"`
+	Provider    string `json:"provider"`
+}
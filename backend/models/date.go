@@ -0,0 +1,101 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Date is a calendar day. It reads YYYY-MM-DD or an RFC3339 timestamp, taken as the day
+// the timestamp falls on in its own offset, and always writes YYYY-MM-DD, so clients
+// can send whichever they have.
+type Date struct {
+	time.Time
+}
+
+// NewDate is the calendar day t falls on, at midnight UTC
+func NewDate(t time.Time) Date {
+	if t.IsZero() {
+		return Date{}
+	}
+	return Date{time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)}
+}
+
+// ParseDate reads a day written as YYYY-MM-DD or as an RFC3339 timestamp
+func ParseDate(value string) (Date, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse(DateLayout, value); err == nil {
+		return Date{t}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return NewDate(t), nil
+	}
+	return Date{}, fmt.Errorf("invalid date %q: use YYYY-MM-DD or an RFC3339 timestamp", value)
+}
+
+// NormalizeDate rewrites a day in either accepted form as YYYY-MM-DD; an empty value
+// stays empty
+func NormalizeDate(value string) (string, error) {
+	if strings.TrimSpace(value) == "" {
+		return "", nil
+	}
+	date, err := ParseDate(value)
+	if err != nil {
+		return "", err
+	}
+	return date.String(), nil
+}
+
+// String is the day as YYYY-MM-DD, or empty when unset
+func (d Date) String() string {
+	if d.IsZero() {
+		return ""
+	}
+	return d.Format(DateLayout)
+}
+
+// MarshalJSON writes the day as YYYY-MM-DD, or null when unset
+func (d Date) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON reads a day in either accepted form; null and "" leave it unset
+func (d *Date) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*d = Date{}
+		return nil
+	}
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("invalid date %s: use YYYY-MM-DD or an RFC3339 timestamp", data)
+	}
+	return d.UnmarshalParam(value)
+}
+
+// UnmarshalParam reads a day from a form field or query parameter
+func (d *Date) UnmarshalParam(param string) error {
+	if strings.TrimSpace(param) == "" {
+		*d = Date{}
+		return nil
+	}
+	date, err := ParseDate(param)
+	if err != nil {
+		return err
+	}
+	*d = date
+	return nil
+}
+
+// DaysBetween counts the calendar days from start to end, each read on its own clock: 0
+// on the same day and negative when end comes first. Unlike dividing the elapsed hours,
+// the count doesn't slip across a daylight saving change.
+func DaysBetween(start, end time.Time) int {
+	from := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	to := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, time.UTC)
+	return int(to.Sub(from) / (24 * time.Hour))
+}
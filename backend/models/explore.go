@@ -0,0 +1,16 @@
+package models
+
+// ExploreRequest is the body of the explore call. City may be left out when Origin is
+// given, to explore the nearest city in reach.
+type ExploreRequest struct {
+	Mood          string   `json:"mood" binding:"required"`
+	City          string   `json:"city"`
+	Budget        float64  `json:"budget"`
+	Duration      int      `json:"duration"` // in days
+	Interests     []string `json:"interests"`
+	Season        string   `json:"season"`
+	Eco           bool     `json:"eco"`             // favour parks, walking tours and local markets
+	Origin        string   `json:"origin"`          // supported city or "lat,lng" the trip starts from
+	MaxDriveHours float64  `json:"max_drive_hours"` // with origin, how long a drive is acceptable
+	MaxDistanceKm float64  `json:"max_distance_km"` // with origin, how far by road is acceptable
+}
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"time"
+)
+
+// ItineraryRequest is the body of the create and update itinerary calls
+type ItineraryRequest struct {
+	City          string   `json:"city" binding:"required"`
+	StartDate     Date     `json:"start_date" binding:"required"`
+	EndDate       Date     `json:"end_date" binding:"required"`
+	Interests     []string `json:"interests"`
+	Budget        float64  `json:"budget"`
+	GroupSize     int      `json:"group_size"`
+	Pace          string   `json:"pace"`              // "relaxed", "moderate", "intense"
+	Accommodation string   `json:"accommodation"`     // "budget", "mid-range", "luxury"
+	Transport     string   `json:"transport"`         // "walking", "public", "taxi", "rental"
+	Eco           bool     `json:"eco"`               // prefer transit and walking
+	Version       *int     `json:"version,omitempty"` // revision an edit is based on
+}
+
+// Days counts the days of the trip, both ends included
+func (r ItineraryRequest) Days() int {
+	return DaysBetween(r.StartDate.Time, r.EndDate.Time) + 1
+}
+
+// ItineraryResponse represents the response from itinerary generation
+type ItineraryResponse struct {
+	ID        string                 `json:"id,omitempty"`
+	OwnerID   string                 `json:"owner_id,omitempty"` // user notified about changes to the trip
+	Revision  int                    `json:"revision"`
+	Anchors   []Booking              `json:"anchors,omitempty"` // imported flights and hotels
+	Success   bool                   `json:"success"`
+	Itinerary map[string]interface{} `json:"itinerary"`
+	Driving   *DrivingAdvisory       `json:"driving,omitempty"`   // for trips by rental car
+	Rental    *RentalSelection       `json:"rental,omitempty"`    // the rental car chosen for the trip
+	Festivals []FestivalOccurrence   `json:"festivals,omitempty"` // recurring festivals during the trip
+	Metadata  struct {
+		City        string  `json:"city"`
+		Duration    int     `json:"duration"`
+		TotalCost   float64 `json:"total_cost"`
+		GeneratedAt string  `json:"generated_at"`
+	} `json:"metadata"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// Booking is a flight or hotel reservation the itinerary must plan around
+type Booking struct {
+	ID           string    `json:"id"`
+	Type         string    `json:"type"` // flight, hotel
+	Title        string    `json:"title"`
+	Confirmation string    `json:"confirmation,omitempty"`
+	Provider     string    `json:"provider,omitempty"`      // airline or hotel chain
+	FlightNumber string    `json:"flight_number,omitempty"` // e.g. AC 123
+	Origin       string    `json:"origin,omitempty"`
+	Destination  string    `json:"destination,omitempty"`
+	Address      string    `json:"address,omitempty"`
+	Start        time.Time `json:"start"` // departure or check-in
+	End          time.Time `json:"end"`   // arrival or check-out
+	Source       string    `json:"source"`
+}
+
+// DrivingAdvisory is the driving information for a trip
+type DrivingAdvisory struct {
+	Destination  string      `json:"destination"`
+	Province     string      `json:"province,omitempty"`
+	DriveOn      string      `json:"drive_on"`
+	Units        string      `json:"units"`
+	SpeedLimits  SpeedLimits `json:"speed_limits"`
+	WinterTires  *TireRule   `json:"winter_tires,omitempty"`
+	StuddedTires *TireRule   `json:"studded_tires,omitempty"`
+	Rules        []string    `json:"rules"`
+	Wildlife     []string    `json:"wildlife"`
+	Warnings     []string    `json:"warnings"` // tire laws and winter advice in effect during the trip
+}
+
+// SpeedLimits are the default limits in km/h unless signed otherwise
+type SpeedLimits struct {
+	Urban   int `json:"urban"`
+	Rural   int `json:"rural"`
+	Highway int `json:"highway"`
+}
+
+// TireRule is a seasonal tire requirement or allowance. Without dates it applies year-round.
+type TireRule struct {
+	Required bool   `json:"required,omitempty"`
+	Allowed  bool   `json:"allowed,omitempty"`
+	From     string `json:"from,omitempty"` // MM-DD
+	To       string `json:"to,omitempty"`   // MM-DD, may be in the next year
+	Note     string `json:"note,omitempty"`
+}
+
+// RentalSelection is the rental car chosen for a trip, with its estimated fuel cost
+type RentalSelection struct {
+	Offer      RentalOffer `json:"offer"`
+	RoofBox    bool        `json:"roof_box"`
+	DailyKm    int         `json:"daily_km"`
+	FuelPrice  float64     `json:"fuel_price"` // per litre
+	FuelLiters float64     `json:"fuel_liters"`
+	FuelCost   float64     `json:"fuel_cost"`
+	RentalCost float64     `json:"rental_cost"` // including the roof box
+	Total      float64     `json:"total"`
+	Currency   string      `json:"currency"`
+	SelectedAt time.Time   `json:"selected_at"`
+}
+
+// RentalOffer is a vehicle class offered by one company for the trip
+type RentalOffer struct {
+	ID        string       `json:"id"`
+	Provider  string       `json:"provider"`
+	Company   string       `json:"company"`
+	Vehicle   VehicleClass `json:"vehicle"`
+	Days      int          `json:"days"`
+	DailyRate float64      `json:"daily_rate"`
+	Total     float64      `json:"total"`
+	Currency  string       `json:"currency"`
+}
+
+// VehicleClass is a rental car category
+type VehicleClass struct {
+	ID            string  `json:"id"`
+	Name          string  `json:"name"`
+	Examples      string  `json:"examples"`
+	Seats         int     `json:"seats"`
+	Suitcases     int     `json:"suitcases"`
+	FuelLPer100Km float64 `json:"fuel_l_per_100km"`
+	DailyRate     float64 `json:"daily_rate"`
+	AWD           bool    `json:"awd,omitempty"`
+	RoofBox       bool    `json:"roof_box"` // a roof box can be added
+}
+
+// FestivalOccurrence is a festival placed on the calendar for a given year
+type FestivalOccurrence struct {
+	Festival
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+	Estimated bool   `json:"estimated"` // dates come from past years, not a published schedule
+}
+
+// Festival is a recurring festival or annual event with its typical dates
+type Festival struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	City         string   `json:"city"`
+	Description  string   `json:"description"`
+	Category     string   `json:"category"`
+	Tags         []string `json:"tags"`
+	TypicalStart string   `json:"typical_start"` // MM-DD
+	TypicalEnd   string   `json:"typical_end"`   // MM-DD, may be in the next year
+	DateNote     string   `json:"date_note"`     // how the dates are set, e.g. "the first Friday in July"
+	PriceRange   string   `json:"price_range,omitempty"`
+	Website      string   `json:"website,omitempty"`
+}
@@ -0,0 +1,14 @@
+// Package models defines the request and response bodies shared by the HTTP handlers
+// and the services behind them.
+//
+// Each body is declared once, here, with its binding rules. Handlers bind straight
+// into these types and services accept them as they are, so a field added to a
+// request reaches the code that uses it without a hand-written copy in between.
+// Responses, and the types they are built from, live here too so their JSON is the
+// API's contract; services refers to them through aliases.
+// The package imports nothing else from the module, which keeps it usable from
+// both layers.
+package models
+
+// DateLayout is how calendar dates are written
+const DateLayout = "2006-01-02"
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// PackingRequest is the body of the create and update packing list calls, and what
+// the packing list is generated from
+type PackingRequest struct {
+	Destination   string          `json:"destination" binding:"required"`
+	StartDate     Date            `json:"start_date" binding:"required"`
+	EndDate       Date            `json:"end_date" binding:"required"`
+	Activities    []string        `json:"activities"`
+	Weather       string          `json:"weather"`
+	GroupSize     int             `json:"group_size"`
+	AgeGroup      string          `json:"age_group"` // "adult", "child", "senior"
+	SpecialNeeds  []string        `json:"special_needs"`
+	BaggageType   string          `json:"baggage_type"`             // "carry-on", "checked", "both"
+	Travelers     []Traveler      `json:"travelers,omitempty"`      // per-person lists plus shared items
+	Medications   []Medication    `json:"medications,omitempty"`    // packed, with refill reminders
+	Vehicle       *PackingVehicle `json:"vehicle,omitempty"`        // rental car, for car items and luggage space
+	DailyGuidance bool            `json:"daily_guidance,omitempty"` // what to wear each day, from the forecast
+	Version       *int            `json:"version,omitempty"`        // version an edit is based on
+}
+
+// Traveler is a named member of a group. Empty fields fall back to the request's values.
+type Traveler struct {
+	Name         string   `json:"name"`
+	AgeGroup     string   `json:"age_group,omitempty"`
+	Activities   []string `json:"activities,omitempty"`
+	SpecialNeeds []string `json:"special_needs,omitempty"`
+}
+
+// Medication is a medication a traveler declares for a trip
+type Medication struct {
+	Name        string  `json:"name"`
+	DosesPerDay float64 `json:"doses_per_day,omitempty"`
+	SupplyDays  int     `json:"supply_days,omitempty"` // days of supply on hand; no reminder when unset
+	Traveler    string  `json:"traveler,omitempty"`    // on group lists, who takes it
+}
+
+// PackingVehicle is the rental car a packing list is for
+type PackingVehicle struct {
+	Class   string `json:"class"` // vehicle class ID, e.g. "midsize-suv"
+	RoofBox bool   `json:"roof_box,omitempty"`
+}
+
+// PackingResponse is a generated packing list, as saved and returned by the packing calls
+type PackingResponse struct {
+	ID              string                `json:"id"`
+	Destination     string                `json:"destination"`
+	StartDate       string                `json:"start_date,omitempty"` // the trip's dates, checked when the list is edited
+	EndDate         string                `json:"end_date,omitempty"`
+	Categories      []interface{}         `json:"categories"` // with travelers, the items shared by the group
+	Travelers       []TravelerPackingList `json:"travelers,omitempty"`
+	ActivityMatches []ActivityMatch       `json:"activity_matches,omitempty"` // how free-text activities were read
+	BaggageSplit    *BaggageSplit         `json:"baggage_split,omitempty"`    // for carry-on and "both" trips
+	RefillReminders []RefillReminder      `json:"refill_reminders,omitempty"` // medications to refill before leaving
+	TotalItems      int                   `json:"total_items"`
+	Notes           []string              `json:"notes"`
+	Weather         WeatherInfo           `json:"weather"`
+	WeatherRange    *PackingWeatherRange  `json:"weather_range,omitempty"` // what the clothing was chosen for
+	DailyGuidance   []DailyOutfit         `json:"daily_guidance,omitempty"`
+	Version         int                   `json:"version"`
+	DeletedAt       *time.Time            `json:"deleted_at,omitempty"`
+}
+
+// TravelerPackingList is one traveler's personal items
+type TravelerPackingList struct {
+	Name         string            `json:"name"`
+	AgeGroup     string            `json:"age_group"`
+	Categories   []PackingCategory `json:"categories"`
+	TotalItems   int               `json:"total_items"`
+	BaggageSplit *BaggageSplit     `json:"baggage_split,omitempty"`
+}
+
+// PackingCategory represents a category of items in the packing list
+type PackingCategory struct {
+	Name  string        `json:"name"`
+	Items []PackingItem `json:"items"`
+}
+
+// PackingItem represents a single item in the packing list
+type PackingItem struct {
+	Name       string `json:"name"`
+	Quantity   int    `json:"quantity"`
+	Reason     string `json:"reason"`
+	Medication bool   `json:"medication,omitempty"` // a declared medication, kept in the carry-on
+}
+
+// PackingWeatherRange is the span of feels-like temperatures, in °C, a list is packed for
+type PackingWeatherRange struct {
+	Low        float64  `json:"low"`
+	High       float64  `json:"high"`
+	Categories []string `json:"categories"` // weather categories packed for, coldest first
+}
+
+// ActivityMatch maps a free-text activity description to packing rule categories
+type ActivityMatch struct {
+	Input      string   `json:"input"`
+	Activities []string `json:"activities"` // activity_rules keys, empty when unrecognized
+	Source     string   `json:"source,omitempty"`
+	Matched    []string `json:"matched,omitempty"` // synonyms that matched
+	Confidence float64  `json:"confidence"`
+}
+
+// BaggageSplit assigns a packing list's items to the carry-on and checked bags
+type BaggageSplit struct {
+	BaggageType string       `json:"baggage_type"`
+	CarryOn     []SplitItem  `json:"carry_on"`
+	Checked     []SplitItem  `json:"checked"`
+	Liquids     *LiquidCheck `json:"liquids,omitempty"`
+	Warnings    []string     `json:"warnings,omitempty"`
+}
+
+// SplitItem is an item, or part of its quantity, placed in one bag
+type SplitItem struct {
+	Name     string `json:"name"`
+	Quantity int    `json:"quantity"`
+	Category string `json:"category"`
+	Reason   string `json:"reason"`
+}
+
+// LiquidCheck lists the liquids in the carry-on and the limits they must meet
+type LiquidCheck struct {
+	Items          []string `json:"items"`
+	MaxContainerML int      `json:"max_container_ml"`
+	BagLiters      float64  `json:"bag_liters"`
+	Note           string   `json:"note"`
+}
+
+// RefillReminder is a reminder to refill a medication before departure
+type RefillReminder struct {
+	Medication string `json:"medication"`
+	Traveler   string `json:"traveler,omitempty"`
+	RemindOn   string `json:"remind_on"` // YYYY-MM-DD
+	DaysShort  int    `json:"days_short"`
+	Message    string `json:"message"`
+}
+
+// DailyOutfit is what to wear on one day of a trip, from that day's forecast
+type DailyOutfit struct {
+	Day     int      `json:"day"`
+	Date    string   `json:"date"`
+	Summary string   `json:"summary"` // e.g. "Rain likely (60%), feels like 4°C to 12°C"
+	Wear    []string `json:"wear"`
+}
+
+// Label is the day's heading in printed guidance, e.g. "Day 3 (2026-11-03)"
+func (o DailyOutfit) Label() string {
+	return fmt.Sprintf("Day %d (%s)", o.Day, o.Date)
+}
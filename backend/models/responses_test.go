@@ -0,0 +1,256 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// assertJSON checks v marshals to want, compared ignoring whitespace
+func assertJSON(t *testing.T, v interface{}, want string) {
+	t.Helper()
+	got, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, []byte(want)); err != nil {
+		t.Fatalf("want isn't JSON: %v", err)
+	}
+	if !bytes.Equal(got, compact.Bytes()) {
+		t.Errorf("JSON =\n%s\nwant\n%s", got, compact.Bytes())
+	}
+}
+
+// assertRoundTrip checks v decodes back from its JSON unchanged
+func assertRoundTrip(t *testing.T, v interface{}) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	decoded := reflect.New(reflect.TypeOf(v))
+	if err := json.Unmarshal(data, decoded.Interface()); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if !reflect.DeepEqual(decoded.Elem().Interface(), v) {
+		t.Errorf("round trip =\n%+v\nwant\n%+v", decoded.Elem().Interface(), v)
+	}
+}
+
+func TestPackingResponseJSON(t *testing.T) {
+	deleted := time.Date(2026, time.October, 2, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		value PackingResponse
+		want  string
+	}{
+		{
+			name:  "only required fields",
+			value: PackingResponse{ID: "p1", Destination: "Toronto", Categories: []interface{}{}, Notes: []string{}},
+			want: `{"id":"p1","destination":"Toronto","categories":[],"total_items":0,"notes":[],
+				"weather":{"temperature":0,"feels_like":0,"condition":"","humidity":0,"wind_speed":0},"version":0}`,
+		},
+		{
+			name: "every field",
+			value: PackingResponse{
+				ID:          "p1",
+				Destination: "Banff",
+				StartDate:   "2026-12-01",
+				EndDate:     "2026-12-04",
+				Categories: []interface{}{PackingCategory{Name: "Essentials", Items: []PackingItem{
+					{Name: "Passport", Quantity: 1, Reason: "ID"},
+					{Name: "Insulin", Quantity: 2, Reason: "Declared medication", Medication: true},
+				}}},
+				Travelers:       []TravelerPackingList{{Name: "Sam", AgeGroup: "child", Categories: []PackingCategory{}, TotalItems: 3}},
+				ActivityMatches: []ActivityMatch{{Input: "ski", Activities: []string{"outdoor_adventure"}, Source: "synonym", Matched: []string{"ski"}, Confidence: 0.9}},
+				BaggageSplit: &BaggageSplit{
+					BaggageType: "both",
+					CarryOn:     []SplitItem{{Name: "Insulin", Quantity: 2, Category: "Essentials", Reason: "medication"}},
+					Checked:     []SplitItem{},
+					Liquids:     &LiquidCheck{Items: []string{"Sunscreen"}, MaxContainerML: 100, BagLiters: 1, Note: "one bag"},
+					Warnings:    []string{"Pack the knife in checked bags"},
+				},
+				RefillReminders: []RefillReminder{{Medication: "Insulin", Traveler: "Sam", RemindOn: "2026-10-20", DaysShort: 2, Message: "Refill"}},
+				TotalItems:      5,
+				Notes:           []string{"Pack layers"},
+				Weather:         WeatherInfo{Temperature: -3, FeelsLike: -9, Condition: "Snow", Humidity: 80, WindSpeed: 4, Estimated: true},
+				WeatherRange:    &PackingWeatherRange{Low: -9, High: 2, Categories: []string{"cold", "cool"}},
+				DailyGuidance:   []DailyOutfit{{Day: 1, Date: "2026-12-01", Summary: "Snow likely", Wear: []string{"Parka"}}},
+				Version:         4,
+				DeletedAt:       &deleted,
+			},
+			want: `{"id":"p1","destination":"Banff","start_date":"2026-12-01","end_date":"2026-12-04",
+				"categories":[{"name":"Essentials","items":[
+					{"name":"Passport","quantity":1,"reason":"ID"},
+					{"name":"Insulin","quantity":2,"reason":"Declared medication","medication":true}]}],
+				"travelers":[{"name":"Sam","age_group":"child","categories":[],"total_items":3}],
+				"activity_matches":[{"input":"ski","activities":["outdoor_adventure"],"source":"synonym","matched":["ski"],"confidence":0.9}],
+				"baggage_split":{"baggage_type":"both",
+					"carry_on":[{"name":"Insulin","quantity":2,"category":"Essentials","reason":"medication"}],
+					"checked":[],
+					"liquids":{"items":["Sunscreen"],"max_container_ml":100,"bag_liters":1,"note":"one bag"},
+					"warnings":["Pack the knife in checked bags"]},
+				"refill_reminders":[{"medication":"Insulin","traveler":"Sam","remind_on":"2026-10-20","days_short":2,"message":"Refill"}],
+				"total_items":5,"notes":["Pack layers"],
+				"weather":{"temperature":-3,"feels_like":-9,"condition":"Snow","humidity":80,"wind_speed":4,"estimated":true},
+				"weather_range":{"low":-9,"high":2,"categories":["cold","cool"]},
+				"daily_guidance":[{"day":1,"date":"2026-12-01","summary":"Snow likely","wear":["Parka"]}],
+				"version":4,"deleted_at":"2026-10-02T09:30:00Z"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertJSON(t, tt.value, tt.want)
+		})
+	}
+
+	// Categories are untyped, so only the typed parts survive a round trip as they were
+	typed := tests[1].value
+	typed.Categories = nil
+	assertRoundTrip(t, typed)
+}
+
+func TestItineraryResponseJSON(t *testing.T) {
+	start := time.Date(2026, time.July, 3, 14, 0, 0, 0, time.UTC)
+	selected := time.Date(2026, time.June, 20, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		value ItineraryResponse
+		want  string
+	}{
+		{
+			name:  "only required fields",
+			value: ItineraryResponse{Revision: 1, Success: true, Itinerary: map[string]interface{}{}},
+			want:  `{"revision":1,"success":true,"itinerary":{},"metadata":{"city":"","duration":0,"total_cost":0,"generated_at":""}}`,
+		},
+		{
+			name: "every field",
+			value: func() ItineraryResponse {
+				response := ItineraryResponse{
+					ID:       "it1",
+					OwnerID:  "u1",
+					Revision: 3,
+					Anchors: []Booking{{
+						ID: "b1", Type: "flight", Title: "AC 123", Confirmation: "ABC123", Provider: "Air Canada",
+						FlightNumber: "AC 123", Origin: "YYZ", Destination: "YVR", Start: start, End: start.Add(5 * time.Hour), Source: "ics",
+					}},
+					Success:   true,
+					Itinerary: map[string]interface{}{"days": []interface{}{}},
+					Driving: &DrivingAdvisory{
+						Destination: "Whistler", Province: "British Columbia", DriveOn: "right", Units: "km/h",
+						SpeedLimits: SpeedLimits{Urban: 50, Rural: 80, Highway: 100},
+						WinterTires: &TireRule{Required: true, From: "10-01", To: "04-30", Note: "Sea to Sky"},
+						Rules:       []string{"Right on red"}, Wildlife: []string{"Bears"}, Warnings: []string{},
+					},
+					Rental: &RentalSelection{
+						Offer: RentalOffer{
+							ID: "r1", Provider: "mock", Company: "Budget", Days: 4, DailyRate: 60, Total: 240, Currency: "CAD",
+							Vehicle: VehicleClass{ID: "midsize-suv", Name: "Midsize SUV", Examples: "RAV4", Seats: 5, Suitcases: 3, FuelLPer100Km: 8.5, DailyRate: 60, AWD: true, RoofBox: true},
+						},
+						RoofBox: true, DailyKm: 120, FuelPrice: 1.6, FuelLiters: 40.8, FuelCost: 65.28, RentalCost: 280, Total: 345.28, Currency: "CAD", SelectedAt: selected,
+					},
+					Festivals: []FestivalOccurrence{{
+						Festival: Festival{
+							ID: "f1", Name: "Calgary Stampede", City: "Calgary", Description: "Rodeo", Category: "culture",
+							Tags: []string{"rodeo"}, TypicalStart: "07-03", TypicalEnd: "07-12", DateNote: "early July", Website: "https://www.calgarystampede.com",
+						},
+						StartDate: "2026-07-03", EndDate: "2026-07-12", Estimated: true,
+					}},
+				}
+				response.Metadata.City = "Vancouver"
+				response.Metadata.Duration = 4
+				response.Metadata.TotalCost = 1200.5
+				response.Metadata.GeneratedAt = "2026-06-20T08:00:00Z"
+				return response
+			}(),
+			want: `{"id":"it1","owner_id":"u1","revision":3,
+				"anchors":[{"id":"b1","type":"flight","title":"AC 123","confirmation":"ABC123","provider":"Air Canada",
+					"flight_number":"AC 123","origin":"YYZ","destination":"YVR",
+					"start":"2026-07-03T14:00:00Z","end":"2026-07-03T19:00:00Z","source":"ics"}],
+				"success":true,"itinerary":{"days":[]},
+				"driving":{"destination":"Whistler","province":"British Columbia","drive_on":"right","units":"km/h",
+					"speed_limits":{"urban":50,"rural":80,"highway":100},
+					"winter_tires":{"required":true,"from":"10-01","to":"04-30","note":"Sea to Sky"},
+					"rules":["Right on red"],"wildlife":["Bears"],"warnings":[]},
+				"rental":{"offer":{"id":"r1","provider":"mock","company":"Budget",
+						"vehicle":{"id":"midsize-suv","name":"Midsize SUV","examples":"RAV4","seats":5,"suitcases":3,
+							"fuel_l_per_100km":8.5,"daily_rate":60,"awd":true,"roof_box":true},
+						"days":4,"daily_rate":60,"total":240,"currency":"CAD"},
+					"roof_box":true,"daily_km":120,"fuel_price":1.6,"fuel_liters":40.8,"fuel_cost":65.28,
+					"rental_cost":280,"total":345.28,"currency":"CAD","selected_at":"2026-06-20T08:00:00Z"},
+				"festivals":[{"id":"f1","name":"Calgary Stampede","city":"Calgary","description":"Rodeo","category":"culture",
+					"tags":["rodeo"],"typical_start":"07-03","typical_end":"07-12","date_note":"early July",
+					"website":"https://www.calgarystampede.com",
+					"start_date":"2026-07-03","end_date":"2026-07-12","estimated":true}],
+				"metadata":{"city":"Vancouver","duration":4,"total_cost":1200.5,"generated_at":"2026-06-20T08:00:00Z"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertJSON(t, tt.value, tt.want)
+			assertRoundTrip(t, tt.value)
+		})
+	}
+}
+
+func TestChatResponseJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		value ChatResponse
+		want  string
+	}{
+		{
+			name:  "only required fields",
+			value: ChatResponse{Response: "Hi", SessionID: "s1", Intent: "greeting", Confidence: 1, Cards: []ChatCard{}, Timestamp: "2026-10-14T12:00:00Z"},
+			want:  `{"response":"Hi","session_id":"s1","intent":"greeting","confidence":1,"cards":[],"timestamp":"2026-10-14T12:00:00Z"}`,
+		},
+		{
+			name: "every field",
+			value: ChatResponse{
+				Response:    "Here's the weather",
+				SessionID:   "s1",
+				Intent:      "weather",
+				Confidence:  0.8,
+				Suggestions: []string{"Plan a trip"},
+				Data:        map[string]interface{}{"city": "Toronto"},
+				Intents:     []string{"weather", "events"},
+				Cards: []ChatCard{
+					{Type: "weather", Version: 1, Weather: &WeatherCard{
+						City: "Toronto", Temperature: 18, Condition: "Clear", Humidity: 50, WindSpeed: 3,
+						Forecast: []WeatherForecast{{Date: "2026-10-15", HighTemp: 19, LowTemp: 9, FeelsLikeHigh: 18, FeelsLikeLow: 7,
+							Condition: "Rain", Humidity: 70, WindSpeed: 5, Precipitation: 2.5, PrecipProbability: 0.6}},
+					}},
+					{Type: "event", Version: 1, Event: &EventCard{ID: "e1", Name: "Jazz Night", Date: "2026-10-16", Time: "20:00", Location: "The Rex", Category: "Music", Price: 25, BookingURL: "https://example.com/e1"},
+						Actions: []CardAction{{Label: "Book", Kind: "link", URL: "https://example.com/e1"}, {Label: "More like this", Kind: "message", Message: "More jazz"}}},
+					{Type: "itinerary", Version: 1, Itinerary: &ItineraryPreviewCard{ID: "it1", City: "Toronto", StartDate: "2026-10-16", EndDate: "2026-10-18", Days: 3, TotalCost: 600, Highlights: []string{"CN Tower"}}},
+				},
+				Audio:     &ChatAudio{URL: "/api/v1/chat/audio/a1", ContentType: "audio/mpeg", Provider: "mock"},
+				Turn:      2,
+				Moderated: "output",
+				Timestamp: "2026-10-14T12:00:00Z",
+			},
+			want: `{"response":"Here's the weather","session_id":"s1","intent":"weather","confidence":0.8,
+				"suggestions":["Plan a trip"],"data":{"city":"Toronto"},"intents":["weather","events"],
+				"cards":[
+					{"type":"weather","version":1,"weather":{"city":"Toronto","temperature":18,"condition":"Clear","humidity":50,"wind_speed":3,
+						"forecast":[{"date":"2026-10-15","high_temp":19,"low_temp":9,"feels_like_high":18,"feels_like_low":7,
+							"condition":"Rain","humidity":70,"wind_speed":5,"precipitation":2.5,"precip_probability":0.6}]}},
+					{"type":"event","version":1,"event":{"id":"e1","name":"Jazz Night","date":"2026-10-16","time":"20:00","location":"The Rex",
+						"category":"Music","price":25,"booking_url":"https://example.com/e1"},
+						"actions":[{"label":"Book","kind":"link","url":"https://example.com/e1"},{"label":"More like this","kind":"message","message":"More jazz"}]},
+					{"type":"itinerary","version":1,"itinerary":{"id":"it1","city":"Toronto","start_date":"2026-10-16","end_date":"2026-10-18",
+						"days":3,"total_cost":600,"highlights":["CN Tower"]}}],
+				"audio":{"url":"/api/v1/chat/audio/a1","content_type":"audio/mpeg","provider":"mock"},
+				"turn":2,"moderated":"output","timestamp":"2026-10-14T12:00:00Z"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertJSON(t, tt.value, tt.want)
+			assertRoundTrip(t, tt.value)
+		})
+	}
+}
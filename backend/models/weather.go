@@ -0,0 +1,25 @@
+package models
+
+// WeatherInfo represents weather information for a location
+type WeatherInfo struct {
+	Temperature float64 `json:"temperature"`
+	FeelsLike   float64 `json:"feels_like"` // with wind chill or humidex, °C
+	Condition   string  `json:"condition"`
+	Humidity    int     `json:"humidity"`
+	WindSpeed   float64 `json:"wind_speed"`
+	Estimated   bool    `json:"estimated,omitempty"` // seasonal averages rather than a live reading
+}
+
+// WeatherForecast represents a weather forecast for a specific date
+type WeatherForecast struct {
+	Date              string  `json:"date"`
+	HighTemp          float64 `json:"high_temp"`
+	LowTemp           float64 `json:"low_temp"`
+	FeelsLikeHigh     float64 `json:"feels_like_high"`
+	FeelsLikeLow      float64 `json:"feels_like_low"`
+	Condition         string  `json:"condition"`
+	Humidity          int     `json:"humidity"`
+	WindSpeed         float64 `json:"wind_speed"`
+	Precipitation     float64 `json:"precipitation"`
+	PrecipProbability float64 `json:"precip_probability"` // the day's highest chance, 0 to 1; 0 for seasonal estimates
+}
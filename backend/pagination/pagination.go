@@ -0,0 +1,254 @@
+// Package pagination implements the cursor-based paging shared by list endpoints.
+//
+// Lists accept ?limit, ?cursor, ?sort and ?order, plus ?since and ?until to
+// filter by each item's timestamp. Cursors are opaque keyset tokens: they record
+// the sort field, order and the last item returned, so pages stay stable while
+// items are added or removed.
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit bounds
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// ErrInvalidCursor is returned for cursors that can't be decoded or don't match the request
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Options describes how an endpoint can be paged
+type Options struct {
+	SortFields   []string // accepted ?sort values; the first is the default
+	Descending   bool     // default order
+	DefaultLimit int      // page size when ?limit is absent, DefaultLimit if zero
+}
+
+// Params is a parsed page request
+type Params struct {
+	Limit      int
+	Sort       string
+	Descending bool
+	Since      time.Time // zero when unset
+	Until      time.Time // zero when unset
+	after      *cursor
+}
+
+// Page describes the page returned alongside a list
+type Page struct {
+	Limit      int    `json:"limit"`
+	Total      int    `json:"total"` // items matching the filters, across all pages
+	HasMore    bool   `json:"has_more"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Sort       string `json:"sort"`
+	Order      string `json:"order"`
+}
+
+// Keys extracts sort keys from list items. Keys compare as strings, so build
+// them with TimeKey and NumberKey to keep timestamps and numbers in order.
+type Keys[T any] struct {
+	ID     func(T) string            // unique tiebreaker
+	Fields map[string]func(T) string // sort field -> key
+	Time   func(T) time.Time         // timestamp filtered by since and until, optional
+}
+
+// cursor is the decoded form of a page token
+type cursor struct {
+	Sort  string `json:"s"`
+	Order string `json:"o"`
+	Key   string `json:"k"`
+	ID    string `json:"i"`
+}
+
+// Parse reads paging parameters from a query string
+func Parse(query url.Values, opts Options) (Params, error) {
+	params := Params{Limit: opts.DefaultLimit, Descending: opts.Descending}
+	if params.Limit <= 0 {
+		params.Limit = DefaultLimit
+	}
+	if len(opts.SortFields) > 0 {
+		params.Sort = opts.SortFields[0]
+	}
+
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			return params, fmt.Errorf("limit must be a positive integer")
+		}
+		params.Limit = min(limit, MaxLimit)
+	}
+
+	if raw := query.Get("sort"); raw != "" {
+		if !contains(opts.SortFields, raw) {
+			return params, fmt.Errorf("sort must be one of: %s", strings.Join(opts.SortFields, ", "))
+		}
+		params.Sort = raw
+	}
+
+	switch strings.ToLower(query.Get("order")) {
+	case "":
+	case "asc":
+		params.Descending = false
+	case "desc":
+		params.Descending = true
+	default:
+		return params, fmt.Errorf("order must be 'asc' or 'desc'")
+	}
+
+	for name, target := range map[string]*time.Time{"since": &params.Since, "until": &params.Until} {
+		raw := query.Get(name)
+		if raw == "" {
+			continue
+		}
+		t, err := parseTime(raw)
+		if err != nil {
+			return params, fmt.Errorf("%s must be an RFC3339 timestamp or YYYY-MM-DD date", name)
+		}
+		*target = t
+	}
+
+	if raw := query.Get("cursor"); raw != "" {
+		after, err := decodeCursor(raw)
+		if err != nil {
+			return params, err
+		}
+		// A cursor carries its own ordering; an explicit conflicting one is a client error
+		if (query.Get("sort") != "" && query.Get("sort") != after.Sort) ||
+			(query.Get("order") != "" && !strings.EqualFold(query.Get("order"), after.Order)) {
+			return params, fmt.Errorf("%w: sort and order must match the cursor", ErrInvalidCursor)
+		}
+		if !contains(opts.SortFields, after.Sort) {
+			return params, ErrInvalidCursor
+		}
+		params.Sort = after.Sort
+		params.Descending = after.Order == "desc"
+		params.after = after
+	}
+
+	return params, nil
+}
+
+// Apply filters, sorts and slices items into the requested page
+func Apply[T any](items []T, params Params, keys Keys[T]) ([]T, Page) {
+	key := keys.Fields[params.Sort]
+	if key == nil {
+		key = keys.ID
+	}
+
+	filtered := make([]T, 0, len(items))
+	for _, item := range items {
+		if keys.Time != nil && !params.within(keys.Time(item)) {
+			continue
+		}
+		filtered = append(filtered, item)
+	}
+
+	less := func(aKey, aID, bKey, bID string) bool {
+		if aKey != bKey {
+			return (aKey < bKey) != params.Descending
+		}
+		if aID == bID {
+			return false
+		}
+		return (aID < bID) != params.Descending
+	}
+	sort.SliceStable(filtered, func(i, j int) bool {
+		return less(key(filtered[i]), keys.ID(filtered[i]), key(filtered[j]), keys.ID(filtered[j]))
+	})
+
+	start := 0
+	if params.after != nil {
+		start = sort.Search(len(filtered), func(i int) bool {
+			return less(params.after.Key, params.after.ID, key(filtered[i]), keys.ID(filtered[i]))
+		})
+	}
+	end := min(start+params.Limit, len(filtered))
+
+	page := Page{
+		Limit: params.Limit,
+		Total: len(filtered),
+		Sort:  params.Sort,
+		Order: params.order(),
+	}
+	result := filtered[start:end]
+	if end < len(filtered) && len(result) > 0 {
+		last := result[len(result)-1]
+		page.HasMore = true
+		page.NextCursor = encodeCursor(cursor{Sort: params.Sort, Order: page.Order, Key: key(last), ID: keys.ID(last)})
+	}
+	return result, page
+}
+
+// TimeKey formats a timestamp as a sortable key
+func TimeKey(t time.Time) string {
+	return t.UTC().Format("20060102T150405.000000000")
+}
+
+// NumberKey formats a number as a sortable key
+func NumberKey(value float64) string {
+	// Offset so negative values sort below positive ones
+	return fmt.Sprintf("%020.4f", value+math.Pow(10, 12))
+}
+
+// within reports whether t falls inside the since/until window
+func (p Params) within(t time.Time) bool {
+	if !p.Since.IsZero() && t.Before(p.Since) {
+		return false
+	}
+	if !p.Until.IsZero() && !t.Before(p.Until) {
+		return false
+	}
+	return true
+}
+
+func (p Params) order() string {
+	if p.Descending {
+		return "desc"
+	}
+	return "asc"
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(raw string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Sort == "" || (c.Order != "asc" && c.Order != "desc") {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// parseTime accepts RFC3339 timestamps and plain dates
+func parseTime(raw string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", raw)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
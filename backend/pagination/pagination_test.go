@@ -0,0 +1,193 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type item struct {
+	id    string
+	score float64
+	at    time.Time
+}
+
+var (
+	base     = time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	testOpts = Options{SortFields: []string{"score", "at"}, DefaultLimit: 2}
+	testKeys = Keys[item]{
+		ID: func(i item) string { return i.id },
+		Fields: map[string]func(item) string{
+			"score": func(i item) string { return NumberKey(i.score) },
+			"at":    func(i item) string { return TimeKey(i.at) },
+		},
+		Time: func(i item) time.Time { return i.at },
+	}
+	// Scores tie across page boundaries, so only the ID keeps the order stable
+	testItems = []item{
+		{id: "e", score: 3, at: base.Add(4 * time.Hour)},
+		{id: "b", score: 1, at: base.Add(1 * time.Hour)},
+		{id: "d", score: 1, at: base.Add(3 * time.Hour)},
+		{id: "a", score: -2, at: base},
+		{id: "c", score: 1, at: base.Add(2 * time.Hour)},
+	}
+)
+
+// walk pages through items with query, following next_cursor until the last page
+func walk(t *testing.T, query url.Values) []string {
+	t.Helper()
+	var ids []string
+	for pages := 0; pages < len(testItems)+1; pages++ {
+		params, err := Parse(query, testOpts)
+		if err != nil {
+			t.Fatalf("Parse(%s): %v", query.Encode(), err)
+		}
+		result, page := Apply(testItems, params, testKeys)
+		for _, i := range result {
+			ids = append(ids, i.id)
+		}
+		if page.Total != len(testItems) {
+			t.Errorf("total = %d, want %d", page.Total, len(testItems))
+		}
+		if !page.HasMore {
+			if page.NextCursor != "" {
+				t.Errorf("last page has a cursor")
+			}
+			return ids
+		}
+		// The cursor carries the sort and order, so only it is sent again
+		query = url.Values{"cursor": {page.NextCursor}, "limit": {query.Get("limit")}}
+	}
+	t.Fatalf("paging %v never ended", ids)
+	return nil
+}
+
+func TestApplyCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"default sort and limit", "", "a,b,c,d,e"},
+		{"descending with ties", "order=desc", "e,d,c,b,a"},
+		{"one per page", "limit=1", "a,b,c,d,e"},
+		{"page ending inside a tie", "limit=3&order=desc", "e,d,c,b,a"},
+		{"by time", "sort=at&order=desc&limit=2", "e,d,c,b,a"},
+		{"single page", "limit=10", "a,b,c,d,e"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("ParseQuery: %v", err)
+			}
+			if got := strings.Join(walk(t, query), ","); got != tt.want {
+				t.Errorf("paged %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyCursorAfterRemovedItem(t *testing.T) {
+	params, err := Parse(url.Values{}, testOpts)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	_, page := Apply(testItems, params, testKeys)
+
+	// Removing the last item of the first page doesn't repeat or skip the others
+	var remaining []item
+	for _, i := range testItems {
+		if i.id != "b" {
+			remaining = append(remaining, i)
+		}
+	}
+	params, err = Parse(url.Values{"cursor": {page.NextCursor}}, testOpts)
+	if err != nil {
+		t.Fatalf("Parse cursor: %v", err)
+	}
+	result, _ := Apply(remaining, params, testKeys)
+	if len(result) != 2 || result[0].id != "c" || result[1].id != "d" {
+		t.Errorf("page after the cursor = %+v, want c and d", result)
+	}
+}
+
+func TestParseRejectsInvalidCursors(t *testing.T) {
+	foreign := encodeCursor(cursor{Sort: "price", Order: "asc", Key: NumberKey(3), ID: "x"})
+	valid := encodeCursor(cursor{Sort: "score", Order: "asc", Key: NumberKey(1), ID: "b"})
+	tests := []struct {
+		name  string
+		query url.Values
+	}{
+		{"not base64", url.Values{"cursor": {"%%%"}}},
+		{"not JSON", url.Values{"cursor": {encodeRaw("page 2")}}},
+		{"missing sort", url.Values{"cursor": {encodeRaw(`{"o":"asc","k":"1","i":"b"}`)}}},
+		{"unknown order", url.Values{"cursor": {encodeRaw(`{"s":"score","o":"up","k":"1","i":"b"}`)}}},
+		{"from another list", url.Values{"cursor": {foreign}}},
+		{"conflicting sort", url.Values{"cursor": {valid}, "sort": {"at"}}},
+		{"conflicting order", url.Values{"cursor": {valid}, "order": {"desc"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.query, testOpts); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("Parse error = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+
+	// Repeating the cursor's own sort and order is fine
+	if _, err := Parse(url.Values{"cursor": {valid}, "sort": {"score"}, "order": {"ASC"}}, testOpts); err != nil {
+		t.Errorf("Parse with matching sort and order: %v", err)
+	}
+}
+
+func encodeRaw(data string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(data))
+}
+
+func TestParseClampsLimit(t *testing.T) {
+	tests := []struct {
+		limit   string
+		opts    Options
+		want    int
+		wantErr bool
+	}{
+		{limit: "", opts: testOpts, want: 2},
+		{limit: "", opts: Options{SortFields: []string{"score"}}, want: DefaultLimit},
+		{limit: "7", opts: testOpts, want: 7},
+		{limit: fmt.Sprint(MaxLimit), opts: testOpts, want: MaxLimit},
+		{limit: fmt.Sprint(MaxLimit + 1), opts: testOpts, want: MaxLimit},
+		{limit: "100000", opts: testOpts, want: MaxLimit},
+		{limit: "0", opts: testOpts, wantErr: true},
+		{limit: "-3", opts: testOpts, wantErr: true},
+		{limit: "ten", opts: testOpts, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run("limit="+tt.limit, func(t *testing.T) {
+			query := url.Values{}
+			if tt.limit != "" {
+				query.Set("limit", tt.limit)
+			}
+			params, err := Parse(query, tt.opts)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Parse accepted limit %q", tt.limit)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if params.Limit != tt.want {
+				t.Errorf("limit = %d, want %d", params.Limit, tt.want)
+			}
+			if _, page := Apply(testItems, params, testKeys); page.Limit != tt.want {
+				t.Errorf("page limit = %d, want %d", page.Limit, tt.want)
+			}
+		})
+	}
+}
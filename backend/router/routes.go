@@ -3,25 +3,47 @@ package router
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/joshndala/cantrip/handlers"
+	"github.com/joshndala/cantrip/middleware"
+	"github.com/joshndala/cantrip/services"
 )
 
 // SetupRoutes configures all API routes
 func SetupRoutes(r *gin.Engine) {
+	// Per-route timeout for endpoints that call the AI agent or render documents
+	expensive := middleware.Timeout(middleware.DefaultHandlerTimeout)
+	// Shorter budget for endpoints that only look up weather or events, which fall back
+	// to seasonal and sample data when an upstream runs out of time
+	lookup := middleware.Timeout(middleware.LookupHandlerTimeout)
+	// Streamed chat replies are bounded by the agent stream timeout rather than left open
+	streaming := middleware.Timeout(services.UpstreamTimeoutFor(services.UpstreamAgentStream))
+
 	// API v1 group
 	v1 := r.Group("/api/v1")
 	{
 		// Health check
 		v1.GET("/health", func(c *gin.Context) {
-			c.JSON(200, gin.H{"status": "healthy"})
+			c.JSON(200, gin.H{"status": "healthy", "agent": services.GetAgentHealth()})
 		})
 
+		// Build and runtime configuration, without secrets
+		v1.GET("/version", handlers.GetVersionHandler)
+
+		// The tenant serving the request and its branding
+		v1.GET("/tenant", handlers.GetTenantHandler)
+
 		// Chat routes
 		chat := v1.Group("/chat")
 		{
-			chat.POST("", handlers.ChatHandler)
-			chat.POST("/", handlers.ChatHandler)
-			chat.POST("/stream", handlers.ChatStreamHandler)
+			chat.POST("", expensive, handlers.ChatHandler)
+			chat.POST("/", expensive, handlers.ChatHandler)
+			chat.POST("/stream", streaming, handlers.ChatStreamHandler)
+			middleware.AllowBodySize("/api/v1/chat/voice", services.MaxVoiceBytes+64<<10)
+			chat.POST("/voice", expensive, handlers.VoiceChatHandler)
+			chat.GET("/audio/:name", handlers.GetChatAudioHandler)
+			chat.POST("/feedback", handlers.ChatFeedbackHandler)
+			chat.GET("/sessions", handlers.ListSessionsHandler)
 			chat.GET("/history/:session_id", handlers.GetConversationHistory)
+			chat.GET("/history/:session_id/export", expensive, handlers.ExportConversationHandler)
 			chat.DELETE("/history/:session_id", handlers.ClearConversation)
 			chat.GET("/suggestions/:session_id", handlers.GetConversationSuggestions)
 		}
@@ -29,37 +51,96 @@ func SetupRoutes(r *gin.Engine) {
 		// Explore routes
 		explore := v1.Group("/explore")
 		{
-			explore.POST("/", handlers.ExploreHandler)
-			explore.GET("/mood/:mood", handlers.GetExploreByMood)
+			explore.POST("/", expensive, handlers.ExploreHandler)
+			explore.GET("/mood/:mood", lookup, handlers.GetExploreByMood)
+			explore.GET("/recommended", lookup, handlers.GetRecommendationsHandler)
 		}
 
 		// Itinerary routes
 		itinerary := v1.Group("/itinerary")
 		{
-			itinerary.POST("/", handlers.CreateItineraryHandler)
+			itinerary.POST("/", expensive, handlers.CreateItineraryHandler)
 			itinerary.GET("/:id", handlers.GetItineraryHandler)
-			itinerary.PUT("/:id", handlers.UpdateItineraryHandler)
+			itinerary.GET("/:id/revisions", handlers.ListItineraryRevisionsHandler)
+			itinerary.GET("/:id/diff", handlers.GetItineraryDiffHandler)
+			itinerary.PUT("/:id", expensive, handlers.UpdateItineraryHandler)
+			itinerary.PATCH("/:id/recalculate", handlers.RecalculateItineraryHandler)
+			itinerary.PUT("/:id/rental", handlers.SelectRentalHandler)
 			itinerary.DELETE("/:id", handlers.DeleteItineraryHandler)
+			itinerary.DELETE("/batch", handlers.DeleteItineraryBatchHandler)
+			itinerary.POST("/:id/restore", handlers.RestoreItineraryHandler)
+			itinerary.POST("/:id/calendar", handlers.SyncItineraryCalendarHandler)
+			itinerary.DELETE("/:id/calendar", handlers.UnlinkItineraryCalendarHandler)
+			itinerary.POST("/:id/agenda", handlers.SubscribeAgendaFeedHandler)
+			itinerary.DELETE("/:id/agenda", handlers.UnsubscribeAgendaFeedHandler)
+			itinerary.POST("/:id/share", handlers.CreateTripShareHandler)
+			itinerary.DELETE("/:id/share/:token", handlers.RevokeTripShareHandler)
+		}
+
+		// Trip routes
+		trips := v1.Group("/trips")
+		{
+			trips.POST("/:id/import", expensive, handlers.ImportBookingsHandler)
+			trips.GET("/:id/emissions", handlers.GetTripEmissionsHandler)
+			// Uploads may exceed the default body limit; leave room for the multipart framing
+			middleware.AllowBodySize("/api/v1/trips/:id/attachments", services.MaxAttachmentBytes+64<<10)
+			trips.POST("/:id/attachments", handlers.UploadAttachmentHandler)
+			trips.GET("/:id/attachments", handlers.ListAttachmentsHandler)
+			trips.GET("/:id/attachments/:attachmentId", handlers.DownloadAttachmentHandler)
+			trips.DELETE("/:id/attachments/:attachmentId", handlers.DeleteAttachmentHandler)
+			trips.GET("/:id/journal", handlers.ListJournalEntriesHandler)
+			trips.POST("/:id/journal", handlers.CreateJournalEntryHandler)
+			trips.GET("/:id/journal/:entryId", handlers.GetJournalEntryHandler)
+			trips.PUT("/:id/journal/:entryId", handlers.UpdateJournalEntryHandler)
+			trips.DELETE("/:id/journal/:entryId", handlers.DeleteJournalEntryHandler)
+			middleware.AllowBodySize("/api/v1/trips/:id/journal/:entryId/photos", services.MaxAttachmentBytes+64<<10)
+			trips.POST("/:id/journal/:entryId/photos", handlers.UploadJournalPhotoHandler)
+			trips.GET("/:id/memories", expensive, handlers.ExportMemoriesHandler)
+			trips.POST("/:id/summary", expensive, handlers.CompileTripSummaryHandler)
+			trips.PUT("/:id/ratings", handlers.RateTripHandler)
+			middleware.AllowBodySize("/api/v1/trips/:id/expenses", services.MaxAttachmentBytes+64<<10)
+			trips.POST("/:id/expenses", handlers.AddExpenseHandler)
+			trips.GET("/:id/expenses", handlers.ListExpensesHandler)
+			trips.DELETE("/:id/expenses/:expenseId", handlers.DeleteExpenseHandler)
 		}
 
 		// Packing routes
 		packing := v1.Group("/packing")
 		{
-			packing.POST("/", handlers.GeneratePackingListHandler)
+			packing.POST("/", lookup, handlers.GeneratePackingListHandler)
+			packing.POST("/import", handlers.ImportPackingListHandler)
+			packing.POST("/activities/classify", handlers.ClassifyActivitiesHandler)
 			packing.GET("/:id", handlers.GetPackingListHandler)
-			packing.PUT("/:id", handlers.UpdatePackingListHandler)
+			packing.PUT("/:id", lookup, handlers.UpdatePackingListHandler)
+			packing.DELETE("/:id", handlers.DeletePackingListHandler)
+			packing.DELETE("/batch", handlers.DeletePackingListBatchHandler)
+			packing.POST("/:id/restore", handlers.RestorePackingListHandler)
 			packing.GET("/suggestions", handlers.GetPackingSuggestionsHandler)
-			packing.GET("/:id/export", handlers.ExportPackingListHandler)
+			packing.GET("/templates", handlers.ListPackingTemplatesHandler)
+			packing.GET("/templates/:templateId", handlers.GetPackingTemplateHandler)
+			packing.POST("/templates/:templateId/instantiate", lookup, handlers.InstantiatePackingTemplateHandler)
+			packing.DELETE("/templates/:templateId", handlers.DeletePackingTemplateHandler)
+			packing.POST("/:id/template", handlers.SavePackingTemplateHandler)
+			packing.GET("/baggage-policies", handlers.GetBaggagePoliciesHandler)
+			packing.GET("/:id/baggage-check", handlers.CheckBaggageHandler)
+			packing.GET("/:id/export", expensive, handlers.ExportPackingListHandler)
 		}
 
 		// Tips routes
 		tips := v1.Group("/tips")
 		{
 			tips.POST("/", handlers.GetTravelTipsHandler)
+			tips.POST("/submissions", handlers.SubmitTipHandler)
+			tips.GET("/submissions", handlers.ListMyTipsHandler)
 			tips.GET("/cultural/:destination", handlers.GetCulturalTipsHandler)
 			tips.GET("/tipping/:destination", handlers.GetTippingGuideHandler)
 			tips.GET("/safety/:destination", handlers.GetSafetyTipsHandler)
 			tips.GET("/customs/:destination", handlers.GetLocalCustomsHandler)
+			tips.GET("/health/:destination", handlers.GetHealthTipsHandler)
+			tips.GET("/insurance", handlers.GetInsuranceInfoHandler)
+			tips.GET("/connectivity/:destination", handlers.GetConnectivityTipsHandler)
+			tips.GET("/offline/:destination", handlers.GetOfflineBundleHandler)
+			tips.GET("/driving/:destination", handlers.GetDrivingInfoHandler)
 			tips.GET("/emergency/:destination", handlers.GetEmergencyInfoHandler)
 			tips.GET("/language/:destination", handlers.GetLanguageInfoHandler)
 		}
@@ -67,35 +148,147 @@ func SetupRoutes(r *gin.Engine) {
 		// Weather routes
 		weather := v1.Group("/weather")
 		{
-			weather.GET("/current", handlers.GetWeatherHandler)
-			weather.GET("/forecast", handlers.GetWeatherForecastHandler)
-			weather.GET("/forecast/with-notes", handlers.GetWeatherForecastWithNotesHandler)
+			weather.GET("/current", lookup, handlers.GetWeatherHandler)
+			weather.GET("/forecast", lookup, handlers.GetWeatherForecastHandler)
+			weather.GET("/forecast/with-notes", lookup, handlers.GetWeatherForecastWithNotesHandler)
 		}
 
 		// Places routes
 		places := v1.Group("/places")
 		{
-			places.GET("/events", handlers.GetEventsHandler)
-			places.GET("/suggestions", handlers.GenerateTripSuggestionsHandler)
+			places.GET("/events", lookup, handlers.GetEventsHandler)
+			places.GET("/festivals", handlers.GetFestivalsHandler)
+			places.GET("/nightlife/:city", handlers.GetNightlifeHandler)
+			places.GET("/suggestions", lookup, handlers.GenerateTripSuggestionsHandler)
+			places.GET("/cities", handlers.ListCitiesHandler)
+			places.GET("/neighborhoods/:city", handlers.GetNeighborhoodsHandler)
+			places.GET("/attractions/:city", handlers.GetAttractionsHandler)
+			// the attraction ID shares the :city wildcard, which gin requires per segment
+			places.POST("/attractions/:city/reviews", handlers.SubmitReviewHandler)
+			places.GET("/attractions/:city/reviews", handlers.ListReviewsHandler)
+		}
+
+		// Guide routes
+		guides := v1.Group("/guides")
+		{
+			guides.GET("/:city", handlers.ListGuidesHandler)
+			guides.GET("/:city/:slug", handlers.GetGuideHandler)
+		}
+
+		// Search routes
+		search := v1.Group("/search")
+		{
+			search.GET("/semantic", handlers.SemanticSearchHandler)
+			search.GET("/autocomplete", handlers.AutocompleteHandler)
+		}
+
+		// Transport routes
+		transport := v1.Group("/transport")
+		{
+			transport.GET("/rentals", handlers.SearchRentalsHandler)
 		}
 
 		// PDF routes
 		pdf := v1.Group("/pdf")
 		{
-			pdf.POST("/generate", handlers.GeneratePDFHandler)
+			pdf.POST("/generate", expensive, handlers.GeneratePDFHandler)
+			pdf.POST("/generate/batch", expensive, handlers.GeneratePDFBatchHandler)
 			pdf.GET("/download/:id", handlers.DownloadPDFHandler)
 			pdf.GET("/status/:id", handlers.GetPDFStatusHandler)
 			pdf.DELETE("/:id", handlers.DeletePDFHandler)
 			pdf.GET("/list", handlers.ListPDFsHandler)
+			pdf.GET("/source/:type/:id", handlers.ListSourcePDFsHandler)
 			pdf.POST("/share/:id", handlers.SharePDFHandler)
 		}
+
+		// Local object store signed URLs, used when no GCS bucket is configured
+		v1.GET("/objects/*object", handlers.GetLocalObjectHandler)
+
+		// Resized image previews
+		v1.GET("/images/:id", handlers.GetImagePreviewHandler)
+
+		// Trip cards for third-party sites, readable from any allowed origin
+		embed := v1.Group("/embed", middleware.EmbedCORS())
+		{
+			embed.GET("/itinerary/:token", handlers.GetEmbedTripHandler)
+			embed.OPTIONS("/itinerary/:token")
+		}
+
+		// Event tracking routes
+		events := v1.Group("/events")
+		{
+			events.GET("/watches", handlers.ListEventWatchesHandler)
+			events.POST("/:id/watch", handlers.WatchEventHandler)
+			events.DELETE("/:id/watch", handlers.UnwatchEventHandler)
+		}
+
+		// Notification routes
+		notifications := v1.Group("/notifications")
+		{
+			notifications.GET("", handlers.ListNotificationsHandler)
+			notifications.POST("/:id/read", handlers.MarkNotificationReadHandler)
+			notifications.GET("/devices", handlers.ListDevicesHandler)
+			notifications.POST("/devices", handlers.RegisterDeviceHandler)
+			notifications.DELETE("/devices/:token", handlers.UnregisterDeviceHandler)
+			notifications.GET("/preferences", handlers.GetNotificationPreferencesHandler)
+			notifications.PUT("/preferences", handlers.UpdateNotificationPreferencesHandler)
+		}
+
+		// Google Calendar integration
+		calendar := v1.Group("/calendar/google")
+		{
+			calendar.GET("/connect", handlers.ConnectCalendarHandler)
+			calendar.GET("/callback", handlers.CalendarCallbackHandler)
+			calendar.DELETE("", handlers.DisconnectCalendarHandler)
+		}
+
+		// Outbound booking links
+		out := v1.Group("/out")
+		{
+			out.GET("/:event_id", handlers.OutboundRedirectHandler)
+			out.POST("/:event_id/conversion", handlers.ConversionPostbackHandler)
+		}
+
+		// Trash routes
+		v1.GET("/trash", handlers.ListTrashHandler)
+
+		// Admin routes
+		admin := v1.Group("/admin", middleware.RequireAdmin())
+		{
+			admin.GET("/audit", handlers.GetAuditLogHandler)
+			admin.POST("/cities/import", handlers.ImportCitiesHandler)
+			admin.POST("/cities/reload", handlers.ReloadCitiesHandler)
+			admin.POST("/rules/reload", handlers.ReloadRulesHandler)
+			admin.POST("/search/reindex", handlers.ReindexSearchHandler)
+			admin.POST("/guides", handlers.IngestGuidesHandler)
+			admin.DELETE("/guides/:id", handlers.DeleteGuideHandler)
+			admin.GET("/tips", handlers.ListSubmittedTipsHandler)
+			admin.POST("/tips/:id/approve", handlers.ApproveTipHandler)
+			admin.POST("/tips/:id/reject", handlers.RejectTipHandler)
+			admin.DELETE("/reviews/:id", handlers.RemoveReviewHandler)
+			admin.GET("/outbound/stats", handlers.GetOutboundStatsHandler)
+			admin.GET("/chat/feedback", handlers.GetChatFeedbackHandler)
+			admin.GET("/moderation/incidents", handlers.GetModerationIncidentsHandler)
+			admin.GET("/analytics", handlers.GetAnalyticsStatsHandler)
+			admin.GET("/experiments", handlers.GetExperimentResultsHandler)
+			admin.GET("/render/stats", handlers.GetRenderPoolStatsHandler)
+			admin.GET("/upstream/stats", handlers.GetUpstreamDedupeStatsHandler)
+			admin.GET("/debug/pprof/*profile", handlers.ProfileHandler)
+			admin.POST("/debug/pprof/*profile", handlers.ProfileHandler) // pprof posts symbol lookups
+		}
 	}
 
+	// Public pages for shared trips
+	r.GET("/share/trip/:token", handlers.SharedTripPageHandler)
+
+	// Daily agenda feeds for calendar subscriptions, e.g. /agenda/<token>.ics
+	r.GET("/agenda/:token", handlers.AgendaFeedHandler)
+
 	// Root route
 	r.GET("/", func(c *gin.Context) {
 		c.JSON(200, gin.H{
 			"message": "Welcome to CanTrip API",
-			"version": "1.0.0",
+			"version": services.BuildVersion,
 			"endpoints": gin.H{
 				"chat":      "/api/v1/chat",
 				"explore":   "/api/v1/explore",
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/joshndala/cantrip/models"
+	"github.com/joshndala/cantrip/utils"
+)
+
+// ActivityClassifierAgent enables the agent fallback via ACTIVITY_CLASSIFIER
+const ActivityClassifierAgent = "agent"
+
+// Activity match sources
+const (
+	ActivityMatchRule    = "rule"    // the description is a rule key
+	ActivityMatchKeyword = "keyword" // a synonym appears in the description
+	ActivityMatchAgent   = "agent"   // classified by the agent
+)
+
+// ActivityMatch maps a free-text activity description to packing rule categories
+type ActivityMatch = models.ActivityMatch
+
+// ClassifyActivitiesRequest asks the agent to classify descriptions into known categories
+type ClassifyActivitiesRequest struct {
+	Descriptions []string `json:"descriptions"`
+	Categories   []string `json:"categories"`
+}
+
+// ClassifyActivitiesResponse is the agent's classification, in request order
+type ClassifyActivitiesResponse struct {
+	Matches []ActivityMatch `json:"matches"`
+}
+
+// ClassifyActivities maps free-text activities such as "backcountry camping near Jasper"
+// to activity rule keys. Rule keys and synonyms are matched first; with ACTIVITY_CLASSIFIER=agent
+// the agent classifies whatever the keywords miss.
+func ClassifyActivities(ctx context.Context, descriptions []string) ([]ActivityMatch, error) {
+	rules, err := loadPackingRules(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load packing rules: %w", err)
+	}
+
+	categories := make([]string, 0, len(rules.ActivityRules))
+	for key := range rules.ActivityRules {
+		categories = append(categories, key)
+	}
+	sort.Strings(categories)
+
+	matches := make([]ActivityMatch, len(descriptions))
+	var unmatched []int
+	for i, description := range descriptions {
+		matches[i] = classifyActivity(rules, categories, description)
+		if len(matches[i].Activities) == 0 && strings.TrimSpace(description) != "" {
+			unmatched = append(unmatched, i)
+		}
+	}
+
+	if len(unmatched) > 0 && os.Getenv("ACTIVITY_CLASSIFIER") == ActivityClassifierAgent {
+		classifyActivitiesWithAgent(ctx, categories, descriptions, unmatched, matches)
+	}
+	return matches, nil
+}
+
+// CanonicalActivities returns the distinct activity rule keys across matches, in order
+func CanonicalActivities(matches []ActivityMatch) []string {
+	activities := []string{}
+	for _, match := range matches {
+		for _, activity := range match.Activities {
+			if !utils.Contains(activities, activity) {
+				activities = append(activities, activity)
+			}
+		}
+	}
+	return activities
+}
+
+// ResolvePackingActivities replaces the free-text activities of a packing request, and of
+// each traveler, with activity rule keys and returns how every description was classified
+func ResolvePackingActivities(ctx context.Context, req *PackingRequest) ([]ActivityMatch, error) {
+	descriptions := append([]string{}, req.Activities...)
+	for _, traveler := range req.Travelers {
+		descriptions = append(descriptions, traveler.Activities...)
+	}
+	if len(descriptions) == 0 {
+		return nil, nil
+	}
+
+	matches, err := ClassifyActivities(ctx, descriptions)
+	if err != nil {
+		return nil, err
+	}
+
+	next := len(req.Activities)
+	req.Activities = CanonicalActivities(matches[:next])
+	for i := range req.Travelers {
+		n := len(req.Travelers[i].Activities)
+		if n == 0 {
+			continue
+		}
+		req.Travelers[i].Activities = CanonicalActivities(matches[next : next+n])
+		next += n
+	}
+	return matches, nil
+}
+
+// classifyActivity matches one description against rule keys and their synonyms
+func classifyActivity(rules *PackingRules, categories []string, description string) ActivityMatch {
+	match := ActivityMatch{Input: description, Activities: []string{}}
+	words := activityWords(description)
+	if words == "  " {
+		return match
+	}
+
+	// A rule key given directly, e.g. "outdoor_adventure" or "Outdoor Adventure"
+	for _, category := range categories {
+		if words == activityWords(category) {
+			match.Activities = []string{category}
+			match.Source = ActivityMatchRule
+			match.Confidence = 1
+			return match
+		}
+	}
+
+	for _, category := range categories {
+		matched := false
+		for _, synonym := range append([]string{strings.ReplaceAll(category, "_", " ")}, rules.ActivitySynonyms[category]...) {
+			if strings.Contains(words, activityWords(synonym)) && !utils.Contains(match.Matched, synonym) {
+				match.Matched = append(match.Matched, synonym)
+				matched = true
+			}
+		}
+		if matched {
+			match.Activities = append(match.Activities, category)
+		}
+	}
+	if len(match.Activities) > 0 {
+		match.Source = ActivityMatchKeyword
+		// Several categories from one phrase are less certain than a single one
+		match.Confidence = 0.9 / float64(len(match.Activities))
+		if match.Confidence < 0.5 {
+			match.Confidence = 0.5
+		}
+	}
+	return match
+}
+
+// classifyActivitiesWithAgent fills in unmatched descriptions from the agent. Categories
+// the rules don't know are dropped; failures leave the descriptions unclassified.
+func classifyActivitiesWithAgent(ctx context.Context, categories, descriptions []string, unmatched []int, matches []ActivityMatch) {
+	req := ClassifyActivitiesRequest{Categories: categories}
+	for _, i := range unmatched {
+		req.Descriptions = append(req.Descriptions, descriptions[i])
+	}
+
+	var result ClassifyActivitiesResponse
+	if err := GetAIClient().transport.Call(ctx, AgentMethodClassifyActivities, req, &result); err != nil {
+		utils.LogError("Failed to classify activities with the agent", err)
+		return
+	}
+
+	for j, i := range unmatched {
+		if j >= len(result.Matches) {
+			break
+		}
+		agentMatch := result.Matches[j]
+		activities := []string{}
+		for _, activity := range agentMatch.Activities {
+			if utils.Contains(categories, activity) && !utils.Contains(activities, activity) {
+				activities = append(activities, activity)
+			}
+		}
+		if len(activities) == 0 {
+			continue
+		}
+		matches[i].Activities = activities
+		matches[i].Source = ActivityMatchAgent
+		matches[i].Confidence = agentMatch.Confidence
+	}
+}
+
+// activityWords lowercases text and pads its words with spaces so phrases match on word
+// boundaries: " backcountry camping near jasper "
+func activityWords(text string) string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return " " + strings.Join(fields, " ") + " "
+}
@@ -0,0 +1,380 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/joshndala/cantrip/models"
+	"github.com/joshndala/cantrip/utils"
+)
+
+// agendaFeedCollection stores trip agenda subscriptions, one document per feed token
+const agendaFeedCollection = "agenda_feeds"
+
+// agendaEmailHour is the local hour, in the trip city, daily agenda emails go out from
+const agendaEmailHour = 7
+
+// agendaRefreshInterval is how often calendar apps are asked to re-fetch a feed, so
+// itinerary edits and forecast updates show up
+const agendaRefreshInterval = "PT1H"
+
+var (
+	// ErrAgendaFeedNotFound is returned for an unknown or revoked feed token
+	ErrAgendaFeedNotFound = errors.New("agenda feed not found")
+	// ErrInvalidAgendaFeed is returned for a daily email request without a valid address
+	ErrInvalidAgendaFeed = errors.New("invalid agenda feed")
+)
+
+// AgendaFeed is a subscription to a trip's daily agenda. The token is its only credential,
+// so calendar apps can fetch it without signing in.
+type AgendaFeed struct {
+	Token       string    `json:"token"`
+	TripID      string    `json:"trip_id"`
+	UserID      string    `json:"user_id"`
+	DailyEmail  bool      `json:"daily_email"`
+	Email       string    `json:"email,omitempty"`
+	LastEmailed string    `json:"last_emailed,omitempty"` // YYYY-MM-DD in the trip's timezone
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// AgendaFeedInput are the options for a trip's agenda feed
+type AgendaFeedInput struct {
+	DailyEmail bool   `json:"daily_email"`
+	Email      string `json:"email"` // required with daily_email
+}
+
+// DailyAgenda is one day of a trip as the feed and email show it
+type DailyAgenda struct {
+	TripID     string           `json:"trip_id"`
+	Title      string           `json:"title"`
+	City       string           `json:"city"`
+	Date       string           `json:"date"`
+	Day        int              `json:"day"` // 1 for the first day of the trip
+	Timezone   string           `json:"timezone"`
+	Weather    *WeatherForecast `json:"weather,omitempty"`
+	Activities []AgendaActivity `json:"activities"`
+}
+
+// AgendaActivity is a timed activity on a day's agenda
+type AgendaActivity struct {
+	Key         string `json:"key"` // stable across fetches, so calendar apps update events in place
+	Name        string `json:"name"`
+	Start       string `json:"start"` // HH:MM local time
+	End         string `json:"end"`
+	Location    string `json:"location,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// SubscribeAgendaFeed creates a trip's agenda feed for a user, or updates the email
+// settings of the one they already have
+func SubscribeAgendaFeed(ctx context.Context, itinerary *ItineraryResponse, userID string, input AgendaFeedInput) (*AgendaFeed, error) {
+	email := strings.TrimSpace(input.Email)
+	if input.DailyEmail {
+		address, err := mail.ParseAddress(email)
+		if err != nil {
+			return nil, fmt.Errorf("%w: a valid email is required for the daily agenda email", ErrInvalidAgendaFeed)
+		}
+		email = address.Address
+	}
+
+	feed, err := findAgendaFeed(ctx, itinerary.ID, userID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if feed == nil {
+		feed = &AgendaFeed{Token: utils.GenerateID(), TripID: itinerary.ID, UserID: userID, CreatedAt: now}
+	}
+	feed.DailyEmail = input.DailyEmail
+	feed.Email = email
+	feed.UpdatedAt = now
+
+	if err := saveDocument(ctx, agendaFeedCollection, feed.Token, feed); err != nil {
+		return nil, fmt.Errorf("failed to save agenda feed: %w", err)
+	}
+	return feed, nil
+}
+
+// findAgendaFeed returns a user's feed for a trip, or nil when they haven't subscribed
+func findAgendaFeed(ctx context.Context, tripID, userID string) (*AgendaFeed, error) {
+	feeds, err := listAgendaFeeds(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range feeds {
+		if feeds[i].TripID == tripID && feeds[i].UserID == userID {
+			return &feeds[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// listAgendaFeeds loads every agenda feed
+func listAgendaFeeds(ctx context.Context) ([]AgendaFeed, error) {
+	ids, err := listDocumentIDs(ctx, agendaFeedCollection)
+	if err != nil {
+		return nil, err
+	}
+	feeds := make([]AgendaFeed, 0, len(ids))
+	for _, id := range ids {
+		var feed AgendaFeed
+		if err := loadDocument(ctx, agendaFeedCollection, id, &feed); err != nil {
+			utils.LogError("Failed to load agenda feed", err)
+			continue
+		}
+		feeds = append(feeds, feed)
+	}
+	return feeds, nil
+}
+
+// UnsubscribeAgendaFeed deletes a user's feed for a trip, so its URL stops resolving
+func UnsubscribeAgendaFeed(ctx context.Context, tripID, userID string) error {
+	feed, err := findAgendaFeed(ctx, tripID, userID)
+	if err != nil {
+		return err
+	}
+	if feed == nil {
+		return ErrAgendaFeedNotFound
+	}
+	return deleteDocument(ctx, agendaFeedCollection, feed.Token)
+}
+
+// ResolveAgendaFeed returns a feed and the current version of its trip, so edits show up
+// on the next fetch
+func ResolveAgendaFeed(ctx context.Context, token string) (*AgendaFeed, *ItineraryResponse, error) {
+	var feed AgendaFeed
+	if err := loadDocument(ctx, agendaFeedCollection, token, &feed); err != nil {
+		if errors.Is(err, ErrDocumentNotFound) {
+			return nil, nil, ErrAgendaFeedNotFound
+		}
+		return nil, nil, err
+	}
+	// Trips in the trash aren't served
+	itinerary, err := GetItinerary(ctx, feed.TripID)
+	if err != nil {
+		return nil, nil, ErrAgendaFeedNotFound
+	}
+	return &feed, itinerary, nil
+}
+
+// TripToday returns the date in the trip city's timezone, YYYY-MM-DD
+func TripToday(ctx context.Context, itinerary *ItineraryResponse) string {
+	return tripLocalTime(ctx, itinerary).Format("2006-01-02")
+}
+
+// tripLocalTime is the current time in the trip city
+func tripLocalTime(ctx context.Context, itinerary *ItineraryResponse) time.Time {
+	city := stringField(itinerary.Itinerary, "city", itinerary.Metadata.City)
+	location, err := time.LoadLocation(calendarTimezone(ctx, city))
+	if err != nil {
+		return time.Now().UTC()
+	}
+	return time.Now().In(location)
+}
+
+// BuildDailyAgenda returns a trip's agenda for a date with the latest forecast, or nil when
+// the date isn't one of the trip's days
+func BuildDailyAgenda(ctx context.Context, itinerary *ItineraryResponse, date string) *DailyAgenda {
+	city, start, end, ok := itineraryTripDates(itinerary)
+	day, err := time.Parse("2006-01-02", date)
+	if !ok || err != nil || day.Before(start) || day.After(end) {
+		return nil
+	}
+
+	timezone := calendarTimezone(ctx, city)
+	agenda := &DailyAgenda{
+		TripID:     itinerary.ID,
+		Title:      tripTitle(itinerary, city),
+		City:       city,
+		Date:       date,
+		Day:        models.DaysBetween(start, day) + 1,
+		Timezone:   timezone,
+		Activities: []AgendaActivity{},
+	}
+
+	for _, activity := range buildCalendarActivities(itinerary, city, timezone) {
+		event := activity.event
+		if !strings.HasPrefix(event.Start.DateTime, date+"T") {
+			continue
+		}
+		agenda.Activities = append(agenda.Activities, AgendaActivity{
+			Key:         activity.key,
+			Name:        event.Summary,
+			Start:       event.Start.DateTime[len("2006-01-02T") : len("2006-01-02T")+5],
+			End:         event.End.DateTime[len("2006-01-02T") : len("2006-01-02T")+5],
+			Location:    event.Location,
+			Description: event.Description,
+		})
+	}
+
+	if forecast, err := GetWeatherForecast(ctx, city, date, date); err == nil && len(forecast) > 0 {
+		agenda.Weather = &forecast[0]
+	} else if err != nil {
+		utils.LogError("Failed to get weather for daily agenda", err)
+	}
+	return agenda
+}
+
+// weatherSummary describes a day's forecast in one line, e.g. "Light rain, 12°C / 6°C, 80% chance of precipitation"
+func weatherSummary(forecast *WeatherForecast) string {
+	summary := fmt.Sprintf("%s, %.0f°C / %.0f°C", utils.CapitalizeFirst(forecast.Condition), forecast.HighTemp, forecast.LowTemp)
+	if forecast.PrecipProbability > 0 {
+		summary += fmt.Sprintf(", %.0f%% chance of precipitation", forecast.PrecipProbability*100)
+	}
+	return summary
+}
+
+// RenderAgendaICS renders a day's agenda as an iCalendar feed. A nil agenda, for a
+// date outside the trip, renders a calendar with no events.
+func RenderAgendaICS(feed *AgendaFeed, itinerary *ItineraryResponse, agenda *DailyAgenda) []byte {
+	title := tripTitle(itinerary, stringField(itinerary.Itinerary, "city", itinerary.Metadata.City))
+	var b strings.Builder
+	line := func(s string) {
+		b.WriteString(foldICSLine(s))
+		b.WriteString("\r\n")
+	}
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//CanTrip//Daily Agenda//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	line("X-WR-CALNAME:" + escapeICSText(title))
+	line("REFRESH-INTERVAL;VALUE=DURATION:" + agendaRefreshInterval)
+	line("X-PUBLISHED-TTL:" + agendaRefreshInterval)
+
+	if agenda != nil {
+		date := strings.ReplaceAll(agenda.Date, "-", "")
+		line("X-WR-TIMEZONE:" + agenda.Timezone)
+		if agenda.Weather != nil {
+			next, _ := time.Parse("2006-01-02", agenda.Date)
+			line("BEGIN:VEVENT")
+			line("UID:" + feed.Token + "-" + date + "-weather@cantrip")
+			line("DTSTAMP:" + stamp)
+			line("DTSTART;VALUE=DATE:" + date)
+			line("DTEND;VALUE=DATE:" + next.AddDate(0, 0, 1).Format("20060102"))
+			line("SUMMARY:" + escapeICSText(fmt.Sprintf("Day %d in %s: %s", agenda.Day, agenda.City, weatherSummary(agenda.Weather))))
+			line("TRANSP:TRANSPARENT")
+			line("END:VEVENT")
+		}
+		for _, activity := range agenda.Activities {
+			line("BEGIN:VEVENT")
+			line("UID:" + feed.Token + "-" + AnonymousID(activity.Key) + "@cantrip")
+			line("DTSTAMP:" + stamp)
+			line("DTSTART;TZID=" + agenda.Timezone + ":" + date + "T" + strings.ReplaceAll(activity.Start, ":", "") + "00")
+			line("DTEND;TZID=" + agenda.Timezone + ":" + date + "T" + strings.ReplaceAll(activity.End, ":", "") + "00")
+			line("SUMMARY:" + escapeICSText(activity.Name))
+			if activity.Location != "" {
+				line("LOCATION:" + escapeICSText(activity.Location))
+			}
+			if activity.Description != "" {
+				line("DESCRIPTION:" + escapeICSText(activity.Description))
+			}
+			line("END:VEVENT")
+		}
+	}
+
+	line("END:VCALENDAR")
+	return []byte(b.String())
+}
+
+// escapeICSText escapes an iCalendar TEXT value
+func escapeICSText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// foldICSLine wraps a content line at 75 octets as RFC 5545 requires, without splitting
+// a UTF-8 character
+func foldICSLine(s string) string {
+	const limit = 75
+	if len(s) <= limit {
+		return s
+	}
+	var b strings.Builder
+	width := 0
+	for _, r := range s {
+		size := len(string(r))
+		if width+size > limit {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	return b.String()
+}
+
+// SendDailyAgendas emails each subscriber their trip's agenda once a day, from 7am in the
+// trip city. It runs with the reminder hooks, so emails go out within the hour.
+func SendDailyAgendas(ctx context.Context) (int, error) {
+	mailer := GetMailer()
+	if mailer == nil {
+		return 0, nil
+	}
+	feeds, err := listAgendaFeeds(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, feed := range feeds {
+		if !feed.DailyEmail || feed.Email == "" {
+			continue
+		}
+		itinerary, err := GetItinerary(ctx, feed.TripID)
+		if err != nil {
+			continue
+		}
+		now := tripLocalTime(ctx, itinerary)
+		today := now.Format("2006-01-02")
+		if now.Hour() < agendaEmailHour || feed.LastEmailed == today {
+			continue
+		}
+		agenda := BuildDailyAgenda(ctx, itinerary, today)
+		if agenda == nil {
+			continue
+		}
+
+		if err := mailer.Send(ctx, agendaEmail(feed.Email, agenda)); err != nil {
+			utils.LogError("Failed to send daily agenda to "+feed.Email, err)
+			continue
+		}
+		feed.LastEmailed = today
+		if err := saveDocument(ctx, agendaFeedCollection, feed.Token, feed); err != nil {
+			utils.LogError("Failed to save agenda feed", err)
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// agendaEmail writes a day's agenda as a plain-text email
+func agendaEmail(to string, agenda *DailyAgenda) Email {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Good morning! Here's day %d of %s.\n\n", agenda.Day, agenda.Title)
+	if agenda.Weather != nil {
+		fmt.Fprintf(&b, "Weather: %s\n\n", weatherSummary(agenda.Weather))
+	}
+	if len(agenda.Activities) == 0 {
+		b.WriteString("Nothing is scheduled today. Enjoy the free time!\n")
+	}
+	for _, activity := range agenda.Activities {
+		fmt.Fprintf(&b, "%s-%s  %s\n", activity.Start, activity.End, activity.Name)
+		if activity.Location != "" {
+			fmt.Fprintf(&b, "             %s\n", activity.Location)
+		}
+	}
+	b.WriteString("\nYou're getting this because you subscribed to your trip's daily agenda in CanTrip.\n")
+
+	return Email{
+		To:      to,
+		Subject: fmt.Sprintf("Your day in %s: %s", agenda.City, agenda.Date),
+		Text:    b.String(),
+	}
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// AgentGRPCPort is the port the agent serves the gRPC contract on, beside its HTTP port
+const AgentGRPCPort = "50051"
+
+// agentServicePath prefixes every method of the Agent service in agent.proto
+const agentServicePath = "/" + AgentContractVersion + ".Agent/"
+
+// grpcAgentTransport calls the agent's gRPC service. Requests and results are
+// google.protobuf.Struct messages holding the same fields as the JSON routes.
+type grpcAgentTransport struct {
+	conn *grpc.ClientConn
+}
+
+// newGRPCAgentTransport creates a gRPC transport for target; the connection is made on
+// the first call
+func newGRPCAgentTransport(target string) (*grpcAgentTransport, error) {
+	conn, err := grpc.NewClient(target,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()), // Traced, so the agent continues the trace
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create agent gRPC client for %s: %w", target, err)
+	}
+	return &grpcAgentTransport{conn: conn}, nil
+}
+
+// agentGRPCTarget is AGENT_GRPC_ADDR, or the host of the agent's HTTP base URL on AgentGRPCPort
+func agentGRPCTarget(baseURL string) string {
+	if addr := os.Getenv("AGENT_GRPC_ADDR"); addr != "" {
+		return addr
+	}
+	host := "localhost"
+	if parsed, err := url.Parse(baseURL); err == nil && parsed.Hostname() != "" {
+		host = parsed.Hostname()
+	}
+	return net.JoinHostPort(host, AgentGRPCPort)
+}
+
+func (t *grpcAgentTransport) Name() string {
+	return "grpc/" + AgentContractVersion
+}
+
+// Connect dials the agent and waits until the connection is ready, so the first call
+// doesn't pay for the dial and HTTP/2 handshake
+func (t *grpcAgentTransport) Connect(ctx context.Context) error {
+	t.conn.Connect()
+	for {
+		state := t.conn.GetState()
+		if state == connectivity.Ready {
+			return nil
+		}
+		if !t.conn.WaitForStateChange(ctx, state) {
+			return fmt.Errorf("agent gRPC connection is %s: %w", state, ctx.Err())
+		}
+	}
+}
+
+// Call performs a unary call
+func (t *grpcAgentTransport) Call(ctx context.Context, method string, req, resp interface{}) error {
+	ctx, cancel, err := withAgentDeadline(ctx, method, UpstreamAgent)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	if err := agentUnavailable(method); err != nil {
+		return err
+	}
+	in, err := toAgentStruct(method, req)
+	if err != nil {
+		return err
+	}
+
+	out := new(structpb.Struct)
+	if err := t.conn.Invoke(ctx, agentServicePath+method, in, out); err != nil {
+		return grpcAgentError(ctx, method, err)
+	}
+	recordAgentHealth(nil)
+
+	data, err := protojson.Marshal(out)
+	if err != nil {
+		return &AgentError{Code: AgentErrInternal, Message: fmt.Sprintf("failed to encode result: %v", err), Method: method}
+	}
+	if err := json.Unmarshal(data, resp); err != nil {
+		return &AgentError{Code: AgentErrInternal, Message: fmt.Sprintf("failed to decode result: %v", err), Method: method}
+	}
+	return nil
+}
+
+// Stream performs a server-streaming call, ending after a done chunk or when the agent
+// closes the stream
+func (t *grpcAgentTransport) Stream(ctx context.Context, method string, req interface{}, onChunk func(AgentStreamChunk) error) error {
+	ctx, cancel, err := withAgentDeadline(ctx, method, UpstreamAgentStream)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	if err := agentUnavailable(method); err != nil {
+		return err
+	}
+	in, err := toAgentStruct(method, req)
+	if err != nil {
+		return err
+	}
+
+	stream, err := t.conn.NewStream(ctx, &grpc.StreamDesc{StreamName: method, ServerStreams: true}, agentServicePath+method)
+	if err != nil {
+		return grpcAgentError(ctx, method, err)
+	}
+	if err := stream.SendMsg(in); err != nil && !errors.Is(err, io.EOF) {
+		return grpcAgentError(ctx, method, err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		return grpcAgentError(ctx, method, err)
+	}
+
+	for received := false; ; received = true {
+		out := new(structpb.Struct)
+		if err := stream.RecvMsg(out); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return grpcAgentError(ctx, method, err)
+		}
+		if !received {
+			recordAgentHealth(nil)
+		}
+
+		fields := out.AsMap()
+		data, err := json.Marshal(fields)
+		if err != nil {
+			return &AgentError{Code: AgentErrInternal, Message: fmt.Sprintf("failed to encode chunk: %v", err), Method: method}
+		}
+		chunk := AgentStreamChunk{Data: data, Fields: fields}
+		chunk.Type, _ = fields["type"].(string)
+
+		if err := onChunk(chunk); err != nil {
+			return err
+		}
+		if chunk.Type == "done" {
+			return nil
+		}
+	}
+}
+
+// toAgentStruct converts a request into the Struct the agent service takes
+func toAgentStruct(method string, req interface{}) (*structpb.Struct, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, &AgentError{Code: AgentErrInvalidArgument, Message: fmt.Sprintf("failed to marshal request: %v", err), Method: method}
+	}
+	in := new(structpb.Struct)
+	if err := protojson.Unmarshal(data, in); err != nil {
+		return nil, &AgentError{Code: AgentErrInvalidArgument, Message: fmt.Sprintf("request is not an object: %v", err), Method: method}
+	}
+	return in, nil
+}
+
+// grpcAgentError converts a gRPC status into a typed agent error. Only an unreachable
+// agent counts against its health; any other status means it answered.
+func grpcAgentError(ctx context.Context, method string, err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return agentTransportError(ctx, method, err)
+	}
+
+	code := AgentErrInternal
+	switch st.Code() {
+	case codes.Unavailable:
+		return agentTransportError(ctx, method, err)
+	case codes.Canceled, codes.DeadlineExceeded:
+		return transportError(ctx, method, err)
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		code = AgentErrInvalidArgument
+	case codes.NotFound:
+		code = AgentErrNotFound
+	case codes.Unimplemented:
+		code = AgentErrUnimplemented
+	case codes.ResourceExhausted, codes.Aborted:
+		code = AgentErrUnavailable
+	}
+	recordAgentHealth(nil)
+	return &AgentError{Code: code, Message: st.Message(), Method: method}
+}
@@ -0,0 +1,327 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// fakeAgentServer serves the Agent service in-process, answering every method through
+// handle: unary methods with its single result, ChatStream with each message in turn
+type fakeAgentServer struct {
+	handle func(method string, req *structpb.Struct) ([]map[string]interface{}, error)
+}
+
+func (f *fakeAgentServer) serve(srv interface{}, stream grpc.ServerStream) error {
+	fullMethod, _ := grpc.MethodFromServerStream(stream)
+	if !strings.HasPrefix(fullMethod, agentServicePath) {
+		return status.Errorf(codes.Unimplemented, "unknown service for %s", fullMethod)
+	}
+	if _, ok := stream.Context().Deadline(); !ok {
+		return status.Error(codes.InvalidArgument, "call has no deadline")
+	}
+
+	req := new(structpb.Struct)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	results, err := f.handle(strings.TrimPrefix(fullMethod, agentServicePath), req)
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		msg, err := structpb.NewStruct(result)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		if err := stream.SendMsg(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startFakeAgent serves handle on a local port, returning the address to dial
+func startFakeAgent(t *testing.T, handle func(method string, req *structpb.Struct) ([]map[string]interface{}, error)) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	server := grpc.NewServer(grpc.UnknownServiceHandler((&fakeAgentServer{handle: handle}).serve))
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	return listener.Addr().String()
+}
+
+func newTestGRPCTransport(t *testing.T, addr string) *grpcAgentTransport {
+	t.Helper()
+	transport, err := newGRPCAgentTransport(addr)
+	if err != nil {
+		t.Fatalf("newGRPCAgentTransport: %v", err)
+	}
+	t.Cleanup(func() { transport.conn.Close() })
+	return transport
+}
+
+func TestGRPCAgentTransportCall(t *testing.T) {
+	resetAgentHealth(t)
+	addr := startFakeAgent(t, func(method string, req *structpb.Struct) ([]map[string]interface{}, error) {
+		if method != AgentMethodChat {
+			return nil, status.Errorf(codes.Unimplemented, "unknown method %s", method)
+		}
+		message := req.GetFields()["message"].GetStringValue()
+		if message == "" {
+			return nil, status.Error(codes.InvalidArgument, "message is required")
+		}
+		return []map[string]interface{}{{"response": "echo: " + message, "confidence": 0.9}}, nil
+	})
+	transport := newTestGRPCTransport(t, addr)
+
+	var resp struct {
+		Response   string  `json:"response"`
+		Confidence float64 `json:"confidence"`
+	}
+	if err := transport.Call(context.Background(), AgentMethodChat, map[string]interface{}{"message": "hi", "session_id": "s1"}, &resp); err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	if resp.Response != "echo: hi" || resp.Confidence != 0.9 {
+		t.Errorf("Call decoded %+v", resp)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		req    map[string]interface{}
+		want   AgentErrorCode
+	}{
+		{"invalid argument", AgentMethodChat, map[string]interface{}{"session_id": "s1"}, AgentErrInvalidArgument},
+		{"unimplemented", AgentMethodParseBookings, map[string]interface{}{"text": "flight"}, AgentErrUnimplemented},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := transport.Call(context.Background(), tt.method, tt.req, &resp)
+			if code := AgentErrorCodeOf(err); code != tt.want {
+				t.Errorf("Call error = %v (code %q), want code %q", err, code, tt.want)
+			}
+		})
+	}
+	if health := GetAgentHealth(); !health.Healthy {
+		t.Errorf("agent answering with an error status counted as unhealthy: %+v", health)
+	}
+}
+
+func TestGRPCAgentTransportConnect(t *testing.T) {
+	addr := startFakeAgent(t, func(method string, req *structpb.Struct) ([]map[string]interface{}, error) {
+		return nil, status.Errorf(codes.Unimplemented, "unknown method %s", method)
+	})
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	unreachable := closed.Addr().String()
+	closed.Close()
+
+	tests := []struct {
+		name    string
+		addr    string
+		wantErr bool
+	}{
+		{"agent listening", addr, false},
+		{"agent unreachable", unreachable, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := newTestGRPCTransport(t, tt.addr)
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+
+			err := (&fallbackAgentTransport{primary: transport, fallback: newHTTPAgentTransport("http://" + tt.addr)}).Connect(ctx)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Connect error = %v, want error %v", err, tt.wantErr)
+			}
+			if state := transport.conn.GetState(); !tt.wantErr && state != connectivity.Ready {
+				t.Errorf("connection %s after Connect, want READY", state)
+			}
+		})
+	}
+}
+
+func TestGRPCAgentTransportStream(t *testing.T) {
+	resetAgentHealth(t)
+	addr := startFakeAgent(t, func(method string, req *structpb.Struct) ([]map[string]interface{}, error) {
+		if method != AgentMethodChatStream {
+			return nil, status.Errorf(codes.Unimplemented, "unknown method %s", method)
+		}
+		return []map[string]interface{}{
+			{"type": "token", "content": "Hello "},
+			{"type": "token", "content": "Banff"},
+			{"type": "done", "suggestions": []interface{}{"Plan a hike"}},
+			{"type": "token", "content": "after done"},
+		}, nil
+	})
+	transport := newTestGRPCTransport(t, addr)
+
+	var types, content []string
+	err := transport.Stream(context.Background(), AgentMethodChatStream, map[string]interface{}{"message": "hi"}, func(chunk AgentStreamChunk) error {
+		types = append(types, chunk.Type)
+		if text, ok := chunk.Fields["content"].(string); ok {
+			content = append(content, text)
+		}
+		var decoded map[string]interface{}
+		if err := json.Unmarshal(chunk.Data, &decoded); err != nil || decoded["type"] != chunk.Type {
+			t.Errorf("chunk data %s doesn't match its fields", chunk.Data)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	if got := strings.Join(types, ","); got != "token,token,done" {
+		t.Errorf("chunk types = %s, want token,token,done", got)
+	}
+	if got := strings.Join(content, ""); got != "Hello Banff" {
+		t.Errorf("streamed content = %q", got)
+	}
+}
+
+func TestFallbackAgentTransport(t *testing.T) {
+	legacy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != legacyAgentEndpoints[AgentMethodChat] {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, map[string]interface{}{"response": "legacy"})
+	}))
+	t.Cleanup(legacy.Close)
+
+	// A port nothing listens on, as for an agent that predates the gRPC service
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	unreachable := closed.Addr().String()
+	closed.Close()
+
+	unimplemented := startFakeAgent(t, func(method string, req *structpb.Struct) ([]map[string]interface{}, error) {
+		return nil, status.Errorf(codes.Unimplemented, "unknown method %s", method)
+	})
+	failing := startFakeAgent(t, func(method string, req *structpb.Struct) ([]map[string]interface{}, error) {
+		return nil, status.Error(codes.Internal, "graph failed")
+	})
+
+	tests := []struct {
+		name           string
+		addr           string
+		wantResponse   string
+		wantCode       AgentErrorCode
+		wantLegacyOnly bool
+	}{
+		{"gRPC unreachable", unreachable, "legacy", "", false},
+		{"method unimplemented", unimplemented, "legacy", "", true},
+		{"agent error", failing, "", AgentErrInternal, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetAgentHealth(t)
+			transport := &fallbackAgentTransport{
+				primary:  newTestGRPCTransport(t, tt.addr),
+				fallback: newHTTPAgentTransport(legacy.URL),
+			}
+
+			var resp struct {
+				Response string `json:"response"`
+			}
+			err := transport.Call(context.Background(), AgentMethodChat, map[string]interface{}{"message": "hi"}, &resp)
+			if code := AgentErrorCodeOf(err); code != tt.wantCode {
+				t.Fatalf("Call error = %v, want code %q", err, tt.wantCode)
+			}
+			if resp.Response != tt.wantResponse {
+				t.Errorf("response = %q, want %q", resp.Response, tt.wantResponse)
+			}
+			if got := transport.isLegacyOnly(AgentMethodChat); got != tt.wantLegacyOnly {
+				t.Errorf("legacy only = %v, want %v", got, tt.wantLegacyOnly)
+			}
+		})
+	}
+}
+
+func TestFallbackAgentTransportPerMethod(t *testing.T) {
+	resetAgentHealth(t)
+	legacy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{"response": "legacy"})
+	}))
+	t.Cleanup(legacy.Close)
+
+	// An agent that serves the contract except for Chat
+	addr := startFakeAgent(t, func(method string, req *structpb.Struct) ([]map[string]interface{}, error) {
+		if method == AgentMethodChat {
+			return nil, status.Errorf(codes.Unimplemented, "unknown method %s", method)
+		}
+		return []map[string]interface{}{{"response": "grpc"}}, nil
+	})
+	transport := &fallbackAgentTransport{
+		primary:  newTestGRPCTransport(t, addr),
+		fallback: newHTTPAgentTransport(legacy.URL),
+	}
+
+	calls := []struct {
+		method string
+		want   string
+	}{
+		{AgentMethodChat, "legacy"},
+		{AgentMethodExploreDestination, "grpc"},
+		{AgentMethodChat, "legacy"},
+		{AgentMethodGeneratePackingList, "grpc"},
+	}
+	for _, call := range calls {
+		var resp struct {
+			Response string `json:"response"`
+		}
+		if err := transport.Call(context.Background(), call.method, map[string]interface{}{"message": "hi"}, &resp); err != nil {
+			t.Fatalf("Call(%s): %v", call.method, err)
+		}
+		if resp.Response != call.want {
+			t.Errorf("Call(%s) answered by %q, want %q", call.method, resp.Response, call.want)
+		}
+	}
+	if transport.isLegacyOnly(AgentMethodExploreDestination) {
+		t.Error("one unimplemented method sent the others to the legacy routes")
+	}
+	if transport.Name() != "grpc/"+AgentContractVersion {
+		t.Errorf("Name() = %q after one method fell back", transport.Name())
+	}
+}
+
+func TestAgentGRPCTarget(t *testing.T) {
+	tests := []struct {
+		name    string
+		addr    string
+		baseURL string
+		want    string
+	}{
+		{"agent host", "", LangGraphDockerBaseURL, "cantrip-agent:" + AgentGRPCPort},
+		{"localhost", "", LangGraphBaseURL, "localhost:" + AgentGRPCPort},
+		{"unparseable base URL", "", "://", "localhost:" + AgentGRPCPort},
+		{"override", "agent.internal:6000", LangGraphBaseURL, "agent.internal:6000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AGENT_GRPC_ADDR", tt.addr)
+			if got := agentGRPCTarget(tt.baseURL); got != tt.want {
+				t.Errorf("agentGRPCTarget(%q) = %q, want %q", tt.baseURL, got, tt.want)
+			}
+		})
+	}
+}
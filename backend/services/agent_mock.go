@@ -0,0 +1,567 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/joshndala/cantrip/models"
+	"github.com/joshndala/cantrip/utils"
+)
+
+// AIModeMock selects the in-process mock agent via AI_MODE
+const AIModeMock = "mock"
+
+// mockAgentTimestamp keeps mock responses deterministic
+const mockAgentTimestamp = "2024-01-01T00:00:00Z"
+
+// mockChatReply is a canned reply selected by keyword
+type mockChatReply struct {
+	Keywords    []string
+	Intent      string
+	Response    string
+	Suggestions []string
+}
+
+// mockChatReplies are matched in order; the last entry is the default
+var mockChatReplies = []mockChatReply{
+	{
+		Keywords:    []string{"itinerary", "plan", "schedule"},
+		Intent:      "plan_trip",
+		Response:    "I'd love to help you plan your trip! Tell me which city you're visiting, your travel dates and what you enjoy, and I'll put together a day-by-day itinerary.",
+		Suggestions: []string{"Plan 3 days in Toronto", "Create a relaxed weekend in Victoria", "What should I see in Montreal?"},
+	},
+	{
+		Keywords:    []string{"weather", "temperature", "rain", "snow"},
+		Intent:      "weather",
+		Response:    "Canadian weather varies a lot by season and region. Summers are warm across most cities, while winters can be very cold inland. Layers and a waterproof jacket are always a good idea.",
+		Suggestions: []string{"What's the weather like in Vancouver?", "Create a packing list for winter in Quebec City"},
+	},
+	{
+		Keywords:    []string{"pack", "bring", "luggage"},
+		Intent:      "packing",
+		Response:    "Packing depends on your destination, season and activities. Share your trip details and I'll build a packing list tailored to the weather and what you have planned.",
+		Suggestions: []string{"Create a packing list for Banff in winter", "What should I bring for hiking?"},
+	},
+	{
+		Keywords:    []string{"food", "eat", "restaurant", "dinner"},
+		Intent:      "food",
+		Response:    "Canada has a fantastic food scene, from poutine in Montreal to fresh seafood in Halifax and dim sum in Vancouver. Which city are you curious about?",
+		Suggestions: []string{"Best food in Montreal", "Where should I eat in Vancouver?"},
+	},
+	{
+		Intent:      "greeting",
+		Response:    fallbackChatResponse,
+		Suggestions: []string{"Tell me about popular Canadian destinations", "Help me plan a trip to Toronto", "What's the weather like in Vancouver?"},
+	},
+}
+
+// mockAgentTransport is a deterministic in-process implementation of the agent contract
+type mockAgentTransport struct{}
+
+func (t *mockAgentTransport) Name() string {
+	return "mock"
+}
+
+// Call answers unary calls from local data and fixtures
+func (t *mockAgentTransport) Call(ctx context.Context, method string, req, resp interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return &AgentError{Code: AgentErrDeadlineExceeded, Message: err.Error(), Method: method}
+	}
+
+	var result interface{}
+	var err error
+	switch method {
+	case AgentMethodGenerateItinerary:
+		var itineraryReq AIItineraryRequest
+		if err := remarshal(req, &itineraryReq); err != nil {
+			return &AgentError{Code: AgentErrInvalidArgument, Message: err.Error(), Method: method}
+		}
+		result, err = mockGenerateItinerary(ctx, itineraryReq)
+	case AgentMethodExploreDestination:
+		var exploreReq AIExploreRequest
+		if err := remarshal(req, &exploreReq); err != nil {
+			return &AgentError{Code: AgentErrInvalidArgument, Message: err.Error(), Method: method}
+		}
+		result, err = mockExploreDestination(ctx, exploreReq)
+	case AgentMethodChat:
+		var chatReq AIChatRequest
+		if err := remarshal(req, &chatReq); err != nil {
+			return &AgentError{Code: AgentErrInvalidArgument, Message: err.Error(), Method: method}
+		}
+		result = mockChat(ctx, chatReq)
+	case AgentMethodGeneratePackingList:
+		var packingReq AIPackingRequest
+		if err := remarshal(req, &packingReq); err != nil {
+			return &AgentError{Code: AgentErrInvalidArgument, Message: err.Error(), Method: method}
+		}
+		result, err = mockGeneratePackingList(ctx, packingReq)
+	case AgentMethodParseBookings:
+		var parseReq ParseBookingsRequest
+		if err := remarshal(req, &parseReq); err != nil {
+			return &AgentError{Code: AgentErrInvalidArgument, Message: err.Error(), Method: method}
+		}
+		result = mockParseBookings(parseReq)
+	case AgentMethodClassifyActivities:
+		var classifyReq ClassifyActivitiesRequest
+		if err := remarshal(req, &classifyReq); err != nil {
+			return &AgentError{Code: AgentErrInvalidArgument, Message: err.Error(), Method: method}
+		}
+		result = mockClassifyActivities(classifyReq)
+	case AgentMethodSummarizeConversation:
+		var summarizeReq SummarizeConversationRequest
+		if err := remarshal(req, &summarizeReq); err != nil {
+			return &AgentError{Code: AgentErrInvalidArgument, Message: err.Error(), Method: method}
+		}
+		result = SummarizeConversationResponse{Summary: localChatSummary(summarizeReq.Summary, summarizeReq.Messages)}
+	default:
+		return &AgentError{Code: AgentErrUnimplemented, Message: "method not supported by mock agent", Method: method}
+	}
+	if err != nil {
+		return &AgentError{Code: AgentErrInvalidArgument, Message: err.Error(), Method: method}
+	}
+
+	if err := remarshal(result, resp); err != nil {
+		return &AgentError{Code: AgentErrInternal, Message: err.Error(), Method: method}
+	}
+	return nil
+}
+
+// Stream replays the mock chat reply word by word
+func (t *mockAgentTransport) Stream(ctx context.Context, method string, req interface{}, onChunk func(AgentStreamChunk) error) error {
+	if method != AgentMethodChatStream {
+		return &AgentError{Code: AgentErrUnimplemented, Message: "method not supported by mock agent", Method: method}
+	}
+
+	var chatReq AIChatRequest
+	if err := remarshal(req, &chatReq); err != nil {
+		return &AgentError{Code: AgentErrInvalidArgument, Message: err.Error(), Method: method}
+	}
+	reply := mockChat(ctx, chatReq)
+
+	words := strings.Fields(reply.Response)
+	for i, word := range words {
+		if err := ctx.Err(); err != nil {
+			return &AgentError{Code: AgentErrDeadlineExceeded, Message: err.Error(), Method: method}
+		}
+
+		content := word
+		if i < len(words)-1 {
+			content += " "
+		}
+		if err := emitMockChunk(onChunk, map[string]interface{}{
+			"type":       "token",
+			"content":    content,
+			"session_id": reply.SessionID,
+			"intent":     reply.Intent,
+			"confidence": reply.Confidence,
+			"timestamp":  reply.Timestamp,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return emitMockChunk(onChunk, map[string]interface{}{
+		"type":        "done",
+		"suggestions": reply.Suggestions,
+		"data":        reply.Data,
+		"session_id":  reply.SessionID,
+	})
+}
+
+// emitMockChunk encodes a chunk and hands it to the stream callback
+func emitMockChunk(onChunk func(AgentStreamChunk) error, fields map[string]interface{}) error {
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	chunkType, _ := fields["type"].(string)
+	return onChunk(AgentStreamChunk{Type: chunkType, Data: data, Fields: fields})
+}
+
+// mockChat picks a canned reply based on the message keywords
+func mockChat(ctx context.Context, req AIChatRequest) ChatResponse {
+	reply := matchMockChatReply(strings.ToLower(req.Message))
+
+	data := map[string]interface{}{"mode": AIModeMock}
+	// Weather questions about a known city get seasonal conditions for a weather card
+	if reply.Intent == "weather" {
+		if city := mockMentionedCity(ctx, req.Message); city != "" {
+			weather := mockSeasonalWeather(ctx, city, time.Time{})
+			data["weather"] = map[string]interface{}{
+				"city":        city,
+				"temperature": weather.Temperature,
+				"condition":   weather.Condition,
+				"humidity":    weather.Humidity,
+				"wind_speed":  weather.WindSpeed,
+			}
+		}
+	}
+
+	return ChatResponse{
+		Response:    reply.Response,
+		SessionID:   req.SessionID,
+		Intent:      reply.Intent,
+		Confidence:  0.9,
+		Suggestions: reply.Suggestions,
+		Data:        data,
+		Timestamp:   mockAgentTimestamp,
+	}
+}
+
+// mockMentionedCity returns the first city from the metadata named in the message
+func mockMentionedCity(ctx context.Context, message string) string {
+	metadata, err := loadCityMetadata(ctx)
+	if err != nil {
+		return ""
+	}
+	words := activityWords(message)
+	for _, city := range metadata.Cities {
+		if strings.Contains(words, activityWords(city.Name)) {
+			return city.Name
+		}
+	}
+	return ""
+}
+
+// matchMockChatReply returns the first reply with a keyword found in the message
+func matchMockChatReply(message string) mockChatReply {
+	for _, reply := range mockChatReplies {
+		for _, keyword := range reply.Keywords {
+			if strings.Contains(message, keyword) {
+				return reply
+			}
+		}
+	}
+	return mockChatReplies[len(mockChatReplies)-1]
+}
+
+// mockGenerateItinerary builds a day-by-day itinerary from the city metadata
+func mockGenerateItinerary(ctx context.Context, req AIItineraryRequest) (*ItineraryResponse, error) {
+	start, err := time.Parse("2006-01-02", req.StartDate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start_date: %w", err)
+	}
+	span, err := tripSpan(req.StartDate, req.EndDate)
+	if err != nil {
+		return nil, err
+	}
+	duration := span.Days
+
+	places := mockCityPlaces(ctx, req.City)
+	if len(req.Recommended) > 0 {
+		// Recommended activities come first, without repeating them later
+		ordered := append([]string{}, req.Recommended...)
+		for _, place := range places {
+			if !utils.Contains(req.Recommended, place) {
+				ordered = append(ordered, place)
+			}
+		}
+		places = ordered
+	}
+
+	// Activities per day follow the requested pace
+	perDay := 3
+	switch req.Pace {
+	case "relaxed":
+		perDay = 2
+	case "intense":
+		perDay = 4
+	}
+	slots := []string{"09:00", "11:30", "14:00", "16:30", "19:00"}
+
+	groupSize := req.GroupSize
+	if groupSize <= 0 {
+		groupSize = 1
+	}
+	budget := req.Budget
+	if budget <= 0 {
+		budget = 1000
+	}
+	activityCost := math.Round(budget/float64(duration*(perDay+2))*100) / 100
+
+	days := make([]interface{}, 0, duration)
+	totalCost := 0.0
+	next := 0
+	for d := 0; d < duration; d++ {
+		activities := make([]interface{}, 0, perDay)
+		for a := 0; a < perDay; a++ {
+			place := places[next%len(places)]
+			next++
+			activities = append(activities, map[string]interface{}{
+				"name":       place,
+				"start_time": slots[a],
+				"end_time":   addMockHours(slots[a], 2),
+				"location":   fmt.Sprintf("%s, %s", place, req.City),
+				"cost":       activityCost,
+				"category":   "sightseeing",
+			})
+		}
+		dayCost := activityCost * float64(perDay+2)
+		totalCost += dayCost
+
+		days = append(days, map[string]interface{}{
+			"day":        d + 1,
+			"date":       start.AddDate(0, 0, d).Format("2006-01-02"),
+			"activities": activities,
+			"meals": []interface{}{
+				map[string]interface{}{"type": "lunch", "time": "12:30", "cost": activityCost},
+				map[string]interface{}{"type": "dinner", "time": "18:30", "cost": activityCost},
+			},
+			"transport":  "Public transit and walking",
+			"total_cost": math.Round(dayCost*100) / 100,
+			"notes":      fmt.Sprintf("Day %d in %s", d+1, req.City),
+		})
+	}
+	totalCost = math.Round(totalCost*100) / 100
+
+	resp := &ItineraryResponse{
+		Success: true,
+		Itinerary: map[string]interface{}{
+			"city":          req.City,
+			"start_date":    req.StartDate,
+			"end_date":      req.EndDate,
+			"duration":      duration,
+			"group_size":    groupSize,
+			"pace":          req.Pace,
+			"accommodation": req.Accommodation,
+			"total_cost":    totalCost,
+			"summary":       fmt.Sprintf("A %d-day %s trip to %s", duration, req.Pace, req.City),
+			"created_at":    mockAgentTimestamp,
+			"days":          days,
+		},
+	}
+	resp.Metadata.City = req.City
+	resp.Metadata.Duration = duration
+	resp.Metadata.TotalCost = totalCost
+	resp.Metadata.GeneratedAt = mockAgentTimestamp
+
+	return resp, nil
+}
+
+// mockExploreDestination builds suggestions and events from the local data
+func mockExploreDestination(ctx context.Context, req AIExploreRequest) (*AIExploreResponse, error) {
+	weather := mockSeasonalWeather(ctx, req.City, time.Now())
+
+	suggestions, err := GenerateTripSuggestions(ctx, req.Mood, req.City, req.Budget, req.Duration, req.Interests, weather, req.Eco)
+	if err != nil {
+		return nil, err
+	}
+	events, err := getEventsFromSampleData(ctx, req.City, req.Mood, req.Interests)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &AIExploreResponse{Success: true}
+	if err := remarshal(suggestions, &resp.Suggestions); err != nil {
+		return nil, err
+	}
+	if err := remarshal(events, &resp.Events); err != nil {
+		return nil, err
+	}
+	if err := remarshal(weather, &resp.Weather); err != nil {
+		return nil, err
+	}
+	resp.Metadata.City = req.City
+	resp.Metadata.Mood = req.Mood
+	resp.Metadata.GeneratedAt = mockAgentTimestamp
+
+	return resp, nil
+}
+
+// mockGeneratePackingList delegates to the rule-based packing generator
+func mockGeneratePackingList(ctx context.Context, req AIPackingRequest) (*AIPackingResponse, error) {
+	start, _ := models.ParseDate(req.StartDate)
+	end, _ := models.ParseDate(req.EndDate)
+	weather := mockSeasonalWeather(ctx, req.Destination, start.Time)
+
+	packingList, err := GeneratePackingList(ctx, PackingRequest{
+		Destination:  req.Destination,
+		StartDate:    start,
+		EndDate:      end,
+		Activities:   req.Activities,
+		Weather:      req.Weather,
+		GroupSize:    req.GroupSize,
+		AgeGroup:     req.AgeGroup,
+		SpecialNeeds: req.SpecialNeeds,
+	}, weather)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &AIPackingResponse{Success: true}
+	if err := remarshal(packingList, &resp.PackingList); err != nil {
+		return nil, err
+	}
+	if err := remarshal(weather, &resp.Weather); err != nil {
+		return nil, err
+	}
+	resp.Metadata.Destination = req.Destination
+	resp.Metadata.TotalItems = packingList.TotalItems
+	resp.Metadata.GeneratedAt = mockAgentTimestamp
+
+	return resp, nil
+}
+
+// mockCityPlaces returns the attractions and neighborhoods of a city, or generic places
+func mockCityPlaces(ctx context.Context, city string) []string {
+	if metadata, err := loadCityMetadata(ctx); err == nil {
+		if cityData, err := findCity(metadata, city); err == nil {
+			places := append([]string{}, cityData.Attractions...)
+			for _, neighborhood := range cityData.Neighborhoods {
+				places = append(places, fmt.Sprintf("Explore %s", neighborhood.Name))
+			}
+			if len(places) > 0 {
+				return places
+			}
+		}
+	}
+
+	return []string{
+		fmt.Sprintf("%s Old Town walking tour", city),
+		fmt.Sprintf("%s city museum", city),
+		fmt.Sprintf("%s waterfront", city),
+		fmt.Sprintf("%s local market", city),
+	}
+}
+
+// mockSeasonalWeather returns the seasonal average weather without random variation
+func mockSeasonalWeather(ctx context.Context, city string, date time.Time) WeatherInfo {
+	if date.IsZero() {
+		date = time.Now()
+	}
+	seasonName := getSeasonForDate(date)
+
+	temperature := 15.0
+	if metadata, err := loadCityMetadata(ctx); err == nil {
+		if cityData, err := findCity(metadata, city); err == nil {
+			if season, ok := cityData.Seasons[seasonName]; ok {
+				temperature = season.AvgTemp
+			}
+		}
+	}
+
+	return WeatherInfo{
+		Temperature: temperature,
+		FeelsLike:   FeelsLike(temperature, 60, 12),
+		Condition:   getWeatherCondition(seasonName, temperature),
+		Humidity:    60,
+		WindSpeed:   12,
+	}
+}
+
+// Patterns the mock agent uses to read booking confirmations
+var (
+	mockDateTimeExpr  = regexp.MustCompile(`(\d{4}-\d{2}-\d{2})[T ](\d{1,2}:\d{2})`)
+	mockDateExpr      = regexp.MustCompile(`\d{4}-\d{2}-\d{2}`)
+	mockHotelExpr     = regexp.MustCompile(`(?im)^\s*(?:hotel|property|stay at)\s*:?\s*(.+)$`)
+	mockParagraphExpr = regexp.MustCompile(`\n\s*\n`)
+)
+
+// mockParseBookings extracts flights and hotels from confirmation text with ISO dates.
+// Each paragraph is treated as one booking.
+func mockParseBookings(req ParseBookingsRequest) ParseBookingsResponse {
+	location, err := time.LoadLocation(req.Timezone)
+	if err != nil {
+		location = time.UTC
+	}
+
+	bookings := []Booking{}
+	for _, block := range mockParagraphExpr.Split(req.Text, -1) {
+		lower := strings.ToLower(block)
+		booking := Booking{}
+		if match := confirmationExpr.FindStringSubmatch(block); match != nil {
+			booking.Confirmation = match[1]
+		}
+
+		var times []time.Time
+		for _, match := range mockDateTimeExpr.FindAllStringSubmatch(block, -1) {
+			if t, err := time.ParseInLocation("2006-01-02 15:04", match[1]+" "+match[2], location); err == nil {
+				times = append(times, t)
+			}
+		}
+
+		switch {
+		case containsAny(lower, flightKeywords) && (len(times) > 0):
+			booking.Type = BookingFlight
+			if match := flightNumberExpr.FindStringSubmatch(block); match != nil {
+				booking.FlightNumber = match[1] + " " + match[2]
+			}
+			if match := airportPairExpr.FindStringSubmatch(block); match != nil {
+				booking.Origin, booking.Destination = match[1], match[2]
+			}
+			booking.Title = strings.TrimSpace("Flight " + booking.FlightNumber)
+			booking.Start, booking.End = times[0], times[0].Add(2*time.Hour)
+			if len(times) > 1 {
+				booking.End = times[1]
+			}
+		case containsAny(lower, hotelKeywords):
+			booking.Type = BookingHotel
+			booking.Title = "Hotel"
+			if match := mockHotelExpr.FindStringSubmatch(block); match != nil {
+				booking.Title = strings.TrimSpace(match[1])
+			}
+			if len(times) > 0 {
+				booking.Start = times[0]
+				if len(times) > 1 {
+					booking.End = times[1]
+				}
+			} else if dates := mockDateExpr.FindAllString(block, 2); len(dates) > 0 {
+				checkIn, _ := time.ParseInLocation("2006-01-02", dates[0], location)
+				booking.Start = atClock(checkIn, defaultCheckIn, location)
+				if len(dates) > 1 {
+					checkOut, _ := time.ParseInLocation("2006-01-02", dates[1], location)
+					booking.End = atClock(checkOut, defaultCheckOut, location)
+				}
+			}
+			if booking.Start.IsZero() {
+				continue
+			}
+			if booking.End.IsZero() {
+				booking.End = booking.Start
+			}
+		default:
+			continue
+		}
+		bookings = append(bookings, booking)
+	}
+	return ParseBookingsResponse{Bookings: bookings}
+}
+
+// addMockHours adds hours to an HH:MM time
+func addMockHours(clock string, hours int) string {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return clock
+	}
+	return t.Add(time.Duration(hours) * time.Hour).Format("15:04")
+}
+
+// remarshal converts between types through their JSON representation
+func remarshal(from, to interface{}) error {
+	data, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, to)
+}
+
+// mockClassifyActivities files every description under general sightseeing with low
+// confidence, when the caller allows that category
+func mockClassifyActivities(req ClassifyActivitiesRequest) ClassifyActivitiesResponse {
+	resp := ClassifyActivitiesResponse{Matches: make([]ActivityMatch, len(req.Descriptions))}
+	for i, description := range req.Descriptions {
+		resp.Matches[i] = ActivityMatch{Input: description, Activities: []string{}}
+		for _, category := range req.Categories {
+			if category == "city_exploration" {
+				resp.Matches[i].Activities = []string{category}
+				resp.Matches[i].Confidence = 0.3
+			}
+		}
+	}
+	return resp
+}
@@ -0,0 +1,416 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/joshndala/cantrip/utils"
+)
+
+// AgentContractVersion is the protobuf package of the agent service in proto/cantrip/agent/v1
+const AgentContractVersion = "cantrip.agent.v1"
+
+// Agent methods defined by the contract
+const (
+	AgentMethodGenerateItinerary   = "GenerateItinerary"
+	AgentMethodExploreDestination  = "ExploreDestination"
+	AgentMethodChat                = "Chat"
+	AgentMethodChatStream          = "ChatStream"
+	AgentMethodGeneratePackingList = "GeneratePackingList"
+	AgentMethodParseBookings       = "ParseBookings"
+	AgentMethodClassifyActivities  = "ClassifyActivities"
+
+	AgentMethodSummarizeConversation = "SummarizeConversation"
+)
+
+// legacyAgentEndpoints maps contract methods to the original agent routes
+var legacyAgentEndpoints = map[string]string{
+	AgentMethodGenerateItinerary:   "/generate-itinerary",
+	AgentMethodExploreDestination:  "/explore-destination",
+	AgentMethodChat:                "/chat",
+	AgentMethodChatStream:          "/chat/stream",
+	AgentMethodGeneratePackingList: "/generate-packing-list",
+	AgentMethodParseBookings:       "/parse-bookings",
+	AgentMethodClassifyActivities:  "/classify-activities",
+
+	AgentMethodSummarizeConversation: "/summarize-conversation",
+}
+
+// AgentErrorCode classifies agent failures
+type AgentErrorCode string
+
+// Agent error codes
+const (
+	AgentErrInvalidArgument  AgentErrorCode = "invalid_argument"
+	AgentErrNotFound         AgentErrorCode = "not_found"
+	AgentErrDeadlineExceeded AgentErrorCode = "deadline_exceeded"
+	AgentErrUnavailable      AgentErrorCode = "unavailable"
+	AgentErrUnimplemented    AgentErrorCode = "unimplemented"
+	AgentErrInternal         AgentErrorCode = "internal"
+)
+
+// AgentError is a typed error returned by agent transports
+type AgentError struct {
+	Code    AgentErrorCode `json:"code"`
+	Message string         `json:"message"`
+	Method  string         `json:"-"`
+}
+
+func (e *AgentError) Error() string {
+	return fmt.Sprintf("agent %s failed (%s): %s", e.Method, e.Code, e.Message)
+}
+
+// Temporary reports whether retrying the call may succeed
+func (e *AgentError) Temporary() bool {
+	return e.Code == AgentErrUnavailable || e.Code == AgentErrDeadlineExceeded
+}
+
+// AgentErrorCodeOf returns the agent error code of err, or "" if err is not an AgentError
+func AgentErrorCodeOf(err error) AgentErrorCode {
+	var agentErr *AgentError
+	if errors.As(err, &agentErr) {
+		return agentErr.Code
+	}
+	return ""
+}
+
+// AgentStreamChunk is a single event received from a streaming call
+type AgentStreamChunk struct {
+	Type   string                 // token, done, error
+	Data   []byte                 // raw JSON payload
+	Fields map[string]interface{} // decoded payload
+}
+
+// AgentTransport carries contract calls to the LangGraph agent
+type AgentTransport interface {
+	// Call performs a unary call and decodes the result into resp
+	Call(ctx context.Context, method string, req, resp interface{}) error
+	// Stream performs a server-streaming call, invoking onChunk for every event
+	Stream(ctx context.Context, method string, req interface{}, onChunk func(AgentStreamChunk) error) error
+	// Name identifies the transport for logging
+	Name() string
+}
+
+// httpAgentTransport speaks the agent's original JSON routes over HTTP
+type httpAgentTransport struct {
+	baseURL      string
+	httpClient   *http.Client
+	streamClient *http.Client
+}
+
+// newHTTPAgentTransport creates an HTTP transport for the agent
+func newHTTPAgentTransport(baseURL string) *httpAgentTransport {
+	return &httpAgentTransport{
+		baseURL:      baseURL,
+		httpClient:   &http.Client{Transport: tracedTransport(sharedAgentTransport())},             // Traced, so the agent continues the trace from traceparent
+		streamClient: &http.Client{Timeout: 0, Transport: tracedTransport(sharedAgentTransport())}, // Streams are bounded by their deadline budget only
+	}
+}
+
+func (t *httpAgentTransport) Name() string {
+	return "http/legacy"
+}
+
+// endpoint resolves the URL path for a method
+func (t *httpAgentTransport) endpoint(method string) (string, error) {
+	path, ok := legacyAgentEndpoints[method]
+	if !ok {
+		return "", &AgentError{Code: AgentErrUnimplemented, Message: "no legacy endpoint", Method: method}
+	}
+	return path, nil
+}
+
+// newRequest builds the JSON request for a method
+func (t *httpAgentTransport) newRequest(ctx context.Context, method string, stream bool, req interface{}) (*http.Request, error) {
+	path, err := t.endpoint(method)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, &AgentError{Code: AgentErrInvalidArgument, Message: fmt.Sprintf("failed to marshal request: %v", err), Method: method}
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, t.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, &AgentError{Code: AgentErrInternal, Message: fmt.Sprintf("failed to create request: %v", err), Method: method}
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	if stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+		httpReq.Header.Set("Cache-Control", "no-cache")
+	}
+
+	return httpReq, nil
+}
+
+// Call performs a unary call
+func (t *httpAgentTransport) Call(ctx context.Context, method string, req, resp interface{}) error {
+	ctx, cancel, err := withAgentDeadline(ctx, method, UpstreamAgent)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	if err := agentUnavailable(method); err != nil {
+		return err
+	}
+	httpReq, err := t.newRequest(ctx, method, false, req)
+	if err != nil {
+		return err
+	}
+
+	httpResp, err := t.httpClient.Do(httpReq)
+	if err != nil {
+		return agentTransportError(ctx, method, err)
+	}
+	defer httpResp.Body.Close()
+	recordAgentHealth(nil)
+
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return transportError(ctx, method, err)
+	}
+
+	if httpResp.StatusCode != http.StatusOK {
+		return statusError(method, httpResp.StatusCode, data)
+	}
+
+	if err := json.Unmarshal(data, resp); err != nil {
+		return &AgentError{Code: AgentErrInternal, Message: fmt.Sprintf("failed to decode response: %v", err), Method: method}
+	}
+	return nil
+}
+
+// Stream performs a server-streaming call over server-sent events
+func (t *httpAgentTransport) Stream(ctx context.Context, method string, req interface{}, onChunk func(AgentStreamChunk) error) error {
+	ctx, cancel, err := withAgentDeadline(ctx, method, UpstreamAgentStream)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	if err := agentUnavailable(method); err != nil {
+		return err
+	}
+	httpReq, err := t.newRequest(ctx, method, true, req)
+	if err != nil {
+		return err
+	}
+
+	httpResp, err := t.streamClient.Do(httpReq)
+	if err != nil {
+		return agentTransportError(ctx, method, err)
+	}
+	defer httpResp.Body.Close()
+	recordAgentHealth(nil)
+
+	if httpResp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(httpResp.Body)
+		return statusError(method, httpResp.StatusCode, data)
+	}
+
+	scanner := bufio.NewScanner(httpResp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		data := strings.TrimPrefix(line, "data: ")
+		if data == "" {
+			continue
+		}
+
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(data), &fields); err != nil {
+			continue
+		}
+
+		chunk := AgentStreamChunk{Data: []byte(data), Fields: fields}
+		chunk.Type, _ = fields["type"].(string)
+
+		if err := onChunk(chunk); err != nil {
+			return err
+		}
+		if chunk.Type == "done" {
+			return nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return transportError(ctx, method, err)
+	}
+
+	return nil
+}
+
+// fallbackAgentTransport prefers the gRPC contract and falls back to the legacy HTTP
+// routes for a call the primary can't serve: the agent answers Unimplemented, or gRPC is
+// unreachable. Only Unimplemented is remembered, per method, so an agent that lacks one
+// method or drops gRPC briefly keeps the contract for everything else.
+type fallbackAgentTransport struct {
+	primary    AgentTransport
+	fallback   AgentTransport
+	legacyOnly sync.Map // method -> struct{}, for methods the agent doesn't serve over gRPC
+}
+
+func (t *fallbackAgentTransport) Name() string {
+	return t.primary.Name()
+}
+
+func (t *fallbackAgentTransport) Call(ctx context.Context, method string, req, resp interface{}) error {
+	if t.isLegacyOnly(method) {
+		return t.fallback.Call(ctx, method, req, resp)
+	}
+	err := t.primary.Call(ctx, method, req, resp)
+	if !t.shouldFallBack(err) {
+		return err
+	}
+	fallbackErr := t.fallback.Call(ctx, method, req, resp)
+	t.settle(method, err, fallbackErr)
+	return fallbackErr
+}
+
+func (t *fallbackAgentTransport) Stream(ctx context.Context, method string, req interface{}, onChunk func(AgentStreamChunk) error) error {
+	if t.isLegacyOnly(method) {
+		return t.fallback.Stream(ctx, method, req, onChunk)
+	}
+	// A stream that already delivered chunks can't be replayed on the other transport
+	delivered := false
+	err := t.primary.Stream(ctx, method, req, func(chunk AgentStreamChunk) error {
+		delivered = true
+		return onChunk(chunk)
+	})
+	if delivered || !t.shouldFallBack(err) {
+		return err
+	}
+	fallbackErr := t.fallback.Stream(ctx, method, req, onChunk)
+	t.settle(method, err, fallbackErr)
+	return fallbackErr
+}
+
+// Connect opens the primary's connection ahead of the first call
+func (t *fallbackAgentTransport) Connect(ctx context.Context) error {
+	if connector, ok := t.primary.(agentConnector); ok {
+		return connector.Connect(ctx)
+	}
+	return nil
+}
+
+func (t *fallbackAgentTransport) isLegacyOnly(method string) bool {
+	_, ok := t.legacyOnly.Load(method)
+	return ok
+}
+
+// shouldFallBack reports whether a primary failure may mean the agent lacks the contract
+func (t *fallbackAgentTransport) shouldFallBack(err error) bool {
+	code := AgentErrorCodeOf(err)
+	return code == AgentErrUnimplemented || code == AgentErrUnavailable
+}
+
+// settle sends further calls of method to the fallback transport once the agent answered
+// that it doesn't implement it over gRPC but the legacy route does. An unreachable gRPC
+// port is retried on the next call.
+func (t *fallbackAgentTransport) settle(method string, primaryErr, fallbackErr error) {
+	if AgentErrorCodeOf(primaryErr) != AgentErrUnimplemented || AgentErrorCodeOf(fallbackErr) == AgentErrUnimplemented {
+		return
+	}
+	if _, loaded := t.legacyOnly.LoadOrStore(method, struct{}{}); !loaded {
+		utils.LogWarning(fmt.Sprintf("Agent does not serve %s over %s, falling back to %s: %v", method, AgentContractVersion, t.fallback.Name(), primaryErr))
+	}
+}
+
+// NewAgentTransport selects a transport based on AI_MODE and AGENT_TRANSPORT (grpc, legacy
+// or auto). gRPC dials AGENT_GRPC_ADDR, or the agent's host on AgentGRPCPort.
+func NewAgentTransport(baseURL string) AgentTransport {
+	// The mock agent runs in-process and needs no Python service
+	if os.Getenv("AI_MODE") == AIModeMock {
+		return &mockAgentTransport{}
+	}
+
+	mode := os.Getenv("AGENT_TRANSPORT")
+	if mode == "legacy" {
+		return newHTTPAgentTransport(baseURL)
+	}
+	grpcTransport, err := newGRPCAgentTransport(agentGRPCTarget(baseURL))
+	if err != nil {
+		utils.LogWarning(fmt.Sprintf("Agent gRPC transport unavailable, using the legacy routes: %v", err))
+		return newHTTPAgentTransport(baseURL)
+	}
+	switch mode {
+	case "grpc", "v1":
+		return grpcTransport
+	default:
+		return &fallbackAgentTransport{
+			primary:  grpcTransport,
+			fallback: newHTTPAgentTransport(baseURL),
+		}
+	}
+}
+
+// withAgentDeadline bounds a call by the agent's configured timeout and the caller's
+// remaining budget; gRPC carries the result to the agent as the call's deadline
+func withAgentDeadline(ctx context.Context, method, upstream string) (context.Context, context.CancelFunc, error) {
+	ctx, cancel, err := WithUpstreamBudget(ctx, upstream)
+	if err != nil {
+		return ctx, cancel, &AgentError{Code: AgentErrDeadlineExceeded, Message: err.Error(), Method: method}
+	}
+	return ctx, cancel, nil
+}
+
+// transportError converts a network failure into a typed agent error
+func transportError(ctx context.Context, method string, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) || errors.Is(err, context.DeadlineExceeded) {
+		return &AgentError{Code: AgentErrDeadlineExceeded, Message: err.Error(), Method: method}
+	}
+	return &AgentError{Code: AgentErrUnavailable, Message: err.Error(), Method: method}
+}
+
+// agentTransportError converts a failure to reach the agent and, unless the caller gave
+// up, counts it against the agent's health
+func agentTransportError(ctx context.Context, method string, err error) error {
+	if ctx.Err() == nil {
+		recordAgentHealth(err)
+	}
+	return transportError(ctx, method, err)
+}
+
+// statusError converts a non-200 response into a typed agent error
+func statusError(method string, status int, body []byte) error {
+	message := strings.TrimSpace(string(body))
+	var legacy struct {
+		Detail interface{} `json:"detail"`
+	}
+	if err := json.Unmarshal(body, &legacy); err == nil && legacy.Detail != nil {
+		message = fmt.Sprint(legacy.Detail)
+	}
+	if message == "" {
+		message = http.StatusText(status)
+	}
+
+	code := AgentErrInternal
+	switch status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		code = AgentErrInvalidArgument
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		code = AgentErrUnimplemented
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		code = AgentErrDeadlineExceeded
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusTooManyRequests:
+		code = AgentErrUnavailable
+	}
+
+	return &AgentError{Code: code, Message: message, Method: method}
+}
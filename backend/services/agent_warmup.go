@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/joshndala/cantrip/utils"
+)
+
+// Agent connection pooling and warm-up
+const (
+	AgentMaxIdleConns       = 32               // idle keep-alive connections kept open to the agent
+	AgentPrewarmConnections = 4                // connections opened before the first user request
+	AgentHealthInterval     = 30 * time.Second // between probes while healthy, inside the idle timeout
+	agentIdleConnTimeout    = 90 * time.Second
+	agentProbeTimeout       = 5 * time.Second
+	agentBackoffMin         = time.Second
+	agentBackoffMax         = time.Minute
+	// agentFailFastAfter consecutive failures, calls fail at once until the next probe is due
+	agentFailFastAfter = 3
+)
+
+// AgentHealthStatus is what the backend last saw of the LangGraph agent
+type AgentHealthStatus struct {
+	Healthy             bool      `json:"healthy"`
+	Warm                bool      `json:"warm"` // the connection pool has been prewarmed
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastError           string    `json:"last_error,omitempty"`
+	LastChecked         time.Time `json:"last_checked,omitempty"`
+	NextProbe           time.Time `json:"next_probe,omitempty"`
+}
+
+var (
+	agentHTTPTransportOnce sync.Once
+	agentHTTPTransport     *http.Transport
+
+	agentHealthMu sync.Mutex
+	agentHealth   AgentHealthStatus
+)
+
+// agentConnector is a transport whose connection can be opened before the first call
+type agentConnector interface {
+	Connect(ctx context.Context) error
+}
+
+// sharedAgentTransport is the connection pool every agent client uses, so the connections
+// opened by warm-up and health probes are the ones user requests reuse
+func sharedAgentTransport() *http.Transport {
+	agentHTTPTransportOnce.Do(func() {
+		agentHTTPTransport = http.DefaultTransport.(*http.Transport).Clone()
+		agentHTTPTransport.MaxIdleConns = AgentMaxIdleConns * 2
+		agentHTTPTransport.MaxIdleConnsPerHost = AgentMaxIdleConns
+		agentHTTPTransport.IdleConnTimeout = agentIdleConnTimeout
+	})
+	return agentHTTPTransport
+}
+
+// GetAgentHealth returns the agent's last known health
+func GetAgentHealth() AgentHealthStatus {
+	agentHealthMu.Lock()
+	defer agentHealthMu.Unlock()
+	return agentHealth
+}
+
+// recordAgentHealth notes the outcome of a probe or call and schedules the next probe,
+// backing off exponentially while the agent keeps failing
+func recordAgentHealth(err error) AgentHealthStatus {
+	agentHealthMu.Lock()
+	defer agentHealthMu.Unlock()
+
+	now := time.Now()
+	agentHealth.LastChecked = now
+	if err == nil {
+		agentHealth.Healthy = true
+		agentHealth.ConsecutiveFailures = 0
+		agentHealth.LastError = ""
+		agentHealth.NextProbe = now.Add(AgentHealthInterval)
+		return agentHealth
+	}
+
+	agentHealth.Healthy = false
+	agentHealth.ConsecutiveFailures++
+	agentHealth.LastError = err.Error()
+	agentHealth.NextProbe = now.Add(agentBackoff(agentHealth.ConsecutiveFailures))
+	return agentHealth
+}
+
+// agentBackoff doubles from a second up to a minute with each consecutive failure
+func agentBackoff(failures int) time.Duration {
+	backoff := agentBackoffMin
+	for i := 1; i < failures && backoff < agentBackoffMax; i++ {
+		backoff *= 2
+	}
+	if backoff > agentBackoffMax {
+		return agentBackoffMax
+	}
+	return backoff
+}
+
+// agentUnavailable returns an error while the agent has failed repeatedly and its next
+// probe isn't due, so requests fail fast instead of each waiting out the timeout
+func agentUnavailable(method string) error {
+	health := GetAgentHealth()
+	if health.ConsecutiveFailures < agentFailFastAfter || !time.Now().Before(health.NextProbe) {
+		return nil
+	}
+	return &AgentError{
+		Code:    AgentErrUnavailable,
+		Message: fmt.Sprintf("agent is unavailable (%s), retrying in %s", health.LastError, time.Until(health.NextProbe).Round(time.Second)),
+		Method:  method,
+	}
+}
+
+// pingAgent calls the agent's health endpoint
+func pingAgent(ctx context.Context) error {
+	client := GetAIClient()
+	ctx, cancel := context.WithTimeout(ctx, agentProbeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, client.baseURL+"/health", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to check health: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check failed with status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// StartAgentWarmup probes the agent in the background from startup: once it answers, a few
+// parallel pings fill the connection pool, then probes keep the connections alive and
+// notice outages, backing off while the agent is down. Mock mode answers in-process, so
+// the agent is reported healthy and warm without probing.
+func StartAgentWarmup(ctx context.Context) {
+	if os.Getenv("AI_MODE") == AIModeMock {
+		agentHealthMu.Lock()
+		agentHealth = AgentHealthStatus{Healthy: true, Warm: true, LastChecked: time.Now()}
+		agentHealthMu.Unlock()
+		return
+	}
+	go func() {
+		started := time.Now()
+		for attempt := 1; ; attempt++ {
+			health := recordAgentHealth(pingAgent(ctx))
+			if health.Healthy && !health.Warm {
+				prewarmAgentConnections(ctx)
+				utils.LogInfo(fmt.Sprintf("Agent warm after %d attempt(s) in %s", attempt, time.Since(started).Round(time.Millisecond)))
+			} else if !health.Healthy && health.ConsecutiveFailures == 1 {
+				utils.LogWarning("Agent health check failed, backing off: " + health.LastError)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Until(health.NextProbe)):
+			}
+		}
+	}()
+}
+
+// prewarmAgentConnections opens several pooled connections at once, and the gRPC
+// connection when the agent is called over gRPC, so first requests don't each dial the agent
+func prewarmAgentConnections(ctx context.Context) {
+	var wg sync.WaitGroup
+	if connector, ok := GetAIClient().transport.(agentConnector); ok {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, agentProbeTimeout)
+			defer cancel()
+			if err := connector.Connect(ctx); err != nil {
+				utils.LogWarning(fmt.Sprintf("Failed to prewarm the agent gRPC connection: %v", err))
+			}
+		}()
+	}
+	for i := 0; i < AgentPrewarmConnections; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pingAgent(ctx)
+		}()
+	}
+	wg.Wait()
+
+	agentHealthMu.Lock()
+	agentHealth.Warm = true
+	agentHealthMu.Unlock()
+}
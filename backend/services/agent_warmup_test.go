@@ -0,0 +1,30 @@
+package services
+
+import (
+	"context"
+	"testing"
+)
+
+// resetAgentHealth clears the shared agent health after a test that changes it
+func resetAgentHealth(t *testing.T) {
+	t.Cleanup(func() {
+		agentHealthMu.Lock()
+		agentHealth = AgentHealthStatus{}
+		agentHealthMu.Unlock()
+	})
+}
+
+func TestStartAgentWarmupInMockMode(t *testing.T) {
+	resetAgentHealth(t)
+	t.Setenv("AI_MODE", AIModeMock)
+
+	StartAgentWarmup(context.Background())
+
+	health := GetAgentHealth()
+	if !health.Healthy || !health.Warm || health.LastChecked.IsZero() {
+		t.Errorf("mock agent health = %+v, want healthy and warm", health)
+	}
+	if err := agentUnavailable(AgentMethodChat); err != nil {
+		t.Errorf("mock agent reported unavailable: %v", err)
+	}
+}
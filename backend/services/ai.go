@@ -2,57 +2,72 @@ package services
 
 //COMPLETED
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"time"
+
+	"github.com/joshndala/cantrip/models"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // AI service configuration
 const (
-	LangGraphBaseURL = "http://localhost:8001"
-	DefaultTimeout   = 30 * time.Second
+	LangGraphBaseURL       = "http://localhost:8001"
+	LangGraphDockerBaseURL = "http://cantrip-agent:8001"
+	DefaultTimeout         = 30 * time.Second
 )
 
-// ItineraryRequest represents a request to generate an itinerary
-type ItineraryRequest struct {
-	City          string   `json:"city"`
-	StartDate     string   `json:"start_date"`
-	EndDate       string   `json:"end_date"`
-	Interests     []string `json:"interests"`
-	Budget        float64  `json:"budget"`
-	GroupSize     int      `json:"group_size"`
-	Pace          string   `json:"pace"`          // relaxed, moderate, intense
-	Accommodation string   `json:"accommodation"` // budget, mid-range, luxury
+// AIItineraryRequest is the agent's request to generate an itinerary
+type AIItineraryRequest struct {
+	City          string    `json:"city"`
+	StartDate     string    `json:"start_date"`
+	EndDate       string    `json:"end_date"`
+	Interests     []string  `json:"interests"`
+	Budget        float64   `json:"budget"`
+	GroupSize     int       `json:"group_size"`
+	Pace          string    `json:"pace"`                  // relaxed, moderate, intense
+	Accommodation string    `json:"accommodation"`         // budget, mid-range, luxury
+	Anchors       []Booking `json:"anchors,omitempty"`     // fixed bookings to plan around
+	Transport     string    `json:"transport,omitempty"`   // walking, public, taxi, rental
+	Eco           bool      `json:"eco,omitempty"`         // prefer transit and walking legs
+	Recommended   []string  `json:"recommended,omitempty"` // activities similar travelers enjoyed, to work in
 }
 
-// ItineraryResponse represents the response from itinerary generation
-type ItineraryResponse struct {
-	Success   bool                   `json:"success"`
-	Itinerary map[string]interface{} `json:"itinerary"`
-	Metadata  struct {
-		City        string  `json:"city"`
-		Duration    int     `json:"duration"`
-		TotalCost   float64 `json:"total_cost"`
-		GeneratedAt string  `json:"generated_at"`
-	} `json:"metadata"`
+// NewAIItineraryRequest carries an itinerary call's body over to the agent's request.
+// Anchors and recommendations are filled in by the service.
+func NewAIItineraryRequest(req models.ItineraryRequest) AIItineraryRequest {
+	return AIItineraryRequest{
+		City:          req.City,
+		StartDate:     req.StartDate.String(),
+		EndDate:       req.EndDate.String(),
+		Interests:     req.Interests,
+		Budget:        req.Budget,
+		GroupSize:     req.GroupSize,
+		Pace:          req.Pace,
+		Accommodation: req.Accommodation,
+		Transport:     req.Transport,
+		Eco:           req.Eco,
+	}
 }
 
-// ExploreRequest represents a request to explore a destination
-type ExploreRequest struct {
+// ItineraryResponse represents the response from itinerary generation
+type ItineraryResponse = models.ItineraryResponse
+
+// AIExploreRequest is the agent's request to explore a destination
+type AIExploreRequest struct {
 	Mood      string   `json:"mood"`
 	City      string   `json:"city"`
 	Budget    float64  `json:"budget"`
 	Duration  int      `json:"duration"`
 	Interests []string `json:"interests"`
+	Eco       bool     `json:"eco,omitempty"`
 }
 
-// ExploreResponse represents the response from destination exploration
-type ExploreResponse struct {
+// AIExploreResponse is the agent's destination exploration result
+type AIExploreResponse struct {
 	Success     bool                     `json:"success"`
 	Suggestions []map[string]interface{} `json:"suggestions"`
 	Weather     map[string]interface{}   `json:"weather"`
@@ -64,25 +79,14 @@ type ExploreResponse struct {
 	} `json:"metadata"`
 }
 
-// ChatRequest represents a chat request
-type ChatRequest struct {
+// AIChatRequest is the agent's chat request
+type AIChatRequest struct {
 	Message   string                   `json:"message"`
 	SessionID string                   `json:"session_id"`
 	Context   map[string]interface{}   `json:"context"`
 	History   []map[string]interface{} `json:"history"`
 }
 
-// AIChatResponse represents the response from chat
-type AIChatResponse struct {
-	Response    string                   `json:"response"`
-	SessionID   string                   `json:"session_id"`
-	Intent      string                   `json:"intent"`
-	Confidence  float64                  `json:"confidence"`
-	Suggestions []map[string]interface{} `json:"suggestions"`
-	Data        map[string]interface{}   `json:"data"`
-	Timestamp   string                   `json:"timestamp"`
-}
-
 // AIPackingRequest represents a request to generate a packing list
 type AIPackingRequest struct {
 	Destination  string   `json:"destination"`
@@ -111,20 +115,27 @@ type AIPackingResponse struct {
 type AIClient struct {
 	baseURL    string
 	httpClient *http.Client
+	transport  AgentTransport
 }
 
 // NewAIClient creates a new AI client
 func NewAIClient() *AIClient {
 	baseURL := os.Getenv("LANGGRAPH_BASE_URL")
 	if baseURL == "" {
+		// In Docker: use service name, in development: use localhost
 		baseURL = LangGraphBaseURL
+		if os.Getenv("DOCKER_ENV") != "" {
+			baseURL = LangGraphDockerBaseURL
+		}
 	}
 
 	return &AIClient{
 		baseURL: baseURL,
 		httpClient: &http.Client{
-			Timeout: DefaultTimeout,
+			Timeout:   UpstreamTimeoutFor(UpstreamAgent),
+			Transport: tracedTransport(sharedAgentTransport()),
 		},
+		transport: NewAgentTransport(baseURL),
 	}
 }
 
@@ -145,114 +156,121 @@ func GetAIClient() *AIClient {
 }
 
 // GenerateItinerary generates a complete itinerary using the LangGraph agent
-func GenerateItinerary(req ItineraryRequest) (*ItineraryResponse, error) {
+func GenerateItinerary(ctx context.Context, req AIItineraryRequest) (*ItineraryResponse, error) {
 	client := GetAIClient()
 
-	// Convert request to JSON
-	jsonData, err := json.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal itinerary request: %w", err)
+	// Eco trips default to transit unless the traveler picked a mode
+	if req.Eco && req.Transport == "" {
+		req.Transport = TransportPublic
 	}
 
-	// Make HTTP request to LangGraph agent
-	resp, err := client.makeRequest("POST", "/generate-itinerary", jsonData)
-	if err != nil {
-		return nil, err
+	// Travelers like this one point the agent at what they enjoyed
+	if len(req.Recommended) == 0 {
+		req.Recommended = recommendedForItinerary(ctx, req)
 	}
 
-	// Parse response
-	var itineraryResp ItineraryResponse
-	if err := json.Unmarshal(resp, &itineraryResp); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal itinerary response: %w", err)
+	agentCtx, span := startSpan(ctx, "agent."+AgentMethodGenerateItinerary,
+		attribute.String("agent.transport", client.transport.Name()),
+		attribute.String("itinerary.city", req.City),
+	)
+	var result ItineraryResponse
+	err := client.transport.Call(agentCtx, AgentMethodGenerateItinerary, req, &result)
+	endSpan(span, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate itinerary: %w", err)
 	}
 
-	return &itineraryResp, nil
-}
+	// Scheduling and lookups after the agent replies are timed separately
+	_, span = startSpan(ctx, "itinerary.enrich")
+	defer span.End()
 
-// ExploreDestination explores a destination using the LangGraph agent
-func ExploreDestination(req ExploreRequest) (*ExploreResponse, error) {
-	client := GetAIClient()
+	// Slot popular attractions at off-peak times
+	ScheduleItineraryForCrowds(ctx, &result)
 
-	// Convert request to JSON
-	jsonData, err := json.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal explore request: %w", err)
+	// Fixed bookings take precedence over planned activities
+	if len(result.Anchors) == 0 {
+		result.Anchors = req.Anchors
 	}
+	ApplyBookingAnchors(ctx, &result)
 
-	// Make HTTP request to LangGraph agent
-	resp, err := client.makeRequest("POST", "/explore-destination", jsonData)
-	if err != nil {
-		return nil, err
+	// Road trips get the province's driving rules and tire laws
+	if UsesRentalCar(req.Transport, &result) {
+		if advisory, err := GetDrivingAdvisory(ctx, req.City, req.StartDate, req.EndDate); err == nil {
+			result.Driving = advisory
+		}
 	}
 
-	// Parse response
-	var exploreResp ExploreResponse
-	if err := json.Unmarshal(resp, &exploreResp); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal explore response: %w", err)
+	if req.Eco {
+		annotateEcoTransport(&result)
 	}
 
-	return &exploreResp, nil
-}
+	// Nightlife interests get an evening out on each day
+	if WantsNightlife(req.Interests) {
+		ScheduleNightlife(ctx, &result)
+	}
 
-// Chat handles conversational chat with the travel agent
-func Chat(req ChatRequest) (*AIChatResponse, error) {
-	client := GetAIClient()
+	// Days point at curated guides about what's planned
+	LinkItineraryGuides(ctx, &result)
 
-	// Convert request to JSON
-	jsonData, err := json.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal chat request: %w", err)
+	// Major festivals are known months before ticket APIs list them
+	if festivals, err := FindFestivals(ctx, req.City, req.StartDate, req.EndDate); err == nil && len(festivals) > 0 {
+		result.Festivals = festivals
 	}
 
-	// Make HTTP request to LangGraph agent
-	resp, err := client.makeRequest("POST", "/chat", jsonData)
-	if err != nil {
-		return nil, err
-	}
+	return &result, nil
+}
 
-	// Parse response
-	var chatResp AIChatResponse
-	if err := json.Unmarshal(resp, &chatResp); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal chat response: %w", err)
+// ExploreDestination explores a destination using the LangGraph agent
+func ExploreDestination(ctx context.Context, req AIExploreRequest) (*AIExploreResponse, error) {
+	client := GetAIClient()
+
+	var result AIExploreResponse
+	if err := client.transport.Call(ctx, AgentMethodExploreDestination, req, &result); err != nil {
+		return nil, fmt.Errorf("failed to explore destination: %w", err)
 	}
 
-	return &chatResp, nil
+	return &result, nil
 }
 
-// GenerateAIPackingList generates a personalized packing list using the LangGraph agent
-func GenerateAIPackingList(req AIPackingRequest) (*AIPackingResponse, error) {
+// Chat handles conversational chat with the travel agent
+func Chat(ctx context.Context, req AIChatRequest) (*ChatResponse, error) {
 	client := GetAIClient()
 
-	// Convert request to JSON
-	jsonData, err := json.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal packing request: %w", err)
+	var result ChatResponse
+	if err := client.transport.Call(ctx, AgentMethodChat, req, &result); err != nil {
+		return nil, fmt.Errorf("failed to chat with agent: %w", err)
 	}
 
-	// Make HTTP request to LangGraph agent
-	resp, err := client.makeRequest("POST", "/generate-packing-list", jsonData)
-	if err != nil {
-		return nil, err
-	}
+	result.Cards = BuildChatCards(result.Data)
+	result.Intents = ChatIntents(result.Intent, result.Data, result.Cards)
+	return &result, nil
+}
 
-	// Parse response
-	var packingResp AIPackingResponse
-	if err := json.Unmarshal(resp, &packingResp); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal packing response: %w", err)
+// GenerateAIPackingList generates a personalized packing list using the LangGraph agent
+func GenerateAIPackingList(ctx context.Context, req AIPackingRequest) (*AIPackingResponse, error) {
+	client := GetAIClient()
+
+	var result AIPackingResponse
+	if err := client.transport.Call(ctx, AgentMethodGeneratePackingList, req, &result); err != nil {
+		return nil, fmt.Errorf("failed to generate packing list: %w", err)
 	}
 
-	return &packingResp, nil
+	return &result, nil
 }
 
 // GetAIRecommendations gets recommendations for a specific city and category
-func GetAIRecommendations(city, category string) ([]map[string]interface{}, error) {
+func GetAIRecommendations(ctx context.Context, city, category string) ([]map[string]interface{}, error) {
 	client := GetAIClient()
 
 	// Build URL with query parameters
 	url := fmt.Sprintf("%s/tools/recommendations?city=%s&category=%s", client.baseURL, city, category)
 
-	// Make HTTP request
-	resp, err := client.httpClient.Get(url)
+	// Make HTTP request within the caller's deadline
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recommendations request: %w", err)
+	}
+	resp, err := client.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get recommendations: %w", err)
 	}
@@ -276,7 +294,7 @@ func GetAIRecommendations(city, category string) ([]map[string]interface{}, erro
 }
 
 // GetAIEvents gets events for a specific city and date
-func GetAIEvents(city, date string) ([]map[string]interface{}, error) {
+func GetAIEvents(ctx context.Context, city, date string) ([]map[string]interface{}, error) {
 	client := GetAIClient()
 
 	// Build URL with query parameters
@@ -285,8 +303,12 @@ func GetAIEvents(city, date string) ([]map[string]interface{}, error) {
 		url += fmt.Sprintf("&date=%s", date)
 	}
 
-	// Make HTTP request
-	resp, err := client.httpClient.Get(url)
+	// Make HTTP request within the caller's deadline
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create events request: %w", err)
+	}
+	resp, err := client.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get events: %w", err)
 	}
@@ -310,14 +332,18 @@ func GetAIEvents(city, date string) ([]map[string]interface{}, error) {
 }
 
 // GetAttractions gets attractions for a specific city and category
-func GetAttractions(city, category string) ([]map[string]interface{}, error) {
+func GetAttractions(ctx context.Context, city, category string) ([]map[string]interface{}, error) {
 	client := GetAIClient()
 
 	// Build URL with query parameters
 	url := fmt.Sprintf("%s/tools/attractions?city=%s&category=%s", client.baseURL, city, category)
 
-	// Make HTTP request
-	resp, err := client.httpClient.Get(url)
+	// Make HTTP request within the caller's deadline
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create attractions request: %w", err)
+	}
+	resp, err := client.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get attractions: %w", err)
 	}
@@ -341,10 +367,14 @@ func GetAttractions(city, category string) ([]map[string]interface{}, error) {
 }
 
 // HealthCheck checks if the LangGraph agent is healthy
-func HealthCheck() error {
+func HealthCheck(ctx context.Context) error {
 	client := GetAIClient()
 
-	resp, err := client.httpClient.Get(client.baseURL + "/health")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, client.baseURL+"/health", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create health request: %w", err)
+	}
+	resp, err := client.httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to check health: %w", err)
 	}
@@ -357,76 +387,18 @@ func HealthCheck() error {
 	return nil
 }
 
-// makeRequest makes an HTTP request to the LangGraph agent
-func (c *AIClient) makeRequest(method, endpoint string, data []byte) ([]byte, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
-	defer cancel()
-
-	// Create request
-	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+endpoint, bytes.NewBuffer(data))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	// Make request
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API returned status: %d", resp.StatusCode)
-	}
-
-	// Read response
-	responseData, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	return responseData, nil
-}
-
 // Legacy functions for backward compatibility
 func GenerateItineraryLegacy(req interface{}) (interface{}, error) {
 	// Convert legacy request to new format
-	itineraryReq, ok := req.(ItineraryRequest)
+	itineraryReq, ok := req.(AIItineraryRequest)
 	if !ok {
 		return nil, fmt.Errorf("invalid request type")
 	}
 
-	result, err := GenerateItinerary(itineraryReq)
+	result, err := GenerateItinerary(context.Background(), itineraryReq)
 	if err != nil {
 		return nil, err
 	}
 
 	return result, nil
 }
-
-// SaveItinerary saves an itinerary (placeholder for future implementation)
-func SaveItinerary(itinerary interface{}) error {
-	// TODO: Implement saving to GCS or database
-	return nil
-}
-
-// GetItinerary retrieves an itinerary by ID (placeholder for future implementation)
-func GetItinerary(id string) (interface{}, error) {
-	// TODO: Implement retrieval from GCS or database
-	return map[string]interface{}{
-		"id":         id,
-		"city":       "Sample City",
-		"total_cost": 1000.0,
-		"duration":   7,
-		"days":       []interface{}{},
-		"created_at": "2024-01-01T00:00:00Z",
-	}, nil
-}
-
-// DeleteItinerary deletes an itinerary (placeholder for future implementation)
-func DeleteItinerary(id string) error {
-	// TODO: Implement deletion from GCS or database
-	return nil
-}
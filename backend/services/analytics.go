@@ -0,0 +1,315 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joshndala/cantrip/utils"
+	"google.golang.org/api/bigquery/v2"
+)
+
+// Analytics event names
+const (
+	EventItineraryGenerated = "itinerary_generated"
+	EventPDFDownloaded      = "pdf_downloaded"
+	EventMoodSelected       = "mood_selected"
+)
+
+// ANALYTICS_SINK values; the log file is the default
+const (
+	AnalyticsSinkFile     = "file"
+	AnalyticsSinkPostHog  = "posthog"
+	AnalyticsSinkBigQuery = "bigquery"
+	AnalyticsSinkNone     = "none"
+)
+
+// AnalyticsLogFile is where the file sink appends events
+const AnalyticsLogFile = "data/analytics/events.jsonl"
+
+// PostHogDefaultHost is PostHog Cloud's ingestion host, overridden by POSTHOG_HOST
+const PostHogDefaultHost = "https://us.i.posthog.com"
+
+// Analytics batching
+const (
+	analyticsQueueSize = 1000 // events buffered before new ones are dropped
+	analyticsBatchSize = 50
+)
+
+// AnalyticsEvent is one anonymous usage event. It carries no user ID, IP address or free
+// text: AnonymousID is a salted hash, and properties are limited to product dimensions.
+type AnalyticsEvent struct {
+	ID          string                 `json:"id"`
+	Name        string                 `json:"event"`
+	AnonymousID string                 `json:"anonymous_id"`
+	Timestamp   time.Time              `json:"timestamp"`
+	Properties  map[string]interface{} `json:"properties,omitempty"`
+}
+
+// AnalyticsSink delivers batches of events
+type AnalyticsSink interface {
+	Name() string
+	Send(ctx context.Context, events []AnalyticsEvent) error
+}
+
+var (
+	analyticsOnce  sync.Once
+	analyticsQueue chan AnalyticsEvent
+)
+
+// AnalyticsEnabled reports whether usage analytics are collected; ANALYTICS_DISABLED=true
+// opts the whole deployment out
+func AnalyticsEnabled() bool {
+	return os.Getenv("ANALYTICS_DISABLED") != "true" && GetAnalyticsSink() != nil
+}
+
+// newAnalyticsSink builds the sink chosen by ANALYTICS_SINK
+func newAnalyticsSink() AnalyticsSink {
+	switch sink := os.Getenv("ANALYTICS_SINK"); sink {
+	case AnalyticsSinkNone:
+		return nil
+	case AnalyticsSinkPostHog:
+		apiKey := os.Getenv("POSTHOG_API_KEY")
+		if apiKey == "" {
+			utils.LogWarning("ANALYTICS_SINK=posthog requires POSTHOG_API_KEY, analytics disabled")
+			return nil
+		}
+		host := os.Getenv("POSTHOG_HOST")
+		if host == "" {
+			host = PostHogDefaultHost
+		}
+		return &PostHogSink{APIKey: apiKey, Host: strings.TrimSuffix(host, "/"), HTTPClient: upstreamHTTPClient()}
+	case AnalyticsSinkBigQuery:
+		project, dataset, table := os.Getenv("BIGQUERY_PROJECT"), os.Getenv("BIGQUERY_DATASET"), os.Getenv("BIGQUERY_TABLE")
+		if project == "" || dataset == "" || table == "" {
+			utils.LogWarning("ANALYTICS_SINK=bigquery requires BIGQUERY_PROJECT, BIGQUERY_DATASET and BIGQUERY_TABLE, analytics disabled")
+			return nil
+		}
+		return &BigQuerySink{Project: project, Dataset: dataset, Table: table}
+	case "", AnalyticsSinkFile:
+		return &FileAnalyticsSink{Path: AnalyticsLogFile}
+	default:
+		utils.LogWarning(fmt.Sprintf("Unknown ANALYTICS_SINK %q, analytics disabled", sink))
+		return nil
+	}
+}
+
+// AnonymousID derives a stable, non-reversible ID for an actor, salted with ANALYTICS_SALT
+func AnonymousID(actor string) string {
+	if actor == "" || actor == "anonymous" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(os.Getenv("ANALYTICS_SALT") + "\n" + actor))
+	return hex.EncodeToString(sum[:12])
+}
+
+// TrackEvent queues an event for delivery without waiting on the sink. Events are dropped,
+// not delayed, when analytics are disabled or the queue is full.
+func TrackEvent(name, anonymousID string, properties map[string]interface{}) {
+	if !AnalyticsEnabled() {
+		return
+	}
+	analyticsOnce.Do(startAnalyticsWorker)
+
+	event := AnalyticsEvent{
+		ID:          uuid.New().String(),
+		Name:        name,
+		AnonymousID: anonymousID,
+		Timestamp:   time.Now().UTC(),
+		Properties:  properties,
+	}
+	select {
+	case analyticsQueue <- event:
+	default:
+		utils.LogWarning("Analytics queue full, dropping " + name)
+	}
+}
+
+// startAnalyticsWorker sends queued events in batches, at least every few seconds
+func startAnalyticsWorker() {
+	analyticsQueue = make(chan AnalyticsEvent, analyticsQueueSize)
+	go func() {
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+
+		var batch []AnalyticsEvent
+		flush := func() {
+			if len(batch) == 0 {
+				return
+			}
+			if sink := GetAnalyticsSink(); sink != nil {
+				ctx, cancel := context.WithTimeout(context.Background(), UpstreamTimeout)
+				if err := sink.Send(ctx, batch); err != nil {
+					utils.LogError(fmt.Sprintf("Failed to send %d analytics events to %s", len(batch), sink.Name()), err)
+				}
+				cancel()
+			}
+			batch = nil
+		}
+
+		for {
+			select {
+			case event := <-analyticsQueue:
+				batch = append(batch, event)
+				if len(batch) >= analyticsBatchSize {
+					flush()
+				}
+			case <-ticker.C:
+				flush()
+			}
+		}
+	}()
+}
+
+// FileAnalyticsSink appends events to a JSON lines file
+type FileAnalyticsSink struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (s *FileAnalyticsSink) Name() string {
+	return AnalyticsSinkFile
+}
+
+func (s *FileAnalyticsSink) Send(ctx context.Context, events []AnalyticsEvent) error {
+	var lines bytes.Buffer
+	for _, event := range events {
+		line, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to marshal analytics event: %w", err)
+		}
+		lines.Write(append(line, '\n'))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0755); err != nil {
+		return fmt.Errorf("failed to create analytics directory: %w", err)
+	}
+	file, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open analytics log: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(lines.Bytes()); err != nil {
+		return fmt.Errorf("failed to write analytics events: %w", err)
+	}
+	return nil
+}
+
+// PostHogSink sends events to the PostHog batch API
+type PostHogSink struct {
+	APIKey     string
+	Host       string
+	HTTPClient *http.Client
+}
+
+func (s *PostHogSink) Name() string {
+	return AnalyticsSinkPostHog
+}
+
+func (s *PostHogSink) Send(ctx context.Context, events []AnalyticsEvent) error {
+	batch := make([]map[string]interface{}, 0, len(events))
+	for _, event := range events {
+		properties := map[string]interface{}{"$insert_id": event.ID}
+		for key, value := range event.Properties {
+			properties[key] = value
+		}
+		distinctID := event.AnonymousID
+		if distinctID == "" {
+			// Without a person, PostHog still counts the event but builds no profile
+			distinctID = event.ID
+			properties["$process_person_profile"] = false
+		}
+		batch = append(batch, map[string]interface{}{
+			"event":       event.Name,
+			"distinct_id": distinctID,
+			"timestamp":   event.Timestamp.Format(time.RFC3339Nano),
+			"properties":  properties,
+		})
+	}
+	payload, err := json.Marshal(map[string]interface{}{"api_key": s.APIKey, "batch": batch})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Host+"/batch/", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create PostHog request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send to PostHog: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("PostHog API returned status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// BigQuerySink streams events into a table with the columns id, event, anonymous_id,
+// timestamp and properties (a JSON string), using application default credentials
+type BigQuerySink struct {
+	Project string
+	Dataset string
+	Table   string
+
+	once    sync.Once
+	service *bigquery.Service
+	err     error
+}
+
+func (s *BigQuerySink) Name() string {
+	return AnalyticsSinkBigQuery
+}
+
+func (s *BigQuerySink) Send(ctx context.Context, events []AnalyticsEvent) error {
+	s.once.Do(func() {
+		s.service, s.err = bigquery.NewService(context.Background())
+	})
+	if s.err != nil {
+		return fmt.Errorf("failed to create BigQuery client: %w", s.err)
+	}
+
+	rows := make([]*bigquery.TableDataInsertAllRequestRows, 0, len(events))
+	for _, event := range events {
+		properties, err := json.Marshal(event.Properties)
+		if err != nil {
+			return fmt.Errorf("failed to marshal analytics event: %w", err)
+		}
+		rows = append(rows, &bigquery.TableDataInsertAllRequestRows{
+			// The insert ID lets BigQuery drop rows retried after a timeout
+			InsertId: event.ID,
+			Json: map[string]bigquery.JsonValue{
+				"id":           event.ID,
+				"event":        event.Name,
+				"anonymous_id": event.AnonymousID,
+				"timestamp":    event.Timestamp.Format(time.RFC3339Nano),
+				"properties":   string(properties),
+			},
+		})
+	}
+
+	resp, err := s.service.Tabledata.InsertAll(s.Project, s.Dataset, s.Table, &bigquery.TableDataInsertAllRequest{Rows: rows}).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("failed to insert into BigQuery: %w", err)
+	}
+	if len(resp.InsertErrors) > 0 {
+		return fmt.Errorf("BigQuery rejected %d of %d rows", len(resp.InsertErrors), len(rows))
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrAnalyticsStoreUnavailable is returned when events go to an external sink, which is
+// queried with its own tools
+var ErrAnalyticsStoreUnavailable = errors.New("analytics stats are computed from the file sink")
+
+// topAnalyticsCounts bounds the destination and mood rankings
+const topAnalyticsCounts = 10
+
+// AnalyticsStats aggregates usage events over a date range
+type AnalyticsStats struct {
+	From            time.Time        `json:"from,omitempty"`
+	To              time.Time        `json:"to,omitempty"`
+	Events          map[string]int   `json:"events"`
+	TopDestinations []AnalyticsCount `json:"top_destinations"` // by itineraries generated
+	PopularMoods    []AnalyticsCount `json:"popular_moods"`
+	AverageTripDays float64          `json:"average_trip_days"`
+	Funnel          AnalyticsFunnel  `json:"funnel"`
+	ByDay           []AnalyticsDay   `json:"by_day"`
+}
+
+// AnalyticsCount is a ranked value
+type AnalyticsCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// AnalyticsFunnel follows visitors from exploring a mood to generating an itinerary to
+// downloading a PDF. Steps count distinct anonymous visitors who did that step after the
+// previous one; events without a visitor ID can't be followed and are left out.
+type AnalyticsFunnel struct {
+	Explored           int     `json:"explored"`
+	Planned            int     `json:"planned"`
+	Downloaded         int     `json:"downloaded"`
+	ExploreToItinerary float64 `json:"explore_to_itinerary"`
+	ItineraryToPDF     float64 `json:"itinerary_to_pdf"`
+	ExploreToPDF       float64 `json:"explore_to_pdf"`
+}
+
+// AnalyticsDay counts a day's events by name
+type AnalyticsDay struct {
+	Date   string         `json:"date"`
+	Events map[string]int `json:"events"`
+}
+
+// GetAnalyticsStats aggregates the events logged between from and to; a zero time leaves
+// that end of the range open
+func GetAnalyticsStats(from, to time.Time) (*AnalyticsStats, error) {
+	sink, ok := GetAnalyticsSink().(*FileAnalyticsSink)
+	if !ok {
+		return nil, ErrAnalyticsStoreUnavailable
+	}
+
+	stats := &AnalyticsStats{
+		From:            from,
+		To:              to,
+		Events:          map[string]int{},
+		TopDestinations: []AnalyticsCount{},
+		PopularMoods:    []AnalyticsCount{},
+		ByDay:           []AnalyticsDay{},
+	}
+
+	file, err := os.Open(sink.Path)
+	if os.IsNotExist(err) {
+		return stats, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open analytics log: %w", err)
+	}
+	defer file.Close()
+
+	destinations := map[string]int{}
+	cityNames := map[string]string{}
+	moods := map[string]int{}
+	days := map[string]*AnalyticsDay{}
+	var tripDays, trips int
+
+	// The furthest funnel step each visitor reached, in order; the log is chronological
+	funnelSteps := map[string]int{}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event AnalyticsEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		if (!from.IsZero() && event.Timestamp.Before(from)) || (!to.IsZero() && !event.Timestamp.Before(to)) {
+			continue
+		}
+
+		stats.Events[event.Name]++
+		date := event.Timestamp.Format("2006-01-02")
+		day := days[date]
+		if day == nil {
+			day = &AnalyticsDay{Date: date, Events: map[string]int{}}
+			days[date] = day
+		}
+		day.Events[event.Name]++
+
+		step := 0
+		switch event.Name {
+		case EventMoodSelected:
+			if mood, _ := event.Properties["mood"].(string); mood != "" {
+				moods[strings.ToLower(mood)]++
+			}
+			step = 1
+		case EventItineraryGenerated:
+			// Cities are counted case-insensitively under their first spelling
+			city, _ := event.Properties["city"].(string)
+			if city = strings.TrimSpace(city); city != "" {
+				key := strings.ToLower(city)
+				if _, seen := cityNames[key]; !seen {
+					cityNames[key] = city
+				}
+				destinations[cityNames[key]]++
+			}
+			if length, ok := event.Properties["days"].(float64); ok && length > 0 {
+				tripDays += int(length)
+				trips++
+			}
+			step = 2
+		case EventPDFDownloaded:
+			step = 3
+		}
+		if step > 0 && event.AnonymousID != "" && funnelSteps[event.AnonymousID] == step-1 {
+			funnelSteps[event.AnonymousID] = step
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read analytics log: %w", err)
+	}
+
+	stats.TopDestinations = rankAnalyticsCounts(destinations)
+	stats.PopularMoods = rankAnalyticsCounts(moods)
+	if trips > 0 {
+		stats.AverageTripDays = float64(tripDays) / float64(trips)
+	}
+	stats.Funnel = analyticsFunnel(funnelSteps)
+
+	for _, day := range days {
+		stats.ByDay = append(stats.ByDay, *day)
+	}
+	sort.Slice(stats.ByDay, func(i, j int) bool { return stats.ByDay[i].Date < stats.ByDay[j].Date })
+	return stats, nil
+}
+
+// analyticsFunnel counts the visitors who reached each step
+func analyticsFunnel(steps map[string]int) AnalyticsFunnel {
+	var funnel AnalyticsFunnel
+	for _, step := range steps {
+		if step >= 1 {
+			funnel.Explored++
+		}
+		if step >= 2 {
+			funnel.Planned++
+		}
+		if step >= 3 {
+			funnel.Downloaded++
+		}
+	}
+	if funnel.Explored > 0 {
+		funnel.ExploreToItinerary = float64(funnel.Planned) / float64(funnel.Explored)
+		funnel.ExploreToPDF = float64(funnel.Downloaded) / float64(funnel.Explored)
+	}
+	if funnel.Planned > 0 {
+		funnel.ItineraryToPDF = float64(funnel.Downloaded) / float64(funnel.Planned)
+	}
+	return funnel
+}
+
+// rankAnalyticsCounts sorts counts from most to least common, keeping the top few
+func rankAnalyticsCounts(counts map[string]int) []AnalyticsCount {
+	ranked := make([]AnalyticsCount, 0, len(counts))
+	for value, count := range counts {
+		ranked = append(ranked, AnalyticsCount{Value: value, Count: count})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Count != ranked[j].Count {
+			return ranked[i].Count > ranked[j].Count
+		}
+		return ranked[i].Value < ranked[j].Value
+	})
+	if len(ranked) > topAnalyticsCounts {
+		ranked = ranked[:topAnalyticsCounts]
+	}
+	return ranked
+}
@@ -0,0 +1,322 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/joshndala/cantrip/utils"
+)
+
+// Attachment scan statuses
+const (
+	ScanStatusClean     = "clean"     // the scanner found nothing
+	ScanStatusInfected  = "infected"  // the scanner matched a signature; the file is quarantined
+	ScanStatusError     = "error"     // the scanner failed; the file is quarantined until rescanned
+	ScanStatusUnscanned = "unscanned" // no scanner is configured
+)
+
+// maxAttachmentPixels bounds decoded image size, so a small file cannot expand into a huge bitmap
+const maxAttachmentPixels = 50_000_000
+
+// clamdTimeout bounds one scan, including the upload of the file to clamd
+const clamdTimeout = 30 * time.Second
+
+// clamdChunkSize is the INSTREAM chunk size; clamd's default StreamMaxLength is far larger
+const clamdChunkSize = 64 << 10
+
+// allowedAttachmentTypes are the sniffed content types an attachment may have
+var allowedAttachmentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/gif":       true,
+	"image/bmp":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+}
+
+// ErrAttachmentQuarantined is returned when downloading a file the scanner flagged
+var ErrAttachmentQuarantined = errors.New("attachment is quarantined")
+
+// sniffAttachment detects the upload's type from its content, ignoring the client's
+// extension and Content-Type, and checks that the extension agrees with it
+func sniffAttachment(filename string, data []byte) (string, error) {
+	contentType := strings.SplitN(http.DetectContentType(data), ";", 2)[0]
+	if !allowedAttachmentTypes[contentType] {
+		return "", fmt.Errorf("%w: file content is %s, not an image or PDF", ErrInvalidAttachment, contentType)
+	}
+	ext := utils.GetFileExtension(filename)
+	if getContentTypeFromExtension(ext) != contentType {
+		return "", fmt.Errorf("%w: file content is %s, which does not match the %s extension", ErrInvalidAttachment, contentType, ext)
+	}
+	return contentType, nil
+}
+
+// stripImageMetadata re-encodes JPEG, PNG and GIF images, dropping EXIF, GPS and any other
+// embedded metadata; JPEGs are rotated upright first, since their EXIF orientation is lost.
+// WebP metadata chunks are removed without re-encoding, and BMPs carry no metadata. It
+// reports whether the image was rewritten.
+func stripImageMetadata(contentType string, data []byte) ([]byte, bool, error) {
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif":
+	case "image/webp":
+		stripped, err := stripWebPMetadata(data)
+		return stripped, err == nil, err
+	default:
+		return data, false, nil
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: image could not be read", ErrInvalidAttachment)
+	}
+	if config.Width*config.Height > maxAttachmentPixels {
+		return nil, false, fmt.Errorf("%w: image is larger than %d megapixels", ErrInvalidAttachment, maxAttachmentPixels/1_000_000)
+	}
+
+	var out bytes.Buffer
+	switch contentType {
+	case "image/jpeg":
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, false, fmt.Errorf("%w: image could not be read", ErrInvalidAttachment)
+		}
+		err = jpeg.Encode(&out, orientImage(img, jpegOrientation(data)), &jpeg.Options{Quality: 90})
+		if err != nil {
+			return nil, false, err
+		}
+	case "image/png":
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, false, fmt.Errorf("%w: image could not be read", ErrInvalidAttachment)
+		}
+		if err := png.Encode(&out, img); err != nil {
+			return nil, false, err
+		}
+	case "image/gif":
+		// DecodeAll keeps the frames, timing and loop count of animations
+		anim, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, false, fmt.Errorf("%w: image could not be read", ErrInvalidAttachment)
+		}
+		if err := gif.EncodeAll(&out, anim); err != nil {
+			return nil, false, err
+		}
+	}
+	return out.Bytes(), true, nil
+}
+
+// jpegOrientation reads the EXIF orientation tag (1-8) of a JPEG, or 1 when it has none
+func jpegOrientation(data []byte) int {
+	// Walk the segments before the image data, looking for the APP1 Exif block
+	for i := 2; i+4 <= len(data) && data[i] == 0xFF; {
+		marker := data[i+1]
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if marker == 0xDA || length < 2 || i+2+length > len(data) {
+			break
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+// tiffOrientation finds the orientation tag in the first IFD of an EXIF TIFF block
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for n := 0; n < entries; n++ {
+		entry := ifd + 2 + n*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			if orientation := int(order.Uint16(tiff[entry+8:])); orientation >= 1 && orientation <= 8 {
+				return orientation
+			}
+			break
+		}
+	}
+	return 1
+}
+
+// orientImage applies an EXIF orientation, returning the image as it should be displayed
+func orientImage(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	// Orientations 5-8 swap the width and height
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	out := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			// Map each output pixel back to the stored pixel it shows
+			var sx, sy int
+			switch orientation {
+			case 2: // flip horizontally
+				sx, sy = w-1-x, y
+			case 3: // rotate 180°
+				sx, sy = w-1-x, h-1-y
+			case 4: // flip vertically
+				sx, sy = x, h-1-y
+			case 5: // transpose
+				sx, sy = y, x
+			case 6: // rotate 90° clockwise
+				sx, sy = y, h-1-x
+			case 7: // transverse
+				sx, sy = w-1-y, h-1-x
+			case 8: // rotate 90° counter-clockwise
+				sx, sy = w-1-y, x
+			}
+			out.Set(x, y, img.At(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+	return out
+}
+
+// stripWebPMetadata drops the EXIF and XMP chunks of a WebP file and clears their flags
+func stripWebPMetadata(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, fmt.Errorf("%w: image could not be read", ErrInvalidAttachment)
+	}
+
+	out := append([]byte{}, data[:12]...)
+	for i := 12; i+8 <= len(data); {
+		fourCC := string(data[i : i+4])
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		if i+8+size > len(data) {
+			return nil, fmt.Errorf("%w: image could not be read", ErrInvalidAttachment)
+		}
+		// Chunks are padded to an even length; tolerate a missing pad on the last one
+		end := i + 8 + size + size%2
+		if end > len(data) {
+			end = len(data)
+		}
+
+		switch fourCC {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := append([]byte{}, data[i:end]...)
+			if size > 0 {
+				chunk[8] &^= 0x08 | 0x04 // EXIF and XMP present flags
+			}
+			out = append(out, chunk...)
+		default:
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out, nil
+}
+
+// scanAttachment runs the upload through ClamAV when CLAMAV_ADDRESS is set (host:port, or
+// a unix socket path), returning the scan status and any matched signature
+func scanAttachment(ctx context.Context, data []byte) (status, signature string) {
+	address := os.Getenv("CLAMAV_ADDRESS")
+	if address == "" {
+		return ScanStatusUnscanned, ""
+	}
+
+	signature, err := clamdScan(ctx, address, data)
+	if err != nil {
+		utils.LogError("Attachment virus scan failed", err)
+		return ScanStatusError, ""
+	}
+	if signature != "" {
+		return ScanStatusInfected, signature
+	}
+	return ScanStatusClean, ""
+}
+
+// clamdScan streams data to clamd with the INSTREAM command, returning the signature it
+// matched, or "" when the data is clean
+func clamdScan(ctx context.Context, address string, data []byte) (string, error) {
+	network := "tcp"
+	if strings.HasPrefix(address, "/") {
+		network = "unix"
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, clamdTimeout)
+	defer cancel()
+	conn, err := (&net.Dialer{}).DialContext(ctx, network, address)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to clamd: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return "", fmt.Errorf("failed to send to clamd: %w", err)
+	}
+	size := make([]byte, 4)
+	for start := 0; start < len(data); start += clamdChunkSize {
+		end := start + clamdChunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		binary.BigEndian.PutUint32(size, uint32(end-start))
+		if _, err := conn.Write(append(size, data[start:end]...)); err != nil {
+			return "", fmt.Errorf("failed to send to clamd: %w", err)
+		}
+	}
+	// A zero-length chunk ends the stream
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return "", fmt.Errorf("failed to send to clamd: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil {
+		return "", fmt.Errorf("failed to read clamd reply: %w", err)
+	}
+	reply = strings.TrimSuffix(strings.TrimPrefix(reply, "stream: "), "\x00")
+	switch {
+	case reply == "OK":
+		return "", nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return strings.TrimSuffix(reply, " FOUND"), nil
+	default:
+		return "", fmt.Errorf("clamd: %s", reply)
+	}
+}
@@ -0,0 +1,324 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joshndala/cantrip/utils"
+)
+
+// MaxAttachmentBytes is the largest file a trip attachment may be
+const MaxAttachmentBytes = 10 << 20 // 10 MB
+
+// MaxAttachmentsPerTrip bounds the attachments stored for one trip
+const MaxAttachmentsPerTrip = 50
+
+// attachmentsCollection stores each trip's attachment list
+const attachmentsCollection = "trip_attachments"
+
+// Attachment kinds
+const (
+	AttachmentTicket      = "ticket"
+	AttachmentReservation = "reservation"
+	AttachmentPhoto       = "photo"
+	AttachmentReceipt     = "receipt" // logged with an expense
+	AttachmentOther       = "other"
+)
+
+// ErrInvalidAttachment is returned for an empty, oversized or unsupported upload
+var ErrInvalidAttachment = errors.New("invalid attachment")
+
+// Attachment is a file a traveler stored with a trip, such as a ticket or reservation
+type Attachment struct {
+	ID          string    `json:"id"`
+	TripID      string    `json:"trip_id"`
+	Filename    string    `json:"filename"`
+	Kind        string    `json:"kind"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	UploadedBy  string    `json:"uploaded_by"`
+	UploadedAt  time.Time `json:"uploaded_at"`
+
+	// Safety checks run on upload
+	ScanStatus       string `json:"scan_status"`              // clean, infected, error or unscanned
+	ScanSignature    string `json:"scan_signature,omitempty"` // the signature an infected file matched
+	Quarantined      bool   `json:"quarantined"`              // held back from downloads and bundles
+	MetadataStripped bool   `json:"metadata_stripped"`        // EXIF and GPS data were removed
+
+	// Resized previews of photos, by size
+	Previews map[string]string `json:"previews,omitempty"`
+}
+
+// objectName is where the attachment's file is stored; quarantined files are kept apart
+func (a Attachment) objectName() string {
+	prefix := "attachments"
+	if a.Quarantined {
+		prefix = "quarantine"
+	}
+	return fmt.Sprintf("%s/%s/%s%s", prefix, a.TripID, a.ID, utils.GetFileExtension(a.Filename))
+}
+
+// TripAttachments is the stored attachment list for a trip
+type TripAttachments struct {
+	TripID      string       `json:"trip_id"`
+	Attachments []Attachment `json:"attachments"`
+}
+
+// blobStore returns the configured object store, or a tenant-scoped local one under the
+// data directory when the emulator is turned off
+func blobStore() ObjectStore {
+	if store := GetObjectStore(); store != nil {
+		return store
+	}
+	return dataDirObjectStore()
+}
+
+// isAllowedAttachment accepts images and PDFs
+func isAllowedAttachment(ext string) bool {
+	return utils.IsValidImageExtension(ext) || ext == ".pdf"
+}
+
+// SaveAttachment validates an upload and stores it with the trip. The type is sniffed from
+// the content, the file is virus scanned when ClamAV is configured, and images are
+// re-encoded without their metadata; files the scanner flags are stored in quarantine.
+// kind defaults to a photo for images and other for PDFs.
+func SaveAttachment(ctx context.Context, tripID, uploadedBy, filename, kind string, data []byte) (*Attachment, error) {
+	filename = filepath.Base(strings.TrimSpace(filename))
+	ext := utils.GetFileExtension(filename)
+	switch {
+	case len(data) == 0:
+		return nil, fmt.Errorf("%w: file is empty", ErrInvalidAttachment)
+	case len(data) > MaxAttachmentBytes:
+		return nil, fmt.Errorf("%w: file is larger than %d MB", ErrInvalidAttachment, MaxAttachmentBytes>>20)
+	case !isAllowedAttachment(ext):
+		return nil, fmt.Errorf("%w: only images (jpg, png, gif, bmp, webp) and PDFs are accepted", ErrInvalidAttachment)
+	}
+	contentType, err := sniffAttachment(filename, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if kind == "" {
+		kind = AttachmentOther
+		if utils.IsValidImageExtension(ext) {
+			kind = AttachmentPhoto
+		}
+	}
+	if !utils.Contains([]string{AttachmentTicket, AttachmentReservation, AttachmentPhoto, AttachmentReceipt, AttachmentOther}, kind) {
+		return nil, fmt.Errorf("%w: kind must be ticket, reservation, photo, receipt or other", ErrInvalidAttachment)
+	}
+
+	// Scan the file as uploaded, before it is rewritten
+	status, signature := scanAttachment(ctx, data)
+	quarantined := status == ScanStatusInfected || status == ScanStatusError
+	stripped := false
+	if !quarantined {
+		if data, stripped, err = stripImageMetadata(contentType, data); err != nil {
+			return nil, err
+		}
+		if len(data) > MaxAttachmentBytes {
+			return nil, fmt.Errorf("%w: file is larger than %d MB", ErrInvalidAttachment, MaxAttachmentBytes>>20)
+		}
+	}
+
+	unlock := lockDocument(attachmentsCollection, tripID)
+	defer unlock()
+
+	list, err := loadTripAttachments(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+	if len(list.Attachments) >= MaxAttachmentsPerTrip {
+		return nil, fmt.Errorf("%w: a trip can have at most %d attachments", ErrInvalidAttachment, MaxAttachmentsPerTrip)
+	}
+
+	attachment := Attachment{
+		ID:          uuid.NewString(),
+		TripID:      tripID,
+		Filename:    filename,
+		Kind:        kind,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		UploadedBy:  uploadedBy,
+		UploadedAt:  time.Now().UTC(),
+
+		ScanStatus:       status,
+		ScanSignature:    signature,
+		Quarantined:      quarantined,
+		MetadataStripped: stripped,
+	}
+
+	if err := blobStore().UploadFile(ctx, attachment.objectName(), data, attachment.ContentType); err != nil {
+		return nil, fmt.Errorf("failed to store attachment: %w", err)
+	}
+
+	if !quarantined && strings.HasPrefix(contentType, "image/") {
+		source := ImageSource{ID: attachment.ID, Object: attachment.objectName(), ContentType: contentType, Origin: "attachment"}
+		if err := RegisterImage(ctx, source); err != nil {
+			utils.LogWarning(fmt.Sprintf("Failed to register previews for attachment %s: %v", attachment.ID, err))
+		} else {
+			attachment.Previews = ImagePreviewURLs(attachment.ID)
+		}
+	}
+
+	list.Attachments = append(list.Attachments, attachment)
+	if err := saveDocument(ctx, attachmentsCollection, tripID, list); err != nil {
+		blobStore().DeleteFile(ctx, attachment.objectName())
+		return nil, fmt.Errorf("failed to save attachment list: %w", err)
+	}
+	return &attachment, nil
+}
+
+// loadTripAttachments loads a trip's attachment list, empty when it has none
+func loadTripAttachments(ctx context.Context, tripID string) (*TripAttachments, error) {
+	list := &TripAttachments{TripID: tripID, Attachments: []Attachment{}}
+	if err := loadDocument(ctx, attachmentsCollection, tripID, list); err != nil && !errors.Is(err, ErrDocumentNotFound) {
+		return nil, err
+	}
+	return list, nil
+}
+
+// ListAttachments lists a trip's attachments, newest first
+func ListAttachments(ctx context.Context, tripID string) ([]Attachment, error) {
+	list, err := loadTripAttachments(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+	attachments := list.Attachments
+	sort.SliceStable(attachments, func(i, j int) bool {
+		return attachments[i].UploadedAt.After(attachments[j].UploadedAt)
+	})
+	return attachments, nil
+}
+
+// GetAttachment finds one attachment on a trip
+func GetAttachment(ctx context.Context, tripID, id string) (*Attachment, error) {
+	list, err := loadTripAttachments(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+	for _, attachment := range list.Attachments {
+		if attachment.ID == id {
+			return &attachment, nil
+		}
+	}
+	return nil, fmt.Errorf("attachment %s on trip %s: %w", id, tripID, ErrDocumentNotFound)
+}
+
+// OpenAttachment opens an attachment's file as a seekable stream. Quarantined files
+// return ErrAttachmentQuarantined.
+func OpenAttachment(ctx context.Context, tripID, id string) (*Attachment, io.ReadSeekCloser, error) {
+	attachment, err := GetAttachment(ctx, tripID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if attachment.Quarantined {
+		return attachment, nil, ErrAttachmentQuarantined
+	}
+	store := blobStore()
+	info, err := store.GetFileInfo(ctx, attachment.objectName())
+	if err != nil {
+		return nil, nil, fmt.Errorf("attachment file %s: %w", attachment.objectName(), ErrDocumentNotFound)
+	}
+	return attachment, &objectReader{ctx: ctx, store: store, object: attachment.objectName(), size: info.Size}, nil
+}
+
+// DeleteAttachment removes an attachment and its file
+func DeleteAttachment(ctx context.Context, tripID, id string) (*Attachment, error) {
+	unlock := lockDocument(attachmentsCollection, tripID)
+	defer unlock()
+
+	list, err := loadTripAttachments(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+	for i, attachment := range list.Attachments {
+		if attachment.ID != id {
+			continue
+		}
+		list.Attachments = append(list.Attachments[:i], list.Attachments[i+1:]...)
+		if err := saveDocument(ctx, attachmentsCollection, tripID, list); err != nil {
+			return nil, fmt.Errorf("failed to save attachment list: %w", err)
+		}
+		if err := blobStore().DeleteFile(ctx, attachment.objectName()); err != nil {
+			utils.LogWarning(fmt.Sprintf("Failed to delete attachment file %s: %v", attachment.objectName(), err))
+		}
+		if attachment.Previews != nil {
+			UnregisterImage(ctx, attachment.ID)
+		}
+		return &attachment, nil
+	}
+	return nil, fmt.Errorf("attachment %s on trip %s: %w", id, tripID, ErrDocumentNotFound)
+}
+
+// WriteOfflineZip writes the offline bundle as bundle.json, plus the trip's attachments
+// under attachments/ when tripID is set; quarantined files are left out
+func WriteOfflineZip(ctx context.Context, w io.Writer, bundle *OfflineBundle, tripID string) error {
+	archive := zip.NewWriter(w)
+
+	entry, err := archive.Create("bundle.json")
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(entry)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(bundle); err != nil {
+		return err
+	}
+
+	if tripID != "" {
+		attachments, err := ListAttachments(ctx, tripID)
+		if err != nil {
+			return err
+		}
+		store := blobStore()
+		names := make(map[string]bool)
+		for _, attachment := range attachments {
+			if attachment.Quarantined {
+				continue
+			}
+			reader, err := store.NewRangeReader(ctx, attachment.objectName(), 0, -1)
+			if err != nil {
+				utils.LogWarning(fmt.Sprintf("Skipping attachment %s in offline bundle: %v", attachment.ID, err))
+				continue
+			}
+			// Images and PDFs are already compressed
+			header := &zip.FileHeader{Name: zipEntryName(names, attachment), Method: zip.Store, Modified: attachment.UploadedAt}
+			entry, err := archive.CreateHeader(header)
+			if err != nil {
+				reader.Close()
+				return err
+			}
+			_, err = io.Copy(entry, reader)
+			reader.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return archive.Close()
+}
+
+// zipEntryName places an attachment under attachments/, numbering repeated file names
+func zipEntryName(used map[string]bool, attachment Attachment) string {
+	name := "attachments/" + attachment.Filename
+	if used[name] {
+		ext := filepath.Ext(attachment.Filename)
+		base := strings.TrimSuffix(attachment.Filename, ext)
+		for n := 2; used[name]; n++ {
+			name = fmt.Sprintf("attachments/%s (%d)%s", base, n, ext)
+		}
+	}
+	used[name] = true
+	return name
+}
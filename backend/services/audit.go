@@ -0,0 +1,202 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/joshndala/cantrip/utils"
+)
+
+// Audit actions
+const (
+	AuditActionCreate  = "create"
+	AuditActionUpdate  = "update"
+	AuditActionDelete  = "delete"
+	AuditActionRestore = "restore"
+	AuditActionPurge   = "purge"
+)
+
+// AuditLogFile is the append-only audit store
+const AuditLogFile = "data/audit/audit.jsonl"
+
+// AuditEntry records a single data-modifying operation
+type AuditEntry struct {
+	ID           string                 `json:"id"`
+	Timestamp    time.Time              `json:"timestamp"`
+	Actor        string                 `json:"actor"`
+	Action       string                 `json:"action"`        // create, update, delete, restore, purge
+	ResourceType string                 `json:"resource_type"` // itinerary, packing, pdf
+	ResourceID   string                 `json:"resource_id"`
+	Changes      map[string]FieldChange `json:"changes,omitempty"`
+}
+
+// FieldChange describes the before and after value of a top-level field
+type FieldChange struct {
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// AuditFilter narrows down audit log queries
+type AuditFilter struct {
+	Actor        string
+	Action       string
+	ResourceType string
+	ResourceID   string
+	Since        time.Time // entries at or after
+	Until        time.Time // entries before
+}
+
+// auditMu serializes appends so entries are never interleaved
+var auditMu sync.Mutex
+
+// RecordAudit appends an entry to the audit log
+func RecordAudit(ctx context.Context, actor, action, resourceType, resourceID string, before, after interface{}) error {
+	entry := AuditEntry{
+		ID:           utils.GenerateID(),
+		Timestamp:    time.Now().UTC(),
+		Actor:        actor,
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Changes:      DiffFields(before, after),
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(tenantDataFile(ctx, AuditLogFile)), 0755); err != nil {
+		return fmt.Errorf("failed to create audit directory: %w", err)
+	}
+
+	// Open in append-only mode; existing entries are never rewritten
+	file, err := os.OpenFile(tenantDataFile(ctx, AuditLogFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// QueryAuditLog returns matching audit entries, newest first
+func QueryAuditLog(ctx context.Context, filter AuditFilter) ([]AuditEntry, error) {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	file, err := os.Open(tenantDataFile(ctx, AuditLogFile))
+	if os.IsNotExist(err) {
+		return []AuditEntry{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if filter.matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	// Reverse so the newest entries come first
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+
+	if entries == nil {
+		entries = []AuditEntry{}
+	}
+	return entries, nil
+}
+
+// matches reports whether an entry satisfies the filter
+func (f AuditFilter) matches(entry AuditEntry) bool {
+	if f.Actor != "" && entry.Actor != f.Actor {
+		return false
+	}
+	if f.Action != "" && entry.Action != f.Action {
+		return false
+	}
+	if f.ResourceType != "" && entry.ResourceType != f.ResourceType {
+		return false
+	}
+	if f.ResourceID != "" && entry.ResourceID != f.ResourceID {
+		return false
+	}
+	if !f.Since.IsZero() && entry.Timestamp.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !entry.Timestamp.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// DiffFields compares two values field by field (after a JSON round trip)
+// and returns the top-level fields that differ
+func DiffFields(before, after interface{}) map[string]FieldChange {
+	beforeMap := toFieldMap(before)
+	afterMap := toFieldMap(after)
+
+	changes := make(map[string]FieldChange)
+	for key, afterValue := range afterMap {
+		beforeValue, exists := beforeMap[key]
+		if !exists || !reflect.DeepEqual(beforeValue, afterValue) {
+			changes[key] = FieldChange{Before: beforeValue, After: afterValue}
+		}
+	}
+	for key, beforeValue := range beforeMap {
+		if _, exists := afterMap[key]; !exists {
+			changes[key] = FieldChange{Before: beforeValue}
+		}
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+	return changes
+}
+
+// toFieldMap converts a value to a generic map of its JSON fields
+func toFieldMap(v interface{}) map[string]interface{} {
+	if v == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil
+	}
+	return fields
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Kinds of autocomplete suggestions
+const (
+	CompletionCity       = "city"
+	CompletionAttraction = "attraction"
+)
+
+// Autocomplete limits, small enough to send on every keystroke
+const (
+	DefaultCompletions = 8
+	MaxCompletions     = 20
+	MinCompletionQuery = 2 // shorter queries complete to nothing rather than to everything
+)
+
+// ErrInvalidCompletionQuery is returned for an unknown completion type
+var ErrInvalidCompletionQuery = errors.New("invalid autocomplete query")
+
+// Completion is a city or attraction name matching what the traveler has typed so far
+type Completion struct {
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	City     string `json:"city,omitempty"` // the attraction's city
+	Province string `json:"province"`
+}
+
+// completionEntry is a name in the autocomplete index with its folded forms
+type completionEntry struct {
+	Completion
+	key        string   // folded name
+	words      []string // folded words of the name
+	population int      // of the city, to rank bigger places first
+}
+
+// buildCompletions indexes the city and attraction names of metadata, dropping repeats
+// of a name within a city
+func buildCompletions(metadata *CityMetadata) []completionEntry {
+	var entries []completionEntry
+	seen := map[string]bool{}
+	add := func(completion Completion, population int) {
+		key := NormalizeCityName(completion.Name)
+		if key == "" {
+			return
+		}
+		id := completion.Type + "|" + key + "|" + NormalizeCityName(completion.City)
+		if seen[id] {
+			return
+		}
+		seen[id] = true
+		entries = append(entries, completionEntry{Completion: completion, key: key, words: strings.Fields(key), population: population})
+	}
+
+	for _, city := range metadata.Cities {
+		add(Completion{Type: CompletionCity, Name: city.Name, Province: city.Province}, city.Population)
+	}
+	for _, city := range metadata.Cities {
+		for _, attraction := range city.Attractions {
+			add(Completion{Type: CompletionAttraction, Name: attraction, City: city.Name, Province: city.Province}, city.Population)
+		}
+	}
+	return entries
+}
+
+// Autocomplete ranks the city and attraction names matching a partial query: exact
+// names, then names starting with it, then names with a word starting with it, then
+// names a typo away. Ties go to cities, then to bigger places. An empty kind completes
+// both.
+func Autocomplete(ctx context.Context, query, kind string, limit int) ([]Completion, error) {
+	if kind != "" && kind != CompletionCity && kind != CompletionAttraction {
+		return nil, fmt.Errorf("%w: type must be %s or %s", ErrInvalidCompletionQuery, CompletionCity, CompletionAttraction)
+	}
+	key := NormalizeCityName(query)
+	if len([]rune(key)) < MinCompletionQuery {
+		return []Completion{}, nil
+	}
+	index, err := GetCityIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	type ranked struct {
+		entry *completionEntry
+		rank  int
+	}
+	var matches []ranked
+	for i := range index.completions {
+		entry := &index.completions[i]
+		if kind != "" && entry.Type != kind {
+			continue
+		}
+		if rank, ok := completionRank(entry, key); ok {
+			matches = append(matches, ranked{entry, rank})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.rank != b.rank {
+			return a.rank < b.rank
+		}
+		if a.entry.Type != b.entry.Type {
+			return a.entry.Type == CompletionCity
+		}
+		if a.entry.population != b.entry.population {
+			return a.entry.population > b.entry.population
+		}
+		return len(a.entry.key) < len(b.entry.key)
+	})
+
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	completions := make([]Completion, 0, len(matches))
+	for _, match := range matches {
+		completions = append(completions, match.entry.Completion)
+	}
+	return completions, nil
+}
+
+// completionRank scores how well a folded query matches an entry, lower is better
+func completionRank(entry *completionEntry, key string) (int, bool) {
+	switch {
+	case entry.key == key:
+		return 0, true
+	case strings.HasPrefix(entry.key, key):
+		return 1, true
+	}
+	for _, word := range entry.words {
+		if strings.HasPrefix(word, key) {
+			return 2, true
+		}
+	}
+
+	// One typo is forgiven once there's enough typed to tell names apart
+	typed := len([]rune(key))
+	if typed < 4 {
+		return 0, false
+	}
+	for _, candidate := range append([]string{entry.key}, entry.words...) {
+		if runes := []rune(candidate); len(runes) >= typed && editDistance(key, string(runes[:typed])) <= 1 {
+			return 3, true
+		}
+	}
+	return 0, false
+}
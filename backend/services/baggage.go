@@ -0,0 +1,326 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// ErrUnknownAirline is returned for airlines or fare classes missing from the baggage dataset
+var ErrUnknownAirline = errors.New("unknown airline or fare class")
+
+// carryOnPackingEfficiency is the share of a bag's volume usable once items are packed
+const carryOnPackingEfficiency = 0.8
+
+// BaggagePolicies represents the structure of airline_baggage.json
+type BaggagePolicies struct {
+	Note          string                   `json:"note"`
+	Currency      string                   `json:"currency"`
+	Airlines      map[string]AirlinePolicy `json:"airlines"`
+	ItemEstimates struct {
+		Default ItemEstimate   `json:"default"`
+		Items   []ItemEstimate `json:"items"`
+	} `json:"item_estimates"`
+}
+
+// AirlinePolicy is a carrier's bag size and weight limits and its fare classes
+type AirlinePolicy struct {
+	Name         string               `json:"name"`
+	CarryOn      BagLimit             `json:"carry_on"`
+	PersonalItem BagLimit             `json:"personal_item"`
+	Checked      CheckedBagLimit      `json:"checked"`
+	FareClasses  map[string]FareClass `json:"fare_classes"`
+}
+
+// BagLimit is a cabin bag's maximum dimensions and weight (0 when not weighed)
+type BagLimit struct {
+	DimensionsCM []float64 `json:"dimensions_cm"`
+	MaxWeightKG  float64   `json:"max_weight_kg"`
+}
+
+// CheckedBagLimit is a checked bag's limits and the fees for exceeding them
+type CheckedBagLimit struct {
+	MaxLinearCM       float64 `json:"max_linear_cm"`
+	MaxWeightKG       float64 `json:"max_weight_kg"`
+	OverweightLimitKG float64 `json:"overweight_limit_kg"` // heaviest bag accepted with a fee
+	OverweightFee     float64 `json:"overweight_fee"`
+	OversizeFee       float64 `json:"oversize_fee"`
+}
+
+// FareClass is the baggage included with a fare
+type FareClass struct {
+	Name            string    `json:"name"`
+	Description     string    `json:"description"`
+	CarryOn         bool      `json:"carry_on"`
+	PersonalItem    bool      `json:"personal_item"`
+	CheckedIncluded int       `json:"checked_included"`
+	CheckedFees     []float64 `json:"checked_fees"` // fee for each additional bag, the last repeating
+}
+
+// ItemEstimate is the typical packed weight and volume of items matching the keywords
+type ItemEstimate struct {
+	Keywords []string `json:"keywords,omitempty"`
+	WeightKG float64  `json:"weight_kg"`
+	VolumeL  float64  `json:"volume_l"`
+}
+
+// BaggageCheck compares a packing list's estimated weight and volume with a fare's allowance
+type BaggageCheck struct {
+	PackingListID string            `json:"packing_list_id"`
+	Airline       string            `json:"airline"`
+	FareClass     string            `json:"fare_class"`
+	Currency      string            `json:"currency"`
+	Travelers     []TravelerBaggage `json:"travelers"`
+	EstimatedFees float64           `json:"estimated_fees"`
+	Compliant     bool              `json:"compliant"` // everything fits the included allowance
+	Warnings      []string          `json:"warnings"`
+	Note          string            `json:"note"`
+}
+
+// TravelerBaggage is one traveler's estimated load and bag plan
+type TravelerBaggage struct {
+	Name            string      `json:"name,omitempty"`
+	WeightKG        float64     `json:"weight_kg"`
+	VolumeL         float64     `json:"volume_l"`
+	CabinCapacityKG float64     `json:"cabin_capacity_kg"`
+	CabinCapacityL  float64     `json:"cabin_capacity_l"`
+	CheckedBags     int         `json:"checked_bags"`
+	CheckedWeights  []float64   `json:"checked_weights_kg,omitempty"`
+	Fees            []BagCharge `json:"fees,omitempty"`
+}
+
+// BagCharge is a fee the traveler is likely to pay
+type BagCharge struct {
+	Reason string  `json:"reason"`
+	Amount float64 `json:"amount"`
+}
+
+// loadBaggagePolicies loads the airline baggage dataset
+func loadBaggagePolicies(ctx context.Context) (*BaggagePolicies, error) {
+	data, err := ReadDataset(ctx, AirlineBaggageDataset)
+	if err != nil {
+		return nil, err
+	}
+
+	var policies BaggagePolicies
+	if err := json.Unmarshal(data, &policies); err != nil {
+		return nil, err
+	}
+	return &policies, nil
+}
+
+// ListBaggagePolicies returns every airline's baggage policy, keyed by airline code
+func ListBaggagePolicies(ctx context.Context) (*BaggagePolicies, error) {
+	return loadBaggagePolicies(ctx)
+}
+
+// CheckBaggage estimates the weight and volume of a packing list and compares it with an
+// airline fare class. Group lists are checked per traveler with shared items split evenly.
+func CheckBaggage(ctx context.Context, packingList PackingResponse, airline, fareClass string) (*BaggageCheck, error) {
+	policies, err := loadBaggagePolicies(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load baggage policies: %w", err)
+	}
+
+	airline, fareClass = strings.ToLower(airline), strings.ToLower(fareClass)
+	policy, ok := policies.Airlines[airline]
+	if !ok {
+		return nil, fmt.Errorf("%w: airline %q", ErrUnknownAirline, airline)
+	}
+	fare, ok := policy.FareClasses[fareClass]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s has no fare class %q", ErrUnknownAirline, policy.Name, fareClass)
+	}
+
+	check := &BaggageCheck{
+		PackingListID: packingList.ID,
+		Airline:       policy.Name,
+		FareClass:     fare.Name,
+		Currency:      policies.Currency,
+		Travelers:     []TravelerBaggage{},
+		Compliant:     true,
+		Warnings:      []string{},
+		Note:          policies.Note,
+	}
+
+	shared, err := packingCategories(packingList)
+	if err != nil {
+		return nil, err
+	}
+	sharedWeight, sharedVolume := estimatePackingLoad(policies, shared)
+
+	type load struct {
+		name           string
+		weight, volume float64
+	}
+	var loads []load
+	if len(packingList.Travelers) == 0 {
+		loads = []load{{weight: sharedWeight, volume: sharedVolume}}
+	} else {
+		n := float64(len(packingList.Travelers))
+		for _, traveler := range packingList.Travelers {
+			weight, volume := estimatePackingLoad(policies, traveler.Categories)
+			loads = append(loads, load{traveler.Name, weight + sharedWeight/n, volume + sharedVolume/n})
+		}
+	}
+
+	for _, l := range loads {
+		result := planBags(policy, fare, l.weight, l.volume)
+		result.Name = l.name
+		for _, fee := range result.Fees {
+			check.EstimatedFees += fee.Amount
+		}
+		if len(result.Fees) > 0 {
+			check.Compliant = false
+		}
+		check.Warnings = append(check.Warnings, baggageWarnings(policy, fare, result)...)
+		check.Travelers = append(check.Travelers, result)
+	}
+	check.EstimatedFees = roundCost(check.EstimatedFees)
+
+	return check, nil
+}
+
+// planBags fills the cabin allowance first and checks the rest, adding bags as needed
+func planBags(policy AirlinePolicy, fare FareClass, weight, volume float64) TravelerBaggage {
+	result := TravelerBaggage{WeightKG: roundCost(weight), VolumeL: roundCost(volume)}
+
+	cabinWeight, cabinVolume := 0.0, 0.0
+	if fare.PersonalItem {
+		cabinVolume += bagVolume(policy.PersonalItem.DimensionsCM)
+		cabinWeight += cabinWeightLimit(policy.PersonalItem, policy.CarryOn)
+	}
+	if fare.CarryOn {
+		cabinVolume += bagVolume(policy.CarryOn.DimensionsCM)
+		cabinWeight += cabinWeightLimit(policy.CarryOn, policy.CarryOn)
+	}
+	result.CabinCapacityKG = roundCost(cabinWeight)
+	result.CabinCapacityL = roundCost(cabinVolume)
+
+	// Whatever the cabin bags can't take by weight or volume goes in the hold
+	overflow := math.Max(0, weight-cabinWeight)
+	if volume > cabinVolume {
+		overflow = math.Max(overflow, weight*(volume-cabinVolume)/volume)
+	}
+	if overflow <= 0 {
+		return result
+	}
+
+	limit := policy.Checked.OverweightLimitKG
+	if limit <= 0 {
+		limit = policy.Checked.MaxWeightKG
+	}
+	result.CheckedBags = int(math.Ceil(overflow / limit))
+	perBag := overflow / float64(result.CheckedBags)
+	// Prefer one more bag over paying overweight on each
+	if perBag > policy.Checked.MaxWeightKG {
+		extraFee := checkedBagFee(fare, result.CheckedBags+1)
+		if extraFee <= policy.Checked.OverweightFee*float64(result.CheckedBags) {
+			result.CheckedBags++
+			perBag = overflow / float64(result.CheckedBags)
+		}
+	}
+
+	for i := 1; i <= result.CheckedBags; i++ {
+		result.CheckedWeights = append(result.CheckedWeights, roundCost(perBag))
+		if fee := checkedBagFee(fare, i); fee > 0 {
+			result.Fees = append(result.Fees, BagCharge{Reason: fmt.Sprintf("Checked bag %d", i), Amount: fee})
+		}
+		if perBag > policy.Checked.MaxWeightKG {
+			result.Fees = append(result.Fees, BagCharge{Reason: fmt.Sprintf("Checked bag %d over %.0f kg", i, policy.Checked.MaxWeightKG), Amount: policy.Checked.OverweightFee})
+		}
+	}
+	return result
+}
+
+// checkedBagFee is the fee for the nth checked bag on a fare
+func checkedBagFee(fare FareClass, n int) float64 {
+	if n <= fare.CheckedIncluded {
+		return 0
+	}
+	if len(fare.CheckedFees) == 0 {
+		return 0
+	}
+	i := min(n-fare.CheckedIncluded-1, len(fare.CheckedFees)-1)
+	return fare.CheckedFees[i]
+}
+
+// baggageWarnings explains a traveler's likely fees in plain words
+func baggageWarnings(policy AirlinePolicy, fare FareClass, result TravelerBaggage) []string {
+	var warnings []string
+	who := "Your items"
+	if result.Name != "" {
+		who = result.Name + "'s items"
+	}
+	if result.CheckedBags > fare.CheckedIncluded {
+		warnings = append(warnings, fmt.Sprintf("%s (about %.1f kg, %.0f L) won't fit in the %s cabin allowance; plan on %d checked bag(s), %d included",
+			who, result.WeightKG, result.VolumeL, fare.Name, result.CheckedBags, fare.CheckedIncluded))
+	}
+	for i, weight := range result.CheckedWeights {
+		if weight > policy.Checked.MaxWeightKG {
+			warnings = append(warnings, fmt.Sprintf("%s: checked bag %d is likely overweight (%.1f kg of %.0f kg)", who, i+1, weight, policy.Checked.MaxWeightKG))
+		}
+	}
+	if !fare.CarryOn {
+		warnings = append(warnings, fmt.Sprintf("%s includes a personal item only; a full-size carry-on will be charged at the gate", fare.Name))
+	}
+	return warnings
+}
+
+// estimatePackingLoad totals the estimated weight and volume of every item
+func estimatePackingLoad(policies *BaggagePolicies, categories []PackingCategory) (float64, float64) {
+	weight, volume := 0.0, 0.0
+	for _, category := range categories {
+		for _, item := range category.Items {
+			estimate := estimateItem(policies, item.Name)
+			weight += estimate.WeightKG * float64(item.Quantity)
+			volume += estimate.VolumeL * float64(item.Quantity)
+		}
+	}
+	return weight, volume
+}
+
+// estimateItem finds the first estimate whose keyword appears as a word in the item name
+func estimateItem(policies *BaggagePolicies, name string) ItemEstimate {
+	for _, estimate := range policies.ItemEstimates.Items {
+		if itemMatches(name, estimate.Keywords) {
+			return estimate
+		}
+	}
+	return policies.ItemEstimates.Default
+}
+
+// itemMatches reports whether an item name contains any keyword as whole words,
+// allowing a plural "s" ("Medications" matches "medication")
+func itemMatches(name string, keywords []string) bool {
+	words := " " + strings.Join(strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-'
+	}), " ") + " "
+	for _, keyword := range keywords {
+		keyword = strings.ToLower(keyword)
+		if strings.Contains(words, " "+keyword+" ") || strings.Contains(words, " "+keyword+"s ") {
+			return true
+		}
+	}
+	return false
+}
+
+// bagVolume is a bag's usable volume in liters
+func bagVolume(dimensions []float64) float64 {
+	if len(dimensions) != 3 {
+		return 0
+	}
+	return dimensions[0] * dimensions[1] * dimensions[2] / 1000 * carryOnPackingEfficiency
+}
+
+// cabinWeightLimit falls back to the carry-on limit for bags the airline doesn't weigh
+func cabinWeightLimit(bag, carryOn BagLimit) float64 {
+	if bag.MaxWeightKG > 0 {
+		return bag.MaxWeightKG
+	}
+	return carryOn.MaxWeightKG / 2
+}
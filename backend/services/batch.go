@@ -0,0 +1,72 @@
+package services
+
+import "sync"
+
+// Batch processing limits
+const (
+	MaxBatchSize        = 50
+	DefaultBatchWorkers = 4
+)
+
+// Batch item statuses
+const (
+	BatchStatusOK    = "ok"
+	BatchStatusError = "error"
+)
+
+// BatchResult is the outcome of a single item in a batch request
+type BatchResult struct {
+	Index  int         `json:"index"`
+	ID     string      `json:"id,omitempty"`
+	Status string      `json:"status"` // ok, error
+	Result interface{} `json:"result,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// RunBatch processes count items with a bounded pool of workers.
+// Results are returned in input order regardless of completion order.
+func RunBatch(count, workers int, process func(index int) BatchResult) []BatchResult {
+	if workers <= 0 {
+		workers = DefaultBatchWorkers
+	}
+	if workers > count {
+		workers = count
+	}
+
+	results := make([]BatchResult, count)
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				result := process(i)
+				result.Index = i
+				results[i] = result
+			}
+		}()
+	}
+
+	for i := 0; i < count; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
+
+// BatchSummary counts successes and failures in a batch
+func BatchSummary(results []BatchResult) map[string]int {
+	summary := map[string]int{"total": len(results), "succeeded": 0, "failed": 0}
+	for _, result := range results {
+		if result.Status == BatchStatusOK {
+			summary["succeeded"]++
+		} else {
+			summary["failed"]++
+		}
+	}
+	return summary
+}
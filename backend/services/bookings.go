@@ -0,0 +1,586 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joshndala/cantrip/models"
+)
+
+// Booking types
+const (
+	BookingFlight = "flight"
+	BookingHotel  = "hotel"
+)
+
+// Booking import sources
+const (
+	BookingSourceICS  = "ics"
+	BookingSourcePDF  = "pdf"
+	BookingSourceText = "text"
+)
+
+// Buffers kept free around flights when planning activities
+const (
+	flightBufferBefore = 2 * time.Hour
+	flightBufferAfter  = time.Hour
+)
+
+// Default hotel times when a confirmation only gives dates
+const (
+	defaultCheckIn  = "15:00"
+	defaultCheckOut = "11:00"
+)
+
+// ErrNoBookingsFound is returned when an import contains no recognizable flights or hotels
+var ErrNoBookingsFound = errors.New("no flight or hotel bookings found")
+
+// Booking is a flight or hotel reservation the itinerary must plan around
+type Booking = models.Booking
+
+// ParseBookingsRequest asks the agent to extract bookings from confirmation text
+type ParseBookingsRequest struct {
+	Text     string `json:"text"`
+	Source   string `json:"source"`
+	City     string `json:"city,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// ParseBookingsResponse is the agent's extraction result
+type ParseBookingsResponse struct {
+	Bookings []Booking `json:"bookings"`
+}
+
+// BookingImportResult reports what an import added to an itinerary
+type BookingImportResult struct {
+	Imported  []Booking          `json:"imported"`
+	Skipped   int                `json:"skipped"` // already present
+	Itinerary *ItineraryResponse `json:"itinerary"`
+}
+
+// ImportBookings parses booking confirmations and adds them to an itinerary as fixed anchors.
+// Calendar files are parsed locally; PDFs and pasted text are read by the agent.
+func ImportBookings(ctx context.Context, itineraryID, filename string, data []byte, text string) (*BookingImportResult, error) {
+	itinerary, err := GetItinerary(ctx, itineraryID)
+	if err != nil {
+		return nil, err
+	}
+
+	city, _, _, _ := itineraryTripDates(itinerary)
+	location := cityLocation(ctx, city)
+
+	var bookings []Booking
+	switch source := bookingSource(filename, data, text); source {
+	case BookingSourceICS:
+		bookings, err = parseICSBookings(data, location)
+	case BookingSourcePDF:
+		extracted := extractPDFText(data)
+		if strings.TrimSpace(extracted) == "" {
+			return nil, fmt.Errorf("could not read text from PDF: %w", ErrNoBookingsFound)
+		}
+		bookings, err = ParseBookingText(ctx, extracted, BookingSourcePDF, city)
+	default:
+		if text == "" {
+			text = string(data)
+		}
+		bookings, err = ParseBookingText(ctx, text, BookingSourceText, city)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(bookings) == 0 {
+		return nil, ErrNoBookingsFound
+	}
+
+	result := &BookingImportResult{Imported: []Booking{}}
+	existing := map[string]bool{}
+	for _, anchor := range itinerary.Anchors {
+		existing[anchor.ID] = true
+	}
+	for _, booking := range bookings {
+		booking.ID = bookingID(booking)
+		if existing[booking.ID] {
+			result.Skipped++
+			continue
+		}
+		existing[booking.ID] = true
+		itinerary.Anchors = append(itinerary.Anchors, booking)
+		result.Imported = append(result.Imported, booking)
+	}
+
+	ApplyBookingAnchors(ctx, itinerary)
+	if err := SaveItineraryIfRevision(ctx, itinerary, itinerary.Revision); err != nil {
+		return nil, fmt.Errorf("failed to save itinerary: %w", err)
+	}
+
+	result.Itinerary = itinerary
+	return result, nil
+}
+
+// ParseBookingText asks the agent to extract flights and hotels from confirmation text
+func ParseBookingText(ctx context.Context, text, source, city string) ([]Booking, error) {
+	req := ParseBookingsRequest{Text: text, Source: source, City: city, Timezone: calendarTimezone(ctx, city)}
+
+	var result ParseBookingsResponse
+	if err := GetAIClient().transport.Call(ctx, AgentMethodParseBookings, req, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse bookings: %w", err)
+	}
+
+	bookings := make([]Booking, 0, len(result.Bookings))
+	for _, booking := range result.Bookings {
+		if booking.Start.IsZero() || (booking.Type != BookingFlight && booking.Type != BookingHotel) {
+			continue
+		}
+		booking.Source = source
+		bookings = append(bookings, booking)
+	}
+	return bookings, nil
+}
+
+// ApplyBookingAnchors inserts an itinerary's bookings as fixed activities and drops
+// planned activities that overlap them. It is safe to call repeatedly.
+func ApplyBookingAnchors(ctx context.Context, resp *ItineraryResponse) {
+	if resp == nil || resp.Itinerary == nil || len(resp.Anchors) == 0 {
+		return
+	}
+
+	city, _, _, _ := itineraryTripDates(resp)
+	location := cityLocation(ctx, city)
+
+	days, _ := resp.Itinerary["days"].([]interface{})
+	for _, rawDay := range days {
+		day, ok := rawDay.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		date := fmt.Sprint(day["date"])
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			continue
+		}
+
+		rawActivities, _ := day["activities"].([]interface{})
+		fixed, blocked := anchorsForDay(resp.Anchors, date, location)
+		if len(fixed) == 0 && len(blocked) == 0 {
+			continue
+		}
+
+		activities := make([]interface{}, 0, len(rawActivities)+len(fixed))
+		dropped := 0
+		for _, raw := range rawActivities {
+			activity, ok := raw.(map[string]interface{})
+			if !ok {
+				activities = append(activities, raw)
+				continue
+			}
+			// Anchors from an earlier pass are rebuilt below
+			if isFixed, _ := activity["fixed"].(bool); isFixed {
+				continue
+			}
+			start, _ := activity["start_time"].(string)
+			end, _ := activity["end_time"].(string)
+			if overlapsBlocked(start, end, blocked) {
+				dropped++
+				continue
+			}
+			activities = append(activities, activity)
+		}
+		for _, anchor := range fixed {
+			activities = append(activities, anchor)
+		}
+
+		sort.SliceStable(activities, func(i, j int) bool {
+			a, _ := activities[i].(map[string]interface{})
+			b, _ := activities[j].(map[string]interface{})
+			return fmt.Sprint(a["start_time"]) < fmt.Sprint(b["start_time"])
+		})
+		day["activities"] = activities
+		if dropped > 0 {
+			day["anchor_note"] = fmt.Sprintf("%d activities removed to make room for bookings", dropped)
+		}
+	}
+}
+
+// clockRange is a blocked HH:MM window within a day
+type clockRange struct{ start, end string }
+
+// anchorsForDay returns the fixed activities and blocked windows bookings create on a date
+func anchorsForDay(anchors []Booking, date string, location *time.Location) ([]map[string]interface{}, []clockRange) {
+	var fixed []map[string]interface{}
+	var blocked []clockRange
+
+	for _, booking := range anchors {
+		start, end := booking.Start.In(location), booking.End.In(location)
+		if end.Before(start) {
+			end = start
+		}
+
+		switch booking.Type {
+		case BookingFlight:
+			if start.Format("2006-01-02") == date {
+				fixed = append(fixed, anchorActivity(booking, "Flight "+flightLabel(booking), start, clampToDay(start, end)))
+				blocked = append(blocked, dayWindow(start.Add(-flightBufferBefore), end.Add(flightBufferAfter), date, location))
+			} else if end.Format("2006-01-02") == date {
+				// Overnight flight landing on this day
+				blocked = append(blocked, dayWindow(end, end.Add(flightBufferAfter), date, location))
+			}
+		case BookingHotel:
+			if start.Format("2006-01-02") == date {
+				fixed = append(fixed, anchorActivity(booking, "Check in: "+booking.Title, start, start.Add(30*time.Minute)))
+				blocked = append(blocked, clockRange{start.Format("15:04"), start.Add(30 * time.Minute).Format("15:04")})
+			}
+			if end.Format("2006-01-02") == date && !end.Equal(start) {
+				fixed = append(fixed, anchorActivity(booking, "Check out: "+booking.Title, end.Add(-30*time.Minute), end))
+				blocked = append(blocked, clockRange{end.Add(-30 * time.Minute).Format("15:04"), end.Format("15:04")})
+			}
+		}
+	}
+	return fixed, blocked
+}
+
+// anchorActivity builds a fixed itinerary activity for a booking
+func anchorActivity(booking Booking, name string, start, end time.Time) map[string]interface{} {
+	activity := map[string]interface{}{
+		"name":       name,
+		"start_time": start.Format("15:04"),
+		"end_time":   end.Format("15:04"),
+		"category":   booking.Type,
+		"fixed":      true,
+		"booking_id": booking.ID,
+		"cost":       0.0,
+	}
+	if booking.Address != "" {
+		activity["location"] = booking.Address
+	} else if booking.Origin != "" {
+		activity["location"] = booking.Origin
+	}
+	if booking.Confirmation != "" {
+		activity["description"] = "Confirmation " + booking.Confirmation
+	}
+	return activity
+}
+
+// flightLabel describes a flight, e.g. "AC 123 YYZ → YVR"
+func flightLabel(booking Booking) string {
+	label := booking.FlightNumber
+	if label == "" {
+		label = booking.Title
+	}
+	if booking.Origin != "" && booking.Destination != "" {
+		label += fmt.Sprintf(" %s → %s", booking.Origin, booking.Destination)
+	}
+	return strings.TrimSpace(label)
+}
+
+// clampToDay keeps an end time on the same calendar day as start
+func clampToDay(start, end time.Time) time.Time {
+	if end.Format("2006-01-02") != start.Format("2006-01-02") {
+		return time.Date(start.Year(), start.Month(), start.Day(), 23, 59, 0, 0, start.Location())
+	}
+	return end
+}
+
+// dayWindow converts an absolute window to the HH:MM range it covers on a date
+func dayWindow(from, to time.Time, date string, location *time.Location) clockRange {
+	dayStart, _ := time.ParseInLocation("2006-01-02", date, location)
+	dayEnd := dayStart.Add(24*time.Hour - time.Minute)
+	if from.Before(dayStart) {
+		from = dayStart
+	}
+	if to.After(dayEnd) {
+		to = dayEnd
+	}
+	return clockRange{from.Format("15:04"), to.Format("15:04")}
+}
+
+// overlapsBlocked reports whether an HH:MM activity window overlaps any blocked window
+func overlapsBlocked(start, end string, blocked []clockRange) bool {
+	if _, err := time.Parse("15:04", start); err != nil {
+		return false
+	}
+	if _, err := time.Parse("15:04", end); err != nil || end <= start {
+		end = addCalendarHour(start)
+	}
+	for _, window := range blocked {
+		if start < window.end && end > window.start {
+			return true
+		}
+	}
+	return false
+}
+
+// bookingSource picks a parser from the file name, content or pasted text
+func bookingSource(filename string, data []byte, text string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".ics":
+		return BookingSourceICS
+	case ".pdf":
+		return BookingSourcePDF
+	}
+	switch {
+	case bytes.HasPrefix(data, []byte("%PDF")):
+		return BookingSourcePDF
+	case bytes.Contains(data, []byte("BEGIN:VCALENDAR")):
+		return BookingSourceICS
+	}
+	return BookingSourceText
+}
+
+// bookingID derives a stable ID so importing the same confirmation twice is a no-op
+func bookingID(booking Booking) string {
+	key := strings.Join([]string{booking.Type, strings.ToUpper(booking.Confirmation), strings.ToUpper(booking.FlightNumber), booking.Start.UTC().Format(time.RFC3339)}, "|")
+	sum := sha1.Sum([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// cityLocation returns the time zone of a city, defaulting to Toronto
+func cityLocation(ctx context.Context, city string) *time.Location {
+	location, err := time.LoadLocation(calendarTimezone(ctx, city))
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+// Keywords used to classify calendar entries
+var (
+	flightKeywords   = []string{"flight", "departure", "boarding", "airline", "airways", "air canada", "westjet", "porter"}
+	hotelKeywords    = []string{"hotel", "check-in", "check in", "inn", "suites", "resort", "lodge", "airbnb", "hostel", "stay at"}
+	flightNumberExpr = regexp.MustCompile(`\b([A-Z0-9]{2})\s?(\d{1,4})\b`)
+	airportPairExpr  = regexp.MustCompile(`\b([A-Z]{3})\s*(?:-|–|→|to)\s*([A-Z]{3})\b`)
+	confirmationExpr = regexp.MustCompile(`(?i:confirmation|booking|reservation)(?:\s+(?i:number|code|reference|ref|no\.?))?\s*[:#]?\s*([A-Z0-9]{5,12})\b`)
+)
+
+// parseICSBookings reads flights and hotels from VEVENT entries in a calendar file
+func parseICSBookings(data []byte, location *time.Location) ([]Booking, error) {
+	events, err := parseICSEvents(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var bookings []Booking
+	for _, event := range events {
+		summary := event["SUMMARY"].value
+		description := event["DESCRIPTION"].value
+		text := summary + "\n" + description
+		lower := strings.ToLower(text)
+
+		start, err := parseICSTime(event["DTSTART"], location)
+		if err != nil {
+			continue
+		}
+		end, err := parseICSTime(event["DTEND"], location)
+		if err != nil {
+			end = start
+		}
+
+		booking := Booking{Title: summary, Address: event["LOCATION"].value, Start: start, End: end, Source: BookingSourceICS}
+		if match := confirmationExpr.FindStringSubmatch(text); match != nil {
+			booking.Confirmation = match[1]
+		}
+
+		switch {
+		case containsAny(lower, flightKeywords):
+			booking.Type = BookingFlight
+			if match := flightNumberExpr.FindStringSubmatch(summary); match != nil {
+				booking.FlightNumber = match[1] + " " + match[2]
+			}
+			if match := airportPairExpr.FindStringSubmatch(text); match != nil {
+				booking.Origin, booking.Destination = match[1], match[2]
+			}
+			booking.Address = ""
+		case containsAny(lower, hotelKeywords):
+			booking.Type = BookingHotel
+			// All-day hotel entries span check-in to check-out dates
+			if event["DTSTART"].dateOnly {
+				booking.Start = atClock(start, defaultCheckIn, location)
+				booking.End = atClock(end, defaultCheckOut, location)
+			}
+			booking.Title = strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(summary, "Stay at "), "Hotel: "))
+		default:
+			continue
+		}
+		bookings = append(bookings, booking)
+	}
+	return bookings, nil
+}
+
+// icsProperty is a property value with its parameters
+type icsProperty struct {
+	value    string
+	tzid     string
+	dateOnly bool
+}
+
+// parseICSEvents unfolds a calendar file and returns the properties of each VEVENT
+func parseICSEvents(data []byte) ([]map[string]icsProperty, error) {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		// Continuation lines start with a space or tab
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read calendar file: %w", err)
+	}
+
+	var events []map[string]icsProperty
+	var current map[string]icsProperty
+	for _, line := range lines {
+		switch line {
+		case "BEGIN:VEVENT":
+			current = map[string]icsProperty{}
+			continue
+		case "END:VEVENT":
+			if current != nil {
+				events = append(events, current)
+			}
+			current = nil
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		nameAndParams, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		params := strings.Split(nameAndParams, ";")
+		property := icsProperty{value: unescapeICS(value)}
+		for _, param := range params[1:] {
+			key, paramValue, _ := strings.Cut(param, "=")
+			switch strings.ToUpper(key) {
+			case "TZID":
+				property.tzid = paramValue
+			case "VALUE":
+				property.dateOnly = strings.EqualFold(paramValue, "DATE")
+			}
+		}
+		current[strings.ToUpper(params[0])] = property
+	}
+
+	if len(events) == 0 {
+		return nil, ErrNoBookingsFound
+	}
+	return events, nil
+}
+
+// parseICSTime parses DTSTART/DTEND in UTC, TZID or floating form
+func parseICSTime(property icsProperty, fallback *time.Location) (time.Time, error) {
+	value := property.value
+	if value == "" {
+		return time.Time{}, fmt.Errorf("missing time")
+	}
+
+	location := fallback
+	if property.tzid != "" {
+		if loc, err := time.LoadLocation(property.tzid); err == nil {
+			location = loc
+		}
+	}
+
+	switch {
+	case strings.HasSuffix(value, "Z"):
+		return time.Parse("20060102T150405Z", value)
+	case len(value) == 8:
+		return time.ParseInLocation("20060102", value, location)
+	default:
+		return time.ParseInLocation("20060102T150405", value, location)
+	}
+}
+
+// unescapeICS reverses RFC 5545 text escaping
+func unescapeICS(value string) string {
+	replacer := strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
+	return replacer.Replace(value)
+}
+
+// atClock returns the date of t at an HH:MM time
+func atClock(t time.Time, clock string, location *time.Location) time.Time {
+	parsed, _ := time.Parse("15:04", clock)
+	return time.Date(t.Year(), t.Month(), t.Day(), parsed.Hour(), parsed.Minute(), 0, 0, location)
+}
+
+// containsAny reports whether text contains any of the keywords
+func containsAny(text string, keywords []string) bool {
+	for _, keyword := range keywords {
+		if strings.Contains(text, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// PDF text extraction patterns
+var (
+	pdfStreamExpr = regexp.MustCompile(`(?s)stream\r?\n(.*?)\r?\nendstream`)
+	pdfTextExpr   = regexp.MustCompile(`(?s)\[(.*?)\]\s*TJ|\(((?:\\.|[^\\)])*)\)\s*(?:Tj|'|")|T\*|Td|TD`)
+	pdfStringExpr = regexp.MustCompile(`\(((?:\\.|[^\\)])*)\)`)
+)
+
+// extractPDFText pulls the text shown by a PDF's content streams. It handles the plain and
+// Flate-compressed streams that booking confirmation generators produce; scanned PDFs have
+// no text and yield an empty string.
+func extractPDFText(data []byte) string {
+	var text strings.Builder
+	for _, match := range pdfStreamExpr.FindAllSubmatch(data, -1) {
+		content := match[1]
+		if reader, err := zlib.NewReader(bytes.NewReader(content)); err == nil {
+			if inflated, err := io.ReadAll(reader); err == nil {
+				content = inflated
+			}
+			reader.Close()
+		}
+		if !bytes.Contains(content, []byte("BT")) {
+			continue
+		}
+
+		for _, op := range pdfTextExpr.FindAllSubmatch(content, -1) {
+			switch {
+			case op[1] != nil:
+				for _, part := range pdfStringExpr.FindAllSubmatch(op[1], -1) {
+					text.WriteString(unescapePDFString(part[1]))
+				}
+			case op[2] != nil:
+				text.WriteString(unescapePDFString(op[2]))
+			default:
+				// Line and position operators separate words
+				text.WriteString("\n")
+			}
+		}
+		text.WriteString("\n")
+	}
+
+	// Drop the blank lines left by positioning operators
+	var lines []string
+	for _, line := range strings.Split(text.String(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// unescapePDFString reverses backslash escapes in a PDF literal string
+func unescapePDFString(raw []byte) string {
+	replacer := strings.NewReplacer(`\(`, "(", `\)`, ")", `\\`, `\`, `\n`, "\n", `\r`, "", `\t`, " ")
+	return replacer.Replace(string(raw))
+}
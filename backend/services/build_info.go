@@ -0,0 +1,148 @@
+package services
+
+import (
+	"os"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+)
+
+// Build metadata, stamped at link time:
+//
+//	go build -ldflags "-X github.com/joshndala/cantrip/services.BuildCommit=$(git rev-parse HEAD) \
+//	  -X github.com/joshndala/cantrip/services.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// When unset they fall back to the VCS details Go embeds in binaries built from a checkout.
+var (
+	BuildVersion = "1.0.0"
+	BuildCommit  string
+	BuildTime    string
+)
+
+// BuildInfo describes the running deployment. It reports which features and providers
+// are configured, never the keys or URLs that configure them.
+type BuildInfo struct {
+	Version   string            `json:"version"`
+	Commit    string            `json:"commit"`
+	BuildTime string            `json:"build_time"`
+	Modified  bool              `json:"modified"` // built from a checkout with uncommitted changes
+	GoVersion string            `json:"go_version"`
+	Features  map[string]bool   `json:"features"`
+	Providers map[string]string `json:"providers"`
+	Timeouts  map[string]string `json:"timeouts"`
+}
+
+// GetBuildInfo reports the build and its runtime configuration
+func GetBuildInfo() BuildInfo {
+	info := BuildInfo{
+		Version:   BuildVersion,
+		Commit:    BuildCommit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildTime == "" {
+					info.BuildTime = setting.Value
+				}
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+
+	info.Features = map[string]bool{
+		"mock_agent":         os.Getenv("AI_MODE") == AIModeMock,
+		"gcs":                GetGCSClient() != nil,
+		"tracing":            tracingConfigured(),
+		"analytics":          AnalyticsEnabled(),
+		"attachment_scan":    os.Getenv("CLAMAV_ADDRESS") != "",
+		"calendar_sync":      os.Getenv("GOOGLE_CALENDAR_CLIENT_ID") != "" && os.Getenv("GOOGLE_CALENDAR_CLIENT_SECRET") != "",
+		"admin_api":          os.Getenv("ADMIN_API_KEY") != "",
+		"upstream_fixtures":  os.Getenv("UPSTREAM_FIXTURES") != "",
+		"push_notifications": os.Getenv("FCM_PROJECT_ID") != "",
+		"multi_tenant":       MultiTenant(),
+		"past_trips":         PastTripsAllowed(),
+	}
+
+	info.Providers = map[string]string{
+		"weather":      "seasonal",
+		"object_store": ObjectStoreNone,
+		"pdf_renderer": GetPDFRenderer().Name(),
+	}
+	if os.Getenv("WEATHER_API_KEY") != "" {
+		info.Providers["weather"] = "openweathermap"
+	}
+	var events []string
+	for _, provider := range GetEventProviders() {
+		events = append(events, provider.Name())
+	}
+	info.Providers["events"] = firstNonEmpty(strings.Join(events, ","), "sample")
+	if client := GetAIClient(); client.transport != nil {
+		info.Providers["agent"] = client.transport.Name()
+	}
+	if GetGCSClient() != nil {
+		info.Providers["object_store"] = "gcs"
+	} else if GetLocalObjectStore() != nil {
+		info.Providers["object_store"] = ObjectStoreLocal
+	}
+	named := map[string]interface{ Name() string }{}
+	if provider := GetRentalProvider(); provider != nil {
+		named["rentals"] = provider
+	}
+	if provider := GetSpeechToTextProvider(); provider != nil {
+		named["speech_to_text"] = provider
+	}
+	if provider := GetTextToSpeechProvider(); provider != nil {
+		named["text_to_speech"] = provider
+	}
+	if provider := GetModerationProvider(); provider != nil {
+		named["moderation"] = provider
+	}
+	if sink := GetAnalyticsSink(); sink != nil {
+		named["analytics"] = sink
+	}
+	if reporter := GetErrorReporter(); reporter != nil {
+		named["error_reporter"] = reporter
+	}
+	if provider := GetEmbeddingProvider(); provider != nil {
+		named["embeddings"] = provider
+	}
+	if store := GetVectorStore(); store != nil {
+		named["vector_store"] = store
+	}
+	if m := GetMailer(); m != nil {
+		named["email"] = m
+	}
+	if provider := GetOCRProvider(); provider != nil {
+		named["receipt_ocr"] = provider
+	}
+	for kind, provider := range named {
+		info.Providers[kind] = provider.Name()
+	}
+	var channels []string
+	for _, notifier := range registeredNotifiers() {
+		channels = append(channels, notifier.Name())
+	}
+	sort.Strings(channels)
+	info.Providers["notifications"] = strings.Join(channels, ",")
+
+	info.Timeouts = map[string]string{}
+	for upstream := range defaultUpstreamTimeouts {
+		info.Timeouts[upstream] = UpstreamTimeoutFor(upstream).String()
+	}
+	return info
+}
+
+// tracingConfigured reports whether InitTracing exports spans
+func tracingConfigured() bool {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") + os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	return endpoint != "" && os.Getenv("OTEL_SDK_DISABLED") != "true"
+}
@@ -1,16 +1,115 @@
 package services
 
-// GetCachedSuggestions retrieves cached suggestions for a mood and city
-func GetCachedSuggestions(mood, city string) ([]TripSuggestion, error) {
-	// TODO: Implement actual caching logic
-	return []TripSuggestion{
-		{
-			Title:         "Cached Trip",
-			Description:   "A cached trip suggestion for " + city,
-			Activities:    []string{"Cached activity 1", "Cached activity 2"},
-			EstimatedCost: 500.0,
-			Duration:      7,
-			Tags:          []string{mood, city},
-		},
-	}, nil
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// SuggestionCacheTTL is how long generated trip suggestions are reused
+const SuggestionCacheTTL = 10 * time.Minute
+
+// maxCachedSuggestionSets bounds the cache; expired sets are dropped first when it fills
+const maxCachedSuggestionSets = 1000
+
+// SuggestionQuery is the input to GenerateTripSuggestions, minus the weather
+type SuggestionQuery struct {
+	Mood      string
+	City      string
+	Budget    float64
+	Duration  int
+	Interests []string
+	Eco       bool
+}
+
+// cachedSuggestions is one generated set and when it expires
+type cachedSuggestions struct {
+	suggestions []TripSuggestion
+	expires     time.Time
+}
+
+var (
+	suggestionCacheMu sync.Mutex
+	suggestionCache   = map[string]cachedSuggestions{}
+	// suggestionFlight shares one generation between concurrent misses for the same key,
+	// so a popular city isn't regenerated in parallel when its entry expires
+	suggestionFlight singleflight.Group
+)
+
+// GetCachedSuggestions returns trip suggestions for a query, generating them on a miss.
+// Entries are keyed on the exact budget, since cost models are arbitrary expressions of
+// it and a set costed for one budget can't be rescaled to another.
+// The returned suggestions share their activity and tag slices with the cache and must
+// not be modified.
+func GetCachedSuggestions(ctx context.Context, query SuggestionQuery, weather WeatherInfo) ([]TripSuggestion, error) {
+	key := suggestionCacheKey(ctx, query, weather)
+
+	suggestionCacheMu.Lock()
+	entry, ok := suggestionCache[key]
+	suggestionCacheMu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return copySuggestions(entry), nil
+	}
+
+	value, err, _ := suggestionFlight.Do(key, func() (interface{}, error) {
+		suggestions, err := GenerateTripSuggestions(ctx, query.Mood, query.City, query.Budget, query.Duration, query.Interests, weather, query.Eco)
+		if err != nil {
+			return nil, err
+		}
+		entry := cachedSuggestions{suggestions: suggestions, expires: time.Now().Add(SuggestionCacheTTL)}
+		storeSuggestions(key, entry)
+		return entry, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return copySuggestions(value.(cachedSuggestions)), nil
+}
+
+// suggestionCacheKey combines everything that changes the generated suggestions, including
+// the tenant whose cities they come from, the rules they were built with, so edited rules
+// aren't hidden by earlier results, the experiment variants that shaped them and the guides
+// they link to. Weather only matters through whether the outdoor suggestion is offered.
+func suggestionCacheKey(ctx context.Context, query SuggestionQuery, weather WeatherInfo) string {
+	interests := make([]string, 0, len(query.Interests))
+	for _, interest := range query.Interests {
+		interests = append(interests, strings.ToLower(strings.TrimSpace(interest)))
+	}
+	sort.Strings(interests)
+
+	return fmt.Sprintf("%s|%d|%s|%d|%d|%s|%s|%g|%d|%t|%t|%s", TenantFromContext(ctx).ID, getRules(ctx).LoadedAt.UnixNano(), experimentsKey(ctx), guidesVersion(ctx), ratingsVersion(ctx),
+		strings.ToLower(strings.TrimSpace(query.Mood)), NormalizeCityName(query.City), query.Budget,
+		query.Duration, query.Eco, isGoodWeatherForOutdoor(ctx, weather), strings.Join(interests, ","))
+}
+
+// copySuggestions copies a cached set so callers can adjust its suggestions
+func copySuggestions(entry cachedSuggestions) []TripSuggestion {
+	return append([]TripSuggestion(nil), entry.suggestions...)
+}
+
+// storeSuggestions caches a set, making room by dropping expired sets, then an arbitrary one
+func storeSuggestions(key string, entry cachedSuggestions) {
+	suggestionCacheMu.Lock()
+	defer suggestionCacheMu.Unlock()
+
+	if len(suggestionCache) >= maxCachedSuggestionSets {
+		now := time.Now()
+		for k, cached := range suggestionCache {
+			if now.After(cached.expires) {
+				delete(suggestionCache, k)
+			}
+		}
+		for k := range suggestionCache {
+			if len(suggestionCache) < maxCachedSuggestionSets {
+				break
+			}
+			delete(suggestionCache, k)
+		}
+	}
+	suggestionCache[key] = entry
 }
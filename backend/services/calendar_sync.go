@@ -0,0 +1,520 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joshndala/cantrip/utils"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// Storage collections for Google Calendar sync
+const (
+	CalendarConnectionCollection = "calendar_connections"
+	CalendarSyncCollection       = "calendar_syncs"
+)
+
+// calendarStateTTL bounds how long an OAuth consent link stays valid
+const calendarStateTTL = 15 * time.Minute
+
+// ErrCalendarNotConnected is returned when a user has not linked a Google account
+var ErrCalendarNotConnected = errors.New("google calendar is not connected")
+
+// ErrCalendarNotConfigured is returned when the OAuth client is not configured
+var ErrCalendarNotConfigured = errors.New("google calendar integration is not configured")
+
+// CalendarConnection holds a user's Google OAuth token
+type CalendarConnection struct {
+	UserID      string        `json:"user_id"`
+	Token       *oauth2.Token `json:"token"`
+	ConnectedAt time.Time     `json:"connected_at"`
+}
+
+// CalendarSync records the trip calendar and events pushed for an itinerary
+type CalendarSync struct {
+	ItineraryID string                 `json:"itinerary_id"`
+	UserID      string                 `json:"user_id"`
+	CalendarID  string                 `json:"calendar_id"`
+	Events      map[string]SyncedEvent `json:"events"` // activity key -> event
+	SyncedAt    time.Time              `json:"synced_at"`
+}
+
+// SyncedEvent is a Google Calendar event created for an itinerary activity
+type SyncedEvent struct {
+	EventID string `json:"event_id"`
+	Hash    string `json:"hash"` // detects changes to the activity
+}
+
+// CalendarSyncResult summarizes one sync run
+type CalendarSyncResult struct {
+	CalendarID string `json:"calendar_id"`
+	Created    int    `json:"created"`
+	Updated    int    `json:"updated"`
+	Deleted    int    `json:"deleted"`
+	Unchanged  int    `json:"unchanged"`
+}
+
+// calendarActivity is an itinerary activity as it should appear in the calendar
+type calendarActivity struct {
+	key   string
+	event *calendar.Event
+	hash  string
+}
+
+var (
+	calendarConfigOnce sync.Once
+	calendarConfig     *oauth2.Config
+)
+
+// calendarOAuthConfig builds the OAuth client from GOOGLE_CALENDAR_CLIENT_ID,
+// GOOGLE_CALENDAR_CLIENT_SECRET and GOOGLE_CALENDAR_REDIRECT_URL
+func calendarOAuthConfig() (*oauth2.Config, error) {
+	calendarConfigOnce.Do(func() {
+		clientID := os.Getenv("GOOGLE_CALENDAR_CLIENT_ID")
+		clientSecret := os.Getenv("GOOGLE_CALENDAR_CLIENT_SECRET")
+		if clientID == "" || clientSecret == "" {
+			return
+		}
+		calendarConfig = &oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  os.Getenv("GOOGLE_CALENDAR_REDIRECT_URL"),
+			Scopes:       []string{calendar.CalendarScope},
+			Endpoint:     google.Endpoint,
+		}
+	})
+	if calendarConfig == nil {
+		return nil, ErrCalendarNotConfigured
+	}
+	return calendarConfig, nil
+}
+
+// CalendarAuthURL returns the Google consent page URL for a user of the tenant ctx acts for
+func CalendarAuthURL(ctx context.Context, userID string) (string, error) {
+	config, err := calendarOAuthConfig()
+	if err != nil {
+		return "", err
+	}
+	state := signCalendarState(TenantFromContext(ctx).ID, userID, time.Now().Add(calendarStateTTL))
+	return config.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.ApprovalForce), nil
+}
+
+// CompleteCalendarAuth exchanges the OAuth code from the consent redirect and stores the
+// token with the tenant the consent was started for, since the redirect comes back to one
+// callback URL for every tenant
+func CompleteCalendarAuth(ctx context.Context, state, code string) (*CalendarConnection, error) {
+	config, err := calendarOAuthConfig()
+	if err != nil {
+		return nil, err
+	}
+	tenantID, userID, err := verifyCalendarState(state)
+	if err != nil {
+		return nil, err
+	}
+	tenant, err := TenantByID(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	ctx = WithTenant(ctx, tenant)
+
+	token, err := config.Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange authorization code: %w", err)
+	}
+
+	connection := CalendarConnection{UserID: userID, Token: token, ConnectedAt: time.Now().UTC()}
+	if err := saveDocument(ctx, CalendarConnectionCollection, userID, connection); err != nil {
+		return nil, fmt.Errorf("failed to save calendar connection: %w", err)
+	}
+	utils.LogInfo(fmt.Sprintf("Connected Google Calendar for %s", userID))
+	return &connection, nil
+}
+
+// DisconnectCalendar forgets a user's Google token; trip calendars already created are left in place
+func DisconnectCalendar(ctx context.Context, userID string) error {
+	return deleteDocument(ctx, CalendarConnectionCollection, userID)
+}
+
+// GetCalendarSync returns the sync state for an itinerary
+func GetCalendarSync(ctx context.Context, itineraryID string) (*CalendarSync, error) {
+	var state CalendarSync
+	if err := loadDocument(ctx, CalendarSyncCollection, itineraryID, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// SyncItineraryCalendar pushes an itinerary's activities to its owner's trip calendar,
+// creating the calendar on first sync and then creating, updating and deleting events
+// so the calendar matches the itinerary.
+func SyncItineraryCalendar(ctx context.Context, itinerary *ItineraryResponse) (*CalendarSyncResult, error) {
+	if itinerary.OwnerID == "" {
+		return nil, fmt.Errorf("itinerary %s has no owner: %w", itinerary.ID, ErrCalendarNotConnected)
+	}
+
+	service, err := calendarServiceFor(ctx, itinerary.OwnerID)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := GetCalendarSync(ctx, itinerary.ID)
+	if err != nil {
+		if !errors.Is(err, ErrDocumentNotFound) {
+			return nil, err
+		}
+		state = &CalendarSync{ItineraryID: itinerary.ID, UserID: itinerary.OwnerID, Events: map[string]SyncedEvent{}}
+	}
+	if state.Events == nil {
+		state.Events = map[string]SyncedEvent{}
+	}
+
+	city, _, _, _ := itineraryTripDates(itinerary)
+	timezone := calendarTimezone(ctx, city)
+
+	if state.CalendarID == "" {
+		created, err := service.Calendars.Insert(&calendar.Calendar{
+			Summary:     fmt.Sprintf("CanTrip: %s", tripTitle(itinerary, city)),
+			Description: fmt.Sprintf("Itinerary %s, kept in sync by CanTrip", itinerary.ID),
+			TimeZone:    timezone,
+		}).Context(ctx).Do()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create trip calendar: %w", err)
+		}
+		state.CalendarID = created.Id
+	}
+
+	result := &CalendarSyncResult{CalendarID: state.CalendarID}
+	desired := buildCalendarActivities(itinerary, city, timezone)
+	wanted := make(map[string]bool, len(desired))
+
+	for _, activity := range desired {
+		wanted[activity.key] = true
+		existing, ok := state.Events[activity.key]
+
+		switch {
+		case ok && existing.Hash == activity.hash:
+			result.Unchanged++
+		case ok:
+			if _, err := service.Events.Update(state.CalendarID, existing.EventID, activity.event).Context(ctx).Do(); err != nil {
+				if !isGoogleNotFound(err) {
+					saveCalendarSync(ctx, state)
+					return nil, fmt.Errorf("failed to update calendar event: %w", err)
+				}
+				// Deleted in Google Calendar; recreate it below
+				ok = false
+			} else {
+				state.Events[activity.key] = SyncedEvent{EventID: existing.EventID, Hash: activity.hash}
+				result.Updated++
+			}
+		}
+
+		if !ok {
+			created, err := service.Events.Insert(state.CalendarID, activity.event).Context(ctx).Do()
+			if err != nil {
+				saveCalendarSync(ctx, state)
+				return nil, fmt.Errorf("failed to create calendar event: %w", err)
+			}
+			state.Events[activity.key] = SyncedEvent{EventID: created.Id, Hash: activity.hash}
+			result.Created++
+		}
+	}
+
+	for key, existing := range state.Events {
+		if wanted[key] {
+			continue
+		}
+		if err := service.Events.Delete(state.CalendarID, existing.EventID).Context(ctx).Do(); err != nil && !isGoogleNotFound(err) {
+			saveCalendarSync(ctx, state)
+			return nil, fmt.Errorf("failed to delete calendar event: %w", err)
+		}
+		delete(state.Events, key)
+		result.Deleted++
+	}
+
+	state.SyncedAt = time.Now().UTC()
+	if err := saveCalendarSync(ctx, state); err != nil {
+		return nil, err
+	}
+
+	utils.LogInfo(fmt.Sprintf("Synced itinerary %s to Google Calendar: %d created, %d updated, %d deleted",
+		itinerary.ID, result.Created, result.Updated, result.Deleted))
+	return result, nil
+}
+
+// ResyncItineraryCalendar re-syncs an itinerary that is already linked to a calendar.
+// Itineraries that were never synced are ignored.
+func ResyncItineraryCalendar(ctx context.Context, itinerary *ItineraryResponse) {
+	if _, err := GetCalendarSync(ctx, itinerary.ID); err != nil {
+		return
+	}
+	if _, err := SyncItineraryCalendar(ctx, itinerary); err != nil {
+		utils.LogError(fmt.Sprintf("Failed to resync itinerary %s to Google Calendar", itinerary.ID), err)
+	}
+}
+
+// UnlinkItineraryCalendar deletes an itinerary's trip calendar and forgets its sync state
+func UnlinkItineraryCalendar(ctx context.Context, itineraryID string) error {
+	state, err := GetCalendarSync(ctx, itineraryID)
+	if err != nil {
+		return err
+	}
+
+	service, err := calendarServiceFor(ctx, state.UserID)
+	if err != nil {
+		return err
+	}
+	if err := service.Calendars.Delete(state.CalendarID).Context(ctx).Do(); err != nil && !isGoogleNotFound(err) {
+		return fmt.Errorf("failed to delete trip calendar: %w", err)
+	}
+	return deleteDocument(ctx, CalendarSyncCollection, itineraryID)
+}
+
+// saveCalendarSync stores sync state, including partial progress after a failure
+func saveCalendarSync(ctx context.Context, state *CalendarSync) error {
+	if err := saveDocument(ctx, CalendarSyncCollection, state.ItineraryID, state); err != nil {
+		return fmt.Errorf("failed to save calendar sync state: %w", err)
+	}
+	return nil
+}
+
+// buildCalendarActivities converts an itinerary's timed activities to calendar events
+func buildCalendarActivities(itinerary *ItineraryResponse, city, timezone string) []calendarActivity {
+	var activities []calendarActivity
+	seen := map[string]int{}
+
+	days, _ := itinerary.Itinerary["days"].([]interface{})
+	for _, rawDay := range days {
+		day, ok := rawDay.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		date := fmt.Sprint(day["date"])
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			continue
+		}
+
+		rawActivities, _ := day["activities"].([]interface{})
+		for _, raw := range rawActivities {
+			activity, ok := raw.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := activity["name"].(string)
+			start, _ := activity["start_time"].(string)
+			if name == "" {
+				continue
+			}
+			if _, err := time.Parse("15:04", start); err != nil {
+				continue
+			}
+			end, _ := activity["end_time"].(string)
+			if _, err := time.Parse("15:04", end); err != nil || end <= start {
+				end = addCalendarHour(start)
+			}
+
+			location, _ := activity["location"].(string)
+			if location == "" && city != "" {
+				location = fmt.Sprintf("%s, %s", name, city)
+			}
+			description, _ := activity["description"].(string)
+			if note, _ := activity["crowd_note"].(string); note != "" {
+				description = strings.TrimSpace(description + "\n" + note)
+			}
+
+			// Key activities by day and name so reordering within a day updates times in place
+			key := date + "|" + strings.ToLower(name)
+			seen[key]++
+			if seen[key] > 1 {
+				key = fmt.Sprintf("%s#%d", key, seen[key])
+			}
+
+			event := &calendar.Event{
+				Summary:     name,
+				Location:    location,
+				Description: description,
+				Start:       &calendar.EventDateTime{DateTime: date + "T" + start + ":00", TimeZone: timezone},
+				End:         &calendar.EventDateTime{DateTime: date + "T" + end + ":00", TimeZone: timezone},
+				ExtendedProperties: &calendar.EventExtendedProperties{
+					Private: map[string]string{"cantrip_itinerary": itinerary.ID, "cantrip_key": key},
+				},
+			}
+			activities = append(activities, calendarActivity{key: key, event: event, hash: calendarEventHash(event)})
+		}
+	}
+	return activities
+}
+
+// calendarEventHash fingerprints the fields CanTrip controls
+func calendarEventHash(event *calendar.Event) string {
+	sum := sha1.Sum([]byte(strings.Join([]string{
+		event.Summary, event.Location, event.Description,
+		event.Start.DateTime, event.End.DateTime, event.Start.TimeZone,
+	}, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// addCalendarHour returns an HH:MM time one hour later, capped at the end of the day
+func addCalendarHour(start string) string {
+	t, _ := time.Parse("15:04", start)
+	if t.Hour() >= 23 {
+		return "23:59"
+	}
+	return t.Add(time.Hour).Format("15:04")
+}
+
+// calendarTimezone returns the IANA timezone for a city, defaulting to Toronto
+func calendarTimezone(ctx context.Context, city string) string {
+	if metadata, err := loadCityMetadata(ctx); err == nil {
+		if cityData, err := findCity(metadata, city); err == nil && cityData.Timezone != "" {
+			return cityData.Timezone
+		}
+	}
+	return "America/Toronto"
+}
+
+// tripTitle names a trip calendar, e.g. "Toronto, 2027-07-10 to 2027-07-12"
+func tripTitle(itinerary *ItineraryResponse, city string) string {
+	startDate, _ := itinerary.Itinerary["start_date"].(string)
+	endDate, _ := itinerary.Itinerary["end_date"].(string)
+	if city == "" {
+		city = "Trip"
+	}
+	if startDate == "" {
+		return city
+	}
+	if endDate == "" || endDate == startDate {
+		return fmt.Sprintf("%s, %s", city, startDate)
+	}
+	return fmt.Sprintf("%s, %s to %s", city, startDate, endDate)
+}
+
+// calendarServiceFor returns a Calendar API client authorized as the user.
+// GOOGLE_CALENDAR_ENDPOINT overrides the API base URL, e.g. for a local fake.
+func calendarServiceFor(ctx context.Context, userID string) (*calendar.Service, error) {
+	var connection CalendarConnection
+	if err := loadDocument(ctx, CalendarConnectionCollection, userID, &connection); err != nil {
+		if errors.Is(err, ErrDocumentNotFound) {
+			return nil, ErrCalendarNotConnected
+		}
+		return nil, err
+	}
+	if connection.Token == nil {
+		return nil, ErrCalendarNotConnected
+	}
+
+	opts := []option.ClientOption{}
+	if endpoint := os.Getenv("GOOGLE_CALENDAR_ENDPOINT"); endpoint != "" {
+		opts = append(opts, option.WithEndpoint(endpoint), option.WithTokenSource(oauth2.StaticTokenSource(connection.Token)))
+	} else {
+		config, err := calendarOAuthConfig()
+		if err != nil {
+			return nil, err
+		}
+		source := &persistingTokenSource{
+			ctx:        storageContext(ctx),
+			connection: connection,
+			base:       config.TokenSource(context.Background(), connection.Token),
+		}
+		opts = append(opts, option.WithTokenSource(oauth2.ReuseTokenSource(connection.Token, source)))
+	}
+
+	service, err := calendar.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create calendar client: %w", err)
+	}
+	return service, nil
+}
+
+// persistingTokenSource saves refreshed tokens so the next sync doesn't refresh again
+type persistingTokenSource struct {
+	ctx        context.Context // acts for the connection's tenant
+	mu         sync.Mutex
+	connection CalendarConnection
+	base       oauth2.TokenSource
+}
+
+// Token returns a valid token, storing it when it was refreshed
+func (p *persistingTokenSource) Token() (*oauth2.Token, error) {
+	token, err := p.base.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.connection.Token == nil || token.AccessToken != p.connection.Token.AccessToken {
+		p.connection.Token = token
+		if err := saveDocument(p.ctx, CalendarConnectionCollection, p.connection.UserID, p.connection); err != nil {
+			utils.LogError("Failed to save refreshed calendar token", err)
+		}
+	}
+	return token, nil
+}
+
+// isGoogleNotFound reports whether a Google API call failed because the resource is gone
+func isGoogleNotFound(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && (apiErr.Code == 404 || apiErr.Code == 410)
+}
+
+// calendarStateSecret signs OAuth state, preferring a dedicated secret over the client secret
+func calendarStateSecret() []byte {
+	if secret := os.Getenv("GOOGLE_CALENDAR_STATE_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	return []byte(os.Getenv("GOOGLE_CALENDAR_CLIENT_SECRET"))
+}
+
+// signCalendarState encodes the user and expiry into a tamper-proof OAuth state value
+func signCalendarState(tenantID, userID string, expires time.Time) string {
+	payload := tenantID + "|" + userID + "|" + strconv.FormatInt(expires.Unix(), 10)
+	mac := hmac.New(sha256.New, calendarStateSecret())
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifyCalendarState checks an OAuth state value and returns the tenant and user it was
+// issued to
+func verifyCalendarState(state string) (string, string, error) {
+	encodedPayload, encodedSig, ok := strings.Cut(state, ".")
+	if !ok {
+		return "", "", fmt.Errorf("invalid oauth state")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid oauth state")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSig)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid oauth state")
+	}
+
+	mac := hmac.New(sha256.New, calendarStateSecret())
+	mac.Write(payload)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return "", "", fmt.Errorf("invalid oauth state")
+	}
+
+	tenantID, rest, _ := strings.Cut(string(payload), "|")
+	userID, expiresAt, ok := strings.Cut(rest, "|")
+	expires, err := strconv.ParseInt(expiresAt, 10, 64)
+	if !ok || err != nil || time.Now().Unix() > expires {
+		return "", "", fmt.Errorf("oauth state has expired")
+	}
+	return tenantID, userID, nil
+}
@@ -0,0 +1,271 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/joshndala/cantrip/models"
+	"github.com/joshndala/cantrip/utils"
+)
+
+// ChatCardSchemaVersion is bumped whenever a card's fields change incompatibly
+const ChatCardSchemaVersion = 1
+
+// Chat card types
+const (
+	ChatCardWeather   = "weather"
+	ChatCardEvent     = "event"
+	ChatCardItinerary = "itinerary_preview"
+)
+
+// maxEventCards bounds the event cards built from one reply
+const maxEventCards = 5
+
+// Card action kinds
+const (
+	CardActionLink    = "link"    // open URL
+	CardActionMessage = "message" // send Message as the next chat turn
+)
+
+// ChatCard is a typed, renderable piece of an agent reply. Exactly one of Weather, Event
+// or Itinerary is set, matching Type.
+type ChatCard = models.ChatCard
+
+// CardAction is a button on a card
+type CardAction = models.CardAction
+
+// WeatherCard shows current conditions and, when the agent sent one, a forecast
+type WeatherCard = models.WeatherCard
+
+// EventCard shows one event
+type EventCard = models.EventCard
+
+// ItineraryPreviewCard summarizes a generated itinerary
+type ItineraryPreviewCard = models.ItineraryPreviewCard
+
+// BuildChatCards converts the agent's free-form reply data into typed cards. The agent
+// sends "weather", "events" (or a single "event") and "itinerary"; values that don't fit
+// a card's schema are skipped rather than passed through.
+func BuildChatCards(data map[string]interface{}) []ChatCard {
+	cards := []ChatCard{}
+	if len(data) == 0 {
+		return cards
+	}
+
+	if raw, ok := data["weather"].(map[string]interface{}); ok {
+		if card := weatherCard(raw); card != nil {
+			cards = append(cards, *card)
+		}
+	}
+
+	var events []interface{}
+	if raw, ok := data["events"].([]interface{}); ok {
+		events = raw
+	} else if raw, ok := data["event"].(map[string]interface{}); ok {
+		events = []interface{}{raw}
+	}
+	eventCards := 0
+	for _, raw := range events {
+		if eventCards == maxEventCards {
+			break
+		}
+		if event, ok := raw.(map[string]interface{}); ok {
+			if card := eventCard(event); card != nil {
+				cards = append(cards, *card)
+				eventCards++
+			}
+		}
+	}
+
+	if raw, ok := data["itinerary"].(map[string]interface{}); ok {
+		if card := itineraryCard(raw); card != nil {
+			cards = append(cards, *card)
+		}
+	}
+	return cards
+}
+
+// ChatIntents lists a reply's intents: the primary one, any others the agent named in
+// data["intents"], and those implied by its cards
+func ChatIntents(primary string, data map[string]interface{}, cards []ChatCard) []string {
+	intents := []string{}
+	add := func(intent string) {
+		if intent != "" && !utils.Contains(intents, intent) {
+			intents = append(intents, intent)
+		}
+	}
+
+	add(primary)
+	if raw, ok := data["intents"].([]interface{}); ok {
+		for _, intent := range raw {
+			if s, ok := intent.(string); ok {
+				add(s)
+			}
+		}
+	}
+	for _, card := range cards {
+		switch card.Type {
+		case ChatCardWeather:
+			add("weather")
+		case ChatCardEvent:
+			add("events")
+		case ChatCardItinerary:
+			add("plan_trip")
+		}
+	}
+	return intents
+}
+
+func weatherCard(raw map[string]interface{}) *ChatCard {
+	var weather WeatherCard
+	if err := remarshal(raw, &weather); err != nil || weather.City == "" {
+		return nil
+	}
+	// Agents may nest current conditions under "current"
+	if current, ok := raw["current"].(map[string]interface{}); ok {
+		var info WeatherInfo
+		if remarshal(current, &info) == nil {
+			weather.Temperature, weather.Condition = info.Temperature, info.Condition
+			weather.Humidity, weather.WindSpeed = info.Humidity, info.WindSpeed
+		}
+	}
+
+	return &ChatCard{
+		Type:    ChatCardWeather,
+		Version: ChatCardSchemaVersion,
+		Weather: &weather,
+		Actions: []CardAction{
+			{Label: "See forecast", Kind: CardActionLink, URL: "/api/v1/weather/forecast?city=" + url.QueryEscape(weather.City)},
+			{Label: "What should I pack?", Kind: CardActionMessage, Message: fmt.Sprintf("What should I pack for %s?", weather.City)},
+		},
+	}
+}
+
+func eventCard(raw map[string]interface{}) *ChatCard {
+	var event Event
+	if err := remarshal(raw, &event); err != nil || event.Name == "" {
+		return nil
+	}
+
+	card := &ChatCard{
+		Type:    ChatCardEvent,
+		Version: ChatCardSchemaVersion,
+		Event: &EventCard{
+			ID:         event.ID,
+			Name:       event.Name,
+			Date:       event.Date,
+			Time:       event.Time,
+			Location:   event.Location,
+			Category:   event.Category,
+			Price:      event.Price,
+			BookingURL: event.BookingURL,
+		},
+	}
+
+	// Prefer the tracked outbound link, so ticket clicks are attributed
+	booking := event.OutboundURL
+	if booking == "" {
+		booking = event.BookingURL
+	}
+	if booking != "" {
+		card.Actions = append(card.Actions, CardAction{Label: "Get tickets", Kind: CardActionLink, URL: booking})
+	}
+	if event.ID != "" {
+		card.Actions = append(card.Actions, CardAction{Label: "Watch for changes", Kind: CardActionLink, URL: "/api/v1/events/" + url.PathEscape(event.ID) + "/watch"})
+	}
+	return card
+}
+
+func itineraryCard(raw map[string]interface{}) *ChatCard {
+	var fields struct {
+		ID         string   `json:"id"`
+		City       string   `json:"city"`
+		StartDate  string   `json:"start_date"`
+		EndDate    string   `json:"end_date"`
+		TotalCost  float64  `json:"total_cost"`
+		Highlights []string `json:"highlights"`
+	}
+	if err := remarshal(raw, &fields); err != nil {
+		return nil
+	}
+	preview := ItineraryPreviewCard{
+		ID:         fields.ID,
+		City:       fields.City,
+		StartDate:  fields.StartDate,
+		EndDate:    fields.EndDate,
+		TotalCost:  fields.TotalCost,
+		Highlights: fields.Highlights,
+	}
+	// "days" is either a count or the list of day plans
+	switch days := raw["days"].(type) {
+	case float64:
+		preview.Days = int(days)
+	case []interface{}:
+		preview.Days = len(days)
+	}
+
+	// A full itinerary response nests the city under metadata and the days under itinerary
+	var full ItineraryResponse
+	if remarshal(raw, &full) == nil {
+		if preview.City == "" {
+			preview.City = full.Metadata.City
+		}
+		if preview.Days == 0 {
+			preview.Days = full.Metadata.Duration
+		}
+		if preview.TotalCost == 0 {
+			preview.TotalCost = full.Metadata.TotalCost
+		}
+	}
+	if preview.City == "" {
+		return nil
+	}
+	if len(preview.Highlights) == 0 {
+		preview.Highlights = itineraryHighlights(raw)
+	}
+
+	card := &ChatCard{Type: ChatCardItinerary, Version: ChatCardSchemaVersion, Itinerary: &preview}
+	if preview.ID != "" {
+		card.Actions = []CardAction{
+			{Label: "Open itinerary", Kind: CardActionLink, URL: "/api/v1/itinerary/" + url.PathEscape(preview.ID)},
+			{Label: "Make a packing list", Kind: CardActionMessage, Message: fmt.Sprintf("Create a packing list for my %s trip", preview.City)},
+		}
+	}
+	return card
+}
+
+// itineraryHighlights takes the first few activity titles from an itinerary's days
+func itineraryHighlights(raw map[string]interface{}) []string {
+	days, _ := raw["days"].([]interface{})
+	if len(days) == 0 {
+		if nested, ok := raw["itinerary"].(map[string]interface{}); ok {
+			days, _ = nested["days"].([]interface{})
+		}
+	}
+
+	highlights := []string{}
+	for _, rawDay := range days {
+		day, _ := rawDay.(map[string]interface{})
+		activities, _ := day["activities"].([]interface{})
+		for _, rawActivity := range activities {
+			var title string
+			switch activity := rawActivity.(type) {
+			case string:
+				title = activity
+			case map[string]interface{}:
+				title, _ = activity["name"].(string)
+				if title == "" {
+					title, _ = activity["title"].(string)
+				}
+			}
+			if title = strings.TrimSpace(title); title != "" {
+				highlights = append(highlights, title)
+			}
+			if len(highlights) == 3 {
+				return highlights
+			}
+		}
+	}
+	return highlights
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Feedback ratings
+const (
+	FeedbackUp   = "up"
+	FeedbackDown = "down"
+)
+
+// chatFeedbackCollection stores one document per rated reply and user
+const chatFeedbackCollection = "chat_feedback"
+
+// maxFeedbackComment bounds free-text feedback comments, in runes
+const maxFeedbackComment = 2000
+
+// Feedback errors
+var (
+	ErrInvalidFeedback = errors.New("invalid feedback")
+	ErrTurnNotFound    = errors.New("turn not found")
+)
+
+// ChatFeedbackRequest rates one assistant reply
+type ChatFeedbackRequest struct {
+	SessionID string `json:"session_id" binding:"required"`
+	Turn      int    `json:"turn" binding:"required"`
+	Rating    string `json:"rating" binding:"required"` // up or down
+	Comment   string `json:"comment,omitempty"`
+	UserID    string `json:"user_id,omitempty"`
+}
+
+// ChatFeedback is a rating of an assistant reply. The question and answer are copied from
+// the session so the feedback outlives compaction and cleared histories.
+type ChatFeedback struct {
+	ID         string    `json:"id"`
+	SessionID  string    `json:"session_id"`
+	Turn       int       `json:"turn"`
+	UserID     string    `json:"user_id,omitempty"`
+	Rating     string    `json:"rating"`
+	Comment    string    `json:"comment,omitempty"`
+	Question   string    `json:"question"`
+	Answer     string    `json:"answer"`
+	AnsweredAt time.Time `json:"answered_at"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// ChatFeedbackStats summarizes feedback on assistant replies
+type ChatFeedbackStats struct {
+	Total        int               `json:"total"`
+	Up           int               `json:"up"`
+	Down         int               `json:"down"`
+	Satisfaction float64           `json:"satisfaction"` // share of ratings that are up
+	WithComments int               `json:"with_comments"`
+	ByDay        []ChatFeedbackDay `json:"by_day"`
+	Negative     []ChatFeedback    `json:"recent_negative"` // most recent thumbs-down replies
+}
+
+// ChatFeedbackDay is a daily tally of ratings
+type ChatFeedbackDay struct {
+	Date string `json:"date"`
+	Up   int    `json:"up"`
+	Down int    `json:"down"`
+}
+
+// SubmitChatFeedback records a rating of a reply still in the session's history. Rating the
+// same reply again replaces the earlier rating.
+func SubmitChatFeedback(ctx context.Context, req ChatFeedbackRequest) (*ChatFeedback, error) {
+	rating := strings.ToLower(strings.TrimSpace(req.Rating))
+	if rating != FeedbackUp && rating != FeedbackDown {
+		return nil, fmt.Errorf("%w: rating must be up or down", ErrInvalidFeedback)
+	}
+	comment := strings.TrimSpace(req.Comment)
+	if len([]rune(comment)) > maxFeedbackComment {
+		return nil, fmt.Errorf("%w: comment is longer than %d characters", ErrInvalidFeedback, maxFeedbackComment)
+	}
+
+	session, exists := sessions[tenantKey(ctx, req.SessionID)]
+	if !exists {
+		return nil, fmt.Errorf("session %s: %w", req.SessionID, ErrDocumentNotFound)
+	}
+	var question, answer *ChatMessage
+	for i := range session.History {
+		message := &session.History[i]
+		if message.Turn != req.Turn {
+			continue
+		}
+		if message.Role == "assistant" {
+			answer = message
+		} else {
+			question = message
+		}
+	}
+	if answer == nil {
+		return nil, fmt.Errorf("session %s turn %d: %w", req.SessionID, req.Turn, ErrTurnNotFound)
+	}
+
+	userID := req.UserID
+	if userID == "" {
+		userID = session.UserID
+	}
+	// The answer's timestamp tells turns apart after a history is cleared and renumbered
+	id := fmt.Sprintf("%s_%d_%d", req.SessionID, req.Turn, answer.Timestamp.UnixNano())
+	if userID != "" {
+		id += "_" + userID
+	}
+	id = strings.NewReplacer("/", "-", "\\", "-", ".", "-").Replace(id)
+
+	unlock := lockDocument(chatFeedbackCollection, id)
+	defer unlock()
+
+	now := time.Now().UTC()
+	feedback := ChatFeedback{CreatedAt: now}
+	if err := loadDocument(ctx, chatFeedbackCollection, id, &feedback); err != nil && !errors.Is(err, ErrDocumentNotFound) {
+		return nil, err
+	}
+	feedback.ID = id
+	feedback.SessionID = req.SessionID
+	feedback.Turn = req.Turn
+	feedback.UserID = userID
+	feedback.Rating = rating
+	feedback.Comment = comment
+	feedback.Answer = answer.Message
+	feedback.AnsweredAt = answer.Timestamp.UTC()
+	if question != nil {
+		feedback.Question = question.Message
+	}
+	feedback.UpdatedAt = now
+
+	if err := saveDocument(ctx, chatFeedbackCollection, id, feedback); err != nil {
+		return nil, fmt.Errorf("failed to save feedback: %w", err)
+	}
+	return &feedback, nil
+}
+
+// ListChatFeedback returns all feedback, optionally only one rating, newest first
+func ListChatFeedback(ctx context.Context, rating string) ([]ChatFeedback, error) {
+	ids, err := listDocumentIDs(ctx, chatFeedbackCollection)
+	if err != nil {
+		return nil, err
+	}
+
+	feedback := []ChatFeedback{}
+	for _, id := range ids {
+		var entry ChatFeedback
+		if err := loadDocument(ctx, chatFeedbackCollection, id, &entry); err != nil {
+			continue
+		}
+		if rating != "" && entry.Rating != rating {
+			continue
+		}
+		feedback = append(feedback, entry)
+	}
+	sort.Slice(feedback, func(i, j int) bool {
+		return feedback[i].UpdatedAt.After(feedback[j].UpdatedAt)
+	})
+	return feedback, nil
+}
+
+// GetChatFeedbackStats tallies feedback given since a time, listing the latest
+// thumbs-down replies for review
+func GetChatFeedbackStats(ctx context.Context, since time.Time) (*ChatFeedbackStats, error) {
+	feedback, err := ListChatFeedback(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &ChatFeedbackStats{ByDay: []ChatFeedbackDay{}, Negative: []ChatFeedback{}}
+	days := map[string]*ChatFeedbackDay{}
+	for _, entry := range feedback {
+		if entry.UpdatedAt.Before(since) {
+			continue
+		}
+		date := entry.UpdatedAt.Format("2006-01-02")
+		day := days[date]
+		if day == nil {
+			day = &ChatFeedbackDay{Date: date}
+			days[date] = day
+		}
+
+		stats.Total++
+		if entry.Comment != "" {
+			stats.WithComments++
+		}
+		switch entry.Rating {
+		case FeedbackUp:
+			stats.Up++
+			day.Up++
+		case FeedbackDown:
+			stats.Down++
+			day.Down++
+			if len(stats.Negative) < 10 {
+				stats.Negative = append(stats.Negative, entry)
+			}
+		}
+	}
+	if stats.Total > 0 {
+		stats.Satisfaction = float64(stats.Up) / float64(stats.Total)
+	}
+
+	for _, day := range days {
+		stats.ByDay = append(stats.ByDay, *day)
+	}
+	sort.Slice(stats.ByDay, func(i, j int) bool { return stats.ByDay[i].Date < stats.ByDay[j].Date })
+	return stats, nil
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joshndala/cantrip/utils"
+)
+
+// ChatStreamReplayTTL is how long a finished reply stays buffered for clients to resume
+const ChatStreamReplayTTL = 5 * time.Minute
+
+// ErrChatStreamExpired is returned when a Last-Event-ID no longer matches a buffered stream
+var ErrChatStreamExpired = errors.New("chat stream is no longer available")
+
+// ChatStreamRequest is a streamed chat turn
+type ChatStreamRequest struct {
+	Message  string
+	Speak    bool   // follow the reply with its audio
+	Language string // for the spoken reply
+}
+
+// ChatStreamEvent is one server-sent event and the ID a client resumes after
+type ChatStreamEvent struct {
+	ID   string
+	Data []byte
+}
+
+// ChatStream buffers one streamed reply. The reply is generated apart from the request
+// that asked for it, so a client that drops can reconnect with Last-Event-ID and pick up
+// the events it missed, including those produced while it was away.
+type ChatStream struct {
+	id        string
+	sessionID string
+
+	mu         sync.Mutex
+	events     []ChatStreamEvent
+	finished   bool
+	finishedAt time.Time
+	changed    chan struct{} // closed and replaced whenever an event is added or the stream ends
+}
+
+var (
+	chatStreamsMu sync.Mutex
+	chatStreams   = map[string]*ChatStream{} // tenantKey of the session -> latest stream
+)
+
+// StartChatStream begins generating a reply into a new stream, which replaces the session's
+// previous one. Generation outlives ctx's cancellation, bounded by the agent stream timeout.
+func StartChatStream(ctx context.Context, session *ConversationSession, req ChatStreamRequest) *ChatStream {
+	stream := &ChatStream{
+		id:        strconv.FormatInt(time.Now().UnixNano(), 36),
+		sessionID: session.SessionID,
+		changed:   make(chan struct{}),
+	}
+
+	chatStreamsMu.Lock()
+	for key, previous := range chatStreams {
+		if previous.expired() {
+			delete(chatStreams, key)
+		}
+	}
+	chatStreams[tenantKey(ctx, session.SessionID)] = stream
+	chatStreamsMu.Unlock()
+
+	go func() {
+		defer stream.finish()
+		ctx := context.WithoutCancel(ctx)
+
+		if err := ProcessChatMessageStream(ctx, req.Message, session, stream); err != nil {
+			utils.LogError("Failed to stream chat reply", err)
+			stream.publish([]byte(`{"type":"error","content":"Failed to process message"}`))
+			return
+		}
+		if req.Speak {
+			speakStreamedReply(ctx, session, req, stream)
+		}
+	}()
+	return stream
+}
+
+// ResumeChatStream finds the session's stream a Last-Event-ID came from and the number of
+// its events the client already has
+func ResumeChatStream(ctx context.Context, sessionID, lastEventID string) (*ChatStream, int, error) {
+	streamID, seq, ok := strings.Cut(lastEventID, ".")
+	received, err := strconv.Atoi(seq)
+	if !ok || err != nil || received < 0 {
+		return nil, 0, fmt.Errorf("invalid Last-Event-ID %q: %w", lastEventID, ErrChatStreamExpired)
+	}
+
+	chatStreamsMu.Lock()
+	stream, exists := chatStreams[tenantKey(ctx, sessionID)]
+	chatStreamsMu.Unlock()
+	if !exists || stream.id != streamID || stream.expired() {
+		return nil, 0, ErrChatStreamExpired
+	}
+
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	if received > len(stream.events) {
+		return nil, 0, ErrChatStreamExpired
+	}
+	return stream, received, nil
+}
+
+// Follow passes write every event after the first skip, waiting for new events until the
+// reply is complete or ctx is done
+func (s *ChatStream) Follow(ctx context.Context, skip int, write func(ChatStreamEvent) error) error {
+	next := skip
+	for {
+		s.mu.Lock()
+		pending := s.events[next:]
+		finished := s.finished
+		changed := s.changed
+		s.mu.Unlock()
+
+		for _, event := range pending {
+			if err := write(event); err != nil {
+				return err
+			}
+		}
+		next += len(pending)
+		if finished {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// publish appends an event, numbered from 1 within the stream
+func (s *ChatStream) publish(data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, ChatStreamEvent{ID: fmt.Sprintf("%s.%d", s.id, len(s.events)+1), Data: data})
+	s.notify()
+}
+
+// publishJSON encodes and appends an event
+func (s *ChatStream) publishJSON(v interface{}) {
+	data, _ := json.Marshal(v)
+	s.publish(data)
+}
+
+func (s *ChatStream) finish() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.finished = true
+	s.finishedAt = time.Now()
+	s.notify()
+}
+
+// notify wakes followers; the caller holds s.mu
+func (s *ChatStream) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *ChatStream) expired() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.finished && time.Since(s.finishedAt) > ChatStreamReplayTTL
+}
+
+// speakStreamedReply follows the done event with the spoken reply, once the full text is known
+func speakStreamedReply(ctx context.Context, session *ConversationSession, req ChatStreamRequest, stream *ChatStream) {
+	history, err := GetConversationHistory(ctx, session.SessionID)
+	// A blocked message never reached the history, so there's no new reply to speak
+	last := len(history) - 1
+	if err != nil || last < 1 || history[last].Role != "assistant" || history[last-1].Message != req.Message {
+		return
+	}
+	audio, err := SpeakReply(ctx, history[last].Message, req.Language)
+	if err != nil {
+		utils.LogError("Failed to synthesize chat reply", err)
+		return
+	}
+	stream.publishJSON(map[string]interface{}{"type": "audio", "session_id": session.SessionID, "audio": audio})
+}
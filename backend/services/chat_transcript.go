@@ -0,0 +1,226 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Transcript export formats
+const (
+	TranscriptFormatPDF      = "pdf"
+	TranscriptFormatMarkdown = "md"
+	TranscriptFormatText     = "txt"
+)
+
+// ErrSessionNotFound is returned for a chat session that doesn't exist
+var ErrSessionNotFound = errors.New("session not found")
+
+// ItinerarySnapshot is an itinerary as the agent presented it in a reply
+type ItinerarySnapshot struct {
+	Turn int `json:"turn"`
+	ItineraryPreviewCard
+}
+
+// ChatTranscript is a planning conversation prepared for export
+type ChatTranscript struct {
+	SessionID   string              `json:"session_id"`
+	CreatedAt   time.Time           `json:"created_at"`
+	LastUpdated time.Time           `json:"last_updated"`
+	Memory      ConversationMemory  `json:"memory"` // what compaction removed from Messages
+	Messages    []ChatMessage       `json:"messages"`
+	Itineraries []ItinerarySnapshot `json:"itineraries,omitempty"`
+}
+
+// GetChatTranscript collects a session's conversation and generated itineraries
+func GetChatTranscript(ctx context.Context, sessionID string) (*ChatTranscript, error) {
+	session, exists := sessions[tenantKey(ctx, sessionID)]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, sessionID)
+	}
+	memory, err := GetConversationMemory(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &ChatTranscript{
+		SessionID:   session.SessionID,
+		CreatedAt:   session.CreatedAt,
+		LastUpdated: session.LastUpdated,
+		Memory:      *memory,
+		Messages:    append([]ChatMessage(nil), session.History...),
+		Itineraries: append([]ItinerarySnapshot(nil), session.Itineraries...),
+	}, nil
+}
+
+// version counts the messages so far, so a PDF is regenerated once the conversation moves on
+func (t *ChatTranscript) version() int {
+	return t.Memory.SummarizedMessages + len(t.Messages)
+}
+
+// itinerariesForTurn returns the snapshots generated in reply to one turn
+func (t *ChatTranscript) itinerariesForTurn(turn int) []ItinerarySnapshot {
+	var snapshots []ItinerarySnapshot
+	for _, snapshot := range t.Itineraries {
+		if snapshot.Turn == turn {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	return snapshots
+}
+
+// earlierItineraries returns the snapshots from turns compacted into the summary
+func (t *ChatTranscript) earlierItineraries() []ItinerarySnapshot {
+	if len(t.Messages) == 0 {
+		return t.Itineraries
+	}
+	var snapshots []ItinerarySnapshot
+	for _, snapshot := range t.Itineraries {
+		if snapshot.Turn < t.Messages[0].Turn {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	return snapshots
+}
+
+// speaker names a message's author in the transcript
+func speaker(message ChatMessage) string {
+	if message.Role == "assistant" {
+		return "CanTrip"
+	}
+	return "You"
+}
+
+// snapshotLines describes an itinerary snapshot, heading first
+func snapshotLines(snapshot ItinerarySnapshot) []string {
+	heading := fmt.Sprintf("Itinerary: %s, %d days", snapshot.City, snapshot.Days)
+	if snapshot.StartDate != "" {
+		heading += fmt.Sprintf(" (%s to %s)", snapshot.StartDate, snapshot.EndDate)
+	}
+	lines := []string{heading}
+	if snapshot.TotalCost > 0 {
+		lines = append(lines, fmt.Sprintf("Estimated cost: $%.2f CAD", snapshot.TotalCost))
+	}
+	for _, highlight := range snapshot.Highlights {
+		lines = append(lines, "- "+highlight)
+	}
+	if snapshot.ID != "" {
+		lines = append(lines, "Saved as itinerary "+snapshot.ID)
+	}
+	return lines
+}
+
+// RenderTranscriptMarkdown formats a transcript as Markdown
+func RenderTranscriptMarkdown(t *ChatTranscript) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Trip Planning Transcript\n\n")
+	fmt.Fprintf(&b, "Session `%s`, started %s\n\n", t.SessionID, t.CreatedAt.Format("January 2, 2006 15:04"))
+
+	writeSnapshots := func(snapshots []ItinerarySnapshot) {
+		for _, snapshot := range snapshots {
+			lines := snapshotLines(snapshot)
+			fmt.Fprintf(&b, "> **%s**\n>\n", lines[0])
+			for _, line := range lines[1:] {
+				fmt.Fprintf(&b, "> %s\n", line)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	if t.Memory.Summary != "" {
+		fmt.Fprintf(&b, "## Earlier in the conversation\n\n%s\n\n", t.Memory.Summary)
+		writeSnapshots(t.earlierItineraries())
+	}
+
+	b.WriteString("## Conversation\n\n")
+	for _, message := range t.Messages {
+		fmt.Fprintf(&b, "**%s** _(%s)_\n\n%s\n\n", speaker(message), message.Timestamp.Format("Jan 2 15:04"), message.Message)
+		if message.Role == "assistant" {
+			writeSnapshots(t.itinerariesForTurn(message.Turn))
+		}
+	}
+	return []byte(b.String())
+}
+
+// RenderTranscriptText formats a transcript as plain text
+func RenderTranscriptText(t *ChatTranscript) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Trip Planning Transcript\nSession %s, started %s\n\n", t.SessionID, t.CreatedAt.Format("January 2, 2006 15:04"))
+
+	writeSnapshots := func(snapshots []ItinerarySnapshot) {
+		for _, snapshot := range snapshots {
+			for _, line := range snapshotLines(snapshot) {
+				fmt.Fprintf(&b, "    %s\n", line)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	if t.Memory.Summary != "" {
+		fmt.Fprintf(&b, "Earlier in the conversation:\n%s\n\n", t.Memory.Summary)
+		writeSnapshots(t.earlierItineraries())
+	}
+
+	for _, message := range t.Messages {
+		fmt.Fprintf(&b, "[%s] %s:\n%s\n\n", message.Timestamp.Format("Jan 2 15:04"), speaker(message), message.Message)
+		if message.Role == "assistant" {
+			writeSnapshots(t.itinerariesForTurn(message.Turn))
+		}
+	}
+	return []byte(b.String())
+}
+
+// GenerateTranscriptPDF generates a PDF of a chat session's transcript
+func GenerateTranscriptPDF(ctx context.Context, sessionID, format string, includeImages bool, customization map[string]interface{}) (*PDFMetadata, error) {
+	t, err := GetChatTranscript(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	pdf := newStructuredPDF("Trip Planning Transcript", t.SessionID)
+	addPDFCoverPage(pdf, "Trip Planning Transcript", []string{
+		"Started " + t.CreatedAt.Format("January 2, 2006"),
+		fmt.Sprintf("%d messages", t.version()),
+	})
+	pdf.AddPage()
+
+	writeSnapshots := func(snapshots []ItinerarySnapshot) {
+		for _, snapshot := range snapshots {
+			lines := snapshotLines(snapshot)
+			pdf.SetFillColor(240, 244, 248)
+			pdf.SetFont("Arial", "B", 10)
+			pdf.CellFormat(0, 7, lines[0], "", 1, "L", true, 0, "")
+			pdf.SetFont("Arial", "", 9)
+			for _, line := range lines[1:] {
+				pdf.CellFormat(0, 5, line, "", 1, "L", true, 0, "")
+			}
+			pdf.Ln(4)
+		}
+	}
+
+	if t.Memory.Summary != "" {
+		pdf.SetFont("Arial", "B", 12)
+		pdf.Cell(0, 8, "Earlier in the conversation")
+		pdf.Ln(9)
+		pdf.SetFont("Arial", "I", 10)
+		pdf.MultiCell(0, 5, t.Memory.Summary, "", "", false)
+		pdf.Ln(4)
+		writeSnapshots(t.earlierItineraries())
+	}
+
+	for _, message := range t.Messages {
+		pdf.SetFont("Arial", "B", 10)
+		pdf.Cell(0, 6, fmt.Sprintf("%s  (%s)", speaker(message), message.Timestamp.Format("Jan 2 15:04")))
+		pdf.Ln(6)
+		pdf.SetFont("Arial", "", 10)
+		pdf.MultiCell(0, 5, message.Message, "", "", false)
+		pdf.Ln(3)
+		if message.Role == "assistant" {
+			writeSnapshots(t.itinerariesForTurn(message.Turn))
+		}
+	}
+
+	source := PDFSource{Type: "transcript", ID: t.SessionID, Version: t.version()}
+	return storePDF(ctx, pdf, source, customization)
+}
@@ -0,0 +1,336 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/joshndala/cantrip/utils"
+)
+
+// OverpassAPIURL is the OpenStreetMap Overpass endpoint used by the importer
+const OverpassAPIURL = "https://overpass-api.de/api/interpreter"
+
+// Neighborhood search radius around an imported city, in metres
+const importNeighborhoodRadius = 8000
+
+// CityImportRecord is a city bootstrapped from open data
+type CityImportRecord struct {
+	Name          string      `json:"name"`
+	Province      string      `json:"province"`
+	Population    int         `json:"population"`
+	Coordinates   Coordinates `json:"coordinates"`
+	Neighborhoods []string    `json:"neighborhoods,omitempty"`
+	Source        string      `json:"source"` // statcan, osm, manual
+}
+
+// CityImportResult summarizes an import run
+type CityImportResult struct {
+	Added    []string `json:"added"`
+	Updated  []string `json:"updated"`
+	Skipped  []string `json:"skipped"`
+	Problems []string `json:"problems,omitempty"`
+	DryRun   bool     `json:"dry_run"`
+	Path     string   `json:"path,omitempty"`
+}
+
+// CityImportOptions controls how records are merged into the metadata
+type CityImportOptions struct {
+	// Overwrite replaces population, coordinates and neighborhoods of existing cities
+	Overwrite bool
+	// DryRun computes the result without writing the metadata
+	DryRun bool
+}
+
+// cityImportMu serializes writes to the metadata store
+var cityImportMu sync.Mutex
+
+// ParseStatCanCSV reads cities from a Statistics Canada census CSV export.
+// Recognized columns: GEO_NAME/name, PROV_NAME/province, population (or any POP_* column),
+// and optional latitude/longitude columns.
+func ParseStatCanCSV(r io.Reader) ([]CityImportRecord, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	columns := map[string]int{}
+	for i, column := range header {
+		key := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+		switch {
+		case key == "geo_name" || key == "name" || key == "csdname":
+			columns["name"] = i
+		case key == "prov_name" || key == "province" || key == "prname":
+			columns["province"] = i
+		case key == "population" || strings.HasPrefix(key, "pop_"):
+			if _, exists := columns["population"]; !exists {
+				columns["population"] = i
+			}
+		case key == "lat" || key == "latitude":
+			columns["lat"] = i
+		case key == "lng" || key == "lon" || key == "longitude":
+			columns["lng"] = i
+		}
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, fmt.Errorf("CSV is missing a GEO_NAME or name column")
+	}
+
+	field := func(row []string, column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	var records []CityImportRecord
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV row: %w", err)
+		}
+
+		name := field(row, "name")
+		if name == "" {
+			continue
+		}
+		// StatCan names carry a type suffix, e.g. "Kelowna (CY)"
+		if i := strings.Index(name, " ("); i > 0 {
+			name = name[:i]
+		}
+
+		record := CityImportRecord{
+			Name:     name,
+			Province: field(row, "province"),
+			Source:   "statcan",
+		}
+		if population, err := strconv.Atoi(strings.ReplaceAll(field(row, "population"), ",", "")); err == nil {
+			record.Population = population
+		}
+		record.Coordinates.Lat, _ = strconv.ParseFloat(field(row, "lat"), 64)
+		record.Coordinates.Lng, _ = strconv.ParseFloat(field(row, "lng"), 64)
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// overpassResponse is the subset of the Overpass JSON output used by the importer
+type overpassResponse struct {
+	Elements []struct {
+		Type   string            `json:"type"`
+		Lat    float64           `json:"lat"`
+		Lon    float64           `json:"lon"`
+		Center *Coordinates      `json:"center"`
+		Tags   map[string]string `json:"tags"`
+	} `json:"elements"`
+}
+
+// FetchOSMCity looks up a Canadian city and its neighbourhoods on OpenStreetMap
+func FetchOSMCity(ctx context.Context, name string) (CityImportRecord, error) {
+	escaped := strings.ReplaceAll(name, `"`, `\"`)
+	query := fmt.Sprintf(`[out:json][timeout:25];
+area["ISO3166-1"="CA"][admin_level=2]->.ca;
+node(area.ca)["place"~"^(city|town)$"]["name"="%s"]->.city;
+.city out;
+node(around.city:%d)["place"~"^(neighbourhood|suburb|quarter)$"];
+out;`, escaped, importNeighborhoodRadius)
+
+	endpoint := OverpassAPIURL + "?" + url.Values{"data": {query}}.Encode()
+
+	var resp overpassResponse
+	if err := getUpstreamJSON(ctx, upstreamHTTPClient(), "Overpass", endpoint, nil, &resp); err != nil {
+		return CityImportRecord{}, err
+	}
+
+	record := CityImportRecord{Name: name, Source: "osm"}
+	found := false
+	for _, element := range resp.Elements {
+		place := element.Tags["place"]
+		if (place == "city" || place == "town") && strings.EqualFold(element.Tags["name"], name) && !found {
+			found = true
+			record.Coordinates = Coordinates{Lat: element.Lat, Lng: element.Lon}
+			if element.Center != nil {
+				record.Coordinates = *element.Center
+			}
+			record.Population, _ = strconv.Atoi(strings.ReplaceAll(element.Tags["population"], ",", ""))
+			record.Province = element.Tags["is_in:province"]
+			if record.Province == "" {
+				record.Province = element.Tags["is_in:state"]
+			}
+			continue
+		}
+		if neighborhood := element.Tags["name"]; neighborhood != "" && place != "city" && place != "town" {
+			record.Neighborhoods = append(record.Neighborhoods, neighborhood)
+		}
+	}
+	if !found {
+		return CityImportRecord{}, fmt.Errorf("city '%s' not found on OpenStreetMap", name)
+	}
+
+	record.Neighborhoods = utils.RemoveDuplicates(record.Neighborhoods)
+	sort.Strings(record.Neighborhoods)
+	return record, nil
+}
+
+// ImportCities merges imported records into the city metadata and writes it to DATA_DIR
+func ImportCities(ctx context.Context, records []CityImportRecord, opts CityImportOptions) (*CityImportResult, error) {
+	cityImportMu.Lock()
+	defer cityImportMu.Unlock()
+
+	// A private copy, since the indexed metadata is shared with readers
+	metadata, err := readCityMetadata(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load city metadata: %w", err)
+	}
+	if metadata.SchemaVersion == 0 {
+		metadata.SchemaVersion = DatasetSchemaVersion
+	}
+
+	// Curated cities are used as templates for the fields open data cannot provide
+	curated, err := GetCityIndex(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load city index: %w", err)
+	}
+
+	result := &CityImportResult{Added: []string{}, Updated: []string{}, Skipped: []string{}, DryRun: opts.DryRun}
+	for _, record := range records {
+		if problem := validateImportRecord(record); problem != "" {
+			result.Problems = append(result.Problems, problem)
+			continue
+		}
+
+		existing, err := findCity(metadata, record.Name)
+		if err == nil {
+			if !opts.Overwrite {
+				result.Skipped = append(result.Skipped, record.Name)
+				continue
+			}
+			for i := range metadata.Cities {
+				if strings.EqualFold(metadata.Cities[i].Name, existing.Name) {
+					mergeImportRecord(&metadata.Cities[i], record)
+				}
+			}
+			result.Updated = append(result.Updated, existing.Name)
+			continue
+		}
+
+		city := City{
+			Name:          record.Name,
+			Province:      record.Province,
+			Country:       "Canada",
+			Attractions:   []string{},
+			Neighborhoods: []Neighborhood{},
+		}
+		mergeImportRecord(&city, record)
+
+		// Borrow timezone and seasonal data from the nearest curated city
+		if template, _, ok := curated.Nearest(record.Coordinates); ok {
+			city.Timezone = template.Timezone
+			city.Seasons = template.Seasons
+			if city.Province == "" {
+				city.Province = template.Province
+			}
+			city.Description = fmt.Sprintf("%s, %s. Imported from %s open data; seasonal information is based on nearby %s.", record.Name, city.Province, record.Source, template.Name)
+		}
+
+		metadata.Cities = append(metadata.Cities, city)
+		result.Added = append(result.Added, record.Name)
+	}
+
+	if opts.DryRun || (len(result.Added) == 0 && len(result.Updated) == 0) {
+		return result, nil
+	}
+
+	// Imports go to the tenant's own data directory when it has one
+	dirs := datasetDirs(ctx)
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("DATA_DIR must be set to write imported cities")
+	}
+	dir := dirs[0]
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	content, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal city metadata: %w", err)
+	}
+
+	// Write atomically so readers never see a partial file
+	path := filepath.Join(dir, CityMetadataDataset)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write city metadata: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, fmt.Errorf("failed to replace city metadata: %w", err)
+	}
+
+	// Serve the imported cities without a restart
+	if _, err := ReloadCityIndex(ctx); err != nil {
+		return nil, fmt.Errorf("failed to reload city index: %w", err)
+	}
+
+	result.Path = path
+	return result, nil
+}
+
+// validateImportRecord returns a description of why a record cannot be imported, or ""
+func validateImportRecord(record CityImportRecord) string {
+	if record.Name == "" {
+		return "record without a name"
+	}
+	if record.Coordinates.Lat == 0 && record.Coordinates.Lng == 0 {
+		return fmt.Sprintf("%s: missing coordinates", record.Name)
+	}
+	if record.Coordinates.Lat < 41 || record.Coordinates.Lat > 84 || record.Coordinates.Lng < -142 || record.Coordinates.Lng > -52 {
+		return fmt.Sprintf("%s: coordinates are outside Canada", record.Name)
+	}
+	return ""
+}
+
+// mergeImportRecord copies open data fields onto a city
+func mergeImportRecord(city *City, record CityImportRecord) {
+	city.Coordinates = record.Coordinates
+	if record.Population > 0 {
+		city.Population = record.Population
+	}
+	if record.Province != "" {
+		city.Province = record.Province
+	}
+	if len(record.Neighborhoods) > 0 {
+		// Keep the curated characteristics of neighborhoods that are already known
+		known := make(map[string]Neighborhood, len(city.Neighborhoods))
+		for _, neighborhood := range city.Neighborhoods {
+			known[strings.ToLower(neighborhood.Name)] = neighborhood
+		}
+		neighborhoods := make([]Neighborhood, 0, len(record.Neighborhoods))
+		for _, name := range record.Neighborhoods {
+			if neighborhood, ok := known[strings.ToLower(name)]; ok {
+				neighborhoods = append(neighborhoods, neighborhood)
+				continue
+			}
+			neighborhoods = append(neighborhoods, Neighborhood{Name: name, Vibes: []string{}})
+		}
+		city.Neighborhoods = neighborhoods
+	}
+}
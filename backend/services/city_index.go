@@ -0,0 +1,252 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/joshndala/cantrip/utils"
+	"golang.org/x/text/unicode/norm"
+)
+
+// CityIndex is the city metadata parsed once, with lookups by name, by province and by
+// nearest coordinates, and city and attraction names for autocomplete. It is read-only;
+// ReloadCityIndex swaps in a new one.
+type CityIndex struct {
+	metadata    *CityMetadata
+	byName      map[string]*City
+	byProvince  map[string][]*City
+	tree        *cityNode
+	completions []completionEntry // city and attraction names for autocomplete
+}
+
+// cityNode is a k-d tree node over cities as points on the unit sphere. Euclidean distance
+// between those points orders cities the same way as great-circle distance, without the
+// longitude wraparound of a latitude/longitude tree.
+type cityNode struct {
+	city        *City
+	point       [3]float64
+	axis        int
+	left, right *cityNode
+}
+
+var (
+	cityIndexMu sync.RWMutex
+	cityIndexes = map[string]*CityIndex{} // by tenant ID, since tenants may bring their own cities
+)
+
+// GetCityIndex returns the city index of the tenant ctx acts for, building it on first use
+func GetCityIndex(ctx context.Context) (*CityIndex, error) {
+	cityIndexMu.RLock()
+	index := cityIndexes[TenantFromContext(ctx).ID]
+	cityIndexMu.RUnlock()
+	if index != nil {
+		return index, nil
+	}
+	return ReloadCityIndex(ctx)
+}
+
+// ReloadCityIndex re-reads city_metadata.json, for example after an import into DATA_DIR.
+// Lookups in progress keep the index they started with.
+func ReloadCityIndex(ctx context.Context) (*CityIndex, error) {
+	metadata, err := readCityMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	index := NewCityIndex(metadata)
+	tenant := TenantFromContext(ctx).ID
+
+	cityIndexMu.Lock()
+	cityIndexes[tenant] = index
+	cityIndexMu.Unlock()
+	utils.LogInfo(fmt.Sprintf("Indexed %d cities for tenant %s", len(metadata.Cities), tenant))
+	return index, nil
+}
+
+// readCityMetadata parses city_metadata.json into a fresh value the caller may modify
+func readCityMetadata(ctx context.Context) (*CityMetadata, error) {
+	data, err := ReadDataset(ctx, CityMetadataDataset)
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata CityMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, err
+	}
+
+	return &metadata, nil
+}
+
+// NewCityIndex indexes metadata, which must not be modified afterwards
+func NewCityIndex(metadata *CityMetadata) *CityIndex {
+	index := &CityIndex{
+		metadata:   metadata,
+		byName:     make(map[string]*City, len(metadata.Cities)),
+		byProvince: map[string][]*City{},
+	}
+
+	nodes := make([]*cityNode, 0, len(metadata.Cities))
+	for i := range metadata.Cities {
+		city := &metadata.Cities[i]
+		// The first city with a name wins, as with the linear search this replaces
+		if key := NormalizeCityName(city.Name); key != "" {
+			if _, exists := index.byName[key]; !exists {
+				index.byName[key] = city
+			}
+		}
+		if province := NormalizeCityName(city.Province); province != "" {
+			index.byProvince[province] = append(index.byProvince[province], city)
+		}
+		nodes = append(nodes, &cityNode{city: city, point: unitVector(city.Coordinates)})
+	}
+	index.tree = buildCityTree(nodes, 0)
+	index.completions = buildCompletions(metadata)
+	return index
+}
+
+// NormalizeCityName folds case, accents and punctuation, so "Montréal", "montreal" and
+// "Trois-Rivières" and "trois rivieres" match
+func NormalizeCityName(name string) string {
+	var folded strings.Builder
+	space := false
+	for _, r := range norm.NFD.String(name) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if space && folded.Len() > 0 {
+				folded.WriteByte(' ')
+			}
+			space = false
+			folded.WriteRune(unicode.ToLower(r))
+		default:
+			space = true
+		}
+	}
+	return folded.String()
+}
+
+// Metadata returns the indexed metadata; callers must not modify it
+func (ix *CityIndex) Metadata() *CityMetadata {
+	return ix.metadata
+}
+
+// Find returns a city by name
+func (ix *CityIndex) Find(name string) (*City, bool) {
+	city, ok := ix.byName[NormalizeCityName(name)]
+	return city, ok
+}
+
+// InProvince returns a province's cities in dataset order
+func (ix *CityIndex) InProvince(province string) []*City {
+	return ix.byProvince[NormalizeCityName(province)]
+}
+
+// Provinces lists the provinces with cities, sorted
+func (ix *CityIndex) Provinces() []string {
+	provinces := make([]string, 0, len(ix.byProvince))
+	for _, cities := range ix.byProvince {
+		provinces = append(provinces, cities[0].Province)
+	}
+	sort.Strings(provinces)
+	return provinces
+}
+
+// Nearest returns the city closest to coordinates and its distance in kilometres
+func (ix *CityIndex) Nearest(coordinates Coordinates) (*City, float64, bool) {
+	if ix.tree == nil {
+		return nil, 0, false
+	}
+	target := unitVector(coordinates)
+	var best *cityNode
+	bestDist := math.MaxFloat64
+	ix.tree.nearest(target, &best, &bestDist)
+	return best.city, utils.CalculateDistance(coordinates.Lat, coordinates.Lng, best.city.Coordinates.Lat, best.city.Coordinates.Lng), true
+}
+
+// Within returns the cities within radiusKm of coordinates, nearest first
+func (ix *CityIndex) Within(coordinates Coordinates, radiusKm float64) []*City {
+	if ix.tree == nil || radiusKm <= 0 {
+		return nil
+	}
+	// The chord subtending a great-circle arc of radiusKm
+	chord := 2 * math.Sin(math.Min(radiusKm/earthRadiusKm, math.Pi)/2)
+
+	var found []*City
+	ix.tree.within(unitVector(coordinates), chord*chord, &found)
+	distance := func(city *City) float64 {
+		return utils.CalculateDistance(coordinates.Lat, coordinates.Lng, city.Coordinates.Lat, city.Coordinates.Lng)
+	}
+	sort.SliceStable(found, func(i, j int) bool { return distance(found[i]) < distance(found[j]) })
+	return found
+}
+
+// earthRadiusKm matches utils.CalculateDistance
+const earthRadiusKm = 6371
+
+// unitVector places coordinates on the unit sphere
+func unitVector(c Coordinates) [3]float64 {
+	lat, lng := c.Lat*math.Pi/180, c.Lng*math.Pi/180
+	return [3]float64{math.Cos(lat) * math.Cos(lng), math.Cos(lat) * math.Sin(lng), math.Sin(lat)}
+}
+
+func squaredDistance(a, b [3]float64) float64 {
+	dx, dy, dz := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return dx*dx + dy*dy + dz*dz
+}
+
+// buildCityTree splits nodes at the median of each axis in turn
+func buildCityTree(nodes []*cityNode, depth int) *cityNode {
+	if len(nodes) == 0 {
+		return nil
+	}
+	axis := depth % 3
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].point[axis] < nodes[j].point[axis] })
+	mid := len(nodes) / 2
+	node := nodes[mid]
+	node.axis = axis
+	node.left = buildCityTree(nodes[:mid], depth+1)
+	node.right = buildCityTree(nodes[mid+1:], depth+1)
+	return node
+}
+
+func (n *cityNode) nearest(target [3]float64, best **cityNode, bestDist *float64) {
+	if n == nil {
+		return
+	}
+	if d := squaredDistance(n.point, target); d < *bestDist {
+		*best, *bestDist = n, d
+	}
+	diff := target[n.axis] - n.point[n.axis]
+	near, far := n.left, n.right
+	if diff > 0 {
+		near, far = far, near
+	}
+	near.nearest(target, best, bestDist)
+	// The far side can only hold a closer city if the splitting plane is closer
+	if diff*diff < *bestDist {
+		far.nearest(target, best, bestDist)
+	}
+}
+
+func (n *cityNode) within(target [3]float64, limit float64, found *[]*City) {
+	if n == nil {
+		return
+	}
+	if squaredDistance(n.point, target) <= limit {
+		*found = append(*found, n.city)
+	}
+	diff := target[n.axis] - n.point[n.axis]
+	if diff <= 0 || diff*diff <= limit {
+		n.left.within(target, limit, found)
+	}
+	if diff >= 0 || diff*diff <= limit {
+		n.right.within(target, limit, found)
+	}
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrVersionConflict is returned when a write is based on an outdated version
+var ErrVersionConflict = errors.New("version conflict")
+
+// VersionConflictError reports the version a stale write was based on and the current document
+type VersionConflictError struct {
+	Resource string
+	ID       string
+	Expected int
+	Current  int
+	Document interface{} // latest stored version, for client-side merge
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("%s %s is at version %d, write was based on version %d", e.Resource, e.ID, e.Current, e.Expected)
+}
+
+// Unwrap lets callers match the conflict with errors.Is(err, ErrVersionConflict)
+func (e *VersionConflictError) Unwrap() error {
+	return ErrVersionConflict
+}
+
+// documentLock is a document's mutex and how many callers hold or wait for it
+type documentLock struct {
+	mu    sync.Mutex
+	users int
+}
+
+// documentLocks serializes read-modify-write cycles per document within this process.
+// Entries are dropped once no one holds or waits for them, so the map only grows with
+// the documents being written at the same time.
+var (
+	documentLocksMu sync.Mutex
+	documentLocks   = map[string]*documentLock{} // collection/id
+)
+
+// lockDocument locks a single document and returns the unlock function
+func lockDocument(collection, id string) func() {
+	key := collection + "/" + id
+	documentLocksMu.Lock()
+	lock, ok := documentLocks[key]
+	if !ok {
+		lock = &documentLock{}
+		documentLocks[key] = lock
+	}
+	lock.users++
+	documentLocksMu.Unlock()
+
+	lock.mu.Lock()
+	return func() {
+		lock.mu.Unlock()
+		documentLocksMu.Lock()
+		if lock.users--; lock.users == 0 {
+			delete(documentLocks, key)
+		}
+		documentLocksMu.Unlock()
+	}
+}
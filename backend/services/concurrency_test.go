@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLockDocumentSerializesAndForgets(t *testing.T) {
+	const writers = 50
+	var wg sync.WaitGroup
+	counts := map[string]*int{"trip-1": new(int), "trip-2": new(int)}
+	for i := 0; i < writers; i++ {
+		for _, id := range []string{"trip-1", "trip-2"} {
+			wg.Add(1)
+			go func(id string) {
+				defer wg.Done()
+				unlock := lockDocument(ItineraryCollection, id)
+				defer unlock()
+				*counts[id]++ // raced unless the lock serializes writers of a document
+			}(id)
+		}
+	}
+
+	// A document locked by another collection is separate
+	unlock := lockDocument(PackingListCollection, "trip-1")
+	wg.Wait()
+	unlock()
+
+	for _, id := range []string{"trip-1", "trip-2"} {
+		if *counts[id] != writers {
+			t.Errorf("%s written %d times, want %d", id, *counts[id], writers)
+		}
+	}
+
+	documentLocksMu.Lock()
+	defer documentLocksMu.Unlock()
+	if len(documentLocks) != 0 {
+		t.Errorf("%d locks kept after every writer finished", len(documentLocks))
+	}
+}
+
+func TestConditionalSaveOfMissingDocument(t *testing.T) {
+	t.Chdir(t.TempDir())
+	ctx := context.Background()
+
+	err := SaveItineraryIfRevision(ctx, &ItineraryResponse{ID: "missing"}, 1)
+	if !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("SaveItineraryIfRevision on a missing itinerary = %v, want ErrDocumentNotFound", err)
+	}
+	err = SavePackingListIfVersion(ctx, &PackingResponse{ID: "missing"}, 1)
+	if !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("SavePackingListIfVersion on a missing list = %v, want ErrDocumentNotFound", err)
+	}
+}
+
+func TestReplaceKeepsMissingAndTrashedDocumentsGone(t *testing.T) {
+	t.Chdir(t.TempDir())
+	ctx := context.Background()
+	trashedAt := time.Now().UTC()
+
+	if err := SaveItinerary(ctx, &ItineraryResponse{ID: "trip", OwnerID: "owner-1"}); err != nil {
+		t.Fatalf("SaveItinerary: %v", err)
+	}
+	if err := SaveItinerary(ctx, &ItineraryResponse{ID: "trashed", OwnerID: "owner-1", DeletedAt: &trashedAt}); err != nil {
+		t.Fatalf("SaveItinerary: %v", err)
+	}
+	if err := SavePackingList(ctx, &PackingResponse{ID: "trashed", DeletedAt: &trashedAt}); err != nil {
+		t.Fatalf("SavePackingList: %v", err)
+	}
+
+	tests := []struct {
+		id   string
+		want error
+	}{
+		{"missing", ErrDocumentNotFound},
+		{"trashed", ErrInTrash},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			if err := ReplaceItinerary(ctx, &ItineraryResponse{ID: tt.id}); !errors.Is(err, tt.want) {
+				t.Errorf("ReplaceItinerary = %v, want %v", err, tt.want)
+			}
+			if err := SaveItineraryIfRevision(ctx, &ItineraryResponse{ID: tt.id}, 1); !errors.Is(err, tt.want) {
+				t.Errorf("SaveItineraryIfRevision = %v, want %v", err, tt.want)
+			}
+			if err := ReplacePackingList(ctx, &PackingResponse{ID: tt.id}); !errors.Is(err, tt.want) {
+				t.Errorf("ReplacePackingList = %v, want %v", err, tt.want)
+			}
+			if err := SavePackingListIfVersion(ctx, &PackingResponse{ID: tt.id}, 1); !errors.Is(err, tt.want) {
+				t.Errorf("SavePackingListIfVersion = %v, want %v", err, tt.want)
+			}
+		})
+	}
+	if _, err := GetItinerary(ctx, "trashed"); !errors.Is(err, ErrInTrash) {
+		t.Errorf("trashed itinerary restored by an edit: %v", err)
+	}
+
+	// An edit without the owner keeps the stored one
+	if err := ReplaceItinerary(ctx, &ItineraryResponse{ID: "trip", Success: true}); err != nil {
+		t.Fatalf("ReplaceItinerary: %v", err)
+	}
+	itinerary, err := GetItinerary(ctx, "trip")
+	if err != nil {
+		t.Fatalf("GetItinerary: %v", err)
+	}
+	if itinerary.OwnerID != "owner-1" || !itinerary.Success {
+		t.Errorf("replaced itinerary = owner %q, success %v", itinerary.OwnerID, itinerary.Success)
+	}
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+)
+
+// ConnectivityData represents the structure of connectivity.json
+type ConnectivityData struct {
+	Note            string           `json:"note"`
+	Carriers        []Carrier        `json:"carriers"`
+	CoverageCaveats []CoverageCaveat `json:"coverage_caveats"`
+	WiFiTips        []WiFiTip        `json:"wifi_tips"`
+}
+
+// Carrier is a mobile carrier or eSIM seller with prepaid options for visitors
+type Carrier struct {
+	ID      string        `json:"id"`
+	Name    string        `json:"name"`
+	Network string        `json:"network"` // the towers it uses
+	ESIM    bool          `json:"esim"`
+	Prepaid bool          `json:"prepaid"`
+	SoldAt  []string      `json:"sold_at"`
+	Plans   []CarrierPlan `json:"plans"`
+	Notes   string        `json:"notes,omitempty"`
+}
+
+// CarrierPlan is a prepaid plan's typical price in CAD
+type CarrierPlan struct {
+	Name   string  `json:"name"`
+	Price  float64 `json:"price"`
+	Days   int     `json:"days"`
+	DataGB float64 `json:"data_gb"`
+}
+
+// CoverageCaveat warns about weak or missing coverage in a region
+type CoverageCaveat struct {
+	Region       string   `json:"region"`
+	Destinations []string `json:"destinations"`
+	Provinces    []string `json:"provinces"` // the whole province or territory when listed
+	Caveat       string   `json:"caveat"`
+	Advice       string   `json:"advice"`
+}
+
+// WiFiTip is general advice about finding and using Wi-Fi
+type WiFiTip struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+// ConnectivityInfo is the connectivity advice for a destination
+type ConnectivityInfo struct {
+	Destination     string           `json:"destination"`
+	Carriers        []Carrier        `json:"carriers"`
+	CoverageCaveats []CoverageCaveat `json:"coverage_caveats"` // only those for the destination
+	WiFiTips        []WiFiTip        `json:"wifi_tips"`
+	Note            string           `json:"note"`
+}
+
+// loadConnectivityData loads the carrier and coverage dataset
+func loadConnectivityData(ctx context.Context) (*ConnectivityData, error) {
+	data, err := ReadDataset(ctx, ConnectivityDataset)
+	if err != nil {
+		return nil, err
+	}
+
+	var connectivity ConnectivityData
+	if err := json.Unmarshal(data, &connectivity); err != nil {
+		return nil, err
+	}
+	return &connectivity, nil
+}
+
+// GetConnectivityInfo returns SIM and eSIM options, coverage caveats for the destination or
+// its province, and Wi-Fi tips. With esimOnly, carriers without eSIM are left out.
+func GetConnectivityInfo(ctx context.Context, destination string, esimOnly bool) (*ConnectivityInfo, error) {
+	data, err := loadConnectivityData(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	province := ""
+	if metadata, err := loadCityMetadata(ctx); err == nil {
+		if city, err := findCity(metadata, destination); err == nil {
+			province = city.Province
+		}
+	}
+
+	info := &ConnectivityInfo{
+		Destination:     destination,
+		Carriers:        []Carrier{},
+		CoverageCaveats: []CoverageCaveat{},
+		WiFiTips:        data.WiFiTips,
+		Note:            data.Note,
+	}
+	for _, carrier := range data.Carriers {
+		if !esimOnly || carrier.ESIM {
+			info.Carriers = append(info.Carriers, carrier)
+		}
+	}
+	for _, caveat := range data.CoverageCaveats {
+		if containsFold(caveat.Destinations, strings.TrimSpace(destination)) || (province != "" && containsFold(caveat.Provinces, province)) {
+			info.CoverageCaveats = append(info.CoverageCaveats, caveat)
+		}
+	}
+	return info, nil
+}
@@ -1,88 +1,129 @@
 package services
 
 import (
-	"bufio"
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
-	"os"
 	"strings"
 	"time"
 
-	"github.com/gin-gonic/gin"
+	"github.com/joshndala/cantrip/models"
 )
 
 // ChatMessage represents a message in the conversation
 type ChatMessage struct {
 	Role      string    `json:"role"` // "user" or "assistant"
 	Message   string    `json:"message"`
+	Turn      int       `json:"turn,omitempty"` // shared by a question and its answer, counted from 1
 	Timestamp time.Time `json:"timestamp"`
 }
 
 // ConversationSession represents a chat session
 type ConversationSession struct {
 	SessionID   string                 `json:"session_id"`
+	UserID      string                 `json:"user_id,omitempty"`
 	Context     map[string]interface{} `json:"context"`
 	History     []ChatMessage          `json:"history"`
+	Itineraries []ItinerarySnapshot    `json:"itineraries,omitempty"` // kept through compaction for transcripts
 	CreatedAt   time.Time              `json:"created_at"`
 	LastUpdated time.Time              `json:"last_updated"`
 }
 
 // ChatResponse represents the AI agent's response
-type ChatResponse struct {
-	Response    string                 `json:"response"`
-	SessionID   string                 `json:"session_id"`
-	Intent      string                 `json:"intent"`
-	Confidence  float64                `json:"confidence"`
-	Suggestions []string               `json:"suggestions,omitempty"`
-	Data        map[string]interface{} `json:"data,omitempty"`
-	Timestamp   string                 `json:"timestamp"`
-}
+type ChatResponse = models.ChatResponse
+
+// fallbackChatResponse is sent when the agent cannot be reached
+const fallbackChatResponse = "I'm your AI Canadian travel assistant! I can help you plan trips across Canada, suggest destinations, create itineraries, and more. What would you like to know?"
 
-// In-memory session storage (in production, use Redis or database)
+// In-memory session storage (in production, use Redis or database), keyed by tenantKey
 var sessions = make(map[string]*ConversationSession)
 
 // GetOrCreateSession gets an existing session or creates a new one
-func GetOrCreateSession(sessionID, userID string) (*ConversationSession, error) {
+func GetOrCreateSession(ctx context.Context, sessionID, userID string) (*ConversationSession, error) {
 	if sessionID == "" {
 		// Generate a new session ID
 		sessionID = fmt.Sprintf("session_%d", time.Now().UnixNano())
 	}
 
-	if session, exists := sessions[sessionID]; exists {
+	if session, exists := sessions[tenantKey(ctx, sessionID)]; exists {
 		session.LastUpdated = time.Now()
+		if session.UserID == "" {
+			session.UserID = userID
+		}
 		return session, nil
 	}
 
 	// Create new session
 	session := &ConversationSession{
 		SessionID:   sessionID,
+		UserID:      userID,
 		Context:     make(map[string]interface{}),
 		History:     []ChatMessage{},
 		CreatedAt:   time.Now(),
 		LastUpdated: time.Now(),
 	}
 
-	sessions[sessionID] = session
+	sessions[tenantKey(ctx, sessionID)] = session
 	return session, nil
 }
 
-// ProcessChatMessage processes a user message with the AI agent
-func ProcessChatMessage(message string, session *ConversationSession) (*ChatResponse, error) {
+// ProcessChatMessage processes a user message with the AI agent. Harmful messages are
+// answered with a refusal without reaching the agent, and harmful replies are replaced.
+func ProcessChatMessage(ctx context.Context, message string, session *ConversationSession) (*ChatResponse, error) {
+	verdict, err := ModerateChatInput(ctx, session, message)
+	if err != nil {
+		return nil, err
+	}
+	if verdict.Action == ModerationBlock {
+		return moderatedResponse(session, verdict.Reply, ModerationInput), nil
+	}
+
 	// Call LangGraph agent for processing
-	response, err := callLangGraphAgent(message, session)
+	response, err := callLangGraphAgent(ctx, message, session)
 	if err != nil {
 		return nil, fmt.Errorf("failed to process message with AI agent: %w", err)
 	}
 
+	if reply, verdict := ModerateChatReply(ctx, session, response.Response); verdict.Action == ModerationBlock {
+		return moderatedResponse(session, reply, ModerationOutput), nil
+	}
+
+	response.Cards = BuildChatCards(response.Data)
+	response.Intents = ChatIntents(response.Intent, response.Data, response.Cards)
 	return response, nil
 }
 
-// ProcessChatMessageStream processes a user message with streaming response
-func ProcessChatMessageStream(message string, session *ConversationSession, c *gin.Context) error {
+// moderatedResponse is the refusal sent in place of a blocked message or reply
+func moderatedResponse(session *ConversationSession, reply, stage string) *ChatResponse {
+	return &ChatResponse{
+		Response:  reply,
+		SessionID: session.SessionID,
+		Intent:    "moderated",
+		Cards:     []ChatCard{},
+		Moderated: stage,
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+}
+
+// ProcessChatMessageStream processes a user message with streaming response, publishing the
+// reply's events to stream. A blocked message streams the refusal in place of the agent's reply.
+func ProcessChatMessageStream(ctx context.Context, message string, session *ConversationSession, stream *ChatStream) error {
+	verdict, err := ModerateChatInput(ctx, session, message)
+	if err != nil {
+		return err
+	}
+	if verdict.Action == ModerationBlock {
+		for _, chunk := range []map[string]interface{}{
+			{"type": "token", "content": verdict.Reply, "session_id": session.SessionID},
+			{"type": "done", "session_id": session.SessionID, "intent": "moderated", "moderated": ModerationInput},
+		} {
+			stream.publishJSON(chunk)
+		}
+		return nil
+	}
+
 	// Call streaming LangGraph agent
-	err := callLangGraphAgentStream(message, session, c)
+	err = callLangGraphAgentStream(ctx, message, session, stream)
 	if err != nil {
 		return fmt.Errorf("failed to process message with AI agent: %w", err)
 	}
@@ -90,17 +131,22 @@ func ProcessChatMessageStream(message string, session *ConversationSession, c *g
 	return nil
 }
 
-// UpdateSession updates the session with new messages
-func UpdateSession(sessionID, userMessage, aiResponse string) error {
-	session, exists := sessions[sessionID]
+// UpdateSession updates the session with new messages and returns the turn they were
+// recorded as. Itinerary cards in the reply are kept as snapshots of what was generated.
+func UpdateSession(ctx context.Context, sessionID, userMessage, aiResponse string, cards []ChatCard) (int, error) {
+	session, exists := sessions[tenantKey(ctx, sessionID)]
 	if !exists {
-		return fmt.Errorf("session not found: %s", sessionID)
+		return 0, fmt.Errorf("session not found: %s", sessionID)
 	}
 
+	// Turns keep counting through compaction, so feedback can refer to them
+	turn := (summarizedMessages(session)+len(session.History))/2 + 1
+
 	// Add user message to history
 	session.History = append(session.History, ChatMessage{
 		Role:      "user",
 		Message:   userMessage,
+		Turn:      turn,
 		Timestamp: time.Now(),
 	})
 
@@ -108,16 +154,62 @@ func UpdateSession(sessionID, userMessage, aiResponse string) error {
 	session.History = append(session.History, ChatMessage{
 		Role:      "assistant",
 		Message:   aiResponse,
+		Turn:      turn,
 		Timestamp: time.Now(),
 	})
 
+	for _, card := range cards {
+		if card.Type == ChatCardItinerary && card.Itinerary != nil {
+			session.Itineraries = append(session.Itineraries, ItinerarySnapshot{Turn: turn, ItineraryPreviewCard: *card.Itinerary})
+		}
+	}
+
 	session.LastUpdated = time.Now()
-	return nil
+	compactSession(context.WithoutCancel(ctx), session)
+	return turn, nil
 }
 
-// GetConversationHistory returns the conversation history
-func GetConversationHistory(sessionID string) ([]ChatMessage, error) {
-	session, exists := sessions[sessionID]
+// SessionSummary lists a chat session without its full history
+type SessionSummary struct {
+	SessionID    string    `json:"session_id"`
+	MessageCount int       `json:"message_count"`
+	LastMessage  string    `json:"last_message,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	LastUpdated  time.Time `json:"last_updated"`
+}
+
+// ListSessions returns summaries of a user's chat sessions
+func ListSessions(ctx context.Context, userID string) []SessionSummary {
+	summaries := []SessionSummary{}
+	tenantPrefix := tenantKey(ctx, "")
+	for key, session := range sessions {
+		if !strings.HasPrefix(key, tenantPrefix) || session.UserID != userID {
+			continue
+		}
+		summary := SessionSummary{
+			SessionID:    session.SessionID,
+			MessageCount: len(session.History) + summarizedMessages(session),
+			CreatedAt:    session.CreatedAt,
+			LastUpdated:  session.LastUpdated,
+		}
+		if len(session.History) > 0 {
+			summary.LastMessage = session.History[len(session.History)-1].Message
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
+// summarizedMessages counts the messages compacted into the session's summary
+func summarizedMessages(session *ConversationSession) int {
+	count, _ := session.Context[ContextSummarizedMessages].(int)
+	return count
+}
+
+// GetConversationHistory returns the conversation history; messages compacted into the
+// summary are no longer listed
+func GetConversationHistory(ctx context.Context, sessionID string) ([]ChatMessage, error) {
+	session, exists := sessions[tenantKey(ctx, sessionID)]
 	if !exists {
 		return nil, fmt.Errorf("session not found: %s", sessionID)
 	}
@@ -126,13 +218,14 @@ func GetConversationHistory(sessionID string) ([]ChatMessage, error) {
 }
 
 // ClearConversation clears the conversation history
-func ClearConversation(sessionID string) error {
-	session, exists := sessions[sessionID]
+func ClearConversation(ctx context.Context, sessionID string) error {
+	session, exists := sessions[tenantKey(ctx, sessionID)]
 	if !exists {
 		return fmt.Errorf("session not found: %s", sessionID)
 	}
 
 	session.History = []ChatMessage{}
+	session.Itineraries = nil
 	session.Context = make(map[string]interface{})
 	session.LastUpdated = time.Now()
 
@@ -140,8 +233,8 @@ func ClearConversation(sessionID string) error {
 }
 
 // GetConversationSuggestions returns suggested follow-up questions
-func GetConversationSuggestions(sessionID string) ([]string, error) {
-	session, exists := sessions[sessionID]
+func GetConversationSuggestions(ctx context.Context, sessionID string) ([]string, error) {
+	session, exists := sessions[tenantKey(ctx, sessionID)]
 	if !exists {
 		return nil, fmt.Errorf("session not found: %s", sessionID)
 	}
@@ -156,7 +249,7 @@ func GetConversationSuggestions(sessionID string) ([]string, error) {
 }
 
 // callLangGraphAgent calls the LangGraph agent for message processing
-func callLangGraphAgent(message string, session *ConversationSession) (*ChatResponse, error) {
+func callLangGraphAgent(ctx context.Context, message string, session *ConversationSession) (*ChatResponse, error) {
 	// Prepare the request data
 	requestData := map[string]interface{}{
 		"message":    message,
@@ -165,25 +258,17 @@ func callLangGraphAgent(message string, session *ConversationSession) (*ChatResp
 		"history":    session.History,
 	}
 
-	// Convert to JSON
-	jsonData, err := json.Marshal(requestData)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	// Make HTTP request to LangGraph agent
-	// In Docker: use service name, in development: use localhost
-	agentURL := "http://cantrip-agent:8001/chat"
-	if os.Getenv("DOCKER_ENV") == "" {
-		agentURL = "http://localhost:8001/chat"
-	}
-	resp, err := http.Post(agentURL, "application/json", bytes.NewBuffer(jsonData))
+	var response ChatResponse
+	err := GetAIClient().transport.Call(ctx, AgentMethodChat, requestData, &response)
 	if err != nil {
 		// Log the error for debugging
 		fmt.Printf("Error calling LangGraph agent: %v\n", err)
+		if AgentErrorCodeOf(err) == AgentErrInvalidArgument {
+			return nil, err
+		}
 		// For now, return a mock response since LangGraph agent might not be running
 		return &ChatResponse{
-			Response:    "I'm your AI Canadian travel assistant! I can help you plan trips across Canada, suggest destinations, create itineraries, and more. What would you like to know?",
+			Response:    fallbackChatResponse,
 			SessionID:   session.SessionID,
 			Intent:      "greeting",
 			Confidence:  0.8,
@@ -191,21 +276,12 @@ func callLangGraphAgent(message string, session *ConversationSession) (*ChatResp
 			Timestamp:   time.Now().Format(time.RFC3339),
 		}, nil
 	}
-	defer resp.Body.Close()
-
-	// Parse response
-	var response ChatResponse
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		fmt.Printf("Error decoding LangGraph response: %v\n", err)
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
 
-	fmt.Printf("Successfully got response from LangGraph agent: %s\n", response.Response[:50])
 	return &response, nil
 }
 
 // callLangGraphAgentStream calls the LangGraph agent for streaming message processing
-func callLangGraphAgentStream(message string, session *ConversationSession, c *gin.Context) error {
+func callLangGraphAgentStream(ctx context.Context, message string, session *ConversationSession, stream *ChatStream) error {
 	// Prepare the request data
 	requestData := map[string]interface{}{
 		"message":    message,
@@ -214,107 +290,77 @@ func callLangGraphAgentStream(message string, session *ConversationSession, c *g
 		"history":    session.History,
 	}
 
-	// Convert to JSON
-	jsonData, err := json.Marshal(requestData)
-	if err != nil {
-		return fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	// Make HTTP request to streaming LangGraph agent
-	agentURL := "http://cantrip-agent:8001/chat/stream"
-	if os.Getenv("DOCKER_ENV") == "" {
-		agentURL = "http://localhost:8001/chat/stream"
-	}
-
-	// Create HTTP client with proper timeout for streaming
-	client := &http.Client{
-		Timeout: 0, // No timeout for streaming
-	}
-
-	req, err := http.NewRequest("POST", agentURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		fmt.Printf("Error creating request: %v\n", err)
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "text/event-stream")
-	req.Header.Set("Cache-Control", "no-cache")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		fmt.Printf("Error calling streaming LangGraph agent: %v\n", err)
-		// Send fallback response
-		fallbackResponse := "I'm your AI Canadian travel assistant! I can help you plan trips across Canada, suggest destinations, create itineraries, and more. What would you like to know?"
-		words := strings.Fields(fallbackResponse)
-		for _, word := range words {
-			chunk := map[string]interface{}{
-				"type":       "token",
-				"content":    word + " ",
-				"session_id": session.SessionID,
-				"intent":     "greeting",
-				"confidence": 0.8,
-				"timestamp":  time.Now().Format(time.RFC3339),
+	var fullResponse strings.Builder
+	forwarded := false
+
+	err := GetAIClient().transport.Stream(ctx, AgentMethodChatStream, requestData, func(chunk AgentStreamChunk) error {
+		// The done chunk completes the reply: record it, then add its turn and cards
+		if chunk.Type == "done" {
+			// Tokens already reached the client, so a blocked reply is replaced after the fact
+			reply, verdict := ModerateChatReply(ctx, session, fullResponse.String())
+			if verdict.Action == ModerationBlock {
+				chunk.Fields["moderated"] = ModerationOutput
+				chunk.Fields["replacement"] = reply
+				delete(chunk.Fields, "data")
+			}
+			var cards []ChatCard
+			if data, ok := chunk.Fields["data"].(map[string]interface{}); ok {
+				cards = BuildChatCards(data)
+				chunk.Fields["cards"] = cards
+			}
+			turn, err := UpdateSession(ctx, session.SessionID, message, reply, cards)
+			if err == nil {
+				chunk.Fields["turn"] = turn
+			}
+			if encoded, err := json.Marshal(chunk.Fields); err == nil {
+				chunk.Data = encoded
 			}
-			chunkJSON, _ := json.Marshal(chunk)
-			fmt.Fprintf(c.Writer, "data: %s\n\n", chunkJSON)
-			c.Writer.Flush()
-			time.Sleep(50 * time.Millisecond)
 		}
 
-		// Send done signal
-		doneChunk := map[string]interface{}{
-			"type":       "done",
-			"session_id": session.SessionID,
+		// Forward the chunk to the client
+		stream.publish(chunk.Data)
+		forwarded = true
+
+		// Collect the full response for session update
+		if chunk.Type == "token" {
+			if content, ok := chunk.Fields["content"].(string); ok {
+				fullResponse.WriteString(content)
+			}
 		}
-		doneJSON, _ := json.Marshal(doneChunk)
-		fmt.Fprintf(c.Writer, "data: %s\n\n", doneJSON)
-		c.Writer.Flush()
+		return nil
+	})
+	if err == nil {
 		return nil
 	}
-	defer resp.Body.Close()
 
-	// Stream the response
-	scanner := bufio.NewScanner(resp.Body)
-	var fullResponse strings.Builder
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(line, "data: ") {
-			data := strings.TrimPrefix(line, "data: ")
-			if data == "" {
-				continue
-			}
-
-			// Parse the chunk
-			var chunk map[string]interface{}
-			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
-				continue
-			}
+	// Once chunks have reached the client the stream cannot be replaced
+	if forwarded {
+		return fmt.Errorf("error reading stream: %w", err)
+	}
 
-			// Forward the chunk to the client
-			fmt.Fprintf(c.Writer, "data: %s\n\n", data)
-			c.Writer.Flush()
+	fmt.Printf("Error calling streaming LangGraph agent: %v\n", err)
 
-			// Collect the full response for session update
-			if chunkType, ok := chunk["type"].(string); ok && chunkType == "token" {
-				if content, ok := chunk["content"].(string); ok {
-					fullResponse.WriteString(content)
-				}
-			}
-
-			// If this is the done signal, update the session
-			if chunkType, ok := chunk["type"].(string); ok && chunkType == "done" {
-				// Update session with the complete response
-				UpdateSession(session.SessionID, message, fullResponse.String())
-				break
-			}
+	// Send fallback response
+	words := strings.Fields(fallbackChatResponse)
+	for _, word := range words {
+		chunk := map[string]interface{}{
+			"type":       "token",
+			"content":    word + " ",
+			"session_id": session.SessionID,
+			"intent":     "greeting",
+			"confidence": 0.8,
+			"timestamp":  time.Now().Format(time.RFC3339),
 		}
+		stream.publishJSON(chunk)
+		time.Sleep(50 * time.Millisecond)
 	}
 
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("error reading stream: %w", err)
+	// Send done signal
+	doneChunk := map[string]interface{}{
+		"type":       "done",
+		"session_id": session.SessionID,
 	}
-
+	stream.publishJSON(doneChunk)
 	return nil
 }
 
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/joshndala/cantrip/utils"
+)
+
+// ChatSummarizerAgent has the agent write conversation summaries via CHAT_SUMMARIZER; the
+// default is a local heuristic
+const ChatSummarizerAgent = "agent"
+
+// Conversation compaction limits
+const (
+	chatCompactThreshold = 20   // history length that triggers compaction
+	chatRecentMessages   = 10   // messages kept verbatim after compaction
+	maxChatSummaryRunes  = 2000 // summary length sent with every request
+	chatSummaryTimeout   = 10 * time.Second
+)
+
+// Session context keys written by compaction
+const (
+	ContextConversationSummary = "conversation_summary"
+	ContextTripDetails         = "trip_details"
+	ContextSummarizedMessages  = "summarized_messages"
+)
+
+// SummarizeConversationRequest asks the agent to fold older turns into the summary so far
+type SummarizeConversationRequest struct {
+	Summary     string        `json:"summary"`
+	Messages    []ChatMessage `json:"messages"`
+	TripDetails TripDetails   `json:"trip_details"`
+	MaxLength   int           `json:"max_length"`
+}
+
+// SummarizeConversationResponse is the agent's updated summary
+type SummarizeConversationResponse struct {
+	Summary string `json:"summary"`
+}
+
+// TripDetails are the trip facts mentioned in a conversation, kept through compaction
+type TripDetails struct {
+	Destinations []string `json:"destinations,omitempty"`
+	Dates        []string `json:"dates,omitempty"`
+	Budget       string   `json:"budget,omitempty"`
+	Travelers    string   `json:"travelers,omitempty"`
+	Interests    []string `json:"interests,omitempty"`
+}
+
+var (
+	chatDatePattern      = regexp.MustCompile(`(?i)\b(\d{4}-\d{2}-\d{2}|(jan|feb|mar|apr|may|jun|jul|aug|sep|sept|oct|nov|dec)[a-z]*\.? \d{1,2}(st|nd|rd|th)?)\b`)
+	chatBudgetPattern    = regexp.MustCompile(`\$\s?\d[\d,]*(\.\d+)?\s?(k\b)?`)
+	chatTravelersPattern = regexp.MustCompile(`(?i)\b(\d+|two|three|four|five|six)\s+(people|persons|travell?ers|adults|guests|of us)\b`)
+)
+
+// chatInterests are the interests the local summarizer recognizes
+var chatInterests = []string{
+	"hiking", "skiing", "camping", "wildlife", "beaches", "food", "restaurants", "museums",
+	"history", "art", "music", "festivals", "nightlife", "shopping", "sports", "photography",
+}
+
+// compactSession folds all but the most recent messages into the session's summary once
+// the history is long, so each agent request carries a bounded history
+func compactSession(ctx context.Context, session *ConversationSession) {
+	if len(session.History) <= chatCompactThreshold {
+		return
+	}
+
+	cut := len(session.History) - chatRecentMessages
+	older := session.History[:cut]
+
+	details := sessionTripDetails(session)
+	details = extractTripDetails(ctx, details, older)
+
+	previous, _ := session.Context[ContextConversationSummary].(string)
+	summary := ""
+	if os.Getenv("CHAT_SUMMARIZER") == ChatSummarizerAgent {
+		summary = summarizeWithAgent(ctx, previous, older, details)
+	}
+	if summary == "" {
+		summary = localChatSummary(previous, older)
+	}
+
+	session.Context[ContextConversationSummary] = summary
+	session.Context[ContextTripDetails] = details
+	session.Context[ContextSummarizedMessages] = summarizedMessages(session) + len(older)
+	session.History = append([]ChatMessage{}, session.History[cut:]...)
+}
+
+// sessionTripDetails reads the trip details already in the session context
+func sessionTripDetails(session *ConversationSession) TripDetails {
+	var details TripDetails
+	if raw, ok := session.Context[ContextTripDetails]; ok {
+		remarshal(raw, &details)
+	}
+	return details
+}
+
+// summarizeWithAgent asks the agent for an updated summary, returning "" when it fails
+func summarizeWithAgent(ctx context.Context, previous string, messages []ChatMessage, details TripDetails) string {
+	ctx, cancel := context.WithTimeout(ctx, chatSummaryTimeout)
+	defer cancel()
+
+	req := SummarizeConversationRequest{Summary: previous, Messages: messages, TripDetails: details, MaxLength: maxChatSummaryRunes}
+	var result SummarizeConversationResponse
+	if err := GetAIClient().transport.Call(ctx, AgentMethodSummarizeConversation, req, &result); err != nil {
+		utils.LogError("Failed to summarize conversation with the agent", err)
+		return ""
+	}
+	return truncateSummary(strings.TrimSpace(result.Summary))
+}
+
+// localChatSummary appends a line per user question to the previous summary, dropping the
+// oldest lines once it is too long
+func localChatSummary(previous string, messages []ChatMessage) string {
+	var lines []string
+	if previous != "" {
+		lines = strings.Split(previous, "\n")
+	}
+	for _, message := range messages {
+		if message.Role != "user" {
+			continue
+		}
+		text := strings.Join(strings.Fields(message.Message), " ")
+		if i := strings.IndexAny(text, ".?!"); i > 0 {
+			text = text[:i+1]
+		}
+		if text == "" {
+			continue
+		}
+		lines = append(lines, "- Asked: "+utils.TruncateString(text, 120))
+	}
+
+	for len(lines) > 1 && len([]rune(strings.Join(lines, "\n"))) > maxChatSummaryRunes {
+		lines = lines[1:]
+	}
+	return truncateSummary(strings.Join(lines, "\n"))
+}
+
+// truncateSummary caps a summary at maxChatSummaryRunes
+func truncateSummary(summary string) string {
+	if runes := []rune(summary); len(runes) > maxChatSummaryRunes {
+		return string(runes[:maxChatSummaryRunes])
+	}
+	return summary
+}
+
+// extractTripDetails adds the destinations, dates, budget, group size and interests the
+// user mentioned to details; later mentions of a budget or group size replace earlier ones
+func extractTripDetails(ctx context.Context, details TripDetails, messages []ChatMessage) TripDetails {
+	var cities []string
+	if metadata, err := loadCityMetadata(ctx); err == nil {
+		for _, city := range metadata.Cities {
+			cities = append(cities, city.Name)
+		}
+	}
+
+	for _, message := range messages {
+		if message.Role != "user" {
+			continue
+		}
+		text := message.Message
+		words := activityWords(text)
+
+		for _, city := range cities {
+			if strings.Contains(words, activityWords(city)) && !utils.Contains(details.Destinations, city) {
+				details.Destinations = append(details.Destinations, city)
+			}
+		}
+		for _, date := range chatDatePattern.FindAllString(text, -1) {
+			if !utils.Contains(details.Dates, date) {
+				details.Dates = append(details.Dates, date)
+			}
+		}
+		if budgets := chatBudgetPattern.FindAllString(text, -1); len(budgets) > 0 {
+			details.Budget = strings.TrimSpace(budgets[len(budgets)-1])
+		}
+		if travelers := chatTravelersPattern.FindAllString(text, -1); len(travelers) > 0 {
+			details.Travelers = travelers[len(travelers)-1]
+		}
+		for _, interest := range chatInterests {
+			if strings.Contains(words, " "+interest+" ") && !utils.Contains(details.Interests, interest) {
+				details.Interests = append(details.Interests, interest)
+			}
+		}
+	}
+	return details
+}
+
+// ConversationMemory is what a session has compacted out of its history
+type ConversationMemory struct {
+	Summary            string      `json:"summary"`
+	SummarizedMessages int         `json:"summarized_messages"`
+	TripDetails        TripDetails `json:"trip_details"`
+}
+
+// GetConversationMemory returns the summary and trip details of a session's compacted messages
+func GetConversationMemory(ctx context.Context, sessionID string) (*ConversationMemory, error) {
+	session, exists := sessions[tenantKey(ctx, sessionID)]
+	if !exists {
+		return nil, fmt.Errorf("session not found: %s", sessionID)
+	}
+	summary, _ := session.Context[ContextConversationSummary].(string)
+	return &ConversationMemory{
+		Summary:            summary,
+		SummarizedMessages: summarizedMessages(session),
+		TripDetails:        sessionTripDetails(session),
+	}, nil
+}
@@ -0,0 +1,275 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/joshndala/cantrip/utils"
+)
+
+// AccommodationTiers are the accommodation levels priced in the cost-of-living dataset
+var AccommodationTiers = []string{"budget", "mid-range", "luxury"}
+
+// Defaults for itineraries generated before currency and tier were stored
+const (
+	DefaultCurrency      = "CAD"
+	DefaultAccommodation = "mid-range"
+	MaxGroupSize         = 50
+	guestsPerRoom        = 2
+)
+
+// ErrInvalidCostInput is returned for unsupported currencies, tiers or group sizes
+var ErrInvalidCostInput = errors.New("invalid cost recalculation input")
+
+// CostOfLiving represents the structure of cost_of_living.json
+type CostOfLiving struct {
+	BaseCurrency  string               `json:"base_currency"`
+	ExchangeRates map[string]float64   `json:"exchange_rates"` // units per base currency
+	Default       CityCosts            `json:"default"`
+	Cities        map[string]CityCosts `json:"cities"`
+}
+
+// CityCosts are typical prices in a city, in the base currency
+type CityCosts struct {
+	Accommodation  map[string]float64 `json:"accommodation"` // nightly room rate by tier
+	Meal           map[string]float64 `json:"meal"`          // per person by tier
+	TransitDayPass float64            `json:"transit_day_pass"`
+}
+
+// CostRecalculation lists the trip parameters to change; empty fields keep the current value
+type CostRecalculation struct {
+	GroupSize     int    `json:"group_size,omitempty"`
+	Currency      string `json:"currency,omitempty"`
+	Accommodation string `json:"accommodation,omitempty"`
+}
+
+// CostBreakdown totals an itinerary's costs by kind
+type CostBreakdown struct {
+	Currency      string  `json:"currency"`
+	GroupSize     int     `json:"group_size"`
+	Accommodation string  `json:"accommodation"`
+	Activities    float64 `json:"activities"`
+	Meals         float64 `json:"meals"`
+	Transit       float64 `json:"transit"`
+	Rental        float64 `json:"rental,omitempty"` // rental car and fuel, replacing transit passes
+	Lodging       float64 `json:"lodging"`
+	LodgingNights int     `json:"lodging_nights"` // nights not covered by an imported hotel booking
+	Total         float64 `json:"total"`
+	PerPerson     float64 `json:"per_person"`
+}
+
+// loadCostOfLiving loads the cost-of-living dataset
+func loadCostOfLiving(ctx context.Context) (*CostOfLiving, error) {
+	data, err := ReadDataset(ctx, CostOfLivingDataset)
+	if err != nil {
+		return nil, err
+	}
+
+	var costs CostOfLiving
+	if err := json.Unmarshal(data, &costs); err != nil {
+		return nil, err
+	}
+	return &costs, nil
+}
+
+// cityCosts returns the prices for a city, falling back to the national default
+func (c *CostOfLiving) cityCosts(city string) CityCosts {
+	for name, costs := range c.Cities {
+		if strings.EqualFold(name, city) {
+			return costs
+		}
+	}
+	return c.Default
+}
+
+// convert changes an amount between two supported currencies
+func (c *CostOfLiving) convert(amount float64, from, to string) float64 {
+	if from == to {
+		return amount
+	}
+	return amount / c.ExchangeRates[from] * c.ExchangeRates[to]
+}
+
+// RecalculateItineraryCosts returns a copy of an itinerary repriced for a new group size,
+// currency or accommodation tier, without calling the agent. Stored activity and meal
+// costs are per person; lodging and transit come from the cost-of-living dataset, and a
+// selected rental car replaces the transit passes.
+func RecalculateItineraryCosts(ctx context.Context, original *ItineraryResponse, change CostRecalculation) (*ItineraryResponse, *CostBreakdown, error) {
+	costs, err := loadCostOfLiving(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load cost-of-living data: %w", err)
+	}
+
+	var itinerary *ItineraryResponse
+	if err := remarshal(original, &itinerary); err != nil {
+		return nil, nil, fmt.Errorf("failed to copy itinerary: %w", err)
+	}
+
+	currentCurrency := strings.ToUpper(stringField(itinerary.Itinerary, "currency", DefaultCurrency))
+	if _, ok := costs.ExchangeRates[currentCurrency]; !ok {
+		currentCurrency = DefaultCurrency
+	}
+
+	breakdown := &CostBreakdown{
+		Currency:      currentCurrency,
+		GroupSize:     intField(itinerary.Itinerary, "group_size", 1),
+		Accommodation: strings.ToLower(stringField(itinerary.Itinerary, "accommodation", DefaultAccommodation)),
+	}
+	if !utils.Contains(AccommodationTiers, breakdown.Accommodation) {
+		breakdown.Accommodation = DefaultAccommodation
+	}
+
+	if change.Currency != "" {
+		breakdown.Currency = strings.ToUpper(change.Currency)
+		if _, ok := costs.ExchangeRates[breakdown.Currency]; !ok {
+			return nil, nil, fmt.Errorf("%w: unsupported currency %q", ErrInvalidCostInput, change.Currency)
+		}
+	}
+	if change.Accommodation != "" {
+		breakdown.Accommodation = strings.ToLower(change.Accommodation)
+		if !utils.Contains(AccommodationTiers, breakdown.Accommodation) {
+			return nil, nil, fmt.Errorf("%w: accommodation must be one of %s", ErrInvalidCostInput, strings.Join(AccommodationTiers, ", "))
+		}
+	}
+	if change.GroupSize != 0 {
+		if change.GroupSize < 1 || change.GroupSize > MaxGroupSize {
+			return nil, nil, fmt.Errorf("%w: group_size must be between 1 and %d", ErrInvalidCostInput, MaxGroupSize)
+		}
+		breakdown.GroupSize = change.GroupSize
+	}
+
+	city := costs.cityCosts(itinerary.Metadata.City)
+	fromBase := func(amount float64) float64 {
+		return costs.convert(amount, costs.BaseCurrency, breakdown.Currency)
+	}
+	nightlyRate := fromBase(city.Accommodation[breakdown.Accommodation])
+	rooms := (breakdown.GroupSize + guestsPerRoom - 1) / guestsPerRoom
+	booked := hotelNights(itinerary.Anchors)
+
+	days, _ := itinerary.Itinerary["days"].([]interface{})
+	for d, rawDay := range days {
+		day, ok := rawDay.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		activities := repriceLineItems(day["activities"], costs, currentCurrency, breakdown.Currency, 0)
+		meals := repriceLineItems(day["meals"], costs, currentCurrency, breakdown.Currency, fromBase(city.Meal[breakdown.Accommodation]))
+		transit := fromBase(city.TransitDayPass)
+		if itinerary.Rental != nil {
+			transit = 0
+		}
+		group := float64(breakdown.GroupSize)
+		breakdown.Activities += activities * group
+		breakdown.Meals += meals * group
+		breakdown.Transit += transit * group
+		dayTotal := (activities + meals + transit) * group
+
+		// The last day has no night; nights with an imported hotel booking are already paid for
+		lodging := 0.0
+		date, _ := day["date"].(string)
+		if d < len(days)-1 && !booked[date] {
+			lodging = nightlyRate * float64(rooms)
+			breakdown.LodgingNights++
+		}
+		breakdown.Lodging += lodging
+		dayTotal += lodging
+
+		day["transport_cost"] = roundCost(transit)
+		day["accommodation_cost"] = roundCost(lodging)
+		day["total_cost"] = roundCost(dayTotal)
+		breakdown.Total += dayTotal
+	}
+
+	// The rental car is priced for the whole group rather than per person
+	if itinerary.Rental != nil {
+		breakdown.Rental = costs.convert(itinerary.Rental.Total, itinerary.Rental.Currency, breakdown.Currency)
+		breakdown.Total += breakdown.Rental
+	}
+
+	breakdown.Activities = roundCost(breakdown.Activities)
+	breakdown.Rental = roundCost(breakdown.Rental)
+	breakdown.Meals = roundCost(breakdown.Meals)
+	breakdown.Transit = roundCost(breakdown.Transit)
+	breakdown.Lodging = roundCost(breakdown.Lodging)
+	breakdown.Total = roundCost(breakdown.Total)
+	breakdown.PerPerson = roundCost(breakdown.Total / float64(breakdown.GroupSize))
+
+	itinerary.Itinerary["currency"] = breakdown.Currency
+	itinerary.Itinerary["group_size"] = breakdown.GroupSize
+	itinerary.Itinerary["accommodation"] = breakdown.Accommodation
+	itinerary.Itinerary["total_cost"] = breakdown.Total
+	itinerary.Itinerary["cost_breakdown"] = breakdown
+	itinerary.Metadata.TotalCost = breakdown.Total
+
+	return itinerary, breakdown, nil
+}
+
+// repriceLineItems converts each item's per-person cost to the target currency in place,
+// filling missing costs with fallback (already in the target currency), and returns the sum
+func repriceLineItems(raw interface{}, costs *CostOfLiving, from, to string, fallback float64) float64 {
+	items, _ := raw.([]interface{})
+	total := 0.0
+	for _, rawItem := range items {
+		item, ok := rawItem.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		cost, ok := item["cost"].(float64)
+		if ok {
+			cost = costs.convert(cost, from, to)
+		} else {
+			cost = fallback
+		}
+		item["cost"] = roundCost(cost)
+		total += cost
+	}
+	return total
+}
+
+// hotelNights returns the dates covered by imported hotel bookings
+func hotelNights(anchors []Booking) map[string]bool {
+	nights := map[string]bool{}
+	for _, anchor := range anchors {
+		if anchor.Type != BookingHotel || anchor.End.IsZero() {
+			continue
+		}
+		for night := anchor.Start; night.Format("2006-01-02") < anchor.End.Format("2006-01-02"); night = night.AddDate(0, 0, 1) {
+			nights[night.Format("2006-01-02")] = true
+		}
+	}
+	return nights
+}
+
+// stringField reads a string from a generic itinerary body
+func stringField(body map[string]interface{}, key, fallback string) string {
+	if value, ok := body[key].(string); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+// intField reads a positive integer from a generic itinerary body, which may
+// hold an int before it is stored and a float64 after
+func intField(body map[string]interface{}, key string, fallback int) int {
+	switch value := body[key].(type) {
+	case int:
+		if value > 0 {
+			return value
+		}
+	case float64:
+		if value > 0 {
+			return int(value)
+		}
+	}
+	return fallback
+}
+
+// roundCost rounds to cents
+func roundCost(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
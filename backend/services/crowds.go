@@ -0,0 +1,427 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joshndala/cantrip/utils"
+)
+
+// Crowd levels
+const (
+	CrowdLow      = "low"
+	CrowdModerate = "moderate"
+	CrowdHigh     = "high"
+	CrowdVeryHigh = "very_high"
+)
+
+// Attraction categories used by the crowd heuristics
+const (
+	attractionMuseum   = "museum"
+	attractionMarket   = "market"
+	attractionOutdoor  = "outdoor"
+	attractionLandmark = "landmark"
+	attractionDistrict = "district"
+)
+
+// attractionKeywords classifies attractions by name; checked in order
+var attractionKeywords = []struct {
+	category string
+	keywords []string
+}{
+	{attractionMuseum, []string{"museum", "gallery", "musée", "musee", "science", "aquarium", "library"}},
+	{attractionMarket, []string{"market", "marché", "marche"}},
+	{attractionDistrict, []string{"district", "street", "village", "square", "quarter", "old ", "chinatown", "avenue", "strip"}},
+	{attractionOutdoor, []string{"park", "garden", "trail", "lake", "beach", "mountain", "island", "canyon", "glacier", "provincial", "national", "falls", "harbour", "waterfront", "zoo"}},
+}
+
+// crowdPeaks is the busiest window (start and end hour) for each category
+var crowdPeaks = map[string][2]int{
+	attractionMuseum:   {11, 15},
+	attractionMarket:   {10, 13},
+	attractionOutdoor:  {12, 16},
+	attractionLandmark: {10, 16},
+	attractionDistrict: {13, 20},
+}
+
+// CrowdEstimate is the expected crowd level at an attraction for a given time
+type CrowdEstimate struct {
+	Level   string   `json:"level"`
+	Score   int      `json:"score"` // 0-100
+	Reasons []string `json:"reasons"`
+}
+
+// BestTimeToVisit summarizes when an attraction is quietest on a given day
+type BestTimeToVisit struct {
+	Date      string `json:"date"`
+	BestTime  string `json:"best_time"`
+	BestLevel string `json:"best_level"`
+	PeakHours string `json:"peak_hours"`
+	PeakLevel string `json:"peak_level"`
+	Note      string `json:"note,omitempty"`
+}
+
+// AttractionDetail describes an attraction with crowd estimates
+type AttractionDetail struct {
+	ID          string          `json:"id"` // for posting and listing reviews
+	Name        string          `json:"name"`
+	City        string          `json:"city"`
+	Category    string          `json:"category"`
+	Popularity  string          `json:"popularity"`
+	BestTime    BestTimeToVisit `json:"best_time"`
+	QuietestDay string          `json:"quietest_day"`
+	Rating      float64         `json:"rating"`
+	ReviewCount int             `json:"review_count"`
+}
+
+// GetAttractionDetails returns the attractions of a city with crowd estimates for a date (YYYY-MM-DD, default today)
+func GetAttractionDetails(ctx context.Context, city, date string) ([]AttractionDetail, error) {
+	day := time.Now()
+	if date != "" {
+		parsed, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date format, use YYYY-MM-DD: %w", err)
+		}
+		day = parsed
+	}
+
+	metadata, err := loadCityMetadata(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load city metadata: %w", err)
+	}
+	cityData, err := findCity(metadata, city)
+	if err != nil {
+		return nil, err
+	}
+
+	details := make([]AttractionDetail, 0, len(cityData.Attractions))
+	for rank, attraction := range cityData.Attractions {
+		popularity := attractionPopularity(rank)
+		rating := GetAttractionRating(ctx, cityData.Name, attraction)
+		details = append(details, AttractionDetail{
+			ID:          AttractionID(cityData.Name, attraction),
+			Name:        attraction,
+			City:        cityData.Name,
+			Category:    classifyAttraction(attraction),
+			Popularity:  popularityLabel(popularity),
+			BestTime:    bestTimeToVisit(attraction, popularity, day),
+			QuietestDay: quietestDay(attraction, popularity, day),
+			Rating:      rating.Rating,
+			ReviewCount: rating.ReviewCount,
+		})
+	}
+
+	return details, nil
+}
+
+// EstimateCrowd estimates how busy an attraction is at a given time.
+// Popularity is 0-1, with 1 being the city's headline attraction.
+func EstimateCrowd(attraction string, popularity float64, at time.Time) CrowdEstimate {
+	category := classifyAttraction(attraction)
+	var reasons []string
+
+	// Seasonality
+	season := utils.GetSeason(int(at.Month()))
+	seasonFactor := map[string]float64{"summer": 1.0, "spring": 0.7, "fall": 0.75, "winter": 0.5}[season]
+	if category == attractionOutdoor && season == "winter" {
+		seasonFactor = 0.3
+	}
+	if isWinterAttraction(attraction) && season == "winter" {
+		seasonFactor = 1.0
+	}
+	if season == "summer" {
+		reasons = append(reasons, "peak summer travel season")
+	}
+
+	// Weekday vs weekend
+	dayFactor := 0.7
+	switch at.Weekday() {
+	case time.Saturday, time.Sunday:
+		dayFactor = 1.0
+		reasons = append(reasons, "weekend")
+	case time.Friday:
+		dayFactor = 0.85
+	}
+	if category == attractionMarket && dayFactor < 1.0 {
+		dayFactor -= 0.1
+	}
+
+	// Time of day relative to the category's peak
+	peak := crowdPeaks[category]
+	hour := at.Hour()
+	hourFactor := 0.45
+	switch {
+	case hour >= peak[0] && hour < peak[1]:
+		hourFactor = 1.0
+		reasons = append(reasons, fmt.Sprintf("peak hours %02d:00-%02d:00", peak[0], peak[1]))
+	case hour < 10:
+		hourFactor = 0.3
+		reasons = append(reasons, "early in the day")
+	case hour == peak[0]-1 || hour == peak[1]:
+		hourFactor = 0.75
+	}
+
+	score := int(100 * popularity * seasonFactor * dayFactor * hourFactor)
+	if score > 100 {
+		score = 100
+	}
+
+	return CrowdEstimate{Level: crowdLevel(score), Score: score, Reasons: reasons}
+}
+
+// bestTimeToVisit finds the quietest opening hour and the peak window for a day
+func bestTimeToVisit(attraction string, popularity float64, day time.Time) BestTimeToVisit {
+	category := classifyAttraction(attraction)
+	peak := crowdPeaks[category]
+	base := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+
+	// Outdoor spots and districts are worth visiting at the edges of the day; venues open around 9
+	firstHour, lastHour := 9, 17
+	if category == attractionOutdoor || category == attractionDistrict {
+		firstHour, lastHour = 8, 20
+	}
+
+	best := firstHour
+	bestScore := 101
+	for hour := firstHour; hour <= lastHour; hour++ {
+		if estimate := EstimateCrowd(attraction, popularity, base.Add(time.Duration(hour)*time.Hour)); estimate.Score < bestScore {
+			best, bestScore = hour, estimate.Score
+		}
+	}
+	peakEstimate := EstimateCrowd(attraction, popularity, base.Add(time.Duration(peak[0])*time.Hour))
+
+	result := BestTimeToVisit{
+		Date:      base.Format("2006-01-02"),
+		BestTime:  fmt.Sprintf("%02d:00", best),
+		BestLevel: crowdLevel(bestScore),
+		PeakHours: fmt.Sprintf("%02d:00-%02d:00", peak[0], peak[1]),
+		PeakLevel: peakEstimate.Level,
+	}
+	if isCrowded(peakEstimate.Level) {
+		if best < peak[0] {
+			result.Note = goEarlyNote(attraction, result.BestTime, result.PeakHours)
+		} else {
+			result.Note = fmt.Sprintf("Go late: crowds at %s thin out after %02d:00", attraction, peak[1])
+		}
+	}
+	return result
+}
+
+// quietestDay returns the weekday with the lowest peak crowd during the week of the given day
+func quietestDay(attraction string, popularity float64, day time.Time) string {
+	peak := crowdPeaks[classifyAttraction(attraction)]
+	quietest, lowest := day.Weekday(), 101
+	for i := 0; i < 7; i++ {
+		d := day.AddDate(0, 0, i)
+		at := time.Date(d.Year(), d.Month(), d.Day(), peak[0], 0, 0, 0, d.Location())
+		if score := EstimateCrowd(attraction, popularity, at).Score; score < lowest {
+			quietest, lowest = d.Weekday(), score
+		}
+	}
+	return quietest.String()
+}
+
+// ScheduleItineraryForCrowds moves crowded attractions to the earliest slots of each day
+// and annotates activities with their expected crowd level
+func ScheduleItineraryForCrowds(ctx context.Context, resp *ItineraryResponse) {
+	if resp == nil || resp.Itinerary == nil {
+		return
+	}
+
+	var cityData *City
+	if metadata, err := loadCityMetadata(ctx); err == nil {
+		city, _ := resp.Itinerary["city"].(string)
+		if city == "" {
+			city = resp.Metadata.City
+		}
+		cityData, _ = findCity(metadata, city)
+	}
+
+	days, _ := resp.Itinerary["days"].([]interface{})
+	for _, rawDay := range days {
+		day, ok := rawDay.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		date, err := time.Parse("2006-01-02", fmt.Sprint(day["date"]))
+		if err != nil {
+			continue
+		}
+		activities, _ := day["activities"].([]interface{})
+		scheduleDayForCrowds(activities, cityData, date)
+	}
+}
+
+// scheduleDayForCrowds reorders one day's activities in place, keeping the day's time slots
+func scheduleDayForCrowds(activities []interface{}, cityData *City, date time.Time) {
+	type slot struct{ start, end string }
+	type candidate struct {
+		activity map[string]interface{}
+		name     string
+		pressure int // peak crowd score, 0 when not crowd sensitive
+		index    int
+	}
+
+	var slots []slot
+	var candidates []candidate
+	for i, raw := range activities {
+		activity, ok := raw.(map[string]interface{})
+		if !ok {
+			return
+		}
+		start, _ := activity["start_time"].(string)
+		end, _ := activity["end_time"].(string)
+		if _, err := time.Parse("15:04", start); err != nil {
+			// Leave free-form schedules untouched
+			return
+		}
+		slots = append(slots, slot{start, end})
+
+		name, _ := activity["name"].(string)
+		popularity := activityPopularity(name, cityData)
+		peak := crowdPeaks[classifyAttraction(name)]
+		at := time.Date(date.Year(), date.Month(), date.Day(), peak[0], 0, 0, 0, time.UTC)
+		pressure := 0
+		if estimate := EstimateCrowd(name, popularity, at); isCrowded(estimate.Level) && classifyAttraction(name) != attractionDistrict {
+			pressure = estimate.Score
+		}
+		candidates = append(candidates, candidate{activity, name, pressure, i})
+	}
+	if len(candidates) < 2 {
+		return
+	}
+
+	sort.Slice(slots, func(i, j int) bool { return slots[i].start < slots[j].start })
+	sort.SliceStable(candidates, func(i, j int) bool {
+		// Crowded attractions first, busiest first; everything else keeps its order
+		if candidates[i].pressure != candidates[j].pressure {
+			return candidates[i].pressure > candidates[j].pressure
+		}
+		return candidates[i].index < candidates[j].index
+	})
+
+	for i, c := range candidates {
+		duration := slotDuration(c.activity)
+		c.activity["start_time"] = slots[i].start
+		if duration > 0 {
+			start, _ := time.Parse("15:04", slots[i].start)
+			c.activity["end_time"] = start.Add(duration).Format("15:04")
+		} else {
+			c.activity["end_time"] = slots[i].end
+		}
+
+		start, _ := time.Parse("15:04", slots[i].start)
+		at := time.Date(date.Year(), date.Month(), date.Day(), start.Hour(), start.Minute(), 0, 0, time.UTC)
+		estimate := EstimateCrowd(c.name, activityPopularity(c.name, cityData), at)
+		c.activity["crowd_level"] = estimate.Level
+		if c.pressure > 0 {
+			peak := crowdPeaks[classifyAttraction(c.name)]
+			peakHours := fmt.Sprintf("%02d:00-%02d:00", peak[0], peak[1])
+			switch {
+			case start.Hour() < peak[0]:
+				c.activity["crowd_note"] = goEarlyNote(c.name, slots[i].start, peakHours)
+			case start.Hour() >= peak[1]:
+				c.activity["crowd_note"] = fmt.Sprintf("Crowds at %s thin out after %02d:00", c.name, peak[1])
+			default:
+				c.activity["crowd_note"] = fmt.Sprintf("Expect crowds at %s during %s; book timed entry if available", c.name, peakHours)
+			}
+		}
+		activities[i] = c.activity
+	}
+}
+
+// slotDuration returns the planned length of an activity, or 0 if unknown
+func slotDuration(activity map[string]interface{}) time.Duration {
+	start, err1 := time.Parse("15:04", fmt.Sprint(activity["start_time"]))
+	end, err2 := time.Parse("15:04", fmt.Sprint(activity["end_time"]))
+	if err1 != nil || err2 != nil || !end.After(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+// activityPopularity matches an itinerary activity against the city's ranked attractions
+func activityPopularity(name string, cityData *City) float64 {
+	if cityData == nil {
+		return 0.5
+	}
+	lower := strings.ToLower(name)
+	for rank, attraction := range cityData.Attractions {
+		if strings.Contains(lower, strings.ToLower(attraction)) {
+			return attractionPopularity(rank)
+		}
+	}
+	return 0.4
+}
+
+// attractionPopularity derives popularity from an attraction's position in the curated list
+func attractionPopularity(rank int) float64 {
+	switch {
+	case rank < 2:
+		return 1.0
+	case rank < 4:
+		return 0.85
+	case rank < 6:
+		return 0.7
+	default:
+		return 0.55
+	}
+}
+
+func popularityLabel(popularity float64) string {
+	switch {
+	case popularity >= 1.0:
+		return "must-see"
+	case popularity >= 0.7:
+		return "popular"
+	default:
+		return "local favourite"
+	}
+}
+
+// classifyAttraction assigns a crowd category from the attraction name
+func classifyAttraction(name string) string {
+	lower := strings.ToLower(name) + " "
+	for _, group := range attractionKeywords {
+		for _, keyword := range group.keywords {
+			if strings.Contains(lower, keyword) {
+				return group.category
+			}
+		}
+	}
+	return attractionLandmark
+}
+
+func isWinterAttraction(name string) bool {
+	lower := strings.ToLower(name)
+	for _, keyword := range []string{"ski", "rink", "skate", "ice", "snow", "winter"} {
+		if strings.Contains(lower, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+func crowdLevel(score int) string {
+	switch {
+	case score < 30:
+		return CrowdLow
+	case score < 55:
+		return CrowdModerate
+	case score < 75:
+		return CrowdHigh
+	default:
+		return CrowdVeryHigh
+	}
+}
+
+func isCrowded(level string) bool {
+	return level == CrowdHigh || level == CrowdVeryHigh
+}
+
+func goEarlyNote(attraction, bestTime, peakHours string) string {
+	return fmt.Sprintf("Go early: arrive at %s around %s to beat the %s crowds", attraction, bestTime, peakHours)
+}
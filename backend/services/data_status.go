@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DataState says how much of a response is backed by data about the city asked for
+type DataState string
+
+const (
+	DataFound           DataState = "found"            // the city is supported and every source answered
+	DataApproximate     DataState = "approximate"      // supported, but part of the response is estimated
+	DataUnsupportedCity DataState = "unsupported_city" // no data for the city; anything returned is generic
+)
+
+// SuggestedCityLimit caps the supported cities offered in place of an unsupported one
+const SuggestedCityLimit = 3
+
+// DataStatus tells clients what a response is based on, so they can say when results are
+// estimated or generic rather than presenting them as the real thing
+type DataStatus struct {
+	State           DataState       `json:"state"`
+	City            string          `json:"city"`                       // as the dataset spells it when supported
+	Notes           []string        `json:"notes,omitempty"`            // what was estimated or left out
+	SuggestedCities []SuggestedCity `json:"suggested_cities,omitempty"` // supported cities to try instead
+}
+
+// SuggestedCity is a supported city offered in place of one we have no data for
+type SuggestedCity struct {
+	Name     string `json:"name"`
+	Province string `json:"province"`
+}
+
+// provinceCodes maps Canadian postal abbreviations to province names, so "Sudbury, ON"
+// suggests Ontario's cities
+var provinceCodes = map[string]string{
+	"ab": "Alberta", "bc": "British Columbia", "mb": "Manitoba", "nb": "New Brunswick",
+	"nl": "Newfoundland & Labrador", "ns": "Nova Scotia", "nt": "Northwest Territories",
+	"nu": "Nunavut", "on": "Ontario", "pe": "Prince Edward Island", "qc": "Quebec",
+	"sk": "Saskatchewan", "yt": "Yukon",
+}
+
+// CityDataStatus reports whether city is supported, suggesting supported cities when it isn't
+func CityDataStatus(ctx context.Context, city string) DataStatus {
+	index, err := GetCityIndex(ctx)
+	if err != nil {
+		return DataStatus{State: DataApproximate, City: city, Notes: []string{"City data is unavailable right now"}}
+	}
+	if cityData, ok := index.Find(city); ok {
+		return DataStatus{State: DataFound, City: cityData.Name}
+	}
+	return DataStatus{
+		State:           DataUnsupportedCity,
+		City:            city,
+		Notes:           []string{fmt.Sprintf("We don't have travel data for %s yet", city)},
+		SuggestedCities: SuggestSupportedCities(index, city, SuggestedCityLimit),
+	}
+}
+
+// Degrade marks the response as partly estimated, with a note saying what. A response for
+// an unsupported city stays unsupported.
+func (s *DataStatus) Degrade(note string) {
+	if s.State == DataFound {
+		s.State = DataApproximate
+	}
+	s.Notes = append(s.Notes, note)
+}
+
+// SuggestSupportedCities picks up to limit supported cities for a name we have no data
+// for: the cities of the province in "Name, Province" first, then names within a couple
+// of typos, and the largest cities when nothing is close
+func SuggestSupportedCities(index *CityIndex, name string, limit int) []SuggestedCity {
+	place, region := name, ""
+	if comma := strings.LastIndex(name, ","); comma >= 0 {
+		place, region = name[:comma], strings.TrimSpace(name[comma+1:])
+	}
+	if province, ok := provinceCodes[strings.ToLower(region)]; ok {
+		region = province
+	}
+
+	var candidates []*City
+	seen := map[*City]bool{}
+	add := func(cities []*City) {
+		for _, city := range cities {
+			if !seen[city] {
+				seen[city] = true
+				candidates = append(candidates, city)
+			}
+		}
+	}
+
+	if region != "" {
+		add(byPopulation(index.InProvince(region)))
+	}
+	add(similarlyNamed(index, place))
+	if len(candidates) == 0 {
+		add(byPopulation(citiesOf(index)))
+	}
+
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	suggested := make([]SuggestedCity, 0, len(candidates))
+	for _, city := range candidates {
+		suggested = append(suggested, SuggestedCity{Name: city.Name, Province: city.Province})
+	}
+	return suggested
+}
+
+// maxNameTypos is how many edits apart a name can be from a city's and still suggest it
+const maxNameTypos = 2
+
+// similarlyNamed returns the cities whose names start with name or are within maxNameTypos
+// edits of it, closest first
+func similarlyNamed(index *CityIndex, name string) []*City {
+	key := NormalizeCityName(name)
+	if len(key) < 3 {
+		return nil
+	}
+	distances := map[*City]int{}
+	var matches []*City
+	for _, city := range citiesOf(index) {
+		cityKey := NormalizeCityName(city.Name)
+		distance := editDistance(key, cityKey)
+		if strings.HasPrefix(cityKey, key) {
+			distance = 0
+		}
+		if distance <= maxNameTypos {
+			distances[city] = distance
+			matches = append(matches, city)
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return distances[matches[i]] < distances[matches[j]] })
+	return matches
+}
+
+// citiesOf lists the indexed cities in dataset order
+func citiesOf(index *CityIndex) []*City {
+	cities := make([]*City, 0, len(index.metadata.Cities))
+	for i := range index.metadata.Cities {
+		cities = append(cities, &index.metadata.Cities[i])
+	}
+	return cities
+}
+
+// byPopulation orders a copy of cities from largest to smallest
+func byPopulation(cities []*City) []*City {
+	sorted := append([]*City(nil), cities...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Population > sorted[j].Population })
+	return sorted
+}
+
+// editDistance is the optimal string alignment distance between a and b: the edits,
+// counting swapped neighbouring letters as one, that turn a into b
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	rows := make([][]int, len(ra)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(rb)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			rows[i][j] = min(min(rows[i-1][j]+1, rows[i][j-1]+1), rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+	return rows[len(ra)][len(rb)]
+}
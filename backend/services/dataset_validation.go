@@ -0,0 +1,674 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/joshndala/cantrip/utils"
+)
+
+// DatasetSchemaVersion is the dataset schema version this build understands
+const DatasetSchemaVersion = 1
+
+// Dataset validation modes for DATA_VALIDATION
+const (
+	DataValidationStrict = "strict" // refuse to start on any problem
+	DataValidationWarn   = "warn"   // start degraded and log the problems
+)
+
+// DatasetValidationError lists every problem found across the datasets
+type DatasetValidationError struct {
+	Problems []string
+}
+
+func (e *DatasetValidationError) Error() string {
+	return fmt.Sprintf("%d dataset problem(s):\n  - %s", len(e.Problems), strings.Join(e.Problems, "\n  - "))
+}
+
+// datasetValidator collects problems for a single dataset
+type datasetValidator struct {
+	dataset  string
+	problems []string
+}
+
+func (v *datasetValidator) addf(format string, args ...interface{}) {
+	v.problems = append(v.problems, v.dataset+": "+fmt.Sprintf(format, args...))
+}
+
+// ValidateDatasets checks the schema version and structure of every required dataset.
+// Problems that make a dataset unusable are returned as a DatasetValidationError;
+// warnings describe issues the services can tolerate.
+func ValidateDatasets(ctx context.Context) (warnings []string, err error) {
+	validators := map[string]func(*datasetValidator, map[string]json.RawMessage){
+		CityMetadataDataset:     validateCityMetadata,
+		TipsDataset:             validateTips,
+		PackingRulesDataset:     validatePackingRules,
+		CostOfLivingDataset:     validateCostOfLiving,
+		AirlineBaggageDataset:   validateAirlineBaggage,
+		PackingTemplatesDataset: validatePackingTemplates,
+		TravelInsuranceDataset:  validateTravelInsurance,
+		ConnectivityDataset:     validateConnectivity,
+		DrivingRulesDataset:     validateDrivingRules,
+		CarRentalsDataset:       validateCarRentals,
+		EmissionFactorsDataset:  validateEmissionFactors,
+		FestivalsDataset:        validateFestivals,
+		NightlifeDataset:        validateNightlife,
+		ModerationRulesDataset:  validateModerationRules,
+		RulesDataset:            validateRules,
+		ExperimentsDataset:      validateExperiments,
+	}
+
+	var problems []string
+	for _, name := range RequiredDatasets {
+		v := &datasetValidator{dataset: name}
+
+		content, err := ReadDataset(ctx, name)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+
+		var root map[string]json.RawMessage
+		if err := json.Unmarshal(content, &root); err != nil {
+			v.addf("invalid JSON: %v", err)
+			problems = append(problems, v.problems...)
+			continue
+		}
+
+		// Check the schema version before the structure
+		var version int
+		if raw, ok := root["schema_version"]; !ok {
+			warnings = append(warnings, fmt.Sprintf("%s: no schema_version, assuming %d", name, DatasetSchemaVersion))
+		} else if err := json.Unmarshal(raw, &version); err != nil {
+			v.addf("schema_version must be an integer")
+		} else if version != DatasetSchemaVersion {
+			v.addf("incompatible schema_version %d (this build supports %d)", version, DatasetSchemaVersion)
+		}
+
+		if validate, ok := validators[name]; ok {
+			validate(v, root)
+		}
+		problems = append(problems, v.problems...)
+	}
+
+	if len(problems) > 0 {
+		return warnings, &DatasetValidationError{Problems: problems}
+	}
+	return warnings, nil
+}
+
+// validateCityMetadata checks city_metadata.json
+func validateCityMetadata(v *datasetValidator, root map[string]json.RawMessage) {
+	var metadata CityMetadata
+	raw, ok := root["cities"]
+	if !ok {
+		v.addf("missing cities")
+		return
+	}
+	if err := json.Unmarshal(raw, &metadata.Cities); err != nil {
+		v.addf("cities must be a list of city objects: %v", err)
+		return
+	}
+	if len(metadata.Cities) == 0 {
+		v.addf("cities is empty")
+	}
+
+	seen := make(map[string]bool)
+	for i, city := range metadata.Cities {
+		label := fmt.Sprintf("cities[%d]", i)
+		if city.Name == "" {
+			v.addf("%s: missing name", label)
+		} else {
+			label = fmt.Sprintf("cities[%d] (%s)", i, city.Name)
+			key := strings.ToLower(city.Name)
+			if seen[key] {
+				v.addf("%s: duplicate city name", label)
+			}
+			seen[key] = true
+		}
+		if city.Province == "" {
+			v.addf("%s: missing province", label)
+		}
+		if city.Timezone == "" {
+			v.addf("%s: missing timezone", label)
+		}
+		if city.Coordinates.Lat < -90 || city.Coordinates.Lat > 90 || city.Coordinates.Lng < -180 || city.Coordinates.Lng > 180 ||
+			(city.Coordinates.Lat == 0 && city.Coordinates.Lng == 0) {
+			v.addf("%s: invalid coordinates", label)
+		}
+		if len(city.Seasons) == 0 {
+			v.addf("%s: missing seasons", label)
+		}
+		for name, season := range city.Seasons {
+			if len(season.Months) == 0 {
+				v.addf("%s: season %s has no months", label, name)
+			}
+		}
+		for j, neighborhood := range city.Neighborhoods {
+			if neighborhood.Name == "" {
+				v.addf("%s: neighborhoods[%d] missing name", label, j)
+			}
+			if access := neighborhood.Transit.Access; access != "" && !utils.Contains(TransitAccessLevels, access) {
+				v.addf("%s: neighborhood %s has unknown transit access %q", label, neighborhood.Name, access)
+			}
+		}
+	}
+}
+
+// validateTips checks tips.json
+func validateTips(v *datasetValidator, root map[string]json.RawMessage) {
+	for key, raw := range root {
+		if key == "schema_version" {
+			continue
+		}
+
+		var section map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &section); err != nil {
+			v.addf("%s must be an object", key)
+		}
+	}
+
+	if _, ok := root["general_canada"]; !ok {
+		v.addf("missing general_canada")
+	}
+}
+
+// validatePackingRules checks packing_rules.json
+func validatePackingRules(v *datasetValidator, root map[string]json.RawMessage) {
+	sections := []string{"weather_rules", "activity_rules", "duration_rules", "group_rules", "age_rules", "special_needs", "baggage_rules"}
+	for _, section := range sections {
+		raw, ok := root[section]
+		if !ok {
+			v.addf("missing %s", section)
+			continue
+		}
+		var rules map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &rules); err != nil {
+			v.addf("%s must be an object", section)
+		}
+	}
+
+	var weatherRules map[string]struct {
+		TemperatureRange []float64 `json:"temperature_range"`
+	}
+	if raw, ok := root["weather_rules"]; ok && json.Unmarshal(raw, &weatherRules) == nil {
+		for name, rule := range weatherRules {
+			if len(rule.TemperatureRange) != 2 || rule.TemperatureRange[0] > rule.TemperatureRange[1] {
+				v.addf("weather_rules.%s: temperature_range must be [min, max]", name)
+			}
+		}
+	}
+
+	var baggageRules map[string]json.RawMessage
+	if raw, ok := root["baggage_rules"]; ok && json.Unmarshal(raw, &baggageRules) == nil {
+		for _, required := range []string{"carry_on", "checked"} {
+			if _, ok := baggageRules[required]; !ok {
+				v.addf("baggage_rules: missing %s", required)
+			}
+		}
+	}
+
+	// The carry-on / checked split is optional
+	if raw, ok := root["baggage_split"]; ok {
+		var split BaggageSplitRules
+		if err := json.Unmarshal(raw, &split); err != nil {
+			v.addf("baggage_split is invalid: %v", err)
+		} else if split.Liquids.MaxContainerML <= 0 || split.Liquids.BagLiters <= 0 {
+			v.addf("baggage_split.liquids: max_container_ml and bag_liters must be positive")
+		}
+	}
+
+	if raw, ok := root["health_rules"]; ok {
+		var health HealthRules
+		if err := json.Unmarshal(raw, &health); err != nil {
+			v.addf("health_rules is invalid: %v", err)
+		} else {
+			for _, rule := range health.Seasonal {
+				for _, month := range rule.Months {
+					if month < 1 || month > 12 {
+						v.addf("health_rules.seasonal %q: month %d out of range", rule.Name, month)
+					}
+				}
+			}
+		}
+	}
+
+	// Synonyms are optional, but must point at activity rules that exist
+	if raw, ok := root["activity_synonyms"]; ok {
+		var synonyms map[string][]string
+		var activityRules map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &synonyms); err != nil {
+			v.addf("activity_synonyms must map activity rules to lists of phrases")
+		} else if json.Unmarshal(root["activity_rules"], &activityRules) == nil {
+			for activity := range synonyms {
+				if _, ok := activityRules[activity]; !ok {
+					v.addf("activity_synonyms.%s: no such activity rule", activity)
+				}
+			}
+		}
+	}
+}
+
+// validateCostOfLiving checks cost_of_living.json
+func validateCostOfLiving(v *datasetValidator, root map[string]json.RawMessage) {
+	var costs CostOfLiving
+	sections := map[string]interface{}{
+		"base_currency":  &costs.BaseCurrency,
+		"exchange_rates": &costs.ExchangeRates,
+		"default":        &costs.Default,
+		"cities":         &costs.Cities,
+	}
+	for section, target := range sections {
+		raw, ok := root[section]
+		if !ok {
+			v.addf("missing %s", section)
+			continue
+		}
+		if err := json.Unmarshal(raw, target); err != nil {
+			v.addf("%s is invalid: %v", section, err)
+		}
+	}
+
+	if rate, ok := costs.ExchangeRates[costs.BaseCurrency]; !ok || rate != 1 {
+		v.addf("exchange_rates: base currency %q must have a rate of 1", costs.BaseCurrency)
+	}
+	for currency, rate := range costs.ExchangeRates {
+		if rate <= 0 {
+			v.addf("exchange_rates.%s must be positive", currency)
+		}
+	}
+
+	check := func(label string, city CityCosts) {
+		for _, tier := range AccommodationTiers {
+			if city.Accommodation[tier] <= 0 {
+				v.addf("%s: missing accommodation rate for %s", label, tier)
+			}
+			if city.Meal[tier] <= 0 {
+				v.addf("%s: missing meal price for %s", label, tier)
+			}
+		}
+	}
+	check("default", costs.Default)
+	for name, city := range costs.Cities {
+		check("cities."+name, city)
+	}
+}
+
+// validateAirlineBaggage checks airline_baggage.json
+func validateAirlineBaggage(v *datasetValidator, root map[string]json.RawMessage) {
+	var airlines map[string]AirlinePolicy
+	raw, ok := root["airlines"]
+	if !ok {
+		v.addf("missing airlines")
+		return
+	}
+	if err := json.Unmarshal(raw, &airlines); err != nil {
+		v.addf("airlines is invalid: %v", err)
+		return
+	}
+
+	for code, airline := range airlines {
+		label := "airlines." + code
+		if len(airline.CarryOn.DimensionsCM) != 3 || len(airline.PersonalItem.DimensionsCM) != 3 {
+			v.addf("%s: cabin bag dimensions must be [length, width, depth]", label)
+		}
+		if airline.Checked.MaxWeightKG <= 0 {
+			v.addf("%s: missing checked max_weight_kg", label)
+		}
+		if airline.Checked.OverweightLimitKG != 0 && airline.Checked.OverweightLimitKG < airline.Checked.MaxWeightKG {
+			v.addf("%s: overweight_limit_kg is below max_weight_kg", label)
+		}
+		if len(airline.FareClasses) == 0 {
+			v.addf("%s: no fare classes", label)
+		}
+		for name, fare := range airline.FareClasses {
+			if fare.CheckedIncluded < 0 {
+				v.addf("%s.fare_classes.%s: checked_included can't be negative", label, name)
+			}
+		}
+	}
+
+	if _, ok := root["item_estimates"]; !ok {
+		v.addf("missing item_estimates")
+	}
+}
+
+// validatePackingTemplates checks packing_templates.json
+func validatePackingTemplates(v *datasetValidator, root map[string]json.RawMessage) {
+	var templates []PackingTemplate
+	raw, ok := root["templates"]
+	if !ok {
+		v.addf("missing templates")
+		return
+	}
+	if err := json.Unmarshal(raw, &templates); err != nil {
+		v.addf("templates is invalid: %v", err)
+		return
+	}
+
+	seen := map[string]bool{}
+	for i, template := range templates {
+		label := fmt.Sprintf("templates[%d]", i)
+		if template.ID == "" || template.Name == "" {
+			v.addf("%s: id and name are required", label)
+		}
+		if seen[template.ID] {
+			v.addf("%s: duplicate id %q", label, template.ID)
+		}
+		seen[template.ID] = true
+		if countPackingItems(template.Categories) == 0 {
+			v.addf("%s: no items", label)
+		}
+	}
+}
+
+func validateTravelInsurance(v *datasetValidator, root map[string]json.RawMessage) {
+	var providers []InsuranceProvider
+	raw, ok := root["providers"]
+	if !ok {
+		v.addf("missing providers")
+		return
+	}
+	if err := json.Unmarshal(raw, &providers); err != nil {
+		v.addf("providers is invalid: %v", err)
+		return
+	}
+
+	for i, provider := range providers {
+		label := fmt.Sprintf("providers[%d]", i)
+		if provider.ID == "" || provider.Name == "" {
+			v.addf("%s: id and name are required", label)
+		}
+		if len(provider.Plans) == 0 {
+			v.addf("%s: no plans", label)
+		}
+		for j, plan := range provider.Plans {
+			planLabel := fmt.Sprintf("%s.plans[%d]", label, j)
+			switch plan.Type {
+			case InsuranceEmergencyMedical, InsuranceTripCancellation, InsuranceAllInclusive:
+			default:
+				v.addf("%s: unknown type %q", planLabel, plan.Type)
+			}
+			if len(plan.DailyRates) == 0 && plan.CancellationRate <= 0 {
+				v.addf("%s: needs daily_rates or a cancellation_rate", planLabel)
+			}
+			for _, rate := range plan.DailyRates {
+				if rate.Rate <= 0 || rate.MaxAge <= 0 {
+					v.addf("%s: daily rates need a positive max_age and rate", planLabel)
+				}
+			}
+		}
+	}
+}
+
+func validateConnectivity(v *datasetValidator, root map[string]json.RawMessage) {
+	var carriers []Carrier
+	raw, ok := root["carriers"]
+	if !ok {
+		v.addf("missing carriers")
+		return
+	}
+	if err := json.Unmarshal(raw, &carriers); err != nil {
+		v.addf("carriers is invalid: %v", err)
+		return
+	}
+	for i, carrier := range carriers {
+		if carrier.ID == "" || carrier.Name == "" {
+			v.addf("carriers[%d]: id and name are required", i)
+		}
+		if len(carrier.Plans) == 0 {
+			v.addf("carriers[%d]: no plans", i)
+		}
+	}
+
+	var caveats []CoverageCaveat
+	if raw, ok := root["coverage_caveats"]; ok {
+		if err := json.Unmarshal(raw, &caveats); err != nil {
+			v.addf("coverage_caveats is invalid: %v", err)
+			return
+		}
+	}
+	for i, caveat := range caveats {
+		if len(caveat.Destinations) == 0 && len(caveat.Provinces) == 0 {
+			v.addf("coverage_caveats[%d]: needs destinations or provinces", i)
+		}
+	}
+}
+
+func validateDrivingRules(v *datasetValidator, root map[string]json.RawMessage) {
+	var provinces map[string]ProvincialDrivingRule
+	raw, ok := root["provinces"]
+	if !ok {
+		v.addf("missing provinces")
+		return
+	}
+	if err := json.Unmarshal(raw, &provinces); err != nil {
+		v.addf("provinces is invalid: %v", err)
+		return
+	}
+
+	for name, rule := range provinces {
+		if rule.SpeedLimits.Urban <= 0 || rule.SpeedLimits.Highway <= 0 {
+			v.addf("provinces.%s: speed limits are required", name)
+		}
+		for label, tires := range map[string]*TireRule{"winter_tires": rule.WinterTires, "studded_tires": rule.StuddedTires} {
+			if tires == nil || (tires.From == "" && tires.To == "") {
+				continue
+			}
+			if _, err := time.Parse("01-02", tires.From); err != nil {
+				v.addf("provinces.%s.%s: from must be MM-DD", name, label)
+			}
+			if _, err := time.Parse("01-02", tires.To); err != nil {
+				v.addf("provinces.%s.%s: to must be MM-DD", name, label)
+			}
+		}
+	}
+}
+
+func validateCarRentals(v *datasetValidator, root map[string]json.RawMessage) {
+	var classes []VehicleClass
+	raw, ok := root["classes"]
+	if !ok {
+		v.addf("missing classes")
+		return
+	}
+	if err := json.Unmarshal(raw, &classes); err != nil {
+		v.addf("classes is invalid: %v", err)
+		return
+	}
+	if _, ok := root["companies"]; !ok {
+		v.addf("missing companies")
+	}
+
+	seen := map[string]bool{}
+	for i, class := range classes {
+		label := fmt.Sprintf("classes[%d]", i)
+		if class.ID == "" || seen[class.ID] {
+			v.addf("%s: id is missing or duplicated", label)
+		}
+		seen[class.ID] = true
+		if class.DailyRate <= 0 || class.Seats <= 0 || class.FuelLPer100Km <= 0 {
+			v.addf("%s: daily_rate, seats and fuel_l_per_100km must be positive", label)
+		}
+	}
+}
+
+func validateEmissionFactors(v *datasetValidator, root map[string]json.RawMessage) {
+	var transport map[string]TransportFactor
+	raw, ok := root["transport"]
+	if !ok {
+		v.addf("missing transport")
+		return
+	}
+	if err := json.Unmarshal(raw, &transport); err != nil {
+		v.addf("transport is invalid: %v", err)
+		return
+	}
+
+	for _, mode := range []string{"flight_short", "flight_long", EmissionModeRail, EmissionModeBus} {
+		if transport[mode].KgPerPassengerKm <= 0 {
+			v.addf("transport.%s: kg_per_passenger_km must be positive", mode)
+		}
+	}
+	if transport[EmissionModeCar].KgPerVehicleKm <= 0 {
+		v.addf("transport.car: kg_per_vehicle_km must be positive")
+	}
+	if transport["flight_short"].MaxKm <= 0 {
+		v.addf("transport.flight_short: max_km must be positive")
+	}
+}
+
+func validateFestivals(v *datasetValidator, root map[string]json.RawMessage) {
+	var festivals []Festival
+	raw, ok := root["festivals"]
+	if !ok {
+		v.addf("missing festivals")
+		return
+	}
+	if err := json.Unmarshal(raw, &festivals); err != nil {
+		v.addf("festivals is invalid: %v", err)
+		return
+	}
+
+	seen := map[string]bool{}
+	for i, festival := range festivals {
+		label := fmt.Sprintf("festivals[%d]", i)
+		if festival.ID == "" || seen[festival.ID] {
+			v.addf("%s: id is missing or duplicated", label)
+		}
+		seen[festival.ID] = true
+		if festival.Name == "" || festival.City == "" {
+			v.addf("%s: name and city are required", label)
+		}
+		if _, err := time.Parse("01-02", festival.TypicalStart); err != nil {
+			v.addf("%s: typical_start must be MM-DD", label)
+		}
+		if _, err := time.Parse("01-02", festival.TypicalEnd); err != nil {
+			v.addf("%s: typical_end must be MM-DD", label)
+		}
+	}
+}
+
+func validateNightlife(v *datasetValidator, root map[string]json.RawMessage) {
+	var ages map[string]int
+	if err := json.Unmarshal(root["drinking_age"], &ages); err != nil || ages["default"] <= 0 {
+		v.addf("drinking_age must include a positive default")
+	}
+
+	var cities map[string][]NightlifeVenue
+	raw, ok := root["cities"]
+	if !ok {
+		v.addf("missing cities")
+		return
+	}
+	if err := json.Unmarshal(raw, &cities); err != nil {
+		v.addf("cities is invalid: %v", err)
+		return
+	}
+
+	for city, venues := range cities {
+		for i, venue := range venues {
+			label := fmt.Sprintf("cities.%s[%d]", city, i)
+			if venue.Name == "" || venue.Neighborhood == "" {
+				v.addf("%s: name and neighborhood are required", label)
+			}
+			if !utils.Contains([]string{VenueBar, VenueClub, VenueLiveMusic, VenueLateFood}, venue.Type) {
+				v.addf("%s: unknown type %q", label, venue.Type)
+			}
+			if _, err := time.Parse("15:04", venue.Opens); err != nil {
+				v.addf("%s: opens must be HH:MM", label)
+			}
+			if _, err := time.Parse("15:04", venue.Closes); err != nil {
+				v.addf("%s: closes must be HH:MM", label)
+			}
+		}
+	}
+}
+
+func validateModerationRules(v *datasetValidator, root map[string]json.RawMessage) {
+	var blockedReply string
+	if err := json.Unmarshal(root["blocked_reply"], &blockedReply); err != nil || blockedReply == "" {
+		v.addf("blocked_reply is required")
+	}
+
+	var rules []ModerationRule
+	raw, ok := root["rules"]
+	if !ok {
+		v.addf("missing rules")
+		return
+	}
+	if err := json.Unmarshal(raw, &rules); err != nil {
+		v.addf("rules is invalid: %v", err)
+		return
+	}
+
+	for i, rule := range rules {
+		label := fmt.Sprintf("rules[%d]", i)
+		if rule.ID == "" || rule.Category == "" {
+			v.addf("%s: id and category are required", label)
+		}
+		if rule.Action != ModerationFlag && rule.Action != ModerationBlock {
+			v.addf("%s: action must be flag or block", label)
+		}
+		for _, stage := range rule.Stages {
+			if stage != ModerationInput && stage != ModerationOutput {
+				v.addf("%s: unknown stage %q", label, stage)
+			}
+		}
+		if len(rule.Patterns) == 0 {
+			v.addf("%s: at least one pattern is required", label)
+		}
+		for _, pattern := range rule.Patterns {
+			if _, err := regexp.Compile("(?i)" + pattern); err != nil {
+				v.addf("%s: invalid pattern %q: %v", label, pattern, err)
+			}
+		}
+	}
+}
+
+func validateRules(v *datasetValidator, root map[string]json.RawMessage) {
+	content, _ := json.Marshal(root)
+	_, problems := compileRules(content)
+	for _, problem := range problems {
+		v.addf("%s", problem)
+	}
+}
+
+func validateExperiments(v *datasetValidator, root map[string]json.RawMessage) {
+	var experiments []Experiment
+	raw, ok := root["experiments"]
+	if !ok {
+		v.addf("missing experiments")
+		return
+	}
+	if err := json.Unmarshal(raw, &experiments); err != nil {
+		v.addf("experiments is invalid: %v", err)
+		return
+	}
+
+	for i, experiment := range experiments {
+		label := fmt.Sprintf("experiments[%d]", i)
+		known, ok := knownVariants[experiment.ID]
+		if !ok {
+			v.addf("%s: unknown experiment %q", label, experiment.ID)
+			continue
+		}
+		total := 0
+		for _, variant := range experiment.Variants {
+			if !utils.Contains(known, variant.Name) {
+				v.addf("%s: unknown variant %q", label, variant.Name)
+			}
+			if variant.Weight < 0 {
+				v.addf("%s: variant %s has a negative weight", label, variant.Name)
+			}
+			total += variant.Weight
+		}
+		if experiment.Enabled && total <= 0 {
+			v.addf("%s: an enabled experiment needs a positive total weight", label)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/joshndala/cantrip/data"
+)
+
+// Seed dataset file names
+const (
+	CityMetadataDataset     = "city_metadata.json"
+	TipsDataset             = "tips.json"
+	PackingRulesDataset     = "packing_rules.json"
+	CostOfLivingDataset     = "cost_of_living.json"
+	AirlineBaggageDataset   = "airline_baggage.json"
+	PackingTemplatesDataset = "packing_templates.json"
+	TravelInsuranceDataset  = "travel_insurance.json"
+	ConnectivityDataset     = "connectivity.json"
+	DrivingRulesDataset     = "driving_rules.json"
+	CarRentalsDataset       = "car_rentals.json"
+	EmissionFactorsDataset  = "emission_factors.json"
+	FestivalsDataset        = "festivals.json"
+	NightlifeDataset        = "nightlife.json"
+	ModerationRulesDataset  = "moderation_rules.json"
+	RulesDataset            = "rules.json"
+	ExperimentsDataset      = "experiments.json"
+)
+
+// RequiredDatasets must be available before the server starts
+var RequiredDatasets = []string{CityMetadataDataset, TipsDataset, PackingRulesDataset, CostOfLivingDataset, AirlineBaggageDataset, PackingTemplatesDataset, TravelInsuranceDataset, ConnectivityDataset, DrivingRulesDataset, CarRentalsDataset, EmissionFactorsDataset, FestivalsDataset, NightlifeDataset, ModerationRulesDataset, RulesDataset, ExperimentsDataset}
+
+// datasetDir returns the override directory set by DATA_DIR, or "" to use the embedded data
+func datasetDir() string {
+	return os.Getenv("DATA_DIR")
+}
+
+// datasetDirs returns the override directories searched for the tenant ctx acts for: its
+// own data directory, then DATA_DIR
+func datasetDirs(ctx context.Context) []string {
+	var dirs []string
+	if dir := TenantFromContext(ctx).DataDir; dir != "" {
+		dirs = append(dirs, dir)
+	}
+	if dir := datasetDir(); dir != "" {
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// ReadDataset reads a dataset from the tenant's override directories, falling back to the
+// embedded copy
+func ReadDataset(ctx context.Context, name string) ([]byte, error) {
+	for _, dir := range datasetDirs(ctx) {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			return content, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read %s from %s: %w", name, dir, err)
+		}
+	}
+
+	content, err := fs.ReadFile(data.Seed, name)
+	if err != nil {
+		return nil, fmt.Errorf("dataset %s not found: %w", name, err)
+	}
+	return content, nil
+}
+
+// datasetSource describes where a dataset is loaded from
+func datasetSource(ctx context.Context, name string) string {
+	for _, dir := range datasetDirs(ctx) {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return filepath.Join(dir, name)
+		}
+	}
+	return "embedded:" + name
+}
+
+// CheckDatasets verifies that every required dataset can be read for the tenant ctx acts
+// for and reports all missing files at once
+func CheckDatasets(ctx context.Context) error {
+	for _, dir := range datasetDirs(ctx) {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return fmt.Errorf("data directory %s is not accessible: %w", dir, err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("data directory %s is not a directory", dir)
+		}
+	}
+
+	var problems []string
+	for _, name := range RequiredDatasets {
+		if _, err := ReadDataset(ctx, name); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("missing datasets:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+// DatasetSources lists where each required dataset is loaded from for the tenant ctx acts for
+func DatasetSources(ctx context.Context) map[string]string {
+	sources := make(map[string]string, len(RequiredDatasets))
+	for _, name := range RequiredDatasets {
+		sources[name] = datasetSource(ctx, name)
+	}
+	return sources
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/joshndala/cantrip/models"
+)
+
+// TransportRental is the transport type of trips with a rental car
+const TransportRental = "rental"
+
+// DrivingRules represents the structure of driving_rules.json
+type DrivingRules struct {
+	General   GeneralDrivingRules              `json:"general"`
+	Provinces map[string]ProvincialDrivingRule `json:"provinces"`
+}
+
+// GeneralDrivingRules apply everywhere in Canada
+type GeneralDrivingRules struct {
+	DriveOn  string   `json:"drive_on"`
+	Units    string   `json:"units"`
+	Rules    []string `json:"rules"`
+	Wildlife []string `json:"wildlife"`
+	Winter   []string `json:"winter"` // included for trips with winter tire or studded tire seasons
+}
+
+// ProvincialDrivingRule holds a province's speed limits and tire laws
+type ProvincialDrivingRule struct {
+	SpeedLimits  SpeedLimits `json:"speed_limits"`
+	WinterTires  *TireRule   `json:"winter_tires,omitempty"`
+	StuddedTires *TireRule   `json:"studded_tires,omitempty"`
+	Wildlife     []string    `json:"wildlife"`
+	Rules        []string    `json:"rules"`
+}
+
+// SpeedLimits are the default limits in km/h unless signed otherwise
+type SpeedLimits = models.SpeedLimits
+
+// TireRule is a seasonal tire requirement or allowance. Without dates it applies year-round.
+type TireRule = models.TireRule
+
+// DrivingAdvisory is the driving information for a trip
+type DrivingAdvisory = models.DrivingAdvisory
+
+// ErrUnknownProvince is returned when a destination has no provincial driving rules
+var ErrUnknownProvince = errors.New("no driving rules for the destination's province")
+
+// loadDrivingRules loads the driving rules dataset
+func loadDrivingRules(ctx context.Context) (*DrivingRules, error) {
+	data, err := ReadDataset(ctx, DrivingRulesDataset)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules DrivingRules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+	return &rules, nil
+}
+
+// GetDrivingAdvisory returns the driving rules for a destination's province. With trip dates
+// (YYYY-MM-DD), the tire laws and winter advice in effect during the trip become warnings.
+func GetDrivingAdvisory(ctx context.Context, destination, startDate, endDate string) (*DrivingAdvisory, error) {
+	rules, err := loadDrivingRules(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load driving rules: %w", err)
+	}
+
+	// Provinces can be asked for directly as well as through a destination
+	province := destination
+	if metadata, err := loadCityMetadata(ctx); err == nil {
+		if city, err := findCity(metadata, destination); err == nil {
+			province = city.Province
+		}
+	}
+	var provincial ProvincialDrivingRule
+	found := false
+	for name, rule := range rules.Provinces {
+		if strings.EqualFold(name, province) {
+			province, provincial, found = name, rule, true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("%s: %w", destination, ErrUnknownProvince)
+	}
+
+	advisory := &DrivingAdvisory{
+		Destination:  destination,
+		Province:     province,
+		DriveOn:      rules.General.DriveOn,
+		Units:        rules.General.Units,
+		SpeedLimits:  provincial.SpeedLimits,
+		WinterTires:  provincial.WinterTires,
+		StuddedTires: provincial.StuddedTires,
+		Rules:        append(append([]string{}, rules.General.Rules...), provincial.Rules...),
+		Wildlife:     append(append([]string{}, rules.General.Wildlife...), provincial.Wildlife...),
+		Warnings:     []string{},
+	}
+
+	start, err := time.Parse("2006-01-02", startDate)
+	if err != nil {
+		return advisory, nil
+	}
+	end, err := time.Parse("2006-01-02", endDate)
+	if err != nil || end.Before(start) {
+		end = start
+	}
+
+	winter := false
+	if tires := provincial.WinterTires; tires != nil && tires.Required && tireSeasonDuringTrip(tires, start, end) {
+		winter = true
+		warning := fmt.Sprintf("Winter tires are required in %s from %s to %s", province, formatMonthDay(tires.From), formatMonthDay(tires.To))
+		if tires.Note != "" {
+			warning += ": " + tires.Note
+		}
+		advisory.Warnings = append(advisory.Warnings, warning)
+	}
+	if tires := provincial.StuddedTires; tires != nil && tires.Allowed && tires.From != "" && tireSeasonDuringTrip(tires, start, end) {
+		winter = true
+		advisory.Warnings = append(advisory.Warnings, fmt.Sprintf("Studded tires are allowed in %s from %s to %s", province, formatMonthDay(tires.From), formatMonthDay(tires.To)))
+	}
+	if winter {
+		advisory.Warnings = append(advisory.Warnings, rules.General.Winter...)
+	}
+	return advisory, nil
+}
+
+// UsesRentalCar reports whether an itinerary is travelled by rental car, either as the
+// requested transport or in any day's transport plan
+func UsesRentalCar(requested string, itinerary *ItineraryResponse) bool {
+	if strings.EqualFold(strings.TrimSpace(requested), TransportRental) {
+		return true
+	}
+	if itinerary == nil {
+		return false
+	}
+	days, _ := itinerary.Itinerary["days"].([]interface{})
+	for _, day := range days {
+		dayMap, ok := day.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if mentionsRental(dayMap["transport"]) {
+			return true
+		}
+	}
+	return false
+}
+
+// mentionsRental reports whether a day's transport, either free text or a list of legs
+// with a type, includes a rental car
+func mentionsRental(transport interface{}) bool {
+	switch value := transport.(type) {
+	case string:
+		text := strings.ToLower(value)
+		return strings.Contains(text, "rental") || strings.Contains(text, "rent a car")
+	case []interface{}:
+		for _, leg := range value {
+			if legMap, ok := leg.(map[string]interface{}); ok {
+				if legType, _ := legMap["type"].(string); strings.EqualFold(legType, TransportRental) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// tireSeasonDuringTrip reports whether any day of the trip falls in the rule's season
+func tireSeasonDuringTrip(rule *TireRule, start, end time.Time) bool {
+	if rule.From == "" || rule.To == "" {
+		return true
+	}
+	for day, n := start, 0; !day.After(end) && n < 366; day, n = day.AddDate(0, 0, 1), n+1 {
+		monthDay := day.Format("01-02")
+		if rule.From <= rule.To {
+			if monthDay >= rule.From && monthDay <= rule.To {
+				return true
+			}
+		} else if monthDay >= rule.From || monthDay <= rule.To {
+			return true
+		}
+	}
+	return false
+}
+
+// formatMonthDay turns "12-01" into "December 1"
+func formatMonthDay(monthDay string) string {
+	t, err := time.Parse("01-02", monthDay)
+	if err != nil {
+		return monthDay
+	}
+	return t.Format("January 2")
+}
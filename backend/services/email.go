@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// Email is a plain-text message to one recipient
+type Email struct {
+	To      string
+	Subject string
+	Text    string
+}
+
+// Mailer sends email
+type Mailer interface {
+	Name() string
+	Send(ctx context.Context, email Email) error
+}
+
+// newMailer builds an SMTP mailer from SMTP_HOST, SMTP_PORT (default 587), SMTP_USERNAME,
+// SMTP_PASSWORD and SMTP_FROM, or returns nil when SMTP_HOST isn't set
+func newMailer() Mailer {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return nil
+	}
+	return &SMTPMailer{
+		Addr:     net.JoinHostPort(host, firstNonEmpty(os.Getenv("SMTP_PORT"), "587")),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     firstNonEmpty(os.Getenv("SMTP_FROM"), "CanTrip <no-reply@cantrip.app>"),
+	}
+}
+
+// SMTPMailer is a Mailer that relays through an SMTP server, using STARTTLS when the
+// server offers it
+type SMTPMailer struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Name() string {
+	return "smtp"
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, email Email) error {
+	if strings.ContainsAny(email.To+email.Subject, "\r\n") {
+		return fmt.Errorf("email headers must not contain line breaks")
+	}
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		host, _, _ := net.SplitHostPort(m.Addr)
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+
+	from := m.From
+	if start, end := strings.LastIndex(from, "<"), strings.LastIndex(from, ">"); start >= 0 && end > start {
+		from = from[start+1 : end]
+	}
+	message := strings.Join([]string{
+		"From: " + m.From,
+		"To: " + email.To,
+		"Subject: " + email.Subject,
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		strings.ReplaceAll(email.Text, "\n", "\r\n"),
+	}, "\r\n")
+
+	if err := smtp.SendMail(m.Addr, auth, from, []string{email.To}, []byte(message)); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,268 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Embedding providers, chosen with EMBEDDING_PROVIDER
+const (
+	EmbeddingProviderLocal  = "local"
+	EmbeddingProviderOpenAI = "openai"
+)
+
+// OpenAI embeddings endpoint and model
+const (
+	OpenAIEmbeddingBaseURL = "https://api.openai.com/v1"
+	OpenAIEmbeddingModel   = "text-embedding-3-small"
+)
+
+// localEmbeddingDims is the size of the local embedder's vectors
+const localEmbeddingDims = 512
+
+// conceptWeight is how much a word's related concepts count against the word itself
+const conceptWeight = 0.6
+
+// EmbeddingProvider turns text into vectors whose cosine similarity reflects meaning
+type EmbeddingProvider interface {
+	Name() string
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// newEmbeddingProvider picks the embedding provider from the environment, the local
+// embedder unless OpenAI is asked for or is the only key configured
+func newEmbeddingProvider() EmbeddingProvider {
+	apiKey := os.Getenv("OPENAI_API_KEY")
+	switch provider := os.Getenv("EMBEDDING_PROVIDER"); {
+	case provider == EmbeddingProviderOpenAI && apiKey != "",
+		provider == "" && apiKey != "":
+		return &OpenAIEmbeddingClient{
+			APIKey:     apiKey,
+			BaseURL:    OpenAIEmbeddingBaseURL,
+			Model:      firstNonEmpty(os.Getenv("OPENAI_EMBEDDING_MODEL"), OpenAIEmbeddingModel),
+			HTTPClient: upstreamHTTPClient(),
+		}
+	}
+	return &LocalEmbedder{}
+}
+
+// LocalEmbedder embeds text without an upstream: words are stemmed, expanded with the
+// travel concepts they relate to and hashed into a fixed-size vector. It finds "quiet
+// waterfront park" for "peaceful spot by the sea", though not the subtler matches a
+// trained model would.
+type LocalEmbedder struct{}
+
+func (e *LocalEmbedder) Name() string {
+	return EmbeddingProviderLocal
+}
+
+func (e *LocalEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = localEmbedding(text)
+	}
+	return vectors, nil
+}
+
+// localEmbedding hashes a text's terms and concepts into a unit vector
+func localEmbedding(text string) []float32 {
+	vector := make([]float64, localEmbeddingDims)
+	add := func(feature string, weight float64) {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		sign := 1.0
+		if sum&(1<<63) != 0 {
+			sign = -1
+		}
+		vector[sum%localEmbeddingDims] += sign * weight
+	}
+
+	for _, term := range embeddingTerms(text) {
+		add("w:"+term, 1)
+		for _, concept := range embeddingConcepts[term] {
+			add("c:"+concept, conceptWeight)
+		}
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += v * v
+	}
+	embedding := make([]float32, localEmbeddingDims)
+	if norm == 0 {
+		return embedding
+	}
+	norm = math.Sqrt(norm)
+	for i, v := range vector {
+		embedding[i] = float32(v / norm)
+	}
+	return embedding
+}
+
+// embeddingTerms lowercases, splits and stems a text, dropping stopwords
+func embeddingTerms(text string) []string {
+	words := strings.FieldsFunc(NormalizeCityName(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		if embeddingStopwords[word] {
+			continue
+		}
+		terms = append(terms, stemWord(word))
+	}
+	return terms
+}
+
+// stemWord strips common English suffixes so "gardens" and "garden" embed alike
+func stemWord(word string) string {
+	for _, suffix := range []string{"ing", "ies", "es", "ed", "s"} {
+		if len(word) > len(suffix)+3 && strings.HasSuffix(word, suffix) {
+			stem := strings.TrimSuffix(word, suffix)
+			switch {
+			case suffix == "ies":
+				stem += "y"
+			case (suffix == "ing" || suffix == "ed") && doubledConsonant(stem):
+				stem = stem[:len(stem)-1] // "tipping" -> "tip"
+			}
+			return stem
+		}
+	}
+	return word
+}
+
+// doubledConsonant reports whether a stem ends in a doubled consonant other than l, s or z,
+// which English doubles before -ing and -ed
+func doubledConsonant(stem string) bool {
+	n := len(stem)
+	if n < 2 || stem[n-1] != stem[n-2] {
+		return false
+	}
+	return !strings.ContainsRune("aeioulsz", rune(stem[n-1]))
+}
+
+var embeddingStopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "at": true, "be": true, "by": true,
+	"for": true, "from": true, "i": true, "in": true, "is": true, "it": true, "me": true,
+	"my": true, "near": true, "of": true, "on": true, "or": true, "place": true, "places": true,
+	"some": true, "somewhere": true, "spot": true, "spots": true, "the": true, "to": true,
+	"we": true, "where": true, "with": true, "want": true, "good": true, "best": true,
+	"how": true, "much": true, "what": true, "when": true, "do": true, "can": true, "should": true,
+}
+
+// embeddingConcepts maps stemmed words to the travel concepts they suggest. Words that
+// share a concept land near each other even when the words themselves differ.
+var embeddingConcepts = buildEmbeddingConcepts(map[string][]string{
+	"calm":      {"quiet", "peaceful", "calm", "tranquil", "serene", "relax", "relaxing", "secluded", "sleepy", "restful"},
+	"water":     {"water", "waterfront", "harbour", "harbor", "beach", "lake", "ocean", "sea", "seaside", "river", "wharf", "bay", "coast", "coastal", "shore", "marina", "waterfall", "canal", "pier", "island", "kayak", "whale"},
+	"reading":   {"read", "reading", "book", "bookstore", "library", "tea", "cafe", "coffee", "cozy", "study"},
+	"nature":    {"park", "garden", "gardens", "trail", "hike", "hiking", "forest", "nature", "mountain", "lookout", "wildlife", "outdoor", "outdoors", "walk", "scenic", "view"},
+	"history":   {"history", "historic", "heritage", "museum", "castle", "parliament", "fort", "old", "mansion", "cathedral", "basilica", "church"},
+	"arts":      {"art", "arts", "gallery", "theatre", "theater", "music", "concert", "festival", "culture", "cultural", "artsy"},
+	"food":      {"food", "foodie", "restaurant", "dining", "market", "cuisine", "eat", "brunch", "bakery", "poutine", "wine", "brewery"},
+	"night":     {"night", "nightlife", "bar", "club", "pub", "cocktail", "lounge", "dancing", "late", "drink"},
+	"family":    {"family", "kid", "kids", "children", "families", "playground", "zoo", "aquarium"},
+	"shopping":  {"shop", "shopping", "boutique", "mall", "upscale", "luxury"},
+	"budget":    {"budget", "cheap", "free", "affordable", "inexpensive"},
+	"winter":    {"winter", "snow", "ski", "skiing", "skate", "skating", "ice", "christmas"},
+	"summer":    {"summer", "sun", "sunny", "swim", "swimming", "patio"},
+	"safety":    {"safe", "safety", "emergency", "police", "danger", "caution"},
+	"transport": {"transit", "bus", "ferry", "train", "subway", "metro", "bike", "cycling", "drive", "parking"},
+	"money":     {"tip", "tipping", "tax", "price", "cost", "cash", "currency", "pay"},
+})
+
+// buildEmbeddingConcepts inverts concept groups into stemmed word -> concepts
+func buildEmbeddingConcepts(groups map[string][]string) map[string][]string {
+	concepts := map[string][]string{}
+	for concept, words := range groups {
+		for _, word := range words {
+			stem := stemWord(word)
+			concepts[stem] = append(concepts[stem], concept)
+		}
+	}
+	return concepts
+}
+
+// OpenAIEmbeddingClient is an EmbeddingProvider backed by the OpenAI embeddings API
+type OpenAIEmbeddingClient struct {
+	APIKey     string
+	BaseURL    string
+	Model      string
+	HTTPClient *http.Client
+}
+
+func (c *OpenAIEmbeddingClient) Name() string {
+	return EmbeddingProviderOpenAI
+}
+
+func (c *OpenAIEmbeddingClient) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	payload, err := json.Marshal(map[string]interface{}{"model": c.Model, "input": texts})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/embeddings", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OpenAI embeddings request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch from OpenAI embeddings: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OpenAI embeddings API returned status: %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode OpenAI embeddings response: %w", err)
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, item := range result.Data {
+		if item.Index >= 0 && item.Index < len(vectors) {
+			vectors[item.Index] = item.Embedding
+		}
+	}
+	for i, vector := range vectors {
+		if vector == nil {
+			return nil, fmt.Errorf("OpenAI embeddings response is missing input %d", i)
+		}
+	}
+	return vectors, nil
+}
+
+// cosineSimilarity compares two vectors of the same length
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,314 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/joshndala/cantrip/utils"
+)
+
+// Transport modes for emission estimates
+const (
+	EmissionModeFlight = "flight"
+	EmissionModeCar    = "car"
+	EmissionModeRail   = "rail"
+	EmissionModeBus    = "bus"
+)
+
+// Sources of emission legs
+const (
+	EmissionSourceBooking = "booking" // an imported flight
+	EmissionSourceRental  = "rental"  // the rental car's estimated fuel
+	EmissionSourceOrigin  = "origin"  // the journey from the traveler's home
+)
+
+// seatsPerCar is how many travelers share one car on drives from home
+const seatsPerCar = 5
+
+// ErrInvalidEmissionsQuery is returned for unknown modes or origins
+var ErrInvalidEmissionsQuery = errors.New("invalid emissions query")
+
+// EmissionFactors represents the structure of emission_factors.json
+type EmissionFactors struct {
+	Note                  string                     `json:"note"`
+	Transport             map[string]TransportFactor `json:"transport"`
+	FuelKgPerLiter        float64                    `json:"fuel_kg_per_liter"`
+	AccommodationPerNight map[string]float64         `json:"accommodation_kg_per_room_night"`
+	Airports              map[string]string          `json:"airports"`  // IATA code -> city
+	Locations             map[string]Coordinates     `json:"locations"` // origins outside the city metadata
+	RailCorridors         [][]string                 `json:"rail_corridors"`
+	Alternatives          struct {
+		MaxRailKm float64 `json:"max_rail_km"`
+		MaxBusKm  float64 `json:"max_bus_km"`
+	} `json:"alternatives"`
+}
+
+// TransportFactor is the emission factor for a mode. Distances are great-circle, so the
+// detour factor accounts for real routes.
+type TransportFactor struct {
+	KgPerPassengerKm float64 `json:"kg_per_passenger_km,omitempty"`
+	KgPerVehicleKm   float64 `json:"kg_per_vehicle_km,omitempty"`
+	MaxKm            float64 `json:"max_km,omitempty"`
+	DetourFactor     float64 `json:"detour_factor"`
+}
+
+// EmissionsQuery adds the journey from home to a trip's estimate
+type EmissionsQuery struct {
+	Origin       string `json:"origin,omitempty"` // city or airport code
+	Mode         string `json:"mode,omitempty"`   // flight by default
+	OneWay       bool   `json:"one_way,omitempty"`
+	Alternatives bool   `json:"alternatives,omitempty"`
+}
+
+// EmissionLeg is one journey's estimated emissions for everyone on it
+type EmissionLeg struct {
+	Mode       string  `json:"mode"`
+	From       string  `json:"from"`
+	To         string  `json:"to"`
+	DistanceKm float64 `json:"distance_km"`
+	Travelers  int     `json:"travelers"`
+	KgCO2e     float64 `json:"kg_co2e"`
+	Source     string  `json:"source"`
+}
+
+// AccommodationEmissions estimates the trip's lodging
+type AccommodationEmissions struct {
+	Tier   string  `json:"tier"`
+	Nights int     `json:"nights"`
+	Rooms  int     `json:"rooms"`
+	KgCO2e float64 `json:"kg_co2e"`
+}
+
+// EmissionAlternative is a lower-carbon way to make a leg
+type EmissionAlternative struct {
+	From     string  `json:"from"`
+	To       string  `json:"to"`
+	Instead  string  `json:"instead"` // the leg's mode
+	Mode     string  `json:"mode"`
+	KgCO2e   float64 `json:"kg_co2e"`
+	SavingKg float64 `json:"saving_kg"`
+}
+
+// TripEmissions is a trip's estimated carbon footprint
+type TripEmissions struct {
+	ItineraryID   string                 `json:"itinerary_id"`
+	Travelers     int                    `json:"travelers"`
+	Legs          []EmissionLeg          `json:"legs"`
+	Accommodation AccommodationEmissions `json:"accommodation"`
+	TransportKg   float64                `json:"transport_kg"`
+	TotalKg       float64                `json:"total_kg"`
+	PerTravelerKg float64                `json:"per_traveler_kg"`
+	Alternatives  []EmissionAlternative  `json:"alternatives,omitempty"`
+	Unresolved    []string               `json:"unresolved,omitempty"` // flights whose airports aren't known
+	Note          string                 `json:"note"`
+}
+
+// loadEmissionFactors loads the emission factors dataset
+func loadEmissionFactors(ctx context.Context) (*EmissionFactors, error) {
+	data, err := ReadDataset(ctx, EmissionFactorsDataset)
+	if err != nil {
+		return nil, err
+	}
+
+	var factors EmissionFactors
+	if err := json.Unmarshal(data, &factors); err != nil {
+		return nil, err
+	}
+	return &factors, nil
+}
+
+// EstimateTripEmissions estimates CO2e for an itinerary's imported flights, rental car and
+// lodging, plus the journey from the query's origin. With Alternatives, rail and bus
+// options are suggested for legs short enough to make on the ground.
+func EstimateTripEmissions(ctx context.Context, itinerary *ItineraryResponse, query EmissionsQuery) (*TripEmissions, error) {
+	factors, err := loadEmissionFactors(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load emission factors: %w", err)
+	}
+	metadata, err := loadCityMetadata(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load city metadata: %w", err)
+	}
+
+	city, _, _, _ := itineraryTripDates(itinerary)
+	if city == "" {
+		city = itinerary.Metadata.City
+	}
+	travelers := intField(itinerary.Itinerary, "group_size", 1)
+	emissions := &TripEmissions{
+		ItineraryID: itinerary.ID,
+		Travelers:   travelers,
+		Legs:        []EmissionLeg{},
+		Note:        factors.Note,
+	}
+
+	// The journey from home, there and back
+	if query.Origin != "" {
+		mode := firstNonEmpty(strings.ToLower(query.Mode), EmissionModeFlight)
+		if !utils.Contains([]string{EmissionModeFlight, EmissionModeCar, EmissionModeRail, EmissionModeBus}, mode) {
+			return nil, fmt.Errorf("%w: mode must be flight, car, rail or bus", ErrInvalidEmissionsQuery)
+		}
+		from, ok := resolveEmissionLocation(factors, metadata, query.Origin)
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown origin %q", ErrInvalidEmissionsQuery, query.Origin)
+		}
+		to, ok := resolveEmissionLocation(factors, metadata, city)
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown destination %q", ErrInvalidEmissionsQuery, city)
+		}
+		distance := utils.CalculateDistance(from.Lat, from.Lng, to.Lat, to.Lng)
+		emissions.Legs = append(emissions.Legs, emissionLeg(factors, mode, query.Origin, city, distance, travelers, EmissionSourceOrigin))
+		if !query.OneWay {
+			emissions.Legs = append(emissions.Legs, emissionLeg(factors, mode, city, query.Origin, distance, travelers, EmissionSourceOrigin))
+		}
+	}
+
+	// Imported flights, for everyone on the trip
+	for _, booking := range itinerary.Anchors {
+		if booking.Type != BookingFlight {
+			continue
+		}
+		from, okFrom := resolveEmissionLocation(factors, metadata, booking.Origin)
+		to, okTo := resolveEmissionLocation(factors, metadata, booking.Destination)
+		if !okFrom || !okTo {
+			emissions.Unresolved = append(emissions.Unresolved, firstNonEmpty(booking.FlightNumber, booking.Title))
+			continue
+		}
+		distance := utils.CalculateDistance(from.Lat, from.Lng, to.Lat, to.Lng)
+		emissions.Legs = append(emissions.Legs, emissionLeg(factors, EmissionModeFlight, booking.Origin, booking.Destination, distance, travelers, EmissionSourceBooking))
+	}
+
+	// The rental car, from its fuel estimate
+	if rental := itinerary.Rental; rental != nil {
+		emissions.Legs = append(emissions.Legs, EmissionLeg{
+			Mode:       EmissionModeCar,
+			From:       city,
+			To:         city,
+			DistanceKm: float64(rental.DailyKm * rental.Offer.Days),
+			Travelers:  travelers,
+			KgCO2e:     math.Round(rental.FuelLiters*factors.FuelKgPerLiter*10) / 10,
+			Source:     EmissionSourceRental,
+		})
+	}
+
+	// Lodging: every night but the last day, in shared rooms
+	days, _ := itinerary.Itinerary["days"].([]interface{})
+	tier := strings.ToLower(stringField(itinerary.Itinerary, "accommodation", DefaultAccommodation))
+	if _, ok := factors.AccommodationPerNight[tier]; !ok {
+		tier = DefaultAccommodation
+	}
+	emissions.Accommodation = AccommodationEmissions{
+		Tier:   tier,
+		Nights: max(len(days)-1, 0),
+		Rooms:  (travelers + guestsPerRoom - 1) / guestsPerRoom,
+	}
+	emissions.Accommodation.KgCO2e = math.Round(factors.AccommodationPerNight[tier]*float64(emissions.Accommodation.Nights*emissions.Accommodation.Rooms)*10) / 10
+
+	for _, leg := range emissions.Legs {
+		emissions.TransportKg += leg.KgCO2e
+		if query.Alternatives {
+			emissions.Alternatives = append(emissions.Alternatives, emissionAlternatives(factors, leg)...)
+		}
+	}
+	emissions.TransportKg = math.Round(emissions.TransportKg*10) / 10
+	emissions.TotalKg = math.Round((emissions.TransportKg+emissions.Accommodation.KgCO2e)*10) / 10
+	emissions.PerTravelerKg = math.Round(emissions.TotalKg/float64(max(travelers, 1))*10) / 10
+	return emissions, nil
+}
+
+// emissionLeg estimates one journey for the travelers. Cars are counted per vehicle, other
+// modes per passenger; flights use the short- or long-haul factor by distance.
+func emissionLeg(factors *EmissionFactors, mode, from, to string, distance float64, travelers int, source string) EmissionLeg {
+	factor := factors.Transport[mode]
+	if mode == EmissionModeFlight {
+		factor = factors.Transport["flight_long"]
+		if short := factors.Transport["flight_short"]; distance <= short.MaxKm {
+			factor = short
+		}
+	}
+	distance *= math.Max(factor.DetourFactor, 1)
+
+	kg := factor.KgPerPassengerKm * distance * float64(travelers)
+	if mode == EmissionModeCar {
+		cars := (travelers + seatsPerCar - 1) / seatsPerCar
+		kg = factor.KgPerVehicleKm * distance * float64(cars)
+	}
+	return EmissionLeg{
+		Mode:       mode,
+		From:       from,
+		To:         to,
+		DistanceKm: math.Round(distance),
+		Travelers:  travelers,
+		KgCO2e:     math.Round(kg*10) / 10,
+		Source:     source,
+	}
+}
+
+// emissionAlternatives suggests rail on shared corridors, or a bus, for short flights and drives
+func emissionAlternatives(factors *EmissionFactors, leg EmissionLeg) []EmissionAlternative {
+	if leg.Mode != EmissionModeFlight && leg.Mode != EmissionModeCar || leg.From == leg.To {
+		return nil
+	}
+
+	var alternatives []EmissionAlternative
+	consider := func(mode string, maxKm float64) {
+		if leg.DistanceKm > maxKm {
+			return
+		}
+		factor := factors.Transport[mode]
+		kg := math.Round(factor.KgPerPassengerKm*leg.DistanceKm*float64(leg.Travelers)*10) / 10
+		if kg >= leg.KgCO2e {
+			return
+		}
+		alternatives = append(alternatives, EmissionAlternative{
+			From:     leg.From,
+			To:       leg.To,
+			Instead:  leg.Mode,
+			Mode:     mode,
+			KgCO2e:   kg,
+			SavingKg: math.Round((leg.KgCO2e-kg)*10) / 10,
+		})
+	}
+	if onRailCorridor(factors, leg.From, leg.To) {
+		consider(EmissionModeRail, factors.Alternatives.MaxRailKm)
+	}
+	consider(EmissionModeBus, factors.Alternatives.MaxBusKm)
+	return alternatives
+}
+
+// onRailCorridor reports whether two places are on the same passenger rail line
+func onRailCorridor(factors *EmissionFactors, from, to string) bool {
+	from, to = airportCity(factors, from), airportCity(factors, to)
+	for _, corridor := range factors.RailCorridors {
+		if containsFold(corridor, from) && containsFold(corridor, to) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveEmissionLocation finds coordinates for a city or airport code
+func resolveEmissionLocation(factors *EmissionFactors, metadata *CityMetadata, place string) (Coordinates, bool) {
+	place = airportCity(factors, strings.TrimSpace(place))
+	if city, err := findCity(metadata, place); err == nil {
+		return city.Coordinates, true
+	}
+	for name, coordinates := range factors.Locations {
+		if strings.EqualFold(name, place) {
+			return coordinates, true
+		}
+	}
+	return Coordinates{}, false
+}
+
+// airportCity maps an IATA code to its city, leaving other names unchanged
+func airportCity(factors *EmissionFactors, place string) string {
+	if city, ok := factors.Airports[strings.ToUpper(place)]; ok {
+		return city
+	}
+	return place
+}
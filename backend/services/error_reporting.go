@@ -0,0 +1,293 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joshndala/cantrip/utils"
+)
+
+// ERROR_REPORTER values; panics are only logged unless a service is chosen
+const (
+	ErrorReporterLog     = "log"
+	ErrorReporterSentry  = "sentry"
+	ErrorReporterRollbar = "rollbar"
+)
+
+// RollbarItemURL is Rollbar's item API
+const RollbarItemURL = "https://api.rollbar.com/api/1/item/"
+
+// errorReportTimeout bounds delivery of one report
+const errorReportTimeout = 5 * time.Second
+
+// ErrorReport describes a recovered panic
+type ErrorReport struct {
+	ID        string       `json:"id"`
+	Message   string       `json:"message"`
+	Type      string       `json:"type"` // the panic value's Go type
+	Frames    []StackFrame `json:"frames"`
+	Stack     string       `json:"stack"`
+	Method    string       `json:"method,omitempty"`
+	URL       string       `json:"url,omitempty"`
+	Route     string       `json:"route,omitempty"`
+	UserAgent string       `json:"user_agent,omitempty"`
+	UserID    string       `json:"user_id,omitempty"`
+	TraceID   string       `json:"trace_id,omitempty"`
+	Timestamp time.Time    `json:"timestamp"`
+}
+
+// StackFrame is one call in a panic's stack, innermost first
+type StackFrame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+// ErrorReporter delivers panic reports to an error tracking service
+type ErrorReporter interface {
+	Name() string
+	Report(ctx context.Context, report ErrorReport) error
+}
+
+// newErrorReporter builds the reporter chosen by ERROR_REPORTER
+func newErrorReporter() ErrorReporter {
+	client := &http.Client{Timeout: errorReportTimeout, Transport: tracedTransport(nil)}
+	switch reporter := os.Getenv("ERROR_REPORTER"); reporter {
+	case ErrorReporterSentry:
+		dsn, err := ParseSentryDSN(os.Getenv("SENTRY_DSN"))
+		if err != nil {
+			utils.LogWarning(fmt.Sprintf("ERROR_REPORTER=sentry needs a valid SENTRY_DSN (%v), panics are only logged", err))
+			return &LogErrorReporter{}
+		}
+		return &SentryReporter{DSN: dsn, Environment: errorReportEnvironment(), HTTPClient: client}
+	case ErrorReporterRollbar:
+		token := os.Getenv("ROLLBAR_ACCESS_TOKEN")
+		if token == "" {
+			utils.LogWarning("ERROR_REPORTER=rollbar requires ROLLBAR_ACCESS_TOKEN, panics are only logged")
+			return &LogErrorReporter{}
+		}
+		return &RollbarReporter{AccessToken: token, URL: RollbarItemURL, Environment: errorReportEnvironment(), HTTPClient: client}
+	case "", ErrorReporterLog:
+		return &LogErrorReporter{}
+	default:
+		utils.LogWarning(fmt.Sprintf("Unknown ERROR_REPORTER %q, panics are only logged", reporter))
+		return &LogErrorReporter{}
+	}
+}
+
+// errorReportEnvironment names the deployment in reports, "production" unless
+// ERROR_REPORTING_ENVIRONMENT says otherwise
+func errorReportEnvironment() string {
+	if env := os.Getenv("ERROR_REPORTING_ENVIRONMENT"); env != "" {
+		return env
+	}
+	return "production"
+}
+
+// ReportPanic logs a recovered panic and sends it to the configured reporter in the
+// background, so the failed request isn't held up by the tracking service. It fills in the
+// report's ID and timestamp and returns the ID for the error response.
+func ReportPanic(report ErrorReport) string {
+	if report.ID == "" {
+		report.ID = uuid.New().String()
+	}
+	if report.Timestamp.IsZero() {
+		report.Timestamp = time.Now().UTC()
+	}
+
+	// The log keeps the stack even when delivery fails
+	(&LogErrorReporter{}).Report(context.Background(), report)
+
+	if reporter := GetErrorReporter(); reporter != nil && reporter.Name() != ErrorReporterLog {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), errorReportTimeout)
+			defer cancel()
+			if err := reporter.Report(ctx, report); err != nil {
+				utils.LogError(fmt.Sprintf("Failed to report panic %s to %s", report.ID, reporter.Name()), err)
+			}
+		}()
+	}
+	return report.ID
+}
+
+// LogErrorReporter writes reports, stack included, to the server log
+type LogErrorReporter struct{}
+
+func (r *LogErrorReporter) Name() string {
+	return ErrorReporterLog
+}
+
+func (r *LogErrorReporter) Report(ctx context.Context, report ErrorReport) error {
+	utils.LogError(fmt.Sprintf("Recovered panic %s in %s %s", report.ID, report.Method, report.Route), fmt.Errorf("%s\n%s", report.Message, report.Stack))
+	return nil
+}
+
+// SentryDSN holds the parts of a Sentry DSN, https://<key>@<host>/<project>
+type SentryDSN struct {
+	PublicKey string
+	StoreURL  string
+}
+
+// ParseSentryDSN derives the store endpoint and key from a DSN
+func ParseSentryDSN(dsn string) (SentryDSN, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return SentryDSN{}, err
+	}
+	project := strings.Trim(u.Path, "/")
+	if u.User == nil || u.User.Username() == "" || u.Host == "" || project == "" {
+		return SentryDSN{}, fmt.Errorf("expected https://<key>@<host>/<project>")
+	}
+	// Self-hosted Sentry may sit under a path prefix, with the project ID last
+	prefix := ""
+	if i := strings.LastIndex(project, "/"); i >= 0 {
+		prefix, project = "/"+project[:i], project[i+1:]
+	}
+	return SentryDSN{
+		PublicKey: u.User.Username(),
+		StoreURL:  fmt.Sprintf("%s://%s%s/api/%s/store/", u.Scheme, u.Host, prefix, project),
+	}, nil
+}
+
+// SentryReporter sends reports to Sentry's store API
+type SentryReporter struct {
+	DSN         SentryDSN
+	Environment string
+	HTTPClient  *http.Client
+}
+
+func (r *SentryReporter) Name() string {
+	return ErrorReporterSentry
+}
+
+func (r *SentryReporter) Report(ctx context.Context, report ErrorReport) error {
+	// Sentry lists frames outermost first
+	frames := make([]map[string]interface{}, 0, len(report.Frames))
+	for i := len(report.Frames) - 1; i >= 0; i-- {
+		frame := report.Frames[i]
+		frames = append(frames, map[string]interface{}{
+			"function": frame.Function,
+			"abs_path": frame.File,
+			"lineno":   frame.Line,
+			"in_app":   strings.Contains(frame.Function, "joshndala/cantrip"),
+		})
+	}
+
+	event := map[string]interface{}{
+		"event_id":    strings.ReplaceAll(report.ID, "-", ""),
+		"timestamp":   report.Timestamp.Format(time.RFC3339),
+		"level":       "fatal",
+		"platform":    "go",
+		"logger":      "gin.recovery",
+		"environment": r.Environment,
+		"exception": map[string]interface{}{"values": []interface{}{map[string]interface{}{
+			"type":       report.Type,
+			"value":      report.Message,
+			"mechanism":  map[string]interface{}{"type": "panic", "handled": false},
+			"stacktrace": map[string]interface{}{"frames": frames},
+		}}},
+		"request": map[string]interface{}{
+			"method":  report.Method,
+			"url":     report.URL,
+			"headers": map[string]string{"User-Agent": report.UserAgent},
+		},
+		"tags": map[string]string{"route": report.Route, "trace_id": report.TraceID},
+	}
+	if report.UserID != "" {
+		event["user"] = map[string]string{"id": report.UserID}
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.DSN.StoreURL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create Sentry request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", fmt.Sprintf("Sentry sentry_version=7, sentry_client=cantrip-backend/1.0, sentry_key=%s", r.DSN.PublicKey))
+	return sendErrorReport(r.HTTPClient, req, "Sentry")
+}
+
+// RollbarReporter sends reports to Rollbar's item API
+type RollbarReporter struct {
+	AccessToken string
+	URL         string
+	Environment string
+	HTTPClient  *http.Client
+}
+
+func (r *RollbarReporter) Name() string {
+	return ErrorReporterRollbar
+}
+
+func (r *RollbarReporter) Report(ctx context.Context, report ErrorReport) error {
+	// Rollbar lists frames outermost first, like Sentry
+	frames := make([]map[string]interface{}, 0, len(report.Frames))
+	for i := len(report.Frames) - 1; i >= 0; i-- {
+		frame := report.Frames[i]
+		frames = append(frames, map[string]interface{}{
+			"filename": frame.File,
+			"lineno":   frame.Line,
+			"method":   frame.Function,
+		})
+	}
+
+	data := map[string]interface{}{
+		"environment": r.Environment,
+		"uuid":        report.ID,
+		"timestamp":   report.Timestamp.Unix(),
+		"level":       "critical",
+		"platform":    "go",
+		"language":    "go",
+		"framework":   "gin",
+		"context":     report.Route,
+		"body": map[string]interface{}{"trace": map[string]interface{}{
+			"frames":    frames,
+			"exception": map[string]string{"class": report.Type, "message": report.Message},
+		}},
+		"request": map[string]interface{}{
+			"url":     report.URL,
+			"method":  report.Method,
+			"headers": map[string]string{"User-Agent": report.UserAgent},
+		},
+		"custom": map[string]string{"trace_id": report.TraceID},
+	}
+	if report.UserID != "" {
+		data["person"] = map[string]string{"id": report.UserID}
+	}
+	payload, err := json.Marshal(map[string]interface{}{"data": data})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create Rollbar request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Rollbar-Access-Token", r.AccessToken)
+	return sendErrorReport(r.HTTPClient, req, "Rollbar")
+}
+
+// sendErrorReport posts a report and checks for a 2xx reply
+func sendErrorReport(client *http.Client, req *http.Request, service string) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send to %s: %w", service, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s API returned status: %d", service, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,280 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joshndala/cantrip/utils"
+)
+
+// EventWatchCollection stores tracked events
+const EventWatchCollection = "event_watches"
+
+// EventPriceDropThreshold is the relative price drop that triggers an alert
+const EventPriceDropThreshold = 0.05
+
+// ErrEventNotWatchable is returned for events that no ticketing provider can re-check
+var ErrEventNotWatchable = errors.New("event cannot be watched")
+
+// EventWatch tracks the price and availability of an event for a user
+type EventWatch struct {
+	ID               string     `json:"id"`
+	UserID           string     `json:"user_id"`
+	EventID          string     `json:"event_id"`
+	EventName        string     `json:"event_name"`
+	EventDate        string     `json:"event_date,omitempty"`
+	Provider         string     `json:"provider"`
+	City             string     `json:"city"`
+	TargetPrice      float64    `json:"target_price,omitempty"` // alert when the price reaches this
+	LastPrice        float64    `json:"last_price"`
+	TicketsAvailable bool       `json:"tickets_available"`
+	CreatedAt        time.Time  `json:"created_at"`
+	LastCheckedAt    *time.Time `json:"last_checked_at,omitempty"`
+	LastAlertAt      *time.Time `json:"last_alert_at,omitempty"`
+}
+
+// seenEvent is an event recently returned by a search, with the city it was found in
+type seenEvent struct {
+	event Event
+	city  string
+}
+
+// recentEvents lets users watch events straight from search results
+var recentEvents sync.Map // event ID -> seenEvent
+
+// rememberEvents indexes search results by event ID
+func rememberEvents(city string, events []Event) {
+	for _, event := range events {
+		if event.ID != "" {
+			recentEvents.Store(event.ID, seenEvent{event: event, city: city})
+		}
+	}
+}
+
+// findProviderEvent looks an event up in recent search results, re-querying the providers for the city if needed
+func findProviderEvent(ctx context.Context, eventID, city string) (*Event, string, error) {
+	if cached, ok := recentEvents.Load(eventID); ok {
+		seen := cached.(seenEvent)
+		return &seen.event, seen.city, nil
+	}
+	if city == "" {
+		return nil, "", fmt.Errorf("event %s is not in recent results, city is required: %w", eventID, ErrDocumentNotFound)
+	}
+
+	for _, event := range searchProviderEvents(ctx, GetEventProviders(), city) {
+		if EventID(event) == eventID {
+			event.ID = eventID
+			return &event, city, nil
+		}
+	}
+	return nil, "", fmt.Errorf("event %s not found in %s: %w", eventID, city, ErrDocumentNotFound)
+}
+
+// WatchEvent starts tracking an event for a user; watching the same event again updates the target price
+func WatchEvent(ctx context.Context, userID, eventID, city string, targetPrice float64) (*EventWatch, error) {
+	event, eventCity, err := findProviderEvent(ctx, eventID, city)
+	if err != nil {
+		return nil, err
+	}
+	if event.Provider == "" {
+		return nil, fmt.Errorf("event %s is not sold through a ticketing provider: %w", eventID, ErrEventNotWatchable)
+	}
+	if event.Date != "" && event.Date < time.Now().Format("2006-01-02") {
+		return nil, fmt.Errorf("event %s has already taken place: %w", eventID, ErrEventNotWatchable)
+	}
+
+	watches, err := ListEventWatches(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, watch := range watches {
+		if watch.EventID == eventID {
+			watch.TargetPrice = targetPrice
+			if err := saveDocument(ctx, EventWatchCollection, watch.ID, watch); err != nil {
+				return nil, fmt.Errorf("failed to save event watch: %w", err)
+			}
+			return &watch, nil
+		}
+	}
+
+	watch := EventWatch{
+		ID:               utils.GenerateID(),
+		UserID:           userID,
+		EventID:          eventID,
+		EventName:        event.Name,
+		EventDate:        event.Date,
+		Provider:         event.Provider,
+		City:             eventCity,
+		TargetPrice:      targetPrice,
+		LastPrice:        event.Price,
+		TicketsAvailable: event.TicketsAvailable,
+		CreatedAt:        time.Now().UTC(),
+	}
+	if err := saveDocument(ctx, EventWatchCollection, watch.ID, watch); err != nil {
+		return nil, fmt.Errorf("failed to save event watch: %w", err)
+	}
+	return &watch, nil
+}
+
+// UnwatchEvent stops tracking an event for a user
+func UnwatchEvent(ctx context.Context, userID, eventID string) error {
+	watches, err := ListEventWatches(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, watch := range watches {
+		if watch.EventID == eventID {
+			return deleteDocument(ctx, EventWatchCollection, watch.ID)
+		}
+	}
+	return fmt.Errorf("no watch for event %s: %w", eventID, ErrDocumentNotFound)
+}
+
+// ListEventWatches returns a user's watched events, or every watch when userID is empty
+func ListEventWatches(ctx context.Context, userID string) ([]EventWatch, error) {
+	ids, err := listDocumentIDs(ctx, EventWatchCollection)
+	if err != nil {
+		return nil, err
+	}
+
+	watches := []EventWatch{}
+	for _, id := range ids {
+		var watch EventWatch
+		if err := loadDocument(ctx, EventWatchCollection, id, &watch); err != nil {
+			continue
+		}
+		if userID == "" || watch.UserID == userID {
+			watches = append(watches, watch)
+		}
+	}
+
+	sort.Slice(watches, func(i, j int) bool {
+		return watches[i].CreatedAt.Before(watches[j].CreatedAt)
+	})
+	return watches, nil
+}
+
+// CheckEventWatches re-queries the providers for every watched event and notifies users
+// about price drops and tickets selling out. Each city is queried once per run.
+func CheckEventWatches(ctx context.Context) (int, error) {
+	watches, err := ListEventWatches(ctx, "")
+	if err != nil {
+		return 0, err
+	}
+	if len(watches) == 0 {
+		return 0, nil
+	}
+
+	providers := GetEventProviders()
+	if len(providers) == 0 {
+		return 0, fmt.Errorf("no event API keys configured")
+	}
+
+	today := time.Now().Format("2006-01-02")
+	byCity := map[string]map[string]Event{}
+	alerts := 0
+	for _, watch := range watches {
+		// Events that have already happened are no longer tracked
+		if watch.EventDate != "" && watch.EventDate < today {
+			if err := deleteDocument(ctx, EventWatchCollection, watch.ID); err != nil {
+				utils.LogError("Failed to remove expired event watch", err)
+			}
+			continue
+		}
+
+		cityKey := strings.ToLower(watch.City)
+		if _, ok := byCity[cityKey]; !ok {
+			byCity[cityKey] = map[string]Event{}
+			for _, event := range searchProviderEvents(ctx, providers, watch.City) {
+				byCity[cityKey][EventID(event)] = event
+			}
+		}
+
+		now := time.Now().UTC()
+		watch.LastCheckedAt = &now
+
+		event, found := byCity[cityKey][watch.EventID]
+		if found {
+			for _, notification := range eventWatchAlerts(watch, event) {
+				if _, err := Notify(ctx, notification); err != nil {
+					utils.LogError("Failed to send event alert", err)
+					continue
+				}
+				watch.LastAlertAt = &now
+				alerts++
+			}
+			watch.LastPrice = event.Price
+			watch.TicketsAvailable = event.TicketsAvailable
+		}
+
+		if err := saveDocument(ctx, EventWatchCollection, watch.ID, watch); err != nil {
+			utils.LogError("Failed to save event watch", err)
+		}
+	}
+
+	return alerts, nil
+}
+
+// eventWatchAlerts compares a fresh provider result with the last known state
+func eventWatchAlerts(watch EventWatch, event Event) []Notification {
+	var notifications []Notification
+	data := map[string]string{
+		"event_id":     watch.EventID,
+		"provider":     watch.Provider,
+		"outbound_url": OutboundPathPrefix + watch.EventID,
+	}
+
+	dropped := watch.LastPrice > 0 && event.Price > 0 && event.Price <= watch.LastPrice*(1-EventPriceDropThreshold)
+	reachedTarget := watch.TargetPrice > 0 && event.Price > 0 && event.Price <= watch.TargetPrice && watch.LastPrice > watch.TargetPrice
+	if dropped || reachedTarget {
+		body := fmt.Sprintf("Tickets for %s dropped from %s to %s.", watch.EventName, utils.FormatCurrency(watch.LastPrice), utils.FormatCurrency(event.Price))
+		if reachedTarget {
+			body += fmt.Sprintf(" That's at or below your target of %s.", utils.FormatCurrency(watch.TargetPrice))
+		}
+		notifications = append(notifications, Notification{
+			UserID: watch.UserID,
+			Type:   NotificationEventPriceDrop,
+			Title:  fmt.Sprintf("Price drop: %s", watch.EventName),
+			Body:   body,
+			Data:   data,
+		})
+	}
+
+	if watch.TicketsAvailable && !event.TicketsAvailable {
+		notifications = append(notifications, Notification{
+			UserID: watch.UserID,
+			Type:   NotificationEventAvailability,
+			Title:  fmt.Sprintf("Tickets unavailable: %s", watch.EventName),
+			Body:   fmt.Sprintf("%s is no longer on sale through %s.", watch.EventName, watch.Provider),
+			Data:   data,
+		})
+	}
+
+	return notifications
+}
+
+// StartEventWatchPoller runs CheckEventWatches in the background at the given interval
+func StartEventWatchPoller(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			ForEachTenant(context.Background(), func(ctx context.Context) {
+				alerts, err := CheckEventWatches(ctx)
+				if err != nil {
+					utils.LogError("Event watch check failed for tenant "+TenantFromContext(ctx).ID, err)
+					return
+				}
+				if alerts > 0 {
+					utils.LogInfo(fmt.Sprintf("Sent %d event alerts for tenant %s", alerts, TenantFromContext(ctx).ID))
+				}
+			})
+		}
+	}()
+}
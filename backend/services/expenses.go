@@ -0,0 +1,400 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joshndala/cantrip/models"
+	"github.com/joshndala/cantrip/utils"
+)
+
+// expensesCollection stores each trip's expense list
+const expensesCollection = "trip_expenses"
+
+// NotificationBudgetOverspend is sent when a trip's spending in a category passes its budget
+const NotificationBudgetOverspend = "budget_overspend"
+
+// MaxExpensesPerTrip bounds the expenses logged for one trip
+const MaxExpensesPerTrip = 500
+
+// Expense categories. The first five match the lines of a trip's CostBreakdown, which is
+// what they're budgeted against; shopping and other have no budget of their own.
+const (
+	ExpenseActivities = "activities"
+	ExpenseMeals      = "meals"
+	ExpenseTransit    = "transit"
+	ExpenseLodging    = "lodging"
+	ExpenseRental     = "rental"
+	ExpenseShopping   = "shopping"
+	ExpenseOther      = "other"
+)
+
+// ExpenseCategories lists every expense category
+var ExpenseCategories = []string{ExpenseActivities, ExpenseMeals, ExpenseTransit, ExpenseLodging, ExpenseRental, ExpenseShopping, ExpenseOther}
+
+// expenseVendorKeywords guess a category from a receipt's vendor when none is given
+var expenseVendorKeywords = map[string][]string{
+	ExpenseMeals:      {"restaurant", "cafe", "café", "coffee", "bistro", "bar", "pub", "grill", "kitchen", "bakery", "diner", "pizza", "sushi", "fish", "brewing", "tim hortons", "starbucks"},
+	ExpenseLodging:    {"hotel", "inn", "motel", "suites", "hostel", "lodge", "resort", "airbnb"},
+	ExpenseTransit:    {"transit", "taxi", "cab", "uber", "lyft", "ferries", "ferry", "via rail", "parking", "metro", "ttc", "stm", "translink", "gas", "petro", "esso", "shell"},
+	ExpenseRental:     {"rent a car", "car rental", "enterprise", "hertz", "avis", "budget car", "national car"},
+	ExpenseActivities: {"museum", "gallery", "tour", "tours", "gardens", "park", "tickets", "admission", "aquarium", "zoo", "theatre", "cinema"},
+}
+
+// ErrInvalidExpense is returned for an expense without an amount or with an unknown
+// category or currency
+var ErrInvalidExpense = errors.New("invalid expense")
+
+// ExpenseInput is what a traveler logs. Fields left empty are filled from the receipt
+// when one is attached.
+type ExpenseInput struct {
+	Amount   float64     `json:"amount" form:"amount"`
+	Currency string      `json:"currency" form:"currency"` // defaults to the trip's currency
+	Category string      `json:"category" form:"category"` // guessed from the vendor when empty
+	Vendor   string      `json:"vendor" form:"vendor"`
+	Note     string      `json:"note" form:"note"`
+	Date     models.Date `json:"date" form:"date"` // defaults to today
+}
+
+// ReceiptUpload is a receipt photo sent with an expense
+type ReceiptUpload struct {
+	Filename string
+	Data     []byte
+}
+
+// Expense is money spent on a trip
+type Expense struct {
+	ID          string       `json:"id"`
+	TripID      string       `json:"trip_id"`
+	Amount      float64      `json:"amount"`
+	Currency    string       `json:"currency"`
+	TripAmount  float64      `json:"trip_amount"` // the amount in the trip's currency, for the budget
+	Category    string       `json:"category"`
+	Vendor      string       `json:"vendor,omitempty"`
+	Note        string       `json:"note,omitempty"`
+	Date        string       `json:"date"`
+	ReceiptID   string       `json:"receipt_id,omitempty"` // the receipt photo's attachment ID
+	Receipt     *ReceiptScan `json:"receipt,omitempty"`    // what OCR read off the receipt
+	ReceiptNote string       `json:"receipt_note,omitempty"`
+	CreatedBy   string       `json:"created_by"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
+
+// TripExpenses is the stored expense list for a trip
+type TripExpenses struct {
+	TripID   string    `json:"trip_id"`
+	Expenses []Expense `json:"expenses"`
+}
+
+// CategorySpend compares a category's spending with its budget
+type CategorySpend struct {
+	Category    string  `json:"category"`
+	Budgeted    float64 `json:"budgeted"`
+	Spent       float64 `json:"spent"`
+	Remaining   float64 `json:"remaining"`
+	PercentUsed float64 `json:"percent_used,omitempty"` // of the budget; omitted for categories without one
+	Overspent   bool    `json:"overspent"`
+}
+
+// BudgetReport compares a trip's logged expenses with its estimated costs
+type BudgetReport struct {
+	Currency   string          `json:"currency"`
+	Budgeted   float64         `json:"budgeted"`
+	Spent      float64         `json:"spent"`
+	Remaining  float64         `json:"remaining"`
+	Overspent  []string        `json:"overspent"` // categories over budget
+	Categories []CategorySpend `json:"categories"`
+}
+
+// loadTripExpenses loads a trip's expense list, empty when it has none
+func loadTripExpenses(ctx context.Context, tripID string) (*TripExpenses, error) {
+	list := &TripExpenses{TripID: tripID, Expenses: []Expense{}}
+	if err := loadDocument(ctx, expensesCollection, tripID, list); err != nil && !errors.Is(err, ErrDocumentNotFound) {
+		return nil, fmt.Errorf("failed to load expenses: %w", err)
+	}
+	return list, nil
+}
+
+// AddExpense logs an expense against a trip. With a receipt photo the photo is stored as
+// a receipt attachment and read with OCR, filling in the amount, vendor, date and currency
+// the traveler left out. The owner is notified when the expense takes a category over
+// budget.
+func AddExpense(ctx context.Context, itinerary *ItineraryResponse, userID string, input ExpenseInput, receipt *ReceiptUpload) (*Expense, *BudgetReport, error) {
+	costs, err := loadCostOfLiving(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load cost-of-living data: %w", err)
+	}
+	tripCurrency := tripCurrency(itinerary, costs)
+
+	expense := Expense{
+		ID:        utils.GenerateID(),
+		TripID:    itinerary.ID,
+		Amount:    input.Amount,
+		Currency:  strings.ToUpper(strings.TrimSpace(input.Currency)),
+		Category:  strings.ToLower(strings.TrimSpace(input.Category)),
+		Vendor:    strings.TrimSpace(input.Vendor),
+		Note:      strings.TrimSpace(input.Note),
+		Date:      input.Date.String(),
+		CreatedBy: userID,
+		CreatedAt: time.Now().UTC(),
+	}
+	if expense.Category != "" && !utils.Contains(ExpenseCategories, expense.Category) {
+		return nil, nil, fmt.Errorf("%w: category must be one of %s", ErrInvalidExpense, strings.Join(ExpenseCategories, ", "))
+	}
+	if expense.Amount < 0 {
+		return nil, nil, fmt.Errorf("%w: amount can't be negative", ErrInvalidExpense)
+	}
+
+	saved := false
+	if receipt != nil {
+		if err := readReceipt(ctx, &expense, receipt); err != nil {
+			return nil, nil, err
+		}
+		// Don't keep the photo of an expense that wasn't logged
+		defer func() {
+			if !saved {
+				DeleteAttachment(ctx, expense.TripID, expense.ReceiptID)
+			}
+		}()
+	}
+
+	if expense.Amount <= 0 {
+		if receipt != nil {
+			return nil, nil, fmt.Errorf("%w: the receipt's total couldn't be read, enter the amount", ErrInvalidExpense)
+		}
+		return nil, nil, fmt.Errorf("%w: amount or a receipt photo is required", ErrInvalidExpense)
+	}
+	if expense.Currency == "" {
+		expense.Currency = tripCurrency
+	}
+	if _, ok := costs.ExchangeRates[expense.Currency]; !ok {
+		return nil, nil, fmt.Errorf("%w: unsupported currency %q", ErrInvalidExpense, expense.Currency)
+	}
+	if expense.Date == "" {
+		expense.Date = TripToday(ctx, itinerary)
+	}
+	if expense.Category == "" {
+		expense.Category = guessExpenseCategory(expense.Vendor)
+	}
+	expense.Amount = roundCost(expense.Amount)
+	expense.TripAmount = roundCost(costs.convert(expense.Amount, expense.Currency, tripCurrency))
+
+	unlock := lockDocument(expensesCollection, itinerary.ID)
+	defer unlock()
+
+	list, err := loadTripExpenses(ctx, itinerary.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(list.Expenses) >= MaxExpensesPerTrip {
+		return nil, nil, fmt.Errorf("%w: a trip can have at most %d expenses", ErrInvalidExpense, MaxExpensesPerTrip)
+	}
+	before, err := buildBudgetReport(ctx, itinerary, list.Expenses)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	list.Expenses = append(list.Expenses, expense)
+	if err := saveDocument(ctx, expensesCollection, itinerary.ID, list); err != nil {
+		return nil, nil, fmt.Errorf("failed to save expenses: %w", err)
+	}
+	saved = true
+
+	report, err := buildBudgetReport(ctx, itinerary, list.Expenses)
+	if err != nil {
+		return nil, nil, err
+	}
+	notifyOverspend(ctx, itinerary, before, report, expense.Category)
+	return &expense, report, nil
+}
+
+// readReceipt stores a receipt photo with the trip and fills the expense's empty fields
+// from it. A receipt that can't be read is kept anyway, with a note saying why.
+func readReceipt(ctx context.Context, expense *Expense, receipt *ReceiptUpload) error {
+	filename := firstNonEmpty(filepath.Base(receipt.Filename), "receipt.jpg")
+	if !utils.IsValidImageExtension(utils.GetFileExtension(filename)) {
+		return fmt.Errorf("%w: the receipt must be a photo", ErrInvalidAttachment)
+	}
+	attachment, err := SaveAttachment(ctx, expense.TripID, expense.CreatedBy, filename, AttachmentReceipt, receipt.Data)
+	if err != nil {
+		return err
+	}
+	expense.ReceiptID = attachment.ID
+	if attachment.Quarantined {
+		expense.ReceiptNote = "The receipt failed the safety scan and wasn't read"
+		return nil
+	}
+
+	scan, err := ScanReceipt(ctx, receipt.Data)
+	if err != nil {
+		utils.LogWarning(fmt.Sprintf("Failed to read receipt %s: %v", attachment.ID, err))
+		expense.ReceiptNote = "The receipt couldn't be read"
+		if errors.Is(err, ErrOCRUnavailable) {
+			expense.ReceiptNote = "Receipt scanning isn't available"
+		}
+		return nil
+	}
+
+	expense.Receipt = scan
+	if expense.Amount == 0 {
+		expense.Amount = scan.Amount
+		// A receipt's currency only applies to the total read from it
+		if expense.Currency == "" {
+			expense.Currency = scan.Currency
+		}
+	}
+	if expense.Vendor == "" {
+		expense.Vendor = scan.Vendor
+	}
+	if expense.Date == "" {
+		expense.Date = scan.Date
+	}
+	return nil
+}
+
+// guessExpenseCategory picks a category from the vendor's name, other when nothing matches
+func guessExpenseCategory(vendor string) string {
+	words := " " + NormalizeCityName(vendor) + " "
+	for _, category := range ExpenseCategories {
+		for _, keyword := range expenseVendorKeywords[category] {
+			if strings.Contains(words, " "+keyword+" ") {
+				return category
+			}
+		}
+	}
+	return ExpenseOther
+}
+
+// tripCurrency is the currency a trip is budgeted in
+func tripCurrency(itinerary *ItineraryResponse, costs *CostOfLiving) string {
+	currency := strings.ToUpper(stringField(itinerary.Itinerary, "currency", DefaultCurrency))
+	if _, ok := costs.ExchangeRates[currency]; !ok {
+		return DefaultCurrency
+	}
+	return currency
+}
+
+// ListExpenses returns a trip's expenses, newest first, with its budget report
+func ListExpenses(ctx context.Context, itinerary *ItineraryResponse) ([]Expense, *BudgetReport, error) {
+	list, err := loadTripExpenses(ctx, itinerary.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	report, err := buildBudgetReport(ctx, itinerary, list.Expenses)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	expenses := list.Expenses
+	sort.SliceStable(expenses, func(i, j int) bool {
+		if expenses[i].Date != expenses[j].Date {
+			return expenses[i].Date > expenses[j].Date
+		}
+		return expenses[i].CreatedAt.After(expenses[j].CreatedAt)
+	})
+	return expenses, report, nil
+}
+
+// DeleteExpense removes an expense and its receipt photo
+func DeleteExpense(ctx context.Context, tripID, id string) (*Expense, error) {
+	unlock := lockDocument(expensesCollection, tripID)
+	defer unlock()
+
+	list, err := loadTripExpenses(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+	for i, expense := range list.Expenses {
+		if expense.ID != id {
+			continue
+		}
+		list.Expenses = append(list.Expenses[:i], list.Expenses[i+1:]...)
+		if err := saveDocument(ctx, expensesCollection, tripID, list); err != nil {
+			return nil, fmt.Errorf("failed to save expenses: %w", err)
+		}
+		if expense.ReceiptID != "" {
+			if _, err := DeleteAttachment(ctx, tripID, expense.ReceiptID); err != nil && !errors.Is(err, ErrDocumentNotFound) {
+				utils.LogWarning(fmt.Sprintf("Failed to delete receipt %s: %v", expense.ReceiptID, err))
+			}
+		}
+		return &expense, nil
+	}
+	return nil, fmt.Errorf("expense %s on trip %s: %w", id, tripID, ErrDocumentNotFound)
+}
+
+// buildBudgetReport totals expenses by category against the trip's estimated costs
+func buildBudgetReport(ctx context.Context, itinerary *ItineraryResponse, expenses []Expense) (*BudgetReport, error) {
+	_, breakdown, err := RecalculateItineraryCosts(ctx, itinerary, CostRecalculation{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to estimate trip costs: %w", err)
+	}
+	budgets := map[string]float64{
+		ExpenseActivities: breakdown.Activities,
+		ExpenseMeals:      breakdown.Meals,
+		ExpenseTransit:    breakdown.Transit,
+		ExpenseLodging:    breakdown.Lodging,
+		ExpenseRental:     breakdown.Rental,
+	}
+	spent := map[string]float64{}
+	for _, expense := range expenses {
+		spent[expense.Category] += expense.TripAmount
+	}
+
+	report := &BudgetReport{Currency: breakdown.Currency, Budgeted: breakdown.Total, Overspent: []string{}}
+	for _, category := range ExpenseCategories {
+		budget, amount := budgets[category], roundCost(spent[category])
+		if budget == 0 && amount == 0 {
+			continue
+		}
+		line := CategorySpend{
+			Category:  category,
+			Budgeted:  budget,
+			Spent:     amount,
+			Remaining: roundCost(budget - amount),
+			Overspent: budget > 0 && amount > budget,
+		}
+		if budget > 0 {
+			line.PercentUsed = math.Round(amount/budget*1000) / 10
+		}
+		if line.Overspent {
+			report.Overspent = append(report.Overspent, category)
+		}
+		report.Spent += amount
+		report.Categories = append(report.Categories, line)
+	}
+	report.Spent = roundCost(report.Spent)
+	report.Remaining = roundCost(report.Budgeted - report.Spent)
+	return report, nil
+}
+
+// notifyOverspend tells the trip owner when an expense took its category over budget
+func notifyOverspend(ctx context.Context, itinerary *ItineraryResponse, before, after *BudgetReport, category string) {
+	if itinerary.OwnerID == "" || utils.Contains(before.Overspent, category) || !utils.Contains(after.Overspent, category) {
+		return
+	}
+	var line CategorySpend
+	for _, c := range after.Categories {
+		if c.Category == category {
+			line = c
+		}
+	}
+
+	city := stringField(itinerary.Itinerary, "city", itinerary.Metadata.City)
+	notification := Notification{
+		UserID: itinerary.OwnerID,
+		Type:   NotificationBudgetOverspend,
+		Title:  fmt.Sprintf("Over budget on %s", category),
+		Body: fmt.Sprintf("You've spent %.2f %s on %s in %s, %.2f over the %.2f budgeted.",
+			line.Spent, after.Currency, category, city, -line.Remaining, line.Budgeted),
+		Data: map[string]string{"trip_id": itinerary.ID, "category": category},
+	}
+	if _, err := Notify(ctx, notification); err != nil {
+		utils.LogError("Failed to send overspend alert", err)
+	}
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joshndala/cantrip/utils"
+)
+
+// EventExperimentExposure records that a visitor was served an experiment's variant
+const EventExperimentExposure = "experiment_exposure"
+
+// VariantControl is the variant every experiment starts from, served to visitors who
+// can't be assigned and whenever an experiment is off
+const VariantControl = "control"
+
+// Experiments and their variants
+const (
+	ExperimentSuggestionRanking = "suggestion_ranking"
+	VariantInterestScorer       = "interest_scorer" // rank suggestions by how many interests they match
+)
+
+// knownVariants lists the variants the code implements for each experiment; experiments.json
+// can only split traffic between them
+var knownVariants = map[string][]string{
+	ExperimentSuggestionRanking: {VariantControl, VariantInterestScorer},
+}
+
+// Experiment splits visitors between variants by weight
+type Experiment struct {
+	ID          string              `json:"id"`
+	Description string              `json:"description,omitempty"`
+	Enabled     bool                `json:"enabled"`
+	Variants    []ExperimentVariant `json:"variants"`
+}
+
+// ExperimentVariant is one arm of an experiment
+type ExperimentVariant struct {
+	Name   string `json:"name"`
+	Weight int    `json:"weight"`
+}
+
+// ExperimentResults compares an experiment's variants by what exposed visitors did next
+type ExperimentResults struct {
+	Experiment string                     `json:"experiment"`
+	Variants   []ExperimentVariantResults `json:"variants"`
+}
+
+// ExperimentVariantResults counts the distinct visitors exposed to a variant and how many
+// of them went on to generate an itinerary or download a PDF
+type ExperimentVariantResults struct {
+	Variant      string  `json:"variant"`
+	Visitors     int     `json:"visitors"`
+	Itineraries  int     `json:"itineraries"`
+	Downloads    int     `json:"downloads"`
+	PlanRate     float64 `json:"plan_rate"`
+	DownloadRate float64 `json:"download_rate"`
+}
+
+// experimentsFile is the layout of experiments.json
+type experimentsFile struct {
+	Experiments []Experiment `json:"experiments"`
+}
+
+type experimentSubjectKey struct{}
+
+var experimentsCache sync.Map // tenant ID -> map[string]*Experiment
+
+// WithExperimentSubject returns a context whose experiment variants are assigned to
+// subject, the visitor's anonymous analytics ID
+func WithExperimentSubject(ctx context.Context, subject string) context.Context {
+	return context.WithValue(ctx, experimentSubjectKey{}, subject)
+}
+
+// ExperimentSubject returns the anonymous ID variants are assigned to, or ""
+func ExperimentSubject(ctx context.Context) string {
+	subject, _ := ctx.Value(experimentSubjectKey{}).(string)
+	return subject
+}
+
+// AssignedVariant returns the variant of an experiment for the visitor ctx acts for. The
+// same visitor always gets the same variant while the weights are unchanged. Visitors
+// without an ID and experiments that are off or unknown get the control.
+func AssignedVariant(ctx context.Context, experimentID string) string {
+	subject := ExperimentSubject(ctx)
+	experiment := getExperiments(ctx)[experimentID]
+	if subject == "" || experiment == nil || !experiment.Enabled {
+		return VariantControl
+	}
+
+	total := 0
+	for _, variant := range experiment.Variants {
+		total += variant.Weight
+	}
+	if total <= 0 {
+		return VariantControl
+	}
+
+	sum := sha256.Sum256([]byte(experimentID + "\n" + subject))
+	bucket := int(binary.BigEndian.Uint64(sum[:8]) % uint64(total))
+	for _, variant := range experiment.Variants {
+		if bucket < variant.Weight {
+			return variant.Name
+		}
+		bucket -= variant.Weight
+	}
+	return VariantControl
+}
+
+// AssignedVariants returns the variant of every enabled experiment for the visitor ctx acts for
+func AssignedVariants(ctx context.Context) map[string]string {
+	if ExperimentSubject(ctx) == "" {
+		return nil
+	}
+	variants := map[string]string{}
+	for id, experiment := range getExperiments(ctx) {
+		if experiment.Enabled {
+			variants[id] = AssignedVariant(ctx, id)
+		}
+	}
+	return variants
+}
+
+// experimentsKey identifies the variants ctx is assigned, for caches of results that
+// depend on them
+func experimentsKey(ctx context.Context) string {
+	variants := AssignedVariants(ctx)
+	pairs := make([]string, 0, len(variants))
+	for id, variant := range variants {
+		if variant != VariantControl {
+			pairs = append(pairs, id+"="+variant)
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// getExperiments loads experiments.json once per tenant; without it every visitor gets the control
+func getExperiments(ctx context.Context) map[string]*Experiment {
+	tenant := TenantFromContext(ctx).ID
+	if cached, ok := experimentsCache.Load(tenant); ok {
+		return cached.(map[string]*Experiment)
+	}
+
+	experiments := map[string]*Experiment{}
+	content, err := ReadDataset(ctx, ExperimentsDataset)
+	if err == nil {
+		var file experimentsFile
+		if err = json.Unmarshal(content, &file); err == nil {
+			for i := range file.Experiments {
+				experiments[file.Experiments[i].ID] = &file.Experiments[i]
+			}
+		}
+	}
+	if err != nil {
+		utils.LogError("Failed to load experiments", err)
+	}
+	cached, _ := experimentsCache.LoadOrStore(tenant, experiments)
+	return cached.(map[string]*Experiment)
+}
+
+// GetExperimentResults joins exposures logged between from and to with the events each
+// exposed visitor logged afterwards. A visitor counts towards the first variant they were
+// exposed to.
+func GetExperimentResults(from, to time.Time) ([]ExperimentResults, error) {
+	sink, ok := GetAnalyticsSink().(*FileAnalyticsSink)
+	if !ok {
+		return nil, ErrAnalyticsStoreUnavailable
+	}
+
+	file, err := os.Open(sink.Path)
+	if os.IsNotExist(err) {
+		return []ExperimentResults{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open analytics log: %w", err)
+	}
+	defer file.Close()
+
+	type exposure struct {
+		variant    string
+		planned    bool
+		downloaded bool
+	}
+	// experiment -> visitor -> exposure; the log is chronological, so later events follow it
+	exposures := map[string]map[string]*exposure{}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event AnalyticsEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil || event.AnonymousID == "" {
+			continue
+		}
+		if (!from.IsZero() && event.Timestamp.Before(from)) || (!to.IsZero() && !event.Timestamp.Before(to)) {
+			continue
+		}
+
+		switch event.Name {
+		case EventExperimentExposure:
+			experiment, _ := event.Properties["experiment"].(string)
+			variant, _ := event.Properties["variant"].(string)
+			if experiment == "" || variant == "" {
+				continue
+			}
+			if exposures[experiment] == nil {
+				exposures[experiment] = map[string]*exposure{}
+			}
+			if _, seen := exposures[experiment][event.AnonymousID]; !seen {
+				exposures[experiment][event.AnonymousID] = &exposure{variant: variant}
+			}
+		case EventItineraryGenerated, EventPDFDownloaded:
+			for _, visitors := range exposures {
+				if exposed := visitors[event.AnonymousID]; exposed != nil {
+					if event.Name == EventItineraryGenerated {
+						exposed.planned = true
+					} else {
+						exposed.downloaded = true
+					}
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read analytics log: %w", err)
+	}
+
+	results := make([]ExperimentResults, 0, len(exposures))
+	for experiment, visitors := range exposures {
+		byVariant := map[string]*ExperimentVariantResults{}
+		for _, exposed := range visitors {
+			variant := byVariant[exposed.variant]
+			if variant == nil {
+				variant = &ExperimentVariantResults{Variant: exposed.variant}
+				byVariant[exposed.variant] = variant
+			}
+			variant.Visitors++
+			if exposed.planned {
+				variant.Itineraries++
+			}
+			if exposed.downloaded {
+				variant.Downloads++
+			}
+		}
+
+		result := ExperimentResults{Experiment: experiment, Variants: []ExperimentVariantResults{}}
+		for _, variant := range byVariant {
+			variant.PlanRate = float64(variant.Itineraries) / float64(variant.Visitors)
+			variant.DownloadRate = float64(variant.Downloads) / float64(variant.Visitors)
+			result.Variants = append(result.Variants, *variant)
+		}
+		sort.Slice(result.Variants, func(i, j int) bool { return result.Variants[i].Variant < result.Variants[j].Variant })
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Experiment < results[j].Experiment })
+	return results, nil
+}
@@ -0,0 +1,52 @@
+package services
+
+import "math"
+
+// Conditions under which Environment Canada reports wind chill and humidex
+const (
+	windChillMaxTemp    = 10.0 // °C
+	windChillMinWindKmh = 5.0
+	humidexMinTemp      = 20.0 // °C
+	humidexMinValue     = 25.0
+)
+
+// FeelsLike returns the perceived temperature in °C: wind chill when it's cold and windy,
+// humidex when it's warm and humid, otherwise the air temperature. windKmh is in km/h.
+func FeelsLike(temperature float64, humidity int, windKmh float64) float64 {
+	if chill, ok := WindChill(temperature, windKmh); ok {
+		return chill
+	}
+	if humidex, ok := Humidex(temperature, humidity); ok {
+		return humidex
+	}
+	return temperature
+}
+
+// WindChill is Environment Canada's wind chill index, reported for temperatures at or
+// below 10°C with wind of at least 5 km/h
+func WindChill(temperature, windKmh float64) (float64, bool) {
+	if temperature > windChillMaxTemp || windKmh < windChillMinWindKmh {
+		return temperature, false
+	}
+	v := math.Pow(windKmh, 0.16)
+	return 13.12 + 0.6215*temperature - 11.37*v + 0.3965*temperature*v, true
+}
+
+// Humidex is Environment Canada's humidex, reported from 20°C once it reaches 25. The
+// vapour pressure comes from relative humidity rather than the dew point.
+func Humidex(temperature float64, humidity int) (float64, bool) {
+	if temperature < humidexMinTemp || humidity <= 0 {
+		return temperature, false
+	}
+	vapourPressure := float64(humidity) / 100 * 6.112 * math.Exp(17.67*temperature/(temperature+243.5))
+	humidex := temperature + 0.5555*(vapourPressure-10)
+	if humidex < humidexMinValue || humidex <= temperature {
+		return temperature, false
+	}
+	return humidex, true
+}
+
+// metersPerSecondToKmh converts OpenWeatherMap's metric wind speed
+func metersPerSecondToKmh(speed float64) float64 {
+	return speed * 3.6
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joshndala/cantrip/models"
+)
+
+// FestivalProvider tags events that come from the curated festival dataset
+const FestivalProvider = "festivals"
+
+// MaxFestivalRangeDays caps how far a festival search can span
+const MaxFestivalRangeDays = 400
+
+// FestivalData represents the structure of festivals.json
+type FestivalData struct {
+	Note      string     `json:"note"`
+	Festivals []Festival `json:"festivals"`
+}
+
+// Festival is a recurring festival or annual event with its typical dates
+type Festival = models.Festival
+
+// FestivalOccurrence is a festival placed on the calendar for a given year
+type FestivalOccurrence = models.FestivalOccurrence
+
+// ErrInvalidFestivalQuery is returned for a missing or malformed festival date range
+var ErrInvalidFestivalQuery = errors.New("invalid festival query")
+
+// loadFestivals loads the festivals dataset
+func loadFestivals(ctx context.Context) (*FestivalData, error) {
+	data, err := ReadDataset(ctx, FestivalsDataset)
+	if err != nil {
+		return nil, err
+	}
+
+	var festivals FestivalData
+	if err := json.Unmarshal(data, &festivals); err != nil {
+		return nil, err
+	}
+	return &festivals, nil
+}
+
+// FindFestivals lists the festivals expected between start and end (YYYY-MM-DD), earliest first.
+// An empty city searches every destination; an empty end searches the start day only.
+func FindFestivals(ctx context.Context, city, start, end string) ([]FestivalOccurrence, error) {
+	if end == "" {
+		end = start
+	}
+	from, err := time.Parse("2006-01-02", start)
+	if err != nil {
+		return nil, fmt.Errorf("%w: start must be YYYY-MM-DD", ErrInvalidFestivalQuery)
+	}
+	to, err := time.Parse("2006-01-02", end)
+	if err != nil {
+		return nil, fmt.Errorf("%w: end must be YYYY-MM-DD", ErrInvalidFestivalQuery)
+	}
+	if to.Before(from) {
+		return nil, fmt.Errorf("%w: end is before start", ErrInvalidFestivalQuery)
+	}
+	if to.Sub(from) > MaxFestivalRangeDays*24*time.Hour {
+		return nil, fmt.Errorf("%w: range must be at most %d days", ErrInvalidFestivalQuery, MaxFestivalRangeDays)
+	}
+
+	data, err := loadFestivals(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load festivals: %w", err)
+	}
+
+	occurrences := []FestivalOccurrence{}
+	for _, festival := range data.Festivals {
+		if city != "" && !strings.EqualFold(festival.City, city) {
+			continue
+		}
+		// A festival that wraps the new year can start in the year before the range
+		for year := from.Year() - 1; year <= to.Year(); year++ {
+			occurrenceStart, occurrenceEnd, ok := festivalDates(festival, year)
+			if !ok || occurrenceEnd.Before(from) || occurrenceStart.After(to) {
+				continue
+			}
+			occurrences = append(occurrences, FestivalOccurrence{
+				Festival:  festival,
+				StartDate: occurrenceStart.Format("2006-01-02"),
+				EndDate:   occurrenceEnd.Format("2006-01-02"),
+				Estimated: true,
+			})
+		}
+	}
+
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		return occurrences[i].StartDate < occurrences[j].StartDate
+	})
+	return occurrences, nil
+}
+
+// festivalDates places a festival's typical dates in a year
+func festivalDates(festival Festival, year int) (time.Time, time.Time, bool) {
+	start, err := time.Parse("2006-01-02", fmt.Sprintf("%04d-%s", year, festival.TypicalStart))
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	end, err := time.Parse("2006-01-02", fmt.Sprintf("%04d-%s", year, festival.TypicalEnd))
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	if end.Before(start) {
+		end = end.AddDate(1, 0, 0)
+	}
+	return start, end, true
+}
+
+// FestivalEvents returns the festivals in a city between start and end as events, so they
+// show up alongside ticketed events before ticket APIs list anything
+func FestivalEvents(ctx context.Context, city, start, end string) []Event {
+	occurrences, err := FindFestivals(ctx, city, start, end)
+	if err != nil {
+		return nil
+	}
+
+	events := make([]Event, 0, len(occurrences))
+	for _, occurrence := range occurrences {
+		events = append(events, Event{
+			ID:          occurrence.ID + "-" + occurrence.StartDate[:4],
+			Name:        occurrence.Name,
+			Description: fmt.Sprintf("%s. Typical dates: %s.", occurrence.Description, occurrence.DateNote),
+			Date:        occurrence.StartDate,
+			EndDate:     occurrence.EndDate,
+			Location:    occurrence.City,
+			PriceRange:  occurrence.PriceRange,
+			Category:    occurrence.Category,
+			Type:        "festival",
+			BookingURL:  occurrence.Website,
+			Provider:    FestivalProvider,
+			Tags:        occurrence.Tags,
+		})
+	}
+	return events
+}
+
+// MergeFestivalEvents adds the city's festivals between start and end to a list of events,
+// skipping festivals the ticket APIs already listed
+func MergeFestivalEvents(ctx context.Context, events []Event, city, start, end string) []Event {
+	listed := make(map[string]bool, len(events))
+	for _, event := range events {
+		listed[strings.ToLower(event.Name)] = true
+	}
+	for _, festival := range FestivalEvents(ctx, city, start, end) {
+		if !listed[strings.ToLower(festival.Name)] {
+			events = append(events, festival)
+		}
+	}
+	return events
+}
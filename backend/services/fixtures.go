@@ -0,0 +1,189 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Fixture modes for UPSTREAM_FIXTURES
+const (
+	FixtureModeReplay = "replay" // serve recorded responses, fail on unknown requests
+	FixtureModeRecord = "record" // call the real API and save every response
+)
+
+// DefaultFixtureDir holds recorded upstream responses
+const DefaultFixtureDir = "testdata/fixtures"
+
+// fixtureSecretParams are stripped from recorded URLs
+var fixtureSecretParams = []string{"apikey", "appid", "api_key", "token"}
+
+// Fixture is a recorded upstream request and response
+type Fixture struct {
+	Request struct {
+		Method string `json:"method"`
+		URL    string `json:"url"`
+	} `json:"request"`
+	Response struct {
+		Status  int               `json:"status"`
+		Headers map[string]string `json:"headers,omitempty"`
+		Body    json.RawMessage   `json:"body"`
+	} `json:"response"`
+}
+
+// FixtureTransport is an http.RoundTripper that replays or records upstream
+// responses so provider parsers can run against captured payloads
+type FixtureTransport struct {
+	Dir  string
+	Mode string
+	// Next performs real requests in record mode
+	Next http.RoundTripper
+
+	mu sync.Mutex
+}
+
+// NewFixtureTransportFromEnv configures a FixtureTransport from
+// UPSTREAM_FIXTURES and UPSTREAM_FIXTURES_DIR, or returns nil if disabled
+func NewFixtureTransportFromEnv() *FixtureTransport {
+	mode := os.Getenv("UPSTREAM_FIXTURES")
+	if mode != FixtureModeReplay && mode != FixtureModeRecord {
+		return nil
+	}
+
+	dir := os.Getenv("UPSTREAM_FIXTURES_DIR")
+	if dir == "" {
+		dir = DefaultFixtureDir
+	}
+
+	return &FixtureTransport{Dir: dir, Mode: mode, Next: http.DefaultTransport}
+}
+
+// RoundTrip serves the request from a fixture or records it
+func (t *FixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	path := t.fixturePath(req)
+
+	if t.Mode == FixtureModeRecord {
+		return t.record(req, path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("no fixture for %s %s (%s): %w", req.Method, redactURL(req.URL), path, err)
+	}
+
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("invalid fixture %s: %w", path, err)
+	}
+
+	return fixture.httpResponse(req), nil
+}
+
+// record performs the real request and saves the response as a fixture
+func (t *FixtureTransport) record(req *http.Request, path string) (*http.Response, error) {
+	next := t.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var fixture Fixture
+	fixture.Request.Method = req.Method
+	fixture.Request.URL = redactURL(req.URL)
+	fixture.Response.Status = resp.StatusCode
+	fixture.Response.Headers = map[string]string{"Content-Type": resp.Header.Get("Content-Type")}
+	fixture.Response.Body = body
+	if !json.Valid(body) {
+		// Keep non-JSON bodies readable as a JSON string
+		fixture.Response.Body, _ = json.Marshal(string(body))
+	}
+
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create fixture directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write fixture: %w", err)
+	}
+
+	return fixture.httpResponse(req), nil
+}
+
+// fixturePath derives a stable file name from the host and the redacted request
+func (t *FixtureTransport) fixturePath(req *http.Request) string {
+	key := req.Method + " " + redactURL(req.URL)
+	sum := sha1.Sum([]byte(key))
+
+	name := strings.Trim(strings.ReplaceAll(req.URL.Path, "/", "_"), "_")
+	return filepath.Join(t.Dir, req.URL.Hostname(), fmt.Sprintf("%s_%s.json", name, hex.EncodeToString(sum[:4])))
+}
+
+// httpResponse builds an http.Response from the recorded fixture
+func (f *Fixture) httpResponse(req *http.Request) *http.Response {
+	body := []byte(f.Response.Body)
+
+	// Bodies recorded as JSON strings are served verbatim
+	var text string
+	if err := json.Unmarshal(body, &text); err == nil {
+		body = []byte(text)
+	}
+
+	header := make(http.Header)
+	for key, value := range f.Response.Headers {
+		header.Set(key, value)
+	}
+
+	status := f.Response.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	return &http.Response{
+		StatusCode:    status,
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+	}
+}
+
+// redactURL removes API keys and sorts query parameters so fixtures match across keys
+func redactURL(u *url.URL) string {
+	query := u.Query()
+	for _, param := range fixtureSecretParams {
+		query.Del(param)
+	}
+
+	redacted := *u
+	redacted.RawQuery = query.Encode() // Encode sorts by key
+	return redacted.String()
+}
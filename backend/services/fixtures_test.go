@@ -0,0 +1,46 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fixtureServer serves a recorded fixture from testdata/fixtures, failing the test when a
+// request doesn't match the recorded method, path and query (less API keys)
+func fixtureServer(t *testing.T, name string) *httptest.Server {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("..", DefaultFixtureDir, name))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		t.Fatalf("parse fixture %s: %v", name, err)
+	}
+	recorded, err := url.Parse(fixture.Request.URL)
+	if err != nil {
+		t.Fatalf("fixture %s URL: %v", name, err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != fixture.Request.Method || r.URL.Path != recorded.Path {
+			t.Errorf("request %s %s, fixture recorded %s %s", r.Method, r.URL.Path, fixture.Request.Method, recorded.Path)
+		}
+		if got, want := redactURL(r.URL), redactURL(&url.URL{Path: recorded.Path, RawQuery: recorded.RawQuery}); got != want {
+			t.Errorf("request %s, fixture recorded %s", got, want)
+		}
+		response := fixture.httpResponse(r)
+		for key, values := range response.Header {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(response.StatusCode)
+		_, _ = w.Write(fixture.Response.Body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
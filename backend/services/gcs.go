@@ -4,8 +4,10 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -21,6 +23,9 @@ type GCSConfig struct {
 	ProjectID       string
 	BucketName      string
 	CredentialsFile string
+	// SigningServiceAccount signs URLs through IAM SignBlob when the credentials have no
+	// private key; the server's identity needs roles/iam.serviceAccountTokenCreator on it
+	SigningServiceAccount string
 }
 
 // GCSClient wraps the Google Cloud Storage client
@@ -29,6 +34,8 @@ type GCSClient struct {
 	bucket     *storage.BucketHandle
 	projectID  string
 	bucketName string
+	// signingAccount is the GoogleAccessID for signed URLs; empty detects it from the credentials
+	signingAccount string
 }
 
 // FileInfo represents information about a stored file
@@ -63,10 +70,11 @@ func NewGCSClient(config GCSConfig) (*GCSClient, error) {
 	bucket := client.Bucket(config.BucketName)
 
 	return &GCSClient{
-		client:     client,
-		bucket:     bucket,
-		projectID:  config.ProjectID,
-		bucketName: config.BucketName,
+		client:         client,
+		bucket:         bucket,
+		projectID:      config.ProjectID,
+		bucketName:     config.BucketName,
+		signingAccount: config.SigningServiceAccount,
 	}, nil
 }
 
@@ -123,6 +131,16 @@ func (g *GCSClient) DownloadFile(ctx context.Context, objectName string) ([]byte
 	return data, nil
 }
 
+// NewRangeReader streams length bytes of an object starting at offset; a negative length
+// reads to the end of the object
+func (g *GCSClient) NewRangeReader(ctx context.Context, objectName string, offset, length int64) (io.ReadCloser, error) {
+	reader, err := g.bucket.Object(objectName).NewRangeReader(ctx, offset, length)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCS reader: %w", err)
+	}
+	return reader, nil
+}
+
 // DownloadFileToPath downloads a file from GCS to a local path
 func (g *GCSClient) DownloadFileToPath(ctx context.Context, objectName, localPath string) error {
 	data, err := g.DownloadFile(ctx, objectName)
@@ -207,7 +225,7 @@ func (g *GCSClient) DeleteFile(ctx context.Context, objectName string) error {
 func (g *GCSClient) FileExists(ctx context.Context, objectName string) (bool, error) {
 	obj := g.bucket.Object(objectName)
 	_, err := obj.Attrs(ctx)
-	if err == storage.ErrObjectNotExist {
+	if errors.Is(err, storage.ErrObjectNotExist) {
 		return false, nil
 	}
 	if err != nil {
@@ -217,20 +235,37 @@ func (g *GCSClient) FileExists(ctx context.Context, objectName string) (bool, er
 	return true, nil
 }
 
+// MaxSignedURLExpiry is the longest lifetime V4 signing allows
+const MaxSignedURLExpiry = 7 * 24 * time.Hour
+
 // GenerateSignedURL generates a signed URL for temporary access to a file
 func (g *GCSClient) GenerateSignedURL(ctx context.Context, objectName string, expiration time.Duration) (string, error) {
+	return g.GenerateSignedURLWithParams(ctx, objectName, expiration, nil)
+}
+
+// GenerateSignedURLWithParams generates a V4 signed GET URL with extra signed query
+// parameters, such as response-content-disposition. The URL is signed with the private
+// key in the client's credentials when it has one, and otherwise through IAM SignBlob
+// as GCS_SIGNING_SERVICE_ACCOUNT or the service account the server runs as.
+func (g *GCSClient) GenerateSignedURLWithParams(ctx context.Context, objectName string, expiration time.Duration, params url.Values) (string, error) {
+	if expiration <= 0 || expiration > MaxSignedURLExpiry {
+		return "", fmt.Errorf("signed URL expiry must be between 0 and %s", MaxSignedURLExpiry)
+	}
+
 	opts := &storage.SignedURLOptions{
-		Scheme:  storage.SigningSchemeV4,
-		Method:  "GET",
-		Expires: time.Now().Add(expiration),
+		Scheme:          storage.SigningSchemeV4,
+		Method:          "GET",
+		Expires:         time.Now().Add(expiration),
+		GoogleAccessID:  g.signingAccount,
+		QueryParameters: params,
 	}
 
-	url, err := storage.SignedURL(g.bucketName, objectName, opts)
+	signedURL, err := g.bucket.SignedURL(objectName, opts)
 	if err != nil {
 		return "", fmt.Errorf("failed to generate signed URL: %w", err)
 	}
 
-	return url, nil
+	return signedURL, nil
 }
 
 // UploadJSON uploads JSON data to Google Cloud Storage
@@ -279,12 +314,20 @@ func getContentTypeFromExtension(ext string) string {
 		return "image/jpeg"
 	case ".gif":
 		return "image/gif"
+	case ".bmp":
+		return "image/bmp"
+	case ".webp":
+		return "image/webp"
 	case ".pdf":
 		return "application/pdf"
 	case ".txt":
 		return "text/plain"
 	case ".csv":
 		return "text/csv"
+	case ".mp3":
+		return "audio/mpeg"
+	case ".wav":
+		return "audio/wav"
 	case ".xml":
 		return "application/xml"
 	default:
@@ -298,9 +341,10 @@ var gcsClient *GCSClient
 // InitializeGCS initializes the global GCS client
 func InitializeGCS() error {
 	config := GCSConfig{
-		ProjectID:       os.Getenv("GCS_PROJECT_ID"),
-		BucketName:      os.Getenv("GCS_BUCKET_NAME"),
-		CredentialsFile: os.Getenv("GCS_CREDENTIALS_FILE"),
+		ProjectID:             os.Getenv("GCS_PROJECT_ID"),
+		BucketName:            os.Getenv("GCS_BUCKET_NAME"),
+		CredentialsFile:       os.Getenv("GCS_CREDENTIALS_FILE"),
+		SigningServiceAccount: os.Getenv("GCS_SIGNING_SERVICE_ACCOUNT"),
 	}
 
 	if config.ProjectID == "" || config.BucketName == "" {
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/joshndala/cantrip/utils"
+)
+
+// Lifecycle defaults; PDFs outlive their one-month download expiry by a day
+const (
+	DefaultPDFRetentionDays        = 31
+	DefaultNoncurrentRetentionDays = 7
+)
+
+// GCSLifecycleConfig is the bucket lifecycle and retention the server manages at startup
+type GCSLifecycleConfig struct {
+	PDFRetentionDays        int64         // delete pdfs/ objects after this many days; 0 keeps them
+	NoncurrentRetentionDays int64         // delete overwritten object versions after this many days; 0 keeps them
+	RetentionPeriod         time.Duration // minimum object age before deletion; 0 leaves the bucket's policy alone
+}
+
+// GCSLifecycleFromEnv reads GCS_PDF_RETENTION_DAYS, GCS_NONCURRENT_RETENTION_DAYS and
+// GCS_RETENTION_POLICY_DAYS. A retention policy also blocks DELETE requests for newer
+// objects, so it is off unless set.
+func GCSLifecycleFromEnv() GCSLifecycleConfig {
+	days := func(key string, fallback int64) int64 {
+		if value := os.Getenv(key); value != "" {
+			if n, err := strconv.ParseInt(value, 10, 64); err == nil && n >= 0 {
+				return n
+			}
+			utils.LogWarning(fmt.Sprintf("Ignoring %s=%q, expected a number of days", key, value))
+		}
+		return fallback
+	}
+
+	return GCSLifecycleConfig{
+		PDFRetentionDays:        days("GCS_PDF_RETENTION_DAYS", DefaultPDFRetentionDays),
+		NoncurrentRetentionDays: days("GCS_NONCURRENT_RETENTION_DAYS", DefaultNoncurrentRetentionDays),
+		RetentionPeriod:         time.Duration(days("GCS_RETENTION_POLICY_DAYS", 0)) * 24 * time.Hour,
+	}
+}
+
+// rules builds the lifecycle rules for the configuration. Incomplete multipart uploads
+// are always aborted after a day.
+func (c GCSLifecycleConfig) rules() []storage.LifecycleRule {
+	rules := []storage.LifecycleRule{{
+		Action:    storage.LifecycleAction{Type: storage.AbortIncompleteMPUAction},
+		Condition: storage.LifecycleCondition{AgeInDays: 1},
+	}}
+	if c.PDFRetentionDays > 0 {
+		// Every tenant's PDFs, each under its storage prefix
+		var prefixes []string
+		for _, tenant := range Tenants() {
+			prefixes = append(prefixes, tenantObjectName(WithTenant(context.Background(), tenant), "pdfs/"))
+		}
+		rules = append(rules, storage.LifecycleRule{
+			Action:    storage.LifecycleAction{Type: storage.DeleteAction},
+			Condition: storage.LifecycleCondition{AgeInDays: c.PDFRetentionDays, MatchesPrefix: prefixes},
+		})
+	}
+	if c.NoncurrentRetentionDays > 0 {
+		rules = append(rules, storage.LifecycleRule{
+			Action: storage.LifecycleAction{Type: storage.DeleteAction},
+			Condition: storage.LifecycleCondition{
+				DaysSinceNoncurrentTime: c.NoncurrentRetentionDays,
+				Liveness:                storage.Archived,
+			},
+		})
+	}
+	return rules
+}
+
+// ApplyGCSLifecycle replaces the bucket's lifecycle rules, and its retention policy when
+// one is configured, updating the bucket only when they differ
+func ApplyGCSLifecycle(ctx context.Context, config GCSLifecycleConfig) error {
+	client := GetGCSClient()
+	if client == nil {
+		return fmt.Errorf("GCS is not configured")
+	}
+
+	attrs, err := client.bucket.Attrs(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read bucket attributes: %w", err)
+	}
+
+	var update storage.BucketAttrsToUpdate
+	changed := false
+
+	lifecycle := storage.Lifecycle{Rules: config.rules()}
+	if !reflect.DeepEqual(attrs.Lifecycle, lifecycle) {
+		update.Lifecycle = &lifecycle
+		changed = true
+	}
+	if config.RetentionPeriod > 0 && (attrs.RetentionPolicy == nil || attrs.RetentionPolicy.RetentionPeriod != config.RetentionPeriod) {
+		if attrs.RetentionPolicy != nil && attrs.RetentionPolicy.IsLocked {
+			return fmt.Errorf("bucket %s has a locked retention policy", client.bucketName)
+		}
+		update.RetentionPolicy = &storage.RetentionPolicy{RetentionPeriod: config.RetentionPeriod}
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+	if _, err := client.bucket.Update(ctx, update); err != nil {
+		return fmt.Errorf("failed to update bucket lifecycle: %w", err)
+	}
+	utils.LogInfo(fmt.Sprintf("GCS lifecycle updated on %s: %d rules", client.bucketName, len(lifecycle.Rules)))
+	return nil
+}
@@ -0,0 +1,602 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/option"
+)
+
+// fakeGCS is an in-memory stand-in for the GCS JSON API, enough of it for bucket
+// attributes, uploads, reads, listing and deletes
+type fakeGCS struct {
+	t      *testing.T
+	bucket string
+
+	mu      sync.Mutex
+	attrs   map[string]interface{} // bucket resource as the API returns it
+	objects map[string]fakeObject
+	patches []map[string]interface{} // bodies of bucket PATCH requests, in order
+}
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+	created     time.Time
+}
+
+// newFakeGCS starts a fake GCS server and returns a client for its bucket, closed at the
+// end of the test
+func newFakeGCS(t *testing.T, bucket string) (*fakeGCS, *GCSClient) {
+	t.Helper()
+	fake := &fakeGCS{
+		t:       t,
+		bucket:  bucket,
+		attrs:   map[string]interface{}{"kind": "storage#bucket", "name": bucket, "id": bucket},
+		objects: map[string]fakeObject{},
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	t.Setenv("STORAGE_EMULATOR_HOST", server.URL)
+	client, err := storage.NewClient(context.Background())
+	if err != nil {
+		t.Fatalf("storage client: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return fake, &GCSClient{client: client, bucket: client.Bucket(bucket), bucketName: bucket}
+}
+
+// useGCSClient makes client the global GCS client for the rest of the test
+func useGCSClient(t *testing.T, client *GCSClient) {
+	t.Helper()
+	previous := gcsClient
+	gcsClient = client
+	t.Cleanup(func() { gcsClient = previous })
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucketPath := "/storage/v1/b/" + f.bucket
+	switch {
+	case r.URL.Path == bucketPath && r.Method == http.MethodGet:
+		writeJSON(w, f.attrs)
+	case r.URL.Path == bucketPath && r.Method == http.MethodPatch:
+		var patch map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			f.t.Errorf("bucket patch body: %v", err)
+		}
+		f.patches = append(f.patches, patch)
+		for key, value := range patch {
+			f.attrs[key] = value
+		}
+		if policy, ok := patch["retentionPolicy"].(map[string]interface{}); ok {
+			policy["effectiveTime"] = time.Now().UTC().Format(time.RFC3339)
+		}
+		writeJSON(w, f.attrs)
+	case r.URL.Path == "/upload"+bucketPath+"/o" && r.Method == http.MethodPost:
+		f.upload(w, r)
+	case r.URL.Path == bucketPath+"/o" && r.Method == http.MethodGet:
+		f.list(w, r.URL.Query().Get("prefix"))
+	case strings.HasPrefix(r.URL.Path, bucketPath+"/o/"):
+		name, _ := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), bucketPath+"/o/"))
+		f.object(w, r, name)
+	case strings.HasPrefix(r.URL.Path, "/"+f.bucket+"/") && r.Method == http.MethodGet:
+		f.read(w, strings.TrimPrefix(r.URL.Path, "/"+f.bucket+"/"))
+	default:
+		f.t.Errorf("unexpected GCS request %s %s", r.Method, r.URL)
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
+
+// upload stores a multipart upload: the object's metadata, then its content
+func (f *fakeGCS) upload(w http.ResponseWriter, r *http.Request) {
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		f.t.Errorf("upload content type: %v", err)
+		return
+	}
+	parts := multipart.NewReader(r.Body, params["boundary"])
+	metadataPart, err := parts.NextPart()
+	if err != nil {
+		f.t.Errorf("upload metadata part: %v", err)
+		return
+	}
+	var metadata struct {
+		Name        string `json:"name"`
+		ContentType string `json:"contentType"`
+	}
+	if err := json.NewDecoder(metadataPart).Decode(&metadata); err != nil {
+		f.t.Errorf("upload metadata: %v", err)
+		return
+	}
+	mediaPart, err := parts.NextPart()
+	if err != nil {
+		f.t.Errorf("upload media part: %v", err)
+		return
+	}
+	data, _ := io.ReadAll(mediaPart)
+
+	object := fakeObject{data: data, contentType: metadata.ContentType, created: time.Now().UTC()}
+	f.objects[metadata.Name] = object
+	writeJSON(w, f.resource(metadata.Name, object))
+}
+
+func (f *fakeGCS) list(w http.ResponseWriter, prefix string) {
+	var names []string
+	for name := range f.objects {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	items := []map[string]interface{}{}
+	for _, name := range names {
+		items = append(items, f.resource(name, f.objects[name]))
+	}
+	writeJSON(w, map[string]interface{}{"kind": "storage#objects", "items": items})
+}
+
+func (f *fakeGCS) object(w http.ResponseWriter, r *http.Request, name string) {
+	object, ok := f.objects[name]
+	if !ok {
+		http.Error(w, `{"error":{"code":404,"message":"No such object"}}`, http.StatusNotFound)
+		return
+	}
+	switch {
+	case r.Method == http.MethodDelete:
+		delete(f.objects, name)
+		w.WriteHeader(http.StatusNoContent)
+	case r.URL.Query().Get("alt") == "media":
+		f.read(w, name)
+	default:
+		writeJSON(w, f.resource(name, object))
+	}
+}
+
+func (f *fakeGCS) read(w http.ResponseWriter, name string) {
+	object, ok := f.objects[name]
+	if !ok {
+		http.Error(w, "NoSuchKey", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", object.contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(object.data)))
+	w.Write(object.data)
+}
+
+func (f *fakeGCS) resource(name string, object fakeObject) map[string]interface{} {
+	return map[string]interface{}{
+		"kind":        "storage#object",
+		"bucket":      f.bucket,
+		"name":        name,
+		"size":        strconv.Itoa(len(object.data)),
+		"contentType": object.contentType,
+		"timeCreated": object.created.Format(time.RFC3339Nano),
+		"updated":     object.created.Format(time.RFC3339Nano),
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func TestGCSClientListsUploadedObjects(t *testing.T) {
+	_, client := newFakeGCS(t, "cantrip-test")
+	ctx := context.Background()
+	store := tenantObjectStore{client}
+	other := WithTenant(ctx, &Tenant{ID: "acme", StoragePrefix: "tenants/acme"})
+
+	uploads := []struct {
+		ctx  context.Context
+		name string
+		data string
+	}{
+		{ctx, "pdfs/itinerary_a.pdf", "%PDF-a"},
+		{ctx, "pdfs/packing_b.pdf", "%PDF-bb"},
+		{ctx, "itineraries/a.json", "{}"},
+		{other, "pdfs/itinerary_c.pdf", "%PDF-ccc"},
+	}
+	for _, upload := range uploads {
+		if err := store.UploadFile(upload.ctx, upload.name, []byte(upload.data), "application/pdf"); err != nil {
+			t.Fatalf("upload %s: %v", upload.name, err)
+		}
+	}
+
+	files, err := store.ListFiles(ctx, "pdfs/")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(files) != 2 || files[0].Name != "pdfs/itinerary_a.pdf" || files[1].Name != "pdfs/packing_b.pdf" {
+		t.Fatalf("default tenant pdfs/ = %+v, want its two PDFs", files)
+	}
+	if files[1].Size != 7 || files[1].ContentType != "application/pdf" || files[1].Bucket != "cantrip-test" {
+		t.Errorf("listed %+v, want size 7, application/pdf, bucket cantrip-test", files[1])
+	}
+
+	// Another tenant's listing is its own prefix, named as the tenant sees it
+	files, err = store.ListFiles(other, "pdfs/")
+	if err != nil {
+		t.Fatalf("list tenant: %v", err)
+	}
+	if len(files) != 1 || files[0].Name != "pdfs/itinerary_c.pdf" {
+		t.Fatalf("acme pdfs/ = %+v, want pdfs/itinerary_c.pdf", files)
+	}
+
+	data, err := store.DownloadFile(other, "pdfs/itinerary_c.pdf")
+	if err != nil || string(data) != "%PDF-ccc" {
+		t.Errorf("download = %q, %v", data, err)
+	}
+	if err := store.DeleteFile(ctx, "pdfs/packing_b.pdf"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if exists, err := store.FileExists(ctx, "pdfs/packing_b.pdf"); err != nil || exists {
+		t.Errorf("deleted object exists = %v, %v", exists, err)
+	}
+	if files, _ := store.ListFiles(ctx, "pdfs/"); len(files) != 1 {
+		t.Errorf("after delete pdfs/ = %+v, want one PDF", files)
+	}
+}
+
+func TestApplyGCSLifecycle(t *testing.T) {
+	fake, client := newFakeGCS(t, "cantrip-test")
+	useGCSClient(t, client)
+	ctx := context.Background()
+	config := GCSLifecycleConfig{PDFRetentionDays: DefaultPDFRetentionDays, NoncurrentRetentionDays: DefaultNoncurrentRetentionDays}
+
+	if err := ApplyGCSLifecycle(ctx, config); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if len(fake.patches) != 1 {
+		t.Fatalf("%d bucket updates, want 1", len(fake.patches))
+	}
+	attrs, err := client.bucket.Attrs(ctx)
+	if err != nil {
+		t.Fatalf("attrs: %v", err)
+	}
+	rules := attrs.Lifecycle.Rules
+	if len(rules) != 3 {
+		t.Fatalf("rules = %+v, want abort, PDF delete and noncurrent delete", rules)
+	}
+	if rules[0].Action.Type != storage.AbortIncompleteMPUAction || rules[0].Condition.AgeInDays != 1 {
+		t.Errorf("rule 0 = %+v, want abort incomplete uploads after a day", rules[0])
+	}
+	if rules[1].Action.Type != storage.DeleteAction || rules[1].Condition.AgeInDays != DefaultPDFRetentionDays {
+		t.Errorf("rule 1 = %+v, want delete after %d days", rules[1], DefaultPDFRetentionDays)
+	}
+	if prefixes := rules[1].Condition.MatchesPrefix; len(prefixes) != len(Tenants()) || prefixes[0] != "pdfs/" {
+		t.Errorf("PDF rule prefixes = %v, want each tenant's pdfs/", prefixes)
+	}
+	if rules[2].Condition.DaysSinceNoncurrentTime != DefaultNoncurrentRetentionDays || rules[2].Condition.Liveness != storage.Archived {
+		t.Errorf("rule 2 = %+v, want noncurrent versions deleted after %d days", rules[2], DefaultNoncurrentRetentionDays)
+	}
+
+	// Rules the bucket already has are left alone
+	if err := ApplyGCSLifecycle(ctx, config); err != nil {
+		t.Fatalf("reapply: %v", err)
+	}
+	if len(fake.patches) != 1 {
+		t.Errorf("unchanged rules updated the bucket again")
+	}
+
+	config.PDFRetentionDays = 10
+	if err := ApplyGCSLifecycle(ctx, config); err != nil {
+		t.Fatalf("apply changed config: %v", err)
+	}
+	if len(fake.patches) != 2 {
+		t.Errorf("changed rules made %d bucket updates, want 2", len(fake.patches))
+	}
+}
+
+func TestApplyGCSLifecycleRetentionPolicy(t *testing.T) {
+	ctx := context.Background()
+	config := GCSLifecycleConfig{PDFRetentionDays: DefaultPDFRetentionDays, RetentionPeriod: 3 * 24 * time.Hour}
+
+	t.Run("sets the policy", func(t *testing.T) {
+		fake, client := newFakeGCS(t, "cantrip-test")
+		useGCSClient(t, client)
+		if err := ApplyGCSLifecycle(ctx, config); err != nil {
+			t.Fatalf("apply: %v", err)
+		}
+		attrs, err := client.bucket.Attrs(ctx)
+		if err != nil {
+			t.Fatalf("attrs: %v", err)
+		}
+		if attrs.RetentionPolicy == nil || attrs.RetentionPolicy.RetentionPeriod != config.RetentionPeriod {
+			t.Errorf("retention policy = %+v, want %s", attrs.RetentionPolicy, config.RetentionPeriod)
+		}
+		if err := ApplyGCSLifecycle(ctx, config); err != nil || len(fake.patches) != 1 {
+			t.Errorf("reapply made %d bucket updates (%v), want 1", len(fake.patches), err)
+		}
+	})
+
+	t.Run("locked policy", func(t *testing.T) {
+		fake, client := newFakeGCS(t, "cantrip-test")
+		useGCSClient(t, client)
+		fake.attrs["retentionPolicy"] = map[string]interface{}{"retentionPeriod": "86400", "isLocked": true, "effectiveTime": "2026-01-01T00:00:00Z"}
+		err := ApplyGCSLifecycle(ctx, config)
+		if err == nil || !strings.Contains(err.Error(), "locked retention policy") {
+			t.Fatalf("apply over a locked policy = %v, want locked retention policy error", err)
+		}
+		if len(fake.patches) != 0 {
+			t.Errorf("bucket updated despite the locked policy")
+		}
+	})
+}
+
+func TestGCSLifecycleOutlivesPDFExpiry(t *testing.T) {
+	// storePDF expires downloads a month after creation; the delete rule mustn't remove
+	// the object first, whichever month it's created in
+	for _, created := range []time.Time{
+		time.Date(2026, time.January, 31, 12, 0, 0, 0, time.UTC),
+		time.Date(2026, time.July, 15, 12, 0, 0, 0, time.UTC),
+		time.Date(2026, time.December, 31, 23, 0, 0, 0, time.UTC),
+	} {
+		expires := created.AddDate(0, 1, 0)
+		deleted := created.AddDate(0, 0, DefaultPDFRetentionDays)
+		if deleted.Before(expires) {
+			t.Errorf("PDF created %s is deleted %s, before it expires %s", created.Format("Jan 2"), deleted.Format("Jan 2"), expires.Format("Jan 2"))
+		}
+	}
+
+	t.Setenv("GCS_PDF_RETENTION_DAYS", "45")
+	t.Setenv("GCS_NONCURRENT_RETENTION_DAYS", "soon")
+	t.Setenv("GCS_RETENTION_POLICY_DAYS", "2")
+	config := GCSLifecycleFromEnv()
+	if config.PDFRetentionDays != 45 || config.NoncurrentRetentionDays != DefaultNoncurrentRetentionDays || config.RetentionPeriod != 48*time.Hour {
+		t.Errorf("config from env = %+v, want 45 days, default noncurrent, 48h policy", config)
+	}
+}
+
+func TestFindPDFsListsIndexedVersions(t *testing.T) {
+	t.Chdir(t.TempDir())
+	ctx := context.Background()
+	if err := os.MkdirAll(pdfStorageDir(ctx), 0755); err != nil {
+		t.Fatal(err)
+	}
+	created := time.Date(2026, time.October, 1, 9, 0, 0, 0, time.UTC)
+
+	pdfs := []struct {
+		source PDFSource
+		id     string
+		age    time.Duration
+	}{
+		{PDFSource{Type: "itinerary", ID: "trip-1", Version: 1}, "v1", 0},
+		{PDFSource{Type: "itinerary", ID: "trip-1", Version: 2}, "v2", time.Hour},
+		{PDFSource{Type: "itinerary", ID: "trip-1", Version: 2}, "v2-print", 2 * time.Hour},
+		{PDFSource{Type: "itinerary", ID: "trip-10", Version: 1}, "other", 3 * time.Hour},
+	}
+	for _, pdf := range pdfs {
+		metadata := PDFMetadata{ID: pdf.id, Type: pdf.source.Type, SourceID: pdf.source.ID, SourceVersion: pdf.source.Version, CreatedAt: created.Add(pdf.age)}
+		if err := savePDFMetadata(ctx, metadata); err != nil {
+			t.Fatalf("save metadata: %v", err)
+		}
+		if err := indexPDF(ctx, pdf.source, pdf.id); err != nil {
+			t.Fatalf("index: %v", err)
+		}
+	}
+	// Indexing the same PDF again doesn't list it twice
+	if err := indexPDF(ctx, pdfs[0].source, pdfs[0].id); err != nil {
+		t.Fatalf("reindex: %v", err)
+	}
+
+	ids := func(version int) []string {
+		t.Helper()
+		found, err := FindPDFs(ctx, "itinerary", "trip-1", version)
+		if err != nil {
+			t.Fatalf("find: %v", err)
+		}
+		var ids []string
+		for _, pdf := range found {
+			ids = append(ids, pdf.ID)
+		}
+		return ids
+	}
+
+	tests := []struct {
+		version int
+		want    string
+	}{
+		{version: -1, want: "v2-print,v2,v1"},
+		{version: 2, want: "v2-print,v2"},
+		{version: 1, want: "v1"},
+		{version: 3, want: ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(ids(tt.version), ","); got != tt.want {
+			t.Errorf("FindPDFs version %d = %q, want %q", tt.version, got, tt.want)
+		}
+	}
+
+	if err := unindexPDF(ctx, &PDFMetadata{ID: "v2", Type: "itinerary", SourceID: "trip-1", SourceVersion: 2}); err != nil {
+		t.Fatalf("unindex: %v", err)
+	}
+	if got := strings.Join(ids(-1), ","); got != "v2-print,v1" {
+		t.Errorf("after unindex = %q, want v2-print,v1", got)
+	}
+}
+
+// stubSigner answers IAM SignBlob requests by signing the payload with its own key, and
+// fails any other request so signing never reaches the network
+type stubSigner struct {
+	key      *rsa.PrivateKey
+	fail     bool
+	accounts []string // service accounts SignBlob was called as
+	payloads [][]byte
+}
+
+func (s *stubSigner) RoundTrip(r *http.Request) (*http.Response, error) {
+	respond := func(status int, body string) (*http.Response, error) {
+		return &http.Response{StatusCode: status, Header: http.Header{"Content-Type": {"application/json"}}, Body: io.NopCloser(strings.NewReader(body)), Request: r}, nil
+	}
+	if r.URL.Host != "iamcredentials.googleapis.com" || !strings.HasSuffix(r.URL.Path, ":signBlob") {
+		return respond(http.StatusForbidden, `{"error":{"code":403,"message":"unexpected request"}}`)
+	}
+	if s.fail {
+		return respond(http.StatusForbidden, `{"error":{"code":403,"message":"iam.serviceAccounts.signBlob denied"}}`)
+	}
+	var request struct {
+		Payload string `json:"payload"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return nil, err
+	}
+	payload, err := base64.StdEncoding.DecodeString(request.Payload)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v1/projects/-/serviceAccounts/"), ":signBlob")
+	account, _ := url.PathUnescape(name)
+	s.accounts = append(s.accounts, account)
+	s.payloads = append(s.payloads, payload)
+
+	digest := sha256.Sum256(payload)
+	signature, err := rsa.SignPKCS1v15(nil, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	return respond(http.StatusOK, fmt.Sprintf(`{"keyId":"stub","signedBlob":%q}`, base64.StdEncoding.EncodeToString(signature)))
+}
+
+// serviceAccountJSON is credentials for email holding key, as a key file would have them
+func serviceAccountJSON(t *testing.T, email string, key *rsa.PrivateKey) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     "cantrip-test",
+		"private_key_id": "test",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"client_email":   email,
+		"client_id":      "1",
+		"token_uri":      "https://oauth2.googleapis.com/token",
+	})
+	return data
+}
+
+func TestGenerateSignedURLWithParams(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const keyAccount = "cantrip@cantrip-test.iam.gserviceaccount.com"
+	const signingAccount = "pdf-signer@cantrip-test.iam.gserviceaccount.com"
+	params := url.Values{"response-content-disposition": {"attachment; filename=trip.pdf"}}
+
+	tests := []struct {
+		name           string
+		credentials    bool // a key file with a private key
+		signingAccount string
+		signerFails    bool
+		expiration     time.Duration
+		wantAccount    string // in X-Goog-Credential
+		wantSignBlob   bool
+		wantErr        string
+	}{
+		{name: "private key", credentials: true, expiration: time.Hour, wantAccount: keyAccount},
+		{name: "IAM SignBlob", signingAccount: signingAccount, expiration: time.Hour, wantAccount: signingAccount, wantSignBlob: true},
+		{name: "IAM SignBlob at the longest expiry", signingAccount: signingAccount, expiration: MaxSignedURLExpiry, wantAccount: signingAccount, wantSignBlob: true},
+		{name: "SignBlob denied", signingAccount: signingAccount, signerFails: true, expiration: time.Hour, wantErr: "signBlob denied"},
+		{name: "no expiry", credentials: true, expiration: 0, wantErr: "expiry must be between"},
+		{name: "past the V4 limit", signingAccount: signingAccount, expiration: MaxSignedURLExpiry + time.Second, wantErr: "expiry must be between"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer := &stubSigner{key: key, fail: tt.signerFails}
+			opts := []option.ClientOption{option.WithHTTPClient(&http.Client{Transport: signer})}
+			if tt.credentials {
+				opts = append(opts, option.WithCredentialsJSON(serviceAccountJSON(t, keyAccount, key)))
+			}
+			client, err := storage.NewClient(context.Background(), opts...)
+			if err != nil {
+				t.Fatalf("storage client: %v", err)
+			}
+			defer client.Close()
+			gcs := &GCSClient{client: client, bucket: client.Bucket("cantrip-test"), bucketName: "cantrip-test", signingAccount: tt.signingAccount}
+
+			signedURL, err := gcs.GenerateSignedURLWithParams(context.Background(), "pdfs/trip.pdf", tt.expiration, params)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("sign: %v", err)
+			}
+
+			u, err := url.Parse(signedURL)
+			if err != nil {
+				t.Fatalf("signed URL %q: %v", signedURL, err)
+			}
+			query := u.Query()
+			if u.Host != "storage.googleapis.com" || u.Path != "/cantrip-test/pdfs/trip.pdf" {
+				t.Errorf("signed URL %s%s, want storage.googleapis.com/cantrip-test/pdfs/trip.pdf", u.Host, u.Path)
+			}
+			if got := query.Get("X-Goog-Algorithm"); got != "GOOG4-RSA-SHA256" {
+				t.Errorf("algorithm %q, want V4", got)
+			}
+			if got := query.Get("X-Goog-Credential"); !strings.HasPrefix(got, tt.wantAccount+"/") {
+				t.Errorf("credential %q, want %s", got, tt.wantAccount)
+			}
+			// Counted from when the URL is signed, so a second may have gone by
+			if got, _ := strconv.Atoi(query.Get("X-Goog-Expires")); got < int(tt.expiration.Seconds())-1 || got > int(tt.expiration.Seconds()) {
+				t.Errorf("expires %q, want %s", query.Get("X-Goog-Expires"), tt.expiration)
+			}
+			if got := query.Get("response-content-disposition"); got != params.Get("response-content-disposition") {
+				t.Errorf("content disposition %q, want it signed into the URL", got)
+			}
+
+			signature, err := hex.DecodeString(query.Get("X-Goog-Signature"))
+			if err != nil || len(signature) != key.Size() {
+				t.Fatalf("signature %q isn't an RSA-2048 signature", query.Get("X-Goog-Signature"))
+			}
+			if !tt.wantSignBlob {
+				if len(signer.accounts) != 0 {
+					t.Errorf("private key signing called SignBlob as %v", signer.accounts)
+				}
+				return
+			}
+			if len(signer.accounts) != 1 || signer.accounts[0] != tt.wantAccount {
+				t.Fatalf("SignBlob called as %v, want %s once", signer.accounts, tt.wantAccount)
+			}
+			// The URL carries the signature SignBlob returned over the V4 string to sign
+			digest := sha256.Sum256(signer.payloads[0])
+			if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+				t.Errorf("signature doesn't verify: %v", err)
+			}
+			if !strings.HasPrefix(string(signer.payloads[0]), "GOOG4-RSA-SHA256\n") {
+				t.Errorf("SignBlob payload %q, want a V4 string to sign", signer.payloads[0])
+			}
+		})
+	}
+}
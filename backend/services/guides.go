@@ -0,0 +1,360 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joshndala/cantrip/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// guideCollection stores curated guide articles, one document per guide
+const guideCollection = "guides"
+
+// MaxGuideBytes bounds an ingested article, frontmatter included
+const MaxGuideBytes = 256 << 10
+
+// maxLinkedGuides is how many guides are linked from one itinerary day or suggestion
+const maxLinkedGuides = 2
+
+// ErrInvalidGuide is returned for an article without frontmatter, a title, a known city or
+// a body
+var ErrInvalidGuide = errors.New("invalid guide")
+
+// Guide is a curated travel article about a city, written in markdown
+type Guide struct {
+	ID        string    `json:"id"` // city and slug, e.g. "victoria-a-slow-day-in-james-bay"
+	City      string    `json:"city"`
+	Slug      string    `json:"slug"`
+	Title     string    `json:"title"`
+	Summary   string    `json:"summary,omitempty"`
+	Author    string    `json:"author,omitempty"`
+	Published string    `json:"published,omitempty"` // YYYY-MM-DD
+	Tags      []string  `json:"tags,omitempty"`
+	Covers    []string  `json:"covers,omitempty"` // attractions and activities the guide is about, for linking
+	Body      string    `json:"body,omitempty"`   // markdown, without the frontmatter
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// GuideLink points an itinerary day or suggestion at a guide
+type GuideLink struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// GuideIngestResult reports what happened to one ingested article
+type GuideIngestResult struct {
+	ID      string `json:"id,omitempty"`
+	Title   string `json:"title,omitempty"`
+	Created bool   `json:"created,omitempty"` // false when an existing guide was replaced
+	Error   string `json:"error,omitempty"`
+}
+
+// guideFrontmatter is the YAML block that opens an article
+type guideFrontmatter struct {
+	Title     string   `yaml:"title"`
+	City      string   `yaml:"city"`
+	Slug      string   `yaml:"slug"`
+	Summary   string   `yaml:"summary"`
+	Author    string   `yaml:"author"`
+	Published string   `yaml:"published"`
+	Tags      []string `yaml:"tags"`
+	Covers    []string `yaml:"covers"`
+}
+
+// cachedGuides is a tenant's guides, loaded once and replaced on every ingest
+type cachedGuides struct {
+	guides  []Guide
+	version int64 // changes whenever the guides do, for the suggestion cache key
+}
+
+var (
+	guidesMu    sync.Mutex
+	guideCaches sync.Map // tenant ID -> *cachedGuides
+)
+
+// IngestGuides parses and stores markdown articles. Each article is handled on its own, so
+// one with bad frontmatter doesn't stop the rest; an article whose city and slug match an
+// existing guide replaces it.
+func IngestGuides(ctx context.Context, articles []string) ([]GuideIngestResult, error) {
+	index, err := GetCityIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	guidesMu.Lock()
+	defer guidesMu.Unlock()
+
+	results := make([]GuideIngestResult, 0, len(articles))
+	changed := false
+	for _, article := range articles {
+		guide, err := parseGuide(index, article)
+		if err != nil {
+			results = append(results, GuideIngestResult{Error: err.Error()})
+			continue
+		}
+
+		now := time.Now().UTC()
+		guide.CreatedAt, guide.UpdatedAt = now, now
+		var existing Guide
+		created := true
+		if err := loadDocument(ctx, guideCollection, guide.ID, &existing); err == nil {
+			guide.CreatedAt = existing.CreatedAt
+			created = false
+		}
+		if err := saveDocument(ctx, guideCollection, guide.ID, guide); err != nil {
+			results = append(results, GuideIngestResult{ID: guide.ID, Title: guide.Title, Error: "failed to save guide: " + err.Error()})
+			continue
+		}
+		changed = true
+		results = append(results, GuideIngestResult{ID: guide.ID, Title: guide.Title, Created: created})
+	}
+
+	if changed {
+		guideCaches.Delete(TenantFromContext(ctx).ID)
+	}
+	return results, nil
+}
+
+// parseGuide splits an article into its frontmatter and markdown body
+func parseGuide(index *CityIndex, article string) (*Guide, error) {
+	if len(article) > MaxGuideBytes {
+		return nil, fmt.Errorf("%w: article is larger than %d KB", ErrInvalidGuide, MaxGuideBytes>>10)
+	}
+	content := strings.TrimPrefix(strings.ReplaceAll(article, "\r\n", "\n"), "\ufeff")
+	if !strings.HasPrefix(content, "---\n") {
+		return nil, fmt.Errorf("%w: article must start with --- frontmatter", ErrInvalidGuide)
+	}
+	rest := "\n" + content[len("---\n"):] + "\n"
+	end := strings.Index(rest, "\n---\n")
+	if end < 0 {
+		return nil, fmt.Errorf("%w: frontmatter isn't closed with ---", ErrInvalidGuide)
+	}
+	header, body := rest[:end], rest[end+len("\n---\n"):]
+
+	var front guideFrontmatter
+	decoder := yaml.NewDecoder(bytes.NewReader([]byte(header)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&front); err != nil {
+		return nil, fmt.Errorf("%w: frontmatter: %v", ErrInvalidGuide, err)
+	}
+
+	switch {
+	case strings.TrimSpace(front.Title) == "":
+		return nil, fmt.Errorf("%w: title is required", ErrInvalidGuide)
+	case strings.TrimSpace(body) == "":
+		return nil, fmt.Errorf("%w: %q has no body", ErrInvalidGuide, front.Title)
+	}
+	city, ok := index.Find(front.City)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown city %q", ErrInvalidGuide, front.City)
+	}
+	if front.Published != "" {
+		if _, err := time.Parse("2006-01-02", front.Published); err != nil {
+			return nil, fmt.Errorf("%w: published must be YYYY-MM-DD", ErrInvalidGuide)
+		}
+	}
+
+	slug := guideSlug(firstNonEmpty(front.Slug, front.Title))
+	if slug == "" {
+		return nil, fmt.Errorf("%w: slug must contain letters or digits", ErrInvalidGuide)
+	}
+	return &Guide{
+		ID:        guideSlug(city.Name) + "-" + slug,
+		City:      city.Name,
+		Slug:      slug,
+		Title:     strings.TrimSpace(front.Title),
+		Summary:   strings.TrimSpace(front.Summary),
+		Author:    strings.TrimSpace(front.Author),
+		Published: front.Published,
+		Tags:      normalizeInterests(front.Tags),
+		Covers:    front.Covers,
+		Body:      strings.TrimSpace(body) + "\n",
+	}, nil
+}
+
+// guideSlug folds a title or city into lowercase words joined by hyphens
+func guideSlug(s string) string {
+	return strings.ReplaceAll(NormalizeCityName(s), " ", "-")
+}
+
+// loadGuides returns the tenant's guides, newest first
+func loadGuides(ctx context.Context) (*cachedGuides, error) {
+	tenant := TenantFromContext(ctx).ID
+	if cached, ok := guideCaches.Load(tenant); ok {
+		return cached.(*cachedGuides), nil
+	}
+
+	ids, err := listDocumentIDs(ctx, guideCollection)
+	if err != nil {
+		return nil, err
+	}
+	loaded := &cachedGuides{version: time.Now().UnixNano()}
+	for _, id := range ids {
+		var guide Guide
+		if err := loadDocument(ctx, guideCollection, id, &guide); err != nil {
+			utils.LogError("Failed to load guide", err)
+			continue
+		}
+		loaded.guides = append(loaded.guides, guide)
+	}
+	sort.Slice(loaded.guides, func(i, j int) bool {
+		a, b := loaded.guides[i], loaded.guides[j]
+		if a.Published != b.Published {
+			return a.Published > b.Published
+		}
+		return a.ID < b.ID
+	})
+
+	actual, _ := guideCaches.LoadOrStore(tenant, loaded)
+	return actual.(*cachedGuides), nil
+}
+
+// guidesVersion identifies the tenant's current set of guides
+func guidesVersion(ctx context.Context) int64 {
+	cached, err := loadGuides(ctx)
+	if err != nil {
+		return 0
+	}
+	return cached.version
+}
+
+// ListGuides returns a city's guides without their bodies, newest first, optionally only
+// those with a tag
+func ListGuides(ctx context.Context, city, tag string) ([]Guide, error) {
+	cached, err := loadGuides(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key := NormalizeCityName(city)
+	tag = strings.ToLower(strings.TrimSpace(tag))
+
+	guides := []Guide{}
+	for _, guide := range cached.guides {
+		if NormalizeCityName(guide.City) != key || (tag != "" && !utils.Contains(guide.Tags, tag)) {
+			continue
+		}
+		guide.Body = ""
+		guides = append(guides, guide)
+	}
+	return guides, nil
+}
+
+// GetGuide returns one of a city's guides with its body
+func GetGuide(ctx context.Context, city, slug string) (*Guide, error) {
+	var guide Guide
+	if err := loadDocument(ctx, guideCollection, guideSlug(city)+"-"+guideSlug(slug), &guide); err != nil {
+		return nil, err
+	}
+	return &guide, nil
+}
+
+// DeleteGuide removes a guide
+func DeleteGuide(ctx context.Context, id string) error {
+	guidesMu.Lock()
+	defer guidesMu.Unlock()
+
+	var guide Guide
+	if err := loadDocument(ctx, guideCollection, id, &guide); err != nil {
+		return err
+	}
+	if err := deleteDocument(ctx, guideCollection, id); err != nil {
+		return err
+	}
+	guideCaches.Delete(TenantFromContext(ctx).ID)
+	return nil
+}
+
+// guidesAbout finds a city's guides that cover or are tagged with any of the terms,
+// such as a day's activities or a suggestion's tags
+func guidesAbout(guides []Guide, city string, terms []string) []GuideLink {
+	key := NormalizeCityName(city)
+	var links []GuideLink
+	for _, guide := range guides {
+		if NormalizeCityName(guide.City) != key || !guideMentions(guide, terms) {
+			continue
+		}
+		links = append(links, GuideLink{
+			ID:    guide.ID,
+			Title: guide.Title,
+			URL:   fmt.Sprintf("/api/v1/guides/%s/%s", guideSlug(guide.City), guide.Slug),
+		})
+		if len(links) == maxLinkedGuides {
+			break
+		}
+	}
+	return links
+}
+
+// guideMentions reports whether a term names something the guide covers, or is one of
+// its tags. Covered attractions match inside longer activity names, so a guide to
+// "Butchart Gardens" links from "Morning at Butchart Gardens".
+func guideMentions(guide Guide, terms []string) bool {
+	for _, term := range terms {
+		term = strings.ToLower(strings.TrimSpace(term))
+		if term == "" {
+			continue
+		}
+		if utils.Contains(guide.Tags, term) {
+			return true
+		}
+		for _, covered := range guide.Covers {
+			if covered = strings.ToLower(strings.TrimSpace(covered)); covered != "" && strings.Contains(term, covered) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// LinkItineraryGuides adds links to guides about each day's activities
+func LinkItineraryGuides(ctx context.Context, resp *ItineraryResponse) {
+	if resp == nil || resp.Itinerary == nil {
+		return
+	}
+	cached, err := loadGuides(ctx)
+	if err != nil {
+		utils.LogError("Failed to load guides for itinerary", err)
+		return
+	}
+	if len(cached.guides) == 0 {
+		return
+	}
+
+	city := stringField(resp.Itinerary, "city", resp.Metadata.City)
+	days, _ := resp.Itinerary["days"].([]interface{})
+	for _, rawDay := range days {
+		day, ok := rawDay.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if links := guidesAbout(cached.guides, city, plannedActivityNames(day)); len(links) > 0 {
+			day["guides"] = links
+		}
+	}
+}
+
+// linkSuggestionGuides adds links to guides about each suggestion's activities or tags
+func linkSuggestionGuides(ctx context.Context, run *SuggestionRun) error {
+	if run.City == nil {
+		return nil
+	}
+	cached, err := loadGuides(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load guides: %w", err)
+	}
+	for i := range run.Suggestions {
+		suggestion := &run.Suggestions[i]
+		terms := append(append([]string{suggestion.Title}, suggestion.Activities...), suggestion.Tags...)
+		suggestion.Guides = guidesAbout(cached.guides, run.City.Name, terms)
+	}
+	return nil
+}
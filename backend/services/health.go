@@ -0,0 +1,336 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joshndala/cantrip/models"
+	"github.com/joshndala/cantrip/utils"
+)
+
+// HealthCategory is the packing list category for health items and medications
+const HealthCategory = "Health & Medications"
+
+// RefillReminderCollection stores scheduled medication refill reminders
+const RefillReminderCollection = "refill_reminders"
+
+// NotificationMedicationRefill reminds a user to refill a medication before a trip
+const NotificationMedicationRefill = "medication_refill"
+
+// HealthRules represents the health_rules section of packing_rules.json
+type HealthRules struct {
+	Always   []string             `json:"always"`
+	Seasonal []SeasonalHealthRule `json:"seasonal"`
+	Altitude AltitudeRule         `json:"altitude"`
+	Refill   RefillRule           `json:"refill"`
+}
+
+// SeasonalHealthRule adds items for trips overlapping some months, such as blackfly season
+type SeasonalHealthRule struct {
+	Name       string   `json:"name"`
+	Months     []int    `json:"months"`
+	Provinces  []string `json:"provinces"`  // everywhere when empty
+	Activities []string `json:"activities"` // any activity when empty
+	Items      []string `json:"items"`
+	Note       string   `json:"note"`
+}
+
+// AltitudeRule adds items and a note for destinations at elevation
+type AltitudeRule struct {
+	MinElevationM int            `json:"min_elevation_m"`
+	Locations     map[string]int `json:"locations"` // destination -> elevation in meters
+	Items         []string       `json:"items"`
+	Note          string         `json:"note"` // formatted with the destination and elevation
+}
+
+// RefillRule controls how far ahead refill reminders are sent
+type RefillRule struct {
+	LeadDays  int `json:"lead_days"`  // days before departure to remind
+	SpareDays int `json:"spare_days"` // extra days of supply to pack
+}
+
+// Medication is a medication a traveler declares for a trip
+type Medication = models.Medication
+
+// RefillReminder is a reminder to refill a medication before departure
+type RefillReminder = models.RefillReminder
+
+// ScheduledRefillReminder is a refill reminder stored for delivery
+type ScheduledRefillReminder struct {
+	RefillReminder
+	ID            string     `json:"id"`
+	UserID        string     `json:"user_id"`
+	PackingListID string     `json:"packing_list_id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+}
+
+// healthPackingCategory builds the health category for a trip from the destination's
+// province, elevation and the months travelled, and returns notes explaining it
+func healthPackingCategory(ctx context.Context, rules *PackingRules, destination, startDate, endDate string, activities []string) (PackingCategory, []string) {
+	category := PackingCategory{Name: HealthCategory, Items: []PackingItem{}}
+	if rules.HealthRules == nil {
+		return category, nil
+	}
+	health := rules.HealthRules
+
+	for _, name := range health.Always {
+		category.Items = append(category.Items, PackingItem{Name: name, Quantity: 1, Reason: "Health essential"})
+	}
+
+	province := ""
+	if metadata, err := loadCityMetadata(ctx); err == nil {
+		if city, err := findCity(metadata, destination); err == nil {
+			province = city.Province
+		}
+	}
+
+	var notes []string
+	months := tripMonths(startDate, endDate)
+	for _, rule := range health.Seasonal {
+		if !overlapsMonths(months, rule.Months) {
+			continue
+		}
+		if len(rule.Provinces) > 0 && !containsFold(rule.Provinces, province) {
+			continue
+		}
+		if len(rule.Activities) > 0 && !containsAnyActivity(activities, rule.Activities) {
+			continue
+		}
+		for _, name := range rule.Items {
+			category.Items = append(category.Items, PackingItem{Name: name, Quantity: 1, Reason: rule.Name})
+		}
+		if rule.Note != "" {
+			notes = append(notes, rule.Note)
+		}
+	}
+
+	for location, elevation := range health.Altitude.Locations {
+		if !strings.EqualFold(location, destination) || elevation < health.Altitude.MinElevationM {
+			continue
+		}
+		for _, name := range health.Altitude.Items {
+			category.Items = append(category.Items, PackingItem{Name: name, Quantity: 1, Reason: fmt.Sprintf("High elevation (%d m)", elevation)})
+		}
+		if health.Altitude.Note != "" {
+			notes = append(notes, fmt.Sprintf(health.Altitude.Note, location, elevation))
+		}
+	}
+
+	return dedupePackingCategory(category), notes
+}
+
+// addMedications lists declared medications in the health category, with enough doses for
+// the trip plus spare days. Call after the duration and group multipliers.
+func addMedications(categories []PackingCategory, rules *PackingRules, medications []Medication, duration int) []PackingCategory {
+	if len(medications) == 0 {
+		return categories
+	}
+	spare := 0
+	if rules.HealthRules != nil {
+		spare = rules.HealthRules.Refill.SpareDays
+	}
+	days := duration + spare
+
+	items := []PackingItem{}
+	for _, medication := range medications {
+		item := PackingItem{Name: medication.Name, Quantity: 1, Reason: "Declared medication", Medication: true}
+		if medication.DosesPerDay > 0 {
+			item.Quantity = int(math.Ceil(medication.DosesPerDay * float64(days)))
+			item.Reason = fmt.Sprintf("%g per day for %d days, including %d spare", medication.DosesPerDay, days, spare)
+		}
+		items = append(items, item)
+	}
+
+	for i := range categories {
+		if categories[i].Name == HealthCategory {
+			categories[i].Items = append(categories[i].Items, items...)
+			return categories
+		}
+	}
+	return consolidatePackingCategories(append(categories, PackingCategory{Name: HealthCategory, Items: items}))
+}
+
+// planRefillReminders returns a reminder for each medication whose supply runs out before
+// the trip ends, dated a few days ahead of departure (or today if that has passed)
+func planRefillReminders(rules *PackingRules, medications []Medication, startDate string, duration int) []RefillReminder {
+	start, err := time.Parse("2006-01-02", startDate)
+	if err != nil {
+		return nil
+	}
+	lead, spare := 7, 0
+	if rules.HealthRules != nil {
+		lead, spare = rules.HealthRules.Refill.LeadDays, rules.HealthRules.Refill.SpareDays
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	remindOn := start.AddDate(0, 0, -lead)
+	if remindOn.Before(today) {
+		remindOn = today
+	}
+
+	// Supply is counted from today, so it has to last until departure as well
+	needed := models.DaysBetween(today, start) + duration + spare
+	var reminders []RefillReminder
+	for _, medication := range medications {
+		if medication.SupplyDays <= 0 || medication.SupplyDays >= needed {
+			continue
+		}
+		reminders = append(reminders, RefillReminder{
+			Medication: medication.Name,
+			Traveler:   medication.Traveler,
+			RemindOn:   remindOn.Format("2006-01-02"),
+			DaysShort:  needed - medication.SupplyDays,
+			Message: fmt.Sprintf("Refill %s before your trip on %s: your supply runs %d day(s) short",
+				medication.Name, startDate, needed-medication.SupplyDays),
+		})
+	}
+	return reminders
+}
+
+// ScheduleRefillReminders stores a packing list's refill reminders for delivery to the
+// user, replacing any scheduled for an earlier version of the list
+func ScheduleRefillReminders(ctx context.Context, userID string, packingList PackingResponse) error {
+	existing, err := listRefillReminders(ctx)
+	if err != nil {
+		return err
+	}
+	for _, reminder := range existing {
+		if reminder.UserID == userID && reminder.PackingListID == packingList.ID && reminder.SentAt == nil {
+			if err := deleteDocument(ctx, RefillReminderCollection, reminder.ID); err != nil {
+				return fmt.Errorf("failed to replace refill reminder: %w", err)
+			}
+		}
+	}
+
+	for _, reminder := range packingList.RefillReminders {
+		scheduled := ScheduledRefillReminder{
+			RefillReminder: reminder,
+			ID:             utils.GenerateID(),
+			UserID:         userID,
+			PackingListID:  packingList.ID,
+			CreatedAt:      time.Now().UTC(),
+		}
+		if err := saveDocument(ctx, RefillReminderCollection, scheduled.ID, scheduled); err != nil {
+			return fmt.Errorf("failed to save refill reminder: %w", err)
+		}
+	}
+	return nil
+}
+
+// SendDueRefillReminders notifies users of refill reminders that are due
+func SendDueRefillReminders(ctx context.Context) (int, error) {
+	reminders, err := listRefillReminders(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	today := time.Now().UTC().Format("2006-01-02")
+	sent := 0
+	for _, reminder := range reminders {
+		if reminder.SentAt != nil || reminder.RemindOn > today {
+			continue
+		}
+		title := "Refill " + reminder.Medication
+		if reminder.Traveler != "" {
+			title += " for " + reminder.Traveler
+		}
+		_, err := Notify(ctx, Notification{
+			UserID: reminder.UserID,
+			Type:   NotificationMedicationRefill,
+			Title:  title,
+			Body:   reminder.Message,
+			Data:   map[string]string{"packing_list_id": reminder.PackingListID, "medication": reminder.Medication},
+		})
+		if err != nil {
+			utils.LogError("Failed to send refill reminder", err)
+			continue
+		}
+		now := time.Now().UTC()
+		reminder.SentAt = &now
+		if err := saveDocument(ctx, RefillReminderCollection, reminder.ID, reminder); err != nil {
+			utils.LogError("Failed to mark refill reminder sent", err)
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// listRefillReminders loads every scheduled refill reminder, oldest first
+func listRefillReminders(ctx context.Context) ([]ScheduledRefillReminder, error) {
+	ids, err := listDocumentIDs(ctx, RefillReminderCollection)
+	if err != nil {
+		return nil, err
+	}
+	reminders := []ScheduledRefillReminder{}
+	for _, id := range ids {
+		var reminder ScheduledRefillReminder
+		if err := loadDocument(ctx, RefillReminderCollection, id, &reminder); err == nil {
+			reminders = append(reminders, reminder)
+		}
+	}
+	sort.Slice(reminders, func(i, j int) bool {
+		return reminders[i].CreatedAt.Before(reminders[j].CreatedAt)
+	})
+	return reminders, nil
+}
+
+// tripMonths returns the calendar months a trip touches
+func tripMonths(startDate, endDate string) map[int]bool {
+	months := map[int]bool{}
+	start, err := time.Parse("2006-01-02", startDate)
+	if err != nil {
+		return months
+	}
+	end, err := time.Parse("2006-01-02", endDate)
+	if err != nil || end.Before(start) {
+		end = start
+	}
+	for day := start; !day.After(end) && len(months) < 12; day = day.AddDate(0, 0, 1) {
+		months[int(day.Month())] = true
+	}
+	return months
+}
+
+// overlapsMonths reports whether any rule month is in the trip
+func overlapsMonths(trip map[int]bool, months []int) bool {
+	for _, month := range months {
+		if trip[month] {
+			return true
+		}
+	}
+	return false
+}
+
+// containsAnyActivity reports whether the trip includes one of the activities
+func containsAnyActivity(activities, wanted []string) bool {
+	for _, activity := range activities {
+		if utils.Contains(wanted, activity) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsFold reports whether values holds value, ignoring case
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// dedupePackingCategory merges items repeated within one category, combining reasons
+func dedupePackingCategory(category PackingCategory) PackingCategory {
+	merged := consolidatePackingCategories([]PackingCategory{category})
+	if len(merged) == 0 {
+		return PackingCategory{Name: category.Name, Items: []PackingItem{}}
+	}
+	return merged[0]
+}
@@ -0,0 +1,318 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/joshndala/cantrip/models"
+	"github.com/joshndala/cantrip/utils"
+)
+
+// Insurance plan types
+const (
+	InsuranceEmergencyMedical = "emergency_medical"
+	InsuranceTripCancellation = "trip_cancellation"
+	InsuranceAllInclusive     = "all_inclusive"
+)
+
+// InsuranceReminderCollection records the trips already sent an insurance reminder
+const InsuranceReminderCollection = "insurance_reminders"
+
+// InsuranceReminderWindow is how far ahead of departure owners are reminded about insurance
+const InsuranceReminderWindow = 21 * 24 * time.Hour
+
+// NotificationInsuranceReminder prompts a trip owner to arrange travel insurance
+const NotificationInsuranceReminder = "insurance_reminder"
+
+// Limits on insurance comparison queries
+const (
+	MaxInsuranceTripDays = 365
+	MaxTravelerAge       = 120
+	defaultTravelerAge   = 35 // assumed when a reminder has no traveler ages
+)
+
+// ErrInvalidInsuranceQuery is returned for missing or out-of-range comparison parameters
+var ErrInvalidInsuranceQuery = errors.New("invalid insurance query")
+
+// InsuranceData represents the structure of travel_insurance.json
+type InsuranceData struct {
+	Note      string              `json:"note"`
+	Currency  string              `json:"currency"`
+	Providers []InsuranceProvider `json:"providers"`
+}
+
+// InsuranceProvider is an insurer and its plans
+type InsuranceProvider struct {
+	ID    string          `json:"id"`
+	Name  string          `json:"name"`
+	Plans []InsurancePlan `json:"plans"`
+}
+
+// InsurancePlan is one product with its sample pricing and limits
+type InsurancePlan struct {
+	ID                       string          `json:"id"`
+	Type                     string          `json:"type"`
+	Name                     string          `json:"name"`
+	DailyRates               []InsuranceRate `json:"daily_rates,omitempty"`       // per traveler, by age band
+	CancellationRate         float64         `json:"cancellation_rate,omitempty"` // fraction of the insured trip cost
+	MinimumPremium           float64         `json:"minimum_premium"`
+	MaxTripDays              int             `json:"max_trip_days"`
+	MaxAge                   int             `json:"max_age"`
+	MedicalCoverage          float64         `json:"medical_coverage,omitempty"`
+	CancellationLimit        float64         `json:"cancellation_limit,omitempty"`
+	Deductible               float64         `json:"deductible,omitempty"`
+	PreExistingStabilityDays int             `json:"pre_existing_stability_days,omitempty"`
+	AdventureSports          bool            `json:"adventure_sports,omitempty"`
+	Features                 []string        `json:"features,omitempty"`
+}
+
+// InsuranceRate is the daily premium for travelers up to an age
+type InsuranceRate struct {
+	MaxAge int     `json:"max_age"`
+	Rate   float64 `json:"rate"`
+}
+
+// InsuranceQuery describes the trip to compare plans for
+type InsuranceQuery struct {
+	Destination string   `json:"destination,omitempty"`
+	TripCost    float64  `json:"trip_cost"` // prepaid, non-refundable costs to insure
+	Duration    int      `json:"duration"`  // days
+	Ages        []int    `json:"ages"`
+	Activities  []string `json:"activities,omitempty"`
+	PlanType    string   `json:"plan_type,omitempty"` // one type, or all when empty
+}
+
+// InsuranceQuote is the estimated premium for one plan
+type InsuranceQuote struct {
+	ProviderID   string        `json:"provider_id"`
+	ProviderName string        `json:"provider_name"`
+	Plan         InsurancePlan `json:"plan"`
+	Premium      float64       `json:"premium"`
+	PerTraveler  float64       `json:"per_traveler"`
+	Reason       string        `json:"reason,omitempty"` // why the plan isn't available
+}
+
+// InsuranceComparison compares plans for a trip
+type InsuranceComparison struct {
+	Query           InsuranceQuery   `json:"query"`
+	Currency        string           `json:"currency"`
+	Quotes          []InsuranceQuote `json:"quotes"`     // cheapest first
+	Ineligible      []InsuranceQuote `json:"ineligible"` // plans the trip or travelers don't qualify for
+	Recommendations []string         `json:"recommendations"`
+	Tips            []Tip            `json:"tips"`
+	Note            string           `json:"note"`
+}
+
+// insuranceReminder marks a trip as reminded
+type insuranceReminder struct {
+	ItineraryID string    `json:"itinerary_id"`
+	SentAt      time.Time `json:"sent_at"`
+}
+
+// loadInsuranceData loads the insurance comparison dataset
+func loadInsuranceData(ctx context.Context) (*InsuranceData, error) {
+	data, err := ReadDataset(ctx, TravelInsuranceDataset)
+	if err != nil {
+		return nil, err
+	}
+
+	var insurance InsuranceData
+	if err := json.Unmarshal(data, &insurance); err != nil {
+		return nil, err
+	}
+	return &insurance, nil
+}
+
+// CompareInsurance estimates premiums for every plan that fits the trip and explains
+// what to look for given the travelers' ages, trip cost and activities
+func CompareInsurance(ctx context.Context, query InsuranceQuery) (*InsuranceComparison, error) {
+	if query.Duration < 1 || query.Duration > MaxInsuranceTripDays {
+		return nil, fmt.Errorf("%w: duration must be between 1 and %d days", ErrInvalidInsuranceQuery, MaxInsuranceTripDays)
+	}
+	if len(query.Ages) == 0 {
+		return nil, fmt.Errorf("%w: at least one traveler age is required", ErrInvalidInsuranceQuery)
+	}
+	for _, age := range query.Ages {
+		if age < 0 || age > MaxTravelerAge {
+			return nil, fmt.Errorf("%w: ages must be between 0 and %d", ErrInvalidInsuranceQuery, MaxTravelerAge)
+		}
+	}
+	if query.TripCost < 0 {
+		return nil, fmt.Errorf("%w: trip_cost can't be negative", ErrInvalidInsuranceQuery)
+	}
+	switch query.PlanType {
+	case "", InsuranceEmergencyMedical, InsuranceTripCancellation, InsuranceAllInclusive:
+	default:
+		return nil, fmt.Errorf("%w: plan must be %s, %s or %s", ErrInvalidInsuranceQuery, InsuranceEmergencyMedical, InsuranceTripCancellation, InsuranceAllInclusive)
+	}
+
+	data, err := loadInsuranceData(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load insurance data: %w", err)
+	}
+
+	comparison := &InsuranceComparison{
+		Query:      query,
+		Currency:   data.Currency,
+		Quotes:     []InsuranceQuote{},
+		Ineligible: []InsuranceQuote{},
+		Note:       data.Note,
+	}
+	for _, provider := range data.Providers {
+		for _, plan := range provider.Plans {
+			if query.PlanType != "" && plan.Type != query.PlanType {
+				continue
+			}
+			quote := quoteInsurancePlan(provider, plan, query)
+			if quote.Reason != "" {
+				comparison.Ineligible = append(comparison.Ineligible, quote)
+			} else {
+				comparison.Quotes = append(comparison.Quotes, quote)
+			}
+		}
+	}
+	sort.SliceStable(comparison.Quotes, func(i, j int) bool {
+		return comparison.Quotes[i].Premium < comparison.Quotes[j].Premium
+	})
+
+	comparison.Recommendations = insuranceRecommendations(query)
+	comparison.Tips = []Tip{}
+	if tips, err := GetTravelTips(ctx, query.Destination, "health", []string{"insurance"}); err == nil {
+		comparison.Tips = tips
+	}
+	return comparison, nil
+}
+
+// quoteInsurancePlan prices a plan for the trip, or records why it isn't available
+func quoteInsurancePlan(provider InsuranceProvider, plan InsurancePlan, query InsuranceQuery) InsuranceQuote {
+	quote := InsuranceQuote{ProviderID: provider.ID, ProviderName: provider.Name, Plan: plan}
+
+	oldest := 0
+	for _, age := range query.Ages {
+		oldest = max(oldest, age)
+	}
+	switch {
+	case plan.MaxTripDays > 0 && query.Duration > plan.MaxTripDays:
+		quote.Reason = fmt.Sprintf("covers trips up to %d days", plan.MaxTripDays)
+		return quote
+	case plan.MaxAge > 0 && oldest > plan.MaxAge:
+		quote.Reason = fmt.Sprintf("covers travelers up to age %d", plan.MaxAge)
+		return quote
+	case plan.CancellationRate > 0 && query.TripCost <= 0:
+		quote.Reason = "needs the trip cost to price cancellation cover"
+		return quote
+	case plan.CancellationLimit > 0 && query.TripCost > plan.CancellationLimit:
+		quote.Reason = fmt.Sprintf("cancellation cover is limited to %.0f", plan.CancellationLimit)
+		return quote
+	}
+
+	premium := 0.0
+	for _, age := range query.Ages {
+		premium += dailyInsuranceRate(plan.DailyRates, age) * float64(query.Duration)
+	}
+	premium += plan.CancellationRate * query.TripCost
+	premium = math.Max(premium, plan.MinimumPremium*float64(len(query.Ages)))
+
+	quote.Premium = roundCost(premium)
+	quote.PerTraveler = roundCost(premium / float64(len(query.Ages)))
+	return quote
+}
+
+// dailyInsuranceRate returns the rate for the first age band covering the traveler
+func dailyInsuranceRate(rates []InsuranceRate, age int) float64 {
+	for _, band := range rates {
+		if age <= band.MaxAge {
+			return band.Rate
+		}
+	}
+	if len(rates) > 0 {
+		return rates[len(rates)-1].Rate
+	}
+	return 0
+}
+
+// insuranceRecommendations explains which cover matters for the trip
+func insuranceRecommendations(query InsuranceQuery) []string {
+	recommendations := []string{
+		"Provincial health plans pay only part of medical costs outside your home province, and nothing for visitors; emergency medical cover is the essential part of any plan",
+	}
+	if query.TripCost > 0 {
+		recommendations = append(recommendations, fmt.Sprintf("Insure the full %.0f of prepaid, non-refundable costs with cancellation and interruption cover", query.TripCost))
+	} else {
+		recommendations = append(recommendations, "Add trip_cost to compare cancellation cover for prepaid flights, tours and lodging")
+	}
+	for _, age := range query.Ages {
+		if age >= 60 {
+			recommendations = append(recommendations, "Travelers 60 and over usually answer a medical questionnaire; check the pre-existing condition stability period against recent changes in medication")
+			break
+		}
+	}
+	if containsAnyActivity(query.Activities, []string{"outdoor_adventure"}) {
+		recommendations = append(recommendations, "Skiing, mountain hiking and other adventure sports are excluded by many plans; choose one that covers them, including search and rescue")
+	}
+	if query.Duration > 30 {
+		recommendations = append(recommendations, "Long trips can exceed single-trip limits; compare with an annual multi-trip plan")
+	}
+	return recommendations
+}
+
+// SendInsuranceReminders prompts owners of trips starting within three weeks to arrange
+// insurance, once per trip, with the cheapest emergency medical estimate for the group
+func SendInsuranceReminders(ctx context.Context) (int, error) {
+	ids, err := listDocumentIDs(ctx, ItineraryCollection)
+	if err != nil {
+		return 0, err
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	horizon := today.Add(InsuranceReminderWindow)
+	sent := 0
+	for _, id := range ids {
+		var reminded insuranceReminder
+		if loadDocument(ctx, InsuranceReminderCollection, id, &reminded) == nil {
+			continue
+		}
+
+		itinerary, err := GetItinerary(ctx, id)
+		if err != nil || itinerary.OwnerID == "" {
+			continue
+		}
+		city, start, end, ok := itineraryTripDates(itinerary)
+		if !ok || start.Before(today) || start.After(horizon) {
+			continue
+		}
+
+		groupSize := intField(itinerary.Itinerary, "group_size", 1)
+		ages := make([]int, groupSize)
+		for i := range ages {
+			ages[i] = defaultTravelerAge
+		}
+		body := fmt.Sprintf("Your trip to %s starts on %s. Check that your travel insurance covers medical care and cancellation.", city, start.Format("2006-01-02"))
+		comparison, err := CompareInsurance(ctx, InsuranceQuery{Destination: city, Duration: models.DaysBetween(start, end) + 1, Ages: ages, PlanType: InsuranceEmergencyMedical})
+		if err == nil && len(comparison.Quotes) > 0 {
+			body += fmt.Sprintf(" Emergency medical plans start around %.2f %s for your group.", comparison.Quotes[0].Premium, comparison.Currency)
+		}
+
+		_, err = Notify(ctx, Notification{
+			UserID: itinerary.OwnerID,
+			Type:   NotificationInsuranceReminder,
+			Title:  "Travel insurance for " + city,
+			Body:   body,
+			Data:   map[string]string{"itinerary_id": id},
+		})
+		if err != nil {
+			utils.LogError("Failed to send insurance reminder", err)
+			continue
+		}
+		if err := saveDocument(ctx, InsuranceReminderCollection, id, insuranceReminder{ItineraryID: id, SentAt: time.Now().UTC()}); err != nil {
+			utils.LogError("Failed to record insurance reminder", err)
+		}
+		sent++
+	}
+	return sent, nil
+}
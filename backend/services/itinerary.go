@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/joshndala/cantrip/utils"
+)
+
+// ItineraryCollection is the storage collection for itineraries
+const ItineraryCollection = "itineraries"
+
+// SaveItinerary saves an itinerary to GCS or local storage.
+// Saves that change the plan bump the revision and keep a snapshot for diffs.
+func SaveItinerary(ctx context.Context, itinerary *ItineraryResponse) error {
+	// Assign a stable ID so the itinerary can be referenced later
+	if itinerary.ID == "" {
+		itinerary.ID = utils.GenerateID()
+	}
+
+	unlock := lockDocument(ItineraryCollection, itinerary.ID)
+	defer unlock()
+
+	previous, err := loadItinerary(ctx, itinerary.ID)
+	if err != nil {
+		previous = nil
+	}
+	return saveItinerary(ctx, itinerary, previous)
+}
+
+// SaveItineraryIfRevision saves an itinerary only if the stored copy is still at the
+// expected revision, returning a *VersionConflictError otherwise
+func SaveItineraryIfRevision(ctx context.Context, itinerary *ItineraryResponse, expected int) error {
+	unlock := lockDocument(ItineraryCollection, itinerary.ID)
+	defer unlock()
+
+	previous, err := storedItinerary(ctx, itinerary.ID)
+	if err != nil {
+		return err
+	}
+	if previous.Revision != expected {
+		return &VersionConflictError{Resource: "itinerary", ID: itinerary.ID, Expected: expected, Current: previous.Revision, Document: previous}
+	}
+	return saveItinerary(ctx, itinerary, previous)
+}
+
+// ReplaceItinerary saves an edit of a stored itinerary without a revision check, keeping
+// its owner. A missing or trashed itinerary isn't recreated.
+func ReplaceItinerary(ctx context.Context, itinerary *ItineraryResponse) error {
+	unlock := lockDocument(ItineraryCollection, itinerary.ID)
+	defer unlock()
+
+	previous, err := storedItinerary(ctx, itinerary.ID)
+	if err != nil {
+		return err
+	}
+	itinerary.OwnerID = previous.OwnerID
+	return saveItinerary(ctx, itinerary, previous)
+}
+
+// storedItinerary loads the itinerary an edit replaces, which must exist outside the trash
+func storedItinerary(ctx context.Context, id string) (*ItineraryResponse, error) {
+	previous, err := loadItinerary(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if previous.DeletedAt != nil {
+		return nil, fmt.Errorf("itinerary with ID '%s': %w", id, ErrInTrash)
+	}
+	return previous, nil
+}
+
+// saveItinerary stores an itinerary, snapshotting a new revision when its content changed
+func saveItinerary(ctx context.Context, itinerary, previous *ItineraryResponse) error {
+	if previous != nil && !itineraryContentChanged(previous, itinerary) {
+		itinerary.Revision = previous.Revision
+	} else {
+		itinerary.Revision = 1
+		if previous != nil {
+			itinerary.Revision = previous.Revision + 1
+		}
+		if err := saveItineraryRevision(ctx, itinerary); err != nil {
+			return err
+		}
+	}
+
+	return saveDocument(ctx, ItineraryCollection, itinerary.ID, itinerary)
+}
+
+// GetItinerary retrieves an itinerary by ID
+func GetItinerary(ctx context.Context, id string) (*ItineraryResponse, error) {
+	itinerary, err := loadItinerary(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if itinerary.DeletedAt != nil {
+		return nil, fmt.Errorf("itinerary with ID '%s': %w", id, ErrInTrash)
+	}
+
+	return itinerary, nil
+}
+
+// loadItinerary loads an itinerary regardless of its trash state
+func loadItinerary(ctx context.Context, id string) (*ItineraryResponse, error) {
+	var itinerary ItineraryResponse
+	if err := loadDocument(ctx, ItineraryCollection, id, &itinerary); err != nil {
+		if errors.Is(err, ErrDocumentNotFound) {
+			return nil, fmt.Errorf("itinerary with ID '%s' not found: %w", id, ErrDocumentNotFound)
+		}
+		return nil, err
+	}
+
+	return &itinerary, nil
+}
+
+// DeleteItinerary moves an itinerary to the trash
+func DeleteItinerary(ctx context.Context, id string) error {
+	itinerary, err := GetItinerary(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	itinerary.DeletedAt = &now
+	return SaveItinerary(ctx, itinerary)
+}
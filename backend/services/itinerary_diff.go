@@ -0,0 +1,296 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joshndala/cantrip/utils"
+)
+
+// ItineraryRevisionCollection stores a snapshot of every itinerary revision
+const ItineraryRevisionCollection = "itinerary_revisions"
+
+// ErrRevisionNotFound is returned for revisions that were never saved
+var ErrRevisionNotFound = errors.New("itinerary revision not found")
+
+// ItineraryRevision is a saved version of an itinerary
+type ItineraryRevision struct {
+	ItineraryID string            `json:"itinerary_id"`
+	Revision    int               `json:"revision"`
+	SavedAt     time.Time         `json:"saved_at"`
+	Itinerary   ItineraryResponse `json:"itinerary"`
+}
+
+// ItineraryRevisionSummary lists a revision without its content
+type ItineraryRevisionSummary struct {
+	Revision  int       `json:"revision"`
+	SavedAt   time.Time `json:"saved_at"`
+	TotalCost float64   `json:"total_cost"`
+}
+
+// ItineraryDiff is a structured comparison of two revisions
+type ItineraryDiff struct {
+	ItineraryID string             `json:"itinerary_id"`
+	From        int                `json:"from"`
+	To          int                `json:"to"`
+	Dates       map[string]Change  `json:"dates,omitempty"` // start_date, end_date, city
+	Cost        CostDelta          `json:"cost"`
+	Added       []ActivitySnapshot `json:"added"`
+	Removed     []ActivitySnapshot `json:"removed"`
+	Moved       []ActivityMove     `json:"moved"`
+	Unchanged   int                `json:"unchanged"`
+}
+
+// Change is a before and after value
+type Change struct {
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// CostDelta compares total trip cost
+type CostDelta struct {
+	Before float64 `json:"before"`
+	After  float64 `json:"after"`
+	Delta  float64 `json:"delta"`
+}
+
+// ActivitySnapshot is an activity at a point in the schedule
+type ActivitySnapshot struct {
+	Name      string  `json:"name"`
+	Date      string  `json:"date"`
+	StartTime string  `json:"start_time,omitempty"`
+	EndTime   string  `json:"end_time,omitempty"`
+	Location  string  `json:"location,omitempty"`
+	Cost      float64 `json:"cost,omitempty"`
+}
+
+// ActivityMove is an activity that changed day or time
+type ActivityMove struct {
+	Name string           `json:"name"`
+	From ActivitySnapshot `json:"from"`
+	To   ActivitySnapshot `json:"to"`
+}
+
+// itineraryRevisionID names the snapshot document for a revision
+func itineraryRevisionID(itineraryID string, revision int) string {
+	return fmt.Sprintf("%s-r%d", itineraryID, revision)
+}
+
+// saveItineraryRevision snapshots an itinerary at its current revision
+func saveItineraryRevision(ctx context.Context, itinerary *ItineraryResponse) error {
+	revision := ItineraryRevision{
+		ItineraryID: itinerary.ID,
+		Revision:    itinerary.Revision,
+		SavedAt:     time.Now().UTC(),
+		Itinerary:   *itinerary,
+	}
+	if err := saveDocument(ctx, ItineraryRevisionCollection, itineraryRevisionID(itinerary.ID, itinerary.Revision), revision); err != nil {
+		return fmt.Errorf("failed to save itinerary revision: %w", err)
+	}
+	return nil
+}
+
+// GetItineraryRevision loads a saved revision of an itinerary
+func GetItineraryRevision(ctx context.Context, itineraryID string, revision int) (*ItineraryRevision, error) {
+	var snapshot ItineraryRevision
+	if err := loadDocument(ctx, ItineraryRevisionCollection, itineraryRevisionID(itineraryID, revision), &snapshot); err != nil {
+		if errors.Is(err, ErrDocumentNotFound) {
+			return nil, fmt.Errorf("revision %d of itinerary %s: %w", revision, itineraryID, ErrRevisionNotFound)
+		}
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// ListItineraryRevisions returns an itinerary's saved revisions, oldest first
+func ListItineraryRevisions(ctx context.Context, itineraryID string) ([]ItineraryRevisionSummary, error) {
+	ids, err := listDocumentIDs(ctx, ItineraryRevisionCollection)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := []ItineraryRevisionSummary{}
+	prefix := itineraryID + "-r"
+	for _, id := range ids {
+		if !strings.HasPrefix(id, prefix) {
+			continue
+		}
+		if _, err := strconv.Atoi(strings.TrimPrefix(id, prefix)); err != nil {
+			continue
+		}
+		var snapshot ItineraryRevision
+		if err := loadDocument(ctx, ItineraryRevisionCollection, id, &snapshot); err != nil {
+			continue
+		}
+		revisions = append(revisions, ItineraryRevisionSummary{
+			Revision:  snapshot.Revision,
+			SavedAt:   snapshot.SavedAt,
+			TotalCost: snapshot.Itinerary.Metadata.TotalCost,
+		})
+	}
+
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i].Revision < revisions[j].Revision })
+	return revisions, nil
+}
+
+// deleteItineraryRevisions removes every snapshot of an itinerary
+func deleteItineraryRevisions(ctx context.Context, itineraryID string) {
+	revisions, err := ListItineraryRevisions(ctx, itineraryID)
+	if err != nil {
+		utils.LogError("Failed to list itinerary revisions", err)
+		return
+	}
+	for _, revision := range revisions {
+		if err := deleteDocument(ctx, ItineraryRevisionCollection, itineraryRevisionID(itineraryID, revision.Revision)); err != nil {
+			utils.LogError("Failed to delete itinerary revision", err)
+		}
+	}
+}
+
+// itineraryContentChanged reports whether a save changes the plan itself,
+// ignoring bookkeeping such as trash state, ownership and generation time
+func itineraryContentChanged(previous, current *ItineraryResponse) bool {
+	content := func(it *ItineraryResponse) []byte {
+		data, _ := json.Marshal([]interface{}{it.Itinerary, it.Anchors, it.Metadata.City, it.Metadata.Duration, it.Metadata.TotalCost})
+		return data
+	}
+	return !bytes.Equal(content(previous), content(current))
+}
+
+// DiffItineraryRevisions compares two revisions of an itinerary
+func DiffItineraryRevisions(ctx context.Context, itineraryID string, from, to int) (*ItineraryDiff, error) {
+	before, err := GetItineraryRevision(ctx, itineraryID, from)
+	if err != nil {
+		return nil, err
+	}
+	after, err := GetItineraryRevision(ctx, itineraryID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := DiffItineraries(&before.Itinerary, &after.Itinerary)
+	diff.ItineraryID = itineraryID
+	diff.From, diff.To = from, to
+	return diff, nil
+}
+
+// DiffItineraries computes added, removed and moved activities, date changes and the cost delta.
+// Activities are matched by name; repeated names are paired in schedule order.
+func DiffItineraries(before, after *ItineraryResponse) *ItineraryDiff {
+	diff := &ItineraryDiff{
+		Dates:   map[string]Change{},
+		Added:   []ActivitySnapshot{},
+		Removed: []ActivitySnapshot{},
+		Moved:   []ActivityMove{},
+	}
+
+	for _, field := range []string{"city", "start_date", "end_date"} {
+		old, _ := before.Itinerary[field].(string)
+		current, _ := after.Itinerary[field].(string)
+		if old != current {
+			diff.Dates[field] = Change{Before: old, After: current}
+		}
+	}
+
+	diff.Cost = CostDelta{
+		Before: itineraryCost(before),
+		After:  itineraryCost(after),
+	}
+	diff.Cost.Delta = math.Round((diff.Cost.After-diff.Cost.Before)*100) / 100
+
+	remaining := map[string][]ActivitySnapshot{}
+	for _, activity := range itineraryActivities(before) {
+		key := strings.ToLower(activity.Name)
+		remaining[key] = append(remaining[key], activity)
+	}
+
+	for _, activity := range itineraryActivities(after) {
+		key := strings.ToLower(activity.Name)
+		candidates := remaining[key]
+		if len(candidates) == 0 {
+			diff.Added = append(diff.Added, activity)
+			continue
+		}
+
+		// Prefer an exact match so repeated activities aren't reported as moved
+		match := 0
+		for i, candidate := range candidates {
+			if candidate.Date == activity.Date && candidate.StartTime == activity.StartTime {
+				match = i
+				break
+			}
+		}
+		previous := candidates[match]
+		remaining[key] = append(candidates[:match:match], candidates[match+1:]...)
+
+		if previous.Date != activity.Date || previous.StartTime != activity.StartTime || previous.EndTime != activity.EndTime {
+			diff.Moved = append(diff.Moved, ActivityMove{Name: activity.Name, From: previous, To: activity})
+		} else {
+			diff.Unchanged++
+		}
+	}
+
+	for _, activity := range itineraryActivities(before) {
+		key := strings.ToLower(activity.Name)
+		for i, candidate := range remaining[key] {
+			if candidate == activity {
+				diff.Removed = append(diff.Removed, activity)
+				remaining[key] = append(remaining[key][:i:i], remaining[key][i+1:]...)
+				break
+			}
+		}
+	}
+
+	if len(diff.Dates) == 0 {
+		diff.Dates = nil
+	}
+	return diff
+}
+
+// itineraryActivities flattens an itinerary's days into activity snapshots in schedule order
+func itineraryActivities(itinerary *ItineraryResponse) []ActivitySnapshot {
+	var activities []ActivitySnapshot
+	days, _ := itinerary.Itinerary["days"].([]interface{})
+	for _, rawDay := range days {
+		day, ok := rawDay.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		date, _ := day["date"].(string)
+
+		rawActivities, _ := day["activities"].([]interface{})
+		for _, raw := range rawActivities {
+			activity, ok := raw.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			snapshot := ActivitySnapshot{Date: date}
+			snapshot.Name, _ = activity["name"].(string)
+			snapshot.StartTime, _ = activity["start_time"].(string)
+			snapshot.EndTime, _ = activity["end_time"].(string)
+			snapshot.Location, _ = activity["location"].(string)
+			snapshot.Cost, _ = activity["cost"].(float64)
+			if snapshot.Name != "" {
+				activities = append(activities, snapshot)
+			}
+		}
+	}
+	return activities
+}
+
+// itineraryCost returns the total cost, falling back to the itinerary body
+func itineraryCost(itinerary *ItineraryResponse) float64 {
+	if itinerary.Metadata.TotalCost != 0 {
+		return itinerary.Metadata.TotalCost
+	}
+	cost, _ := itinerary.Itinerary["total_cost"].(float64)
+	return cost
+}
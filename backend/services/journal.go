@@ -0,0 +1,449 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/joshndala/cantrip/models"
+	"github.com/joshndala/cantrip/utils"
+)
+
+// journalCollection stores each trip's journal
+const journalCollection = "trip_journals"
+
+// Journal limits
+const (
+	MaxJournalEntriesPerTrip = 200
+	MaxJournalPhotosPerEntry = 10
+	MaxJournalTextRunes      = 10000
+)
+
+// ErrInvalidJournalEntry is returned for an entry with no content, a day outside the
+// trip or photos that aren't the trip's
+var ErrInvalidJournalEntry = errors.New("invalid journal entry")
+
+// JournalEntry is a traveler's note about one day of a trip. Photos are the trip's photo
+// attachments, so they share its storage, scanning and previews.
+type JournalEntry struct {
+	ID        string    `json:"id"`
+	TripID    string    `json:"trip_id"`
+	Day       int       `json:"day"`            // counted from 1
+	Date      string    `json:"date,omitempty"` // the day's date, when the trip has dates
+	Title     string    `json:"title,omitempty"`
+	Text      string    `json:"text"`
+	PhotoIDs  []string  `json:"photo_ids"`
+	Author    string    `json:"author,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// JournalEntryInput is the writable part of a journal entry
+type JournalEntryInput struct {
+	Day      int      `json:"day"`
+	Title    string   `json:"title"`
+	Text     string   `json:"text"`
+	PhotoIDs []string `json:"photo_ids"`
+}
+
+// TripJournal is the stored journal for a trip
+type TripJournal struct {
+	TripID  string         `json:"trip_id"`
+	Entries []JournalEntry `json:"entries"`
+}
+
+// loadTripJournal loads a trip's journal, empty when it has none
+func loadTripJournal(ctx context.Context, tripID string) (*TripJournal, error) {
+	journal := &TripJournal{TripID: tripID, Entries: []JournalEntry{}}
+	if err := loadDocument(ctx, journalCollection, tripID, journal); err != nil && !errors.Is(err, ErrDocumentNotFound) {
+		return nil, err
+	}
+	return journal, nil
+}
+
+// ListJournalEntries lists a trip's entries in day order, oldest first within a day.
+// A day above zero lists only that day.
+func ListJournalEntries(ctx context.Context, tripID string, day int) ([]JournalEntry, error) {
+	journal, err := loadTripJournal(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+	entries := []JournalEntry{}
+	for _, entry := range journal.Entries {
+		if day <= 0 || entry.Day == day {
+			entries = append(entries, entry)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Day != entries[j].Day {
+			return entries[i].Day < entries[j].Day
+		}
+		return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+	})
+	return entries, nil
+}
+
+// GetJournalEntry finds one entry on a trip
+func GetJournalEntry(ctx context.Context, tripID, id string) (*JournalEntry, error) {
+	journal, err := loadTripJournal(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range journal.Entries {
+		if entry.ID == id {
+			return &entry, nil
+		}
+	}
+	return nil, fmt.Errorf("journal entry %s on trip %s: %w", id, tripID, ErrDocumentNotFound)
+}
+
+// CreateJournalEntry adds an entry to a trip's journal
+func CreateJournalEntry(ctx context.Context, itinerary *ItineraryResponse, author string, input JournalEntryInput) (*JournalEntry, error) {
+	date, err := validateJournalEntry(ctx, itinerary, &input)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock := lockDocument(journalCollection, itinerary.ID)
+	defer unlock()
+
+	journal, err := loadTripJournal(ctx, itinerary.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(journal.Entries) >= MaxJournalEntriesPerTrip {
+		return nil, fmt.Errorf("%w: a trip can have at most %d journal entries", ErrInvalidJournalEntry, MaxJournalEntriesPerTrip)
+	}
+
+	now := time.Now().UTC()
+	entry := JournalEntry{
+		ID:        uuid.NewString(),
+		TripID:    itinerary.ID,
+		Day:       input.Day,
+		Date:      date,
+		Title:     input.Title,
+		Text:      input.Text,
+		PhotoIDs:  input.PhotoIDs,
+		Author:    author,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	journal.Entries = append(journal.Entries, entry)
+	if err := saveDocument(ctx, journalCollection, itinerary.ID, journal); err != nil {
+		return nil, fmt.Errorf("failed to save journal: %w", err)
+	}
+	return &entry, nil
+}
+
+// UpdateJournalEntry replaces an entry's day, title, text and photos
+func UpdateJournalEntry(ctx context.Context, itinerary *ItineraryResponse, id string, input JournalEntryInput) (*JournalEntry, error) {
+	date, err := validateJournalEntry(ctx, itinerary, &input)
+	if err != nil {
+		return nil, err
+	}
+	return modifyJournalEntry(ctx, itinerary.ID, id, func(entry *JournalEntry) error {
+		entry.Day = input.Day
+		entry.Date = date
+		entry.Title = input.Title
+		entry.Text = input.Text
+		entry.PhotoIDs = input.PhotoIDs
+		return nil
+	})
+}
+
+// AddJournalPhoto uploads a photo to the trip's attachments and adds it to an entry
+func AddJournalPhoto(ctx context.Context, itinerary *ItineraryResponse, id, uploadedBy, filename string, data []byte) (*JournalEntry, error) {
+	entry, err := GetJournalEntry(ctx, itinerary.ID, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(entry.PhotoIDs) >= MaxJournalPhotosPerEntry {
+		return nil, fmt.Errorf("%w: an entry can have at most %d photos", ErrInvalidJournalEntry, MaxJournalPhotosPerEntry)
+	}
+	if !utils.IsValidImageExtension(utils.GetFileExtension(filename)) {
+		return nil, fmt.Errorf("%w: only images (jpg, png, gif, bmp, webp) are accepted", ErrInvalidAttachment)
+	}
+
+	photo, err := SaveAttachment(ctx, itinerary.ID, uploadedBy, filename, AttachmentPhoto, data)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := modifyJournalEntry(ctx, itinerary.ID, id, func(entry *JournalEntry) error {
+		if len(entry.PhotoIDs) >= MaxJournalPhotosPerEntry {
+			return fmt.Errorf("%w: an entry can have at most %d photos", ErrInvalidJournalEntry, MaxJournalPhotosPerEntry)
+		}
+		entry.PhotoIDs = append(entry.PhotoIDs, photo.ID)
+		return nil
+	})
+	if err != nil {
+		DeleteAttachment(ctx, itinerary.ID, photo.ID)
+		return nil, err
+	}
+	return updated, nil
+}
+
+// DeleteJournalEntry removes an entry; its photos stay with the trip's attachments
+func DeleteJournalEntry(ctx context.Context, tripID, id string) (*JournalEntry, error) {
+	unlock := lockDocument(journalCollection, tripID)
+	defer unlock()
+
+	journal, err := loadTripJournal(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+	for i, entry := range journal.Entries {
+		if entry.ID != id {
+			continue
+		}
+		journal.Entries = append(journal.Entries[:i], journal.Entries[i+1:]...)
+		if err := saveDocument(ctx, journalCollection, tripID, journal); err != nil {
+			return nil, fmt.Errorf("failed to save journal: %w", err)
+		}
+		return &entry, nil
+	}
+	return nil, fmt.Errorf("journal entry %s on trip %s: %w", id, tripID, ErrDocumentNotFound)
+}
+
+// modifyJournalEntry applies change to one entry under the journal's lock
+func modifyJournalEntry(ctx context.Context, tripID, id string, change func(entry *JournalEntry) error) (*JournalEntry, error) {
+	unlock := lockDocument(journalCollection, tripID)
+	defer unlock()
+
+	journal, err := loadTripJournal(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range journal.Entries {
+		entry := &journal.Entries[i]
+		if entry.ID != id {
+			continue
+		}
+		if err := change(entry); err != nil {
+			return nil, err
+		}
+		entry.UpdatedAt = time.Now().UTC()
+		if err := saveDocument(ctx, journalCollection, tripID, journal); err != nil {
+			return nil, fmt.Errorf("failed to save journal: %w", err)
+		}
+		updated := *entry
+		return &updated, nil
+	}
+	return nil, fmt.Errorf("journal entry %s on trip %s: %w", id, tripID, ErrDocumentNotFound)
+}
+
+// validateJournalEntry tidies an entry's input and checks it against the trip, returning
+// the date of the entry's day when the trip has dates
+func validateJournalEntry(ctx context.Context, itinerary *ItineraryResponse, input *JournalEntryInput) (string, error) {
+	input.Title = strings.TrimSpace(input.Title)
+	input.Text = strings.TrimSpace(input.Text)
+	if input.PhotoIDs == nil {
+		input.PhotoIDs = []string{}
+	}
+
+	switch {
+	case input.Text == "" && len(input.PhotoIDs) == 0:
+		return "", fmt.Errorf("%w: an entry needs text or photos", ErrInvalidJournalEntry)
+	case utf8.RuneCountInString(input.Text) > MaxJournalTextRunes:
+		return "", fmt.Errorf("%w: text is longer than %d characters", ErrInvalidJournalEntry, MaxJournalTextRunes)
+	case len(input.PhotoIDs) > MaxJournalPhotosPerEntry:
+		return "", fmt.Errorf("%w: an entry can have at most %d photos", ErrInvalidJournalEntry, MaxJournalPhotosPerEntry)
+	}
+
+	days := tripDayCount(itinerary)
+	if input.Day < 1 || (days > 0 && input.Day > days) {
+		return "", fmt.Errorf("%w: day must be between 1 and %d", ErrInvalidJournalEntry, max(days, 1))
+	}
+
+	for _, photoID := range input.PhotoIDs {
+		photo, err := GetAttachment(ctx, itinerary.ID, photoID)
+		if err != nil {
+			return "", fmt.Errorf("%w: photo %s is not attached to the trip", ErrInvalidJournalEntry, photoID)
+		}
+		if photo.Quarantined || !strings.HasPrefix(photo.ContentType, "image/") {
+			return "", fmt.Errorf("%w: attachment %s is not a photo", ErrInvalidJournalEntry, photoID)
+		}
+	}
+
+	if _, start, _, ok := itineraryTripDates(itinerary); ok {
+		return start.AddDate(0, 0, input.Day-1).Format("2006-01-02"), nil
+	}
+	return "", nil
+}
+
+// tripDayCount is the number of days in a trip, or 0 when it can't be told
+func tripDayCount(itinerary *ItineraryResponse) int {
+	if _, start, end, ok := itineraryTripDates(itinerary); ok {
+		return models.DaysBetween(start, end) + 1
+	}
+	if days, ok := itinerary.Itinerary["days"].([]interface{}); ok && len(days) > 0 {
+		return len(days)
+	}
+	return itinerary.Metadata.Duration
+}
+
+// GenerateMemoriesPDF generates a post-trip keepsake: each day's plan, followed by the
+// journal entries written about it and their photos. A "journal": false customization
+// leaves the entries out, and includeImages false leaves out the photos.
+func GenerateMemoriesPDF(ctx context.Context, id, format string, includeImages bool, customization map[string]interface{}) (*PDFMetadata, error) {
+	itinerary, err := GetItinerary(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get itinerary: %w", err)
+	}
+
+	var entries []JournalEntry
+	if includeJournal, ok := customization["journal"].(bool); !ok || includeJournal {
+		if entries, err = ListJournalEntries(ctx, id, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	city, start, end, hasDates := itineraryTripDates(itinerary)
+	if city == "" {
+		city = itinerary.Metadata.City
+	}
+	dates := ""
+	if hasDates {
+		dates = fmt.Sprintf("%s to %s", start.Format("2006-01-02"), end.Format("2006-01-02"))
+	}
+
+	pdf := newStructuredPDF("Trip Memories", strings.TrimSpace(city+"  "+dates))
+	addPDFCoverPage(pdf, "Trip Memories", []string{city, dates, fmt.Sprintf("%d journal entries", len(entries))})
+	contents := newPDFContents(pdf)
+
+	plans, _ := itinerary.Itinerary["days"].([]interface{})
+	days := max(tripDayCount(itinerary), len(plans))
+	for _, entry := range entries {
+		days = max(days, entry.Day)
+	}
+
+	for day := 1; day <= days; day++ {
+		var dayEntries []JournalEntry
+		for _, entry := range entries {
+			if entry.Day == day {
+				dayEntries = append(dayEntries, entry)
+			}
+		}
+		var activities []string
+		if day <= len(plans) {
+			activities = plannedActivityNames(plans[day-1])
+		}
+		if len(dayEntries) == 0 && len(activities) == 0 {
+			continue
+		}
+
+		title := fmt.Sprintf("Day %d", day)
+		if hasDates {
+			title = fmt.Sprintf("%s - %s", title, start.AddDate(0, 0, day-1).Format("Monday, January 2"))
+		}
+		contents.section(title, 0, true)
+		pdf.SetFont("Arial", "B", 14)
+		pdf.Cell(0, 10, title)
+		pdf.Ln(12)
+
+		if len(activities) > 0 {
+			pdf.SetFont("Arial", "B", 10)
+			pdf.Cell(0, 6, "Planned:")
+			pdf.Ln(7)
+			pdf.SetFont("Arial", "", 10)
+			for _, name := range activities {
+				pdf.Cell(0, 5, "• "+name)
+				pdf.Ln(6)
+			}
+			pdf.Ln(4)
+		}
+
+		for _, entry := range dayEntries {
+			if entry.Title != "" {
+				pdf.SetFont("Arial", "B", 12)
+				pdf.Cell(0, 8, entry.Title)
+				pdf.Ln(9)
+			}
+			if entry.Text != "" {
+				pdf.SetFont("Arial", "", 10)
+				pdf.MultiCell(0, 5, entry.Text, "", "", false)
+				pdf.Ln(3)
+			}
+			if includeImages {
+				writeJournalPhotosPDF(ctx, pdf, itinerary.ID, entry.PhotoIDs)
+			}
+			pdf.Ln(4)
+		}
+	}
+
+	contents.write()
+
+	source := PDFSource{Type: "memories", ID: id, Version: itinerary.Revision}
+	return storePDF(ctx, pdf, source, customization)
+}
+
+// plannedActivityNames lists the activity names of one day of a generated itinerary
+func plannedActivityNames(plan interface{}) []string {
+	day, _ := plan.(map[string]interface{})
+	activities, _ := day["activities"].([]interface{})
+	var names []string
+	for _, activity := range activities {
+		if activity, ok := activity.(map[string]interface{}); ok {
+			if name, _ := activity["name"].(string); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// Largest size, in mm, photos print at in the memories PDF
+const (
+	journalPhotoWidth  = 120
+	journalPhotoHeight = 150
+)
+
+// writeJournalPhotosPDF prints an entry's photos from their medium previews. Photos that
+// are gone or can't be decoded are skipped rather than failing the document.
+func writeJournalPhotosPDF(ctx context.Context, pdf *pdfDocument, tripID string, photoIDs []string) {
+	for _, photoID := range photoIDs {
+		if _, err := GetAttachment(ctx, tripID, photoID); err != nil {
+			continue
+		}
+		preview, err := GetImagePreview(ctx, photoID, ImageSizeMedium)
+		if err != nil {
+			continue
+		}
+		data, err := io.ReadAll(preview.Content)
+		preview.Content.Close()
+		if err != nil {
+			continue
+		}
+		config, format, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil || config.Width == 0 || (format != "jpeg" && format != "png") {
+			continue
+		}
+		imageType := "jpg"
+		if format == "png" {
+			imageType = "png"
+		}
+
+		width := float64(journalPhotoWidth)
+		height := width * float64(config.Height) / float64(config.Width)
+		if height > journalPhotoHeight {
+			width, height = width*journalPhotoHeight/height, journalPhotoHeight
+		}
+		_, pageHeight := pdf.GetPageSize()
+		_, bottom := pdf.GetAutoPageBreak()
+		if pdf.GetY()+height > pageHeight-bottom {
+			pdf.AddPage()
+		}
+		left, _, _, _ := pdf.GetMargins()
+		drawn := pdf.DrawImage("journal-"+photoID, imageType, data, left, pdf.GetY(), width)
+		pdf.SetY(pdf.GetY() + drawn + 4)
+	}
+}
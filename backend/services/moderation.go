@@ -0,0 +1,495 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joshndala/cantrip/utils"
+)
+
+// OpenAI moderation endpoint
+const (
+	OpenAIModerationBaseURL = "https://api.openai.com/v1"
+	OpenAIModerationModel   = "omni-moderation-latest"
+)
+
+// ModerationProviderOpenAI selects the OpenAI moderation API via MODERATION_PROVIDER; the
+// default is the local rules in moderation_rules.json
+const ModerationProviderOpenAI = "openai"
+
+// ModerationLogFile is the append-only moderation incident log
+const ModerationLogFile = "data/moderation/incidents.jsonl"
+
+// Moderation actions, from least to most severe
+const (
+	ModerationAllow = "allow"
+	ModerationFlag  = "flag"  // allowed, but logged for review
+	ModerationBlock = "block" // replaced with a refusal, and counted against the session
+)
+
+// Moderation stages
+const (
+	ModerationInput  = "input"  // the user's message
+	ModerationOutput = "output" // the agent's reply
+)
+
+// openAIBlockedCategories are the OpenAI moderation categories that block rather than flag
+var openAIBlockedCategories = []string{
+	"sexual/minors", "self-harm/intent", "self-harm/instructions", "violence/graphic",
+	"hate/threatening", "harassment/threatening", "illicit/violent",
+}
+
+// ErrSessionThrottled is returned while a session is cooling down after repeated blocks
+var ErrSessionThrottled = errors.New("session is temporarily blocked")
+
+// ThrottleError tells the caller when an offending session may chat again
+type ThrottleError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottleError) Error() string {
+	return fmt.Sprintf("%v for %s", ErrSessionThrottled, e.RetryAfter.Round(time.Second))
+}
+
+func (e *ThrottleError) Unwrap() error {
+	return ErrSessionThrottled
+}
+
+// ModerationRules is moderation_rules.json
+type ModerationRules struct {
+	BlockedReply string           `json:"blocked_reply"`
+	Strikes      ModerationStrike `json:"strikes"`
+	Rules        []ModerationRule `json:"rules"`
+}
+
+// ModerationStrike limits how many blocked messages a session may send
+type ModerationStrike struct {
+	Limit           int `json:"limit"`          // blocks within the window that throttle a session
+	WindowMinutes   int `json:"window_minutes"` // how long a block counts
+	CooldownMinutes int `json:"cooldown_minutes"`
+}
+
+// ModerationRule matches harmful text with case-insensitive regular expressions
+type ModerationRule struct {
+	ID       string   `json:"id"`
+	Category string   `json:"category"`
+	Action   string   `json:"action"` // flag or block
+	Stages   []string `json:"stages"` // input, output
+	Patterns []string `json:"patterns"`
+	Reply    string   `json:"reply,omitempty"` // replaces blocked_reply for this rule
+
+	compiled []*regexp.Regexp
+}
+
+// ModerationVerdict is the outcome of moderating one message
+type ModerationVerdict struct {
+	Action     string   `json:"action"`
+	Categories []string `json:"categories,omitempty"`
+	RuleID     string   `json:"rule_id,omitempty"`
+	Provider   string   `json:"provider"`
+	Reply      string   `json:"-"` // what to answer instead of a blocked message
+}
+
+// ModerationIncident is a flagged or blocked message
+type ModerationIncident struct {
+	ID         string    `json:"id"`
+	Timestamp  time.Time `json:"timestamp"`
+	SessionID  string    `json:"session_id"`
+	UserID     string    `json:"user_id,omitempty"`
+	Stage      string    `json:"stage"`
+	Action     string    `json:"action"`
+	Categories []string  `json:"categories,omitempty"`
+	RuleID     string    `json:"rule_id,omitempty"`
+	Provider   string    `json:"provider"`
+	Excerpt    string    `json:"excerpt"`
+	Throttled  bool      `json:"throttled,omitempty"` // the block started a cooldown
+}
+
+// ModerationProvider classifies a message at a stage of the conversation
+type ModerationProvider interface {
+	Name() string
+	Moderate(ctx context.Context, text, stage string) (*ModerationVerdict, error)
+}
+
+var (
+	moderationRules sync.Map   // tenant ID -> *ModerationRules
+	moderationMu    sync.Mutex // guards sessionStrikes and the incident log
+	sessionStrikes  = map[string]*strikeRecord{}
+)
+
+// strikeRecord tracks a session's recent blocked messages
+type strikeRecord struct {
+	blocks         []time.Time
+	throttledUntil time.Time
+}
+
+// getModerationRules loads moderation_rules.json once per tenant; without it nothing is blocked
+func getModerationRules(ctx context.Context) *ModerationRules {
+	tenant := TenantFromContext(ctx).ID
+	if rules, ok := moderationRules.Load(tenant); ok {
+		return rules.(*ModerationRules)
+	}
+	rules, err := loadModerationRules(ctx)
+	if err != nil {
+		utils.LogError("Failed to load moderation rules", err)
+		rules = &ModerationRules{}
+	}
+	cached, _ := moderationRules.LoadOrStore(tenant, rules)
+	return cached.(*ModerationRules)
+}
+
+// loadModerationRules loads and compiles moderation_rules.json
+func loadModerationRules(ctx context.Context) (*ModerationRules, error) {
+	data, err := ReadDataset(ctx, ModerationRulesDataset)
+	if err != nil {
+		return nil, err
+	}
+	var rules ModerationRules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+	for i := range rules.Rules {
+		rule := &rules.Rules[i]
+		for _, pattern := range rule.Patterns {
+			re, err := regexp.Compile("(?i)" + pattern)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: %w", rule.ID, err)
+			}
+			rule.compiled = append(rule.compiled, re)
+		}
+	}
+	return &rules, nil
+}
+
+// newModerationProvider uses the OpenAI moderation API when MODERATION_PROVIDER selects it,
+// and the local rules otherwise
+func newModerationProvider() ModerationProvider {
+	local := &localModerationProvider{}
+	if apiKey := os.Getenv("OPENAI_API_KEY"); apiKey != "" && os.Getenv("MODERATION_PROVIDER") == ModerationProviderOpenAI {
+		return &OpenAIModerationClient{
+			APIKey:     apiKey,
+			BaseURL:    OpenAIModerationBaseURL,
+			Model:      OpenAIModerationModel,
+			HTTPClient: upstreamHTTPClient(),
+			Fallback:   local,
+		}
+	}
+	return local
+}
+
+// ChatThrottleRemaining is how long a session must wait before chatting again, zero when
+// it isn't throttled
+func ChatThrottleRemaining(sessionID string) time.Duration {
+	return max(throttleRemaining(sessionID), 0)
+}
+
+// ModerateChatInput checks a user's message before it reaches the agent. A throttled
+// session gets a *ThrottleError; a blocked message gets the verdict, whose Reply should be
+// sent instead of asking the agent.
+func ModerateChatInput(ctx context.Context, session *ConversationSession, text string) (*ModerationVerdict, error) {
+	if wait := throttleRemaining(session.SessionID); wait > 0 {
+		return nil, &ThrottleError{RetryAfter: wait}
+	}
+
+	verdict := moderate(ctx, text, ModerationInput)
+	if verdict.Action == ModerationAllow {
+		return verdict, nil
+	}
+
+	incident := newModerationIncident(session, text, ModerationInput, verdict)
+	if verdict.Action == ModerationBlock {
+		incident.Throttled = addStrike(ctx, session.SessionID)
+	}
+	recordModerationIncident(ctx, incident)
+	return verdict, nil
+}
+
+// ModerateChatReply checks the agent's reply, returning the text to send: the reply itself,
+// or a refusal when it is blocked. Blocked replies don't count against the session.
+func ModerateChatReply(ctx context.Context, session *ConversationSession, reply string) (string, *ModerationVerdict) {
+	verdict := moderate(ctx, reply, ModerationOutput)
+	if verdict.Action == ModerationAllow {
+		return reply, verdict
+	}
+
+	recordModerationIncident(ctx, newModerationIncident(session, reply, ModerationOutput, verdict))
+	if verdict.Action == ModerationBlock {
+		return verdict.Reply, verdict
+	}
+	return reply, verdict
+}
+
+// moderate runs the provider, allowing the message when it fails
+func moderate(ctx context.Context, text, stage string) *ModerationVerdict {
+	provider := GetModerationProvider()
+	verdict, err := provider.Moderate(ctx, text, stage)
+	if err != nil {
+		utils.LogError("Failed to moderate chat message", err)
+		return &ModerationVerdict{Action: ModerationAllow, Provider: provider.Name()}
+	}
+	verdict.Provider = provider.Name()
+	if verdict.Action == ModerationBlock && verdict.Reply == "" {
+		verdict.Reply = getModerationRules(ctx).BlockedReply
+	}
+	return verdict
+}
+
+// throttleRemaining is how long a session must still wait before chatting again
+func throttleRemaining(sessionID string) time.Duration {
+	moderationMu.Lock()
+	defer moderationMu.Unlock()
+	if record, ok := sessionStrikes[sessionID]; ok {
+		return time.Until(record.throttledUntil)
+	}
+	return 0
+}
+
+// addStrike counts a blocked message against a session, starting a cooldown when it
+// reaches the limit within the window, and reports whether it did
+func addStrike(ctx context.Context, sessionID string) bool {
+	limits := getModerationRules(ctx).Strikes
+	if limits.Limit <= 0 {
+		return false
+	}
+	now := time.Now()
+
+	moderationMu.Lock()
+	defer moderationMu.Unlock()
+
+	record := sessionStrikes[sessionID]
+	if record == nil {
+		record = &strikeRecord{}
+		sessionStrikes[sessionID] = record
+	}
+
+	window := now.Add(-time.Duration(limits.WindowMinutes) * time.Minute)
+	recent := record.blocks[:0]
+	for _, at := range record.blocks {
+		if at.After(window) {
+			recent = append(recent, at)
+		}
+	}
+	record.blocks = append(recent, now)
+
+	if len(record.blocks) < limits.Limit {
+		return false
+	}
+	record.blocks = nil
+	record.throttledUntil = now.Add(time.Duration(limits.CooldownMinutes) * time.Minute)
+	return true
+}
+
+func newModerationIncident(session *ConversationSession, text, stage string, verdict *ModerationVerdict) ModerationIncident {
+	return ModerationIncident{
+		ID:         uuid.New().String(),
+		Timestamp:  time.Now().UTC(),
+		SessionID:  session.SessionID,
+		UserID:     session.UserID,
+		Stage:      stage,
+		Action:     verdict.Action,
+		Categories: verdict.Categories,
+		RuleID:     verdict.RuleID,
+		Provider:   verdict.Provider,
+		Excerpt:    utils.TruncateString(text, 200),
+	}
+}
+
+// recordModerationIncident appends an incident to the moderation log
+func recordModerationIncident(ctx context.Context, incident ModerationIncident) {
+	line, err := json.Marshal(incident)
+	if err != nil {
+		utils.LogError("Failed to marshal moderation incident", err)
+		return
+	}
+
+	moderationMu.Lock()
+	defer moderationMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(tenantDataFile(ctx, ModerationLogFile)), 0755); err != nil {
+		utils.LogError("Failed to create moderation directory", err)
+		return
+	}
+	file, err := os.OpenFile(tenantDataFile(ctx, ModerationLogFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		utils.LogError("Failed to open moderation log", err)
+		return
+	}
+	defer file.Close()
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		utils.LogError("Failed to write moderation incident", err)
+	}
+}
+
+// ListModerationIncidents returns logged incidents, optionally only one action, newest first
+func ListModerationIncidents(ctx context.Context, action string) ([]ModerationIncident, error) {
+	incidents := []ModerationIncident{}
+	file, err := os.Open(tenantDataFile(ctx, ModerationLogFile))
+	if os.IsNotExist(err) {
+		return incidents, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open moderation log: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var incident ModerationIncident
+		if err := json.Unmarshal(scanner.Bytes(), &incident); err != nil {
+			continue
+		}
+		if action != "" && incident.Action != action {
+			continue
+		}
+		incidents = append(incidents, incident)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read moderation log: %w", err)
+	}
+
+	for i, j := 0, len(incidents)-1; i < j; i, j = i+1, j-1 {
+		incidents[i], incidents[j] = incidents[j], incidents[i]
+	}
+	return incidents, nil
+}
+
+// localModerationProvider applies the regular expressions in moderation_rules.json
+type localModerationProvider struct{}
+
+func (p *localModerationProvider) Name() string {
+	return "rules"
+}
+
+// Moderate returns the most severe matching rule's verdict
+func (p *localModerationProvider) Moderate(ctx context.Context, text, stage string) (*ModerationVerdict, error) {
+	verdict := &ModerationVerdict{Action: ModerationAllow}
+	for _, rule := range getModerationRules(ctx).Rules {
+		if !utils.Contains(rule.Stages, stage) || !rule.matches(text) {
+			continue
+		}
+		if !utils.Contains(verdict.Categories, rule.Category) {
+			verdict.Categories = append(verdict.Categories, rule.Category)
+		}
+		if verdict.Action != ModerationBlock {
+			verdict.Action, verdict.RuleID, verdict.Reply = rule.Action, rule.ID, rule.Reply
+		}
+	}
+	return verdict, nil
+}
+
+func (r *ModerationRule) matches(text string) bool {
+	for _, re := range r.compiled {
+		if re.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenAIModerationClient is a ModerationProvider backed by the OpenAI moderation API. The
+// local rules still apply on top, and alone when the API fails.
+type OpenAIModerationClient struct {
+	APIKey     string
+	BaseURL    string
+	Model      string
+	HTTPClient *http.Client
+	Fallback   ModerationProvider
+}
+
+func (c *OpenAIModerationClient) Name() string {
+	return ModerationProviderOpenAI
+}
+
+func (c *OpenAIModerationClient) Moderate(ctx context.Context, text, stage string) (*ModerationVerdict, error) {
+	local, _ := c.Fallback.Moderate(ctx, text, stage)
+	if local != nil && local.Action == ModerationBlock {
+		return local, nil
+	}
+
+	verdict, err := c.moderate(ctx, text)
+	if err != nil {
+		utils.LogError("OpenAI moderation failed, using local rules", err)
+		return local, nil
+	}
+	if verdict.Action == ModerationAllow && local != nil {
+		return local, nil
+	}
+	return verdict, nil
+}
+
+func (c *OpenAIModerationClient) moderate(ctx context.Context, text string) (*ModerationVerdict, error) {
+	payload, err := json.Marshal(map[string]string{"model": c.Model, "input": text})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/moderations", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OpenAI moderation request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch from OpenAI moderation: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OpenAI moderation API returned status: %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Results []struct {
+			Flagged    bool            `json:"flagged"`
+			Categories map[string]bool `json:"categories"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode OpenAI moderation response: %w", err)
+	}
+
+	verdict := &ModerationVerdict{Action: ModerationAllow}
+	for _, r := range result.Results {
+		if !r.Flagged {
+			continue
+		}
+		verdict.Action = ModerationFlag
+		for category, hit := range r.Categories {
+			if !hit {
+				continue
+			}
+			verdict.Categories = append(verdict.Categories, category)
+			if utils.Contains(openAIBlockedCategories, category) {
+				verdict.Action = ModerationBlock
+			}
+		}
+	}
+	for _, category := range verdict.Categories {
+		if verdict.Action == ModerationBlock && strings.HasPrefix(category, "self-harm") {
+			verdict.Reply = selfHarmReply(ctx)
+		}
+	}
+	return verdict, nil
+}
+
+// selfHarmReply is the self-harm rule's reply, so both providers answer with crisis resources
+func selfHarmReply(ctx context.Context) string {
+	for _, rule := range getModerationRules(ctx).Rules {
+		if rule.Category == "self_harm" && rule.Reply != "" {
+			return rule.Reply
+		}
+	}
+	return ""
+}
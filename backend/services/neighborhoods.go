@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Transit access levels for a neighborhood
+const (
+	TransitExcellent = "excellent"  // rapid transit within walking distance
+	TransitGood      = "good"       // frequent buses or streetcars
+	TransitLimited   = "limited"    // infrequent service, a car helps
+	TransitCarNeeded = "car_needed" // no practical public transit
+)
+
+// TransitAccessLevels lists the valid transit access levels
+var TransitAccessLevels = []string{TransitExcellent, TransitGood, TransitLimited, TransitCarNeeded}
+
+// Neighborhood describes a neighborhood and its character
+type Neighborhood struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	Vibes       []string      `json:"vibes"` // e.g. nightlife, family, foodie
+	SafetyNotes string        `json:"safety_notes,omitempty"`
+	Transit     TransitAccess `json:"transit"`
+}
+
+// TransitAccess describes how well a neighborhood is served by public transit
+type TransitAccess struct {
+	Access string `json:"access,omitempty"`
+	Notes  string `json:"notes,omitempty"`
+}
+
+// UnmarshalJSON accepts either a neighborhood object or a plain name, so
+// older datasets and imported cities keep loading
+func (n *Neighborhood) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*n = Neighborhood{Name: name, Vibes: []string{}}
+		return nil
+	}
+
+	type plain Neighborhood
+	var decoded plain
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	if decoded.Vibes == nil {
+		decoded.Vibes = []string{}
+	}
+	*n = Neighborhood(decoded)
+	return nil
+}
+
+// HasVibe reports whether the neighborhood is tagged with a vibe
+func (n Neighborhood) HasVibe(vibe string) bool {
+	for _, v := range n.Vibes {
+		if strings.EqualFold(v, vibe) {
+			return true
+		}
+	}
+	return false
+}
+
+// MoodNeighborhoodVibes maps moods to the neighborhood vibes that suit them
+var MoodNeighborhoodVibes = map[string][]string{
+	"excited":     {"nightlife", "shopping", "arts"},
+	"relaxed":     {"quiet", "waterfront", "outdoors"},
+	"adventurous": {"outdoors", "arts", "budget"},
+	"romantic":    {"romantic", "foodie", "upscale"},
+	"family":      {"family", "outdoors", "quiet"},
+	"cultural":    {"historic", "arts", "foodie"},
+	"party":       {"nightlife", "lgbtq", "student"},
+	"educational": {"historic", "arts", "student"},
+}
+
+// GetNeighborhoods returns the neighborhoods of a city, optionally filtered by vibe
+func GetNeighborhoods(ctx context.Context, city, vibe string) ([]Neighborhood, error) {
+	metadata, err := loadCityMetadata(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load city metadata: %w", err)
+	}
+
+	cityData, err := findCity(metadata, city)
+	if err != nil {
+		return nil, err
+	}
+
+	neighborhoods := []Neighborhood{}
+	for _, neighborhood := range cityData.Neighborhoods {
+		if vibe == "" || neighborhood.HasVibe(vibe) {
+			neighborhoods = append(neighborhoods, neighborhood)
+		}
+	}
+
+	return neighborhoods, nil
+}
+
+// neighborhoodsForMood ranks a city's neighborhoods by how well their vibes suit a mood.
+// Only matching neighborhoods are returned; all of them are returned if the mood is unknown
+// or nothing matches.
+func neighborhoodsForMood(cityData *City, mood string) []Neighborhood {
+	vibes := MoodNeighborhoodVibes[strings.ToLower(mood)]
+	if len(vibes) == 0 {
+		return cityData.Neighborhoods
+	}
+
+	type scored struct {
+		neighborhood Neighborhood
+		score        int
+	}
+	var matches []scored
+	for _, neighborhood := range cityData.Neighborhoods {
+		score := 0
+		for i, vibe := range vibes {
+			if neighborhood.HasVibe(vibe) {
+				// Earlier vibes are the strongest signal for the mood
+				score += len(vibes) - i
+			}
+		}
+		if score > 0 {
+			matches = append(matches, scored{neighborhood, score})
+		}
+	}
+	if len(matches) == 0 {
+		return cityData.Neighborhoods
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	neighborhoods := make([]Neighborhood, len(matches))
+	for i, match := range matches {
+		neighborhoods[i] = match.neighborhood
+	}
+	return neighborhoods
+}
+
+// neighborhoodNames returns the names of the given neighborhoods
+func neighborhoodNames(neighborhoods []Neighborhood) []string {
+	names := make([]string, len(neighborhoods))
+	for i, neighborhood := range neighborhoods {
+		names[i] = neighborhood.Name
+	}
+	return names
+}
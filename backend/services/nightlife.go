@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joshndala/cantrip/utils"
+)
+
+// Nightlife venue types
+const (
+	VenueBar       = "bar"
+	VenueClub      = "club"
+	VenueLiveMusic = "live_music"
+	VenueLateFood  = "late_food"
+)
+
+// Evening itinerary slots for nightlife
+const (
+	NightlifeSlot     = "21:30"
+	LateNightFoodSlot = "23:30"
+)
+
+// NightlifeData represents the structure of nightlife.json
+type NightlifeData struct {
+	DrinkingAge map[string]int              `json:"drinking_age"` // by province, with a default
+	LastCall    map[string]string           `json:"last_call"`    // by province, with a default
+	Tips        []string                    `json:"tips"`
+	Cities      map[string][]NightlifeVenue `json:"cities"`
+}
+
+// NightlifeVenue is a bar, club, live music venue or late-night food spot
+type NightlifeVenue struct {
+	Name           string   `json:"name"`
+	Type           string   `json:"type"`
+	Neighborhood   string   `json:"neighborhood"`
+	Opens          string   `json:"opens"`        // HH:MM
+	Closes         string   `json:"closes"`       // HH:MM, after midnight when earlier than opens
+	TypicalCost    float64  `json:"typical_cost"` // per person for cover and a couple of drinks or a meal
+	Tags           []string `json:"tags"`
+	AgeRestriction string   `json:"age_restriction,omitempty"` // e.g. "19+", set from the province
+}
+
+// NightlifeCluster groups venues in one neighborhood for a night out
+type NightlifeCluster struct {
+	Neighborhood string           `json:"neighborhood"`
+	Venues       []NightlifeVenue `json:"venues"`
+}
+
+// NightlifeGuide is the nightlife for a city
+type NightlifeGuide struct {
+	City        string             `json:"city"`
+	Province    string             `json:"province,omitempty"`
+	DrinkingAge int                `json:"drinking_age"`
+	LastCall    string             `json:"last_call"`
+	Clusters    []NightlifeCluster `json:"clusters"`
+	Tips        []string           `json:"tips"`
+}
+
+// ErrNoNightlife is returned for a city without nightlife data
+var ErrNoNightlife = errors.New("no nightlife information for destination")
+
+// ErrInvalidNightlifeQuery is returned for an unknown venue type or malformed time
+var ErrInvalidNightlifeQuery = errors.New("invalid nightlife query")
+
+// loadNightlife loads the nightlife dataset
+func loadNightlife(ctx context.Context) (*NightlifeData, error) {
+	data, err := ReadDataset(ctx, NightlifeDataset)
+	if err != nil {
+		return nil, err
+	}
+
+	var nightlife NightlifeData
+	if err := json.Unmarshal(data, &nightlife); err != nil {
+		return nil, err
+	}
+	return &nightlife, nil
+}
+
+// GetNightlife returns a city's venues grouped by neighborhood, with the province's drinking age.
+// venueType limits the venues to one type, and openAt (HH:MM) to venues open at that time.
+func GetNightlife(ctx context.Context, city, venueType, openAt string) (*NightlifeGuide, error) {
+	if venueType != "" && !utils.Contains([]string{VenueBar, VenueClub, VenueLiveMusic, VenueLateFood}, venueType) {
+		return nil, fmt.Errorf("%w: type must be bar, club, live_music or late_food", ErrInvalidNightlifeQuery)
+	}
+	if openAt != "" {
+		if _, err := time.Parse("15:04", openAt); err != nil {
+			return nil, fmt.Errorf("%w: open_at must be HH:MM", ErrInvalidNightlifeQuery)
+		}
+	}
+
+	data, err := loadNightlife(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load nightlife: %w", err)
+	}
+
+	var venues []NightlifeVenue
+	var name string
+	for cityName, cityVenues := range data.Cities {
+		if strings.EqualFold(cityName, city) {
+			name, venues = cityName, cityVenues
+			break
+		}
+	}
+	if venues == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoNightlife, city)
+	}
+
+	guide := &NightlifeGuide{City: name, Tips: data.Tips}
+	if metadata, err := loadCityMetadata(ctx); err == nil {
+		if cityData, err := findCity(metadata, name); err == nil {
+			guide.Province = cityData.Province
+		}
+	}
+	guide.DrinkingAge = provinceValue(data.DrinkingAge, guide.Province)
+	guide.LastCall = provinceValue(data.LastCall, guide.Province)
+
+	var filtered []NightlifeVenue
+	for _, venue := range venues {
+		if venueType != "" && venue.Type != venueType {
+			continue
+		}
+		if openAt != "" && !venueOpenAt(venue, openAt) {
+			continue
+		}
+		if venue.Type != VenueLateFood {
+			venue.AgeRestriction = fmt.Sprintf("%d+", guide.DrinkingAge)
+		}
+		filtered = append(filtered, venue)
+	}
+	guide.Clusters = clusterVenues(filtered)
+
+	return guide, nil
+}
+
+// provinceValue looks up a per-province value, falling back to the "default" entry
+func provinceValue[T any](values map[string]T, province string) T {
+	if value, ok := values[province]; ok {
+		return value
+	}
+	return values["default"]
+}
+
+// clusterVenues groups venues by neighborhood, busiest neighborhoods first
+func clusterVenues(venues []NightlifeVenue) []NightlifeCluster {
+	clusters := []NightlifeCluster{}
+	index := make(map[string]int)
+	for _, venue := range venues {
+		i, ok := index[venue.Neighborhood]
+		if !ok {
+			i = len(clusters)
+			index[venue.Neighborhood] = i
+			clusters = append(clusters, NightlifeCluster{Neighborhood: venue.Neighborhood})
+		}
+		clusters[i].Venues = append(clusters[i].Venues, venue)
+	}
+
+	sort.SliceStable(clusters, func(i, j int) bool {
+		return len(clusters[i].Venues) > len(clusters[j].Venues)
+	})
+	return clusters
+}
+
+// venueOpenAt reports whether a venue is open at a time of day, including hours past midnight
+func venueOpenAt(venue NightlifeVenue, at string) bool {
+	if venue.Closes < venue.Opens {
+		return at >= venue.Opens || at < venue.Closes
+	}
+	return at >= venue.Opens && at < venue.Closes
+}
+
+// nightlifeTripSuggestion builds a party-mood suggestion from the city's busiest nightlife neighborhoods
+func nightlifeTripSuggestion(ctx context.Context, cityData *City, budget float64, duration int) (TripSuggestion, bool) {
+	guide, err := GetNightlife(ctx, cityData.Name, "", "")
+	if err != nil || len(guide.Clusters) == 0 {
+		return TripSuggestion{}, false
+	}
+
+	var activities []string
+	neighborhoods := make([]string, 0, len(guide.Clusters))
+	for _, cluster := range guide.Clusters {
+		names := make([]string, 0, len(cluster.Venues))
+		for _, venue := range cluster.Venues {
+			names = append(names, venue.Name)
+		}
+		activities = append(activities, fmt.Sprintf("Night out in %s: %s", cluster.Neighborhood, strings.Join(names, ", ")))
+		neighborhoods = append(neighborhoods, cluster.Neighborhood)
+	}
+
+	return TripSuggestion{
+		Title:         fmt.Sprintf("Nights Out in %s", cityData.Name),
+		Description:   fmt.Sprintf("Bars, clubs and live music in %s, with late-night food to finish. Venues are %d+.", strings.Join(neighborhoods, ", "), guide.DrinkingAge),
+		Activities:    activities,
+		EstimatedCost: estimateCost(ctx, CostNightlife, budget, duration, cityData.Name),
+		Duration:      duration,
+		Tags:          []string{"nightlife", "music", "party", "entertainment"},
+	}, true
+}
+
+// WantsNightlife reports whether a trip's interests call for nights out
+func WantsNightlife(interests []string) bool {
+	for _, interest := range interests {
+		if strings.EqualFold(interest, "nightlife") || strings.EqualFold(interest, "party") {
+			return true
+		}
+	}
+	return false
+}
+
+// ScheduleNightlife adds an evening out to each day of the itinerary, rotating through
+// the city's nightlife neighborhoods and ending with late-night food nearby
+func ScheduleNightlife(ctx context.Context, resp *ItineraryResponse) {
+	if resp == nil || resp.Itinerary == nil {
+		return
+	}
+	city, _ := resp.Itinerary["city"].(string)
+	if city == "" {
+		city = resp.Metadata.City
+	}
+	guide, err := GetNightlife(ctx, city, "", "")
+	if err != nil || len(guide.Clusters) == 0 {
+		return
+	}
+
+	days, _ := resp.Itinerary["days"].([]interface{})
+	for i, rawDay := range days {
+		day, ok := rawDay.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		cluster := guide.Clusters[i%len(guide.Clusters)]
+		activities, _ := day["activities"].([]interface{})
+
+		if venue, ok := pickVenue(cluster, NightlifeSlot, func(v NightlifeVenue) bool { return v.Type != VenueLateFood }, i); ok {
+			activities = append(activities, nightlifeActivity(venue, city, NightlifeSlot, 2))
+		}
+		if venue, ok := pickVenue(cluster, LateNightFoodSlot, func(v NightlifeVenue) bool { return v.Type == VenueLateFood }, i); ok {
+			activities = append(activities, nightlifeActivity(venue, city, LateNightFoodSlot, 1))
+		}
+		day["activities"] = activities
+		day["nightlife_note"] = fmt.Sprintf("Night out in %s. Bring photo ID: the drinking age is %d and last call is %s.", cluster.Neighborhood, guide.DrinkingAge, guide.LastCall)
+	}
+}
+
+// pickVenue chooses a venue in the cluster that is open at the slot, varying the choice by day
+func pickVenue(cluster NightlifeCluster, slot string, match func(NightlifeVenue) bool, day int) (NightlifeVenue, bool) {
+	var candidates []NightlifeVenue
+	for _, venue := range cluster.Venues {
+		if match(venue) && venueOpenAt(venue, slot) {
+			candidates = append(candidates, venue)
+		}
+	}
+	if len(candidates) == 0 {
+		return NightlifeVenue{}, false
+	}
+	return candidates[day%len(candidates)], true
+}
+
+// nightlifeActivity converts a venue into an itinerary activity
+func nightlifeActivity(venue NightlifeVenue, city, slot string, hours int) map[string]interface{} {
+	activity := map[string]interface{}{
+		"name":       venue.Name,
+		"start_time": slot,
+		"end_time":   addMockHours(slot, hours),
+		"location":   fmt.Sprintf("%s, %s, %s", venue.Name, venue.Neighborhood, city),
+		"cost":       venue.TypicalCost,
+		"category":   "nightlife",
+		"closes":     venue.Closes,
+	}
+	if venue.AgeRestriction != "" {
+		activity["age_restriction"] = venue.AgeRestriction
+	}
+	return activity
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/joshndala/cantrip/utils"
+)
+
+// NotificationCollection stores each user's notification inbox
+const NotificationCollection = "notifications"
+
+// Notification types
+const (
+	NotificationEventPriceDrop    = "event_price_drop"
+	NotificationEventAvailability = "event_availability"
+)
+
+// Notification is a message delivered to a user through every configured channel
+type Notification struct {
+	ID        string            `json:"id"`
+	UserID    string            `json:"user_id"`
+	Type      string            `json:"type"`
+	Title     string            `json:"title"`
+	Body      string            `json:"body"`
+	Data      map[string]string `json:"data,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	ReadAt    *time.Time        `json:"read_at,omitempty"`
+}
+
+// Notifier delivers notifications over a single channel
+type Notifier interface {
+	Name() string
+	Send(ctx context.Context, notification Notification) error
+}
+
+var (
+	notifiersMu sync.RWMutex
+	notifiers   []Notifier
+	notifyOnce  sync.Once
+)
+
+// RegisterNotifier adds a delivery channel
+func RegisterNotifier(notifier Notifier) {
+	notifiersMu.Lock()
+	defer notifiersMu.Unlock()
+	notifiers = append(notifiers, notifier)
+}
+
+// registeredNotifiers returns the delivery channels, configuring them from the environment on first use
+func registeredNotifiers() []Notifier {
+	notifyOnce.Do(func() {
+		if url := os.Getenv("NOTIFICATION_WEBHOOK_URL"); url != "" {
+			RegisterNotifier(&WebhookNotifier{URL: url, HTTPClient: &http.Client{Timeout: 10 * time.Second}})
+		}
+		if os.Getenv("FCM_PROJECT_ID") != "" {
+			fcm, err := NewFCMNotifierFromEnv(context.Background())
+			if err != nil {
+				utils.LogError("Push notifications disabled", err)
+			} else {
+				RegisterNotifier(fcm)
+			}
+		}
+	})
+
+	notifiersMu.RLock()
+	defer notifiersMu.RUnlock()
+	return append([]Notifier{}, notifiers...)
+}
+
+// Notify stores a notification in the user's inbox and delivers it through every channel
+// the user's preferences allow.
+// Delivery failures are logged; only failing to store the notification is an error.
+func Notify(ctx context.Context, notification Notification) (*Notification, error) {
+	if notification.UserID == "" {
+		return nil, fmt.Errorf("notification has no recipient")
+	}
+	if notification.ID == "" {
+		notification.ID = utils.GenerateID()
+	}
+	if notification.CreatedAt.IsZero() {
+		notification.CreatedAt = time.Now().UTC()
+	}
+
+	if err := saveDocument(ctx, NotificationCollection, notification.ID, notification); err != nil {
+		return nil, fmt.Errorf("failed to store notification: %w", err)
+	}
+
+	prefs := GetNotificationPreferences(ctx, notification.UserID)
+	for _, notifier := range registeredNotifiers() {
+		if !prefs.allows(notifier.Name(), notification.Type, notification.CreatedAt) {
+			continue
+		}
+		if err := notifier.Send(ctx, notification); err != nil {
+			utils.LogError(fmt.Sprintf("Failed to deliver notification %s via %s", notification.ID, notifier.Name()), err)
+		}
+	}
+
+	utils.LogInfo(fmt.Sprintf("Notified %s: %s", notification.UserID, notification.Title))
+	return &notification, nil
+}
+
+// ListNotifications returns a user's notifications, newest first
+func ListNotifications(ctx context.Context, userID string, unreadOnly bool) ([]Notification, error) {
+	ids, err := listDocumentIDs(ctx, NotificationCollection)
+	if err != nil {
+		return nil, err
+	}
+
+	notifications := []Notification{}
+	for _, id := range ids {
+		var notification Notification
+		if err := loadDocument(ctx, NotificationCollection, id, &notification); err != nil {
+			continue
+		}
+		if notification.UserID != userID || (unreadOnly && notification.ReadAt != nil) {
+			continue
+		}
+		notifications = append(notifications, notification)
+	}
+
+	sort.Slice(notifications, func(i, j int) bool {
+		return notifications[i].CreatedAt.After(notifications[j].CreatedAt)
+	})
+	return notifications, nil
+}
+
+// MarkNotificationRead marks one of a user's notifications as read
+func MarkNotificationRead(ctx context.Context, userID, id string) (*Notification, error) {
+	var notification Notification
+	if err := loadDocument(ctx, NotificationCollection, id, &notification); err != nil {
+		return nil, err
+	}
+	if notification.UserID != userID {
+		return nil, fmt.Errorf("%s/%s: %w", NotificationCollection, id, ErrDocumentNotFound)
+	}
+
+	if notification.ReadAt == nil {
+		now := time.Now().UTC()
+		notification.ReadAt = &now
+		if err := saveDocument(ctx, NotificationCollection, id, notification); err != nil {
+			return nil, err
+		}
+	}
+	return &notification, nil
+}
+
+// WebhookNotifier posts notifications as JSON to an HTTP endpoint
+type WebhookNotifier struct {
+	URL        string
+	HTTPClient *http.Client
+}
+
+// Name identifies the channel
+func (w *WebhookNotifier) Name() string {
+	return "webhook"
+}
+
+// Send posts the notification to the webhook
+func (w *WebhookNotifier) Send(ctx context.Context, notification Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// ReminderHook sends one kind of scheduled reminder and returns how many were sent
+type ReminderHook struct {
+	Name string
+	Send func(ctx context.Context) (int, error)
+}
+
+var (
+	reminderHooksMu sync.RWMutex
+	reminderHooks   = []ReminderHook{
+		{Name: "medication refill", Send: SendDueRefillReminders},
+		{Name: "travel insurance", Send: SendInsuranceReminders},
+		{Name: "daily agenda", Send: SendDailyAgendas},
+	}
+)
+
+// RegisterReminderHook adds a reminder to the scheduler
+func RegisterReminderHook(hook ReminderHook) {
+	reminderHooksMu.Lock()
+	defer reminderHooksMu.Unlock()
+	reminderHooks = append(reminderHooks, hook)
+}
+
+// SendReminders runs every reminder hook once. A failing hook is logged and doesn't stop the others.
+func SendReminders(ctx context.Context) int {
+	reminderHooksMu.RLock()
+	hooks := append([]ReminderHook{}, reminderHooks...)
+	reminderHooksMu.RUnlock()
+
+	total := 0
+	for _, hook := range hooks {
+		sent, err := hook.Send(ctx)
+		if err != nil {
+			utils.LogError(fmt.Sprintf("%s reminder check failed", hook.Name), err)
+			continue
+		}
+		if sent > 0 {
+			utils.LogInfo(fmt.Sprintf("Sent %d %s reminders", sent, hook.Name))
+		}
+		total += sent
+	}
+	return total
+}
+
+// StartReminderScheduler runs SendReminders in the background at the given interval
+func StartReminderScheduler(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			ForEachTenant(context.Background(), func(ctx context.Context) { SendReminders(ctx) })
+		}
+	}()
+}
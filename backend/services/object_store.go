@@ -0,0 +1,474 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joshndala/cantrip/utils"
+)
+
+// Object store backends, selected with OBJECT_STORE when no GCS bucket is configured
+const (
+	ObjectStoreLocal = "local" // filesystem emulator, the default
+	ObjectStoreNone  = "none"  // local fallbacks only
+)
+
+// LocalObjectURLPrefix is the route serving local signed URLs
+const LocalObjectURLPrefix = "/api/v1/objects/"
+
+// ObjectStore is the blob storage API for documents and PDFs. GCSClient implements it
+// against a bucket, and LocalObjectStore against a directory for development and CI.
+type ObjectStore interface {
+	UploadFile(ctx context.Context, objectName string, data []byte, contentType string) error
+	UploadFileFromPath(ctx context.Context, localPath, objectName string) error
+	DownloadFile(ctx context.Context, objectName string) ([]byte, error)
+	NewRangeReader(ctx context.Context, objectName string, offset, length int64) (io.ReadCloser, error)
+	GetFileInfo(ctx context.Context, objectName string) (*FileInfo, error)
+	ListFiles(ctx context.Context, prefix string) ([]FileInfo, error)
+	DeleteFile(ctx context.Context, objectName string) error
+	FileExists(ctx context.Context, objectName string) (bool, error)
+	GenerateSignedURL(ctx context.Context, objectName string, expiration time.Duration) (string, error)
+	GenerateSignedURLWithParams(ctx context.Context, objectName string, expiration time.Duration, params url.Values) (string, error)
+	UploadJSON(ctx context.Context, objectName string, data interface{}) error
+	DownloadJSON(ctx context.Context, objectName string, target interface{}) error
+}
+
+// ErrObjectNotFound is returned by the local store for a missing object
+var ErrObjectNotFound = errors.New("object not found")
+
+// ErrInvalidObjectSignature is returned for a local signed URL that is forged or expired
+var ErrInvalidObjectSignature = errors.New("invalid or expired object signature")
+
+var (
+	localStoreOnce sync.Once
+	localStore     *LocalObjectStore
+)
+
+// GetObjectStore returns the GCS bucket when configured, otherwise the local emulator
+// unless OBJECT_STORE=none. Callers fall back to local files when it returns nil. Object
+// names are placed under the storage prefix of the tenant each call's context acts for.
+func GetObjectStore() ObjectStore {
+	if client := GetGCSClient(); client != nil {
+		return tenantObjectStore{traceObjectStore(client, "gcs")}
+	}
+	if store := GetLocalObjectStore(); store != nil {
+		return tenantObjectStore{traceObjectStore(store, ObjectStoreLocal)}
+	}
+	return nil
+}
+
+// GetLocalObjectStore returns the filesystem emulator, or nil when OBJECT_STORE disables it.
+// Objects live under OBJECT_STORE_DIR, by default the data directory itself, so
+// "pdfs/x.pdf" is the same file as the local PDF and documents keep their usual paths.
+func GetLocalObjectStore() *LocalObjectStore {
+	localStoreOnce.Do(func() {
+		mode := os.Getenv("OBJECT_STORE")
+		if mode == "" {
+			mode = ObjectStoreLocal
+		}
+		if mode != ObjectStoreLocal {
+			if mode != ObjectStoreNone {
+				utils.LogWarning("Unknown OBJECT_STORE " + mode + ", using local files only")
+			}
+			return
+		}
+
+		root := os.Getenv("OBJECT_STORE_DIR")
+		if root == "" {
+			root = "data"
+		}
+		localStore = NewLocalObjectStore(root, localSigningKey(root))
+	})
+	return localStore
+}
+
+var (
+	dataDirStoreOnce sync.Once
+	dataDirStore     ObjectStore
+)
+
+// dataDirObjectStore keeps objects under the data directory for callers that need a store
+// when OBJECT_STORE disables the emulator. Like the shared store it's scoped to the tenant
+// and signs with the persistent key.
+func dataDirObjectStore() ObjectStore {
+	dataDirStoreOnce.Do(func() {
+		store := NewLocalObjectStore("data", localSigningKey("data"))
+		dataDirStore = tenantObjectStore{traceObjectStore(store, ObjectStoreLocal)}
+	})
+	return dataDirStore
+}
+
+// localSigningKey is OBJECT_STORE_SIGNING_KEY, or the key persisted for root
+func localSigningKey(root string) []byte {
+	if key := os.Getenv("OBJECT_STORE_SIGNING_KEY"); key != "" {
+		return []byte(key)
+	}
+	key, err := persistentSigningKey(root)
+	if err != nil {
+		utils.LogWarning(fmt.Sprintf("Signed object URLs won't survive a restart: %v", err))
+	}
+	return key
+}
+
+// localSigningKeyFile is where keys used to be kept, under the store's root. A key found
+// there is moved to signingKeyFile, and the name is never served as an object.
+const localSigningKeyFile = ".signing_key"
+
+// signingKeyFile is where the key generated for a store root is kept: in the user's config
+// directory, outside the source tree, so it can't be committed along with the data directory
+func signingKeyFile(root string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("no config directory for the signing key: %w", err)
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve object store directory: %w", err)
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(dir, "cantrip", "signing-keys", hex.EncodeToString(sum[:8])), nil
+}
+
+// persistentSigningKey reads the signing key generated for a store root, generating and
+// saving one the first time, so signed URLs outlive a restart without OBJECT_STORE_SIGNING_KEY
+func persistentSigningKey(root string) ([]byte, error) {
+	file, err := signingKeyFile(root)
+	if err != nil {
+		return nil, err
+	}
+	if key, err := readSigningKey(file); err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return key, err
+	}
+
+	// Move a key kept under the root, so links signed with it still verify
+	legacy := filepath.Join(root, localSigningKeyFile)
+	key, err := readSigningKey(legacy)
+	if errors.Is(err, fs.ErrNotExist) {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate signing key: %w", err)
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create signing key directory: %w", err)
+	}
+	if err := writeFileAtomic(file, []byte(hex.EncodeToString(key)), 0600); err != nil {
+		return nil, fmt.Errorf("failed to save signing key: %w", err)
+	}
+	if err := os.Remove(legacy); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		utils.LogWarning(fmt.Sprintf("Failed to remove the old signing key %s: %v", legacy, err))
+	}
+	return key, nil
+}
+
+// readSigningKey reads a hex-encoded key file
+func readSigningKey(file string) ([]byte, error) {
+	encoded, err := os.ReadFile(file)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil || len(key) == 0 {
+		return nil, fmt.Errorf("invalid signing key in %s", file)
+	}
+	return key, nil
+}
+
+// LocalObjectStore keeps objects as files under a root directory. Signed URLs point at
+// LocalObjectURLPrefix with an HMAC token instead of a cloud signature.
+type LocalObjectStore struct {
+	root string
+	key  []byte
+}
+
+// NewLocalObjectStore creates a store rooted at dir. Without a signing key a random one is
+// generated, so signed URLs last until the server restarts.
+func NewLocalObjectStore(dir string, key []byte) *LocalObjectStore {
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			panic(fmt.Sprintf("failed to generate object signing key: %v", err))
+		}
+	}
+	return &LocalObjectStore{root: dir, key: key}
+}
+
+// path maps an object name to a file under the root, refusing names that escape it
+func (s *LocalObjectStore) path(objectName string) (string, error) {
+	clean := path.Clean("/" + objectName)
+	if clean == "/" || clean == "/"+localSigningKeyFile || strings.HasSuffix(objectName, "/") {
+		return "", fmt.Errorf("invalid object name %q", objectName)
+	}
+	return filepath.Join(s.root, filepath.FromSlash(clean)), nil
+}
+
+// UploadFile writes an object, replacing it atomically
+func (s *LocalObjectStore) UploadFile(ctx context.Context, objectName string, data []byte, contentType string) error {
+	file, err := s.path(objectName)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return fmt.Errorf("failed to create object directory: %w", err)
+	}
+	return writeFileAtomic(file, data, 0644)
+}
+
+// UploadFileFromPath copies a local file into the store; a file already at the object's
+// path is left as is
+func (s *LocalObjectStore) UploadFileFromPath(ctx context.Context, localPath, objectName string) error {
+	file, err := s.path(objectName)
+	if err != nil {
+		return err
+	}
+	if src, err := filepath.Abs(localPath); err == nil {
+		if dst, err := filepath.Abs(file); err == nil && src == dst {
+			return nil
+		}
+	}
+
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to read local file: %w", err)
+	}
+	return s.UploadFile(ctx, objectName, data, getContentTypeFromExtension(filepath.Ext(localPath)))
+}
+
+// DownloadFile reads an object
+func (s *LocalObjectStore) DownloadFile(ctx context.Context, objectName string) ([]byte, error) {
+	file, err := s.path(objectName)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%s: %w", objectName, ErrObjectNotFound)
+	}
+	return data, err
+}
+
+// NewRangeReader streams length bytes of an object from offset; a negative length reads to the end
+func (s *LocalObjectStore) NewRangeReader(ctx context.Context, objectName string, offset, length int64) (io.ReadCloser, error) {
+	file, err := s.open(objectName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if length < 0 {
+		return file, nil
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(file, length), file}, nil
+}
+
+// open opens an object's file for reading
+func (s *LocalObjectStore) open(objectName string) (*os.File, error) {
+	file, err := s.path(objectName)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%s: %w", objectName, ErrObjectNotFound)
+	}
+	return f, err
+}
+
+// GetFileInfo describes an object
+func (s *LocalObjectStore) GetFileInfo(ctx context.Context, objectName string) (*FileInfo, error) {
+	file, err := s.path(objectName)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%s: %w", objectName, ErrObjectNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file attributes: %w", err)
+	}
+	return s.fileInfo(objectName, info), nil
+}
+
+func (s *LocalObjectStore) fileInfo(objectName string, info os.FileInfo) *FileInfo {
+	return &FileInfo{
+		Name:        objectName,
+		Size:        info.Size(),
+		ContentType: getContentTypeFromExtension(path.Ext(objectName)),
+		Created:     info.ModTime(),
+		Updated:     info.ModTime(),
+		URL:         LocalObjectURLPrefix + objectName,
+		Bucket:      ObjectStoreLocal,
+	}
+}
+
+// ListFiles lists the objects whose names start with prefix
+func (s *LocalObjectStore) ListFiles(ctx context.Context, prefix string) ([]FileInfo, error) {
+	// Walk only the directory the prefix names, not the whole store
+	dir := s.root
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		dir = filepath.Join(s.root, filepath.FromSlash(path.Clean("/"+prefix[:i])))
+	}
+
+	var files []FileInfo
+	err := filepath.WalkDir(dir, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() || strings.HasSuffix(entry.Name(), ".tmp") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.root, file)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if name == localSigningKeyFile || !strings.HasPrefix(name, prefix) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		files = append(files, *s.fileInfo(name, info))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files, nil
+}
+
+// DeleteFile removes an object; deleting a missing object is not an error
+func (s *LocalObjectStore) DeleteFile(ctx context.Context, objectName string) error {
+	file, err := s.path(objectName)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(file); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+	return nil
+}
+
+// FileExists reports whether an object exists
+func (s *LocalObjectStore) FileExists(ctx context.Context, objectName string) (bool, error) {
+	_, err := s.GetFileInfo(ctx, objectName)
+	if errors.Is(err, ErrObjectNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// GenerateSignedURL returns a local URL that serves the object until it expires
+func (s *LocalObjectStore) GenerateSignedURL(ctx context.Context, objectName string, expiration time.Duration) (string, error) {
+	return s.GenerateSignedURLWithParams(ctx, objectName, expiration, nil)
+}
+
+// GenerateSignedURLWithParams signs a local URL with extra query parameters, honouring
+// response-content-type and response-content-disposition like GCS
+func (s *LocalObjectStore) GenerateSignedURLWithParams(ctx context.Context, objectName string, expiration time.Duration, params url.Values) (string, error) {
+	if expiration <= 0 || expiration > MaxSignedURLExpiry {
+		return "", fmt.Errorf("signed URL expiry must be between 0 and %s", MaxSignedURLExpiry)
+	}
+	if _, err := s.path(objectName); err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	for key, values := range params {
+		query[key] = values
+	}
+	query.Set("expires", strconv.FormatInt(time.Now().Add(expiration).Unix(), 10))
+	query.Set("signature", s.sign(objectName, query))
+
+	return LocalObjectURLPrefix + (&url.URL{Path: objectName}).EscapedPath() + "?" + query.Encode(), nil
+}
+
+// sign computes the token for an object and its query, excluding the signature itself
+func (s *LocalObjectStore) sign(objectName string, query url.Values) string {
+	signed := url.Values{}
+	for key, values := range query {
+		if key != "signature" {
+			signed[key] = values
+		}
+	}
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte("GET\n" + objectName + "\n" + signed.Encode()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignedURL checks a local signed URL's token and expiry
+func (s *LocalObjectStore) VerifySignedURL(objectName string, query url.Values) error {
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return ErrInvalidObjectSignature
+	}
+	expected := s.sign(objectName, query)
+	if !hmac.Equal([]byte(expected), []byte(query.Get("signature"))) {
+		return ErrInvalidObjectSignature
+	}
+	return nil
+}
+
+// OpenSigned verifies a signed URL and opens its object for serving
+func (s *LocalObjectStore) OpenSigned(objectName string, query url.Values) (*os.File, error) {
+	if err := s.VerifySignedURL(objectName, query); err != nil {
+		return nil, err
+	}
+	return s.open(objectName)
+}
+
+// UploadJSON writes an indented JSON object
+func (s *LocalObjectStore) UploadJSON(ctx context.Context, objectName string, data interface{}) error {
+	jsonData, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	return s.UploadFile(ctx, objectName, jsonData, "application/json")
+}
+
+// DownloadJSON reads a JSON object into target
+func (s *LocalObjectStore) DownloadJSON(ctx context.Context, objectName string, target interface{}) error {
+	data, err := s.DownloadFile(ctx, objectName)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, target); err != nil {
+		return fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMain keeps the signing keys generated by stores under test out of the real user
+// config directory
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "cantrip-config")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("XDG_CONFIG_HOME", dir)
+	os.Setenv("HOME", dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// isolateUserConfig points the user config directory at a temporary one, returning it
+func isolateUserConfig(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	config, err := os.UserConfigDir()
+	if err != nil {
+		t.Fatalf("UserConfigDir: %v", err)
+	}
+	return config
+}
+
+func TestPersistentSigningKeySurvivesRestart(t *testing.T) {
+	config := isolateUserConfig(t)
+	root := t.TempDir()
+	key, err := persistentSigningKey(root)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	first := NewLocalObjectStore(root, key)
+	ctx := context.Background()
+	if err := first.UploadFile(ctx, "pdfs/trip.pdf", []byte("%PDF"), "application/pdf"); err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	signedURL, err := first.GenerateSignedURL(ctx, "pdfs/trip.pdf", time.Hour)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	// A restarted server reads the same key back, so the link still verifies
+	key, err = persistentSigningKey(root)
+	if err != nil {
+		t.Fatalf("reload key: %v", err)
+	}
+	restarted := NewLocalObjectStore(root, key)
+	u, _ := url.Parse(signedURL)
+	if err := restarted.VerifySignedURL("pdfs/trip.pdf", u.Query()); err != nil {
+		t.Errorf("link signed before the restart: %v", err)
+	}
+
+	// The key file isn't an object
+	if _, err := restarted.DownloadFile(ctx, localSigningKeyFile); err == nil {
+		t.Error("the signing key can be read as an object")
+	}
+	files, err := restarted.ListFiles(ctx, "")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(files) != 1 || files[0].Name != "pdfs/trip.pdf" {
+		t.Errorf("objects = %+v, want only pdfs/trip.pdf", files)
+	}
+
+	// The key is kept in the user's config directory, not beside the objects
+	file, err := signingKeyFile(root)
+	if err != nil {
+		t.Fatalf("signingKeyFile: %v", err)
+	}
+	if !strings.HasPrefix(file, config) {
+		t.Errorf("signing key kept at %s, outside %s", file, config)
+	}
+	if _, err := os.Stat(filepath.Join(root, localSigningKeyFile)); err == nil {
+		t.Error("signing key written under the store's root")
+	}
+}
+
+func TestPersistentSigningKeyMovesKeyOutOfRoot(t *testing.T) {
+	isolateUserConfig(t)
+	root := t.TempDir()
+	legacy := filepath.Join(root, localSigningKeyFile)
+	if err := os.WriteFile(legacy, []byte("0a0b0c0d\n"), 0600); err != nil {
+		t.Fatalf("write legacy key: %v", err)
+	}
+
+	key, err := persistentSigningKey(root)
+	if err != nil {
+		t.Fatalf("persistentSigningKey: %v", err)
+	}
+	if hex.EncodeToString(key) != "0a0b0c0d" {
+		t.Errorf("key = %x, want the one kept under the root", key)
+	}
+	if _, err := os.Stat(legacy); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("old key file left under the root: %v", err)
+	}
+	if again, err := persistentSigningKey(root); err != nil || !bytes.Equal(again, key) {
+		t.Errorf("key after moving = %x, %v", again, err)
+	}
+}
+
+func TestBlobStoreScopesToTenantWithoutEmulator(t *testing.T) {
+	isolateUserConfig(t)
+	t.Chdir(t.TempDir())
+	t.Setenv("OBJECT_STORE", ObjectStoreNone)
+	t.Setenv("OBJECT_STORE_SIGNING_KEY", "")
+	if GetObjectStore() != nil {
+		t.Skip("an object store is configured")
+	}
+
+	ctx := WithTenant(context.Background(), &Tenant{ID: "acme", StoragePrefix: "tenants/acme"})
+	if err := blobStore().UploadFile(ctx, "attachments/trip/a.jpg", []byte("jpeg"), "image/jpeg"); err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join("data", "tenants", "acme", "attachments", "trip", "a.jpg")); err != nil {
+		t.Errorf("attachment isn't under the tenant's prefix: %v", err)
+	}
+	file, err := signingKeyFile("data")
+	if err != nil {
+		t.Fatalf("signingKeyFile: %v", err)
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Errorf("no persistent signing key: %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// OfflineBundle gathers what an international visitor needs without a data connection:
+// emergency numbers, language, safety, health, customs, tipping and connectivity
+type OfflineBundle struct {
+	Destination  string                 `json:"destination"`
+	GeneratedAt  time.Time              `json:"generated_at"`
+	Emergency    Emergency              `json:"emergency"`
+	Language     Language               `json:"language"`
+	Safety       []Tip                  `json:"safety"`
+	Health       []Tip                  `json:"health"`
+	Customs      []Tip                  `json:"customs"`
+	Tipping      map[string]interface{} `json:"tipping"`
+	Connectivity *ConnectivityInfo      `json:"connectivity"`
+}
+
+// GetOfflineBundle builds the offline bundle for a destination
+func GetOfflineBundle(ctx context.Context, destination string) (*OfflineBundle, error) {
+	bundle := &OfflineBundle{Destination: destination, GeneratedAt: time.Now().UTC()}
+
+	var err error
+	if bundle.Emergency, err = GetEmergencyInfo(ctx, destination); err != nil {
+		return nil, fmt.Errorf("failed to get emergency info: %w", err)
+	}
+	if bundle.Language, err = GetLanguageInfo(ctx, destination); err != nil {
+		return nil, fmt.Errorf("failed to get language info: %w", err)
+	}
+	if bundle.Safety, err = GetSafetyTips(ctx, destination); err != nil {
+		return nil, fmt.Errorf("failed to get safety tips: %w", err)
+	}
+	if bundle.Health, err = GetHealthTips(ctx, destination); err != nil {
+		return nil, fmt.Errorf("failed to get health tips: %w", err)
+	}
+	if bundle.Customs, err = GetLocalCustoms(ctx, destination); err != nil {
+		return nil, fmt.Errorf("failed to get local customs: %w", err)
+	}
+	if bundle.Tipping, err = GetTippingGuide(ctx, destination); err != nil {
+		return nil, fmt.Errorf("failed to get tipping guide: %w", err)
+	}
+	if bundle.Connectivity, err = GetConnectivityInfo(ctx, destination, false); err != nil {
+		return nil, fmt.Errorf("failed to get connectivity info: %w", err)
+	}
+	return bundle, nil
+}
@@ -0,0 +1,337 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joshndala/cantrip/utils"
+)
+
+// OutboundLinkCollection stores the destination of every tracked outbound link
+const OutboundLinkCollection = "outbound_links"
+
+// OutboundLogFile is the append-only click and conversion store
+const OutboundLogFile = "data/outbound/outbound.jsonl"
+
+// OutboundPathPrefix is the public path clients follow to leave for a booking page
+const OutboundPathPrefix = "/api/v1/out/"
+
+// Outbound log entry types
+const (
+	OutboundClick      = "click"
+	OutboundConversion = "conversion"
+)
+
+// affiliateProgram describes how a provider attributes referrals
+type affiliateProgram struct {
+	IDEnv      string // environment variable holding our affiliate ID
+	IDParam    string // query parameter carrying the affiliate ID
+	SubIDParam string // query parameter echoed back in conversion postbacks
+}
+
+// affiliatePrograms are keyed by EventProvider.Name()
+var affiliatePrograms = map[string]affiliateProgram{
+	"ticketmaster": {IDEnv: "TICKETMASTER_AFFILIATE_ID", IDParam: "camefrom", SubIDParam: "subid"},
+	"eventbrite":   {IDEnv: "EVENTBRITE_AFFILIATE_ID", IDParam: "aff", SubIDParam: "afu"},
+}
+
+// OutboundLink is a tracked booking destination for an event
+type OutboundLink struct {
+	EventID        string    `json:"event_id"`
+	Provider       string    `json:"provider"`
+	EventName      string    `json:"event_name"`
+	DestinationURL string    `json:"destination_url"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// OutboundEntry records a click-through or a conversion
+type OutboundEntry struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"` // click, conversion
+	EventID   string    `json:"event_id"`
+	Provider  string    `json:"provider"`
+	ClickID   string    `json:"click_id,omitempty"`
+	Actor     string    `json:"actor,omitempty"`
+	Referrer  string    `json:"referrer,omitempty"`
+	Revenue   float64   `json:"revenue,omitempty"`
+	Currency  string    `json:"currency,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// OutboundStats summarizes click-throughs and conversions
+type OutboundStats struct {
+	Clicks         int                       `json:"clicks"`
+	UniqueVisitors int                       `json:"unique_visitors"`
+	Conversions    int                       `json:"conversions"`
+	ConversionRate float64                   `json:"conversion_rate"`
+	Revenue        float64                   `json:"revenue"`
+	ByProvider     map[string]*OutboundCount `json:"by_provider"`
+	TopEvents      []OutboundEventCount      `json:"top_events"`
+}
+
+// OutboundCount is a per-provider tally
+type OutboundCount struct {
+	Clicks      int     `json:"clicks"`
+	Conversions int     `json:"conversions"`
+	Revenue     float64 `json:"revenue"`
+}
+
+// OutboundEventCount is a per-event tally
+type OutboundEventCount struct {
+	EventID     string `json:"event_id"`
+	EventName   string `json:"event_name,omitempty"`
+	Clicks      int    `json:"clicks"`
+	Conversions int    `json:"conversions"`
+}
+
+var (
+	// outboundLinks caches registered links so repeated searches do not rewrite them
+	outboundLinks sync.Map // tenantKey of the event ID -> OutboundLink
+	outboundMu    sync.Mutex
+)
+
+// EventID derives a stable identifier for an event from its provider and identity
+func EventID(event Event) string {
+	key := strings.ToLower(strings.Join([]string{event.Provider, event.Name, event.Date, event.Location, event.BookingURL}, "|"))
+	sum := sha1.Sum([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// RegisterOutboundLinks assigns event IDs and replaces booking URLs with tracked outbound links
+func RegisterOutboundLinks(ctx context.Context, events []Event) []Event {
+	for i := range events {
+		if events[i].ID == "" {
+			events[i].ID = EventID(events[i])
+		}
+		if events[i].BookingURL == "" {
+			continue
+		}
+
+		if _, known := outboundLinks.Load(tenantKey(ctx, events[i].ID)); !known {
+			link := OutboundLink{
+				EventID:        events[i].ID,
+				Provider:       events[i].Provider,
+				EventName:      events[i].Name,
+				DestinationURL: events[i].BookingURL,
+				CreatedAt:      time.Now().UTC(),
+			}
+			if err := saveDocument(ctx, OutboundLinkCollection, link.EventID, link); err != nil {
+				utils.LogError("Failed to save outbound link", err)
+				continue
+			}
+			outboundLinks.Store(tenantKey(ctx, link.EventID), link)
+		}
+		events[i].OutboundURL = OutboundPathPrefix + events[i].ID
+	}
+	return events
+}
+
+// GetOutboundLink loads a registered outbound link
+func GetOutboundLink(ctx context.Context, eventID string) (*OutboundLink, error) {
+	if cached, ok := outboundLinks.Load(tenantKey(ctx, eventID)); ok {
+		link := cached.(OutboundLink)
+		return &link, nil
+	}
+
+	var link OutboundLink
+	if err := loadDocument(ctx, OutboundLinkCollection, eventID, &link); err != nil {
+		return nil, err
+	}
+	outboundLinks.Store(tenantKey(ctx, eventID), link)
+	return &link, nil
+}
+
+// AffiliateURL appends affiliate and campaign parameters for a provider
+func AffiliateURL(provider, rawURL, clickID string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", fmt.Errorf("invalid booking URL %q", rawURL)
+	}
+
+	query := u.Query()
+	if program, ok := affiliatePrograms[provider]; ok {
+		if affiliateID := os.Getenv(program.IDEnv); affiliateID != "" {
+			query.Set(program.IDParam, affiliateID)
+			if clickID != "" {
+				query.Set(program.SubIDParam, clickID)
+			}
+		}
+	}
+
+	campaign := os.Getenv("OUTBOUND_CAMPAIGN")
+	if campaign == "" {
+		campaign = "events"
+	}
+	query.Set("utm_source", "cantrip")
+	query.Set("utm_medium", "referral")
+	query.Set("utm_campaign", campaign)
+
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// RecordClickThrough logs a click on an outbound link and returns the affiliate destination
+func RecordClickThrough(ctx context.Context, eventID, actor, referrer string) (string, error) {
+	link, err := GetOutboundLink(ctx, eventID)
+	if err != nil {
+		return "", err
+	}
+
+	entry := OutboundEntry{
+		ID:        utils.GenerateID(),
+		Type:      OutboundClick,
+		EventID:   link.EventID,
+		Provider:  link.Provider,
+		Actor:     actor,
+		Referrer:  referrer,
+		Timestamp: time.Now().UTC(),
+	}
+
+	destination, err := AffiliateURL(link.Provider, link.DestinationURL, entry.ID)
+	if err != nil {
+		return "", err
+	}
+
+	if err := appendOutboundEntry(ctx, entry); err != nil {
+		// Never block the user from reaching the booking page
+		utils.LogError("Failed to record click-through", err)
+	}
+	return destination, nil
+}
+
+// RecordConversion logs a conversion reported by an affiliate network postback
+func RecordConversion(ctx context.Context, eventID, clickID string, revenue float64, currency string) error {
+	link, err := GetOutboundLink(ctx, eventID)
+	if err != nil {
+		return err
+	}
+
+	return appendOutboundEntry(ctx, OutboundEntry{
+		ID:        utils.GenerateID(),
+		Type:      OutboundConversion,
+		EventID:   link.EventID,
+		Provider:  link.Provider,
+		ClickID:   clickID,
+		Revenue:   revenue,
+		Currency:  strings.ToUpper(currency),
+		Timestamp: time.Now().UTC(),
+	})
+}
+
+// GetOutboundStats aggregates click-throughs and conversions, optionally since a time and for one provider
+func GetOutboundStats(ctx context.Context, since time.Time, provider string) (*OutboundStats, error) {
+	stats := &OutboundStats{ByProvider: map[string]*OutboundCount{}, TopEvents: []OutboundEventCount{}}
+
+	file, err := os.Open(tenantDataFile(ctx, OutboundLogFile))
+	if os.IsNotExist(err) {
+		return stats, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open outbound log: %w", err)
+	}
+	defer file.Close()
+
+	visitors := map[string]bool{}
+	events := map[string]*OutboundEventCount{}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry OutboundEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if entry.Timestamp.Before(since) || (provider != "" && entry.Provider != provider) {
+			continue
+		}
+
+		byProvider := stats.ByProvider[entry.Provider]
+		if byProvider == nil {
+			byProvider = &OutboundCount{}
+			stats.ByProvider[entry.Provider] = byProvider
+		}
+		byEvent := events[entry.EventID]
+		if byEvent == nil {
+			byEvent = &OutboundEventCount{EventID: entry.EventID}
+			events[entry.EventID] = byEvent
+		}
+
+		switch entry.Type {
+		case OutboundClick:
+			stats.Clicks++
+			byProvider.Clicks++
+			byEvent.Clicks++
+			if entry.Actor != "" {
+				visitors[entry.Actor] = true
+			}
+		case OutboundConversion:
+			stats.Conversions++
+			stats.Revenue += entry.Revenue
+			byProvider.Conversions++
+			byProvider.Revenue += entry.Revenue
+			byEvent.Conversions++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read outbound log: %w", err)
+	}
+
+	stats.UniqueVisitors = len(visitors)
+	if stats.Clicks > 0 {
+		stats.ConversionRate = float64(stats.Conversions) / float64(stats.Clicks)
+	}
+
+	for _, count := range events {
+		if link, err := GetOutboundLink(ctx, count.EventID); err == nil {
+			count.EventName = link.EventName
+		}
+		stats.TopEvents = append(stats.TopEvents, *count)
+	}
+	sort.Slice(stats.TopEvents, func(i, j int) bool {
+		if stats.TopEvents[i].Clicks != stats.TopEvents[j].Clicks {
+			return stats.TopEvents[i].Clicks > stats.TopEvents[j].Clicks
+		}
+		return stats.TopEvents[i].EventID < stats.TopEvents[j].EventID
+	})
+	if len(stats.TopEvents) > 10 {
+		stats.TopEvents = stats.TopEvents[:10]
+	}
+
+	return stats, nil
+}
+
+// appendOutboundEntry appends an entry to the outbound log
+func appendOutboundEntry(ctx context.Context, entry OutboundEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal outbound entry: %w", err)
+	}
+
+	outboundMu.Lock()
+	defer outboundMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(tenantDataFile(ctx, OutboundLogFile)), 0755); err != nil {
+		return fmt.Errorf("failed to create outbound directory: %w", err)
+	}
+
+	file, err := os.OpenFile(tenantDataFile(ctx, OutboundLogFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open outbound log: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write outbound entry: %w", err)
+	}
+	return nil
+}
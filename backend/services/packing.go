@@ -4,89 +4,141 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
-	"os"
-	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/joshndala/cantrip/models"
 )
 
-type PackingRequest struct {
-	Destination  string   `json:"destination"`
-	StartDate    string   `json:"start_date"`
-	EndDate      string   `json:"end_date"`
-	Activities   []string `json:"activities"`
-	Weather      string   `json:"weather"`
-	GroupSize    int      `json:"group_size"`
-	AgeGroup     string   `json:"age_group"`
-	SpecialNeeds []string `json:"special_needs"`
-	BaggageType  string   `json:"baggage_type"`
-}
+// PackingRequest is what a packing list is generated from
+type PackingRequest = models.PackingRequest
 
-type PackingResponse struct {
-	ID          string        `json:"id"`
-	Destination string        `json:"destination"`
-	Categories  []interface{} `json:"categories"`
-	TotalItems  int           `json:"total_items"`
-	Notes       []string      `json:"notes"`
-	Weather     WeatherInfo   `json:"weather"`
-}
+// Traveler is a named member of a group
+type Traveler = models.Traveler
+
+// TravelerPackingList is one traveler's personal items
+type TravelerPackingList = models.TravelerPackingList
+
+// PackingResponse is a generated packing list, as saved and returned by the packing calls
+type PackingResponse = models.PackingResponse
 
 // PackingCategory represents a category of items in the packing list
-type PackingCategory struct {
-	Name  string        `json:"name"`
-	Items []PackingItem `json:"items"`
-}
+type PackingCategory = models.PackingCategory
+
+// PackingWeatherRange is the span of feels-like temperatures, in °C, a list is packed for
+type PackingWeatherRange = models.PackingWeatherRange
 
 // PackingItem represents a single item in the packing list
-type PackingItem struct {
-	Name     string `json:"name"`
-	Quantity int    `json:"quantity"`
-	Reason   string `json:"reason"`
-}
+type PackingItem = models.PackingItem
 
 // PackingRules represents the structure of packing_rules.json
 type PackingRules struct {
-	WeatherRules  map[string]interface{} `json:"weather_rules"`
-	ActivityRules map[string]interface{} `json:"activity_rules"`
-	DurationRules map[string]interface{} `json:"duration_rules"`
-	GroupRules    map[string]interface{} `json:"group_rules"`
-	AgeRules      map[string]interface{} `json:"age_rules"`
-	SpecialNeeds  map[string]interface{} `json:"special_needs"`
-	BaggageRules  map[string]interface{} `json:"baggage_rules"`
+	SchemaVersion    int                    `json:"schema_version"`
+	WeatherRules     map[string]interface{} `json:"weather_rules"`
+	ActivityRules    map[string]interface{} `json:"activity_rules"`
+	ActivitySynonyms map[string][]string    `json:"activity_synonyms"` // activity rule key -> free-text phrases
+	DurationRules    map[string]interface{} `json:"duration_rules"`
+	GroupRules       map[string]interface{} `json:"group_rules"`
+	AgeRules         map[string]interface{} `json:"age_rules"`
+	SpecialNeeds     map[string]interface{} `json:"special_needs"`
+	BaggageRules     map[string]interface{} `json:"baggage_rules"`
+	BaggageSplit     *BaggageSplitRules     `json:"baggage_split"`
+	HealthRules      *HealthRules           `json:"health_rules"`
+	RoadTrip         *RoadTripRules         `json:"road_trip"`
 }
 
 // GeneratePackingList generates a packing list based on the request and weather information
-func GeneratePackingList(req PackingRequest, weather WeatherInfo) (PackingResponse, error) {
+func GeneratePackingList(ctx context.Context, req PackingRequest, weather WeatherInfo) (PackingResponse, error) {
 	// Load packing rules
-	rules, err := loadPackingRules()
+	rules, err := loadPackingRules(ctx)
 	if err != nil {
 		return PackingResponse{}, fmt.Errorf("failed to load packing rules: %w", err)
 	}
 
-	// Calculate trip duration
-	duration, err := calculateDuration(req.StartDate, req.EndDate)
+	// Quantities are for every day of the trip, both ends included
+	span, err := tripSpan(req.StartDate.String(), req.EndDate.String())
 	if err != nil {
-		return PackingResponse{}, fmt.Errorf("failed to calculate duration: %w", err)
+		return PackingResponse{}, err
+	}
+	duration := span.Days
+
+	// Pack for every day of the trip, by how cold or hot it feels rather than the air
+	// temperature. Without a forecast, the current reading stands in for the whole trip.
+	forecast, _ := GetWeatherForecast(ctx, req.Destination, req.StartDate.String(), req.EndDate.String())
+	weatherRange := tripWeatherRange(weather, forecast)
+
+	if len(req.Travelers) > 0 {
+		return generateGroupPackingList(ctx, req, weather, rules, weatherRange, forecast, duration)
+	}
+
+	health, healthNotes := healthPackingCategory(ctx, rules, req.Destination, req.StartDate.String(), req.EndDate.String(), req.Activities)
+	rain, rainNotes := rainPackingCategory(forecast)
+	categories := buildPackingCategories(rules, weatherRange.Categories, req.Activities, req.AgeGroup, req.SpecialNeeds, req.BaggageType, health, rain)
+
+	// Apply duration multiplier
+	applyDurationMultiplier(categories, rules, duration)
+
+	// Apply group size multiplier
+	applyGroupMultiplier(categories, rules, req.GroupSize)
+
+	// Medications are counted in doses, so they're added after the multipliers
+	categories = addMedications(categories, rules, req.Medications, duration)
+
+	// Car items, and whether the luggage fits the rental
+	roadTrip, roadTripNotes := roadTripPackingCategory(ctx, rules, req.Vehicle, req.StartDate.String(), req.EndDate.String(), max(req.GroupSize, 1), categories)
+	if len(roadTrip.Items) > 0 {
+		categories = consolidatePackingCategories(append(categories, roadTrip))
 	}
 
-	// Determine weather category based on temperature
-	weatherCategory := getWeatherCategory(weather.Temperature)
+	// Calculate total items
+	totalItems := countPackingItems(categories)
+
+	// Generate notes
+	notes := append(generateNotes(rules, duration, req.GroupSize, weatherRange), healthNotes...)
+	notes = append(notes, rainNotes...)
+	notes = append(notes, roadTripNotes...)
+
+	response := PackingResponse{
+		ID:              generatePackingListID(req.Destination, req.StartDate.String()),
+		Destination:     req.Destination,
+		StartDate:       req.StartDate.String(),
+		EndDate:         req.EndDate.String(),
+		Categories:      packingCategoriesInterface(categories),
+		TotalItems:      totalItems,
+		Notes:           notes,
+		Weather:         weather,
+		WeatherRange:    &weatherRange,
+		BaggageSplit:    splitBaggage(rules, req.BaggageType, categories),
+		RefillReminders: planRefillReminders(rules, req.Medications, req.StartDate.String(), duration),
+	}
+	if req.DailyGuidance {
+		response.DailyGuidance = dailyOutfitGuidance(rules, forecast)
+	}
+	return response, nil
+}
 
+// buildPackingCategories collects items for the weather categories, activities, age group,
+// special needs and baggage type, plus any extra categories, consolidated but before any
+// quantity multipliers
+func buildPackingCategories(rules *PackingRules, weatherCategories []string, activities []string, ageGroup string, specialNeeds []string, baggageType string, extra ...PackingCategory) []PackingCategory {
 	// Generate categories based on weather, activities, and other factors
 	categories := []PackingCategory{}
 
-	// Add weather-based clothing
-	if weatherItems := getWeatherItems(rules, weatherCategory); len(weatherItems) > 0 {
-		categories = append(categories, PackingCategory{
-			Name:  "Weather-Appropriate Clothing",
-			Items: weatherItems,
-		})
+	// Add weather-based clothing, layered when the trip spans several categories
+	for _, weatherCategory := range weatherCategories {
+		if weatherItems := getWeatherItems(rules, weatherCategory); len(weatherItems) > 0 {
+			categories = append(categories, PackingCategory{
+				Name:  "Weather-Appropriate Clothing",
+				Items: weatherItems,
+			})
+		}
 	}
 
 	// Add activity-based items
-	for _, activity := range req.Activities {
+	for _, activity := range activities {
 		if activityItems := getActivityItems(rules, activity); len(activityItems) > 0 {
 			categories = append(categories, PackingCategory{
 				Name:  fmt.Sprintf("%s Gear", strings.Title(strings.ReplaceAll(activity, "_", " "))),
@@ -96,7 +148,7 @@ func GeneratePackingList(req PackingRequest, weather WeatherInfo) (PackingRespon
 	}
 
 	// Add age-specific items
-	if ageItems := getAgeItems(rules, req.AgeGroup); len(ageItems) > 0 {
+	if ageItems := getAgeItems(rules, ageGroup); len(ageItems) > 0 {
 		categories = append(categories, PackingCategory{
 			Name:  "Age-Specific Items",
 			Items: ageItems,
@@ -104,7 +156,7 @@ func GeneratePackingList(req PackingRequest, weather WeatherInfo) (PackingRespon
 	}
 
 	// Add special needs items
-	for _, need := range req.SpecialNeeds {
+	for _, need := range specialNeeds {
 		if specialItems := getSpecialNeedsItems(rules, need); len(specialItems) > 0 {
 			categories = append(categories, PackingCategory{
 				Name:  fmt.Sprintf("%s Items", strings.Title(strings.ReplaceAll(need, "_", " "))),
@@ -114,7 +166,7 @@ func GeneratePackingList(req PackingRequest, weather WeatherInfo) (PackingRespon
 	}
 
 	// Add essentials
-	essentials := getEssentials(rules, req.BaggageType)
+	essentials := getEssentials(rules, baggageType)
 	if len(essentials) > 0 {
 		categories = append(categories, PackingCategory{
 			Name:  "Essentials",
@@ -122,42 +174,32 @@ func GeneratePackingList(req PackingRequest, weather WeatherInfo) (PackingRespon
 		})
 	}
 
-	// Apply duration multiplier
-	applyDurationMultiplier(categories, rules, duration)
-
-	// Apply group size multiplier
-	applyGroupMultiplier(categories, rules, req.GroupSize)
+	return consolidatePackingCategories(append(categories, extra...))
+}
 
-	// Calculate total items
+// countPackingItems totals item quantities across categories
+func countPackingItems(categories []PackingCategory) int {
 	totalItems := 0
 	for _, category := range categories {
 		for _, item := range category.Items {
 			totalItems += item.Quantity
 		}
 	}
+	return totalItems
+}
 
-	// Generate notes
-	notes := generateNotes(rules, duration, req.GroupSize, weatherCategory)
-
-	// Convert categories to interface{} for JSON serialization
+// packingCategoriesInterface converts categories to interface{} for JSON serialization
+func packingCategoriesInterface(categories []PackingCategory) []interface{} {
 	categoriesInterface := make([]interface{}, len(categories))
 	for i, category := range categories {
 		categoriesInterface[i] = category
 	}
-
-	return PackingResponse{
-		ID:          generatePackingListID(req.Destination, req.StartDate),
-		Destination: req.Destination,
-		Categories:  categoriesInterface,
-		TotalItems:  totalItems,
-		Notes:       notes,
-		Weather:     weather,
-	}, nil
+	return categoriesInterface
 }
 
 // loadPackingRules loads the packing rules from the JSON file
-func loadPackingRules() (*PackingRules, error) {
-	data, err := os.ReadFile("data/packing_rules.json")
+func loadPackingRules(ctx context.Context) (*PackingRules, error) {
+	data, err := ReadDataset(ctx, PackingRulesDataset)
 	if err != nil {
 		return nil, err
 	}
@@ -170,22 +212,6 @@ func loadPackingRules() (*PackingRules, error) {
 	return &rules, nil
 }
 
-// calculateDuration calculates the duration of the trip in days
-func calculateDuration(startDate, endDate string) (int, error) {
-	start, err := time.Parse("2006-01-02", startDate)
-	if err != nil {
-		return 0, err
-	}
-
-	end, err := time.Parse("2006-01-02", endDate)
-	if err != nil {
-		return 0, err
-	}
-
-	duration := end.Sub(start)
-	return int(duration.Hours() / 24), nil
-}
-
 // getWeatherCategory determines the weather category based on temperature
 func getWeatherCategory(temperature float64) string {
 	switch {
@@ -202,6 +228,71 @@ func getWeatherCategory(temperature float64) string {
 	}
 }
 
+// UmbrellaPrecipProbability is the daily chance of precipitation, 0 to 1, at which rain gear
+// is packed
+const UmbrellaPrecipProbability = 0.4
+
+// rainPackingCategory packs rain gear when any day of the trip's forecast is likely to be
+// wet, with a note naming the wettest day
+func rainPackingCategory(forecast []WeatherForecast) (PackingCategory, []string) {
+	category := PackingCategory{Name: "Weather-Appropriate Clothing", Items: []PackingItem{}}
+
+	var wettest *WeatherForecast
+	for i := range forecast {
+		if wettest == nil || forecast[i].PrecipProbability > wettest.PrecipProbability {
+			wettest = &forecast[i]
+		}
+	}
+	if wettest == nil || wettest.PrecipProbability < UmbrellaPrecipProbability {
+		return category, nil
+	}
+
+	reason := fmt.Sprintf("%.0f%% chance of precipitation on %s", wettest.PrecipProbability*100, wettest.Date)
+	category.Items = append(category.Items,
+		PackingItem{Name: "Umbrella", Quantity: 1, Reason: reason},
+		PackingItem{Name: "Rain jacket", Quantity: 1, Reason: reason},
+	)
+	return category, []string{fmt.Sprintf("Rain is likely during your trip (%s), so keep an umbrella handy.", reason)}
+}
+
+// weatherCategoryOrder lists the packing weather categories from coldest to hottest
+var weatherCategoryOrder = []string{"cold", "cool", "mild", "warm", "hot"}
+
+// tripWeatherRange spans the feels-like lows and highs of every forecast day, with each
+// weather category in between, so a trip swinging from 5°C to 25°C is packed in layers
+func tripWeatherRange(weather WeatherInfo, forecast []WeatherForecast) PackingWeatherRange {
+	low, high := perceivedTemperature(weather), perceivedTemperature(weather)
+	for i, day := range forecast {
+		if i == 0 {
+			low, high = day.FeelsLikeLow, day.FeelsLikeHigh
+		}
+		low, high = math.Min(low, day.FeelsLikeLow), math.Max(high, day.FeelsLikeHigh)
+	}
+
+	var categories []string
+	coldest, hottest := getWeatherCategory(low), getWeatherCategory(high)
+	within := false
+	for _, category := range weatherCategoryOrder {
+		within = within || category == coldest
+		if within {
+			categories = append(categories, category)
+		}
+		if category == hottest {
+			break
+		}
+	}
+	return PackingWeatherRange{Low: low, High: high, Categories: categories}
+}
+
+// perceivedTemperature is the feels-like temperature, computed for weather from clients or
+// older saved lists that don't carry one
+func perceivedTemperature(weather WeatherInfo) float64 {
+	if weather.FeelsLike != 0 || weather.Temperature == 0 {
+		return weather.FeelsLike
+	}
+	return FeelsLike(weather.Temperature, weather.Humidity, weather.WindSpeed)
+}
+
 // getWeatherItems gets items based on weather category
 func getWeatherItems(rules *PackingRules, weatherCategory string) []PackingItem {
 	var items []PackingItem
@@ -336,7 +427,7 @@ func getSpecialNeedsItems(rules *PackingRules, specialNeed string) []PackingItem
 func getEssentials(rules *PackingRules, baggageType string) []PackingItem {
 	var items []PackingItem
 
-	if baggageRule, exists := rules.BaggageRules[baggageType]; exists {
+	if baggageRule, exists := rules.BaggageRules[normalizeBaggageType(baggageType)]; exists {
 		if baggageMap, ok := baggageRule.(map[string]interface{}); ok {
 			if essentials, ok := baggageMap["essentials"].([]interface{}); ok {
 				for _, item := range essentials {
@@ -353,21 +444,25 @@ func getEssentials(rules *PackingRules, baggageType string) []PackingItem {
 	return items
 }
 
-// applyDurationMultiplier applies duration-based multipliers to item quantities
-func applyDurationMultiplier(categories []PackingCategory, rules *PackingRules, duration int) {
-	var durationCategory string
+// durationCategory picks the duration rule for a trip of the given number of days. A
+// Friday to Sunday trip is a weekend; the bands are a week, two weeks and a month with a
+// travel day on either end.
+func durationCategory(days int) string {
 	switch {
-	case duration <= 2:
-		durationCategory = "weekend"
-	case duration <= 7:
-		durationCategory = "week"
-	case duration <= 14:
-		durationCategory = "two_weeks"
+	case days <= 3:
+		return "weekend"
+	case days <= 8:
+		return "week"
+	case days <= 15:
+		return "two_weeks"
 	default:
-		durationCategory = "month"
+		return "month"
 	}
+}
 
-	if durationRule, exists := rules.DurationRules[durationCategory]; exists {
+// applyDurationMultiplier applies duration-based multipliers to item quantities
+func applyDurationMultiplier(categories []PackingCategory, rules *PackingRules, duration int) {
+	if durationRule, exists := rules.DurationRules[durationCategory(duration)]; exists {
 		if durationMap, ok := durationRule.(map[string]interface{}); ok {
 			if multiplier, ok := durationMap["multiplier"].(float64); ok {
 				for i := range categories {
@@ -408,23 +503,11 @@ func applyGroupMultiplier(categories []PackingCategory, rules *PackingRules, gro
 }
 
 // generateNotes generates helpful notes for the packing list
-func generateNotes(rules *PackingRules, duration int, groupSize int, weatherCategory string) []string {
+func generateNotes(rules *PackingRules, duration int, groupSize int, weatherRange PackingWeatherRange) []string {
 	var notes []string
 
 	// Add duration note
-	var durationCategory string
-	switch {
-	case duration <= 2:
-		durationCategory = "weekend"
-	case duration <= 7:
-		durationCategory = "week"
-	case duration <= 14:
-		durationCategory = "two_weeks"
-	default:
-		durationCategory = "month"
-	}
-
-	if durationRule, exists := rules.DurationRules[durationCategory]; exists {
+	if durationRule, exists := rules.DurationRules[durationCategory(duration)]; exists {
 		if durationMap, ok := durationRule.(map[string]interface{}); ok {
 			if note, ok := durationMap["notes"].(string); ok {
 				notes = append(notes, note)
@@ -454,7 +537,11 @@ func generateNotes(rules *PackingRules, duration int, groupSize int, weatherCate
 	}
 
 	// Add weather note
-	notes = append(notes, fmt.Sprintf("Weather is expected to be %s, pack accordingly", weatherCategory))
+	if categories := weatherRange.Categories; len(categories) > 1 {
+		notes = append(notes, fmt.Sprintf("Temperatures should feel between %.0f°C and %.0f°C, so pack layers for %s to %s weather", weatherRange.Low, weatherRange.High, categories[0], categories[len(categories)-1]))
+	} else if len(categories) == 1 {
+		notes = append(notes, fmt.Sprintf("Weather is expected to be %s, pack accordingly", categories[0]))
+	}
 
 	return notes
 }
@@ -464,95 +551,97 @@ func generatePackingListID(destination, startDate string) string {
 	return fmt.Sprintf("packing-%s-%s", strings.ToLower(strings.ReplaceAll(destination, " ", "-")), startDate)
 }
 
-// SavePackingList saves a packing list to GCS or local storage
-func SavePackingList(packingList PackingResponse) error {
-	// Try to save to GCS first
-	if gcsClient := GetGCSClient(); gcsClient != nil {
-		ctx := context.Background()
-		objectName := fmt.Sprintf("packing_lists/%s.json", packingList.ID)
+// PackingListCollection is the storage collection for packing lists
+const PackingListCollection = "packing_lists"
 
-		if err := gcsClient.UploadJSON(ctx, objectName, packingList); err == nil {
-			return nil
-		}
-		// If GCS fails, fall back to local storage
-	}
-
-	// Fallback to local storage
-	return savePackingListLocal(packingList)
-}
+// SavePackingList saves a packing list to GCS or local storage, bumping its version
+func SavePackingList(ctx context.Context, packingList *PackingResponse) error {
+	unlock := lockDocument(PackingListCollection, packingList.ID)
+	defer unlock()
 
-// savePackingListLocal saves a packing list to local file system
-func savePackingListLocal(packingList PackingResponse) error {
-	// Create the data directory if it doesn't exist
-	dataDir := "data/packing_lists"
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		return fmt.Errorf("failed to create data directory: %w", err)
+	packingList.Version = 1
+	if previous, err := loadPackingList(ctx, packingList.ID); err == nil {
+		packingList.Version = previous.Version + 1
 	}
+	return saveDocument(ctx, PackingListCollection, packingList.ID, packingList)
+}
 
-	// Create filename based on the packing list ID
-	filename := filepath.Join(dataDir, fmt.Sprintf("%s.json", packingList.ID))
+// SavePackingListIfVersion saves a packing list only if the stored copy is still at the
+// expected version, returning a *VersionConflictError otherwise
+func SavePackingListIfVersion(ctx context.Context, packingList *PackingResponse, expected int) error {
+	unlock := lockDocument(PackingListCollection, packingList.ID)
+	defer unlock()
 
-	// Convert the packing list to JSON
-	jsonData, err := json.MarshalIndent(packingList, "", "  ")
+	previous, err := GetPackingList(ctx, packingList.ID)
 	if err != nil {
-		return fmt.Errorf("failed to marshal packing list to JSON: %w", err)
+		return err
 	}
-
-	// Write the JSON data to file
-	if err := os.WriteFile(filename, jsonData, 0644); err != nil {
-		return fmt.Errorf("failed to write packing list to file: %w", err)
+	if previous.Version != expected {
+		return &VersionConflictError{Resource: "packing list", ID: packingList.ID, Expected: expected, Current: previous.Version, Document: previous}
 	}
 
-	return nil
+	packingList.Version = previous.Version + 1
+	return saveDocument(ctx, PackingListCollection, packingList.ID, packingList)
 }
 
-// GetPackingList retrieves a packing list by ID from GCS or local storage
-func GetPackingList(id string) (PackingResponse, error) {
-	// Try to get from GCS first
-	if gcsClient := GetGCSClient(); gcsClient != nil {
-		ctx := context.Background()
-		objectName := fmt.Sprintf("packing_lists/%s.json", id)
-
-		var packingList PackingResponse
-		if err := gcsClient.DownloadJSON(ctx, objectName, &packingList); err == nil {
-			return packingList, nil
-		}
-		// If GCS fails, fall back to local storage
+// ReplacePackingList saves an edit of a stored packing list without a version check. A
+// missing or trashed list isn't recreated.
+func ReplacePackingList(ctx context.Context, packingList *PackingResponse) error {
+	unlock := lockDocument(PackingListCollection, packingList.ID)
+	defer unlock()
+
+	previous, err := GetPackingList(ctx, packingList.ID)
+	if err != nil {
+		return err
 	}
 
-	// Fallback to local storage
-	return getPackingListLocal(id)
+	packingList.Version = previous.Version + 1
+	return saveDocument(ctx, PackingListCollection, packingList.ID, packingList)
 }
 
-// getPackingListLocal retrieves a packing list by ID from local file system
-func getPackingListLocal(id string) (PackingResponse, error) {
-	// Create the filename based on the packing list ID
-	filename := filepath.Join("data/packing_lists", fmt.Sprintf("%s.json", id))
-
-	// Check if the file exists
-	if _, err := os.Stat(filename); os.IsNotExist(err) {
-		return PackingResponse{}, fmt.Errorf("packing list with ID '%s' not found", id)
+// GetPackingList retrieves a packing list by ID from GCS or local storage
+func GetPackingList(ctx context.Context, id string) (PackingResponse, error) {
+	packingList, err := loadPackingList(ctx, id)
+	if err != nil {
+		return PackingResponse{}, err
 	}
 
-	// Read the JSON file
-	jsonData, err := os.ReadFile(filename)
-	if err != nil {
-		return PackingResponse{}, fmt.Errorf("failed to read packing list file: %w", err)
+	if packingList.DeletedAt != nil {
+		return PackingResponse{}, fmt.Errorf("packing list with ID '%s': %w", id, ErrInTrash)
 	}
 
-	// Unmarshal the JSON data into a PackingResponse struct
+	return packingList, nil
+}
+
+// loadPackingList loads a packing list regardless of its trash state
+func loadPackingList(ctx context.Context, id string) (PackingResponse, error) {
 	var packingList PackingResponse
-	if err := json.Unmarshal(jsonData, &packingList); err != nil {
-		return PackingResponse{}, fmt.Errorf("failed to unmarshal packing list JSON: %w", err)
+	if err := loadDocument(ctx, PackingListCollection, id, &packingList); err != nil {
+		if errors.Is(err, ErrDocumentNotFound) {
+			return PackingResponse{}, fmt.Errorf("packing list with ID '%s' not found: %w", id, ErrDocumentNotFound)
+		}
+		return PackingResponse{}, err
 	}
 
 	return packingList, nil
 }
 
+// DeletePackingList moves a packing list to the trash
+func DeletePackingList(ctx context.Context, id string) error {
+	packingList, err := GetPackingList(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	packingList.DeletedAt = &now
+	return SavePackingList(ctx, &packingList)
+}
+
 // GetPackingSuggestions gets packing suggestions based on destination, season, and activities
-func GetPackingSuggestions(destination, season string, activities []string) ([]interface{}, error) {
+func GetPackingSuggestions(ctx context.Context, destination, season string, activities []string) ([]interface{}, error) {
 	// Load packing rules
-	rules, err := loadPackingRules()
+	rules, err := loadPackingRules(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load packing rules: %w", err)
 	}
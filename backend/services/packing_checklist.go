@@ -0,0 +1,254 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"math"
+	"path/filepath"
+	"strings"
+)
+
+// PackingLayoutChecklist prints a packing list as a table of empty checkboxes
+const PackingLayoutChecklist = "checklist"
+
+// TemplatesDir holds the HTML templates for printable documents
+const TemplatesDir = "templates"
+
+// PackingChecklist is a packing list arranged for printing: one row per item, grouped by
+// category, with a checkbox column per traveler
+type PackingChecklist struct {
+	Title       string
+	Destination string
+	Columns     []string // "Shared" and each traveler, or "Packed" for a single list
+	Categories  []ChecklistCategory
+	Notes       []string
+	Guidance    []DailyOutfit // day-by-day outfits, when the list has them
+}
+
+// ChecklistCategory is a category heading and its rows
+type ChecklistCategory struct {
+	Name string
+	Rows []ChecklistRow
+}
+
+// ChecklistRow is an item with the quantity each column packs; an empty mark means the
+// column doesn't pack the item
+type ChecklistRow struct {
+	Item  string
+	Marks []string
+}
+
+// RowCount is the number of printed rows, counting category headings
+func (c *PackingChecklist) RowCount() int {
+	rows := len(c.Categories)
+	for _, category := range c.Categories {
+		rows += len(category.Rows)
+	}
+	return rows
+}
+
+// PackingListChecklist arranges a packing list as a checklist. part limits it to one
+// traveler, or "shared", as for exports; otherwise group lists get a column per traveler.
+func PackingListChecklist(packingList PackingResponse, part string) (*PackingChecklist, error) {
+	if part != "" {
+		heading, categories, err := PackingListPart(packingList, part)
+		if err != nil {
+			return nil, err
+		}
+		checklist := BuildPackingChecklist(fmt.Sprintf("Packing Checklist - %s", heading), packingList.Destination, categories, nil, packingList.Notes)
+		checklist.Guidance = packingList.DailyGuidance
+		return checklist, nil
+	}
+
+	categories, err := packingCategories(packingList)
+	if err != nil {
+		return nil, err
+	}
+	checklist := BuildPackingChecklist("Packing Checklist", packingList.Destination, categories, packingList.Travelers, packingList.Notes)
+	checklist.Guidance = packingList.DailyGuidance
+	return checklist, nil
+}
+
+// BuildPackingChecklist merges the shared categories and each traveler's categories into
+// checklist rows, so an item several travelers pack appears once with a box per traveler
+func BuildPackingChecklist(title, destination string, shared []PackingCategory, travelers []TravelerPackingList, notes []string) *PackingChecklist {
+	checklist := &PackingChecklist{Title: title, Destination: destination, Notes: notes}
+	if len(travelers) == 0 {
+		checklist.Columns = []string{"Packed"}
+	} else {
+		checklist.Columns = []string{"Shared"}
+		for _, traveler := range travelers {
+			checklist.Columns = append(checklist.Columns, traveler.Name)
+		}
+	}
+
+	categoryIndex := make(map[string]int)
+	rowIndex := make(map[string]int)
+	add := func(column int, categories []PackingCategory) {
+		for _, category := range categories {
+			key := strings.ToLower(category.Name)
+			c, ok := categoryIndex[key]
+			if !ok {
+				c = len(checklist.Categories)
+				categoryIndex[key] = c
+				checklist.Categories = append(checklist.Categories, ChecklistCategory{Name: category.Name})
+			}
+			for _, item := range category.Items {
+				itemKey := key + "/" + strings.ToLower(item.Name)
+				r, ok := rowIndex[itemKey]
+				if !ok {
+					r = len(checklist.Categories[c].Rows)
+					rowIndex[itemKey] = r
+					checklist.Categories[c].Rows = append(checklist.Categories[c].Rows, ChecklistRow{
+						Item:  item.Name,
+						Marks: make([]string, len(checklist.Columns)),
+					})
+				}
+				checklist.Categories[c].Rows[r].Marks[column] = checklistQuantity(item.Quantity)
+			}
+		}
+	}
+
+	add(0, shared)
+	for i, traveler := range travelers {
+		add(i+1, traveler.Categories)
+	}
+	return checklist
+}
+
+// checklistQuantity is the mark printed beside a checkbox
+func checklistQuantity(quantity int) string {
+	if quantity > 1 {
+		return fmt.Sprintf("x%d", quantity)
+	}
+	return "x1"
+}
+
+// writePackingChecklistPDF draws the checklist as a table, sizing rows so most lists fit
+// on one or two pages, and repeating the column headings on each page
+func writePackingChecklistPDF(pdf *pdfDocument, checklist *PackingChecklist) {
+	left, top, right, bottom := pdf.GetMargins()
+	pageWidth, pageHeight := pdf.GetPageSize()
+	usable := pageHeight - top - bottom - 30
+
+	// Shrink rows to keep the list on one page while they stay legible, then spread it over two
+	rowHeight := 6.0
+	if rows := float64(checklist.RowCount()); rows*rowHeight > usable {
+		rowHeight = usable / rows
+		if rowHeight < 4.5 {
+			rowHeight = max(4.0, math.Min(6.0, 2*usable/rows))
+		}
+	}
+	fontSize := max(7.0, math.Min(10.0, rowHeight*1.6))
+
+	columnWidth := 22.0
+	if n := float64(len(checklist.Columns)); n*columnWidth > 100 {
+		columnWidth = 100 / n
+	}
+	itemWidth := pageWidth - left - right - columnWidth*float64(len(checklist.Columns))
+
+	pdf.SetFont("Arial", "B", 16)
+	pdf.Cell(0, 10, checklist.Title)
+	pdf.Ln(10)
+	pdf.SetFont("Arial", "", 11)
+	pdf.Cell(0, 6, fmt.Sprintf("Destination: %s", checklist.Destination))
+	pdf.Ln(9)
+
+	header := func() {
+		pdf.SetFont("Arial", "B", fontSize)
+		pdf.SetFillColor(230, 230, 230)
+		pdf.CellFormat(itemWidth, rowHeight+1, "Item", "1", 0, "L", true, 0, "")
+		for _, column := range checklist.Columns {
+			pdf.CellFormat(columnWidth, rowHeight+1, column, "1", 0, "C", true, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+	// newRow starts a page with the column headings when the row won't fit
+	newRow := func() {
+		if pdf.GetY()+rowHeight > pageHeight-bottom {
+			pdf.AddPage()
+			header()
+		}
+	}
+
+	autoBreak, margin := pdf.GetAutoPageBreak()
+	pdf.SetAutoPageBreak(false, margin)
+	defer pdf.SetAutoPageBreak(autoBreak, margin)
+
+	header()
+	box := math.Min(3.5, rowHeight-1.5)
+	for _, category := range checklist.Categories {
+		newRow()
+		pdf.SetFont("Arial", "B", fontSize)
+		pdf.SetFillColor(245, 245, 245)
+		pdf.CellFormat(itemWidth+columnWidth*float64(len(checklist.Columns)), rowHeight, category.Name, "1", 1, "L", true, 0, "")
+
+		pdf.SetFont("Arial", "", fontSize)
+		for _, row := range category.Rows {
+			newRow()
+			y := pdf.GetY()
+			pdf.CellFormat(itemWidth, rowHeight, row.Item, "1", 0, "L", false, 0, "")
+			for i, mark := range row.Marks {
+				x := left + itemWidth + float64(i)*columnWidth
+				pdf.CellFormat(columnWidth, rowHeight, "", "1", 0, "C", false, 0, "")
+				if mark == "" {
+					continue
+				}
+				pdf.Rect(x+2, y+(rowHeight-box)/2, box, box, "D")
+				if mark != "x1" {
+					pdf.SetXY(x+2+box, y)
+					pdf.CellFormat(columnWidth-2-box, rowHeight, mark, "", 0, "C", false, 0, "")
+				}
+				pdf.SetXY(x+columnWidth, y)
+			}
+			pdf.Ln(-1)
+		}
+	}
+
+	if len(checklist.Notes) > 0 {
+		pdf.Ln(3)
+		pdf.SetFont("Arial", "", fontSize)
+		for _, note := range checklist.Notes {
+			newRow()
+			pdf.CellFormat(0, rowHeight, "• "+note, "", 1, "L", false, 0, "")
+		}
+	}
+
+	if len(checklist.Guidance) > 0 {
+		pdf.SetAutoPageBreak(autoBreak, margin)
+		pdf.Ln(3)
+		writeDailyGuidancePDF(pdf, checklist.Guidance, fontSize)
+	}
+}
+
+// writeDailyGuidancePDF prints a day-by-day outfit section
+func writeDailyGuidancePDF(pdf *pdfDocument, guidance []DailyOutfit, fontSize float64) {
+	pdf.SetFont("Arial", "B", fontSize+2)
+	pdf.Cell(0, 8, "Day-by-Day Outfit Guidance")
+	pdf.Ln(9)
+	for _, day := range guidance {
+		pdf.SetFont("Arial", "B", fontSize)
+		pdf.Cell(0, 5, fmt.Sprintf("%s: %s", day.Label(), day.Summary))
+		pdf.Ln(5)
+		pdf.SetFont("Arial", "", fontSize)
+		pdf.MultiCell(0, 5, "Wear: "+strings.Join(day.Wear, ", "), "", "L", false)
+		pdf.Ln(1)
+	}
+}
+
+// RenderPackingChecklistHTML renders the checklist with the printable HTML template
+func RenderPackingChecklistHTML(checklist *PackingChecklist) ([]byte, error) {
+	tmpl, err := template.New("packing_checklist.html").
+		Funcs(template.FuncMap{"add": func(a, b int) int { return a + b }, "join": strings.Join}).
+		ParseFiles(filepath.Join(TemplatesDir, "packing_checklist.html"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load checklist template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, checklist); err != nil {
+		return nil, fmt.Errorf("failed to render checklist: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"sort"
+	"strings"
+)
+
+// Category ranks for a stable list order; activity gear and special needs sort by name
+// within their rank
+const (
+	rankEssentials = iota
+	rankWeather
+	rankActivityGear
+	rankAgeSpecific
+	rankSpecialNeeds
+	rankHealth
+	rankOther
+)
+
+// consolidatePackingCategories merges items that several rules produced, such as a rain
+// jacket for both mild weather and hiking. Categories are put in a stable order and each
+// item is kept once, in the first category listing it, with the larger quantity and all
+// of the reasons. Emptied categories are dropped.
+func consolidatePackingCategories(categories []PackingCategory) []PackingCategory {
+	ordered := append([]PackingCategory{}, categories...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		ri, rj := packingCategoryRank(ordered[i].Name), packingCategoryRank(ordered[j].Name)
+		if ri != rj {
+			return ri < rj
+		}
+		if ri == rankActivityGear || ri == rankSpecialNeeds {
+			return ordered[i].Name < ordered[j].Name
+		}
+		return false
+	})
+
+	type location struct{ category, item int }
+	seen := map[string]location{}
+	consolidated := make([]PackingCategory, 0, len(ordered))
+	for _, category := range ordered {
+		// Categories with the same name, e.g. from an import, are merged as well
+		ci := -1
+		for i := range consolidated {
+			if consolidated[i].Name == category.Name {
+				ci = i
+			}
+		}
+		if ci < 0 {
+			ci = len(consolidated)
+			consolidated = append(consolidated, PackingCategory{Name: category.Name, Items: []PackingItem{}})
+		}
+
+		for _, item := range category.Items {
+			key := normalizePackingItem(item.Name)
+			if at, ok := seen[key]; ok {
+				existing := &consolidated[at.category].Items[at.item]
+				existing.Quantity = max(existing.Quantity, item.Quantity)
+				existing.Reason = combineReasons(existing.Reason, item.Reason)
+				continue
+			}
+			seen[key] = location{ci, len(consolidated[ci].Items)}
+			consolidated[ci].Items = append(consolidated[ci].Items, item)
+		}
+	}
+
+	result := consolidated[:0]
+	for _, category := range consolidated {
+		if len(category.Items) > 0 {
+			result = append(result, category)
+		}
+	}
+	return result
+}
+
+// packingCategoryRank places generated category names in list order
+func packingCategoryRank(name string) int {
+	switch {
+	case name == "Essentials":
+		return rankEssentials
+	case name == "Weather-Appropriate Clothing":
+		return rankWeather
+	case strings.HasSuffix(name, " Gear"):
+		return rankActivityGear
+	case name == HealthCategory:
+		return rankHealth
+	case name == "Age-Specific Items":
+		return rankAgeSpecific
+	case strings.HasSuffix(name, " Items"):
+		return rankSpecialNeeds
+	default:
+		return rankOther
+	}
+}
+
+// combineReasons joins two "; "-separated reason lists, skipping repeats
+func combineReasons(a, b string) string {
+	reasons := []string{}
+	for _, reason := range strings.Split(a+"; "+b, "; ") {
+		reason = strings.TrimSpace(reason)
+		if reason == "" {
+			continue
+		}
+		duplicate := false
+		for _, existing := range reasons {
+			if strings.EqualFold(existing, reason) {
+				duplicate = true
+			}
+		}
+		if !duplicate {
+			reasons = append(reasons, reason)
+		}
+	}
+	return strings.Join(reasons, "; ")
+}
@@ -0,0 +1,225 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/joshndala/cantrip/utils"
+)
+
+// SharedItemsCategory holds the items a group packs once rather than per person
+const SharedItemsCategory = "Shared Items"
+
+// PackingPartShared selects the shared items when exporting a group list
+const PackingPartShared = "shared"
+
+// ErrInvalidTravelers is returned when traveler names are repeated or reserved
+var ErrInvalidTravelers = errors.New("invalid travelers")
+
+// Fallbacks when packing_rules.json has no group_rules.shared entry
+var (
+	defaultSharedKeywords     = []string{"first aid", "charger", "adapter", "power bank", "sunscreen"}
+	defaultSharedPerTravelers = 4
+)
+
+// packingPartSlugExpr matches runs of characters not allowed in export file names
+var packingPartSlugExpr = regexp.MustCompile(`[^a-z0-9]+`)
+
+// generateGroupPackingList builds a personal list for each traveler and moves items the
+// group can share, such as first aid kits and chargers, into a single shared list
+func generateGroupPackingList(ctx context.Context, req PackingRequest, weather WeatherInfo, rules *PackingRules, weatherRange PackingWeatherRange, forecast []WeatherForecast, duration int) (PackingResponse, error) {
+	keywords, perTravelers := sharedItemRules(rules)
+
+	// Medications belong to one traveler's list
+	medications := map[string][]Medication{}
+	for _, medication := range req.Medications {
+		traveler := strings.ToLower(strings.TrimSpace(medication.Traveler))
+		if traveler == "" {
+			return PackingResponse{}, fmt.Errorf("%w: medication %q needs a traveler on group lists", ErrInvalidTravelers, medication.Name)
+		}
+		medications[traveler] = append(medications[traveler], medication)
+	}
+
+	var shared []PackingCategory
+	sharedIndex := map[string]int{} // normalized name -> index in shared[0].Items
+	travelers := make([]TravelerPackingList, 0, len(req.Travelers))
+	// Everyone needs rain gear on a wet trip; health notes are collected across travelers
+	rain, notes := rainPackingCategory(forecast)
+	seen := map[string]bool{}
+
+	for i, traveler := range req.Travelers {
+		name := strings.TrimSpace(traveler.Name)
+		if name == "" {
+			name = fmt.Sprintf("Traveler %d", i+1)
+		}
+		if seen[strings.ToLower(name)] {
+			return PackingResponse{}, fmt.Errorf("%w: duplicate traveler name %q", ErrInvalidTravelers, name)
+		}
+		if strings.EqualFold(name, PackingPartShared) {
+			return PackingResponse{}, fmt.Errorf("%w: %q is reserved for the shared items", ErrInvalidTravelers, name)
+		}
+		seen[strings.ToLower(name)] = true
+
+		ageGroup := firstNonEmpty(traveler.AgeGroup, req.AgeGroup, "adult")
+		activities := traveler.Activities
+		if len(activities) == 0 {
+			activities = req.Activities
+		}
+		specialNeeds := traveler.SpecialNeeds
+		if len(specialNeeds) == 0 {
+			specialNeeds = req.SpecialNeeds
+		}
+
+		health, healthNotes := healthPackingCategory(ctx, rules, req.Destination, req.StartDate.String(), req.EndDate.String(), activities)
+		for _, note := range healthNotes {
+			if !utils.Contains(notes, note) {
+				notes = append(notes, note)
+			}
+		}
+
+		categories := buildPackingCategories(rules, weatherRange.Categories, activities, ageGroup, specialNeeds, req.BaggageType, health, rain)
+		applyDurationMultiplier(categories, rules, duration)
+		categories = addMedications(categories, rules, medications[strings.ToLower(name)], duration)
+		delete(medications, strings.ToLower(name))
+
+		// Pull shared items out of the personal list, keeping the largest quantity
+		personal := []PackingCategory{}
+		for _, category := range categories {
+			kept := PackingCategory{Name: category.Name, Items: []PackingItem{}}
+			for _, item := range category.Items {
+				if item.Medication || !containsAny(strings.ToLower(item.Name), keywords) {
+					kept.Items = append(kept.Items, item)
+					continue
+				}
+				if len(shared) == 0 {
+					shared = []PackingCategory{{Name: SharedItemsCategory, Items: []PackingItem{}}}
+				}
+				key := normalizePackingItem(item.Name)
+				if j, ok := sharedIndex[key]; ok {
+					shared[0].Items[j].Quantity = max(shared[0].Items[j].Quantity, item.Quantity)
+					continue
+				}
+				sharedIndex[key] = len(shared[0].Items)
+				shared[0].Items = append(shared[0].Items, PackingItem{Name: item.Name, Quantity: item.Quantity, Reason: "Shared by the group"})
+			}
+			if len(kept.Items) > 0 {
+				personal = append(personal, kept)
+			}
+		}
+
+		travelers = append(travelers, TravelerPackingList{
+			Name:         name,
+			AgeGroup:     ageGroup,
+			Categories:   personal,
+			TotalItems:   countPackingItems(personal),
+			BaggageSplit: splitBaggage(rules, req.BaggageType, personal),
+		})
+	}
+
+	for traveler := range medications {
+		// Anything left over is for someone not on the list
+		return PackingResponse{}, fmt.Errorf("%w: medication for unknown traveler %q", ErrInvalidTravelers, traveler)
+	}
+
+	// One set of shared items covers every few travelers
+	sets := int(math.Ceil(float64(len(travelers)) / float64(perTravelers)))
+	for i := range shared {
+		for j := range shared[i].Items {
+			shared[i].Items[j].Quantity *= sets
+		}
+	}
+
+	// The car and its gear are shared; luggage space counts every traveler's bulky items
+	everything := append([]PackingCategory{}, shared...)
+	for _, traveler := range travelers {
+		everything = append(everything, traveler.Categories...)
+	}
+	roadTrip, roadTripNotes := roadTripPackingCategory(ctx, rules, req.Vehicle, req.StartDate.String(), req.EndDate.String(), len(travelers), everything)
+	if len(roadTrip.Items) > 0 {
+		shared = append(shared, roadTrip)
+	}
+
+	totalItems := countPackingItems(shared)
+	for _, traveler := range travelers {
+		totalItems += traveler.TotalItems
+	}
+
+	notes = append(generateNotes(rules, duration, len(travelers), weatherRange), notes...)
+	if len(shared) > 0 {
+		notes = append(notes, fmt.Sprintf("%d shared item(s) are listed once for the group", len(shared[0].Items)))
+	}
+	notes = append(notes, roadTripNotes...)
+
+	response := PackingResponse{
+		ID:           generatePackingListID(req.Destination, req.StartDate.String()),
+		Destination:  req.Destination,
+		Categories:   packingCategoriesInterface(shared),
+		Travelers:    travelers,
+		TotalItems:   totalItems,
+		Notes:        notes,
+		Weather:      weather,
+		WeatherRange: &weatherRange,
+		// The shared items are split here; each traveler's own items are split on their list
+		BaggageSplit:    splitBaggage(rules, req.BaggageType, shared),
+		RefillReminders: planRefillReminders(rules, req.Medications, req.StartDate.String(), duration),
+	}
+	if req.DailyGuidance {
+		response.DailyGuidance = dailyOutfitGuidance(rules, forecast)
+	}
+	return response, nil
+}
+
+// sharedItemRules reads the shared item keywords and travelers per shared set
+func sharedItemRules(rules *PackingRules) ([]string, int) {
+	keywords, perTravelers := defaultSharedKeywords, defaultSharedPerTravelers
+	sharedRule, ok := rules.GroupRules["shared"].(map[string]interface{})
+	if !ok {
+		return keywords, perTravelers
+	}
+	if list, ok := sharedRule["keywords"].([]interface{}); ok && len(list) > 0 {
+		keywords = make([]string, 0, len(list))
+		for _, keyword := range list {
+			if k, ok := keyword.(string); ok {
+				keywords = append(keywords, strings.ToLower(k))
+			}
+		}
+	}
+	if n, ok := sharedRule["per_travelers"].(float64); ok && n >= 1 {
+		perTravelers = int(n)
+	}
+	return keywords, perTravelers
+}
+
+// PackingListPart returns the categories of one part of a group list: the shared items,
+// or a traveler's personal items by case-insensitive name
+func PackingListPart(packingList PackingResponse, part string) (string, []PackingCategory, error) {
+	if strings.EqualFold(part, PackingPartShared) {
+		categories, err := packingCategories(packingList)
+		return SharedItemsCategory, categories, err
+	}
+	for _, traveler := range packingList.Travelers {
+		if strings.EqualFold(traveler.Name, part) {
+			return traveler.Name, traveler.Categories, nil
+		}
+	}
+	return "", nil, fmt.Errorf("no traveler named %q on packing list %s: %w", part, packingList.ID, ErrDocumentNotFound)
+}
+
+// packingPartSlug makes a traveler name safe for file names
+func packingPartSlug(part string) string {
+	return strings.Trim(packingPartSlugExpr.ReplaceAllString(strings.ToLower(part), "-"), "-")
+}
+
+// firstNonEmpty returns the first non-empty value
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
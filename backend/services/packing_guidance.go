@@ -0,0 +1,60 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/joshndala/cantrip/models"
+)
+
+// DailyOutfit is what to wear on one day of a trip, from that day's forecast
+type DailyOutfit = models.DailyOutfit
+
+// dailyOutfitClothing is how many of the weather rule's clothing items are suggested a day
+const dailyOutfitClothing = 3
+
+// dailyOutfitGuidance suggests an outfit for each forecast day: clothing for how cold the
+// day feels, plus rain or snow gear when the day looks wet
+func dailyOutfitGuidance(rules *PackingRules, forecast []WeatherForecast) []DailyOutfit {
+	guidance := make([]DailyOutfit, 0, len(forecast))
+	for i, day := range forecast {
+		coldest, warmest := getWeatherCategory(day.FeelsLikeLow), getWeatherCategory(day.FeelsLikeHigh)
+
+		var wear []string
+		for _, item := range getWeatherItems(rules, coldest) {
+			if len(wear) == dailyOutfitClothing {
+				break
+			}
+			wear = append(wear, item.Name)
+		}
+
+		var conditions []string
+		condition := forecastCondition(day)
+		switch {
+		case condition == "snow" || condition == "heavy snow":
+			conditions = append(conditions, "snow expected")
+			wear = append(wear, "Insulated waterproof boots")
+		case condition == "rain" || condition == "heavy rain" || condition == "drizzle" || condition == "thunderstorm" ||
+			day.PrecipProbability >= UmbrellaPrecipProbability:
+			if day.PrecipProbability > 0 {
+				conditions = append(conditions, fmt.Sprintf("rain likely (%.0f%%)", day.PrecipProbability*100))
+			} else {
+				conditions = append(conditions, "rain likely")
+			}
+			wear = append(wear, "Waterproof shoes", "Umbrella")
+		}
+		if coldest != warmest {
+			conditions = append(conditions, fmt.Sprintf("layers for %s to %s", coldest, warmest))
+		}
+		conditions = append(conditions, fmt.Sprintf("feels like %.0f°C to %.0f°C", day.FeelsLikeLow, day.FeelsLikeHigh))
+
+		summary := strings.Join(conditions, ", ")
+		guidance = append(guidance, DailyOutfit{
+			Day:     i + 1,
+			Date:    day.Date,
+			Summary: strings.ToUpper(summary[:1]) + summary[1:],
+			Wear:    wear,
+		})
+	}
+	return guidance
+}
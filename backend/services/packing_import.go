@@ -0,0 +1,326 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/joshndala/cantrip/utils"
+)
+
+// ImportedCategoryFallback holds imported items that match no known category
+const ImportedCategoryFallback = "From Previous Trips"
+
+// ErrNoPackingItems is returned when an upload contains no recognizable items
+var ErrNoPackingItems = errors.New("no packing items found")
+
+// PackingImportResult reports how an uploaded list was merged
+type PackingImportResult struct {
+	PackingList PackingResponse `json:"packing_list"`
+	Imported    int             `json:"imported"`   // items read from the upload
+	Added       int             `json:"added"`      // items not already on the list
+	Duplicates  []string        `json:"duplicates"` // items the list already had
+}
+
+// importCategoryKeywords guesses a category for items imported without one
+var importCategoryKeywords = []struct {
+	category string
+	keywords []string
+}{
+	{"Documents", []string{"passport", "visa", "ticket", "boarding pass", "insurance", "license", "licence", "id card", "itinerary"}},
+	{"Electronics", []string{"charger", "adapter", "phone", "camera", "laptop", "power bank", "headphones", "earbuds", "cable", "battery"}},
+	{"Toiletries", []string{"toothbrush", "toothpaste", "shampoo", "conditioner", "deodorant", "sunscreen", "razor", "soap", "lotion", "medication", "floss"}},
+	{"Weather-Appropriate Clothing", []string{"jacket", "coat", "shirt", "t-shirt", "pants", "jeans", "shorts", "sweater", "socks", "underwear", "hat", "gloves", "scarf", "boots", "shoes", "dress", "fleece", "base layer", "raincoat"}},
+}
+
+// Lines of our own packing list PDF, e.g. "• Rain jacket (Qty: 2) - Expected showers"
+var (
+	pdfItemExpr     = regexp.MustCompile(`^(?:[•·*\-]|â€¢|\x95)?\s*(.+?)\s*\(Qty:\s*(\d+)\)(?:\s*-\s*(.*))?$`)
+	pdfHeaderPrefix = []string{"packing list", "destination:", "total items:"}
+)
+
+// ImportPackingList reads a CSV or CanTrip PDF packing list and merges it into the list
+// with packingID, or saves it as a new list when packingID is empty. Items already on the
+// list are skipped, keeping the higher quantity.
+func ImportPackingList(ctx context.Context, filename string, data []byte, packingID, destination string) (*PackingImportResult, error) {
+	var categories []PackingCategory
+	var err error
+	if packingImportIsPDF(filename, data) {
+		text := extractPDFText(data)
+		if text == "" {
+			return nil, fmt.Errorf("could not read text from PDF: %w", ErrNoPackingItems)
+		}
+		categories = parsePackingListText(text)
+	} else {
+		categories, err = parsePackingCSV(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	imported := 0
+	for _, category := range categories {
+		imported += len(category.Items)
+	}
+	if imported == 0 {
+		return nil, ErrNoPackingItems
+	}
+
+	var target PackingResponse
+	if packingID != "" {
+		if target, err = GetPackingList(ctx, packingID); err != nil {
+			return nil, err
+		}
+	} else {
+		target = PackingResponse{
+			ID:          fmt.Sprintf("packing-import-%s", utils.GenerateID()),
+			Destination: destination,
+			Notes:       []string{},
+		}
+	}
+
+	merged, err := packingCategories(target)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PackingImportResult{Imported: imported, Duplicates: []string{}}
+	merged, result.Added, result.Duplicates = mergePackingCategories(merged, categories)
+
+	target.Categories = make([]interface{}, len(merged))
+	target.TotalItems = 0
+	for i, category := range merged {
+		target.Categories[i] = category
+		for _, item := range category.Items {
+			target.TotalItems += item.Quantity
+		}
+	}
+
+	// Merges fail with a version conflict rather than overwrite a concurrent edit
+	if packingID != "" {
+		err = SavePackingListIfVersion(ctx, &target, target.Version)
+	} else {
+		err = SavePackingList(ctx, &target)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to save packing list: %w", err)
+	}
+
+	result.PackingList = target
+	return result, nil
+}
+
+// mergePackingCategories adds imported items that the list doesn't already have.
+// Matching is by normalized item name across all categories.
+func mergePackingCategories(existing, imported []PackingCategory) ([]PackingCategory, int, []string) {
+	type position struct{ category, item int }
+	index := map[string]position{}
+	for c, category := range existing {
+		for i, item := range category.Items {
+			index[normalizePackingItem(item.Name)] = position{c, i}
+		}
+	}
+
+	added := 0
+	duplicates := []string{}
+	for _, category := range imported {
+		for _, item := range category.Items {
+			key := normalizePackingItem(item.Name)
+			if key == "" {
+				continue
+			}
+			if pos, ok := index[key]; ok {
+				current := &existing[pos.category].Items[pos.item]
+				if item.Quantity > current.Quantity {
+					current.Quantity = item.Quantity
+				}
+				duplicates = append(duplicates, item.Name)
+				continue
+			}
+
+			c := findPackingCategory(existing, category.Name)
+			if c < 0 {
+				existing = append(existing, PackingCategory{Name: category.Name})
+				c = len(existing) - 1
+			}
+			existing[c].Items = append(existing[c].Items, item)
+			index[key] = position{c, len(existing[c].Items) - 1}
+			added++
+		}
+	}
+	return existing, added, duplicates
+}
+
+// findPackingCategory returns the index of a category by case-insensitive name, or -1
+func findPackingCategory(categories []PackingCategory, name string) int {
+	for i, category := range categories {
+		if strings.EqualFold(category.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// normalizePackingItem reduces an item name to a comparison key, so "Rain Jackets" matches "rain jacket"
+func normalizePackingItem(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.Join(strings.Fields(strings.Trim(name, ".,;:")), " ")
+	if strings.HasSuffix(name, "ses") || strings.HasSuffix(name, "shes") || strings.HasSuffix(name, "ches") {
+		return strings.TrimSuffix(name, "es")
+	}
+	if strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss") {
+		return strings.TrimSuffix(name, "s")
+	}
+	return name
+}
+
+// packingCategories decodes a stored list's categories, which load back as generic maps
+func packingCategories(packingList PackingResponse) ([]PackingCategory, error) {
+	categories := []PackingCategory{}
+	if err := remarshal(packingList.Categories, &categories); err != nil {
+		return nil, fmt.Errorf("failed to read packing list categories: %w", err)
+	}
+	return categories, nil
+}
+
+// guessPackingCategory picks a category for an item from its name
+func guessPackingCategory(name string) string {
+	lower := strings.ToLower(name)
+	for _, group := range importCategoryKeywords {
+		if containsAny(lower, group.keywords) {
+			return group.category
+		}
+	}
+	return ImportedCategoryFallback
+}
+
+// addImportedItem appends an item to the named category, creating it as needed
+func addImportedItem(categories []PackingCategory, categoryName string, item PackingItem) []PackingCategory {
+	if categoryName == "" {
+		categoryName = guessPackingCategory(item.Name)
+	}
+	if item.Quantity <= 0 {
+		item.Quantity = 1
+	}
+	if c := findPackingCategory(categories, categoryName); c >= 0 {
+		categories[c].Items = append(categories[c].Items, item)
+		return categories
+	}
+	return append(categories, PackingCategory{Name: categoryName, Items: []PackingItem{item}})
+}
+
+// parsePackingCSV reads rows of item, quantity, category and notes. A header row selects
+// the columns; without one the columns are item, quantity, category.
+func parsePackingCSV(data []byte) ([]PackingCategory, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	columns := map[string]int{"item": 0, "quantity": 1, "category": 2, "reason": -1}
+	var categories []PackingCategory
+	first := true
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+
+		if first {
+			first = false
+			if header := packingCSVHeader(record); header != nil {
+				columns = header
+				continue
+			}
+		}
+
+		field := func(name string) string {
+			if i := columns[name]; i >= 0 && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		name := field("item")
+		if name == "" {
+			continue
+		}
+		quantity, _ := strconv.Atoi(field("quantity"))
+		categories = addImportedItem(categories, field("category"), PackingItem{Name: name, Quantity: quantity, Reason: field("reason")})
+	}
+	return categories, nil
+}
+
+// packingCSVHeader maps header names to column indexes, or returns nil for a data row
+func packingCSVHeader(record []string) map[string]int {
+	aliases := map[string]string{
+		"item": "item", "name": "item", "item name": "item",
+		"quantity": "quantity", "qty": "quantity", "count": "quantity",
+		"category": "category", "section": "category",
+		"reason": "reason", "notes": "reason", "note": "reason",
+	}
+
+	columns := map[string]int{"item": -1, "quantity": -1, "category": -1, "reason": -1}
+	for i, cell := range record {
+		if column, ok := aliases[strings.ToLower(strings.TrimSpace(cell))]; ok {
+			columns[column] = i
+		}
+	}
+	if columns["item"] < 0 {
+		return nil
+	}
+	return columns
+}
+
+// parsePackingListText reads the text of a packing list PDF generated by CanTrip:
+// category headings followed by "• Item (Qty: N) - reason" lines, then a Notes section.
+func parsePackingListText(text string) []PackingCategory {
+	var categories []PackingCategory
+	category := ""
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		lower := strings.ToLower(line)
+		if strings.HasPrefix(lower, "notes:") {
+			break
+		}
+		if containsAnyPrefix(lower, pdfHeaderPrefix) {
+			continue
+		}
+
+		match := pdfItemExpr.FindStringSubmatch(line)
+		if match == nil {
+			category = line
+			continue
+		}
+		quantity, _ := strconv.Atoi(match[2])
+		categories = addImportedItem(categories, category, PackingItem{Name: match[1], Quantity: quantity, Reason: match[3]})
+	}
+	return categories
+}
+
+// containsAnyPrefix reports whether text starts with any of the prefixes
+func containsAnyPrefix(text string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(text, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// packingImportIsPDF reports whether an upload is a PDF rather than CSV
+func packingImportIsPDF(filename string, data []byte) bool {
+	return strings.EqualFold(filepath.Ext(filename), ".pdf") || bytes.HasPrefix(data, []byte("%PDF"))
+}
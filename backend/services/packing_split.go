@@ -0,0 +1,152 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/joshndala/cantrip/models"
+)
+
+// Baggage types accepted by packing requests
+const (
+	BaggageCarryOn = "carry_on"
+	BaggageChecked = "checked"
+	BaggageBoth    = "both"
+)
+
+// changeOfClothes is the outfit keyword that stands for a whole spare outfit
+const changeOfClothes = "change of clothes"
+
+// BaggageSplitRules represents the baggage_split section of packing_rules.json
+type BaggageSplitRules struct {
+	CarryOn     map[string][]string `json:"carry_on"` // reason -> keywords kept in the cabin
+	Outfit      []string            `json:"outfit"`   // one of each goes in the carry-on as a spare outfit
+	Liquids     LiquidRules         `json:"liquids"`
+	CheckedOnly []string            `json:"checked_only"` // not allowed in the cabin
+	Prohibited  []string            `json:"prohibited"`   // not allowed on the plane at all
+}
+
+// LiquidRules describes the cabin limits on liquids, gels and aerosols
+type LiquidRules struct {
+	Keywords       []string `json:"keywords"`
+	Exempt         []string `json:"exempt"` // e.g. medication, allowed above the limit
+	MaxContainerML int      `json:"max_container_ml"`
+	BagLiters      float64  `json:"bag_liters"`
+}
+
+// BaggageSplit assigns a packing list's items to the carry-on and checked bags
+type BaggageSplit = models.BaggageSplit
+
+// SplitItem is an item, or part of its quantity, placed in one bag
+type SplitItem = models.SplitItem
+
+// LiquidCheck lists the liquids in the carry-on and the limits they must meet
+type LiquidCheck = models.LiquidCheck
+
+// normalizeBaggageType accepts "carry-on" and "Carry On" as well as the rule keys
+func normalizeBaggageType(baggageType string) string {
+	return strings.NewReplacer("-", "_", " ", "_").Replace(strings.ToLower(strings.TrimSpace(baggageType)))
+}
+
+// splitBaggage sorts items between the carry-on and checked bags. With "both", documents,
+// medications, electronics, valuables and one spare outfit stay in the cabin and liquids
+// are checked; with "carry-on" everything is in the cabin and only the liquid and
+// checked-only restrictions are reported. Other baggage types have no split.
+func splitBaggage(rules *PackingRules, baggageType string, categories []PackingCategory) *BaggageSplit {
+	baggageType = normalizeBaggageType(baggageType)
+	if rules.BaggageSplit == nil || (baggageType != BaggageBoth && baggageType != BaggageCarryOn) {
+		return nil
+	}
+	splitRules := rules.BaggageSplit
+
+	split := &BaggageSplit{BaggageType: baggageType, CarryOn: []SplitItem{}, Checked: []SplitItem{}}
+	var liquids []string
+	spareOutfit := map[string]bool{} // outfit keywords already in the carry-on
+	for _, category := range categories {
+		for _, item := range category.Items {
+			place := func(bag *[]SplitItem, quantity int, reason string) {
+				if quantity > 0 {
+					*bag = append(*bag, SplitItem{Name: item.Name, Quantity: quantity, Category: category.Name, Reason: reason})
+				}
+			}
+
+			if itemMatches(item.Name, splitRules.Prohibited) {
+				split.Warnings = append(split.Warnings, fmt.Sprintf("%s isn't allowed on planes; buy it at your destination", item.Name))
+				continue
+			}
+
+			liquid := !item.Medication && itemMatches(item.Name, splitRules.Liquids.Keywords) && !itemMatches(item.Name, splitRules.Liquids.Exempt)
+			if baggageType == BaggageCarryOn {
+				if itemMatches(item.Name, splitRules.CheckedOnly) {
+					split.Warnings = append(split.Warnings, fmt.Sprintf("%s isn't allowed in carry-on bags; check a bag or buy it at your destination", item.Name))
+					continue
+				}
+				if liquid {
+					liquids = append(liquids, item.Name)
+				}
+				place(&split.CarryOn, item.Quantity, "Carry-on only")
+				continue
+			}
+
+			switch {
+			case item.Medication:
+				place(&split.CarryOn, item.Quantity, "Medications stay with you")
+			case itemMatches(item.Name, splitRules.CheckedOnly):
+				place(&split.Checked, item.Quantity, "Not allowed in the cabin")
+			case liquid:
+				place(&split.Checked, item.Quantity, fmt.Sprintf("Liquids over %d ml must be checked", splitRules.Liquids.MaxContainerML))
+			case carryOnReason(splitRules, item.Name) != "":
+				place(&split.CarryOn, item.Quantity, carryOnReason(splitRules, item.Name))
+			case outfitPiece(splitRules, item.Name) == changeOfClothes:
+				// A packed change of clothes is the spare outfit
+				for _, keyword := range splitRules.Outfit {
+					spareOutfit[keyword] = true
+				}
+				place(&split.CarryOn, item.Quantity, "Spare outfit in case checked bags are delayed")
+			case outfitPiece(splitRules, item.Name) != "" && !spareOutfit[outfitPiece(splitRules, item.Name)]:
+				// One of each kind of garment, e.g. a single shirt of all the shirts packed
+				spareOutfit[outfitPiece(splitRules, item.Name)] = true
+				place(&split.CarryOn, 1, "Spare outfit in case checked bags are delayed")
+				place(&split.Checked, item.Quantity-1, category.Name)
+			default:
+				place(&split.Checked, item.Quantity, category.Name)
+			}
+		}
+	}
+
+	if len(liquids) > 0 {
+		split.Liquids = &LiquidCheck{
+			Items:          liquids,
+			MaxContainerML: splitRules.Liquids.MaxContainerML,
+			BagLiters:      splitRules.Liquids.BagLiters,
+			Note: fmt.Sprintf("Liquids, gels and aerosols must be in containers of %d ml or less, together in one %g L clear bag; medications are exempt",
+				splitRules.Liquids.MaxContainerML, splitRules.Liquids.BagLiters),
+		}
+	}
+	return split
+}
+
+// carryOnReason names the carry-on group an item belongs to, or "" if it can be checked
+func carryOnReason(splitRules *BaggageSplitRules, name string) string {
+	for _, reason := range []string{"Medications", "Documents", "Electronics", "Valuables"} {
+		if itemMatches(name, splitRules.CarryOn[reason]) {
+			return reason + " stay with you"
+		}
+	}
+	for reason, keywords := range splitRules.CarryOn {
+		if itemMatches(name, keywords) {
+			return reason + " stay with you"
+		}
+	}
+	return ""
+}
+
+// outfitPiece returns the outfit keyword an item matches, or "" for other items
+func outfitPiece(splitRules *BaggageSplitRules, name string) string {
+	for _, keyword := range splitRules.Outfit {
+		if itemMatches(name, []string{keyword}) {
+			return keyword
+		}
+	}
+	return ""
+}
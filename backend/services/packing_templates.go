@@ -0,0 +1,260 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joshndala/cantrip/models"
+	"github.com/joshndala/cantrip/utils"
+)
+
+// PackingTemplateCollection stores users' personal templates
+const PackingTemplateCollection = "packing_templates"
+
+// Packing template errors
+var (
+	ErrCuratedTemplate        = errors.New("curated templates can't be changed")
+	ErrInvalidTemplateOptions = errors.New("invalid template options")
+)
+
+// PackingTemplate is a reusable packing list for a kind of trip
+type PackingTemplate struct {
+	ID           string            `json:"id"`
+	Name         string            `json:"name"`
+	Description  string            `json:"description,omitempty"`
+	DurationDays int               `json:"duration_days,omitempty"` // trip length the quantities are for
+	Weather      string            `json:"weather,omitempty"`
+	Activities   []string          `json:"activities,omitempty"`
+	Categories   []PackingCategory `json:"categories"`
+	Notes        []string          `json:"notes,omitempty"`
+	TotalItems   int               `json:"total_items"`
+	Curated      bool              `json:"curated"`
+	UserID       string            `json:"user_id,omitempty"`
+	SourceListID string            `json:"source_list_id,omitempty"`
+	CreatedAt    *time.Time        `json:"created_at,omitempty"`
+}
+
+// PackingTemplateOptions customizes a template into a packing list
+type PackingTemplateOptions struct {
+	Destination string         `json:"destination" binding:"required"`
+	StartDate   models.Date    `json:"start_date,omitempty"` // with end_date, rescales per-day items
+	EndDate     models.Date    `json:"end_date,omitempty"`
+	GroupSize   int            `json:"group_size,omitempty"`
+	AddItems    []TemplateItem `json:"add_items,omitempty"`
+	RemoveItems []string       `json:"remove_items,omitempty"` // item names, matched loosely
+}
+
+// TemplateItem is an item added while instantiating a template
+type TemplateItem struct {
+	Name     string `json:"name"`
+	Quantity int    `json:"quantity,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	Category string `json:"category,omitempty"` // "Other" by default
+}
+
+// SavePackingTemplateRequest saves a packing list as a personal template
+type SavePackingTemplateRequest struct {
+	Name         string `json:"name" binding:"required"`
+	Description  string `json:"description,omitempty"`
+	DurationDays int    `json:"duration_days,omitempty"`
+}
+
+// loadCuratedTemplates reads the built-in templates from the dataset
+func loadCuratedTemplates(ctx context.Context) ([]PackingTemplate, error) {
+	data, err := ReadDataset(ctx, PackingTemplatesDataset)
+	if err != nil {
+		return nil, err
+	}
+
+	var dataset struct {
+		Templates []PackingTemplate `json:"templates"`
+	}
+	if err := json.Unmarshal(data, &dataset); err != nil {
+		return nil, err
+	}
+	for i := range dataset.Templates {
+		dataset.Templates[i].Curated = true
+		dataset.Templates[i].TotalItems = countPackingItems(dataset.Templates[i].Categories)
+	}
+	return dataset.Templates, nil
+}
+
+// ListPackingTemplates returns the curated templates followed by the user's own, newest first
+func ListPackingTemplates(ctx context.Context, userID string) ([]PackingTemplate, error) {
+	templates, err := loadCuratedTemplates(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load packing templates: %w", err)
+	}
+	if userID == "" {
+		return templates, nil
+	}
+
+	ids, err := listDocumentIDs(ctx, PackingTemplateCollection)
+	if err != nil {
+		return nil, err
+	}
+	personal := []PackingTemplate{}
+	for _, id := range ids {
+		var template PackingTemplate
+		if err := loadDocument(ctx, PackingTemplateCollection, id, &template); err != nil {
+			continue
+		}
+		if template.UserID == userID {
+			personal = append(personal, template)
+		}
+	}
+	sort.Slice(personal, func(i, j int) bool {
+		return personal[i].CreatedAt.After(*personal[j].CreatedAt)
+	})
+	return append(templates, personal...), nil
+}
+
+// GetPackingTemplate returns a curated template, or one of the user's own
+func GetPackingTemplate(ctx context.Context, userID, id string) (*PackingTemplate, error) {
+	curated, err := loadCuratedTemplates(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load packing templates: %w", err)
+	}
+	for _, template := range curated {
+		if template.ID == id {
+			return &template, nil
+		}
+	}
+
+	var template PackingTemplate
+	if err := loadDocument(ctx, PackingTemplateCollection, id, &template); err != nil {
+		return nil, err
+	}
+	// Other users' templates are reported as missing
+	if template.UserID != userID {
+		return nil, fmt.Errorf("packing template %s: %w", id, ErrDocumentNotFound)
+	}
+	return &template, nil
+}
+
+// SavePackingListAsTemplate stores a copy of a packing list's items as a personal template.
+// Group lists are saved as one person's list: the shared items plus every traveler's items.
+func SavePackingListAsTemplate(ctx context.Context, userID string, packingList PackingResponse, req SavePackingTemplateRequest) (*PackingTemplate, error) {
+	categories, err := packingCategories(packingList)
+	if err != nil {
+		return nil, err
+	}
+	for _, traveler := range packingList.Travelers {
+		categories = append(categories, traveler.Categories...)
+	}
+	categories = consolidatePackingCategories(categories)
+	if len(categories) == 0 {
+		return nil, fmt.Errorf("packing list %s: %w", packingList.ID, ErrNoPackingItems)
+	}
+
+	now := time.Now().UTC()
+	template := PackingTemplate{
+		ID:           utils.GenerateID(),
+		Name:         strings.TrimSpace(req.Name),
+		Description:  req.Description,
+		DurationDays: req.DurationDays,
+		Categories:   categories,
+		Notes:        packingList.Notes,
+		TotalItems:   countPackingItems(categories),
+		UserID:       userID,
+		SourceListID: packingList.ID,
+		CreatedAt:    &now,
+	}
+	if err := saveDocument(ctx, PackingTemplateCollection, template.ID, template); err != nil {
+		return nil, fmt.Errorf("failed to save packing template: %w", err)
+	}
+	return &template, nil
+}
+
+// DeletePackingTemplate removes one of the user's own templates
+func DeletePackingTemplate(ctx context.Context, userID, id string) error {
+	template, err := GetPackingTemplate(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if template.Curated {
+		return fmt.Errorf("packing template %s: %w", id, ErrCuratedTemplate)
+	}
+	return deleteDocument(ctx, PackingTemplateCollection, id)
+}
+
+// InstantiatePackingTemplate builds a packing list from a template. Items packed more than
+// once are rescaled to the trip length, then the group multiplier and the caller's
+// additions and removals are applied.
+func InstantiatePackingTemplate(ctx context.Context, template PackingTemplate, opts PackingTemplateOptions, weather WeatherInfo) (PackingResponse, error) {
+	rules, err := loadPackingRules(ctx)
+	if err != nil {
+		return PackingResponse{}, fmt.Errorf("failed to load packing rules: %w", err)
+	}
+
+	var categories []PackingCategory
+	if err := remarshal(template.Categories, &categories); err != nil {
+		return PackingResponse{}, fmt.Errorf("failed to copy template: %w", err)
+	}
+
+	duration := template.DurationDays
+	if !opts.StartDate.IsZero() || !opts.EndDate.IsZero() {
+		if err := CheckTripDates(ctx, opts.Destination, opts.StartDate, opts.EndDate, ""); err != nil {
+			return PackingResponse{}, fmt.Errorf("%w: %w", ErrInvalidTemplateOptions, err)
+		}
+		span, _ := tripSpan(opts.StartDate.String(), opts.EndDate.String())
+		duration = span.Days
+	}
+	if template.DurationDays > 0 && duration > 0 && duration != template.DurationDays {
+		scale := float64(duration) / float64(template.DurationDays)
+		for i := range categories {
+			for j := range categories[i].Items {
+				if item := &categories[i].Items[j]; item.Quantity > 1 {
+					item.Quantity = max(1, int(math.Ceil(float64(item.Quantity)*scale)))
+				}
+			}
+		}
+	}
+
+	if opts.GroupSize > 0 {
+		applyGroupMultiplier(categories, rules, opts.GroupSize)
+	}
+
+	removed := map[string]bool{}
+	for _, name := range opts.RemoveItems {
+		removed[normalizePackingItem(name)] = true
+	}
+	for i := range categories {
+		kept := categories[i].Items[:0]
+		for _, item := range categories[i].Items {
+			if !removed[normalizePackingItem(item.Name)] {
+				kept = append(kept, item)
+			}
+		}
+		categories[i].Items = kept
+	}
+
+	for _, item := range opts.AddItems {
+		if strings.TrimSpace(item.Name) == "" {
+			continue
+		}
+		categories = append(categories, PackingCategory{
+			Name:  firstNonEmpty(item.Category, "Other"),
+			Items: []PackingItem{{Name: item.Name, Quantity: max(item.Quantity, 1), Reason: firstNonEmpty(item.Reason, "Added to the template")}},
+		})
+	}
+	categories = consolidatePackingCategories(categories)
+
+	notes := append([]string{fmt.Sprintf("Based on the %s template", template.Name)}, template.Notes...)
+	startKey := firstNonEmpty(opts.StartDate.String(), template.ID)
+
+	return PackingResponse{
+		ID:          generatePackingListID(opts.Destination, startKey),
+		Destination: opts.Destination,
+		Categories:  packingCategoriesInterface(categories),
+		TotalItems:  countPackingItems(categories),
+		Notes:       notes,
+		Weather:     weather,
+	}, nil
+}
@@ -0,0 +1,27 @@
+package services
+
+import (
+	"context"
+	"testing"
+)
+
+func BenchmarkPackingRules(b *testing.B) {
+	rules, err := loadPackingRules(context.Background())
+	if err != nil {
+		b.Fatalf("load packing rules: %v", err)
+	}
+	weatherRange := PackingWeatherRange{Low: 4, High: 22, Categories: []string{"cold", "cool", "mild", "warm"}}
+	activities := []string{"outdoor_adventure", "city_exploration", "beach", "formal"}
+	specialNeeds := []string{"accessibility", "medical"}
+	const duration, groupSize = 10, 4
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		categories := buildPackingCategories(rules, weatherRange.Categories, activities, "adult", specialNeeds, "checked")
+		applyDurationMultiplier(categories, rules, duration)
+		applyGroupMultiplier(categories, rules, groupSize)
+		countPackingItems(categories)
+		generateNotes(rules, duration, groupSize, weatherRange)
+		splitBaggage(rules, "both", categories)
+	}
+}
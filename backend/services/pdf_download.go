@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrPDFNotFound is returned when a PDF's file is in neither local storage nor the object store
+var ErrPDFNotFound = errors.New("PDF file not found")
+
+// PDFFile is an open PDF ready to stream. Content seeks without reading the file into
+// memory, so it can serve Range requests.
+type PDFFile struct {
+	Filename string
+	Type     string // itinerary, packing, tips
+	Size     int64
+	ModTime  time.Time
+	Content  io.ReadSeekCloser
+}
+
+// OpenPDF opens a generated PDF from local storage, falling back to streaming it from the object store
+func OpenPDF(ctx context.Context, id string) (*PDFFile, error) {
+	metadata, err := GetPDFMetadata(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPDFNotFound, err)
+	}
+
+	path := filepath.Join(pdfStorageDir(ctx), metadata.Filename)
+	if file, err := os.Open(path); err == nil {
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to get file info: %w", err)
+		}
+		return &PDFFile{Filename: metadata.Filename, Type: metadata.Type, Size: info.Size(), ModTime: info.ModTime(), Content: file}, nil
+	}
+
+	if store := GetObjectStore(); store != nil {
+		objectName := fmt.Sprintf("pdfs/%s", metadata.Filename)
+		if info, err := store.GetFileInfo(ctx, objectName); err == nil {
+			return &PDFFile{
+				Filename: metadata.Filename,
+				Type:     metadata.Type,
+				Size:     info.Size,
+				ModTime:  info.Updated,
+				Content:  &objectReader{ctx: ctx, store: store, object: objectName, size: info.Size},
+			}, nil
+		}
+	}
+
+	return nil, ErrPDFNotFound
+}
+
+// objectReader reads a stored object as a seekable stream, opening a range read from the
+// current offset on the first Read after each Seek
+type objectReader struct {
+	ctx    context.Context
+	store  ObjectStore
+	object string
+	size   int64
+	offset int64
+	reader io.ReadCloser
+}
+
+func (r *objectReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.reader == nil {
+		reader, err := r.store.NewRangeReader(r.ctx, r.object, r.offset, -1)
+		if err != nil {
+			return 0, err
+		}
+		r.reader = reader
+	}
+	n, err := r.reader.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *objectReader) Seek(offset int64, whence int) (int64, error) {
+	var target int64
+	switch whence {
+	case io.SeekStart:
+		target = offset
+	case io.SeekCurrent:
+		target = r.offset + offset
+	case io.SeekEnd:
+		target = r.size + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if target < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	if target != r.offset {
+		r.closeReader()
+		r.offset = target
+	}
+	return target, nil
+}
+
+func (r *objectReader) Close() error {
+	return r.closeReader()
+}
+
+func (r *objectReader) closeReader() error {
+	if r.reader == nil {
+		return nil
+	}
+	err := r.reader.Close()
+	r.reader = nil
+	return err
+}
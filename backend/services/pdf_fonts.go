@@ -0,0 +1,151 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/joshndala/cantrip/utils"
+	"golang.org/x/text/unicode/norm"
+)
+
+// PDFUnicodeFamily is the font family registered when a UTF-8 font is available
+const PDFUnicodeFamily = "DejaVu"
+
+// pdfFontDirs are searched for DejaVu fonts when PDF_FONT_DIR is not set
+var pdfFontDirs = []string{
+	"fonts",
+	"/usr/share/fonts/truetype/dejavu", // Debian and Ubuntu fonts-dejavu-core
+	"/usr/share/fonts/dejavu",          // Alpine ttf-dejavu
+	"/usr/share/fonts/TTF",
+}
+
+// pdfFontFiles maps gofpdf font styles to DejaVu file names; a missing italic
+// falls back to the regular face
+var pdfFontFiles = map[string]string{
+	"":   "DejaVuSans.ttf",
+	"B":  "DejaVuSans-Bold.ttf",
+	"I":  "DejaVuSans-Oblique.ttf",
+	"BI": "DejaVuSans-BoldOblique.ttf",
+}
+
+// pdfCoreFallbacks replaces characters the core fonts' cp1252 encoding can't show
+var pdfCoreFallbacks = strings.NewReplacer(
+	"→", "->",
+	"←", "<-",
+	"≈", "~",
+	"≥", ">=",
+	"≤", "<=",
+	"✓", "x",
+	"✔", "x",
+	"₂", "2",
+	"\u202f", " ", // narrow no-break space used before French punctuation
+)
+
+var (
+	pdfFontsOnce sync.Once
+	pdfFontBytes map[string][]byte
+)
+
+// pdfDocument wraps a document from the configured PDF renderer so text renders correctly
+// whichever fonts are installed: with DejaVu every string is embedded as UTF-8, and
+// without it French accents are translated to the core fonts' cp1252 encoding
+type pdfDocument struct {
+	PDFEngine
+	unicode   bool
+	translate func(string) string
+}
+
+// newPDFDocument creates an A4 portrait document with UTF-8 fonts when available
+func newPDFDocument() *pdfDocument {
+	doc := &pdfDocument{PDFEngine: GetPDFRenderer().New()}
+
+	fonts := loadPDFFonts()
+	if len(fonts) > 0 {
+		for style := range pdfFontFiles {
+			data, ok := fonts[style]
+			if !ok {
+				data = fonts[strings.TrimSuffix(style, "I")]
+			}
+			doc.AddUTF8FontFromBytes(PDFUnicodeFamily, style, data)
+		}
+		doc.unicode = true
+		return doc
+	}
+
+	cp1252 := doc.UnicodeTranslatorFromDescriptor("")
+	doc.translate = func(text string) string {
+		return cp1252(pdfCoreFallbacks.Replace(text))
+	}
+	return doc
+}
+
+// loadPDFFonts reads the DejaVu regular and bold faces once, from PDF_FONT_DIR or the
+// usual system locations. Without both faces PDFs fall back to the core fonts.
+func loadPDFFonts() map[string][]byte {
+	pdfFontsOnce.Do(func() {
+		dirs := pdfFontDirs
+		if dir := os.Getenv("PDF_FONT_DIR"); dir != "" {
+			dirs = []string{dir}
+		}
+
+		for _, dir := range dirs {
+			fonts := make(map[string][]byte)
+			for style, file := range pdfFontFiles {
+				if data, err := os.ReadFile(filepath.Join(dir, file)); err == nil {
+					fonts[style] = data
+				}
+			}
+			if fonts[""] != nil && fonts["B"] != nil {
+				utils.LogInfo("PDF fonts loaded from " + dir)
+				pdfFontBytes = fonts
+				return
+			}
+		}
+		utils.LogWarning("DejaVu fonts not found; PDFs use core fonts with cp1252 text")
+	})
+	return pdfFontBytes
+}
+
+// text composes accents (e + U+0301 becomes é) and encodes the string for the current fonts
+func (d *pdfDocument) text(s string) string {
+	s = norm.NFC.String(s)
+	if d.unicode {
+		return s
+	}
+	return d.translate(s)
+}
+
+// SetFont selects the UTF-8 family in place of a core font when one is registered
+func (d *pdfDocument) SetFont(family, style string, size float64) {
+	if d.unicode {
+		family = PDFUnicodeFamily
+	}
+	d.PDFEngine.SetFont(family, style, size)
+}
+
+// Cell writes encoded text in a cell
+func (d *pdfDocument) Cell(w, h float64, txt string) {
+	d.PDFEngine.Cell(w, h, d.text(txt))
+}
+
+// CellFormat writes encoded text in a formatted cell
+func (d *pdfDocument) CellFormat(w, h float64, txt, border string, ln int, align string, fill bool, link int, linkURL string) {
+	d.PDFEngine.CellFormat(w, h, d.text(txt), border, ln, align, fill, link, linkURL)
+}
+
+// MultiCell writes encoded text wrapped across lines
+func (d *pdfDocument) MultiCell(w, h float64, txt, border, align string, fill bool) {
+	d.PDFEngine.MultiCell(w, h, d.text(txt), border, align, fill)
+}
+
+// Bookmark adds an outline entry with encoded text
+func (d *pdfDocument) Bookmark(txt string, level int, y float64) {
+	d.PDFEngine.Bookmark(d.text(txt), level, y)
+}
+
+// GetStringWidth measures encoded text
+func (d *pdfDocument) GetStringWidth(s string) float64 {
+	return d.PDFEngine.GetStringWidth(d.text(s))
+}
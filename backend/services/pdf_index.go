@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joshndala/cantrip/utils"
+)
+
+// pdfIDNamespace scopes the name-based UUIDs of generated PDFs
+var pdfIDNamespace = uuid.MustParse("186f01bf-7110-4d4d-9217-13fb2687032b")
+
+// pdfIndexFile maps each source document version to the PDFs generated from it
+const pdfIndexFile = "index.json"
+
+// pdfIndexMu serializes reads and writes of the index
+var pdfIndexMu sync.Mutex
+
+// PDFSource identifies the document a PDF was generated from
+type PDFSource struct {
+	Type    string // itinerary, packing, tips, transcript, memories, summary
+	ID      string
+	Version int // the document's revision or version; 0 for unversioned sources
+}
+
+// key is the source's entry in the PDF index
+func (s PDFSource) key() string {
+	return fmt.Sprintf("%s/%s/%d", s.Type, s.ID, s.Version)
+}
+
+// newPDFID derives a UUID from the source and its customization, so regenerating the same
+// document version with the same options replaces its PDF, while any other source,
+// version or option set gets its own ID. The password is left out of the name; only
+// whether one was set counts.
+func newPDFID(source PDFSource, customization map[string]interface{}) string {
+	options := make(map[string]interface{}, len(customization))
+	for key, value := range customization {
+		options[key] = value
+	}
+	if _, ok := options["password"]; ok {
+		options["password"] = true
+	}
+	encoded, _ := json.Marshal(options) // map keys marshal sorted, so this is stable
+	return uuid.NewSHA1(pdfIDNamespace, []byte(source.key()+"\n"+string(encoded))).String()
+}
+
+// storePDF writes a finished document and its metadata: the file is encrypted and
+// watermarked as customized, indexed under its source, and uploaded to the object store
+func storePDF(ctx context.Context, pdf *pdfDocument, source PDFSource, customization map[string]interface{}) (*PDFMetadata, error) {
+	id := newPDFID(source, customization)
+	filename := fmt.Sprintf("%s_%s.pdf", source.Type, id)
+	if err := os.MkdirAll(pdfStorageDir(ctx), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create PDF storage directory: %w", err)
+	}
+	path := filepath.Join(pdfStorageDir(ctx), filename)
+	expiresAt := time.Now().AddDate(0, 1, 0) // Expires in 1 month
+
+	security, err := writeSecuredPDF(pdf, path, customization, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file info: %w", err)
+	}
+
+	metadata := PDFMetadata{
+		ID:            id,
+		Filename:      filename,
+		Type:          source.Type,
+		SourceID:      source.ID,
+		SourceVersion: source.Version,
+		Size:          fileInfo.Size(),
+		CreatedAt:     time.Now(),
+		ExpiresAt:     expiresAt,
+		DownloadURL:   fmt.Sprintf("/api/v1/pdf/download/%s", id),
+		Customization: customization,
+	}
+	security.record(&metadata)
+
+	if err := savePDFMetadata(ctx, metadata); err != nil {
+		return nil, fmt.Errorf("failed to save metadata: %w", err)
+	}
+	if err := indexPDF(ctx, source, id); err != nil {
+		return nil, fmt.Errorf("failed to index PDF: %w", err)
+	}
+
+	// Upload to the object store. The download URL stays the API route, which streams the
+	// object for as long as the PDF is kept; a signed URL would expire long before it does.
+	if store := GetObjectStore(); store != nil {
+		ctx := storageContext(ctx)
+		objectName := fmt.Sprintf("pdfs/%s", filename)
+		if err := store.UploadFileFromPath(ctx, path, objectName); err != nil {
+			utils.LogWarning(fmt.Sprintf("Failed to upload %s to object store: %v", objectName, err))
+		}
+	}
+
+	return &metadata, nil
+}
+
+// loadPDFIndex reads the index; callers hold pdfIndexMu
+func loadPDFIndex(ctx context.Context) (map[string][]string, error) {
+	index := make(map[string][]string)
+	data, err := os.ReadFile(filepath.Join(pdfStorageDir(ctx), pdfIndexFile))
+	if os.IsNotExist(err) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// savePDFIndex replaces the index atomically; callers hold pdfIndexMu
+func savePDFIndex(ctx context.Context, index map[string][]string) error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(pdfStorageDir(ctx), pdfIndexFile), data, 0644)
+}
+
+// indexPDF records a PDF under its source version
+func indexPDF(ctx context.Context, source PDFSource, id string) error {
+	pdfIndexMu.Lock()
+	defer pdfIndexMu.Unlock()
+
+	index, err := loadPDFIndex(ctx)
+	if err != nil {
+		return err
+	}
+	key := source.key()
+	for _, existing := range index[key] {
+		if existing == id {
+			return nil
+		}
+	}
+	index[key] = append(index[key], id)
+	return savePDFIndex(ctx, index)
+}
+
+// unindexPDF removes a deleted PDF from the index
+func unindexPDF(ctx context.Context, metadata *PDFMetadata) error {
+	pdfIndexMu.Lock()
+	defer pdfIndexMu.Unlock()
+
+	index, err := loadPDFIndex(ctx)
+	if err != nil {
+		return err
+	}
+	key := PDFSource{Type: metadata.Type, ID: metadata.SourceID, Version: metadata.SourceVersion}.key()
+	ids := index[key][:0]
+	for _, id := range index[key] {
+		if id != metadata.ID {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		delete(index, key)
+	} else {
+		index[key] = ids
+	}
+	return savePDFIndex(ctx, index)
+}
+
+// FindPDFs lists the PDFs generated from a source document, newest first. A negative
+// version matches every version.
+func FindPDFs(ctx context.Context, sourceType, sourceID string, version int) ([]PDFMetadata, error) {
+	pdfIndexMu.Lock()
+	index, err := loadPDFIndex(ctx)
+	pdfIndexMu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load PDF index: %w", err)
+	}
+
+	var ids []string
+	if version >= 0 {
+		ids = index[PDFSource{Type: sourceType, ID: sourceID, Version: version}.key()]
+	} else {
+		prefix := sourceType + "/" + sourceID + "/"
+		for key, keyIDs := range index {
+			if strings.HasPrefix(key, prefix) && !strings.Contains(strings.TrimPrefix(key, prefix), "/") {
+				ids = append(ids, keyIDs...)
+			}
+		}
+	}
+
+	pdfs := []PDFMetadata{}
+	for _, id := range ids {
+		if metadata, err := loadPDFMetadata(ctx, id); err == nil {
+			pdfs = append(pdfs, *metadata)
+		}
+	}
+	sort.Slice(pdfs, func(i, j int) bool {
+		return pdfs[i].CreatedAt.After(pdfs[j].CreatedAt)
+	})
+	return pdfs, nil
+}
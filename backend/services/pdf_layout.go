@@ -0,0 +1,227 @@
+package services
+
+import (
+	"fmt"
+	"math"
+)
+
+// pdfPageAlias is replaced with the total page count when the document is written
+const pdfPageAlias = "{nb}"
+
+// pdfContentsEntry is one line of a document's table of contents
+type pdfContentsEntry struct {
+	title string
+	level int
+	page  int
+	link  int
+}
+
+// pdfContents tracks the sections of a document for its table of contents and outline
+type pdfContents struct {
+	pdf     *pdfDocument
+	page    int // page reserved for the table of contents
+	entries []pdfContentsEntry
+}
+
+// newStructuredPDF creates an A4 document with a running header and page-numbered footer.
+// The cover page (page 1) has neither.
+func newStructuredPDF(title, subtitle string) *pdfDocument {
+	pdf := newPDFDocument()
+	pdf.SetTitle(title, true)
+	pdf.AliasNbPages(pdfPageAlias)
+
+	pdf.SetHeaderFunc(func() {
+		if pdf.PageNo() == 1 {
+			return
+		}
+		pdf.SetFont("Arial", "I", 8)
+		pdf.SetTextColor(120, 120, 120)
+		pdf.CellFormat(95, 6, title, "", 0, "L", false, 0, "")
+		pdf.CellFormat(0, 6, subtitle, "", 1, "R", false, 0, "")
+		pdf.SetDrawColor(200, 200, 200)
+		pdf.Line(10, pdf.GetY(), 200, pdf.GetY())
+		pdf.Ln(6)
+		pdf.SetTextColor(0, 0, 0)
+	})
+	pdf.SetFooterFunc(func() {
+		if pdf.PageNo() == 1 {
+			return
+		}
+		pdf.SetY(-15)
+		pdf.SetFont("Arial", "I", 8)
+		pdf.SetTextColor(120, 120, 120)
+		pdf.CellFormat(0, 10, fmt.Sprintf("Page %d of %s", pdf.PageNo(), pdfPageAlias), "", 0, "C", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+	})
+
+	return pdf
+}
+
+// addPDFCoverPage writes a cover page with the title and a line per detail
+func addPDFCoverPage(pdf *pdfDocument, title string, details []string) {
+	pdf.AddPage()
+	pdf.SetY(90)
+	pdf.SetFont("Arial", "B", 28)
+	pdf.CellFormat(0, 14, title, "", 1, "C", false, 0, "")
+	pdf.Ln(6)
+
+	pdf.SetFont("Arial", "", 14)
+	for _, detail := range details {
+		if detail == "" {
+			continue
+		}
+		pdf.CellFormat(0, 9, detail, "", 1, "C", false, 0, "")
+	}
+}
+
+// newPDFContents reserves the next page for the table of contents, which is written
+// by write once every section's page is known
+func newPDFContents(pdf *pdfDocument) *pdfContents {
+	pdf.AddPage()
+	return &pdfContents{pdf: pdf, page: pdf.PageNo()}
+}
+
+// section starts a section, optionally on a new page, adding it to the table of contents
+// and the PDF outline. Level 0 is a top-level section and level 1 is nested under it.
+func (c *pdfContents) section(title string, level int, newPage bool) {
+	if newPage {
+		c.pdf.AddPage()
+	}
+	y := c.pdf.GetY()
+	link := c.pdf.AddLink()
+	c.pdf.SetLink(link, y, -1)
+	c.pdf.Bookmark(title, level, y)
+	c.entries = append(c.entries, pdfContentsEntry{title: title, level: level, page: c.pdf.PageNo(), link: link})
+}
+
+// write fills in the reserved page with a clickable entry and page number per section
+func (c *pdfContents) write() {
+	if len(c.entries) == 0 {
+		return
+	}
+	last := c.pdf.PageNo()
+	c.pdf.SetPage(c.page)
+
+	// Writing past the bottom would insert pages at the end, so long trips get tighter lines
+	autoBreak, margin := c.pdf.GetAutoPageBreak()
+	c.pdf.SetAutoPageBreak(false, margin)
+	c.pdf.SetY(25)
+
+	c.pdf.SetFont("Arial", "B", 16)
+	c.pdf.Cell(0, 10, "Contents")
+	c.pdf.Ln(14)
+
+	lineHeight := 8.0
+	if fit := 240 / float64(len(c.entries)); fit < lineHeight {
+		lineHeight = fit
+	}
+	for _, entry := range c.entries {
+		indent := float64(entry.level) * 8
+		if entry.level == 0 {
+			c.pdf.SetFont("Arial", "B", 11)
+		} else {
+			c.pdf.SetFont("Arial", "", 10)
+		}
+		c.pdf.SetX(10 + indent)
+		c.pdf.CellFormat(170-indent, lineHeight, entry.title, "", 0, "L", false, entry.link, "")
+		c.pdf.CellFormat(20, lineHeight, fmt.Sprint(entry.page), "", 1, "R", false, entry.link, "")
+	}
+
+	c.pdf.SetAutoPageBreak(autoBreak, margin)
+	c.pdf.SetPage(last)
+}
+
+// MaxPDFColumns is the most columns a multi-column layout uses on an A4 page
+const MaxPDFColumns = 3
+
+// pdfColumnBlock is a heading and its lines in a multi-column layout
+type pdfColumnBlock struct {
+	heading string
+	lines   []string
+}
+
+// writePDFColumns lays blocks out in columns, balancing their heights and flowing onto a
+// new page when the last column is full. Headings are kept with their first line.
+func writePDFColumns(pdf *pdfDocument, blocks []pdfColumnBlock, columns int) {
+	const gap, headingHeight, lineHeight = 6.0, 8.0, 5.0
+
+	left, _, right, bottom := pdf.GetMargins()
+	pageWidth, pageHeight := pdf.GetPageSize()
+	width := (pageWidth - left - right - gap*float64(columns-1)) / float64(columns)
+	limit := pageHeight - bottom
+
+	autoBreak, margin := pdf.GetAutoPageBreak()
+	pdf.SetAutoPageBreak(false, margin)
+	defer func() {
+		pdf.SetLeftMargin(left)
+		pdf.SetAutoPageBreak(autoBreak, margin)
+	}()
+
+	// lines estimates the height of a wrapped line
+	pdf.SetFont("Arial", "", 9)
+	lines := func(text string) float64 {
+		return float64(int(pdf.GetStringWidth(text)/(width-2))+1) * lineHeight
+	}
+	heights := make([]float64, len(blocks))
+	total := 0.0
+	for i, block := range blocks {
+		heights[i] = headingHeight + 3
+		for _, line := range block.lines {
+			heights[i] += lines(line)
+		}
+		total += heights[i]
+	}
+
+	top := pdf.GetY()
+	target := math.Min(limit-top, total/float64(columns))
+	column, y, end := 0, top, top
+	next := func() {
+		column++
+		if column == columns {
+			pdf.AddPage()
+			column, top = 0, pdf.GetY()
+			target, end = limit-top, top
+		}
+		y = top
+	}
+	// fit moves to the next column or page when the next h millimetres don't fit
+	fit := func(h float64) {
+		if y+h > limit && y > top {
+			next()
+		}
+	}
+	place := func() {
+		x := left + float64(column)*(width+gap)
+		pdf.SetLeftMargin(x)
+		pdf.SetXY(x, y)
+	}
+
+	for i, block := range blocks {
+		// Start the next column once this one reaches its share of the content
+		if y > top && y-top+heights[i]/2 > target && column < columns-1 {
+			next()
+		}
+		first := 0.0
+		if len(block.lines) > 0 {
+			first = lines(block.lines[0])
+		}
+		fit(headingHeight + first)
+		place()
+		pdf.SetFont("Arial", "B", 12)
+		pdf.MultiCell(width, headingHeight, block.heading, "", "L", false)
+		y = pdf.GetY()
+
+		pdf.SetFont("Arial", "", 9)
+		for _, line := range block.lines {
+			fit(lines(line))
+			place()
+			pdf.MultiCell(width, lineHeight, line, "", "L", false)
+			y = pdf.GetY()
+		}
+		y += 3
+		end = max(end, y)
+	}
+
+	pdf.SetLeftMargin(left)
+	pdf.SetXY(left, end)
+}
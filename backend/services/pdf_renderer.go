@@ -0,0 +1,168 @@
+package services
+
+import (
+	"bytes"
+	"os"
+	"sync"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/joshndala/cantrip/utils"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// PDF backends, selected with PDF_RENDERER
+const (
+	PDFRendererGofpdf = "gofpdf" // github.com/jung-kurt/gofpdf, archived upstream
+	PDFRendererFpdf   = "fpdf"   // github.com/go-pdf/fpdf, the maintained fork
+)
+
+// PDFEngine is the page drawing API the PDF generators use. Both backends implement it.
+type PDFEngine interface {
+	AddPage()
+	PageNo() int
+	SetPage(pageNum int)
+	SetTitle(titleStr string, isUTF8 bool)
+	AliasNbPages(aliasStr string)
+	SetHeaderFunc(fnc func())
+	SetFooterFunc(fnc func())
+
+	SetFont(familyStr, styleStr string, size float64)
+	AddUTF8FontFromBytes(familyStr, styleStr string, utf8Bytes []byte)
+	UnicodeTranslatorFromDescriptor(cpStr string) func(string) string
+	SetTextColor(r, g, b int)
+	SetDrawColor(r, g, b int)
+	SetFillColor(r, g, b int)
+
+	Cell(w, h float64, txtStr string)
+	CellFormat(w, h float64, txtStr, borderStr string, ln int, alignStr string, fill bool, link int, linkStr string)
+	MultiCell(w, h float64, txtStr, borderStr, alignStr string, fill bool)
+	GetStringWidth(s string) float64
+	Ln(h float64)
+	Line(x1, y1, x2, y2 float64)
+	Rect(x, y, w, h float64, styleStr string)
+
+	GetX() float64
+	GetY() float64
+	SetX(x float64)
+	SetY(y float64)
+	SetXY(x, y float64)
+	SetLeftMargin(margin float64)
+	GetMargins() (left, top, right, bottom float64)
+	GetPageSize() (width, height float64)
+	GetAutoPageBreak() (auto bool, margin float64)
+	SetAutoPageBreak(auto bool, margin float64)
+
+	SetAlpha(alpha float64, blendModeStr string)
+	TransformBegin()
+	TransformRotate(angle, x, y float64)
+	TransformEnd()
+	SetProtection(actionFlag byte, userPassStr, ownerPassStr string)
+
+	AddLink() int
+	SetLink(link int, y float64, page int)
+	Bookmark(txtStr string, level int, y float64)
+
+	// DrawImage registers a JPEG or PNG ("jpg" or "png") under name and draws it w wide,
+	// scaled to keep its aspect ratio, returning the height drawn
+	DrawImage(name, imageType string, data []byte, x, y, w float64) float64
+
+	OutputFileAndClose(fileStr string) error
+}
+
+// PDFRenderer creates documents on one PDF backend
+type PDFRenderer interface {
+	Name() string
+	New() PDFEngine
+}
+
+type gofpdfRenderer struct{}
+
+func (gofpdfRenderer) Name() string { return PDFRendererGofpdf }
+
+// New creates an A4 portrait gofpdf document
+func (gofpdfRenderer) New() PDFEngine {
+	return gofpdfEngine{gofpdf.New("P", "mm", "A4", "")}
+}
+
+// gofpdfEngine adds the image drawing whose option types differ between the backends
+type gofpdfEngine struct {
+	*gofpdf.Fpdf
+}
+
+func (e gofpdfEngine) DrawImage(name, imageType string, data []byte, x, y, w float64) float64 {
+	options := gofpdf.ImageOptions{ImageType: imageType}
+	info := e.RegisterImageOptionsReader(name, options, bytes.NewReader(data))
+	if info == nil || info.Width() == 0 {
+		return 0
+	}
+	h := w * info.Height() / info.Width()
+	e.ImageOptions(name, x, y, w, h, false, options, 0, "")
+	return h
+}
+
+type fpdfRenderer struct{}
+
+func (fpdfRenderer) Name() string { return PDFRendererFpdf }
+
+// New creates an A4 portrait go-pdf/fpdf document
+func (fpdfRenderer) New() PDFEngine {
+	return fpdfEngine{fpdf.New("P", "mm", "A4", "")}
+}
+
+// fpdfEngine adds image drawing to go-pdf/fpdf, as gofpdfEngine does to gofpdf
+type fpdfEngine struct {
+	*fpdf.Fpdf
+}
+
+func (e fpdfEngine) DrawImage(name, imageType string, data []byte, x, y, w float64) float64 {
+	options := fpdf.ImageOptions{ImageType: imageType}
+	info := e.RegisterImageOptionsReader(name, options, bytes.NewReader(data))
+	if info == nil || info.Width() == 0 {
+		return 0
+	}
+	h := w * info.Height() / info.Width()
+	e.ImageOptions(name, x, y, w, h, false, options, 0, "")
+	return h
+}
+
+var (
+	pdfRendererMu   sync.RWMutex
+	pdfRendererOnce sync.Once
+	pdfRenderer     PDFRenderer
+)
+
+// NewPDFRenderer returns the backend with the given name, defaulting to gofpdf
+func NewPDFRenderer(name string) PDFRenderer {
+	switch name {
+	case PDFRendererFpdf:
+		return fpdfRenderer{}
+	case "", PDFRendererGofpdf:
+		return gofpdfRenderer{}
+	default:
+		utils.LogWarning("Unknown PDF_RENDERER " + name + ", using " + PDFRendererGofpdf)
+		return gofpdfRenderer{}
+	}
+}
+
+// GetPDFRenderer returns the backend selected by PDF_RENDERER
+func GetPDFRenderer() PDFRenderer {
+	pdfRendererOnce.Do(func() {
+		pdfRendererMu.Lock()
+		defer pdfRendererMu.Unlock()
+		if pdfRenderer == nil {
+			pdfRenderer = NewPDFRenderer(os.Getenv("PDF_RENDERER"))
+			utils.LogInfo("PDF renderer: " + pdfRenderer.Name())
+		}
+	})
+	pdfRendererMu.RLock()
+	defer pdfRendererMu.RUnlock()
+	return pdfRenderer
+}
+
+// SetPDFRenderer overrides the PDF backend
+func SetPDFRenderer(renderer PDFRenderer) {
+	pdfRendererOnce.Do(func() {})
+	pdfRendererMu.Lock()
+	defer pdfRendererMu.Unlock()
+	pdfRenderer = renderer
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// PDF password limits; the PDF standard security handler pads or truncates passwords to 32 bytes
+const (
+	MinPDFPasswordLength = 4
+	MaxPDFPasswordLength = 32
+	MaxPDFWatermarkRunes = 80
+)
+
+// pdfPermissions lets anyone who opens a protected PDF print it and copy its text
+// (gofpdf's CnProtectPrint | CnProtectCopy)
+const pdfPermissions byte = 4 | 16
+
+// ErrInvalidPDFOptions is returned for a malformed password or watermark customization
+var ErrInvalidPDFOptions = errors.New("invalid PDF options")
+
+// pdfSecurity is the password and watermark requested in a PDF's customization:
+// "password" encrypts the file, and "watermark" is true for the shared-copy notice or
+// a custom line of text
+type pdfSecurity struct {
+	password  string
+	watermark string
+}
+
+// pdfSecurityOptions reads the password and watermark customizations. expiresAt dates the
+// default watermark.
+func pdfSecurityOptions(customization map[string]interface{}, expiresAt time.Time) (pdfSecurity, error) {
+	var security pdfSecurity
+
+	if raw, ok := customization["password"]; ok {
+		password, _ := raw.(string)
+		if n := len(password); n < MinPDFPasswordLength || n > MaxPDFPasswordLength {
+			return security, fmt.Errorf("%w: password must be %d to %d characters", ErrInvalidPDFOptions, MinPDFPasswordLength, MaxPDFPasswordLength)
+		}
+		security.password = password
+	}
+
+	switch watermark := customization["watermark"].(type) {
+	case nil:
+	case bool:
+		if watermark {
+			security.watermark = fmt.Sprintf("Shared via CanTrip — expires %s", expiresAt.Format("2006-01-02"))
+		}
+	case string:
+		watermark = strings.TrimSpace(watermark)
+		if len([]rune(watermark)) > MaxPDFWatermarkRunes {
+			return security, fmt.Errorf("%w: watermark must be at most %d characters", ErrInvalidPDFOptions, MaxPDFWatermarkRunes)
+		}
+		security.watermark = watermark
+	default:
+		return security, fmt.Errorf("%w: watermark must be true or text", ErrInvalidPDFOptions)
+	}
+
+	return security, nil
+}
+
+// apply stamps the watermark diagonally across every page and encrypts the document.
+// It runs once the content is complete, just before the file is written.
+func (s pdfSecurity) apply(pdf *pdfDocument) error {
+	if s.watermark != "" {
+		last := pdf.PageNo()
+		width, height := pdf.GetPageSize()
+		autoBreak, margin := pdf.GetAutoPageBreak()
+		pdf.SetAutoPageBreak(false, margin)
+
+		for page := 1; page <= last; page++ {
+			pdf.SetPage(page)
+			pdf.SetFont("Arial", "B", 28)
+			pdf.SetTextColor(150, 150, 150)
+			pdf.SetAlpha(0.25, "Normal")
+			pdf.TransformBegin()
+			pdf.TransformRotate(45, width/2, height/2)
+			textWidth := pdf.GetStringWidth(s.watermark)
+			pdf.SetXY((width-textWidth)/2, height/2-6)
+			pdf.CellFormat(textWidth, 12, s.watermark, "", 0, "C", false, 0, "")
+			pdf.TransformEnd()
+			pdf.SetAlpha(1, "Normal")
+			pdf.SetTextColor(0, 0, 0)
+		}
+
+		pdf.SetAutoPageBreak(autoBreak, margin)
+		pdf.SetPage(last)
+	}
+
+	if s.password != "" {
+		// The owner password is never shared, so nobody can lift the restrictions
+		owner := make([]byte, 16)
+		if _, err := rand.Read(owner); err != nil {
+			return fmt.Errorf("failed to generate owner password: %w", err)
+		}
+		pdf.SetProtection(pdfPermissions, s.password, hex.EncodeToString(owner))
+	}
+	return nil
+}
+
+// record notes the protection and watermark in the metadata, dropping the password from
+// the stored customization
+func (s pdfSecurity) record(metadata *PDFMetadata) {
+	metadata.Protected = s.password != ""
+	metadata.Watermark = s.watermark
+	if _, ok := metadata.Customization["password"]; !ok {
+		return
+	}
+
+	customization := make(map[string]interface{}, len(metadata.Customization))
+	for key, value := range metadata.Customization {
+		if key != "password" {
+			customization[key] = value
+		}
+	}
+	metadata.Customization = customization
+}
+
+// writeSecuredPDF applies the customization's password and watermark and writes the file atomically
+func writeSecuredPDF(pdf *pdfDocument, path string, customization map[string]interface{}, expiresAt time.Time) (pdfSecurity, error) {
+	security, err := pdfSecurityOptions(customization, expiresAt)
+	if err != nil {
+		return security, err
+	}
+	if err := security.apply(pdf); err != nil {
+		return security, err
+	}
+
+	// Render beside the destination and rename, so a download never reads a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return security, fmt.Errorf("failed to save PDF: %w", err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+	if err := pdf.OutputFileAndClose(tmp.Name()); err != nil {
+		return security, fmt.Errorf("failed to save PDF: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return security, fmt.Errorf("failed to save PDF: %w", err)
+	}
+	return security, nil
+}
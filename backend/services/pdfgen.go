@@ -10,20 +10,22 @@ import (
 	"strconv"
 	"strings"
 	"time"
-
-	"github.com/jung-kurt/gofpdf"
 )
 
 // PDFMetadata represents metadata for a PDF file
 type PDFMetadata struct {
 	ID            string                 `json:"id"`
 	Filename      string                 `json:"filename"`
-	Type          string                 `json:"type"` // itinerary, packing, tips
+	Type          string                 `json:"type"` // itinerary, packing, tips, transcript, memories, summary
+	SourceID      string                 `json:"source_id"`
+	SourceVersion int                    `json:"source_version"`
 	Size          int64                  `json:"size"`
 	CreatedAt     time.Time              `json:"created_at"`
 	ExpiresAt     time.Time              `json:"expires_at"`
 	DownloadURL   string                 `json:"download_url"`
 	ShareURL      string                 `json:"share_url,omitempty"`
+	Protected     bool                   `json:"protected,omitempty"` // encrypted with a user password
+	Watermark     string                 `json:"watermark,omitempty"`
 	Customization map[string]interface{} `json:"customization,omitempty"`
 }
 
@@ -38,9 +40,14 @@ type PDFStatus struct {
 	URL       string    `json:"url,omitempty"`
 }
 
-// Global PDF storage directory
+// Global PDF storage directory, the default tenant's
 const PDFStorageDir = "data/pdfs"
 
+// pdfStorageDir returns the PDF directory of the tenant ctx acts for
+func pdfStorageDir(ctx context.Context) string {
+	return localDocumentDir(ctx, "pdfs")
+}
+
 // Initialize PDF storage
 func init() {
 	if err := os.MkdirAll(PDFStorageDir, 0755); err != nil {
@@ -49,44 +56,52 @@ func init() {
 }
 
 // GenerateItineraryPDF generates a PDF for an itinerary
-func GenerateItineraryPDF(id, format string, includeImages bool, customization map[string]interface{}) (string, error) {
+func GenerateItineraryPDF(ctx context.Context, id, format string, includeImages bool, customization map[string]interface{}) (*PDFMetadata, error) {
 	// Get itinerary data
-	itineraryInterface, err := GetItinerary(id)
+	itinerary, err := GetItinerary(ctx, id)
 	if err != nil {
-		return "", fmt.Errorf("failed to get itinerary: %w", err)
+		return nil, fmt.Errorf("failed to get itinerary: %w", err)
 	}
 
-	// Convert interface{} to map for access
-	itineraryData, ok := itineraryInterface.(map[string]interface{})
-	if !ok {
-		return "", fmt.Errorf("invalid itinerary data format")
+	// The generated plan is a free-form map from the AI agent
+	itineraryData := itinerary.Itinerary
+	if itineraryData == nil {
+		return nil, fmt.Errorf("invalid itinerary data format")
 	}
 
-	// Create PDF
-	pdf := gofpdf.New("P", "mm", "A4", "")
-	pdf.AddPage()
-	pdf.SetFont("Arial", "B", 16)
+	city, _ := itineraryData["city"].(string)
+	startDate, _ := itineraryData["start_date"].(string)
+	endDate, _ := itineraryData["end_date"].(string)
+	dates := ""
+	if startDate != "" && endDate != "" {
+		dates = fmt.Sprintf("%s to %s", startDate, endDate)
+	}
+	summary, _ := itineraryData["summary"].(string)
 
-	// Add title
-	pdf.Cell(0, 10, "Travel Itinerary")
-	pdf.Ln(15)
+	// Create PDF with a cover page and table of contents
+	pdf := newStructuredPDF("Travel Itinerary", strings.TrimSpace(city+"  "+dates))
+	addPDFCoverPage(pdf, "Travel Itinerary", []string{city, dates, summary})
+	contents := newPDFContents(pdf)
 
 	// Add itinerary details
+	contents.section("Trip Overview", 0, true)
+	pdf.SetFont("Arial", "B", 16)
+	pdf.Cell(0, 10, "Trip Overview")
+	pdf.Ln(15)
+
 	pdf.SetFont("Arial", "B", 12)
-	if city, ok := itineraryData["city"].(string); ok {
+	if city != "" {
 		pdf.Cell(0, 8, fmt.Sprintf("Destination: %s", city))
 		pdf.Ln(10)
 	}
 
 	// Handle dates (they might be strings from the interface)
-	if startDate, ok := itineraryData["start_date"].(string); ok {
-		if endDate, ok := itineraryData["end_date"].(string); ok {
-			pdf.Cell(0, 8, fmt.Sprintf("Duration: %s to %s", startDate, endDate))
-			pdf.Ln(15)
-		}
+	if dates != "" {
+		pdf.Cell(0, 8, fmt.Sprintf("Duration: %s", dates))
+		pdf.Ln(15)
 	}
 
-	// Add daily plans
+	// Add daily plans, a page per day
 	if days, ok := itineraryData["days"].([]interface{}); ok {
 		for i, dayInterface := range days {
 			day, ok := dayInterface.(map[string]interface{})
@@ -95,13 +110,22 @@ func GenerateItineraryPDF(id, format string, includeImages bool, customization m
 			}
 
 			// Day header
-			pdf.SetFont("Arial", "B", 12)
+			title := fmt.Sprintf("Day %d", i+1)
 			if dayNum, ok := day["day"].(float64); ok {
-				if dateStr, ok := day["date"].(string); ok {
-					pdf.Cell(0, 8, fmt.Sprintf("Day %.0f - %s", dayNum, dateStr))
-					pdf.Ln(10)
-				}
+				title = fmt.Sprintf("Day %.0f", dayNum)
+			}
+			if dateStr, ok := day["date"].(string); ok {
+				title = fmt.Sprintf("%s - %s", title, dateStr)
 			}
+			if i == 0 {
+				contents.section("Daily Plans", 0, true)
+				contents.section(title, 1, false)
+			} else {
+				contents.section(title, 1, true)
+			}
+			pdf.SetFont("Arial", "B", 12)
+			pdf.Cell(0, 8, title)
+			pdf.Ln(10)
 
 			// Activities
 			if activities, ok := day["activities"].([]interface{}); ok && len(activities) > 0 {
@@ -142,101 +166,143 @@ func GenerateItineraryPDF(id, format string, includeImages bool, customization m
 				pdf.Ln(5)
 			}
 
-			// Add page break if not last day
-			if i < len(days)-1 {
-				pdf.AddPage()
-			}
 		}
 	}
 
-	// Save PDF
-	filename := fmt.Sprintf("itinerary_%s.pdf", id)
-	filepath := filepath.Join(PDFStorageDir, filename)
+	// Carbon footprint of the flights, rental car and lodging
+	if emissions, err := EstimateTripEmissions(ctx, itinerary, EmissionsQuery{Alternatives: true}); err == nil && emissions.TotalKg > 0 {
+		pdf.Ln(5)
+		contents.section("Carbon Footprint", 0, false)
+		pdf.SetFont("Arial", "B", 12)
+		pdf.Cell(0, 8, "Carbon Footprint")
+		pdf.Ln(10)
 
-	if err := pdf.OutputFileAndClose(filepath); err != nil {
-		return "", fmt.Errorf("failed to save PDF: %w", err)
+		pdf.SetFont("Arial", "", 10)
+		for _, leg := range emissions.Legs {
+			pdf.Cell(0, 5, fmt.Sprintf("• %s %s to %s: %.0f kg CO2e", strings.Title(leg.Mode), leg.From, leg.To, leg.KgCO2e))
+			pdf.Ln(6)
+		}
+		pdf.Cell(0, 5, fmt.Sprintf("• Lodging, %d night(s): %.0f kg CO2e", emissions.Accommodation.Nights, emissions.Accommodation.KgCO2e))
+		pdf.Ln(6)
+		pdf.SetFont("Arial", "B", 10)
+		pdf.Cell(0, 5, fmt.Sprintf("Total: %.0f kg CO2e (%.0f kg per traveler)", emissions.TotalKg, emissions.PerTravelerKg))
+		pdf.Ln(6)
+		pdf.SetFont("Arial", "", 10)
+		for _, alternative := range emissions.Alternatives {
+			pdf.Cell(0, 5, fmt.Sprintf("• Take the %s from %s to %s instead of the %s to save %.0f kg", alternative.Mode, alternative.From, alternative.To, alternative.Instead, alternative.SavingKg))
+			pdf.Ln(6)
+		}
 	}
 
-	// Get file size
-	fileInfo, err := os.Stat(filepath)
-	if err != nil {
-		return "", fmt.Errorf("failed to get file info: %w", err)
-	}
+	contents.write()
+
+	return storePDF(ctx, pdf, PDFSource{Type: "itinerary", ID: id, Version: itinerary.Revision}, customization)
+}
 
-	// Save metadata
-	metadata := PDFMetadata{
-		ID:            id,
-		Filename:      filename,
-		Type:          "itinerary",
-		Size:          fileInfo.Size(),
-		CreatedAt:     time.Now(),
-		ExpiresAt:     time.Now().AddDate(0, 1, 0), // Expires in 1 month
-		DownloadURL:   fmt.Sprintf("/api/v1/pdf/download/%s", id),
-		Customization: customization,
+// GeneratePackingListPDF generates a PDF for a packing list. For group lists, a
+// "traveler" customization exports one traveler's items, or "shared" the group's.
+func GeneratePackingListPDF(ctx context.Context, id, format string, includeImages bool, customization map[string]interface{}) (*PDFMetadata, error) {
+	// Get packing list data
+	packingList, err := GetPackingList(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get packing list: %w", err)
 	}
 
-	if err := savePDFMetadata(metadata); err != nil {
-		return "", fmt.Errorf("failed to save metadata: %w", err)
+	// Group lists print the shared items, then each traveler under their own heading
+	type packingSection struct {
+		heading    string
+		categories []PackingCategory
 	}
+	title := "Packing List"
+	totalItems := packingList.TotalItems
+	source := PDFSource{Type: "packing", ID: id, Version: packingList.Version}
+	var sections []packingSection
 
-	// Try to upload to GCS if available
-	if gcsClient := GetGCSClient(); gcsClient != nil {
-		ctx := context.Background()
-		objectName := fmt.Sprintf("pdfs/%s", filename)
-		if err := gcsClient.UploadFileFromPath(ctx, objectName, filepath); err == nil {
-			if signedURL, err := gcsClient.GenerateSignedURL(ctx, objectName, 24*time.Hour); err == nil {
-				metadata.DownloadURL = signedURL
-				savePDFMetadata(metadata)
-			}
+	if part, _ := customization["traveler"].(string); part != "" {
+		heading, categories, err := PackingListPart(packingList, part)
+		if err != nil {
+			return nil, err
+		}
+		title = fmt.Sprintf("Packing List - %s", heading)
+		totalItems = countPackingItems(categories)
+		sections = []packingSection{{categories: categories}}
+	} else {
+		categories, err := packingCategories(packingList)
+		if err != nil {
+			return nil, err
+		}
+		sections = []packingSection{{categories: categories}}
+		for _, traveler := range packingList.Travelers {
+			sections = append(sections, packingSection{heading: "Traveler: " + traveler.Name, categories: traveler.Categories})
 		}
 	}
 
-	return metadata.DownloadURL, nil
-}
-
-// GeneratePackingListPDF generates a PDF for a packing list
-func GeneratePackingListPDF(id, format string, includeImages bool, customization map[string]interface{}) (string, error) {
-	// Get packing list data
-	packingList, err := GetPackingList(id)
-	if err != nil {
-		return "", fmt.Errorf("failed to get packing list: %w", err)
-	}
+	columns := min(intField(customization, "columns", 1), MaxPDFColumns)
 
 	// Create PDF
-	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf := newPDFDocument()
 	pdf.AddPage()
+
+	// A "checklist" layout prints a table of checkboxes, a column per traveler
+	if layout, _ := customization["layout"].(string); layout == PackingLayoutChecklist {
+		part, _ := customization["traveler"].(string)
+		checklist, err := PackingListChecklist(packingList, part)
+		if err != nil {
+			return nil, err
+		}
+		writePackingChecklistPDF(pdf, checklist)
+		return storePDF(ctx, pdf, source, customization)
+	}
+
 	pdf.SetFont("Arial", "B", 16)
 
 	// Add title
-	pdf.Cell(0, 10, "Packing List")
+	pdf.Cell(0, 10, title)
 	pdf.Ln(15)
 
 	// Add destination info
 	pdf.SetFont("Arial", "B", 12)
 	pdf.Cell(0, 8, fmt.Sprintf("Destination: %s", packingList.Destination))
 	pdf.Ln(10)
-	pdf.Cell(0, 8, fmt.Sprintf("Total Items: %d", packingList.TotalItems))
+	pdf.Cell(0, 8, fmt.Sprintf("Total Items: %d", totalItems))
 	pdf.Ln(15)
 
 	// Add categories and items
-	for _, categoryInterface := range packingList.Categories {
-		categoryData, _ := json.Marshal(categoryInterface)
-		var category PackingCategory
-		if err := json.Unmarshal(categoryData, &category); err != nil {
-			continue
+	for _, section := range sections {
+		if section.heading != "" {
+			pdf.SetFont("Arial", "B", 14)
+			pdf.Cell(0, 10, section.heading)
+			pdf.Ln(12)
 		}
 
-		pdf.SetFont("Arial", "B", 12)
-		pdf.Cell(0, 8, category.Name)
-		pdf.Ln(10)
+		// A "columns" customization lays the categories out side by side
+		if columns > 1 {
+			blocks := make([]pdfColumnBlock, 0, len(section.categories))
+			for _, category := range section.categories {
+				block := pdfColumnBlock{heading: category.Name}
+				for _, item := range category.Items {
+					block.lines = append(block.lines, fmt.Sprintf("• %s (%d)", item.Name, item.Quantity))
+				}
+				blocks = append(blocks, block)
+			}
+			writePDFColumns(pdf, blocks, columns)
+			pdf.Ln(5)
+			continue
+		}
 
-		pdf.SetFont("Arial", "", 10)
-		for _, item := range category.Items {
-			pdf.Cell(0, 5, fmt.Sprintf("• %s (Qty: %d) - %s",
-				item.Name, item.Quantity, item.Reason))
-			pdf.Ln(6)
+		for _, category := range section.categories {
+			pdf.SetFont("Arial", "B", 12)
+			pdf.Cell(0, 8, category.Name)
+			pdf.Ln(10)
+
+			pdf.SetFont("Arial", "", 10)
+			for _, item := range category.Items {
+				pdf.Cell(0, 5, fmt.Sprintf("• %s (Qty: %d) - %s",
+					item.Name, item.Quantity, item.Reason))
+				pdf.Ln(6)
+			}
+			pdf.Ln(5)
 		}
-		pdf.Ln(5)
 	}
 
 	// Add notes
@@ -252,61 +318,24 @@ func GeneratePackingListPDF(id, format string, includeImages bool, customization
 		}
 	}
 
-	// Save PDF
-	filename := fmt.Sprintf("packing_%s.pdf", id)
-	filepath := filepath.Join(PDFStorageDir, filename)
-
-	if err := pdf.OutputFileAndClose(filepath); err != nil {
-		return "", fmt.Errorf("failed to save PDF: %w", err)
-	}
-
-	// Get file size
-	fileInfo, err := os.Stat(filepath)
-	if err != nil {
-		return "", fmt.Errorf("failed to get file info: %w", err)
-	}
-
-	// Save metadata
-	metadata := PDFMetadata{
-		ID:            id,
-		Filename:      filename,
-		Type:          "packing",
-		Size:          fileInfo.Size(),
-		CreatedAt:     time.Now(),
-		ExpiresAt:     time.Now().AddDate(0, 1, 0),
-		DownloadURL:   fmt.Sprintf("/api/v1/pdf/download/%s", id),
-		Customization: customization,
-	}
-
-	if err := savePDFMetadata(metadata); err != nil {
-		return "", fmt.Errorf("failed to save metadata: %w", err)
-	}
-
-	// Try to upload to GCS if available
-	if gcsClient := GetGCSClient(); gcsClient != nil {
-		ctx := context.Background()
-		objectName := fmt.Sprintf("pdfs/%s", filename)
-		if err := gcsClient.UploadFileFromPath(ctx, objectName, filepath); err == nil {
-			if signedURL, err := gcsClient.GenerateSignedURL(ctx, objectName, 24*time.Hour); err == nil {
-				metadata.DownloadURL = signedURL
-				savePDFMetadata(metadata)
-			}
-		}
+	if len(packingList.DailyGuidance) > 0 {
+		pdf.Ln(5)
+		writeDailyGuidancePDF(pdf, packingList.DailyGuidance, 10)
 	}
 
-	return metadata.DownloadURL, nil
+	return storePDF(ctx, pdf, source, customization)
 }
 
 // GenerateTipsPDF generates a PDF for travel tips
-func GenerateTipsPDF(destination, category string, includeImages bool, customization map[string]interface{}) (string, error) {
+func GenerateTipsPDF(ctx context.Context, destination, category string, includeImages bool, customization map[string]interface{}) (*PDFMetadata, error) {
 	// Get tips data
-	tips, err := GetTravelTips(destination, category, nil)
+	tips, err := GetTravelTips(ctx, destination, category, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to get tips: %w", err)
+		return nil, fmt.Errorf("failed to get tips: %w", err)
 	}
 
 	// Create PDF
-	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf := newPDFDocument()
 	pdf.AddPage()
 	pdf.SetFont("Arial", "B", 16)
 
@@ -356,107 +385,24 @@ func GenerateTipsPDF(destination, category string, includeImages bool, customiza
 		}
 	}
 
-	// Save PDF
-	filename := fmt.Sprintf("tips_%s_%s.pdf", strings.ToLower(destination), category)
-	filepath := filepath.Join(PDFStorageDir, filename)
-
-	if err := pdf.OutputFileAndClose(filepath); err != nil {
-		return "", fmt.Errorf("failed to save PDF: %w", err)
-	}
-
-	// Get file size
-	fileInfo, err := os.Stat(filepath)
-	if err != nil {
-		return "", fmt.Errorf("failed to get file info: %w", err)
-	}
-
-	// Generate ID for this PDF
-	pdfID := fmt.Sprintf("tips_%s_%s_%d",
-		strings.ToLower(destination),
-		category,
-		time.Now().Unix())
-
-	// Save metadata
-	metadata := PDFMetadata{
-		ID:            pdfID,
-		Filename:      filename,
-		Type:          "tips",
-		Size:          fileInfo.Size(),
-		CreatedAt:     time.Now(),
-		ExpiresAt:     time.Now().AddDate(0, 1, 0),
-		DownloadURL:   fmt.Sprintf("/api/v1/pdf/download/%s", pdfID),
-		Customization: customization,
-	}
-
-	if err := savePDFMetadata(metadata); err != nil {
-		return "", fmt.Errorf("failed to save metadata: %w", err)
-	}
-
-	// Try to upload to GCS if available
-	if gcsClient := GetGCSClient(); gcsClient != nil {
-		ctx := context.Background()
-		objectName := fmt.Sprintf("pdfs/%s", filename)
-		if err := gcsClient.UploadFileFromPath(ctx, objectName, filepath); err == nil {
-			if signedURL, err := gcsClient.GenerateSignedURL(ctx, objectName, 24*time.Hour); err == nil {
-				metadata.DownloadURL = signedURL
-				savePDFMetadata(metadata)
-			}
-		}
-	}
-
-	return metadata.DownloadURL, nil
+	source := PDFSource{Type: "tips", ID: strings.ToLower(destination) + ":" + category}
+	return storePDF(ctx, pdf, source, customization)
 }
 
 // GetPDFMetadata retrieves metadata for a PDF
-func GetPDFMetadata(pdfID string) (*PDFMetadata, error) {
-	metadata, err := loadPDFMetadata(pdfID)
+func GetPDFMetadata(ctx context.Context, pdfID string) (*PDFMetadata, error) {
+	metadata, err := loadPDFMetadata(ctx, pdfID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load PDF metadata: %w", err)
 	}
 	return metadata, nil
 }
 
-// DownloadPDF downloads a PDF file
-func DownloadPDF(id, format string) ([]byte, string, error) {
-	// Get metadata
-	metadata, err := GetPDFMetadata(id)
-	if err != nil {
-		return nil, "", fmt.Errorf("failed to get PDF metadata: %w", err)
-	}
-
-	// Check if file exists locally
-	filepath := filepath.Join(PDFStorageDir, metadata.Filename)
-	if _, err := os.Stat(filepath); err == nil {
-		// File exists locally
-		data, err := os.ReadFile(filepath)
-		if err != nil {
-			return nil, "", fmt.Errorf("failed to read PDF file: %w", err)
-		}
-		return data, metadata.Filename, nil
-	}
-
-	// Try to download from GCS if available
-	if gcsClient := GetGCSClient(); gcsClient != nil {
-		ctx := context.Background()
-		objectName := fmt.Sprintf("pdfs/%s", metadata.Filename)
-
-		data, err := gcsClient.DownloadFile(ctx, objectName)
-		if err == nil {
-			// Save locally for future use
-			if err := os.WriteFile(filepath, data, 0644); err == nil {
-				return data, metadata.Filename, nil
-			}
-		}
-	}
-
-	return nil, "", fmt.Errorf("PDF file not found")
-}
-
 // GetPDFStatus checks the status of PDF generation
-func GetPDFStatus(id string) (*PDFStatus, error) {
+func GetPDFStatus(ctx context.Context, id string) (*PDFStatus, error) {
 	// For now, assume all PDFs are completed
 	// In a real implementation, you'd track generation status
-	metadata, err := GetPDFMetadata(id)
+	metadata, err := GetPDFMetadata(ctx, id)
 	if err != nil {
 		return nil, err
 	}
@@ -474,48 +420,51 @@ func GetPDFStatus(id string) (*PDFStatus, error) {
 }
 
 // DeletePDF deletes a PDF file
-func DeletePDF(id string) error {
+func DeletePDF(ctx context.Context, id string) error {
 	// Get metadata
-	metadata, err := GetPDFMetadata(id)
+	metadata, err := GetPDFMetadata(ctx, id)
 	if err != nil {
 		return fmt.Errorf("failed to get PDF metadata: %w", err)
 	}
 
 	// Delete local file
-	filepath := filepath.Join(PDFStorageDir, metadata.Filename)
+	filepath := filepath.Join(pdfStorageDir(ctx), metadata.Filename)
 	if err := os.Remove(filepath); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to delete local PDF file: %w", err)
 	}
 
-	// Delete from GCS if available
-	if gcsClient := GetGCSClient(); gcsClient != nil {
-		ctx := context.Background()
+	// Delete from the object store
+	if store := GetObjectStore(); store != nil {
+		ctx := storageContext(ctx)
 		objectName := fmt.Sprintf("pdfs/%s", metadata.Filename)
-		if err := gcsClient.DeleteFile(ctx, objectName); err != nil {
+		if err := store.DeleteFile(ctx, objectName); err != nil {
 			// Log error but don't fail - local deletion is more important
-			fmt.Printf("Failed to delete PDF from GCS: %v\n", err)
+			fmt.Printf("Failed to delete PDF from object store: %v\n", err)
 		}
 	}
 
 	// Delete metadata
-	if err := deletePDFMetadata(id); err != nil {
+	if err := deletePDFMetadata(ctx, id); err != nil {
 		return fmt.Errorf("failed to delete PDF metadata: %w", err)
 	}
+	if err := unindexPDF(ctx, metadata); err != nil {
+		return fmt.Errorf("failed to update PDF index: %w", err)
+	}
 
 	return nil
 }
 
 // ListUserPDFs lists PDFs for a user (simplified - lists all PDFs)
-func ListUserPDFs(userID string) ([]PDFMetadata, error) {
+func ListUserPDFs(ctx context.Context, userID string) ([]PDFMetadata, error) {
 	// In a real implementation, you'd filter by user ID
 	// For now, return all PDFs
-	return listAllPDFs()
+	return listAllPDFs(ctx)
 }
 
 // CreateShareableLink creates a shareable link for a PDF
-func CreateShareableLink(id, expiryHours string) (string, error) {
+func CreateShareableLink(ctx context.Context, id, expiryHours string) (string, error) {
 	// Get metadata
-	metadata, err := GetPDFMetadata(id)
+	metadata, err := GetPDFMetadata(ctx, id)
 	if err != nil {
 		return "", fmt.Errorf("failed to get PDF metadata: %w", err)
 	}
@@ -532,7 +481,7 @@ func CreateShareableLink(id, expiryHours string) (string, error) {
 	metadata.ExpiresAt = time.Now().Add(time.Duration(hours) * time.Hour)
 
 	// Save updated metadata
-	if err := savePDFMetadata(*metadata); err != nil {
+	if err := savePDFMetadata(ctx, *metadata); err != nil {
 		return "", fmt.Errorf("failed to save shareable link: %w", err)
 	}
 
@@ -540,17 +489,17 @@ func CreateShareableLink(id, expiryHours string) (string, error) {
 }
 
 // Helper functions for metadata management
-func savePDFMetadata(metadata PDFMetadata) error {
-	metadataFile := filepath.Join(PDFStorageDir, fmt.Sprintf("%s_metadata.json", metadata.ID))
+func savePDFMetadata(ctx context.Context, metadata PDFMetadata) error {
+	metadataFile := filepath.Join(pdfStorageDir(ctx), fmt.Sprintf("%s_metadata.json", metadata.ID))
 	data, err := json.MarshalIndent(metadata, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(metadataFile, data, 0644)
+	return writeFileAtomic(metadataFile, data, 0644)
 }
 
-func loadPDFMetadata(id string) (*PDFMetadata, error) {
-	metadataFile := filepath.Join(PDFStorageDir, fmt.Sprintf("%s_metadata.json", id))
+func loadPDFMetadata(ctx context.Context, id string) (*PDFMetadata, error) {
+	metadataFile := filepath.Join(pdfStorageDir(ctx), fmt.Sprintf("%s_metadata.json", id))
 	data, err := os.ReadFile(metadataFile)
 	if err != nil {
 		return nil, err
@@ -564,13 +513,13 @@ func loadPDFMetadata(id string) (*PDFMetadata, error) {
 	return &metadata, nil
 }
 
-func deletePDFMetadata(id string) error {
-	metadataFile := filepath.Join(PDFStorageDir, fmt.Sprintf("%s_metadata.json", id))
+func deletePDFMetadata(ctx context.Context, id string) error {
+	metadataFile := filepath.Join(pdfStorageDir(ctx), fmt.Sprintf("%s_metadata.json", id))
 	return os.Remove(metadataFile)
 }
 
-func listAllPDFs() ([]PDFMetadata, error) {
-	files, err := os.ReadDir(PDFStorageDir)
+func listAllPDFs(ctx context.Context) ([]PDFMetadata, error) {
+	files, err := os.ReadDir(pdfStorageDir(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -579,7 +528,7 @@ func listAllPDFs() ([]PDFMetadata, error) {
 	for _, file := range files {
 		if strings.HasSuffix(file.Name(), "_metadata.json") {
 			id := strings.TrimSuffix(file.Name(), "_metadata.json")
-			if metadata, err := loadPDFMetadata(id); err == nil {
+			if metadata, err := loadPDFMetadata(ctx, id); err == nil {
 				pdfs = append(pdfs, *metadata)
 			}
 		}
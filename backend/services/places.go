@@ -3,16 +3,18 @@ package services
 //COMPLETED
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"net/http"
-	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/joshndala/cantrip/utils"
 )
 
 // Event represents an event in a city
 type Event struct {
+	ID               string   `json:"id,omitempty"`
 	Name             string   `json:"name"`
 	Description      string   `json:"description"`
 	Date             string   `json:"date"`
@@ -25,18 +27,26 @@ type Event struct {
 	Type             string   `json:"type,omitempty"`
 	TicketsAvailable bool     `json:"tickets_available"`
 	BookingURL       string   `json:"booking_url,omitempty"`
+	OutboundURL      string   `json:"outbound_url,omitempty"` // tracked link to the booking page
+	Provider         string   `json:"provider,omitempty"`
 	Rating           float64  `json:"rating,omitempty"`
 	Tags             []string `json:"tags,omitempty"`
 }
 
 // TripSuggestion represents a trip suggestion
 type TripSuggestion struct {
-	Title         string   `json:"title"`
-	Description   string   `json:"description"`
-	Activities    []string `json:"activities"`
-	EstimatedCost float64  `json:"estimated_cost"`
-	Duration      int      `json:"duration"`
-	Tags          []string `json:"tags"`
+	Title               string      `json:"title"`
+	Description         string      `json:"description"`
+	Activities          []string    `json:"activities"`
+	EstimatedCost       float64     `json:"estimated_cost"`
+	Duration            int         `json:"duration"`
+	Tags                []string    `json:"tags"`
+	Sustainable         bool        `json:"sustainable,omitempty"` // boosted by eco mode
+	SustainabilityNotes []string    `json:"sustainability_notes,omitempty"`
+	Guides              []GuideLink `json:"guides,omitempty"` // curated articles about the suggestion
+	Rating              float64     `json:"rating,omitempty"` // mean rating of the attractions it visits
+	Notes               []string    `json:"notes,omitempty"`  // caveats, such as missing seasonal data
+	Score               float64     `json:"-"`                // set by the score stage, higher ranks first
 }
 
 // EventAPIResponse represents the response from event APIs
@@ -44,27 +54,21 @@ type EventAPIResponse struct {
 	Events []Event `json:"events"`
 }
 
-// MoodInterests maps moods to relevant event categories and interests
-var MoodInterests = map[string][]string{
-	"excited":     {"music", "sports", "festival", "entertainment"},
-	"relaxed":     {"arts", "culture", "museum", "theater"},
-	"adventurous": {"outdoor", "sports", "adventure", "festival"},
-	"romantic":    {"arts", "music", "dining", "theater"},
-	"family":      {"family", "kids", "entertainment", "outdoor"},
-	"cultural":    {"culture", "arts", "museum", "heritage"},
-	"party":       {"music", "nightlife", "festival", "entertainment"},
-	"educational": {"museum", "arts", "culture", "workshop"},
-}
-
-// GetEvents retrieves events for a city based on mood and interests
-func GetEvents(city, mood string, interests []string) ([]Event, error) {
+// GetEvents retrieves events for a city based on mood and interests within the context's deadline budget
+func GetEvents(ctx context.Context, city, mood string, interests []string) ([]Event, error) {
 	// First, try to get events from real APIs
-	if events, err := getEventsFromAPI(city, mood, interests); err == nil && len(events) > 0 {
+	if events, err := getEventsFromAPI(ctx, city, mood, interests); err == nil && len(events) > 0 {
+		events = RegisterOutboundLinks(ctx, rateEvents(ctx, city, events))
+		rememberEvents(city, events)
 		return events, nil
 	}
 
 	// Fallback to sample event data
-	return getEventsFromSampleData(city, mood, interests)
+	events, err := getEventsFromSampleData(ctx, city, mood, interests)
+	if err != nil {
+		return nil, err
+	}
+	return RegisterOutboundLinks(ctx, rateEvents(ctx, city, events)), nil
 }
 
 // FilterEventsByDate filters events by a specific date
@@ -87,117 +91,38 @@ func FilterEventsByDate(events []Event, targetDate string) []Event {
 }
 
 // getEventsFromAPI attempts to get events from real event APIs
-func getEventsFromAPI(city, mood string, interests []string) ([]Event, error) {
+func getEventsFromAPI(ctx context.Context, city, mood string, interests []string) ([]Event, error) {
 	// Check if API keys are configured
-	ticketmasterKey := os.Getenv("TICKETMASTER_API_KEY")
-	eventbriteKey := os.Getenv("EVENTBRITE_API_KEY")
-
-	if ticketmasterKey == "" && eventbriteKey == "" {
+	providers := GetEventProviders()
+	if len(providers) == 0 {
 		return nil, fmt.Errorf("no event API keys configured")
 	}
-
-	var allEvents []Event
-
-	// Try Ticketmaster API
-	if ticketmasterKey != "" {
-		if events, err := getTicketmasterEvents(city, mood, interests); err == nil {
-			allEvents = append(allEvents, events...)
-		}
+	ctx, cancel, err := WithUpstreamBudget(ctx, UpstreamEvents)
+	if err != nil {
+		return nil, err
 	}
+	defer cancel()
 
-	// Try Eventbrite API
-	if eventbriteKey != "" {
-		if events, err := getEventbriteEvents(city, mood, interests); err == nil {
-			allEvents = append(allEvents, events...)
-		}
-	}
+	allEvents := searchProviderEvents(ctx, providers, city)
 
 	// Filter and rank events based on mood and interests
-	filteredEvents := filterEventsByMoodAndInterests(allEvents, mood, interests)
+	filteredEvents := filterEventsByMoodAndInterests(ctx, allEvents, mood, interests)
 
 	return filteredEvents, nil
 }
 
-// getTicketmasterEvents gets events from Ticketmaster API
-func getTicketmasterEvents(city, mood string, interests []string) ([]Event, error) {
-	apiKey := os.Getenv("TICKETMASTER_API_KEY")
-
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-
-	// Build query parameters
-	params := fmt.Sprintf("?apikey=%s&city=%s&size=20", apiKey, city)
-
-	url := fmt.Sprintf("https://app.ticketmaster.com/discovery/v2/events.json%s", params)
-
-	req, err := http.NewRequestWithContext(context.Background(), "GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create Ticketmaster request: %w", err)
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch Ticketmaster events: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Ticketmaster API returned status: %d", resp.StatusCode)
-	}
-
-	// Parse Ticketmaster response (simplified)
-	var apiResponse map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&apiResponse); err != nil {
-		return nil, fmt.Errorf("failed to decode Ticketmaster response: %w", err)
-	}
-
-	// Convert Ticketmaster response to our Event format
-	events := convertTicketmasterResponse(apiResponse)
-
-	return events, nil
-}
-
-// getEventbriteEvents gets events from Eventbrite API
-func getEventbriteEvents(city, mood string, interests []string) ([]Event, error) {
-	apiKey := os.Getenv("EVENTBRITE_API_KEY")
-
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-
-	// Build query parameters
-	params := fmt.Sprintf("?location.address=%s&expand=venue", city)
-
-	url := fmt.Sprintf("https://www.eventbriteapi.com/v3/events/search/%s", params)
-
-	req, err := http.NewRequestWithContext(context.Background(), "GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create Eventbrite request: %w", err)
-	}
-
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", apiKey))
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch Eventbrite events: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Eventbrite API returned status: %d", resp.StatusCode)
-	}
-
-	// Parse Eventbrite response (simplified)
-	var apiResponse map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&apiResponse); err != nil {
-		return nil, fmt.Errorf("failed to decode Eventbrite response: %w", err)
+// searchProviderEvents queries every configured provider (Ticketmaster, Eventbrite) and tags events with their source
+func searchProviderEvents(ctx context.Context, providers []EventProvider, city string) []Event {
+	var allEvents []Event
+	for _, provider := range providers {
+		if events, err := provider.SearchEvents(ctx, city); err == nil {
+			for i := range events {
+				events[i].Provider = provider.Name()
+			}
+			allEvents = append(allEvents, events...)
+		}
 	}
-
-	// Convert Eventbrite response to our Event format
-	events := convertEventbriteResponse(apiResponse)
-
-	return events, nil
+	return allEvents
 }
 
 // convertTicketmasterResponse converts Ticketmaster API response to our Event format
@@ -217,9 +142,9 @@ func convertTicketmasterResponse(response map[string]interface{}) []Event {
 						Price:            getPriceFromTicketmaster(eventMap),
 						Category:         getString(eventMap, "classifications.0.segment.name"),
 						Type:             getString(eventMap, "classifications.0.genre.name"),
-						TicketsAvailable: true,
+						TicketsAvailable: ticketmasterOnSale(eventMap),
 						BookingURL:       getString(eventMap, "url"),
-						Rating:           4.0, // Default rating
+						Rating:           DefaultRating,
 						Tags:             getTagsFromTicketmaster(eventMap),
 					}
 					events = append(events, event)
@@ -248,7 +173,7 @@ func convertEventbriteResponse(response map[string]interface{}) []Event {
 					Category:         getString(eventMap, "category.name"),
 					TicketsAvailable: true,
 					BookingURL:       getString(eventMap, "url"),
-					Rating:           4.0, // Default rating
+					Rating:           DefaultRating,
 					Tags:             getTagsFromEventbrite(eventMap),
 				}
 				events = append(events, event)
@@ -260,9 +185,9 @@ func convertEventbriteResponse(response map[string]interface{}) []Event {
 }
 
 // getEventsFromSampleData gets events from sample data based on mood and interests
-func getEventsFromSampleData(city, mood string, interests []string) ([]Event, error) {
+func getEventsFromSampleData(ctx context.Context, city, mood string, interests []string) ([]Event, error) {
 	// Load city metadata to get real attractions and activities
-	metadata, err := loadCityMetadata()
+	metadata, err := loadCityMetadata(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load city metadata: %w", err)
 	}
@@ -271,14 +196,14 @@ func getEventsFromSampleData(city, mood string, interests []string) ([]Event, er
 	cityData, err := findCity(metadata, city)
 	if err != nil {
 		// Return generic events if city not found
-		return getGenericEvents(city, mood, interests), nil
+		return getGenericEvents(ctx, city, mood, interests), nil
 	}
 
 	// Generate events based on city's attractions and seasonal activities
 	events := generateEventsFromCityData(cityData, mood, interests)
 
 	// Filter events based on mood and interests
-	filteredEvents := filterEventsByMoodAndInterests(events, mood, interests)
+	filteredEvents := filterEventsByMoodAndInterests(ctx, events, mood, interests)
 
 	return filteredEvents, nil
 }
@@ -288,8 +213,7 @@ func generateEventsFromCityData(cityData *City, mood string, interests []string)
 	var events []Event
 
 	// Get current season for relevant activities
-	currentSeason := getCurrentSeason()
-	seasonData, exists := cityData.Seasons[currentSeason]
+	season := citySeason(cityData, time.Now())
 
 	// Create events from attractions
 	for _, attraction := range cityData.Attractions {
@@ -302,44 +226,51 @@ func generateEventsFromCityData(cityData *City, mood string, interests []string)
 			Category:         "attraction",
 			Type:             "sightseeing",
 			TicketsAvailable: false, // Unknown availability
-			Rating:           4.0,   // Default rating
+			Rating:           DefaultRating,
 			Tags:             []string{"attraction", "sightseeing", "tourism"},
 		}
 		events = append(events, event)
 	}
 
-	// Create events from seasonal activities
-	if exists {
-		for _, activity := range seasonData.Activities {
-			event := Event{
-				Name:             activity,
-				Description:      fmt.Sprintf("Experience %s in %s during %s", activity, cityData.Name, currentSeason),
-				Date:             "", // Seasonal activity - check local schedules
-				Location:         cityData.Name,
-				Price:            0, // No pricing info available
-				Category:         "activity",
-				Type:             "seasonal",
-				TicketsAvailable: false, // Unknown availability
-				Rating:           4.0,   // Default rating
-				Tags:             []string{"activity", currentSeason, "local"},
-			}
-			events = append(events, event)
+	// Create events from seasonal activities, saying so when they're borrowed from the
+	// nearest season the city has data for
+	for _, activity := range season.Activities() {
+		description := fmt.Sprintf("Experience %s in %s during %s", activity, cityData.Name, season.Season)
+		if season.Approximate() {
+			description = fmt.Sprintf("Experience %s in %s, a %s activity that may also run in %s", activity, cityData.Name, season.Source, season.Season)
+		}
+		event := Event{
+			Name:             activity,
+			Description:      description,
+			Date:             "", // Seasonal activity - check local schedules
+			Location:         cityData.Name,
+			Price:            0, // No pricing info available
+			Category:         "activity",
+			Type:             "seasonal",
+			TicketsAvailable: false, // Unknown availability
+			Rating:           DefaultRating,
+			Tags:             []string{"activity", season.Source, "local"},
 		}
+		events = append(events, event)
 	}
 
 	// Add some neighborhood exploration events
 	for _, neighborhood := range cityData.Neighborhoods {
+		description := fmt.Sprintf("Discover the vibrant %s neighborhood in %s", neighborhood.Name, cityData.Name)
+		if neighborhood.Description != "" {
+			description = fmt.Sprintf("%s: %s", description, neighborhood.Description)
+		}
 		event := Event{
-			Name:             fmt.Sprintf("Explore %s", neighborhood),
-			Description:      fmt.Sprintf("Discover the vibrant %s neighborhood in %s", neighborhood, cityData.Name),
+			Name:             fmt.Sprintf("Explore %s", neighborhood.Name),
+			Description:      description,
 			Date:             "", // Always available
-			Location:         fmt.Sprintf("%s, %s", neighborhood, cityData.Name),
+			Location:         fmt.Sprintf("%s, %s", neighborhood.Name, cityData.Name),
 			Price:            0, // Free exploration
 			Category:         "neighborhood",
 			Type:             "exploration",
 			TicketsAvailable: true, // Always available
-			Rating:           4.0,  // Default rating
-			Tags:             []string{"neighborhood", "local", "exploration"},
+			Rating:           DefaultRating,
+			Tags:             append([]string{"neighborhood", "local", "exploration"}, neighborhood.Vibes...),
 		}
 		events = append(events, event)
 	}
@@ -348,7 +279,7 @@ func generateEventsFromCityData(cityData *City, mood string, interests []string)
 }
 
 // getGenericEvents returns generic events for cities not in metadata
-func getGenericEvents(city, mood string, interests []string) []Event {
+func getGenericEvents(ctx context.Context, city, mood string, interests []string) []Event {
 	// Create generic events based on mood and interests
 	var events []Event
 
@@ -362,12 +293,12 @@ func getGenericEvents(city, mood string, interests []string) []Event {
 		Category:         "exploration",
 		Type:             "sightseeing",
 		TicketsAvailable: true, // Always available
-		Rating:           4.0,  // Default rating
+		Rating:           DefaultRating,
 		Tags:             []string{"downtown", "exploration", "local"},
 	})
 
 	// Add mood-based generic events
-	moodCategories := MoodInterests[strings.ToLower(mood)]
+	moodCategories := MoodCategories(ctx, mood)
 	if moodCategories != nil {
 		for _, category := range moodCategories {
 			events = append(events, Event{
@@ -379,7 +310,7 @@ func getGenericEvents(city, mood string, interests []string) []Event {
 				Category:         category,
 				Type:             "local",
 				TicketsAvailable: false, // Unknown availability
-				Rating:           4.0,   // Default rating
+				Rating:           DefaultRating,
 				Tags:             []string{category, "local", "experience"},
 			})
 		}
@@ -389,17 +320,21 @@ func getGenericEvents(city, mood string, interests []string) []Event {
 }
 
 // filterEventsByMoodAndInterests filters events based on mood and user interests
-func filterEventsByMoodAndInterests(events []Event, mood string, interests []string) []Event {
+func filterEventsByMoodAndInterests(ctx context.Context, events []Event, mood string, interests []string) []Event {
 	var filteredEvents []Event
 
 	// Get mood-based categories
-	moodCategories := MoodInterests[strings.ToLower(mood)]
-	if moodCategories == nil {
-		moodCategories = []string{"entertainment"} // Default category
-	}
+	moodCategories := moodCategoriesOrDefault(ctx, mood)
 
-	// Create a combined list of interests
-	allInterests := append(interests, moodCategories...)
+	// Create a combined list of interests, lowercased once rather than per event. A new
+	// slice keeps append from writing into the caller's interests.
+	allInterests := make([]string, 0, len(interests)+len(moodCategories))
+	for _, interest := range interests {
+		allInterests = append(allInterests, strings.ToLower(interest))
+	}
+	for _, category := range moodCategories {
+		allInterests = append(allInterests, strings.ToLower(category))
+	}
 
 	for _, event := range events {
 		// Check if event matches any interest or mood category
@@ -419,16 +354,23 @@ func filterEventsByMoodAndInterests(events []Event, mood string, interests []str
 	return filteredEvents
 }
 
-// matchesInterests checks if an event matches any of the given interests
+// matchesInterests checks if an event matches any of the given lowercase interests
 func matchesInterests(event Event, interests []string) bool {
-	eventText := strings.ToLower(event.Name + " " + event.Description + " " + event.Category + " " + event.Type)
-
+	// Build the searchable text once; concatenating tag by tag copied it for every tag
+	var text strings.Builder
+	text.Grow(len(event.Name) + len(event.Description) + len(event.Category) + len(event.Type) + 16*len(event.Tags))
+	for _, field := range []string{event.Name, event.Description, event.Category, event.Type} {
+		text.WriteString(field)
+		text.WriteByte(' ')
+	}
 	for _, tag := range event.Tags {
-		eventText += " " + strings.ToLower(tag)
+		text.WriteString(tag)
+		text.WriteByte(' ')
 	}
+	eventText := strings.ToLower(text.String())
 
 	for _, interest := range interests {
-		if strings.Contains(eventText, strings.ToLower(interest)) {
+		if strings.Contains(eventText, interest) {
 			return true
 		}
 	}
@@ -436,34 +378,35 @@ func matchesInterests(event Event, interests []string) bool {
 	return false
 }
 
-// sortEventsByRating sorts events by rating (highest first)
+// sortEventsByRating sorts events by rating (highest first), keeping the order of ties
 func sortEventsByRating(events []Event) {
-	// Simple bubble sort for small lists
-	for i := 0; i < len(events)-1; i++ {
-		for j := 0; j < len(events)-i-1; j++ {
-			if events[j].Rating < events[j+1].Rating {
-				events[j], events[j+1] = events[j+1], events[j]
-			}
-		}
-	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Rating > events[j].Rating
+	})
 }
 
 // Helper functions for API response parsing
+// getString follows a dotted path through a decoded JSON payload, where numeric keys index
+// arrays, e.g. "_embedded.venues.0.name"
 func getString(data map[string]interface{}, path string) string {
-	keys := strings.Split(path, ".")
-	current := data
-
-	for _, key := range keys {
-		if val, ok := current[key].(map[string]interface{}); ok {
-			current = val
-		} else if val, ok := current[key].(string); ok {
-			return val
-		} else {
+	var current interface{} = data
+	for _, key := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			current = node[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return ""
+			}
+			current = node[i]
+		default:
 			return ""
 		}
 	}
 
-	return ""
+	value, _ := current.(string)
+	return value
 }
 
 func getPriceFromTicketmaster(data map[string]interface{}) float64 {
@@ -490,6 +433,15 @@ func getPriceFromEventbrite(data map[string]interface{}) float64 {
 	return 25.0 // Default price
 }
 
+// ticketmasterOnSale reports whether tickets can still be bought
+func ticketmasterOnSale(data map[string]interface{}) bool {
+	switch getString(data, "dates.status.code") {
+	case "offsale", "cancelled", "canceled":
+		return false
+	}
+	return true
+}
+
 func getTagsFromTicketmaster(data map[string]interface{}) []string {
 	var tags []string
 	if classifications, ok := data["classifications"].([]interface{}); ok && len(classifications) > 0 {
@@ -514,54 +466,61 @@ func getTagsFromEventbrite(data map[string]interface{}) []string {
 	return tags
 }
 
-// GenerateTripSuggestions generates trip suggestions based on mood and interests
-func GenerateTripSuggestions(mood, city string, budget float64, duration int, interests []string, weather WeatherInfo) ([]TripSuggestion, error) {
-	// Load city metadata to get real attractions and activities
-	metadata, err := loadCityMetadata()
-	if err != nil {
-		return nil, fmt.Errorf("failed to load city metadata: %w", err)
+// GenerateTripSuggestions generates trip suggestions based on mood and interests by running
+// the suggestion pipeline. With eco set, sustainable suggestions are ranked first and carry
+// the city's notes.
+func GenerateTripSuggestions(ctx context.Context, mood, city string, budget float64, duration int, interests []string, weather WeatherInfo, eco bool) ([]TripSuggestion, error) {
+	run := &SuggestionRun{
+		Query: SuggestionQuery{
+			Mood:      mood,
+			City:      city,
+			Budget:    budget,
+			Duration:  duration,
+			Interests: interests,
+			Eco:       eco,
+		},
+		Weather: weather,
 	}
-
-	// Find the city in metadata
-	cityData, err := findCity(metadata, city)
-	if err != nil {
-		// Generate generic suggestions if city not found
-		return generateGenericTripSuggestions(mood, city, budget, duration, interests, weather), nil
+	if err := runSuggestionPipeline(ctx, run); err != nil {
+		return nil, err
 	}
-
-	// Generate suggestions based on city data
-	suggestions := generateCityBasedTripSuggestions(cityData, mood, budget, duration, interests, weather)
-
-	return suggestions, nil
+	return run.Suggestions, nil
 }
 
-// generateCityBasedTripSuggestions creates trip suggestions based on city metadata
-func generateCityBasedTripSuggestions(cityData *City, mood string, budget float64, duration int, interests []string, weather WeatherInfo) []TripSuggestion {
+// generateCityBasedTripSuggestions creates candidate trip suggestions based on city metadata
+func generateCityBasedTripSuggestions(ctx context.Context, cityData *City, mood string, budget float64, duration int, interests []string, weather WeatherInfo, eco bool) []TripSuggestion {
 	var suggestions []TripSuggestion
 
-	// Get current season for relevant activities
-	currentSeason := getCurrentSeason()
-	seasonData, exists := cityData.Seasons[currentSeason]
+	// Get current season for relevant activities, noting on the suggestions that draw on
+	// it when the city's data is borrowed from another season or missing
+	season := citySeason(cityData, time.Now())
+	currentSeason := season.Season
+	var seasonNotes []string
+	if note := season.Note(cityData.Name); note != "" {
+		seasonNotes = []string{note}
+	}
 
 	// 1. Cultural Explorer Suggestion
 	suggestions = append(suggestions, TripSuggestion{
 		Title:         fmt.Sprintf("Cultural Explorer in %s", cityData.Name),
 		Description:   fmt.Sprintf("Immerse yourself in the rich culture of %s with museums, galleries, and historic sites", cityData.Name),
-		Activities:    getCulturalActivities(cityData, &seasonData),
-		EstimatedCost: calculateCulturalCost(budget, duration),
+		Activities:    getCulturalActivities(cityData, season.Data),
+		EstimatedCost: estimateCost(ctx, CostCultural, budget, duration, cityData.Name),
 		Duration:      duration,
 		Tags:          []string{"culture", "arts", "history", "museum"},
+		Notes:         seasonNotes,
 	})
 
 	// 2. Outdoor Adventure Suggestion
-	if isGoodWeatherForOutdoor(weather) {
+	if isGoodWeatherForOutdoor(ctx, weather) {
 		suggestions = append(suggestions, TripSuggestion{
 			Title:         fmt.Sprintf("Outdoor Adventure in %s", cityData.Name),
 			Description:   fmt.Sprintf("Explore the natural beauty and outdoor activities in %s", cityData.Name),
-			Activities:    getOutdoorActivities(cityData, &seasonData),
-			EstimatedCost: calculateOutdoorCost(budget, duration),
+			Activities:    getOutdoorActivities(cityData, season.Data),
+			EstimatedCost: estimateCost(ctx, CostOutdoor, budget, duration, cityData.Name),
 			Duration:      duration,
 			Tags:          []string{"outdoor", "nature", "adventure", "active"},
+			Notes:         seasonNotes,
 		})
 	}
 
@@ -569,31 +528,43 @@ func generateCityBasedTripSuggestions(cityData *City, mood string, budget float6
 	suggestions = append(suggestions, TripSuggestion{
 		Title:         fmt.Sprintf("Local Food & Culture in %s", cityData.Name),
 		Description:   fmt.Sprintf("Taste the local cuisine and experience the authentic %s lifestyle", cityData.Name),
-		Activities:    getFoodAndLocalActivities(cityData, &seasonData),
-		EstimatedCost: calculateFoodCost(budget, duration),
+		Activities:    getFoodAndLocalActivities(cityData, season.Data),
+		EstimatedCost: estimateCost(ctx, CostFood, budget, duration, cityData.Name),
 		Duration:      duration,
 		Tags:          []string{"food", "local", "culture", "dining"},
+		Notes:         seasonNotes,
 	})
 
-	// 4. Neighborhood Explorer Suggestion
+	// 4. Neighborhood Explorer Suggestion, picking neighborhoods whose vibe suits the mood
+	neighborhoods := neighborhoodsForMood(cityData, mood)
+	neighborhoodDescription := fmt.Sprintf("Discover the diverse neighborhoods and local life in %s", cityData.Name)
+	neighborhoodTags := []string{"neighborhood", "local", "exploration", "community"}
+	if vibes := MoodNeighborhoodVibes[strings.ToLower(mood)]; len(vibes) > 0 && len(neighborhoods) < len(cityData.Neighborhoods) {
+		neighborhoodDescription = fmt.Sprintf("Discover %s, the neighborhoods of %s best suited to a %s trip", strings.Join(neighborhoodNames(neighborhoods), ", "), cityData.Name, mood)
+		for _, neighborhood := range neighborhoods {
+			neighborhoodTags = append(neighborhoodTags, neighborhood.Vibes...)
+		}
+		neighborhoodTags = utils.RemoveDuplicates(neighborhoodTags)
+	}
 	suggestions = append(suggestions, TripSuggestion{
 		Title:         fmt.Sprintf("Neighborhood Explorer in %s", cityData.Name),
-		Description:   fmt.Sprintf("Discover the diverse neighborhoods and local life in %s", cityData.Name),
-		Activities:    getNeighborhoodActivities(cityData),
-		EstimatedCost: calculateNeighborhoodCost(budget, duration),
+		Description:   neighborhoodDescription,
+		Activities:    getNeighborhoodActivities(neighborhoods),
+		EstimatedCost: estimateCost(ctx, CostNeighborhood, budget, duration, cityData.Name),
 		Duration:      duration,
-		Tags:          []string{"neighborhood", "local", "exploration", "community"},
+		Tags:          neighborhoodTags,
 	})
 
 	// 5. Seasonal Special Suggestion
-	if exists {
+	if season.Found() {
 		suggestions = append(suggestions, TripSuggestion{
 			Title:         fmt.Sprintf("%s Seasonal Experience in %s", strings.Title(currentSeason), cityData.Name),
 			Description:   fmt.Sprintf("Experience the best of %s during %s with seasonal activities and events", cityData.Name, currentSeason),
-			Activities:    seasonData.Activities,
-			EstimatedCost: calculateSeasonalCost(budget, duration),
+			Activities:    season.Activities(),
+			EstimatedCost: estimateCost(ctx, CostSeasonal, budget, duration, cityData.Name),
 			Duration:      duration,
 			Tags:          append([]string{currentSeason, "seasonal"}, interests...),
+			Notes:         seasonNotes,
 		})
 	}
 
@@ -601,25 +572,30 @@ func generateCityBasedTripSuggestions(cityData *City, mood string, budget float6
 	suggestions = append(suggestions, TripSuggestion{
 		Title:         fmt.Sprintf("Budget-Friendly %s Experience", cityData.Name),
 		Description:   fmt.Sprintf("Explore %s on a budget with free and low-cost activities", cityData.Name),
-		Activities:    getBudgetActivities(cityData, &seasonData),
-		EstimatedCost: budget * 0.6, // 60% of budget
+		Activities:    getBudgetActivities(cityData, season.Data),
+		EstimatedCost: estimateCost(ctx, CostBudget, budget, duration, cityData.Name),
 		Duration:      duration,
 		Tags:          []string{"budget", "affordable", "free", "value"},
+		Notes:         seasonNotes,
 	})
 
-	// Filter suggestions based on mood and interests
-	filteredSuggestions := filterSuggestionsByMoodAndInterests(suggestions, mood, interests)
+	// 7. Nights out for the party mood
+	if strings.EqualFold(mood, "party") {
+		if nightOut, ok := nightlifeTripSuggestion(ctx, cityData, budget, duration); ok {
+			suggestions = append([]TripSuggestion{nightOut}, suggestions...)
+		}
+	}
 
-	// Limit to top 5 suggestions
-	if len(filteredSuggestions) > 5 {
-		filteredSuggestions = filteredSuggestions[:5]
+	// 8. Low-impact suggestion for eco travelers
+	if eco {
+		suggestions = append(suggestions, ecoTripSuggestion(ctx, cityData, budget, duration))
 	}
 
-	return filteredSuggestions
+	return suggestions
 }
 
 // generateGenericTripSuggestions creates generic suggestions for cities not in metadata
-func generateGenericTripSuggestions(mood, city string, budget float64, duration int, interests []string, weather WeatherInfo) []TripSuggestion {
+func generateGenericTripSuggestions(ctx context.Context, mood, city string, budget float64, duration int, interests []string, weather WeatherInfo) []TripSuggestion {
 	var suggestions []TripSuggestion
 
 	// Generic cultural suggestion
@@ -627,13 +603,13 @@ func generateGenericTripSuggestions(mood, city string, budget float64, duration
 		Title:         fmt.Sprintf("Discover %s", city),
 		Description:   fmt.Sprintf("Explore the culture, history, and attractions of %s", city),
 		Activities:    []string{"Visit local museums", "Explore downtown", "Try local cuisine", "Visit historic sites"},
-		EstimatedCost: budget * 0.8,
+		EstimatedCost: estimateCost(ctx, CostGeneric, budget, duration, city),
 		Duration:      duration,
 		Tags:          append([]string{"culture", "exploration"}, interests...),
 	})
 
 	// Mood-based suggestion
-	moodCategories := MoodInterests[strings.ToLower(mood)]
+	moodCategories := MoodCategories(ctx, mood)
 	if moodCategories != nil {
 		activities := []string{}
 		for _, category := range moodCategories {
@@ -645,7 +621,7 @@ func generateGenericTripSuggestions(mood, city string, budget float64, duration
 			Title:         fmt.Sprintf("%s Adventure in %s", strings.Title(mood), city),
 			Description:   fmt.Sprintf("Enjoy a %s experience in %s with activities tailored to your mood", mood, city),
 			Activities:    activities,
-			EstimatedCost: budget * 0.9,
+			EstimatedCost: estimateCost(ctx, CostGenericMood, budget, duration, city),
 			Duration:      duration,
 			Tags:          append(moodCategories, interests...),
 		})
@@ -743,12 +719,12 @@ func getFoodAndLocalActivities(cityData *City, seasonData *Season) []string {
 	return activities
 }
 
-func getNeighborhoodActivities(cityData *City) []string {
+func getNeighborhoodActivities(neighborhoods []Neighborhood) []string {
 	var activities []string
 
 	// Add neighborhood exploration
-	for _, neighborhood := range cityData.Neighborhoods {
-		activities = append(activities, fmt.Sprintf("Explore %s", neighborhood))
+	for _, neighborhood := range neighborhoods {
+		activities = append(activities, fmt.Sprintf("Explore %s", neighborhood.Name))
 	}
 
 	// Add generic neighborhood activities
@@ -786,51 +762,29 @@ func getBudgetActivities(cityData *City, seasonData *Season) []string {
 	return activities
 }
 
-// Helper functions for cost calculation
-func calculateCulturalCost(budget float64, duration int) float64 {
-	return budget * 0.7 // Cultural activities are typically moderate cost
-}
-
-func calculateOutdoorCost(budget float64, duration int) float64 {
-	return budget * 0.5 // Outdoor activities are often lower cost
-}
-
-func calculateFoodCost(budget float64, duration int) float64 {
-	return budget * 0.8 // Food experiences can be expensive
-}
-
-func calculateNeighborhoodCost(budget float64, duration int) float64 {
-	return budget * 0.6 // Neighborhood exploration is moderate cost
-}
-
-func calculateSeasonalCost(budget float64, duration int) float64 {
-	return budget * 0.75 // Seasonal activities vary in cost
-}
-
 // Helper functions for filtering
-func isGoodWeatherForOutdoor(weather WeatherInfo) bool {
-	// Consider weather suitable for outdoor activities
-	return weather.Temperature > 10 &&
-		weather.Temperature < 35 &&
-		!strings.Contains(strings.ToLower(weather.Condition), "rain") &&
-		!strings.Contains(strings.ToLower(weather.Condition), "snow")
+func isGoodWeatherForOutdoor(ctx context.Context, weather WeatherInfo) bool {
+	return runFilter(ctx, FilterOutdoorWeather, weatherEnv{
+		Temperature: weather.Temperature,
+		FeelsLike:   weather.FeelsLike,
+		Condition:   weather.Condition,
+		Humidity:    weather.Humidity,
+		WindSpeed:   weather.WindSpeed,
+	})
 }
 
-func filterSuggestionsByMoodAndInterests(suggestions []TripSuggestion, mood string, interests []string) []TripSuggestion {
+func filterSuggestionsByMoodAndInterests(ctx context.Context, suggestions []TripSuggestion, mood string, interests []string) []TripSuggestion {
 	var filteredSuggestions []TripSuggestion
 
 	// Get mood-based categories
-	moodCategories := MoodInterests[strings.ToLower(mood)]
-	if moodCategories == nil {
-		moodCategories = []string{"entertainment"} // Default category
-	}
+	moodCategories := moodCategoriesOrDefault(ctx, mood)
 
 	// Create a combined list of interests
-	allInterests := append(interests, moodCategories...)
+	allInterests := append(append([]string{}, interests...), moodCategories...)
 
 	for _, suggestion := range suggestions {
-		// Check if suggestion matches any interest or mood category
-		if matchesSuggestionInterests(suggestion, allInterests) {
+		// Check if suggestion matches the rules' suggestion filter
+		if matchesSuggestionInterests(ctx, suggestion, mood, allInterests) {
 			filteredSuggestions = append(filteredSuggestions, suggestion)
 		}
 	}
@@ -838,18 +792,17 @@ func filterSuggestionsByMoodAndInterests(suggestions []TripSuggestion, mood stri
 	return filteredSuggestions
 }
 
-func matchesSuggestionInterests(suggestion TripSuggestion, interests []string) bool {
-	suggestionText := strings.ToLower(suggestion.Title + " " + suggestion.Description)
-
-	for _, tag := range suggestion.Tags {
-		suggestionText += " " + strings.ToLower(tag)
+func matchesSuggestionInterests(ctx context.Context, suggestion TripSuggestion, mood string, interests []string) bool {
+	env := suggestionEnv{
+		Title:       strings.ToLower(suggestion.Title),
+		Description: strings.ToLower(suggestion.Description),
+		Mood:        strings.ToLower(mood),
+		Interests:   interests,
 	}
-
-	for _, interest := range interests {
-		if strings.Contains(suggestionText, strings.ToLower(interest)) {
-			return true
-		}
+	for _, tag := range suggestion.Tags {
+		env.Tags = append(env.Tags, strings.ToLower(tag))
 	}
+	env.Text = strings.Join(append([]string{env.Title, env.Description}, env.Tags...), " ")
 
-	return false
+	return runFilter(ctx, FilterSuggestion, env)
 }
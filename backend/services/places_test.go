@@ -0,0 +1,49 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// benchmarkEvents is a city's worth of events, a few of which match the benchmark interests
+func benchmarkEvents(n int) []Event {
+	categories := []string{"Music", "Sports", "Arts & Theatre", "Family", "Food & Drink"}
+	events := make([]Event, n)
+	for i := range events {
+		category := categories[i%len(categories)]
+		events[i] = Event{
+			Name:        fmt.Sprintf("Downtown Event %d", i),
+			Description: strings.Repeat("An evening out in the heart of the city. ", 4),
+			Category:    category,
+			Type:        "Performance",
+			Rating:      float64(i%50) / 10,
+			Tags:        []string{strings.ToLower(category), "downtown", "weekend", "tickets"},
+		}
+	}
+	return events
+}
+
+func BenchmarkMatchesInterests(b *testing.B) {
+	events := benchmarkEvents(200)
+	interests := []string{"jazz", "hockey", "food & drink", "festival", "gallery"}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, event := range events {
+			matchesInterests(event, interests)
+		}
+	}
+}
+
+func BenchmarkFilterEventsByMoodAndInterests(b *testing.B) {
+	events := benchmarkEvents(200)
+	interests := []string{"Jazz", "Hockey", "Food & Drink"}
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		filterEventsByMoodAndInterests(ctx, events, "adventurous", interests)
+	}
+}
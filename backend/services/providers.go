@@ -0,0 +1,461 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// Upstream provider endpoints
+const (
+	OpenWeatherMapBaseURL = "http://api.openweathermap.org/data/2.5"
+	TicketmasterBaseURL   = "https://app.ticketmaster.com/discovery/v2"
+	EventbriteBaseURL     = "https://www.eventbriteapi.com/v3"
+	UpstreamTimeout       = 10 * time.Second
+)
+
+// ForecastQuery identifies a forecast location by city name or coordinates
+type ForecastQuery struct {
+	City string
+	Lat  float64
+	Lon  float64
+}
+
+// WeatherProvider fetches current conditions and forecasts from a weather API
+type WeatherProvider interface {
+	CurrentWeather(ctx context.Context, city string) (WeatherInfo, error)
+	Forecast(ctx context.Context, query ForecastQuery) (WeatherForecastResponse, error)
+}
+
+// EventProvider searches an events API
+type EventProvider interface {
+	Name() string
+	SearchEvents(ctx context.Context, city string) ([]Event, error)
+}
+
+// RentalProvider searches a car rental API or aggregator
+type RentalProvider interface {
+	Name() string
+	SearchRentals(ctx context.Context, query RentalQuery) ([]RentalOffer, error)
+}
+
+var (
+	providersMu      sync.RWMutex
+	providersLoaded  bool
+	weatherProvider  WeatherProvider
+	eventProviders   []EventProvider
+	rentalProvider   RentalProvider
+	speechProvider   SpeechToTextProvider
+	ttsProvider      TextToSpeechProvider
+	moderator        ModerationProvider
+	analyticsSink    AnalyticsSink
+	errorReporter    ErrorReporter
+	embedder         EmbeddingProvider
+	vectorStore      VectorStore
+	mailer           Mailer
+	ocrProvider      OCRProvider
+	upstreamHTTPOnce sync.Once
+	upstreamHTTP     *http.Client
+)
+
+// GetWeatherProvider returns the configured weather provider, or nil if none is configured
+func GetWeatherProvider() WeatherProvider {
+	loadProviders()
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	return weatherProvider
+}
+
+// GetEventProviders returns the configured event providers
+func GetEventProviders() []EventProvider {
+	loadProviders()
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	return eventProviders
+}
+
+// GetRentalProvider returns the configured car rental provider
+func GetRentalProvider() RentalProvider {
+	loadProviders()
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	return rentalProvider
+}
+
+// GetSpeechToTextProvider returns the configured speech-to-text provider, or nil if none is configured
+func GetSpeechToTextProvider() SpeechToTextProvider {
+	loadProviders()
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	return speechProvider
+}
+
+// GetTextToSpeechProvider returns the configured text-to-speech provider, or nil if none is configured
+func GetTextToSpeechProvider() TextToSpeechProvider {
+	loadProviders()
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	return ttsProvider
+}
+
+// GetModerationProvider returns the chat moderation provider, the local rules by default
+func GetModerationProvider() ModerationProvider {
+	loadProviders()
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	return moderator
+}
+
+// GetAnalyticsSink returns the usage analytics sink, or nil if analytics are off
+func GetAnalyticsSink() AnalyticsSink {
+	loadProviders()
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	return analyticsSink
+}
+
+// GetErrorReporter returns the panic reporter, which logs only by default
+func GetErrorReporter() ErrorReporter {
+	loadProviders()
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	return errorReporter
+}
+
+// GetEmbeddingProvider returns the semantic search embedding provider, the local embedder by default
+func GetEmbeddingProvider() EmbeddingProvider {
+	loadProviders()
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	return embedder
+}
+
+// GetVectorStore returns where semantic search embeddings are kept, local storage by default
+func GetVectorStore() VectorStore {
+	loadProviders()
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	return vectorStore
+}
+
+// GetMailer returns the configured mailer, or nil if email isn't configured
+func GetMailer() Mailer {
+	loadProviders()
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	return mailer
+}
+
+// GetOCRProvider returns the configured receipt OCR provider, or nil if none is configured
+func GetOCRProvider() OCRProvider {
+	loadProviders()
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	return ocrProvider
+}
+
+// SetWeatherProvider overrides the weather provider
+func SetWeatherProvider(provider WeatherProvider) {
+	loadProviders()
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	weatherProvider = provider
+}
+
+// SetEventProviders overrides the event providers
+func SetEventProviders(providers ...EventProvider) {
+	loadProviders()
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	eventProviders = providers
+}
+
+// SetRentalProvider overrides the car rental provider
+func SetRentalProvider(provider RentalProvider) {
+	loadProviders()
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	rentalProvider = provider
+}
+
+// SetSpeechToTextProvider overrides the speech-to-text provider
+func SetSpeechToTextProvider(provider SpeechToTextProvider) {
+	loadProviders()
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	speechProvider = provider
+}
+
+// SetTextToSpeechProvider overrides the text-to-speech provider
+func SetTextToSpeechProvider(provider TextToSpeechProvider) {
+	loadProviders()
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	ttsProvider = provider
+}
+
+// SetModerationProvider overrides the chat moderation provider
+func SetModerationProvider(provider ModerationProvider) {
+	loadProviders()
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	moderator = provider
+}
+
+// SetAnalyticsSink overrides the usage analytics sink
+func SetAnalyticsSink(sink AnalyticsSink) {
+	loadProviders()
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	analyticsSink = sink
+}
+
+// SetErrorReporter overrides the panic reporter
+func SetErrorReporter(reporter ErrorReporter) {
+	loadProviders()
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	errorReporter = reporter
+}
+
+// SetEmbeddingProvider overrides the semantic search embedding provider
+func SetEmbeddingProvider(provider EmbeddingProvider) {
+	loadProviders()
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	embedder = provider
+}
+
+// SetVectorStore overrides where semantic search embeddings are kept
+func SetVectorStore(store VectorStore) {
+	loadProviders()
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	vectorStore = store
+}
+
+// SetMailer overrides the mailer
+func SetMailer(m Mailer) {
+	loadProviders()
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	mailer = m
+}
+
+// SetOCRProvider overrides the receipt OCR provider
+func SetOCRProvider(provider OCRProvider) {
+	loadProviders()
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	ocrProvider = provider
+}
+
+// loadProviders builds the default providers from the API keys in the environment
+func loadProviders() {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	if providersLoaded {
+		return
+	}
+	providersLoaded = true
+
+	weatherClient := upstreamClientFor(UpstreamWeather)
+	eventsClient := upstreamClientFor(UpstreamEvents)
+
+	if apiKey := os.Getenv("WEATHER_API_KEY"); apiKey != "" {
+		weatherProvider = dedupeWeatherProvider(&OpenWeatherMapClient{APIKey: apiKey, BaseURL: OpenWeatherMapBaseURL, HTTPClient: weatherClient})
+	}
+	if apiKey := os.Getenv("TICKETMASTER_API_KEY"); apiKey != "" {
+		eventProviders = append(eventProviders, dedupeEventProvider(&TicketmasterClient{APIKey: apiKey, BaseURL: TicketmasterBaseURL, HTTPClient: eventsClient}))
+	}
+	if apiKey := os.Getenv("EVENTBRITE_API_KEY"); apiKey != "" {
+		eventProviders = append(eventProviders, dedupeEventProvider(&EventbriteClient{APIKey: apiKey, BaseURL: EventbriteBaseURL, HTTPClient: eventsClient}))
+	}
+	// Rentals are priced from sample data until a live aggregator is set
+	rentalProvider = &SampleRentalProvider{}
+	speechProvider = newSpeechProvider()
+	ttsProvider = newTTSProvider()
+	moderator = newModerationProvider()
+	analyticsSink = newAnalyticsSink()
+	errorReporter = newErrorReporter()
+	embedder = newEmbeddingProvider()
+	vectorStore = newVectorStore()
+	mailer = newMailer()
+	ocrProvider = newOCRProvider()
+}
+
+// upstreamHTTPClient returns the HTTP client shared by upstream providers,
+// routed through recorded fixtures when UPSTREAM_FIXTURES is set and traced
+func upstreamHTTPClient() *http.Client {
+	upstreamHTTPOnce.Do(func() {
+		var transport http.RoundTripper
+		if fixtures := NewFixtureTransportFromEnv(); fixtures != nil {
+			transport = fixtures
+		}
+		upstreamHTTP = &http.Client{Timeout: UpstreamTimeout, Transport: tracedTransport(transport)}
+	})
+	return upstreamHTTP
+}
+
+// upstreamClientFor shares the upstream transport with a timeout configured for one upstream
+func upstreamClientFor(upstream string) *http.Client {
+	return &http.Client{Timeout: UpstreamTimeoutFor(upstream), Transport: upstreamHTTPClient().Transport}
+}
+
+// getUpstreamJSON performs a GET request and decodes the JSON body into v
+func getUpstreamJSON(ctx context.Context, client *http.Client, provider, rawURL string, headers map[string]string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %w", provider, err)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch from %s: %w", provider, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s API returned status: %d", provider, resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", provider, err)
+	}
+
+	return nil
+}
+
+// OpenWeatherMapClient is a WeatherProvider backed by the OpenWeatherMap API
+type OpenWeatherMapClient struct {
+	APIKey     string
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// CurrentWeather fetches the current conditions for a city
+func (c *OpenWeatherMapClient) CurrentWeather(ctx context.Context, city string) (WeatherInfo, error) {
+	params := url.Values{}
+	params.Set("q", city)
+	params.Set("appid", c.APIKey)
+	params.Set("units", "metric")
+
+	var apiResponse map[string]interface{}
+	if err := getUpstreamJSON(ctx, c.HTTPClient, "weather", c.BaseURL+"/weather?"+params.Encode(), nil, &apiResponse); err != nil {
+		return WeatherInfo{}, err
+	}
+
+	return parseOpenWeatherCurrent(apiResponse)
+}
+
+// Forecast fetches the 5 day / 3 hour forecast by city name or coordinates
+func (c *OpenWeatherMapClient) Forecast(ctx context.Context, query ForecastQuery) (WeatherForecastResponse, error) {
+	params := url.Values{}
+	if query.City != "" {
+		params.Set("q", query.City)
+	} else {
+		params.Set("lat", fmt.Sprintf("%.4f", query.Lat))
+		params.Set("lon", fmt.Sprintf("%.4f", query.Lon))
+	}
+	params.Set("appid", c.APIKey)
+	params.Set("units", "metric")
+
+	var forecastResp WeatherForecastResponse
+	if err := getUpstreamJSON(ctx, c.HTTPClient, "forecast", c.BaseURL+"/forecast?"+params.Encode(), nil, &forecastResp); err != nil {
+		return WeatherForecastResponse{}, err
+	}
+
+	return forecastResp, nil
+}
+
+// parseOpenWeatherCurrent extracts weather data from an OpenWeatherMap current weather payload
+func parseOpenWeatherCurrent(apiResponse map[string]interface{}) (WeatherInfo, error) {
+	if main, ok := apiResponse["main"].(map[string]interface{}); ok {
+		if temp, ok := main["temp"].(float64); ok {
+			if weather, ok := apiResponse["weather"].([]interface{}); ok && len(weather) > 0 {
+				if weatherObj, ok := weather[0].(map[string]interface{}); ok {
+					if condition, ok := weatherObj["main"].(string); ok {
+						humidity, _ := main["humidity"].(float64)
+						windSpeed := getWindSpeedFromAPI(apiResponse)
+						feelsLike, ok := main["feels_like"].(float64)
+						if !ok {
+							feelsLike = FeelsLike(temp, int(humidity), metersPerSecondToKmh(windSpeed))
+						}
+						return WeatherInfo{
+							Temperature: temp,
+							FeelsLike:   feelsLike,
+							Condition:   condition,
+							Humidity:    int(humidity),
+							WindSpeed:   windSpeed,
+						}, nil
+					}
+				}
+			}
+		}
+	}
+
+	return WeatherInfo{}, fmt.Errorf("failed to parse API response")
+}
+
+// TicketmasterClient is an EventProvider backed by the Ticketmaster Discovery API
+type TicketmasterClient struct {
+	APIKey     string
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+func (c *TicketmasterClient) Name() string {
+	return "ticketmaster"
+}
+
+// SearchEvents fetches events in a city
+func (c *TicketmasterClient) SearchEvents(ctx context.Context, city string) ([]Event, error) {
+	params := url.Values{}
+	params.Set("apikey", c.APIKey)
+	params.Set("city", city)
+	params.Set("size", "20")
+
+	var apiResponse map[string]interface{}
+	if err := getUpstreamJSON(ctx, c.HTTPClient, "Ticketmaster", c.BaseURL+"/events.json?"+params.Encode(), nil, &apiResponse); err != nil {
+		return nil, err
+	}
+
+	// Convert Ticketmaster response to our Event format
+	return convertTicketmasterResponse(apiResponse), nil
+}
+
+// EventbriteClient is an EventProvider backed by the Eventbrite API
+type EventbriteClient struct {
+	APIKey     string
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+func (c *EventbriteClient) Name() string {
+	return "eventbrite"
+}
+
+// SearchEvents fetches events in a city
+func (c *EventbriteClient) SearchEvents(ctx context.Context, city string) ([]Event, error) {
+	params := url.Values{}
+	params.Set("location.address", city)
+	params.Set("expand", "venue")
+
+	headers := map[string]string{"Authorization": fmt.Sprintf("Bearer %s", c.APIKey)}
+
+	var apiResponse map[string]interface{}
+	if err := getUpstreamJSON(ctx, c.HTTPClient, "Eventbrite", c.BaseURL+"/events/search/?"+params.Encode(), headers, &apiResponse); err != nil {
+		return nil, err
+	}
+
+	// Convert Eventbrite response to our Event format
+	return convertEventbriteResponse(apiResponse), nil
+}
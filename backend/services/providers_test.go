@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestOpenWeatherMapCurrentWeatherFixture(t *testing.T) {
+	server := fixtureServer(t, "api.openweathermap.org/data_2.5_weather_c1768f0a.json")
+	client := &OpenWeatherMapClient{APIKey: "test-key", BaseURL: server.URL + "/data/2.5", HTTPClient: server.Client()}
+
+	weather, err := client.CurrentWeather(context.Background(), "Toronto")
+	if err != nil {
+		t.Fatalf("CurrentWeather: %v", err)
+	}
+	want := WeatherInfo{Temperature: 18.42, FeelsLike: 17.93, Condition: "Clouds", Humidity: 64, WindSpeed: 4.12}
+	if weather != want {
+		t.Errorf("CurrentWeather = %+v, want %+v", weather, want)
+	}
+}
+
+func TestOpenWeatherMapForecastFixture(t *testing.T) {
+	server := fixtureServer(t, "api.openweathermap.org/data_2.5_forecast_fa606c82.json")
+	client := &OpenWeatherMapClient{APIKey: "test-key", BaseURL: server.URL + "/data/2.5", HTTPClient: server.Client()}
+
+	forecast, err := client.Forecast(context.Background(), ForecastQuery{City: "Toronto"})
+	if err != nil {
+		t.Fatalf("Forecast: %v", err)
+	}
+	if len(forecast.List) != 16 {
+		t.Fatalf("got %d forecast periods, want 16", len(forecast.List))
+	}
+	if forecast.City.Name != "Toronto" || forecast.City.Timezone != -14400 {
+		t.Errorf("city = %+v, want Toronto at -14400", forecast.City)
+	}
+
+	first := forecast.List[0]
+	if first.Dt != 1727794800 || first.Main.Temp != 14.2 || first.Main.Humidity != 60 {
+		t.Errorf("first period = dt %d, temp %v, humidity %d", first.Dt, first.Main.Temp, first.Main.Humidity)
+	}
+	if first.Main.FeelsLike == nil || *first.Main.FeelsLike != 13.6 {
+		t.Errorf("first period feels like = %v, want 13.6", first.Main.FeelsLike)
+	}
+	if len(first.Weather) == 0 || first.Weather[0].Main != "Clouds" {
+		t.Errorf("first period weather = %+v, want Clouds", first.Weather)
+	}
+	if rainy := forecast.List[4]; rainy.POP == nil || *rainy.POP != 0.62 {
+		t.Errorf("fifth period pop = %v, want 0.62", rainy.POP)
+	}
+}
+
+func TestTicketmasterSearchEventsFixture(t *testing.T) {
+	server := fixtureServer(t, "app.ticketmaster.com/discovery_v2_events.json_b45c871a.json")
+	client := &TicketmasterClient{APIKey: "test-key", BaseURL: server.URL + "/discovery/v2", HTTPClient: server.Client()}
+
+	events, err := client.SearchEvents(context.Background(), "Toronto")
+	if err != nil {
+		t.Fatalf("SearchEvents: %v", err)
+	}
+	want := []Event{
+		{
+			Name:             "Toronto Maple Leafs vs. Montreal Canadiens",
+			Date:             "2024-10-12",
+			Time:             "19:00:00",
+			Location:         "Scotiabank Arena",
+			Price:            89,
+			Category:         "Sports",
+			Type:             "Hockey",
+			TicketsAvailable: true,
+			BookingURL:       "https://www.ticketmaster.ca/event/G5vYZ9Mf3L0nA",
+			Rating:           DefaultRating,
+			Tags:             []string{"sports"},
+		},
+		{
+			Name:             "Toronto Symphony Orchestra: Beethoven's Fifth",
+			Date:             "2024-10-15",
+			Time:             "20:00:00",
+			Location:         "Roy Thomson Hall",
+			Price:            45,
+			Category:         "Arts & Theatre",
+			Type:             "Classical",
+			TicketsAvailable: true,
+			BookingURL:       "https://www.ticketmaster.ca/event/Z7r9jZ1A7jx0k",
+			Rating:           DefaultRating,
+			Tags:             []string{"arts & theatre"},
+		},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("SearchEvents =\n%+v\nwant\n%+v", events, want)
+	}
+}
+
+func TestEventbriteSearchEventsFixture(t *testing.T) {
+	server := fixtureServer(t, "www.eventbriteapi.com/v3_events_search_890c7c55.json")
+	client := &EventbriteClient{APIKey: "test-key", BaseURL: server.URL + "/v3", HTTPClient: server.Client()}
+
+	events, err := client.SearchEvents(context.Background(), "Toronto")
+	if err != nil {
+		t.Fatalf("SearchEvents: %v", err)
+	}
+	want := []Event{{
+		Name:             "Kensington Market Food Walk",
+		Description:      "Taste your way through one of Toronto's most eclectic neighbourhoods.",
+		Date:             "2024-10-13T11:00:00",
+		EndDate:          "2024-10-13T14:00:00",
+		Location:         "Kensington Market",
+		Price:            25,
+		Category:         "Food & Drink",
+		TicketsAvailable: true,
+		BookingURL:       "https://www.eventbrite.ca/e/kensington-market-food-walk-tickets-1012345678901",
+		Rating:           DefaultRating,
+		Tags:             []string{"food & drink"},
+	}}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("SearchEvents =\n%+v\nwant\n%+v", events, want)
+	}
+}
+
+func TestFixtureTransportReplay(t *testing.T) {
+	client := &OpenWeatherMapClient{
+		APIKey:     "secret",
+		BaseURL:    OpenWeatherMapBaseURL,
+		HTTPClient: &http.Client{Transport: &FixtureTransport{Dir: "../" + DefaultFixtureDir, Mode: FixtureModeReplay}},
+	}
+	weather, err := client.CurrentWeather(context.Background(), "Toronto")
+	if err != nil {
+		t.Fatalf("CurrentWeather through replay: %v", err)
+	}
+	if weather.Temperature != 18.42 {
+		t.Errorf("temperature = %v, want 18.42", weather.Temperature)
+	}
+
+	if _, err := client.CurrentWeather(context.Background(), "Atlantis"); err == nil {
+		t.Error("replaying an unrecorded request succeeded, want an error")
+	}
+}
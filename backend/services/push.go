@@ -0,0 +1,377 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/joshndala/cantrip/utils"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// Storage collections for push delivery
+const (
+	DeviceCollection                 = "devices"
+	NotificationPreferenceCollection = "notification_preferences"
+)
+
+// Device platforms
+const (
+	PlatformAndroid = "android"
+	PlatformIOS     = "ios"
+	PlatformWeb     = "web"
+)
+
+// FCMSendURL is the Firebase Cloud Messaging HTTP v1 endpoint
+const FCMSendURL = "https://fcm.googleapis.com/v1/projects/%s/messages:send"
+
+// fcmScope is the OAuth scope required to send messages
+const fcmScope = "https://www.googleapis.com/auth/firebase.messaging"
+
+// fcmMaxAttempts bounds retries of transient FCM failures
+const fcmMaxAttempts = 3
+
+// errPermanentPush marks failures that retrying cannot fix
+var errPermanentPush = errors.New("permanent push failure")
+
+// Device is a registered push notification token
+type Device struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"user_id"`
+	Token      string    `json:"token"`
+	Platform   string    `json:"platform"` // android, ios, web
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+// NotificationPreferences controls which notifications a user receives on each channel.
+// The inbox always keeps every notification; preferences only affect delivery.
+type NotificationPreferences struct {
+	UserID     string      `json:"user_id"`
+	Push       bool        `json:"push"`
+	Webhook    bool        `json:"webhook"`
+	MutedTypes []string    `json:"muted_types"`
+	QuietHours *QuietHours `json:"quiet_hours,omitempty"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+}
+
+// QuietHours suppresses push delivery during a daily window
+type QuietHours struct {
+	Start    string `json:"start"`    // HH:MM
+	End      string `json:"end"`      // HH:MM
+	Timezone string `json:"timezone"` // IANA name, defaults to UTC
+}
+
+// deviceID derives a storage ID from a token so re-registering is idempotent
+func deviceID(token string) string {
+	sum := sha1.Sum([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// RegisterDevice stores a push token for a user
+func RegisterDevice(ctx context.Context, userID, token, platform string) (*Device, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, fmt.Errorf("token is required")
+	}
+	switch platform {
+	case PlatformAndroid, PlatformIOS, PlatformWeb:
+	default:
+		return nil, fmt.Errorf("invalid platform %q, expected android, ios or web", platform)
+	}
+
+	now := time.Now().UTC()
+	device := Device{ID: deviceID(token), UserID: userID, Token: token, Platform: platform, CreatedAt: now, LastSeenAt: now}
+
+	var existing Device
+	if err := loadDocument(ctx, DeviceCollection, device.ID, &existing); err == nil && existing.UserID == userID {
+		device.CreatedAt = existing.CreatedAt
+	}
+
+	if err := saveDocument(ctx, DeviceCollection, device.ID, device); err != nil {
+		return nil, fmt.Errorf("failed to save device: %w", err)
+	}
+	return &device, nil
+}
+
+// UnregisterDevice removes one of a user's push tokens
+func UnregisterDevice(ctx context.Context, userID, token string) error {
+	id := deviceID(token)
+
+	var device Device
+	if err := loadDocument(ctx, DeviceCollection, id, &device); err != nil {
+		return err
+	}
+	if device.UserID != userID {
+		return fmt.Errorf("%s/%s: %w", DeviceCollection, id, ErrDocumentNotFound)
+	}
+	return deleteDocument(ctx, DeviceCollection, id)
+}
+
+// ListDevices returns a user's registered devices
+func ListDevices(ctx context.Context, userID string) ([]Device, error) {
+	ids, err := listDocumentIDs(ctx, DeviceCollection)
+	if err != nil {
+		return nil, err
+	}
+
+	devices := []Device{}
+	for _, id := range ids {
+		var device Device
+		if err := loadDocument(ctx, DeviceCollection, id, &device); err != nil {
+			continue
+		}
+		if device.UserID == userID {
+			devices = append(devices, device)
+		}
+	}
+	return devices, nil
+}
+
+// GetNotificationPreferences returns a user's preferences, defaulting to every channel enabled
+func GetNotificationPreferences(ctx context.Context, userID string) NotificationPreferences {
+	prefs := NotificationPreferences{UserID: userID, Push: true, Webhook: true, MutedTypes: []string{}}
+	if err := loadDocument(ctx, NotificationPreferenceCollection, userID, &prefs); err != nil && !errors.Is(err, ErrDocumentNotFound) {
+		utils.LogError("Failed to load notification preferences", err)
+	}
+	if prefs.MutedTypes == nil {
+		prefs.MutedTypes = []string{}
+	}
+	return prefs
+}
+
+// UpdateNotificationPreferences replaces a user's preferences
+func UpdateNotificationPreferences(ctx context.Context, prefs NotificationPreferences) (*NotificationPreferences, error) {
+	if prefs.QuietHours != nil {
+		for _, value := range []string{prefs.QuietHours.Start, prefs.QuietHours.End} {
+			if _, err := time.Parse("15:04", value); err != nil {
+				return nil, fmt.Errorf("quiet hours must use HH:MM, got %q", value)
+			}
+		}
+		if prefs.QuietHours.Timezone != "" {
+			if _, err := time.LoadLocation(prefs.QuietHours.Timezone); err != nil {
+				return nil, fmt.Errorf("invalid quiet hours timezone: %w", err)
+			}
+		}
+	}
+	if prefs.MutedTypes == nil {
+		prefs.MutedTypes = []string{}
+	}
+	prefs.UpdatedAt = time.Now().UTC()
+
+	if err := saveDocument(ctx, NotificationPreferenceCollection, prefs.UserID, prefs); err != nil {
+		return nil, fmt.Errorf("failed to save notification preferences: %w", err)
+	}
+	return &prefs, nil
+}
+
+// allows reports whether a notification may be delivered over a channel at the given time
+func (p NotificationPreferences) allows(channel, notificationType string, at time.Time) bool {
+	if utils.Contains(p.MutedTypes, notificationType) {
+		return false
+	}
+
+	switch channel {
+	case "push":
+		return p.Push && !p.QuietHours.contains(at)
+	case "webhook":
+		return p.Webhook
+	}
+	return true
+}
+
+// contains reports whether a time falls inside the quiet hours window
+func (q *QuietHours) contains(at time.Time) bool {
+	if q == nil || q.Start == "" || q.End == "" {
+		return false
+	}
+	if location, err := time.LoadLocation(q.Timezone); err == nil && q.Timezone != "" {
+		at = at.In(location)
+	} else {
+		at = at.UTC()
+	}
+
+	now := at.Format("15:04")
+	if q.Start <= q.End {
+		return now >= q.Start && now < q.End
+	}
+	// Window wraps past midnight, e.g. 22:00-07:00
+	return now >= q.Start || now < q.End
+}
+
+// FCMNotifier delivers notifications to a user's devices through Firebase Cloud Messaging
+type FCMNotifier struct {
+	ProjectID  string
+	SendURL    string       // overrides FCMSendURL, e.g. for an emulator
+	HTTPClient *http.Client // must attach OAuth credentials
+	// Backoff returns the wait before retry attempt n (1-based)
+	Backoff func(attempt int) time.Duration
+}
+
+// NewFCMNotifierFromEnv configures FCM from FCM_PROJECT_ID and Google application default credentials
+func NewFCMNotifierFromEnv(ctx context.Context) (*FCMNotifier, error) {
+	projectID := os.Getenv("FCM_PROJECT_ID")
+	if projectID == "" {
+		return nil, fmt.Errorf("FCM_PROJECT_ID is not set")
+	}
+
+	tokenSource, err := google.DefaultTokenSource(ctx, fcmScope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load FCM credentials: %w", err)
+	}
+
+	client := oauth2.NewClient(ctx, tokenSource)
+	client.Timeout = 10 * time.Second
+	return &FCMNotifier{ProjectID: projectID, HTTPClient: client}, nil
+}
+
+// Name identifies the channel
+func (f *FCMNotifier) Name() string {
+	return "push"
+}
+
+// Send pushes the notification to every device of the recipient, pruning tokens FCM rejects
+func (f *FCMNotifier) Send(ctx context.Context, notification Notification) error {
+	devices, err := ListDevices(ctx, notification.UserID)
+	if err != nil {
+		return err
+	}
+
+	var failures []string
+	for _, device := range devices {
+		err := f.sendWithRetry(ctx, device, notification)
+		switch {
+		case err == nil:
+		case errors.Is(err, errUnregisteredToken):
+			if err := deleteDocument(ctx, DeviceCollection, device.ID); err != nil {
+				utils.LogError("Failed to prune push token", err)
+			} else {
+				utils.LogInfo(fmt.Sprintf("Pruned unregistered %s device for %s", device.Platform, device.UserID))
+			}
+		default:
+			failures = append(failures, fmt.Sprintf("%s: %v", device.ID[:8], err))
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("push failed for %d device(s): %s", len(failures), strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// errUnregisteredToken means the device token is no longer valid and should be removed
+var errUnregisteredToken = fmt.Errorf("unregistered token: %w", errPermanentPush)
+
+// sendWithRetry sends one message, retrying rate limits and server errors with exponential backoff
+func (f *FCMNotifier) sendWithRetry(ctx context.Context, device Device, notification Notification) error {
+	body, err := json.Marshal(fcmMessage(device, notification))
+	if err != nil {
+		return fmt.Errorf("failed to marshal FCM message: %w", err)
+	}
+
+	backoff := f.Backoff
+	if backoff == nil {
+		backoff = func(attempt int) time.Duration { return time.Duration(1<<(attempt-1)) * time.Second }
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = f.send(ctx, body)
+		if err == nil || errors.Is(err, errPermanentPush) || attempt == fcmMaxAttempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff(attempt)):
+		}
+	}
+}
+
+// send performs a single FCM request
+func (f *FCMNotifier) send(ctx context.Context, body []byte) error {
+	endpoint := f.SendURL
+	if endpoint == "" {
+		endpoint = fmt.Sprintf(FCMSendURL, f.ProjectID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create FCM request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := f.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("FCM request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	payload, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var fcmErr struct {
+		Error struct {
+			Status  string `json:"status"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	_ = json.Unmarshal(payload, &fcmErr)
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || fcmErr.Error.Status == "NOT_FOUND" || fcmErr.Error.Status == "UNREGISTERED":
+		return errUnregisteredToken
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("FCM returned status %d: %s", resp.StatusCode, fcmErr.Error.Message)
+	case resp.StatusCode == http.StatusBadRequest && strings.Contains(strings.ToLower(fcmErr.Error.Message), "registration token"):
+		return errUnregisteredToken
+	default:
+		return fmt.Errorf("FCM returned status %d: %s: %w", resp.StatusCode, fcmErr.Error.Message, errPermanentPush)
+	}
+}
+
+// fcmMessage builds an HTTP v1 message; iOS devices receive it through FCM's APNs bridge
+func fcmMessage(device Device, notification Notification) map[string]interface{} {
+	data := map[string]string{"notification_id": notification.ID, "type": notification.Type}
+	for key, value := range notification.Data {
+		data[key] = value
+	}
+
+	message := map[string]interface{}{
+		"token": device.Token,
+		"notification": map[string]string{
+			"title": notification.Title,
+			"body":  notification.Body,
+		},
+		"data": data,
+	}
+
+	switch device.Platform {
+	case PlatformIOS:
+		message["apns"] = map[string]interface{}{
+			"headers": map[string]string{"apns-priority": "10"},
+			"payload": map[string]interface{}{
+				"aps": map[string]interface{}{"sound": "default", "thread-id": notification.Type},
+			},
+		}
+	case PlatformAndroid:
+		message["android"] = map[string]interface{}{
+			"priority":     "high",
+			"notification": map[string]string{"channel_id": notification.Type},
+		}
+	}
+
+	return map[string]interface{}{"message": message}
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/joshndala/cantrip/utils"
+)
+
+// Drive estimates from straight-line distance, used in place of a routing provider
+const (
+	roadDetourFactor = 1.3 // roads run about a third longer than the straight line
+	averageDriveKmh  = 80  // highway driving with stops and slower stretches
+)
+
+// ErrInvalidReachQuery is returned for an unknown origin or a missing or negative limit
+var ErrInvalidReachQuery = errors.New("invalid origin query")
+
+// ReachQuery asks which supported cities are within a drive of an origin
+type ReachQuery struct {
+	Origin        string  // a supported city or "lat,lng"
+	MaxDriveHours float64 // zero for no limit on the drive
+	MaxDistanceKm float64 // by road; zero for no limit on the distance
+}
+
+// ReachableCity is a supported city within reach of an origin, with the estimated drive
+type ReachableCity struct {
+	Name       string  `json:"name"`
+	Province   string  `json:"province"`
+	DistanceKm float64 `json:"distance_km"` // estimated by road
+	DriveHours float64 `json:"drive_hours"`
+}
+
+// CitiesInReach lists the supported cities within the query's limits of its origin, nearest
+// first. An origin city isn't in its own list.
+func CitiesInReach(ctx context.Context, query ReachQuery) ([]ReachableCity, error) {
+	if query.MaxDriveHours < 0 || query.MaxDistanceKm < 0 {
+		return nil, fmt.Errorf("%w: limits can't be negative", ErrInvalidReachQuery)
+	}
+	if query.MaxDriveHours == 0 && query.MaxDistanceKm == 0 {
+		return nil, fmt.Errorf("%w: max_drive_hours or max_distance_km is required with an origin", ErrInvalidReachQuery)
+	}
+	index, err := GetCityIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	origin, originCity, err := resolveOrigin(index, query.Origin)
+	if err != nil {
+		return nil, err
+	}
+
+	// The tighter of the two limits, as a road distance
+	limitKm := math.MaxFloat64
+	if query.MaxDistanceKm > 0 {
+		limitKm = query.MaxDistanceKm
+	}
+	if query.MaxDriveHours > 0 {
+		limitKm = math.Min(limitKm, query.MaxDriveHours*averageDriveKmh)
+	}
+
+	var reachable []ReachableCity
+	for _, city := range index.Within(origin, limitKm/roadDetourFactor) {
+		if city == originCity {
+			continue
+		}
+		distance, hours := EstimateDrive(origin, city.Coordinates)
+		reachable = append(reachable, ReachableCity{Name: city.Name, Province: city.Province, DistanceKm: distance, DriveHours: hours})
+	}
+	return reachable, nil
+}
+
+// EstimateDrive estimates the road distance in kilometres and the hours it takes to drive,
+// both rounded to one decimal
+func EstimateDrive(from, to Coordinates) (float64, float64) {
+	distance := utils.CalculateDistance(from.Lat, from.Lng, to.Lat, to.Lng) * roadDetourFactor
+	return math.Round(distance*10) / 10, math.Round(distance/averageDriveKmh*10) / 10
+}
+
+// resolveOrigin reads an origin given as "lat,lng" or as a supported city's name, returning
+// the city too when it's one
+func resolveOrigin(index *CityIndex, origin string) (Coordinates, *City, error) {
+	origin = strings.TrimSpace(origin)
+	if parts := strings.Split(origin, ","); len(parts) == 2 {
+		lat, latErr := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+		lng, lngErr := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if latErr == nil && lngErr == nil {
+			if lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+				return Coordinates{}, nil, fmt.Errorf("%w: coordinates out of range", ErrInvalidReachQuery)
+			}
+			return Coordinates{Lat: lat, Lng: lng}, nil, nil
+		}
+	}
+	if city, ok := index.Find(origin); ok {
+		return city.Coordinates, city, nil
+	}
+	return Coordinates{}, nil, fmt.Errorf("%w: unknown origin %q, give a supported city or lat,lng", ErrInvalidReachQuery, origin)
+}
@@ -0,0 +1,251 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// OCR_PROVIDER values
+const (
+	OCRProviderGoogle = "google"
+)
+
+// GoogleVisionBaseURL is the Cloud Vision API endpoint receipts are read with
+const GoogleVisionBaseURL = "https://vision.googleapis.com/v1"
+
+// OCRTimeout bounds reading a receipt, which takes longer than other upstream calls
+const OCRTimeout = 30 * time.Second
+
+var (
+	// ErrOCRUnavailable is returned when no OCR provider is configured
+	ErrOCRUnavailable = errors.New("receipt scanning is not configured")
+	// ErrNoTextRecognized is returned for a photo the provider found no text in
+	ErrNoTextRecognized = errors.New("no text was recognized on the receipt")
+)
+
+// OCRProvider reads the text in an image
+type OCRProvider interface {
+	Name() string
+	RecognizeText(ctx context.Context, image []byte) (string, error)
+}
+
+// ReceiptScan is what was read off a receipt photo. Fields the parser couldn't find are
+// left empty.
+type ReceiptScan struct {
+	Vendor   string  `json:"vendor,omitempty"`
+	Amount   float64 `json:"amount,omitempty"`   // the receipt's total
+	Currency string  `json:"currency,omitempty"` // only when the receipt names one
+	Date     string  `json:"date,omitempty"`     // YYYY-MM-DD
+	Text     string  `json:"text"`
+	Provider string  `json:"provider"`
+}
+
+// newOCRProvider picks the OCR provider from OCR_PROVIDER, or Google Vision when its key
+// is set; the mock agent mode gets a canned receipt
+func newOCRProvider() OCRProvider {
+	if apiKey := os.Getenv("GOOGLE_VISION_API_KEY"); apiKey != "" {
+		if provider := os.Getenv("OCR_PROVIDER"); provider == "" || provider == OCRProviderGoogle {
+			client := &http.Client{Timeout: OCRTimeout, Transport: upstreamHTTPClient().Transport}
+			return &GoogleVisionClient{APIKey: apiKey, BaseURL: GoogleVisionBaseURL, HTTPClient: client}
+		}
+	}
+	if os.Getenv("AI_MODE") == AIModeMock {
+		return &mockOCRProvider{}
+	}
+	return nil
+}
+
+// ScanReceipt reads a receipt photo and pulls out its vendor, total and date
+func ScanReceipt(ctx context.Context, image []byte) (*ReceiptScan, error) {
+	provider := GetOCRProvider()
+	if provider == nil {
+		return nil, ErrOCRUnavailable
+	}
+	text, err := provider.RecognizeText(ctx, image)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil, ErrNoTextRecognized
+	}
+	scan := parseReceiptText(text)
+	scan.Provider = provider.Name()
+	return scan, nil
+}
+
+var (
+	receiptAmountExpr = regexp.MustCompile(`(?:[$€£]\s*)?(\d{1,3}(?:,\d{3})+|\d+)[.,](\d{2})\b`)
+	receiptISODate    = regexp.MustCompile(`\b(20\d{2})[-/.](\d{1,2})[-/.](\d{1,2})\b`)
+	receiptUSDate     = regexp.MustCompile(`\b(\d{1,2})[-/.](\d{1,2})[-/.](20\d{2})\b`)
+	receiptTotalLine  = regexp.MustCompile(`(?i)\b(grand\s+total|total|amount\s+due|balance\s+due|montant|amount)\b`)
+	receiptNotTotal   = regexp.MustCompile(`(?i)\b(sub\s*-?\s*total|tax|tip|gratuity|change|tendered|savings|discount|gst|hst|pst|qst|tps|tvq)\b`)
+	receiptNotVendor  = regexp.MustCompile(`(?i)\b(receipt|invoice|welcome|tel|phone|date|time|order|table|server|cashier|www\.|http)\b`)
+)
+
+// receiptCurrencies are the currency markers recognized on a receipt
+var receiptCurrencies = []struct {
+	marker   string
+	currency string
+}{
+	{"CAD", "CAD"}, {"C$", "CAD"}, {"USD", "USD"}, {"US$", "USD"}, {"EUR", "EUR"}, {"€", "EUR"}, {"GBP", "GBP"}, {"£", "GBP"},
+}
+
+// parseReceiptText finds the vendor, total, currency and date in a receipt's text. The
+// total is the amount on the last "total" line that isn't a subtotal or tax, or the largest
+// amount on the receipt when no line is labeled.
+func parseReceiptText(text string) *ReceiptScan {
+	scan := &ReceiptScan{Text: strings.TrimSpace(text)}
+	lines := strings.Split(scan.Text, "\n")
+
+	largest := 0.0
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if scan.Vendor == "" && isVendorLine(line) {
+			scan.Vendor = line
+		}
+		amounts := receiptAmounts(line)
+		for _, amount := range amounts {
+			largest = max(largest, amount)
+		}
+		if len(amounts) > 0 && receiptTotalLine.MatchString(line) && !receiptNotTotal.MatchString(line) {
+			scan.Amount = amounts[len(amounts)-1]
+		}
+		if scan.Date == "" {
+			scan.Date = receiptDate(line)
+		}
+	}
+	if scan.Amount == 0 {
+		scan.Amount = largest
+	}
+
+	upper := strings.ToUpper(scan.Text)
+	for _, c := range receiptCurrencies {
+		if strings.Contains(upper, c.marker) {
+			scan.Currency = c.currency
+			break
+		}
+	}
+	return scan
+}
+
+// isVendorLine reports whether a receipt line looks like the business name printed at the top
+func isVendorLine(line string) bool {
+	letters := 0
+	for _, r := range line {
+		if unicode.IsLetter(r) {
+			letters++
+		}
+	}
+	return letters >= 3 && letters*2 >= len([]rune(line)) && len(receiptAmounts(line)) == 0 && !receiptNotVendor.MatchString(line)
+}
+
+// receiptAmounts returns the money amounts on a receipt line, in order
+func receiptAmounts(line string) []float64 {
+	var amounts []float64
+	for _, match := range receiptAmountExpr.FindAllStringSubmatch(line, -1) {
+		whole := strings.ReplaceAll(match[1], ",", "")
+		if amount, err := strconv.ParseFloat(whole+"."+match[2], 64); err == nil {
+			amounts = append(amounts, amount)
+		}
+	}
+	return amounts
+}
+
+// receiptDate finds a date on a receipt line as YYYY-MM-DD, reading 03/04/2026 month first
+func receiptDate(line string) string {
+	var year, month, day string
+	if m := receiptISODate.FindStringSubmatch(line); m != nil {
+		year, month, day = m[1], m[2], m[3]
+	} else if m := receiptUSDate.FindStringSubmatch(line); m != nil {
+		year, month, day = m[3], m[1], m[2]
+	} else {
+		return ""
+	}
+	date, err := time.Parse("2006-1-2", year+"-"+month+"-"+day)
+	if err != nil {
+		return ""
+	}
+	return date.Format("2006-01-02")
+}
+
+// GoogleVisionClient is an OCRProvider backed by Cloud Vision document text detection
+type GoogleVisionClient struct {
+	APIKey     string
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+func (c *GoogleVisionClient) Name() string {
+	return OCRProviderGoogle
+}
+
+func (c *GoogleVisionClient) RecognizeText(ctx context.Context, image []byte) (string, error) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"requests": []map[string]interface{}{{
+			"image":    map[string]string{"content": base64.StdEncoding.EncodeToString(image)},
+			"features": []map[string]string{{"type": "DOCUMENT_TEXT_DETECTION"}},
+		}},
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/images:annotate?key="+c.APIKey, bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("failed to create Vision request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch from Vision: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Vision API returned status: %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Responses []struct {
+			FullTextAnnotation struct {
+				Text string `json:"text"`
+			} `json:"fullTextAnnotation"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		} `json:"responses"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode Vision response: %w", err)
+	}
+	if len(result.Responses) == 0 {
+		return "", ErrNoTextRecognized
+	}
+	if result.Responses[0].Error != nil {
+		return "", fmt.Errorf("Vision API error: %s", result.Responses[0].Error.Message)
+	}
+	return result.Responses[0].FullTextAnnotation.Text, nil
+}
+
+// mockOCRProvider reads every image as the same restaurant receipt
+type mockOCRProvider struct{}
+
+func (p *mockOCRProvider) Name() string {
+	return "mock"
+}
+
+func (p *mockOCRProvider) RecognizeText(ctx context.Context, image []byte) (string, error) {
+	return "Red Fish Blue Fish\n1006 Wharf St, Victoria BC\n" + time.Now().Format("2006-01-02") +
+		"\nFish & Chips 2pc   18.50\nChowder            9.75\nSubtotal          28.25\nGST 5%             1.41\nTotal CAD         29.66\n", nil
+}
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joshndala/cantrip/utils"
+)
+
+// tripProfileCollection stores one anonymized profile per trip
+const tripProfileCollection = "trip_profiles"
+
+// Trip ratings run from 1 (disliked) to 5 (loved); 3 is neutral
+const (
+	MinTripRating = 1
+	MaxTripRating = 5
+)
+
+// Ratings inferred from a trip recap when the traveler didn't rate an activity
+const (
+	completedActivityRating = 4
+	skippedActivityRating   = 2
+)
+
+// Similarity between a traveler and a stored profile from the same city
+const (
+	baseProfileSimilarity   = 0.1  // any traveler to the city counts a little
+	seasonProfileSimilarity = 0.25 // added when both trips are in the same season
+)
+
+// DefaultRecommendationLimit is how many recommendations are returned unless asked otherwise
+const DefaultRecommendationLimit = 10
+
+// itineraryRecommendations is how many recommendations are passed to the agent
+const itineraryRecommendations = 5
+
+// ErrInvalidTripRating is returned for a rating outside 1-5 or of an activity that isn't
+// in the trip
+var ErrInvalidTripRating = errors.New("invalid trip rating")
+
+// TripProfile is what a trip contributes to recommendations. It keeps no user or trip ID:
+// ID is a salted hash of the trip ID, so the trip's own updates replace it.
+type TripProfile struct {
+	ID        string             `json:"id"`
+	City      string             `json:"city"` // normalized
+	Interests []string           `json:"interests,omitempty"`
+	Season    string             `json:"season,omitempty"`
+	Ratings   map[string]float64 `json:"ratings,omitempty"`   // activity -> rating the traveler gave
+	Completed []string           `json:"completed,omitempty"` // from the trip recap
+	Skipped   []string           `json:"skipped,omitempty"`
+	UpdatedAt time.Time          `json:"updated_at"`
+}
+
+// RecommendationQuery describes the traveler recommendations are for
+type RecommendationQuery struct {
+	City      string
+	Interests []string
+	Season    string   // the current season when empty
+	Exclude   []string // activities the traveler already has
+	TripID    string   // leaves the trip's own profile out
+	Limit     int
+}
+
+// Recommendation is an attraction or event that similar travelers enjoyed
+type Recommendation struct {
+	Name          string  `json:"name"`
+	Score         float64 `json:"score"`
+	Travelers     int     `json:"travelers"` // profiles that rated it
+	AverageRating float64 `json:"average_rating"`
+}
+
+// tripProfileID derives a trip's anonymous profile ID
+func tripProfileID(tripID string) string {
+	return AnonymousID("trip:" + tripID)
+}
+
+// RecordTripProfile stores or refreshes the profile of a newly planned trip, keeping any
+// ratings it already has
+func RecordTripProfile(ctx context.Context, itinerary *ItineraryResponse, interests []string) error {
+	startDate, _ := itinerary.Itinerary["start_date"].(string)
+	return updateTripProfile(ctx, itinerary, func(profile *TripProfile) {
+		profile.Interests = normalizeInterests(interests)
+		if start, err := time.Parse("2006-01-02", startDate); err == nil {
+			profile.Season = getSeasonForDate(start)
+		}
+	})
+}
+
+// RateTripActivities records the traveler's ratings of activities in the trip's plan,
+// replacing earlier ratings of the same activities
+func RateTripActivities(ctx context.Context, itinerary *ItineraryResponse, ratings map[string]float64) (*TripProfile, error) {
+	planned := map[string]string{}
+	days, _ := itinerary.Itinerary["days"].([]interface{})
+	for _, day := range days {
+		for _, name := range plannedActivityNames(day) {
+			planned[strings.ToLower(name)] = name
+		}
+	}
+
+	normalized := make(map[string]float64, len(ratings))
+	for activity, rating := range ratings {
+		name, ok := planned[strings.ToLower(strings.TrimSpace(activity))]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q isn't in the trip", ErrInvalidTripRating, activity)
+		}
+		if rating < MinTripRating || rating > MaxTripRating {
+			return nil, fmt.Errorf("%w: ratings run from %d to %d", ErrInvalidTripRating, MinTripRating, MaxTripRating)
+		}
+		normalized[name] = rating
+	}
+
+	var saved *TripProfile
+	err := updateTripProfile(ctx, itinerary, func(profile *TripProfile) {
+		if profile.Ratings == nil {
+			profile.Ratings = map[string]float64{}
+		}
+		for name, rating := range normalized {
+			profile.Ratings[name] = rating
+		}
+		saved = profile
+	})
+	if err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+// recordTripOutcome adds a recap's completed and skipped activities to the trip's profile
+func recordTripOutcome(ctx context.Context, itinerary *ItineraryResponse, summary *TripSummary) error {
+	return updateTripProfile(ctx, itinerary, func(profile *TripProfile) {
+		profile.Completed, profile.Skipped = nil, nil
+		for _, day := range summary.Days {
+			profile.Completed = append(profile.Completed, day.Completed...)
+			profile.Skipped = append(profile.Skipped, day.Skipped...)
+		}
+	})
+}
+
+// updateTripProfile loads a trip's profile, applies change and saves it
+func updateTripProfile(ctx context.Context, itinerary *ItineraryResponse, change func(profile *TripProfile)) error {
+	id := tripProfileID(itinerary.ID)
+	unlock := lockDocument(tripProfileCollection, id)
+	defer unlock()
+
+	var profile TripProfile
+	if err := loadDocument(ctx, tripProfileCollection, id, &profile); err != nil && !errors.Is(err, ErrDocumentNotFound) {
+		return err
+	}
+	profile.ID = id
+	profile.City = NormalizeCityName(stringField(itinerary.Itinerary, "city", itinerary.Metadata.City))
+	change(&profile)
+	profile.UpdatedAt = time.Now().UTC()
+
+	if err := saveDocument(ctx, tripProfileCollection, id, profile); err != nil {
+		return fmt.Errorf("failed to save trip profile: %w", err)
+	}
+	return nil
+}
+
+// RecommendActivities suggests what travelers like the one described enjoyed in their city.
+// Each stored profile is weighted by how much its interests overlap the traveler's and
+// whether it's from the same season; an activity scores by how far its ratings sit above
+// neutral, so only activities similar travelers liked are recommended.
+func RecommendActivities(ctx context.Context, query RecommendationQuery) ([]Recommendation, error) {
+	city := NormalizeCityName(query.City)
+	season := query.Season
+	if season == "" {
+		season = getSeasonForDate(time.Now())
+	}
+	interests := normalizeInterests(query.Interests)
+	excluded := map[string]bool{}
+	for _, name := range query.Exclude {
+		excluded[strings.ToLower(name)] = true
+	}
+	ownProfile := ""
+	if query.TripID != "" {
+		ownProfile = tripProfileID(query.TripID)
+	}
+
+	ids, err := listDocumentIDs(ctx, tripProfileCollection)
+	if err != nil {
+		return nil, err
+	}
+
+	type tally struct {
+		name      string
+		score     float64
+		ratingSum float64
+		travelers int
+	}
+	tallies := map[string]*tally{}
+
+	for _, id := range ids {
+		if id == ownProfile {
+			continue
+		}
+		var profile TripProfile
+		if err := loadDocument(ctx, tripProfileCollection, id, &profile); err != nil {
+			utils.LogError("Failed to load trip profile", err)
+			continue
+		}
+		if profile.City != city {
+			continue
+		}
+
+		similarity := baseProfileSimilarity + interestOverlap(interests, profile.Interests)
+		if profile.Season == season {
+			similarity += seasonProfileSimilarity
+		}
+
+		for name, rating := range profileRatings(profile) {
+			key := strings.ToLower(name)
+			if excluded[key] {
+				continue
+			}
+			t := tallies[key]
+			if t == nil {
+				t = &tally{name: name}
+				tallies[key] = t
+			}
+			t.score += similarity * (rating - 3)
+			t.ratingSum += rating
+			t.travelers++
+		}
+	}
+
+	recommendations := []Recommendation{}
+	for _, t := range tallies {
+		if t.score <= 0 {
+			continue
+		}
+		recommendations = append(recommendations, Recommendation{
+			Name:          t.name,
+			Score:         math.Round(t.score*100) / 100,
+			Travelers:     t.travelers,
+			AverageRating: math.Round(t.ratingSum/float64(t.travelers)*10) / 10,
+		})
+	}
+	sort.Slice(recommendations, func(i, j int) bool {
+		if recommendations[i].Score != recommendations[j].Score {
+			return recommendations[i].Score > recommendations[j].Score
+		}
+		return recommendations[i].Name < recommendations[j].Name
+	})
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultRecommendationLimit
+	}
+	if len(recommendations) > limit {
+		recommendations = recommendations[:limit]
+	}
+	return recommendations, nil
+}
+
+// recommendedForItinerary names the activities similar travelers enjoyed, for the agent to
+// work into a new itinerary
+func recommendedForItinerary(ctx context.Context, req AIItineraryRequest) []string {
+	query := RecommendationQuery{City: req.City, Interests: req.Interests, Limit: itineraryRecommendations}
+	if start, err := time.Parse("2006-01-02", req.StartDate); err == nil {
+		query.Season = getSeasonForDate(start)
+	}
+	recommendations, err := RecommendActivities(ctx, query)
+	if err != nil {
+		utils.LogError("Failed to load recommendations for itinerary", err)
+		return nil
+	}
+
+	names := make([]string, 0, len(recommendations))
+	for _, recommendation := range recommendations {
+		names = append(names, recommendation.Name)
+	}
+	return names
+}
+
+// profileRatings combines a profile's ratings with those inferred from its recap
+func profileRatings(profile TripProfile) map[string]float64 {
+	ratings := map[string]float64{}
+	for _, name := range profile.Skipped {
+		ratings[name] = skippedActivityRating
+	}
+	for _, name := range profile.Completed {
+		ratings[name] = completedActivityRating
+	}
+	for name, rating := range profile.Ratings {
+		ratings[name] = rating
+	}
+	return ratings
+}
+
+// interestOverlap is the Jaccard similarity of two normalized interest lists
+func interestOverlap(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	set := map[string]bool{}
+	for _, interest := range a {
+		set[interest] = true
+	}
+	shared := 0
+	union := len(set)
+	for _, interest := range b {
+		if set[interest] {
+			shared++
+		} else {
+			union++
+		}
+	}
+	return float64(shared) / float64(union)
+}
+
+// normalizeInterests lowercases, trims and deduplicates interests
+func normalizeInterests(interests []string) []string {
+	var normalized []string
+	seen := map[string]bool{}
+	for _, interest := range interests {
+		interest = strings.ToLower(strings.TrimSpace(interest))
+		if interest != "" && !seen[interest] {
+			seen[interest] = true
+			normalized = append(normalized, interest)
+		}
+	}
+	return normalized
+}
@@ -0,0 +1,242 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/joshndala/cantrip/utils"
+)
+
+// Render pool sizing, overridden by RENDER_WORKERS and RENDER_QUEUE_SIZE
+const (
+	DefaultRenderWorkers   = 4
+	DefaultRenderQueueSize = 32
+)
+
+// ErrRenderQueueFull is returned when PDF and bundle generation is saturated
+var ErrRenderQueueFull = errors.New("render queue is full")
+
+// RenderQueueFullError tells the client how long to wait before retrying
+type RenderQueueFullError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RenderQueueFullError) Error() string {
+	return fmt.Sprintf("render queue is full, retry in %s", e.RetryAfter)
+}
+
+// Unwrap lets callers match the error with errors.Is(err, ErrRenderQueueFull)
+func (e *RenderQueueFullError) Unwrap() error {
+	return ErrRenderQueueFull
+}
+
+// RenderPoolStats is a snapshot of the pool's load
+type RenderPoolStats struct {
+	Workers    int            `json:"workers"`
+	QueueSize  int            `json:"queue_size"`
+	Queued     int            `json:"queued"`
+	Running    int            `json:"running"`
+	Completed  int64          `json:"completed"`
+	Failed     int64          `json:"failed"`
+	Rejected   int64          `json:"rejected"`  // turned away with a full queue
+	Abandoned  int64          `json:"abandoned"` // callers that gave up while queued
+	AvgWaitMs  float64        `json:"avg_wait_ms"`
+	AvgRunMs   float64        `json:"avg_run_ms"`
+	ByKind     map[string]int `json:"by_kind"` // jobs completed per kind
+	RetryAfter int            `json:"retry_after_seconds"`
+}
+
+// RenderPool runs heavy document generation on a fixed set of workers behind a bounded
+// queue, so a burst of requests waits its turn instead of rendering all at once, and is
+// turned away once the queue is full
+type RenderPool struct {
+	workers int
+	queue   chan *renderJob
+	running int32
+
+	mu        sync.Mutex
+	completed int64
+	failed    int64
+	rejected  int64
+	abandoned int64
+	waitTotal time.Duration
+	runTotal  time.Duration
+	byKind    map[string]int
+}
+
+// renderJob is one queued piece of work. state moves from queued to running or abandoned
+// exactly once, so a worker never starts a job its caller has given up on.
+type renderJob struct {
+	ctx      context.Context
+	kind     string
+	run      func(ctx context.Context) error
+	queuedAt time.Time
+	state    int32
+	done     chan error
+}
+
+const (
+	renderQueued int32 = iota
+	renderRunning
+	renderAbandoned
+)
+
+var (
+	renderPoolOnce sync.Once
+	renderPool     *RenderPool
+)
+
+// GetRenderPool returns the shared pool for PDF and bundle generation
+func GetRenderPool() *RenderPool {
+	renderPoolOnce.Do(func() {
+		renderPool = NewRenderPool(envInt("RENDER_WORKERS", DefaultRenderWorkers), envInt("RENDER_QUEUE_SIZE", DefaultRenderQueueSize))
+	})
+	return renderPool
+}
+
+// envInt reads a positive integer from the environment
+func envInt(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		utils.LogWarning(fmt.Sprintf("Invalid %s %q, using %d", name, value, fallback))
+		return fallback
+	}
+	return n
+}
+
+// NewRenderPool starts workers that take jobs from a queue of queueSize
+func NewRenderPool(workers, queueSize int) *RenderPool {
+	pool := &RenderPool{
+		workers: workers,
+		queue:   make(chan *renderJob, queueSize),
+		byKind:  map[string]int{},
+	}
+	for i := 0; i < workers; i++ {
+		go pool.work()
+	}
+	return pool
+}
+
+// Do queues a job and waits for it. It fails fast with a *RenderQueueFullError when the
+// queue is full, and returns the context's error if ctx ends while the job is still queued.
+// A job that has started is waited for, so it may write to the caller's response; it
+// receives ctx to stop early.
+func (p *RenderPool) Do(ctx context.Context, kind string, run func(ctx context.Context) error) error {
+	job := &renderJob{ctx: ctx, kind: kind, run: run, queuedAt: time.Now(), done: make(chan error, 1)}
+	select {
+	case p.queue <- job:
+	default:
+		p.mu.Lock()
+		p.rejected++
+		p.mu.Unlock()
+		return &RenderQueueFullError{RetryAfter: p.retryAfter()}
+	}
+
+	select {
+	case err := <-job.done:
+		return err
+	case <-ctx.Done():
+		if atomic.CompareAndSwapInt32(&job.state, renderQueued, renderAbandoned) {
+			p.mu.Lock()
+			p.abandoned++
+			p.mu.Unlock()
+			return ctx.Err()
+		}
+		return <-job.done
+	}
+}
+
+func (p *RenderPool) work() {
+	for job := range p.queue {
+		if !atomic.CompareAndSwapInt32(&job.state, renderQueued, renderRunning) {
+			continue
+		}
+		atomic.AddInt32(&p.running, 1)
+		started := time.Now()
+		err := runRenderJob(job)
+		atomic.AddInt32(&p.running, -1)
+
+		p.mu.Lock()
+		p.waitTotal += started.Sub(job.queuedAt)
+		p.runTotal += time.Since(started)
+		if err != nil {
+			p.failed++
+		} else {
+			p.completed++
+			p.byKind[job.kind]++
+		}
+		p.mu.Unlock()
+		job.done <- err
+	}
+}
+
+// runRenderJob runs a job, turning a panic into an error so one bad document doesn't take
+// the server down with it
+func runRenderJob(job *renderJob) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			utils.LogError("Render job "+job.kind+" panicked", fmt.Errorf("%v\n%s", recovered, debug.Stack()))
+			err = fmt.Errorf("%s generation failed: %v", job.kind, recovered)
+		}
+	}()
+	return job.run(job.ctx)
+}
+
+// retryAfter estimates when a queue slot frees up from the average job time, at least a second
+func (p *RenderPool) retryAfter() time.Duration {
+	p.mu.Lock()
+	jobs := p.completed + p.failed
+	var avg time.Duration
+	if jobs > 0 {
+		avg = p.runTotal / time.Duration(jobs)
+	}
+	p.mu.Unlock()
+
+	// Every queued job has to start before a new one fits
+	wait := time.Duration(math.Ceil(float64(len(p.queue))/float64(p.workers))) * avg
+	seconds := math.Ceil(wait.Seconds())
+	if seconds < 1 {
+		seconds = 1
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// Stats reports the pool's current load and totals since startup
+func (p *RenderPool) Stats() RenderPoolStats {
+	retryAfter := p.retryAfter()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := RenderPoolStats{
+		Workers:    p.workers,
+		QueueSize:  cap(p.queue),
+		Queued:     len(p.queue),
+		Running:    int(atomic.LoadInt32(&p.running)),
+		Completed:  p.completed,
+		Failed:     p.failed,
+		Rejected:   p.rejected,
+		Abandoned:  p.abandoned,
+		ByKind:     make(map[string]int, len(p.byKind)),
+		RetryAfter: int(retryAfter.Seconds()),
+	}
+	for kind, count := range p.byKind {
+		stats.ByKind[kind] = count
+	}
+	if jobs := p.completed + p.failed; jobs > 0 {
+		stats.AvgWaitMs = float64(p.waitTotal.Milliseconds()) / float64(jobs)
+		stats.AvgRunMs = float64(p.runTotal.Milliseconds()) / float64(jobs)
+	}
+	return stats
+}
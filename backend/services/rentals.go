@@ -0,0 +1,352 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joshndala/cantrip/models"
+)
+
+// RoadTripCategory is the packing list category for a rental car
+const RoadTripCategory = "Road Trip"
+
+// Car rental errors
+var (
+	ErrInvalidRentalQuery = errors.New("invalid rental search")
+	ErrRentalNotFound     = errors.New("rental offer not found")
+)
+
+// CarRentalData represents the structure of car_rentals.json
+type CarRentalData struct {
+	Currency           string             `json:"currency"`
+	Note               string             `json:"note"`
+	DailyKm            int                `json:"daily_km"` // assumed driving per day for fuel estimates
+	Companies          []string           `json:"companies"`
+	Classes            []VehicleClass     `json:"classes"`
+	CompanyAdjustments map[string]float64 `json:"company_adjustments"`
+	CityMultipliers    map[string]float64 `json:"city_multipliers"`
+	FuelPrices         map[string]float64 `json:"fuel_prices"` // per litre, by province
+	DefaultFuelPrice   float64            `json:"default_fuel_price"`
+	RoofBox            RoofBoxOption      `json:"roof_box"`
+}
+
+// VehicleClass is a rental car category
+type VehicleClass = models.VehicleClass
+
+// RoofBoxOption is the price and extra capacity of a roof box
+type RoofBoxOption struct {
+	DailyRate float64 `json:"daily_rate"`
+	Suitcases int     `json:"suitcases"`
+}
+
+// RentalQuery searches for rental cars in a city between two dates (YYYY-MM-DD)
+type RentalQuery struct {
+	City      string `json:"city"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+	Seats     int    `json:"seats,omitempty"` // minimum seats
+}
+
+// RentalOffer is a vehicle class offered by one company for the trip
+type RentalOffer = models.RentalOffer
+
+// RentalSearchResult lists offers cheapest first
+type RentalSearchResult struct {
+	Query  RentalQuery   `json:"query"`
+	Offers []RentalOffer `json:"offers"`
+	Note   string        `json:"note,omitempty"`
+}
+
+// RentalSelection is the rental car chosen for a trip, with its estimated fuel cost
+type RentalSelection = models.RentalSelection
+
+// RentalSelectionRequest picks an offer for an itinerary
+type RentalSelectionRequest struct {
+	OfferID string `json:"offer_id"`
+	RoofBox bool   `json:"roof_box,omitempty"`
+	DailyKm int    `json:"daily_km,omitempty"` // from the dataset when unset
+}
+
+// PackingVehicle is the rental car a packing list is for
+type PackingVehicle = models.PackingVehicle
+
+// RoadTripRules represents the road_trip section of packing_rules.json
+type RoadTripRules struct {
+	Items        []string `json:"items"`
+	WinterMonths []int    `json:"winter_months"`
+	WinterItems  []string `json:"winter_items"`
+	RoofBoxItems []string `json:"roof_box_items"`
+	Bulky        []string `json:"bulky"` // items that take a suitcase's worth of space
+}
+
+// SampleRentalProvider prices rentals from the car rental dataset, standing in for an
+// aggregator until a live provider is configured
+type SampleRentalProvider struct{}
+
+// Name identifies the provider on offers
+func (p *SampleRentalProvider) Name() string {
+	return "sample"
+}
+
+// SearchRentals prices every vehicle class at every company for the trip
+func (p *SampleRentalProvider) SearchRentals(ctx context.Context, query RentalQuery) ([]RentalOffer, error) {
+	data, err := loadCarRentalData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	days, err := rentalDays(query.StartDate, query.EndDate)
+	if err != nil {
+		return nil, err
+	}
+
+	multiplier := 1.0
+	for city, value := range data.CityMultipliers {
+		if strings.EqualFold(city, query.City) {
+			multiplier = value
+		}
+	}
+
+	offers := []RentalOffer{}
+	for _, company := range data.Companies {
+		adjustment := data.CompanyAdjustments[company]
+		if adjustment == 0 {
+			adjustment = 1
+		}
+		for _, class := range data.Classes {
+			daily := roundCost(class.DailyRate * multiplier * adjustment)
+			offers = append(offers, RentalOffer{
+				ID:        rentalOfferID(company, class.ID),
+				Provider:  p.Name(),
+				Company:   company,
+				Vehicle:   class,
+				Days:      days,
+				DailyRate: daily,
+				Total:     roundCost(daily * float64(days)),
+				Currency:  data.Currency,
+			})
+		}
+	}
+	return offers, nil
+}
+
+// loadCarRentalData loads the car rental dataset
+func loadCarRentalData(ctx context.Context) (*CarRentalData, error) {
+	data, err := ReadDataset(ctx, CarRentalsDataset)
+	if err != nil {
+		return nil, err
+	}
+
+	var rentals CarRentalData
+	if err := json.Unmarshal(data, &rentals); err != nil {
+		return nil, err
+	}
+	return &rentals, nil
+}
+
+// SearchRentals returns the rental provider's offers for the trip, cheapest first
+func SearchRentals(ctx context.Context, query RentalQuery) (*RentalSearchResult, error) {
+	if strings.TrimSpace(query.City) == "" {
+		return nil, fmt.Errorf("%w: city is required", ErrInvalidRentalQuery)
+	}
+	if _, err := rentalDays(query.StartDate, query.EndDate); err != nil {
+		return nil, err
+	}
+	start, _ := models.ParseDate(query.StartDate)
+	end, _ := models.ParseDate(query.EndDate)
+	if err := CheckTripDates(ctx, query.City, start, end, ""); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRentalQuery, err)
+	}
+
+	offers, err := GetRentalProvider().SearchRentals(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search rentals: %w", err)
+	}
+
+	result := &RentalSearchResult{Query: query, Offers: []RentalOffer{}}
+	for _, offer := range offers {
+		if query.Seats > 0 && offer.Vehicle.Seats < query.Seats {
+			continue
+		}
+		result.Offers = append(result.Offers, offer)
+	}
+	sort.SliceStable(result.Offers, func(i, j int) bool {
+		return result.Offers[i].Total < result.Offers[j].Total
+	})
+	if data, err := loadCarRentalData(ctx); err == nil {
+		result.Note = data.Note
+	}
+	return result, nil
+}
+
+// SelectRental prices an offer for an itinerary and stores it on a copy of the itinerary,
+// which is then repriced with the rental and fuel in place of transit passes. The trip is
+// marked as a rental-car trip so the driving rules are included.
+func SelectRental(ctx context.Context, original *ItineraryResponse, req RentalSelectionRequest) (*ItineraryResponse, error) {
+	city, start, end, ok := itineraryTripDates(original)
+	if !ok {
+		return nil, fmt.Errorf("%w: the itinerary has no city or dates", ErrInvalidRentalQuery)
+	}
+	startDate, endDate := start.Format("2006-01-02"), end.Format("2006-01-02")
+
+	offers, err := GetRentalProvider().SearchRentals(ctx, RentalQuery{City: city, StartDate: startDate, EndDate: endDate})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search rentals: %w", err)
+	}
+	var offer *RentalOffer
+	for i := range offers {
+		if offers[i].ID == req.OfferID {
+			offer = &offers[i]
+			break
+		}
+	}
+	if offer == nil {
+		return nil, fmt.Errorf("%s: %w", req.OfferID, ErrRentalNotFound)
+	}
+	if req.RoofBox && !offer.Vehicle.RoofBox {
+		return nil, fmt.Errorf("%w: a roof box can't be added to the %s", ErrInvalidRentalQuery, offer.Vehicle.Name)
+	}
+	if req.DailyKm < 0 {
+		return nil, fmt.Errorf("%w: daily_km can't be negative", ErrInvalidRentalQuery)
+	}
+
+	data, err := loadCarRentalData(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load car rental data: %w", err)
+	}
+
+	selection := &RentalSelection{
+		Offer:      *offer,
+		RoofBox:    req.RoofBox,
+		DailyKm:    req.DailyKm,
+		FuelPrice:  data.DefaultFuelPrice,
+		RentalCost: offer.Total,
+		Currency:   offer.Currency,
+		SelectedAt: time.Now().UTC(),
+	}
+	if selection.DailyKm == 0 {
+		selection.DailyKm = data.DailyKm
+	}
+	if metadata, err := loadCityMetadata(ctx); err == nil {
+		if found, err := findCity(metadata, city); err == nil {
+			if price, ok := data.FuelPrices[found.Province]; ok {
+				selection.FuelPrice = price
+			}
+		}
+	}
+	if req.RoofBox {
+		selection.RentalCost += data.RoofBox.DailyRate * float64(offer.Days)
+	}
+	selection.FuelLiters = math.Round(float64(selection.DailyKm*offer.Days)*offer.Vehicle.FuelLPer100Km/100*10) / 10
+	selection.FuelCost = roundCost(selection.FuelLiters * selection.FuelPrice)
+	selection.RentalCost = roundCost(selection.RentalCost)
+	selection.Total = roundCost(selection.RentalCost + selection.FuelCost)
+
+	var itinerary *ItineraryResponse
+	if err := remarshal(original, &itinerary); err != nil {
+		return nil, fmt.Errorf("failed to copy itinerary: %w", err)
+	}
+	itinerary.Rental = selection
+	itinerary.Itinerary["transport"] = TransportRental
+	if advisory, err := GetDrivingAdvisory(ctx, city, startDate, endDate); err == nil {
+		itinerary.Driving = advisory
+	}
+
+	repriced, _, err := RecalculateItineraryCosts(ctx, itinerary, CostRecalculation{})
+	if err != nil {
+		return nil, err
+	}
+	return repriced, nil
+}
+
+// roadTripPackingCategory lists the car items for a rental, and notes whether the group's
+// luggage fits the vehicle. Call after the multipliers so bulky gear is fully counted.
+func roadTripPackingCategory(ctx context.Context, rules *PackingRules, vehicle *PackingVehicle, startDate, endDate string, travelers int, categories []PackingCategory) (PackingCategory, []string) {
+	category := PackingCategory{Name: RoadTripCategory, Items: []PackingItem{}}
+	if vehicle == nil || rules.RoadTrip == nil {
+		return category, nil
+	}
+	roadTrip := rules.RoadTrip
+
+	for _, name := range roadTrip.Items {
+		category.Items = append(category.Items, PackingItem{Name: name, Quantity: 1, Reason: "Rental car"})
+	}
+	if overlapsMonths(tripMonths(startDate, endDate), roadTrip.WinterMonths) {
+		for _, name := range roadTrip.WinterItems {
+			category.Items = append(category.Items, PackingItem{Name: name, Quantity: 1, Reason: "Winter driving"})
+		}
+	}
+	if vehicle.RoofBox {
+		for _, name := range roadTrip.RoofBoxItems {
+			category.Items = append(category.Items, PackingItem{Name: name, Quantity: 1, Reason: "Packing the roof box"})
+		}
+	}
+
+	data, err := loadCarRentalData(ctx)
+	if err != nil {
+		return category, nil
+	}
+	var class *VehicleClass
+	for i := range data.Classes {
+		if strings.EqualFold(data.Classes[i].ID, vehicle.Class) {
+			class = &data.Classes[i]
+		}
+	}
+	if class == nil {
+		return category, []string{fmt.Sprintf("Unknown vehicle class %q; luggage space wasn't checked", vehicle.Class)}
+	}
+
+	// One suitcase per traveler, plus a suitcase's worth for each bulky item
+	bags := max(travelers, 1)
+	for _, existing := range categories {
+		for _, item := range existing.Items {
+			if itemMatches(item.Name, roadTrip.Bulky) {
+				bags += item.Quantity
+			}
+		}
+	}
+	capacity := class.Suitcases
+	if vehicle.RoofBox && class.RoofBox {
+		capacity += data.RoofBox.Suitcases
+	}
+
+	var notes []string
+	switch {
+	case travelers > class.Seats:
+		notes = append(notes, fmt.Sprintf("The %s seats %d; %d travelers need a larger vehicle", class.Name, class.Seats, travelers))
+	case bags <= capacity:
+		notes = append(notes, fmt.Sprintf("About %d bags fit the %s's space for %d", bags, class.Name, capacity))
+	case class.RoofBox && !vehicle.RoofBox:
+		notes = append(notes, fmt.Sprintf("About %d bags won't fit the %s's space for %d; add a roof box for %d more", bags, class.Name, capacity, data.RoofBox.Suitcases))
+	default:
+		notes = append(notes, fmt.Sprintf("About %d bags won't fit the %s's space for %d; pack soft bags or choose a larger vehicle", bags, class.Name, capacity))
+	}
+	return dedupePackingCategory(category), notes
+}
+
+// rentalDays counts rental days, with same-day returns charged as one day
+func rentalDays(startDate, endDate string) (int, error) {
+	start, err := time.Parse("2006-01-02", startDate)
+	if err != nil {
+		return 0, fmt.Errorf("%w: start must be a YYYY-MM-DD date", ErrInvalidRentalQuery)
+	}
+	end, err := time.Parse("2006-01-02", endDate)
+	if err != nil {
+		return 0, fmt.Errorf("%w: end must be a YYYY-MM-DD date", ErrInvalidRentalQuery)
+	}
+	if end.Before(start) {
+		return 0, fmt.Errorf("%w: end must not be before start", ErrInvalidRentalQuery)
+	}
+	// Rentals are charged by the night, with same-day returns as one day
+	return max(models.DaysBetween(start, end), 1), nil
+}
+
+// rentalOfferID identifies an offer by company and class, stable across searches
+func rentalOfferID(company, class string) string {
+	return strings.ToLower(strings.ReplaceAll(company, " ", "-")) + "-" + class
+}
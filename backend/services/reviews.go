@@ -0,0 +1,431 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joshndala/cantrip/utils"
+)
+
+// reviewCollection stores attraction reviews, one document per traveler and attraction
+const reviewCollection = "attraction_reviews"
+
+// DefaultRating is the rating of an attraction or event nobody has reviewed yet. Reviews
+// pull an attraction's rating away from it as they accumulate.
+const DefaultRating = 4.0
+
+// reviewPriorWeight is how many reviews' worth of weight the default rating carries, so
+// one enthusiastic review doesn't make an attraction the city's best
+const reviewPriorWeight = 5
+
+// Review statuses
+const (
+	ReviewPublished = "published"
+	ReviewRemoved   = "removed" // taken down by an admin and left out of ratings
+)
+
+// Review limits and spam checks
+const (
+	MinReviewRating       = 1
+	MaxReviewRating       = 5
+	maxReviewLength       = 2000
+	MaxReviewsPerDay      = 10 // per traveler, across attractions
+	maxRepeatedCharacters = 8  // "!!!!!!!!!" and the like
+	reviewEditInterval    = time.Hour
+)
+
+var (
+	// ErrInvalidReview is returned for a rating outside 1-5 or oversized text
+	ErrInvalidReview = errors.New("invalid review")
+	// ErrReviewRejected is returned for a review that looks like spam or that moderation blocks
+	ErrReviewRejected = errors.New("review was rejected")
+	// ErrTooManyReviews is returned once a traveler has posted MaxReviewsPerDay reviews in a
+	// day, or edits a review again within reviewEditInterval
+	ErrTooManyReviews = errors.New("too many reviews today")
+	// ErrUnknownAttraction is returned for an attraction ID no city lists
+	ErrUnknownAttraction = errors.New("unknown attraction")
+)
+
+var reviewLinkExpr = regexp.MustCompile(`(?i)https?://|www\.|\b[a-z0-9-]+\.(com|net|org|ru|info|biz|xyz)\b`)
+
+// ReviewSubmission is what a traveler sends to review an attraction
+type ReviewSubmission struct {
+	Rating     int    `json:"rating" binding:"required"`
+	Text       string `json:"text"`
+	AuthorName string `json:"author_name"` // how the review is credited, "A Cantrip traveler" when empty
+}
+
+// Review is a traveler's rating of an attraction. Posting again replaces the traveler's
+// earlier review of the same attraction.
+type Review struct {
+	ID            string     `json:"id"`
+	AttractionID  string     `json:"attraction_id"`
+	Attraction    string     `json:"attraction"`
+	City          string     `json:"city"`
+	UserID        string     `json:"user_id,omitempty"` // cleared from public listings
+	AuthorName    string     `json:"author_name"`
+	Rating        int        `json:"rating"`
+	Text          string     `json:"text,omitempty"`
+	Flagged       bool       `json:"flagged,omitempty"` // the moderation rules want a closer look
+	Status        string     `json:"status"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	RemovedAt     *time.Time `json:"removed_at,omitempty"`
+	RemovedBy     string     `json:"removed_by,omitempty"`
+	RemovalReason string     `json:"removal_reason,omitempty"`
+}
+
+// AttractionRating aggregates an attraction's published reviews
+type AttractionRating struct {
+	Rating      float64     `json:"rating"`  // weighted toward DefaultRating while reviews are few
+	Average     float64     `json:"average"` // plain mean of the reviews, zero without any
+	ReviewCount int         `json:"review_count"`
+	Breakdown   map[int]int `json:"breakdown,omitempty"` // stars -> reviews
+}
+
+// cachedRatings is a tenant's aggregated ratings, rebuilt after every review change
+type cachedRatings struct {
+	byAttraction map[string]AttractionRating
+	version      int64
+}
+
+var (
+	reviewsMu    sync.Mutex
+	ratingCaches sync.Map // tenant ID -> *cachedRatings
+)
+
+// AttractionID identifies an attraction by its city and name, e.g. "victoria-butchart-gardens"
+func AttractionID(city, attraction string) string {
+	return guideSlug(city) + "-" + guideSlug(attraction)
+}
+
+// findAttraction resolves an attraction ID to the attraction's name and city
+func findAttraction(ctx context.Context, id string) (string, *City, error) {
+	index, err := GetCityIndex(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	cities := index.Metadata().Cities
+	for i := range cities {
+		for _, attraction := range cities[i].Attractions {
+			if AttractionID(cities[i].Name, attraction) == id {
+				return attraction, &cities[i], nil
+			}
+		}
+	}
+	return "", nil, fmt.Errorf("%w: %s", ErrUnknownAttraction, id)
+}
+
+// reviewID is the document a traveler's review of an attraction is kept in
+func reviewID(attractionID, userID string) string {
+	return AnonymousID("review:" + attractionID + ":" + userID)
+}
+
+// SubmitReview records a traveler's review of an attraction after the spam checks and
+// moderation rules pass it
+func SubmitReview(ctx context.Context, userID, attractionID string, submission ReviewSubmission) (*Review, error) {
+	attraction, city, err := findAttraction(ctx, attractionID)
+	if err != nil {
+		return nil, err
+	}
+
+	text := strings.TrimSpace(submission.Text)
+	author := firstNonEmpty(strings.TrimSpace(submission.AuthorName), defaultTipAuthor)
+	switch {
+	case submission.Rating < MinReviewRating || submission.Rating > MaxReviewRating:
+		return nil, fmt.Errorf("%w: rating must be %d-%d", ErrInvalidReview, MinReviewRating, MaxReviewRating)
+	case len(text) > maxReviewLength:
+		return nil, fmt.Errorf("%w: text must be at most %d characters", ErrInvalidReview, maxReviewLength)
+	case len(author) > maxTipTitleLength:
+		return nil, fmt.Errorf("%w: author_name must be at most %d characters", ErrInvalidReview, maxTipTitleLength)
+	}
+	if reason := firstNonEmpty(reviewSpamReason(text), reviewSpamReason(author)); reason != "" {
+		return nil, fmt.Errorf("%w: %s", ErrReviewRejected, reason)
+	}
+
+	flagged := false
+	if text != "" {
+		verdict := moderate(ctx, text+"\n"+author, ModerationInput)
+		switch verdict.Action {
+		case ModerationBlock:
+			return nil, fmt.Errorf("%w: %s", ErrReviewRejected, strings.Join(verdict.Categories, ", "))
+		case ModerationFlag:
+			flagged = true
+		}
+	}
+
+	reviewsMu.Lock()
+	defer reviewsMu.Unlock()
+
+	reviews, err := listReviews(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id := reviewID(attractionID, userID)
+	dayAgo := time.Now().Add(-24 * time.Hour)
+	today := 0
+	var existing *Review
+	for i, review := range reviews {
+		switch {
+		case review.ID == id:
+			existing = &reviews[i]
+		case review.UserID == userID && review.UpdatedAt.After(dayAgo):
+			today++
+		case text != "" && review.UserID != userID && strings.EqualFold(review.Text, text):
+			return nil, fmt.Errorf("%w: the same text was already posted", ErrReviewRejected)
+		}
+	}
+	if today >= MaxReviewsPerDay {
+		return nil, fmt.Errorf("%w: try again tomorrow", ErrTooManyReviews)
+	}
+
+	now := time.Now().UTC()
+	review := Review{
+		ID:           id,
+		AttractionID: attractionID,
+		Attraction:   attraction,
+		City:         city.Name,
+		UserID:       userID,
+		AuthorName:   author,
+		Rating:       submission.Rating,
+		Text:         text,
+		Flagged:      flagged,
+		Status:       ReviewPublished,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if existing != nil {
+		if existing.Status == ReviewRemoved {
+			return nil, fmt.Errorf("%w: your earlier review of this attraction was taken down", ErrReviewRejected)
+		}
+		if time.Since(existing.UpdatedAt) < reviewEditInterval {
+			return nil, fmt.Errorf("%w: wait before editing your review again", ErrTooManyReviews)
+		}
+		review.CreatedAt = existing.CreatedAt
+	}
+
+	if err := saveDocument(ctx, reviewCollection, review.ID, review); err != nil {
+		return nil, fmt.Errorf("failed to save review: %w", err)
+	}
+	ratingCaches.Delete(TenantFromContext(ctx).ID)
+	return &review, nil
+}
+
+// reviewSpamReason explains why text looks like spam, or is empty when it doesn't
+func reviewSpamReason(text string) string {
+	if reviewLinkExpr.MatchString(text) {
+		return "links aren't allowed in reviews"
+	}
+	run, last := 0, rune(0)
+	for _, r := range text {
+		if r == last && r != ' ' {
+			run++
+			if run >= maxRepeatedCharacters {
+				return "too many repeated characters"
+			}
+		} else {
+			run, last = 1, r
+		}
+	}
+	letters, upper := 0, 0
+	for _, r := range text {
+		if r >= 'A' && r <= 'Z' {
+			upper++
+		}
+		if (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z') {
+			letters++
+		}
+	}
+	if letters >= 20 && upper*10 > letters*8 {
+		return "please don't write in all caps"
+	}
+	return ""
+}
+
+// listReviews loads every review, published or not
+func listReviews(ctx context.Context) ([]Review, error) {
+	ids, err := listDocumentIDs(ctx, reviewCollection)
+	if err != nil {
+		return nil, err
+	}
+	reviews := make([]Review, 0, len(ids))
+	for _, id := range ids {
+		var review Review
+		if err := loadDocument(ctx, reviewCollection, id, &review); err != nil {
+			utils.LogError("Failed to load review", err)
+			continue
+		}
+		reviews = append(reviews, review)
+	}
+	return reviews, nil
+}
+
+// ListAttractionReviews returns an attraction's published reviews, newest first, with its
+// aggregate rating
+func ListAttractionReviews(ctx context.Context, attractionID string) ([]Review, AttractionRating, error) {
+	if _, _, err := findAttraction(ctx, attractionID); err != nil {
+		return nil, AttractionRating{}, err
+	}
+	all, err := listReviews(ctx)
+	if err != nil {
+		return nil, AttractionRating{}, err
+	}
+
+	reviews := []Review{}
+	for _, review := range all {
+		if review.AttractionID == attractionID && review.Status == ReviewPublished {
+			review.UserID = ""
+			reviews = append(reviews, review)
+		}
+	}
+	sort.Slice(reviews, func(i, j int) bool { return reviews[i].UpdatedAt.After(reviews[j].UpdatedAt) })
+	return reviews, aggregateRating(reviews), nil
+}
+
+// RemoveReview takes a review down, leaving it out of listings and ratings
+func RemoveReview(ctx context.Context, id, admin, reason string) (*Review, error) {
+	reviewsMu.Lock()
+	defer reviewsMu.Unlock()
+
+	var review Review
+	if err := loadDocument(ctx, reviewCollection, id, &review); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	review.Status = ReviewRemoved
+	review.RemovedAt = &now
+	review.RemovedBy = admin
+	review.RemovalReason = strings.TrimSpace(reason)
+	if err := saveDocument(ctx, reviewCollection, review.ID, review); err != nil {
+		return nil, fmt.Errorf("failed to save review: %w", err)
+	}
+	ratingCaches.Delete(TenantFromContext(ctx).ID)
+	return &review, nil
+}
+
+// aggregateRating combines reviews into a rating weighted toward DefaultRating
+func aggregateRating(reviews []Review) AttractionRating {
+	rating := AttractionRating{Rating: DefaultRating}
+	if len(reviews) == 0 {
+		return rating
+	}
+	rating.Breakdown = map[int]int{}
+	sum := 0
+	for _, review := range reviews {
+		sum += review.Rating
+		rating.Breakdown[review.Rating]++
+	}
+	rating.ReviewCount = len(reviews)
+	rating.Average = math.Round(float64(sum)/float64(len(reviews))*10) / 10
+	rating.Rating = math.Round((DefaultRating*reviewPriorWeight+float64(sum))/float64(reviewPriorWeight+len(reviews))*10) / 10
+	return rating
+}
+
+// loadRatings returns the tenant's aggregated ratings by attraction ID
+func loadRatings(ctx context.Context) *cachedRatings {
+	tenant := TenantFromContext(ctx).ID
+	if cached, ok := ratingCaches.Load(tenant); ok {
+		return cached.(*cachedRatings)
+	}
+
+	loaded := &cachedRatings{byAttraction: map[string]AttractionRating{}, version: time.Now().UnixNano()}
+	reviews, err := listReviews(ctx)
+	if err != nil {
+		utils.LogError("Failed to load reviews for ratings", err)
+		return loaded
+	}
+	published := map[string][]Review{}
+	for _, review := range reviews {
+		if review.Status == ReviewPublished {
+			published[review.AttractionID] = append(published[review.AttractionID], review)
+		}
+	}
+	for id, attractionReviews := range published {
+		loaded.byAttraction[id] = aggregateRating(attractionReviews)
+	}
+
+	actual, _ := ratingCaches.LoadOrStore(tenant, loaded)
+	return actual.(*cachedRatings)
+}
+
+// GetAttractionRating returns an attraction's aggregate rating, DefaultRating before
+// anyone reviews it
+func GetAttractionRating(ctx context.Context, city, attraction string) AttractionRating {
+	if rating, ok := loadRatings(ctx).byAttraction[AttractionID(city, attraction)]; ok {
+		return rating
+	}
+	return AttractionRating{Rating: DefaultRating}
+}
+
+// ratingsVersion identifies the tenant's current ratings, for the suggestion cache key
+func ratingsVersion(ctx context.Context) int64 {
+	return loadRatings(ctx).version
+}
+
+// rateEvents gives events at a reviewed attraction of the city its rating, then orders
+// the events by rating, keeping the order of ties
+func rateEvents(ctx context.Context, city string, events []Event) []Event {
+	ratings := loadRatings(ctx)
+	if len(ratings.byAttraction) == 0 {
+		return events
+	}
+	index, err := GetCityIndex(ctx)
+	if err != nil {
+		return events
+	}
+	cityData, ok := index.Find(city)
+	if !ok {
+		return events
+	}
+
+	for i := range events {
+		text := strings.ToLower(events[i].Name + " " + events[i].Location)
+		for _, attraction := range cityData.Attractions {
+			if !strings.Contains(text, strings.ToLower(attraction)) {
+				continue
+			}
+			if rating, ok := ratings.byAttraction[AttractionID(cityData.Name, attraction)]; ok {
+				events[i].Rating = rating.Rating
+			}
+			break
+		}
+	}
+	sortEventsByRating(events)
+	return events
+}
+
+// rateSuggestions sets each suggestion's rating to the mean rating of the attractions it
+// visits
+func rateSuggestions(ctx context.Context, run *SuggestionRun) error {
+	if run.City == nil {
+		return nil
+	}
+	ratings := loadRatings(ctx).byAttraction
+	for i := range run.Suggestions {
+		sum, count := 0.0, 0
+		for _, activity := range run.Suggestions[i].Activities {
+			for _, attraction := range run.City.Attractions {
+				if strings.Contains(strings.ToLower(activity), strings.ToLower(attraction)) {
+					rating, ok := ratings[AttractionID(run.City.Name, attraction)]
+					if !ok {
+						rating.Rating = DefaultRating
+					}
+					sum += rating.Rating
+					count++
+					break
+				}
+			}
+		}
+		if count > 0 {
+			run.Suggestions[i].Rating = math.Round(sum/float64(count)*10) / 10
+		}
+	}
+	return nil
+}
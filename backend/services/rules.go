@@ -0,0 +1,322 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/joshndala/cantrip/data"
+	"github.com/joshndala/cantrip/utils"
+)
+
+// RulesCheckInterval is how often rules.json is checked for edits. A changed file is
+// recompiled on the next use; one that no longer compiles is logged and the previous rules
+// stay in effect.
+const RulesCheckInterval = 5 * time.Second
+
+// Cost models in rules.json. Each expression reads budget, duration and city and returns
+// the estimated cost. Cached suggestions are rescaled to a new budget, so a cost should
+// stay proportional to the budget.
+const (
+	CostCultural     = "cultural"
+	CostOutdoor      = "outdoor"
+	CostFood         = "food"
+	CostNeighborhood = "neighborhood"
+	CostSeasonal     = "seasonal"
+	CostBudget       = "budget"
+	CostNightlife    = "nightlife"
+	CostEco          = "eco"
+	CostGeneric      = "generic"
+	CostGenericMood  = "generic_mood"
+)
+
+// Filters in rules.json
+const (
+	// FilterOutdoorWeather decides whether the weather suits the outdoor suggestion. It
+	// reads temperature, feels_like, condition, humidity and wind_speed.
+	FilterOutdoorWeather = "outdoor_weather"
+	// FilterSuggestion decides whether a city suggestion fits the trip. It reads title,
+	// description, tags, text (all three lowercased), mood and interests (the trip's
+	// interests followed by the mood's categories).
+	FilterSuggestion = "suggestion"
+)
+
+var (
+	requiredCostModels = []string{CostCultural, CostOutdoor, CostFood, CostNeighborhood, CostSeasonal, CostBudget, CostNightlife, CostEco, CostGeneric, CostGenericMood}
+	requiredFilters    = []string{FilterOutdoorWeather, FilterSuggestion}
+)
+
+// costEnv is what cost expressions can read
+type costEnv struct {
+	Budget   float64 `expr:"budget"`
+	Duration int     `expr:"duration"`
+	City     string  `expr:"city"`
+}
+
+// weatherEnv is what the outdoor weather filter can read
+type weatherEnv struct {
+	Temperature float64 `expr:"temperature"`
+	FeelsLike   float64 `expr:"feels_like"`
+	Condition   string  `expr:"condition"`
+	Humidity    int     `expr:"humidity"`
+	WindSpeed   float64 `expr:"wind_speed"`
+}
+
+// suggestionEnv is what the suggestion filter can read
+type suggestionEnv struct {
+	Title       string   `expr:"title"`
+	Description string   `expr:"description"`
+	Tags        []string `expr:"tags"`
+	Text        string   `expr:"text"`
+	Mood        string   `expr:"mood"`
+	Interests   []string `expr:"interests"`
+}
+
+// filterEnvs gives each filter the environment it's compiled and run against
+var filterEnvs = map[string]interface{}{
+	FilterOutdoorWeather: weatherEnv{},
+	FilterSuggestion:     suggestionEnv{},
+}
+
+// rulesFile is the layout of rules.json
+type rulesFile struct {
+	Moods                 map[string][]string `json:"moods"`
+	DefaultMoodCategories []string            `json:"default_mood_categories"`
+	Costs                 map[string]string   `json:"costs"`
+	Filters               map[string]string   `json:"filters"`
+}
+
+// Rules are the compiled cost models, filters and mood mappings from rules.json
+type Rules struct {
+	Moods                 map[string][]string
+	DefaultMoodCategories []string
+	Source                string
+	LoadedAt              time.Time
+
+	modTime time.Time
+	costs   map[string]*vm.Program
+	filters map[string]*vm.Program
+}
+
+// cachedRules is a tenant's compiled rules and when their file was last checked
+type cachedRules struct {
+	rules   *Rules
+	checked time.Time
+}
+
+var (
+	rulesCache  sync.Map   // tenant ID -> *cachedRules
+	rulesLoadMu sync.Mutex // one recompile at a time
+)
+
+// getRules returns the tenant's rules, recompiling them when rules.json has changed since
+// it was last checked. Without usable rules the embedded defaults are used.
+func getRules(ctx context.Context) *Rules {
+	tenant := TenantFromContext(ctx).ID
+	if cached, ok := rulesCache.Load(tenant); ok && time.Since(cached.(*cachedRules).checked) < RulesCheckInterval {
+		return cached.(*cachedRules).rules
+	}
+
+	rulesLoadMu.Lock()
+	defer rulesLoadMu.Unlock()
+
+	var current *Rules
+	if cached, ok := rulesCache.Load(tenant); ok {
+		current = cached.(*cachedRules).rules
+		if time.Since(cached.(*cachedRules).checked) < RulesCheckInterval {
+			return current
+		}
+		source, modTime := rulesVersion(ctx)
+		if source == current.Source && modTime.Equal(current.modTime) {
+			rulesCache.Store(tenant, &cachedRules{rules: current, checked: time.Now()})
+			return current
+		}
+	}
+
+	rules, err := loadRules(ctx)
+	if err != nil {
+		utils.LogError("Failed to load rules", err)
+		if current == nil {
+			rules = defaultRules()
+		} else {
+			rules = current
+		}
+	} else if current != nil {
+		utils.LogInfo(fmt.Sprintf("Reloaded rules from %s", rules.Source))
+	}
+	rulesCache.Store(tenant, &cachedRules{rules: rules, checked: time.Now()})
+	return rules
+}
+
+// ReloadRules recompiles rules.json right away, returning the problems when it doesn't
+// compile; the previous rules stay in effect in that case
+func ReloadRules(ctx context.Context) (*Rules, error) {
+	rulesLoadMu.Lock()
+	defer rulesLoadMu.Unlock()
+
+	rules, err := loadRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rulesCache.Store(TenantFromContext(ctx).ID, &cachedRules{rules: rules, checked: time.Now()})
+	return rules, nil
+}
+
+// rulesVersion identifies the rules.json in effect by where it's read from and its
+// modification time, which is zero for the embedded copy
+func rulesVersion(ctx context.Context) (string, time.Time) {
+	source := datasetSource(ctx, RulesDataset)
+	if info, err := os.Stat(source); err == nil {
+		return source, info.ModTime()
+	}
+	return source, time.Time{}
+}
+
+// loadRules reads and compiles rules.json
+func loadRules(ctx context.Context) (*Rules, error) {
+	source, modTime := rulesVersion(ctx)
+	content, err := ReadDataset(ctx, RulesDataset)
+	if err != nil {
+		return nil, err
+	}
+	rules, problems := compileRules(content)
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid %s:\n  - %s", source, strings.Join(problems, "\n  - "))
+	}
+	rules.Source = source
+	rules.modTime = modTime
+	return rules, nil
+}
+
+// defaultRules compiles the embedded rules.json, which always compiles in a released build
+func defaultRules() *Rules {
+	content, err := fs.ReadFile(data.Seed, RulesDataset)
+	if err != nil {
+		return &Rules{Source: "embedded:" + RulesDataset, LoadedAt: time.Now()}
+	}
+	rules, _ := compileRules(content)
+	rules.Source = "embedded:" + RulesDataset
+	return rules
+}
+
+// compileRules parses rules.json and compiles every expression, listing each problem
+func compileRules(content []byte) (*Rules, []string) {
+	rules := &Rules{
+		LoadedAt: time.Now(),
+		costs:    map[string]*vm.Program{},
+		filters:  map[string]*vm.Program{},
+	}
+
+	var file rulesFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return rules, []string{fmt.Sprintf("invalid JSON: %v", err)}
+	}
+
+	var problems []string
+	rules.Moods = map[string][]string{}
+	for mood, categories := range file.Moods {
+		if len(categories) == 0 {
+			problems = append(problems, fmt.Sprintf("moods.%s: at least one category is required", mood))
+		}
+		rules.Moods[strings.ToLower(mood)] = categories
+	}
+	if len(file.DefaultMoodCategories) == 0 {
+		problems = append(problems, "default_mood_categories: at least one category is required")
+	}
+	rules.DefaultMoodCategories = file.DefaultMoodCategories
+
+	for _, name := range sortedKeys(file.Costs) {
+		program, err := expr.Compile(file.Costs[name], expr.Env(costEnv{}), expr.AsFloat64())
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("costs.%s: %v", name, err))
+			continue
+		}
+		rules.costs[name] = program
+	}
+	for _, name := range sortedKeys(file.Filters) {
+		env, known := filterEnvs[name]
+		if !known {
+			problems = append(problems, fmt.Sprintf("filters.%s: unknown filter", name))
+			continue
+		}
+		program, err := expr.Compile(file.Filters[name], expr.Env(env), expr.AsBool())
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("filters.%s: %v", name, err))
+			continue
+		}
+		rules.filters[name] = program
+	}
+
+	for _, name := range requiredCostModels {
+		if _, ok := file.Costs[name]; !ok {
+			problems = append(problems, fmt.Sprintf("costs.%s is required", name))
+		}
+	}
+	for _, name := range requiredFilters {
+		if _, ok := file.Filters[name]; !ok {
+			problems = append(problems, fmt.Sprintf("filters.%s is required", name))
+		}
+	}
+	return rules, problems
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// estimateCost runs a cost model. A model that fails to run costs the whole budget.
+func estimateCost(ctx context.Context, model string, budget float64, duration int, city string) float64 {
+	program := getRules(ctx).costs[model]
+	if program == nil {
+		utils.LogWarning(fmt.Sprintf("No cost model %q, using the full budget", model))
+		return budget
+	}
+	out, err := expr.Run(program, costEnv{Budget: budget, Duration: duration, City: city})
+	if err != nil {
+		utils.LogError(fmt.Sprintf("Cost model %q failed", model), err)
+		return budget
+	}
+	return out.(float64)
+}
+
+// runFilter runs a filter, treating one that fails to run as not matching
+func runFilter(ctx context.Context, name string, env interface{}) bool {
+	program := getRules(ctx).filters[name]
+	if program == nil {
+		return false
+	}
+	out, err := expr.Run(program, env)
+	if err != nil {
+		utils.LogError(fmt.Sprintf("Filter %q failed", name), err)
+		return false
+	}
+	return out.(bool)
+}
+
+// MoodCategories returns the event categories and interests a mood maps to, or nil for a
+// mood the rules don't know
+func MoodCategories(ctx context.Context, mood string) []string {
+	return getRules(ctx).Moods[strings.ToLower(mood)]
+}
+
+// moodCategoriesOrDefault falls back to the default categories for an unknown mood
+func moodCategoriesOrDefault(ctx context.Context, mood string) []string {
+	rules := getRules(ctx)
+	if categories := rules.Moods[strings.ToLower(mood)]; categories != nil {
+		return categories
+	}
+	return rules.DefaultMoodCategories
+}
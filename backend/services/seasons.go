@@ -0,0 +1,89 @@
+package services
+
+import (
+	"fmt"
+	"time"
+)
+
+// seasonCycle is the order the seasons follow through the year
+var seasonCycle = []string{"winter", "spring", "summer", "fall"}
+
+// SeasonalData is the seasonal data a city has for a season. When the city has nothing
+// for the season itself it holds the nearest season's data instead, and when the city
+// has no seasonal data at all Data is nil.
+type SeasonalData struct {
+	Season string  // season asked for
+	Source string  // season Data comes from, empty when there is none
+	Data   *Season // nil when the city has no seasonal data
+}
+
+// Found reports whether there is any seasonal data to use
+func (s SeasonalData) Found() bool {
+	return s.Data != nil
+}
+
+// Approximate reports whether the data was borrowed from another season
+func (s SeasonalData) Approximate() bool {
+	return s.Data != nil && s.Source != s.Season
+}
+
+// Activities are the season's activities, or nil when there is no data
+func (s SeasonalData) Activities() []string {
+	if s.Data == nil {
+		return nil
+	}
+	return s.Data.Activities
+}
+
+// Note explains to a traveler how the seasonal data falls short, or is empty when the
+// city has data for the season itself
+func (s SeasonalData) Note(city string) string {
+	switch {
+	case !s.Found():
+		return fmt.Sprintf("We have no seasonal information for %s, so these activities don't account for the %s season", city, s.Season)
+	case s.Approximate():
+		return fmt.Sprintf("We have no %s information for %s, so seasonal activities are based on %s", s.Season, city, s.Source)
+	}
+	return ""
+}
+
+// citySeason looks up a city's data for the season date falls in. A missing season falls
+// back to the nearest one: the neighbouring season closer to date first, then the other
+// neighbour, then the opposite season.
+func citySeason(cityData *City, date time.Time) SeasonalData {
+	season := getSeasonForDate(date)
+	result := SeasonalData{Season: season}
+	if cityData == nil {
+		return result
+	}
+	for _, candidate := range nearestSeasons(season, date.Month()) {
+		if data, ok := cityData.Seasons[candidate]; ok {
+			result.Source = candidate
+			result.Data = &data
+			return result
+		}
+	}
+	return result
+}
+
+// nearestSeasons orders the seasons by how close they are to season in the given month,
+// starting with season itself
+func nearestSeasons(season string, month time.Month) []string {
+	index := 0
+	for i, name := range seasonCycle {
+		if name == season {
+			index = i
+		}
+	}
+	at := func(offset int) string {
+		return seasonCycle[(index+offset+len(seasonCycle))%len(seasonCycle)]
+	}
+
+	// December, March, June and September open their seasons, so they sit closest to the
+	// season before; the other months are nearer the season after
+	closer, farther := at(1), at(-1)
+	if month%3 == 0 {
+		closer, farther = farther, closer
+	}
+	return []string{season, closer, farther, at(2)}
+}
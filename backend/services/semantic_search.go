@@ -0,0 +1,670 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/joshndala/cantrip/utils"
+)
+
+// Kinds of entries in the semantic search index
+const (
+	SearchKindAttraction   = "attraction"
+	SearchKindNeighborhood = "neighborhood"
+	SearchKindActivity     = "activity" // a seasonal activity
+	SearchKindEvent        = "event"    // a recurring festival
+	SearchKindVenue        = "venue"    // a nightlife venue
+	SearchKindTip          = "tip"
+)
+
+var searchKinds = []string{SearchKindAttraction, SearchKindNeighborhood, SearchKindActivity, SearchKindEvent, SearchKindVenue, SearchKindTip}
+
+// Vector stores, chosen by whether PGVECTOR_URL is set
+const (
+	VectorStoreLocal    = "local"
+	VectorStorePGVector = "pgvector"
+)
+
+// Semantic search limits
+const (
+	DefaultSemanticResults = 10
+	MaxSemanticResults     = 50
+	MaxSemanticQueryLength = 500
+	embeddingBatchSize     = 64
+)
+
+// searchIndexCollection stores the local vector index, one document per embedding provider
+const searchIndexCollection = "search_index"
+
+// ErrInvalidSemanticQuery is returned for an empty or oversized query or an unknown kind
+var ErrInvalidSemanticQuery = errors.New("invalid semantic search query")
+
+// IndexedDocument is an entry in the semantic search index with its embedding
+type IndexedDocument struct {
+	ID        string    `json:"id"`
+	Kind      string    `json:"kind"`
+	Title     string    `json:"title"`
+	Text      string    `json:"text"`
+	City      string    `json:"city,omitempty"` // empty for tips that apply across Canada
+	Hash      string    `json:"hash"`           // of the embedded text and provider, to skip unchanged entries
+	Embedding []float32 `json:"embedding"`
+}
+
+// VectorFilter narrows a vector search. An empty field matches everything; a city also
+// matches entries that apply to every city.
+type VectorFilter struct {
+	City string
+	Kind string
+}
+
+// VectorMatch is an index entry found by a vector search, scored by cosine similarity
+type VectorMatch struct {
+	Document IndexedDocument
+	Score    float64
+}
+
+// VectorStore keeps embeddings and finds the ones nearest a query vector
+type VectorStore interface {
+	Name() string
+	Hashes(ctx context.Context) (map[string]string, error) // entry ID -> content hash
+	Upsert(ctx context.Context, documents []IndexedDocument) error
+	Remove(ctx context.Context, ids []string) error
+	Search(ctx context.Context, vector []float32, filter VectorFilter, limit int) ([]VectorMatch, error)
+}
+
+// SemanticQuery is a natural-language search, optionally narrowed to a city or kind
+type SemanticQuery struct {
+	Text  string
+	City  string // detected from the text when empty
+	Kind  string
+	Limit int
+}
+
+// SemanticResult is an attraction, event, tip or other entry relevant to a query
+type SemanticResult struct {
+	Kind  string  `json:"kind"`
+	Title string  `json:"title"`
+	Text  string  `json:"text"`
+	City  string  `json:"city,omitempty"`
+	Score float64 `json:"score"`
+}
+
+// SemanticSearchResponse is the result of a semantic search
+type SemanticSearchResponse struct {
+	Query   string           `json:"query"`
+	City    string           `json:"city,omitempty"` // the city searched, given or detected
+	Kind    string           `json:"kind,omitempty"`
+	Results []SemanticResult `json:"results"`
+}
+
+// IndexStats reports what a reindex changed
+type IndexStats struct {
+	Provider string `json:"provider"`
+	Store    string `json:"store"`
+	Entries  int    `json:"entries"`
+	Embedded int    `json:"embedded"` // new or changed entries
+	Removed  int    `json:"removed"`
+}
+
+var (
+	searchIndexMu    sync.Mutex
+	searchIndexBuilt = map[string]bool{} // by tenant ID
+)
+
+// newVectorStore picks the vector store from the environment
+func newVectorStore() VectorStore {
+	if url := os.Getenv("PGVECTOR_URL"); url != "" {
+		return &PGVectorStore{URL: url}
+	}
+	return &LocalVectorStore{}
+}
+
+// SemanticSearch finds attractions, neighborhoods, activities, festivals, venues and tips
+// whose meaning is close to the query's, building the tenant's index on first use
+func SemanticSearch(ctx context.Context, query SemanticQuery) (*SemanticSearchResponse, error) {
+	text := strings.TrimSpace(query.Text)
+	switch {
+	case text == "":
+		return nil, fmt.Errorf("%w: q is required", ErrInvalidSemanticQuery)
+	case len(text) > MaxSemanticQueryLength:
+		return nil, fmt.Errorf("%w: q is longer than %d characters", ErrInvalidSemanticQuery, MaxSemanticQueryLength)
+	case query.Kind != "" && !utils.Contains(searchKinds, query.Kind):
+		return nil, fmt.Errorf("%w: kind must be one of %s", ErrInvalidSemanticQuery, strings.Join(searchKinds, ", "))
+	}
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultSemanticResults
+	}
+	limit = min(limit, MaxSemanticResults)
+
+	index, err := GetCityIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	city := ""
+	if query.City != "" {
+		found, ok := index.Find(query.City)
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown city %q", ErrInvalidSemanticQuery, query.City)
+		}
+		city = found.Name
+	} else {
+		city = cityMentioned(index, text)
+	}
+
+	if err := ensureSearchIndex(ctx); err != nil {
+		return nil, err
+	}
+
+	// The city is a filter, so it's left out of what the query means
+	embedded := text
+	if city != "" {
+		embedded = strings.ReplaceAll(NormalizeCityName(text), NormalizeCityName(city), " ")
+	}
+	vectors, err := GetEmbeddingProvider().Embed(ctx, []string{embedded})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+
+	matches, err := GetVectorStore().Search(ctx, vectors[0], VectorFilter{City: city, Kind: query.Kind}, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []SemanticResult{}
+	for _, match := range matches {
+		if match.Score <= 0 {
+			continue
+		}
+		results = append(results, SemanticResult{
+			Kind:  match.Document.Kind,
+			Title: match.Document.Title,
+			Text:  match.Document.Text,
+			City:  match.Document.City,
+			Score: float64(int(match.Score*1000)) / 1000,
+		})
+	}
+	return &SemanticSearchResponse{Query: text, City: city, Kind: query.Kind, Results: results}, nil
+}
+
+// ReindexSemanticSearch re-reads the datasets into the tenant's index, embedding only
+// entries that are new or changed, for example after a dataset is replaced in DATA_DIR
+func ReindexSemanticSearch(ctx context.Context) (*IndexStats, error) {
+	searchIndexMu.Lock()
+	defer searchIndexMu.Unlock()
+	return buildSearchIndex(ctx)
+}
+
+// ensureSearchIndex builds the tenant's index unless it was built since the process started
+func ensureSearchIndex(ctx context.Context) error {
+	searchIndexMu.Lock()
+	defer searchIndexMu.Unlock()
+	if searchIndexBuilt[TenantFromContext(ctx).ID] {
+		return nil
+	}
+	_, err := buildSearchIndex(ctx)
+	return err
+}
+
+// buildSearchIndex syncs the store with the datasets; the caller holds searchIndexMu
+func buildSearchIndex(ctx context.Context) (*IndexStats, error) {
+	provider, store := GetEmbeddingProvider(), GetVectorStore()
+	stats := &IndexStats{Provider: provider.Name(), Store: store.Name()}
+
+	documents, err := searchDocuments(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stats.Entries = len(documents)
+
+	existing, err := store.Hashes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []IndexedDocument
+	current := map[string]bool{}
+	for _, document := range documents {
+		document.Hash = searchDocumentHash(provider, document)
+		current[document.ID] = true
+		if existing[document.ID] != document.Hash {
+			changed = append(changed, document)
+		}
+	}
+
+	for start := 0; start < len(changed); start += embeddingBatchSize {
+		batch := changed[start:min(start+embeddingBatchSize, len(changed))]
+		texts := make([]string, len(batch))
+		for i, document := range batch {
+			texts[i] = document.Title + ". " + document.Text
+		}
+		vectors, err := provider.Embed(ctx, texts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed search index: %w", err)
+		}
+		for i := range batch {
+			batch[i].Embedding = vectors[i]
+		}
+		if err := store.Upsert(ctx, batch); err != nil {
+			return nil, err
+		}
+		stats.Embedded += len(batch)
+	}
+
+	var stale []string
+	for id := range existing {
+		if !current[id] {
+			stale = append(stale, id)
+		}
+	}
+	if len(stale) > 0 {
+		if err := store.Remove(ctx, stale); err != nil {
+			return nil, err
+		}
+		stats.Removed = len(stale)
+	}
+
+	tenant := TenantFromContext(ctx).ID
+	searchIndexBuilt[tenant] = true
+	utils.LogInfo(fmt.Sprintf("Search index for tenant %s: %d entries, %d embedded, %d removed", tenant, stats.Entries, stats.Embedded, stats.Removed))
+	return stats, nil
+}
+
+// searchDocumentHash identifies the embedded text, so a changed entry or a new provider
+// is re-embedded
+func searchDocumentHash(provider EmbeddingProvider, document IndexedDocument) string {
+	sum := sha256.Sum256([]byte(provider.Name() + "\n" + document.Title + "\n" + document.Text))
+	return hex.EncodeToString(sum[:])
+}
+
+// searchDocuments collects the entries to index from the tenant's datasets. Attractions
+// carry the descriptions and vibes of neighborhoods that mention them, since their names
+// alone say little.
+func searchDocuments(ctx context.Context) ([]IndexedDocument, error) {
+	index, err := GetCityIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var documents []IndexedDocument
+	add := func(kind, city, title, text string) {
+		id := kind + ":" + NormalizeCityName(city) + ":" + NormalizeCityName(title)
+		documents = append(documents, IndexedDocument{ID: id, Kind: kind, Title: title, Text: strings.TrimSpace(text), City: city})
+	}
+
+	for _, city := range index.Metadata().Cities {
+		for _, attraction := range city.Attractions {
+			text := fmt.Sprintf("Attraction in %s, %s.", city.Name, city.Province)
+			for _, neighborhood := range city.Neighborhoods {
+				if strings.Contains(strings.ToLower(neighborhood.Description), strings.ToLower(attraction)) {
+					text += fmt.Sprintf(" In %s: %s. %s.", neighborhood.Name, neighborhood.Description, strings.Join(neighborhood.Vibes, ", "))
+				}
+			}
+			add(SearchKindAttraction, city.Name, attraction, text)
+		}
+		for _, neighborhood := range city.Neighborhoods {
+			add(SearchKindNeighborhood, city.Name, neighborhood.Name,
+				fmt.Sprintf("Neighborhood in %s: %s. %s.", city.Name, neighborhood.Description, strings.Join(neighborhood.Vibes, ", ")))
+		}
+		seasons := make([]string, 0, len(city.Seasons))
+		for name := range city.Seasons {
+			seasons = append(seasons, name)
+		}
+		sort.Strings(seasons)
+		for _, name := range seasons {
+			for _, activity := range city.Seasons[name].Activities {
+				add(SearchKindActivity, city.Name, activity, fmt.Sprintf("%s activity in %s.", utils.CapitalizeFirst(name), city.Name))
+			}
+		}
+	}
+
+	if festivals, err := loadFestivals(ctx); err == nil {
+		for _, festival := range festivals.Festivals {
+			if city, ok := index.Find(festival.City); ok {
+				add(SearchKindEvent, city.Name, festival.Name,
+					fmt.Sprintf("%s %s. %s.", festival.Description, festival.DateNote, strings.Join(festival.Tags, ", ")))
+			}
+		}
+	} else {
+		utils.LogError("Failed to load festivals for search index", err)
+	}
+
+	if nightlife, err := loadNightlife(ctx); err == nil {
+		for name, venues := range nightlife.Cities {
+			city, ok := index.Find(name)
+			if !ok {
+				continue
+			}
+			for _, venue := range venues {
+				add(SearchKindVenue, city.Name, venue.Name,
+					fmt.Sprintf("%s in %s, %s. %s.", strings.ReplaceAll(venue.Type, "_", " "), venue.Neighborhood, city.Name, strings.Join(venue.Tags, ", ")))
+			}
+		}
+	} else {
+		utils.LogError("Failed to load nightlife for search index", err)
+	}
+
+	tips, err := loadTipsData(ctx)
+	if err != nil {
+		utils.LogError("Failed to load tips for search index", err)
+		return documents, nil
+	}
+	addTips := func(section map[string]interface{}, city string) {
+		categories := make([]string, 0, len(section))
+		for category := range section {
+			categories = append(categories, category)
+		}
+		sort.Strings(categories)
+		for _, category := range categories {
+			categoryTips, _ := extractTipsFromInterface(section, category)
+			for _, tip := range categoryTips {
+				add(SearchKindTip, city, tip.Title, fmt.Sprintf("%s tip. %s %s.", utils.CapitalizeFirst(category), tip.Description, strings.Join(tip.Tags, ", ")))
+			}
+		}
+	}
+	addTips(tips.GeneralCanada, "")
+	for name, section := range tips.Cities {
+		if city, ok := index.Find(name); ok {
+			addTips(section, city.Name)
+		}
+	}
+
+	return dedupeSearchDocuments(documents), nil
+}
+
+// dedupeSearchDocuments keeps the first entry with each ID, such as an activity listed in
+// two seasons
+func dedupeSearchDocuments(documents []IndexedDocument) []IndexedDocument {
+	seen := map[string]bool{}
+	kept := documents[:0]
+	for _, document := range documents {
+		if !seen[document.ID] {
+			seen[document.ID] = true
+			kept = append(kept, document)
+		}
+	}
+	return kept
+}
+
+// cityMentioned finds the longest city name in a query, so "Quebec City" wins over "Quebec"
+func cityMentioned(index *CityIndex, text string) string {
+	normalized := " " + NormalizeCityName(text) + " "
+	best := ""
+	for _, city := range index.Metadata().Cities {
+		name := NormalizeCityName(city.Name)
+		if name != "" && strings.Contains(normalized, " "+name+" ") && len(name) > len(NormalizeCityName(best)) {
+			best = city.Name
+		}
+	}
+	return best
+}
+
+// matchesVectorFilter reports whether a document passes a filter
+func matchesVectorFilter(document IndexedDocument, filter VectorFilter) bool {
+	if filter.Kind != "" && document.Kind != filter.Kind {
+		return false
+	}
+	return filter.City == "" || document.City == "" || document.City == filter.City
+}
+
+// LocalVectorStore keeps each tenant's index in memory, persisted as a document per
+// embedding provider, and searches it exhaustively. That is quick for the few thousand
+// entries the datasets hold.
+type LocalVectorStore struct {
+	indexes sync.Map // tenant ID -> *localVectorIndex
+}
+
+type localVectorIndex struct {
+	mu        sync.RWMutex
+	documents map[string]IndexedDocument
+}
+
+// localSearchIndex is how the local index is persisted
+type localSearchIndex struct {
+	Provider  string            `json:"provider"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	Documents []IndexedDocument `json:"documents"`
+}
+
+func (s *LocalVectorStore) Name() string {
+	return VectorStoreLocal
+}
+
+// index returns the tenant's index, loading it from storage on first use
+func (s *LocalVectorStore) index(ctx context.Context) *localVectorIndex {
+	tenant := TenantFromContext(ctx).ID
+	if cached, ok := s.indexes.Load(tenant); ok {
+		return cached.(*localVectorIndex)
+	}
+
+	index := &localVectorIndex{documents: map[string]IndexedDocument{}}
+	var stored localSearchIndex
+	if err := loadDocument(ctx, searchIndexCollection, GetEmbeddingProvider().Name(), &stored); err == nil {
+		for _, document := range stored.Documents {
+			index.documents[document.ID] = document
+		}
+	} else if !errors.Is(err, ErrDocumentNotFound) {
+		utils.LogError("Failed to load search index", err)
+	}
+	actual, _ := s.indexes.LoadOrStore(tenant, index)
+	return actual.(*localVectorIndex)
+}
+
+func (s *LocalVectorStore) Hashes(ctx context.Context) (map[string]string, error) {
+	index := s.index(ctx)
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+	hashes := make(map[string]string, len(index.documents))
+	for id, document := range index.documents {
+		hashes[id] = document.Hash
+	}
+	return hashes, nil
+}
+
+func (s *LocalVectorStore) Upsert(ctx context.Context, documents []IndexedDocument) error {
+	index := s.index(ctx)
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	for _, document := range documents {
+		index.documents[document.ID] = document
+	}
+	return s.save(ctx, index)
+}
+
+func (s *LocalVectorStore) Remove(ctx context.Context, ids []string) error {
+	index := s.index(ctx)
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	for _, id := range ids {
+		delete(index.documents, id)
+	}
+	return s.save(ctx, index)
+}
+
+// save persists the index; the caller holds its write lock
+func (s *LocalVectorStore) save(ctx context.Context, index *localVectorIndex) error {
+	stored := localSearchIndex{Provider: GetEmbeddingProvider().Name(), UpdatedAt: time.Now().UTC()}
+	for _, document := range index.documents {
+		stored.Documents = append(stored.Documents, document)
+	}
+	sort.Slice(stored.Documents, func(i, j int) bool { return stored.Documents[i].ID < stored.Documents[j].ID })
+	if err := saveDocument(ctx, searchIndexCollection, stored.Provider, stored); err != nil {
+		return fmt.Errorf("failed to save search index: %w", err)
+	}
+	return nil
+}
+
+func (s *LocalVectorStore) Search(ctx context.Context, vector []float32, filter VectorFilter, limit int) ([]VectorMatch, error) {
+	index := s.index(ctx)
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	var matches []VectorMatch
+	for _, document := range index.documents {
+		if matchesVectorFilter(document, filter) {
+			matches = append(matches, VectorMatch{Document: document, Score: cosineSimilarity(vector, document.Embedding)})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].Document.ID < matches[j].Document.ID
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+// PGVectorStore keeps the index in PostgreSQL with the pgvector extension, one table
+// shared by tenants, and lets the database find the nearest entries
+type PGVectorStore struct {
+	URL string
+
+	once    sync.Once
+	pool    *pgxpool.Pool
+	initErr error
+}
+
+const pgVectorSchema = `
+CREATE EXTENSION IF NOT EXISTS vector;
+CREATE TABLE IF NOT EXISTS semantic_index (
+	tenant    text NOT NULL,
+	id        text NOT NULL,
+	kind      text NOT NULL,
+	title     text NOT NULL,
+	body      text NOT NULL,
+	city      text NOT NULL DEFAULT '',
+	hash      text NOT NULL,
+	embedding vector NOT NULL,
+	PRIMARY KEY (tenant, id)
+)`
+
+func (s *PGVectorStore) Name() string {
+	return VectorStorePGVector
+}
+
+// connect opens the pool and creates the table on first use
+func (s *PGVectorStore) connect(ctx context.Context) (*pgxpool.Pool, error) {
+	s.once.Do(func() {
+		ctx := storageContext(ctx)
+		s.pool, s.initErr = pgxpool.New(ctx, s.URL)
+		if s.initErr != nil {
+			return
+		}
+		if _, err := s.pool.Exec(ctx, pgVectorSchema); err != nil {
+			s.initErr = fmt.Errorf("failed to create pgvector schema: %w", err)
+		}
+	})
+	if s.initErr != nil {
+		return nil, fmt.Errorf("pgvector is unavailable: %w", s.initErr)
+	}
+	return s.pool, nil
+}
+
+func (s *PGVectorStore) Hashes(ctx context.Context) (map[string]string, error) {
+	pool, err := s.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := pool.Query(ctx, `SELECT id, hash FROM semantic_index WHERE tenant = $1`, TenantFromContext(ctx).ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pgvector index: %w", err)
+	}
+	defer rows.Close()
+
+	hashes := map[string]string{}
+	for rows.Next() {
+		var id, hash string
+		if err := rows.Scan(&id, &hash); err != nil {
+			return nil, fmt.Errorf("failed to read pgvector index: %w", err)
+		}
+		hashes[id] = hash
+	}
+	return hashes, rows.Err()
+}
+
+func (s *PGVectorStore) Upsert(ctx context.Context, documents []IndexedDocument) error {
+	pool, err := s.connect(ctx)
+	if err != nil {
+		return err
+	}
+	tenant := TenantFromContext(ctx).ID
+	batch := &pgx.Batch{}
+	for _, document := range documents {
+		batch.Queue(`INSERT INTO semantic_index (tenant, id, kind, title, body, city, hash, embedding)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8::vector)
+			ON CONFLICT (tenant, id) DO UPDATE SET kind = EXCLUDED.kind, title = EXCLUDED.title,
+				body = EXCLUDED.body, city = EXCLUDED.city, hash = EXCLUDED.hash, embedding = EXCLUDED.embedding`,
+			tenant, document.ID, document.Kind, document.Title, document.Text, document.City, document.Hash, pgVectorLiteral(document.Embedding))
+	}
+	if err := pool.SendBatch(storageContext(ctx), batch).Close(); err != nil {
+		return fmt.Errorf("failed to write pgvector index: %w", err)
+	}
+	return nil
+}
+
+func (s *PGVectorStore) Remove(ctx context.Context, ids []string) error {
+	pool, err := s.connect(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := pool.Exec(storageContext(ctx), `DELETE FROM semantic_index WHERE tenant = $1 AND id = ANY($2)`, TenantFromContext(ctx).ID, ids); err != nil {
+		return fmt.Errorf("failed to prune pgvector index: %w", err)
+	}
+	return nil
+}
+
+func (s *PGVectorStore) Search(ctx context.Context, vector []float32, filter VectorFilter, limit int) ([]VectorMatch, error) {
+	pool, err := s.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := pool.Query(ctx, `SELECT id, kind, title, body, city, 1 - (embedding <=> $2::vector) AS score
+		FROM semantic_index
+		WHERE tenant = $1 AND ($3 = '' OR city = '' OR city = $3) AND ($4 = '' OR kind = $4)
+		ORDER BY embedding <=> $2::vector, id
+		LIMIT $5`,
+		TenantFromContext(ctx).ID, pgVectorLiteral(vector), filter.City, filter.Kind, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search pgvector index: %w", err)
+	}
+	defer rows.Close()
+
+	var matches []VectorMatch
+	for rows.Next() {
+		var match VectorMatch
+		document := &match.Document
+		if err := rows.Scan(&document.ID, &document.Kind, &document.Title, &document.Text, &document.City, &match.Score); err != nil {
+			return nil, fmt.Errorf("failed to search pgvector index: %w", err)
+		}
+		matches = append(matches, match)
+	}
+	return matches, rows.Err()
+}
+
+// pgVectorLiteral formats a vector the way pgvector parses it, e.g. [0.1,0.2]
+func pgVectorLiteral(vector []float32) string {
+	var literal strings.Builder
+	literal.WriteByte('[')
+	for i, v := range vector {
+		if i > 0 {
+			literal.WriteByte(',')
+		}
+		literal.WriteString(strconv.FormatFloat(float64(v), 'f', -1, 32))
+	}
+	literal.WriteByte(']')
+	return literal.String()
+}
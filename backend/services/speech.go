@@ -0,0 +1,278 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/joshndala/cantrip/utils"
+)
+
+// Speech-to-text provider endpoints
+const (
+	WhisperBaseURL   = "https://api.openai.com/v1"
+	WhisperModel     = "whisper-1"
+	GoogleSTTBaseURL = "https://speech.googleapis.com/v1"
+	SpeechTimeout    = 30 * time.Second // transcription runs longer than other upstream calls
+)
+
+// STT_PROVIDER values
+const (
+	SpeechProviderWhisper = "whisper"
+	SpeechProviderGoogle  = "google"
+)
+
+// MaxVoiceBytes is the largest audio upload accepted, within the providers' sync limits
+const MaxVoiceBytes = 10 << 20 // 10 MB
+
+// voiceExtensions are the audio formats the providers accept
+var voiceExtensions = []string{".webm", ".ogg", ".oga", ".opus", ".mp3", ".mpga", ".mpeg", ".m4a", ".mp4", ".wav", ".flac"}
+
+// Speech errors
+var (
+	ErrInvalidAudio       = errors.New("invalid audio")
+	ErrSpeechUnavailable  = errors.New("speech-to-text is not configured")
+	ErrNoSpeechRecognized = errors.New("no speech was recognized")
+)
+
+// AudioInput is a recording to transcribe
+type AudioInput struct {
+	Data     []byte
+	Filename string
+	Language string // BCP-47 hint such as "en-CA"; empty to detect
+}
+
+// Transcript is the text recognized in a recording
+type Transcript struct {
+	Text       string  `json:"text"`
+	Language   string  `json:"language,omitempty"`
+	Duration   float64 `json:"duration,omitempty"` // seconds, when the provider reports it
+	Confidence float64 `json:"confidence,omitempty"`
+	Provider   string  `json:"provider"`
+}
+
+// SpeechToTextProvider transcribes recorded audio
+type SpeechToTextProvider interface {
+	Name() string
+	Transcribe(ctx context.Context, audio AudioInput) (*Transcript, error)
+}
+
+// newSpeechProvider picks the provider from STT_PROVIDER, or the first with an API key; the
+// mock agent mode gets a canned transcript
+func newSpeechProvider() SpeechToTextProvider {
+	client := &http.Client{Timeout: SpeechTimeout, Transport: upstreamHTTPClient().Transport}
+	whisperKey, googleKey := os.Getenv("OPENAI_API_KEY"), os.Getenv("GOOGLE_STT_API_KEY")
+
+	switch provider := os.Getenv("STT_PROVIDER"); {
+	case provider == SpeechProviderWhisper && whisperKey != "",
+		provider == "" && whisperKey != "":
+		return &WhisperClient{APIKey: whisperKey, BaseURL: WhisperBaseURL, Model: WhisperModel, HTTPClient: client}
+	case provider == SpeechProviderGoogle && googleKey != "",
+		provider == "" && googleKey != "":
+		return &GoogleSTTClient{APIKey: googleKey, BaseURL: GoogleSTTBaseURL, HTTPClient: client}
+	}
+	if os.Getenv("AI_MODE") == AIModeMock {
+		return &mockSpeechProvider{}
+	}
+	return nil
+}
+
+// TranscribeAudio validates a recording and transcribes it with the configured provider
+func TranscribeAudio(ctx context.Context, audio AudioInput) (*Transcript, error) {
+	switch {
+	case len(audio.Data) == 0:
+		return nil, fmt.Errorf("%w: recording is empty", ErrInvalidAudio)
+	case len(audio.Data) > MaxVoiceBytes:
+		return nil, fmt.Errorf("%w: recording is larger than %d MB", ErrInvalidAudio, MaxVoiceBytes>>20)
+	case !utils.Contains(voiceExtensions, utils.GetFileExtension(audio.Filename)):
+		return nil, fmt.Errorf("%w: supported formats are %s", ErrInvalidAudio, strings.Join(voiceExtensions, ", "))
+	}
+
+	provider := GetSpeechToTextProvider()
+	if provider == nil {
+		return nil, ErrSpeechUnavailable
+	}
+	transcript, err := provider.Transcribe(ctx, audio)
+	if err != nil {
+		return nil, err
+	}
+	transcript.Text = strings.TrimSpace(transcript.Text)
+	if transcript.Text == "" {
+		return nil, ErrNoSpeechRecognized
+	}
+	transcript.Provider = provider.Name()
+	return transcript, nil
+}
+
+// postUpstream sends a request body and decodes the JSON response into v
+func postUpstream(ctx context.Context, client *http.Client, provider, rawURL, contentType string, body io.Reader, headers map[string]string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURL, body)
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %w", provider, err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch from %s: %w", provider, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// Rejected audio is the caller's problem; anything else is the provider's
+		if resp.StatusCode == http.StatusBadRequest {
+			return fmt.Errorf("%w: %s could not decode the recording", ErrInvalidAudio, provider)
+		}
+		return fmt.Errorf("%s API returned status: %d", provider, resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", provider, err)
+	}
+	return nil
+}
+
+// WhisperClient is a SpeechToTextProvider backed by the OpenAI transcription API
+type WhisperClient struct {
+	APIKey     string
+	BaseURL    string
+	Model      string
+	HTTPClient *http.Client
+}
+
+func (c *WhisperClient) Name() string {
+	return SpeechProviderWhisper
+}
+
+// Transcribe uploads the recording as multipart form data
+func (c *WhisperClient) Transcribe(ctx context.Context, audio AudioInput) (*Transcript, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", audio.Filename)
+	if err != nil {
+		return nil, err
+	}
+	part.Write(audio.Data)
+	form.WriteField("model", c.Model)
+	form.WriteField("response_format", "verbose_json")
+	if audio.Language != "" {
+		// Whisper takes ISO-639-1, so "en-CA" becomes "en"
+		form.WriteField("language", strings.ToLower(strings.SplitN(audio.Language, "-", 2)[0]))
+	}
+	if err := form.Close(); err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Text     string  `json:"text"`
+		Language string  `json:"language"`
+		Duration float64 `json:"duration"`
+	}
+	headers := map[string]string{"Authorization": "Bearer " + c.APIKey}
+	if err := postUpstream(ctx, c.HTTPClient, "Whisper", c.BaseURL+"/audio/transcriptions", form.FormDataContentType(), &body, headers, &result); err != nil {
+		return nil, err
+	}
+	return &Transcript{Text: result.Text, Language: result.Language, Duration: result.Duration}, nil
+}
+
+// GoogleSTTClient is a SpeechToTextProvider backed by Google Cloud Speech-to-Text
+type GoogleSTTClient struct {
+	APIKey     string
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+func (c *GoogleSTTClient) Name() string {
+	return SpeechProviderGoogle
+}
+
+// Transcribe sends the recording inline to the synchronous recognize method, which
+// accepts up to a minute of audio
+func (c *GoogleSTTClient) Transcribe(ctx context.Context, audio AudioInput) (*Transcript, error) {
+	language := audio.Language
+	if language == "" {
+		language = "en-CA"
+	}
+	config := map[string]interface{}{
+		"languageCode":               language,
+		"alternativeLanguageCodes":   []string{"fr-CA"},
+		"enableAutomaticPunctuation": true,
+	}
+	// WAV and FLAC headers describe themselves; Opus containers must be named
+	switch utils.GetFileExtension(audio.Filename) {
+	case ".webm":
+		config["encoding"] = "WEBM_OPUS"
+	case ".ogg", ".oga", ".opus":
+		config["encoding"] = "OGG_OPUS"
+	case ".mp3", ".mpga", ".mpeg":
+		config["encoding"] = "MP3"
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"config": config,
+		"audio":  map[string]string{"content": base64.StdEncoding.EncodeToString(audio.Data)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Results []struct {
+			Alternatives []struct {
+				Transcript string  `json:"transcript"`
+				Confidence float64 `json:"confidence"`
+			} `json:"alternatives"`
+			LanguageCode string `json:"languageCode"`
+		} `json:"results"`
+		TotalBilledTime string `json:"totalBilledTime"`
+	}
+	endpoint := c.BaseURL + "/speech:recognize?key=" + url.QueryEscape(c.APIKey)
+	if err := postUpstream(ctx, c.HTTPClient, "Google Speech", endpoint, "application/json", bytes.NewReader(payload), nil, &result); err != nil {
+		return nil, err
+	}
+
+	// Each result is a consecutive stretch of the recording
+	transcript := &Transcript{Language: language}
+	var texts []string
+	for _, r := range result.Results {
+		if len(r.Alternatives) == 0 {
+			continue
+		}
+		texts = append(texts, strings.TrimSpace(r.Alternatives[0].Transcript))
+		if transcript.Confidence == 0 || r.Alternatives[0].Confidence < transcript.Confidence {
+			transcript.Confidence = r.Alternatives[0].Confidence
+		}
+		if r.LanguageCode != "" {
+			transcript.Language = r.LanguageCode
+		}
+	}
+	transcript.Text = strings.Join(texts, " ")
+	if billed, err := time.ParseDuration(result.TotalBilledTime); err == nil {
+		transcript.Duration = billed.Seconds()
+	}
+	return transcript, nil
+}
+
+// mockSpeechProvider returns a fixed transcript so voice chat works offline
+type mockSpeechProvider struct{}
+
+func (p *mockSpeechProvider) Name() string {
+	return "mock"
+}
+
+func (p *mockSpeechProvider) Transcribe(ctx context.Context, audio AudioInput) (*Transcript, error) {
+	return &Transcript{Text: "What's the weather like in Vancouver?", Language: "en", Confidence: 1}, nil
+}
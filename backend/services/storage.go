@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/joshndala/cantrip/utils"
+)
+
+// ErrDocumentNotFound is returned when a stored document does not exist
+var ErrDocumentNotFound = errors.New("document not found")
+
+// localDocumentDir returns the local directory for a document collection of the tenant ctx
+// acts for
+func localDocumentDir(ctx context.Context, collection string) string {
+	return filepath.Join("data", filepath.FromSlash(TenantFromContext(ctx).StoragePrefix), collection)
+}
+
+// tenantDataFile moves a file under the data directory, such as AuditLogFile, into the
+// storage prefix of the tenant ctx acts for
+func tenantDataFile(ctx context.Context, file string) string {
+	rel, err := filepath.Rel("data", file)
+	if err != nil {
+		return file
+	}
+	return filepath.Join(localDocumentDir(ctx, ""), rel)
+}
+
+// storageContext keeps the tenant ctx acts for but not its deadline, so a document write
+// isn't abandoned halfway when the request that made it finishes
+func storageContext(ctx context.Context) context.Context {
+	return context.WithoutCancel(ctx)
+}
+
+// saveDocument saves a JSON document to the object store, falling back to local storage
+func saveDocument(ctx context.Context, collection, id string, v interface{}) error {
+	// Try to save to the object store first
+	if store := GetObjectStore(); store != nil {
+		ctx := storageContext(ctx)
+		objectName := fmt.Sprintf("%s/%s.json", collection, id)
+
+		if err := store.UploadJSON(ctx, objectName, v); err == nil {
+			return nil
+		}
+		// If the store fails, fall back to local storage
+	}
+
+	// Fallback to local storage
+	dataDir := localDocumentDir(ctx, collection)
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	jsonData, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal document: %w", err)
+	}
+
+	filename := filepath.Join(dataDir, fmt.Sprintf("%s.json", id))
+	if err := os.WriteFile(filename, jsonData, 0644); err != nil {
+		return fmt.Errorf("failed to write document: %w", err)
+	}
+
+	return nil
+}
+
+// loadDocument loads a JSON document from the object store, falling back to local storage
+func loadDocument(ctx context.Context, collection, id string, v interface{}) error {
+	// Try to get from the object store first
+	if store := GetObjectStore(); store != nil {
+		ctx := storageContext(ctx)
+		objectName := fmt.Sprintf("%s/%s.json", collection, id)
+
+		if err := store.DownloadJSON(ctx, objectName, v); err == nil {
+			return nil
+		}
+		// If the store fails, fall back to local storage
+	}
+
+	filename := filepath.Join(localDocumentDir(ctx, collection), fmt.Sprintf("%s.json", id))
+	jsonData, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%s/%s: %w", collection, id, ErrDocumentNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read document: %w", err)
+	}
+
+	if err := json.Unmarshal(jsonData, v); err != nil {
+		return fmt.Errorf("failed to unmarshal document: %w", err)
+	}
+
+	return nil
+}
+
+// deleteDocument permanently removes a JSON document from all stores
+func deleteDocument(ctx context.Context, collection, id string) error {
+	if store := GetObjectStore(); store != nil {
+		ctx := storageContext(ctx)
+		objectName := fmt.Sprintf("%s/%s.json", collection, id)
+		if err := store.DeleteFile(ctx, objectName); err != nil {
+			utils.LogWarning(fmt.Sprintf("Failed to delete %s from object store: %v", objectName, err))
+		}
+	}
+
+	filename := filepath.Join(localDocumentDir(ctx, collection), fmt.Sprintf("%s.json", id))
+	if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete document: %w", err)
+	}
+
+	return nil
+}
+
+// listDocumentIDs lists the IDs of all documents in a collection
+func listDocumentIDs(ctx context.Context, collection string) ([]string, error) {
+	seen := make(map[string]bool)
+	var ids []string
+
+	if store := GetObjectStore(); store != nil {
+		ctx := storageContext(ctx)
+		prefix := collection + "/"
+		if files, err := store.ListFiles(ctx, prefix); err == nil {
+			for _, file := range files {
+				id := strings.TrimSuffix(strings.TrimPrefix(file.Name, prefix), ".json")
+				if id != "" && !seen[id] {
+					seen[id] = true
+					ids = append(ids, id)
+				}
+			}
+		}
+	}
+
+	entries, err := os.ReadDir(localDocumentDir(ctx, collection))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to list %s: %w", collection, err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		id := strings.TrimSuffix(entry.Name(), ".json")
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+// writeFileAtomic writes data to a temporary file beside path and renames it into place,
+// so readers and concurrent writers never see a partial file
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
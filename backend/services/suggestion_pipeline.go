@@ -0,0 +1,241 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/joshndala/cantrip/utils"
+)
+
+// Suggestion pipeline phases, in the order they run
+const (
+	SuggestionPhaseFetch  = "fetch"  // gather candidate suggestions
+	SuggestionPhaseEnrich = "enrich" // annotate candidates
+	SuggestionPhaseScore  = "score"  // adjust TripSuggestion.Score
+	SuggestionPhaseFilter = "filter" // drop candidates that don't fit the query
+	SuggestionPhaseRank   = "rank"   // order and trim the final list
+)
+
+var suggestionPhases = []string{
+	SuggestionPhaseFetch,
+	SuggestionPhaseEnrich,
+	SuggestionPhaseScore,
+	SuggestionPhaseFilter,
+	SuggestionPhaseRank,
+}
+
+// maxTripSuggestions is how many suggestions the rank stage keeps
+const maxTripSuggestions = 5
+
+// SuggestionRun carries one request through the pipeline. Stages read the query and
+// rewrite Suggestions in place.
+type SuggestionRun struct {
+	Query       SuggestionQuery
+	Weather     WeatherInfo
+	City        *City // nil when the city isn't in the metadata
+	Suggestions []TripSuggestion
+}
+
+// SuggestionStage is one step of the suggestion pipeline. The request's tenant is
+// available through TenantFromContext, for stages that only apply to some deployments.
+type SuggestionStage struct {
+	Name  string
+	Phase string
+	Run   func(ctx context.Context, run *SuggestionRun) error
+}
+
+var (
+	suggestionStagesMu sync.RWMutex
+	suggestionStages   = []SuggestionStage{
+		{Name: "city candidates", Phase: SuggestionPhaseFetch, Run: fetchSuggestionCandidates},
+		{Name: "sustainability", Phase: SuggestionPhaseEnrich, Run: enrichSustainableSuggestions},
+		{Name: "guides", Phase: SuggestionPhaseEnrich, Run: linkSuggestionGuides},
+		{Name: "ratings", Phase: SuggestionPhaseEnrich, Run: rateSuggestions},
+		{Name: "eco preference", Phase: SuggestionPhaseScore, Run: scoreEcoSuggestions},
+		{Name: "mood and interests", Phase: SuggestionPhaseFilter, Run: filterSuggestionCandidates},
+		{Name: "top suggestions", Phase: SuggestionPhaseRank, Run: rankSuggestions},
+	}
+)
+
+// builtinSuggestionStages is how many of suggestionStages ship with the service
+var builtinSuggestionStages = len(suggestionStages)
+
+// RegisterSuggestionStage adds a stage to the suggestion pipeline. Registered stages run
+// after the built-in stage of their phase, in registration order, so a score stage sees
+// the built-in scores and its own scores are ranked by the built-in rank stage.
+func RegisterSuggestionStage(stage SuggestionStage) error {
+	if stage.Name == "" || stage.Run == nil {
+		return fmt.Errorf("suggestion stage needs a name and a run function")
+	}
+	if !isSuggestionPhase(stage.Phase) {
+		return fmt.Errorf("unknown suggestion phase %q", stage.Phase)
+	}
+
+	suggestionStagesMu.Lock()
+	defer suggestionStagesMu.Unlock()
+	suggestionStages = append(suggestionStages, stage)
+	return nil
+}
+
+// KeywordBoostStage returns a score stage that raises suggestions mentioning any of the
+// keywords in their title, description, activities or tags, such as a tourism board
+// promoting its own attractions
+func KeywordBoostStage(name string, keywords []string, boost float64) SuggestionStage {
+	return SuggestionStage{
+		Name:  name,
+		Phase: SuggestionPhaseScore,
+		Run: func(ctx context.Context, run *SuggestionRun) error {
+			for i := range run.Suggestions {
+				text := strings.ToLower(strings.Join(append([]string{
+					run.Suggestions[i].Title,
+					run.Suggestions[i].Description,
+				}, append(run.Suggestions[i].Activities, run.Suggestions[i].Tags...)...), " "))
+				for _, keyword := range keywords {
+					if keyword != "" && strings.Contains(text, strings.ToLower(keyword)) {
+						run.Suggestions[i].Score += boost
+						break
+					}
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// runSuggestionPipeline runs every stage phase by phase. A failing built-in stage fails
+// the run; a failing registered stage is logged and skipped.
+func runSuggestionPipeline(ctx context.Context, run *SuggestionRun) error {
+	suggestionStagesMu.RLock()
+	stages := append([]SuggestionStage{}, suggestionStages...)
+	suggestionStagesMu.RUnlock()
+
+	for _, phase := range suggestionPhases {
+		for i, stage := range stages {
+			if stage.Phase != phase {
+				continue
+			}
+			if err := stage.Run(ctx, run); err != nil {
+				if i < builtinSuggestionStages {
+					return err
+				}
+				utils.LogError(fmt.Sprintf("Suggestion stage %q failed", stage.Name), err)
+			}
+		}
+	}
+	return nil
+}
+
+func isSuggestionPhase(phase string) bool {
+	for _, known := range suggestionPhases {
+		if phase == known {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchSuggestionCandidates builds candidates from the city's metadata, or generic ones
+// for cities the metadata doesn't know
+func fetchSuggestionCandidates(ctx context.Context, run *SuggestionRun) error {
+	metadata, err := loadCityMetadata(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load city metadata: %w", err)
+	}
+
+	q := run.Query
+	cityData, err := findCity(metadata, q.City)
+	if err != nil {
+		run.Suggestions = generateGenericTripSuggestions(ctx, q.Mood, q.City, q.Budget, q.Duration, q.Interests, run.Weather)
+		return nil
+	}
+	run.City = cityData
+	run.Suggestions = generateCityBasedTripSuggestions(ctx, cityData, q.Mood, q.Budget, q.Duration, q.Interests, run.Weather, q.Eco)
+	return nil
+}
+
+// enrichSustainableSuggestions adds the city's sustainability notes for eco travelers
+func enrichSustainableSuggestions(ctx context.Context, run *SuggestionRun) error {
+	if run.Query.Eco && run.City != nil {
+		markSustainableSuggestions(run.Suggestions, run.City)
+	}
+	return nil
+}
+
+// scoreEcoSuggestions lifts sustainable suggestions for eco travelers
+func scoreEcoSuggestions(ctx context.Context, run *SuggestionRun) error {
+	if !run.Query.Eco {
+		return nil
+	}
+	for i := range run.Suggestions {
+		if run.Suggestions[i].Sustainable {
+			run.Suggestions[i].Score++
+		}
+	}
+	return nil
+}
+
+// filterSuggestionCandidates keeps city suggestions matching the mood or interests.
+// Generic suggestions are already written for the query and pass through. Visitors in the
+// interest scorer variant of the ranking experiment keep the suggestions that match at
+// least one interest, scored by how many they match.
+func filterSuggestionCandidates(ctx context.Context, run *SuggestionRun) error {
+	if run.City == nil {
+		return nil
+	}
+	interests := run.Query.Interests
+	if run.Query.Eco {
+		interests = append(append([]string{}, interests...), "sustainable")
+	}
+	if AssignedVariant(ctx, ExperimentSuggestionRanking) == VariantInterestScorer {
+		run.Suggestions = scoreSuggestionsByInterests(ctx, run.Suggestions, run.Query.Mood, interests)
+		return nil
+	}
+	run.Suggestions = filterSuggestionsByMoodAndInterests(ctx, run.Suggestions, run.Query.Mood, interests)
+	return nil
+}
+
+// scoreSuggestionsByInterests adds a point for each interest or mood category a suggestion
+// mentions, two when it's one of the suggestion's tags, and drops those matching none
+func scoreSuggestionsByInterests(ctx context.Context, suggestions []TripSuggestion, mood string, interests []string) []TripSuggestion {
+	all := append(append([]string{}, interests...), moodCategoriesOrDefault(ctx, mood)...)
+
+	var scored []TripSuggestion
+	for _, suggestion := range suggestions {
+		text := strings.ToLower(suggestion.Title + " " + suggestion.Description)
+		tags := map[string]bool{}
+		for _, tag := range suggestion.Tags {
+			tags[strings.ToLower(tag)] = true
+		}
+
+		matches := 0.0
+		for _, interest := range all {
+			interest = strings.ToLower(interest)
+			switch {
+			case tags[interest]:
+				matches += 2
+			case strings.Contains(text, interest):
+				matches++
+			}
+		}
+		if matches > 0 {
+			suggestion.Score += matches
+			scored = append(scored, suggestion)
+		}
+	}
+	return scored
+}
+
+// rankSuggestions orders suggestions by score, keeping the generated order among equal
+// scores, and keeps the top few
+func rankSuggestions(ctx context.Context, run *SuggestionRun) error {
+	sort.SliceStable(run.Suggestions, func(i, j int) bool {
+		return run.Suggestions[i].Score > run.Suggestions[j].Score
+	})
+	if len(run.Suggestions) > maxTripSuggestions {
+		run.Suggestions = run.Suggestions[:maxTripSuggestions]
+	}
+	return nil
+}
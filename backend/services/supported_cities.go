@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"sort"
+)
+
+// SupportedCity is a city we have data for, with what that data covers
+type SupportedCity struct {
+	Name           string      `json:"name"`
+	Province       string      `json:"province"`
+	Coordinates    Coordinates `json:"coordinates"`
+	HasSeasons     bool        `json:"has_seasons"`     // seasonal weather and activities
+	HasTips        bool        `json:"has_tips"`        // tips beyond the general Canada ones
+	HasAttractions bool        `json:"has_attractions"` // attractions to suggest and plan around
+}
+
+// ListSupportedCities lists the cities of the tenant ctx acts for, sorted by name and
+// limited to a province when one is given
+func ListSupportedCities(ctx context.Context, province string) ([]SupportedCity, error) {
+	index, err := GetCityIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cities := citiesOf(index)
+	if province != "" {
+		cities = index.InProvince(province)
+	}
+
+	// Tips are optional; without them every city just lacks city tips
+	tipCities := map[string]bool{}
+	if tips, err := loadTipsData(ctx); err == nil {
+		for name := range tips.Cities {
+			tipCities[NormalizeCityName(name)] = true
+		}
+	}
+
+	supported := make([]SupportedCity, 0, len(cities))
+	for _, city := range cities {
+		supported = append(supported, SupportedCity{
+			Name:           city.Name,
+			Province:       city.Province,
+			Coordinates:    city.Coordinates,
+			HasSeasons:     len(city.Seasons) > 0,
+			HasTips:        tipCities[NormalizeCityName(city.Name)],
+			HasAttractions: len(city.Attractions) > 0,
+		})
+	}
+	sort.SliceStable(supported, func(i, j int) bool {
+		return NormalizeCityName(supported[i].Name) < NormalizeCityName(supported[j].Name)
+	})
+	return supported, nil
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// TransportPublic is the transport preference for transit, used by eco trips
+const TransportPublic = "public"
+
+// Sustainability holds a city's low-impact travel notes and highlights
+type Sustainability struct {
+	Notes      []string `json:"notes"`
+	Highlights []string `json:"highlights"` // parks, markets and car-free routes
+}
+
+// SustainableKeywords mark a suggestion as low-impact when they appear in its tags or activities
+var SustainableKeywords = []string{"sustainable", "park", "walking", "market", "trail", "garden", "bike", "nature", "hike"}
+
+// isSustainableSuggestion reports whether a suggestion's tags or activities mention a low-impact activity
+func isSustainableSuggestion(suggestion TripSuggestion) bool {
+	text := strings.ToLower(strings.Join(suggestion.Tags, " ") + " " + strings.Join(suggestion.Activities, " "))
+	for _, keyword := range SustainableKeywords {
+		if strings.Contains(text, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// ecoTripSuggestion builds a suggestion around the city's sustainable highlights
+func ecoTripSuggestion(ctx context.Context, cityData *City, budget float64, duration int) TripSuggestion {
+	activities := make([]string, 0, len(cityData.Sustainability.Highlights)+1)
+	for _, highlight := range cityData.Sustainability.Highlights {
+		activities = append(activities, fmt.Sprintf("Visit %s", highlight))
+	}
+	activities = append(activities, "Get around by transit, bike and on foot")
+
+	return TripSuggestion{
+		Title:         fmt.Sprintf("Low-Impact Explorer in %s", cityData.Name),
+		Description:   fmt.Sprintf("See %s by transit and on foot, with parks, walking routes and local markets", cityData.Name),
+		Activities:    activities,
+		EstimatedCost: estimateCost(ctx, CostEco, budget, duration, cityData.Name),
+		Duration:      duration,
+		Tags:          []string{"sustainable", "walking", "park", "market", "local"},
+	}
+}
+
+// markSustainableSuggestions marks sustainable suggestions and annotates them with the
+// city's notes. The rank stage moves them ahead of the rest for eco travelers.
+func markSustainableSuggestions(suggestions []TripSuggestion, cityData *City) {
+	for i := range suggestions {
+		if !isSustainableSuggestion(suggestions[i]) {
+			continue
+		}
+		suggestions[i].Sustainable = true
+		suggestions[i].SustainabilityNotes = cityData.Sustainability.Notes
+	}
+}
+
+// annotateEcoTransport adds a note to days whose transport legs use a taxi or car,
+// suggesting transit or walking instead
+func annotateEcoTransport(itinerary *ItineraryResponse) {
+	if itinerary == nil {
+		return
+	}
+	days, _ := itinerary.Itinerary["days"].([]interface{})
+	for _, day := range days {
+		dayMap, ok := day.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if text, ok := dayMap["transport"].(string); ok {
+			if strings.Contains(strings.ToLower(text), "taxi") {
+				dayMap["sustainability_note"] = "Consider transit or walking instead of a taxi"
+			}
+			continue
+		}
+		legs, _ := dayMap["transport"].([]interface{})
+		var swaps []string
+		for _, leg := range legs {
+			legMap, ok := leg.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			legType, _ := legMap["type"].(string)
+			if !strings.EqualFold(legType, "taxi") {
+				continue
+			}
+			from, _ := legMap["from"].(string)
+			to, _ := legMap["to"].(string)
+			if from != "" && to != "" {
+				swaps = append(swaps, fmt.Sprintf("%s to %s", from, to))
+			} else {
+				swaps = append(swaps, "a taxi ride")
+			}
+		}
+		if len(swaps) > 0 {
+			dayMap["sustainability_note"] = fmt.Sprintf("Consider transit or walking instead of a taxi for %s", strings.Join(swaps, ", "))
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joshndala/cantrip/utils"
+)
+
+// TenantsFileEnv names the JSON file listing a multi-tenant deployment's tenants. Without
+// it the server runs single-tenant and every request belongs to DefaultTenantID.
+const TenantsFileEnv = "TENANTS_FILE"
+
+// DefaultTenantID is the tenant requests belong to when no other tenant claims them. Its
+// data keeps the single-tenant layout, so enabling tenants doesn't move existing trips.
+const DefaultTenantID = "default"
+
+// ErrUnknownTenant is returned for a tenant ID no tenant is configured with
+var ErrUnknownTenant = errors.New("unknown tenant")
+
+// tenantIDPattern keeps tenant IDs safe to use as path and object name segments
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// TenantQuota caps a tenant's request rate. Zero leaves a window unlimited.
+type TenantQuota struct {
+	RequestsPerMinute int `json:"requests_per_minute,omitempty"`
+	RequestsPerDay    int `json:"requests_per_day,omitempty"`
+}
+
+// Tenant is one branded deployment served from this instance, such as a province's
+// tourism board
+type Tenant struct {
+	ID            string            `json:"id"`
+	Name          string            `json:"name"`
+	Hosts         []string          `json:"hosts,omitempty"`          // hostnames that resolve to the tenant
+	APIKeys       []string          `json:"api_keys,omitempty"`       // SHA-256 hex digests of the keys it accepts; none leaves it open
+	DataDir       string            `json:"data_dir,omitempty"`       // dataset overrides, such as its own cities and tips
+	StoragePrefix string            `json:"storage_prefix,omitempty"` // where its documents and PDFs are kept, by default tenants/<id>
+	Quota         TenantQuota       `json:"quota"`
+	Branding      map[string]string `json:"branding,omitempty"` // display name, colors and logo URL for the client
+}
+
+// tenantsConfig is the TENANTS_FILE format
+type tenantsConfig struct {
+	Tenants []*Tenant `json:"tenants"`
+}
+
+var (
+	tenantsMu     sync.RWMutex
+	tenantsByID   = map[string]*Tenant{DefaultTenantID: {ID: DefaultTenantID}}
+	tenantsByHost = map[string]*Tenant{}
+)
+
+// LoadTenants reads the tenants listed in TENANTS_FILE. A tenant with DefaultTenantID may
+// be listed to configure the default tenant's data directory, keys or quota.
+func LoadTenants() error {
+	file := os.Getenv(TenantsFileEnv)
+	if file == "" {
+		return nil
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	var config tenantsConfig
+	if err := json.Unmarshal(content, &config); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+
+	byID := map[string]*Tenant{DefaultTenantID: {ID: DefaultTenantID}}
+	byHost := map[string]*Tenant{}
+	for _, tenant := range config.Tenants {
+		if !tenantIDPattern.MatchString(tenant.ID) {
+			return fmt.Errorf("tenant ID %q must be lowercase letters, digits and dashes", tenant.ID)
+		}
+		if _, exists := byID[tenant.ID]; exists && tenant.ID != DefaultTenantID {
+			return fmt.Errorf("tenant %s is listed twice", tenant.ID)
+		}
+		if tenant.StoragePrefix == "" && tenant.ID != DefaultTenantID {
+			tenant.StoragePrefix = "tenants/" + tenant.ID
+		}
+		tenant.StoragePrefix = strings.Trim(path.Clean("/"+tenant.StoragePrefix), "/")
+		// Tenant data stays out of the default tenant's collections
+		if tenant.ID != DefaultTenantID && !strings.HasPrefix(tenant.StoragePrefix, "tenants/") {
+			return fmt.Errorf("tenant %s storage prefix must be under tenants/", tenant.ID)
+		}
+		if tenant.ID == DefaultTenantID && tenant.StoragePrefix != "" {
+			return fmt.Errorf("the %s tenant keeps the single-tenant storage layout", DefaultTenantID)
+		}
+		for i, key := range tenant.APIKeys {
+			digest, err := hex.DecodeString(key)
+			if err != nil || len(digest) != sha256.Size {
+				return fmt.Errorf("tenant %s API key %d is not a SHA-256 hex digest", tenant.ID, i+1)
+			}
+			tenant.APIKeys[i] = strings.ToLower(key)
+		}
+		for _, host := range tenant.Hosts {
+			host = strings.ToLower(host)
+			if other, taken := byHost[host]; taken {
+				return fmt.Errorf("host %s is claimed by tenants %s and %s", host, other.ID, tenant.ID)
+			}
+			byHost[host] = tenant
+		}
+		byID[tenant.ID] = tenant
+	}
+
+	for _, tenant := range byID {
+		for _, other := range byID {
+			if tenant != other && tenant.StoragePrefix != "" && strings.HasPrefix(other.StoragePrefix+"/", tenant.StoragePrefix+"/") {
+				return fmt.Errorf("tenants %s and %s have overlapping storage prefixes", tenant.ID, other.ID)
+			}
+		}
+	}
+
+	tenantsMu.Lock()
+	tenantsByID = byID
+	tenantsByHost = byHost
+	tenantsMu.Unlock()
+	utils.LogInfo(fmt.Sprintf("Loaded %d tenants from %s", len(byID), file))
+	return nil
+}
+
+// MultiTenant reports whether tenants other than the default are configured
+func MultiTenant() bool {
+	tenantsMu.RLock()
+	defer tenantsMu.RUnlock()
+	return len(tenantsByID) > 1
+}
+
+// Tenants lists every tenant, the default first
+func Tenants() []*Tenant {
+	tenantsMu.RLock()
+	defer tenantsMu.RUnlock()
+	tenants := make([]*Tenant, 0, len(tenantsByID))
+	for _, tenant := range tenantsByID {
+		tenants = append(tenants, tenant)
+	}
+	sort.Slice(tenants, func(i, j int) bool {
+		if (tenants[i].ID == DefaultTenantID) != (tenants[j].ID == DefaultTenantID) {
+			return tenants[i].ID == DefaultTenantID
+		}
+		return tenants[i].ID < tenants[j].ID
+	})
+	return tenants
+}
+
+// TenantByID returns a configured tenant
+func TenantByID(id string) (*Tenant, error) {
+	tenantsMu.RLock()
+	defer tenantsMu.RUnlock()
+	tenant, ok := tenantsByID[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTenant, id)
+	}
+	return tenant, nil
+}
+
+// TenantByHost returns the tenant serving a hostname, ignoring any port, or the default
+// tenant when none claims it
+func TenantByHost(host string) *Tenant {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	tenantsMu.RLock()
+	defer tenantsMu.RUnlock()
+	if tenant, ok := tenantsByHost[strings.ToLower(host)]; ok {
+		return tenant
+	}
+	return tenantsByID[DefaultTenantID]
+}
+
+type tenantContextKey struct{}
+
+// WithTenant returns a copy of ctx whose storage and dataset reads go to tenant
+func WithTenant(ctx context.Context, tenant *Tenant) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant ctx acts for, the default tenant when none was set
+func TenantFromContext(ctx context.Context) *Tenant {
+	if tenant, ok := ctx.Value(tenantContextKey{}).(*Tenant); ok && tenant != nil {
+		return tenant
+	}
+	tenantsMu.RLock()
+	defer tenantsMu.RUnlock()
+	return tenantsByID[DefaultTenantID]
+}
+
+// tenantKey scopes an ID to the tenant ctx acts for, for in-memory maps shared by tenants
+func tenantKey(ctx context.Context, id string) string {
+	return TenantFromContext(ctx).ID + "/" + id
+}
+
+// ForEachTenant runs fn once per tenant with a context acting for it, for background
+// jobs that sweep every tenant's data
+func ForEachTenant(ctx context.Context, fn func(ctx context.Context)) {
+	for _, tenant := range Tenants() {
+		fn(WithTenant(ctx, tenant))
+	}
+}
+
+// RequiresAPIKey reports whether callers must present one of the tenant's API keys
+func (t *Tenant) RequiresAPIKey() bool {
+	return len(t.APIKeys) > 0
+}
+
+// CheckAPIKey reports whether key is one of the tenant's API keys
+func (t *Tenant) CheckAPIKey(key string) bool {
+	digest := sha256.Sum256([]byte(key))
+	provided := []byte(hex.EncodeToString(digest[:]))
+	match := 0
+	for _, accepted := range t.APIKeys {
+		match |= subtle.ConstantTimeCompare(provided, []byte(accepted))
+	}
+	return key != "" && match == 1
+}
+
+// tenantObjectName places an object under the storage prefix of the tenant ctx acts for
+func tenantObjectName(ctx context.Context, objectName string) string {
+	if prefix := TenantFromContext(ctx).StoragePrefix; prefix != "" {
+		return prefix + "/" + objectName
+	}
+	return objectName
+}
+
+// tenantObjectStore keeps each tenant's objects under its storage prefix, so callers name
+// objects the same way whichever tenant they act for
+type tenantObjectStore struct {
+	store ObjectStore
+}
+
+func (s tenantObjectStore) UploadFile(ctx context.Context, objectName string, data []byte, contentType string) error {
+	return s.store.UploadFile(ctx, tenantObjectName(ctx, objectName), data, contentType)
+}
+
+func (s tenantObjectStore) UploadFileFromPath(ctx context.Context, localPath, objectName string) error {
+	return s.store.UploadFileFromPath(ctx, localPath, tenantObjectName(ctx, objectName))
+}
+
+func (s tenantObjectStore) DownloadFile(ctx context.Context, objectName string) ([]byte, error) {
+	return s.store.DownloadFile(ctx, tenantObjectName(ctx, objectName))
+}
+
+func (s tenantObjectStore) NewRangeReader(ctx context.Context, objectName string, offset, length int64) (io.ReadCloser, error) {
+	return s.store.NewRangeReader(ctx, tenantObjectName(ctx, objectName), offset, length)
+}
+
+func (s tenantObjectStore) GetFileInfo(ctx context.Context, objectName string) (*FileInfo, error) {
+	info, err := s.store.GetFileInfo(ctx, tenantObjectName(ctx, objectName))
+	if info != nil {
+		info.Name = objectName
+	}
+	return info, err
+}
+
+func (s tenantObjectStore) ListFiles(ctx context.Context, prefix string) ([]FileInfo, error) {
+	tenantPrefix := tenantObjectName(ctx, "")
+	files, err := s.store.ListFiles(ctx, tenantPrefix+prefix)
+	for i := range files {
+		files[i].Name = strings.TrimPrefix(files[i].Name, tenantPrefix)
+	}
+	return files, err
+}
+
+func (s tenantObjectStore) DeleteFile(ctx context.Context, objectName string) error {
+	return s.store.DeleteFile(ctx, tenantObjectName(ctx, objectName))
+}
+
+func (s tenantObjectStore) FileExists(ctx context.Context, objectName string) (bool, error) {
+	return s.store.FileExists(ctx, tenantObjectName(ctx, objectName))
+}
+
+func (s tenantObjectStore) GenerateSignedURL(ctx context.Context, objectName string, expiration time.Duration) (string, error) {
+	return s.store.GenerateSignedURL(ctx, tenantObjectName(ctx, objectName), expiration)
+}
+
+func (s tenantObjectStore) GenerateSignedURLWithParams(ctx context.Context, objectName string, expiration time.Duration, params url.Values) (string, error) {
+	return s.store.GenerateSignedURLWithParams(ctx, tenantObjectName(ctx, objectName), expiration, params)
+}
+
+func (s tenantObjectStore) UploadJSON(ctx context.Context, objectName string, data interface{}) error {
+	return s.store.UploadJSON(ctx, tenantObjectName(ctx, objectName), data)
+}
+
+func (s tenantObjectStore) DownloadJSON(ctx context.Context, objectName string, target interface{}) error {
+	return s.store.DownloadJSON(ctx, tenantObjectName(ctx, objectName), target)
+}
+
+// tenantWindow counts a tenant's requests in the current minute and day
+type tenantWindow struct {
+	minute      time.Time
+	minuteCount int
+	day         time.Time
+	dayCount    int
+}
+
+var (
+	tenantUsageMu sync.Mutex
+	tenantUsage   = map[string]*tenantWindow{}
+)
+
+// AllowTenantRequest counts a request against the tenant's quota. When the quota is spent
+// it returns false and how long until the window resets. Counts are per instance.
+func AllowTenantRequest(tenant *Tenant, now time.Time) (bool, time.Duration) {
+	if tenant.Quota.RequestsPerMinute <= 0 && tenant.Quota.RequestsPerDay <= 0 {
+		return true, 0
+	}
+	now = now.UTC()
+	minute := now.Truncate(time.Minute)
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	tenantUsageMu.Lock()
+	defer tenantUsageMu.Unlock()
+	usage, ok := tenantUsage[tenant.ID]
+	if !ok {
+		usage = &tenantWindow{}
+		tenantUsage[tenant.ID] = usage
+	}
+	if !usage.minute.Equal(minute) {
+		usage.minute, usage.minuteCount = minute, 0
+	}
+	if !usage.day.Equal(day) {
+		usage.day, usage.dayCount = day, 0
+	}
+
+	if limit := tenant.Quota.RequestsPerDay; limit > 0 && usage.dayCount >= limit {
+		return false, day.Add(24 * time.Hour).Sub(now)
+	}
+	if limit := tenant.Quota.RequestsPerMinute; limit > 0 && usage.minuteCount >= limit {
+		return false, minute.Add(time.Minute).Sub(now)
+	}
+	usage.minuteCount++
+	usage.dayCount++
+	return true, 0
+}
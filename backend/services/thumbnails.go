@@ -0,0 +1,213 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // registers the GIF decoder with image.Decode
+	"image/jpeg"
+	_ "image/png" // registers the PNG decoder with image.Decode
+	"io"
+	"time"
+)
+
+// Preview sizes, by the length of the longest edge in pixels
+const (
+	ImageSizeThumb  = "thumb"
+	ImageSizeMedium = "medium"
+)
+
+var imageSizes = map[string]int{
+	ImageSizeThumb:  200,
+	ImageSizeMedium: 800,
+}
+
+// imagesCollection maps image IDs to the objects they were uploaded as
+const imagesCollection = "images"
+
+// previewQuality is the JPEG quality of generated previews
+const previewQuality = 80
+
+// ErrInvalidImageSize is returned for a preview size other than thumb or medium
+var ErrInvalidImageSize = errors.New("size must be thumb or medium")
+
+// ImageSource is an original image previews can be generated from
+type ImageSource struct {
+	ID          string    `json:"id"`
+	Object      string    `json:"object"`
+	ContentType string    `json:"content_type"`
+	Origin      string    `json:"origin"` // what the image belongs to, such as an attachment
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ImagePreview is a resized image, or the original when it cannot be resized
+type ImagePreview struct {
+	ContentType string
+	ModTime     time.Time
+	Content     io.ReadSeekCloser
+}
+
+// ImagePreviewURLs lists the preview URLs of an image, by size
+func ImagePreviewURLs(id string) map[string]string {
+	urls := make(map[string]string, len(imageSizes))
+	for size := range imageSizes {
+		urls[size] = fmt.Sprintf("/api/v1/images/%s?size=%s", id, size)
+	}
+	return urls
+}
+
+// RegisterImage records a stored image so previews can be served for it
+func RegisterImage(ctx context.Context, source ImageSource) error {
+	if source.CreatedAt.IsZero() {
+		source.CreatedAt = time.Now().UTC()
+	}
+	return saveDocument(ctx, imagesCollection, source.ID, source)
+}
+
+// UnregisterImage forgets an image and deletes its cached previews
+func UnregisterImage(ctx context.Context, id string) {
+	for size := range imageSizes {
+		blobStore().DeleteFile(ctx, previewObject(id, size))
+	}
+	deleteDocument(ctx, imagesCollection, id)
+}
+
+// previewObject is where a preview is cached in the object store
+func previewObject(id, size string) string {
+	return fmt.Sprintf("thumbnails/%s/%s.jpg", id, size)
+}
+
+// GetImagePreview returns a preview of an image at the given size, generating and caching
+// it on first request. JPEG, PNG and GIF images are resized; other formats are served as
+// uploaded.
+func GetImagePreview(ctx context.Context, id, size string) (*ImagePreview, error) {
+	edge, ok := imageSizes[size]
+	if !ok {
+		return nil, ErrInvalidImageSize
+	}
+
+	var source ImageSource
+	if err := loadDocument(ctx, imagesCollection, id, &source); err != nil {
+		return nil, err
+	}
+
+	store := blobStore()
+	object := previewObject(id, size)
+
+	// One request renders a preview while others for it wait, then read the cached copy
+	unlock := lockDocument("thumbnails", id+"/"+size)
+	defer unlock()
+
+	if info, err := store.GetFileInfo(ctx, object); err == nil {
+		return openPreview(ctx, store, object, "image/jpeg", info)
+	}
+
+	original, err := store.DownloadFile(ctx, source.Object)
+	if err != nil {
+		return nil, fmt.Errorf("image %s: %w", id, ErrDocumentNotFound)
+	}
+	preview, err := resizeImage(original, edge)
+	if err != nil {
+		// Formats the standard library cannot decode, such as WebP, are served as they are
+		info, err := store.GetFileInfo(ctx, source.Object)
+		if err != nil {
+			return nil, fmt.Errorf("image %s: %w", id, ErrDocumentNotFound)
+		}
+		return openPreview(ctx, store, source.Object, source.ContentType, info)
+	}
+
+	if err := store.UploadFile(ctx, object, preview, "image/jpeg"); err != nil {
+		return nil, fmt.Errorf("failed to cache preview: %w", err)
+	}
+	info, err := store.GetFileInfo(ctx, object)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cache preview: %w", err)
+	}
+	return openPreview(ctx, store, object, "image/jpeg", info)
+}
+
+func openPreview(ctx context.Context, store ObjectStore, object, contentType string, info *FileInfo) (*ImagePreview, error) {
+	return &ImagePreview{
+		ContentType: contentType,
+		ModTime:     info.Updated,
+		Content:     &objectReader{ctx: ctx, store: store, object: object, size: info.Size},
+	}, nil
+}
+
+// resizeImage scales an image down so its longest edge is at most edge pixels, and encodes
+// it as a JPEG on a white background. Smaller images are re-encoded at their own size.
+func resizeImage(data []byte, edge int) ([]byte, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if config.Width*config.Height > maxAttachmentPixels {
+		return nil, fmt.Errorf("image is larger than %d megapixels", maxAttachmentPixels/1_000_000)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > edge || h > edge {
+		if w >= h {
+			w, h = edge, max(1, h*edge/w)
+		} else {
+			w, h = max(1, w*edge/h), edge
+		}
+	}
+
+	// Flatten transparency onto white, since JPEG has no alpha
+	flat := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, b.Min, draw.Over)
+
+	var out bytes.Buffer
+	if err := jpeg.Encode(&out, downscale(flat, w, h), &jpeg.Options{Quality: previewQuality}); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// downscale shrinks an image to w×h by averaging the source pixels each output pixel
+// covers, which avoids the aliasing of nearest-neighbour sampling
+func downscale(src *image.RGBA, w, h int) *image.RGBA {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	if sw == w && sh == h {
+		return src
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0, y1 := y*sh/h, max((y+1)*sh/h, y*sh/h+1)
+		for x := 0; x < w; x++ {
+			x0, x1 := x*sw/w, max((x+1)*sw/w, x*sw/w+1)
+
+			var r, g, bl, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r += uint32(p[0])
+					g += uint32(p[1])
+					bl += uint32(p[2])
+					a += uint32(p[3])
+					n++
+				}
+			}
+			i := y*dst.Stride + x*4
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(bl / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
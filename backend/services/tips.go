@@ -2,10 +2,11 @@ package services
 
 //COMPLETED
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"os"
 	"strings"
+	"sync"
 )
 
 // TipsData represents the structure of tips.json
@@ -22,6 +23,7 @@ type Tip struct {
 	Priority    string   `json:"priority"`
 	Tags        []string `json:"tags"`
 	Examples    []string `json:"examples,omitempty"`
+	Author      string   `json:"author,omitempty"` // set on tips travelers contributed
 }
 
 // Emergency represents emergency information
@@ -39,16 +41,17 @@ type Language struct {
 	CommonPhrases []interface{} `json:"common_phrases"`
 }
 
-// Global tips data cache
-var tipsData *TipsData
+// Tips data cache, per tenant since tenants may bring their own tips
+var tipsData sync.Map // tenant ID -> *TipsData
 
 // loadTipsData loads tips data from JSON file
-func loadTipsData() (*TipsData, error) {
-	if tipsData != nil {
-		return tipsData, nil
+func loadTipsData(ctx context.Context) (*TipsData, error) {
+	tenant := TenantFromContext(ctx).ID
+	if cached, ok := tipsData.Load(tenant); ok {
+		return cached.(*TipsData), nil
 	}
 
-	data, err := os.ReadFile("data/tips.json")
+	data, err := ReadDataset(ctx, TipsDataset)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read tips.json: %w", err)
 	}
@@ -67,19 +70,20 @@ func loadTipsData() (*TipsData, error) {
 	// Extract city-specific data
 	cities := make(map[string]map[string]interface{})
 	for key, value := range rawData {
-		if key != "general_canada" {
+		if key != "general_canada" && key != "schema_version" {
 			if cityData, ok := value.(map[string]interface{}); ok {
 				cities[key] = cityData
 			}
 		}
 	}
 
-	tipsData = &TipsData{
+	tips := &TipsData{
 		GeneralCanada: generalCanada,
 		Cities:        cities,
 	}
+	tipsData.Store(tenant, tips)
 
-	return tipsData, nil
+	return tips, nil
 }
 
 // mergeTips merges general and city-specific tips with deduplication
@@ -110,6 +114,16 @@ func mergeTips(generalTips []Tip, cityTips []Tip) []Tip {
 	return merged
 }
 
+// containsTipTitle reports whether a tip with the title is already in the list
+func containsTipTitle(tips []Tip, title string) bool {
+	for _, tip := range tips {
+		if strings.EqualFold(tip.Title, title) {
+			return true
+		}
+	}
+	return false
+}
+
 // extractTipsFromInterface extracts tips from interface{} data
 func extractTipsFromInterface(data interface{}, category string) ([]Tip, error) {
 	var tips []Tip
@@ -156,8 +170,8 @@ func extractTipsFromInterface(data interface{}, category string) ([]Tip, error)
 }
 
 // GetTravelTips gets travel tips for a destination
-func GetTravelTips(destination, category string, topics []string) ([]Tip, error) {
-	data, err := loadTipsData()
+func GetTravelTips(ctx context.Context, destination, category string, topics []string) ([]Tip, error) {
+	data, err := loadTipsData(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -180,6 +194,13 @@ func GetTravelTips(destination, category string, topics []string) ([]Tip, error)
 	// Merge tips with deduplication
 	mergedTips := mergeTips(generalTips, cityTips)
 
+	// Approved traveler tips follow, unless curated tips already cover them
+	for _, tip := range approvedUserTips(ctx, destination, category) {
+		if !containsTipTitle(mergedTips, tip.Title) {
+			mergedTips = append(mergedTips, tip)
+		}
+	}
+
 	// Filter by topics if provided
 	if len(topics) > 0 {
 		filteredTips := []Tip{}
@@ -200,8 +221,8 @@ func GetTravelTips(destination, category string, topics []string) ([]Tip, error)
 }
 
 // GetEmergencyInfo gets emergency information for a destination
-func GetEmergencyInfo(destination string) (Emergency, error) {
-	data, err := loadTipsData()
+func GetEmergencyInfo(ctx context.Context, destination string) (Emergency, error) {
+	data, err := loadTipsData(ctx)
 	if err != nil {
 		return Emergency{}, err
 	}
@@ -229,8 +250,8 @@ func GetEmergencyInfo(destination string) (Emergency, error) {
 }
 
 // GetLanguageInfo gets language information for a destination
-func GetLanguageInfo(destination string) (Language, error) {
-	data, err := loadTipsData()
+func GetLanguageInfo(ctx context.Context, destination string) (Language, error) {
+	data, err := loadTipsData(ctx)
 	if err != nil {
 		return Language{}, err
 	}
@@ -277,13 +298,13 @@ func GetLanguageInfo(destination string) (Language, error) {
 }
 
 // GetCulturalTips gets cultural tips for a destination
-func GetCulturalTips(destination string) ([]Tip, error) {
-	return GetTravelTips(destination, "cultural", nil)
+func GetCulturalTips(ctx context.Context, destination string) ([]Tip, error) {
+	return GetTravelTips(ctx, destination, "cultural", nil)
 }
 
 // GetTippingGuide gets tipping guide for a destination
-func GetTippingGuide(destination string) (map[string]interface{}, error) {
-	tips, err := GetTravelTips(destination, "tipping", nil)
+func GetTippingGuide(ctx context.Context, destination string) (map[string]interface{}, error) {
+	tips, err := GetTravelTips(ctx, destination, "tipping", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -302,11 +323,16 @@ func GetTippingGuide(destination string) (map[string]interface{}, error) {
 }
 
 // GetSafetyTips gets safety tips for a destination
-func GetSafetyTips(destination string) ([]Tip, error) {
-	return GetTravelTips(destination, "safety", nil)
+func GetSafetyTips(ctx context.Context, destination string) ([]Tip, error) {
+	return GetTravelTips(ctx, destination, "safety", nil)
+}
+
+// GetHealthTips gets health and travel insurance tips for a destination
+func GetHealthTips(ctx context.Context, destination string) ([]Tip, error) {
+	return GetTravelTips(ctx, destination, "health", nil)
 }
 
 // GetLocalCustoms gets local customs for a destination
-func GetLocalCustoms(destination string) ([]Tip, error) {
-	return GetTravelTips(destination, "customs", nil)
+func GetLocalCustoms(ctx context.Context, destination string) ([]Tip, error) {
+	return GetTravelTips(ctx, destination, "customs", nil)
 }
@@ -0,0 +1,382 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the instrumentation scope of the backend's own spans
+const TracerName = "github.com/joshndala/cantrip"
+
+// DefaultServiceName identifies the backend in traces unless OTEL_SERVICE_NAME is set
+const DefaultServiceName = "cantrip-backend"
+
+// tracer creates the backend's spans; it is a no-op until InitTracing installs a provider
+var tracer = otel.Tracer(TracerName)
+
+// InitTracing exports spans over OTLP/HTTP when OTEL_EXPORTER_OTLP_ENDPOINT or
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT is set, and installs the W3C trace context propagator
+// either way so incoming traceparent headers are continued. The returned function flushes
+// buffered spans.
+func InitTracing(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); endpoint == "" && base != "" {
+		endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+	}
+	if endpoint == "" || os.Getenv("OTEL_SDK_DISABLED") == "true" {
+		return func(context.Context) error { return nil }, nil
+	}
+	if _, err := url.ParseRequestURI(endpoint); err != nil {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q: %w", endpoint, err)
+	}
+
+	// Later detectors win, so OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the default
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", DefaultServiceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build trace resource: %w", err)
+	}
+
+	exporter := &otlpHTTPExporter{
+		Endpoint: endpoint,
+		Headers:  parseOTLPHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")),
+		// Plain client, so exports aren't traced themselves
+		HTTPClient: &http.Client{Timeout: UpstreamTimeout},
+	}
+	// The sampler follows OTEL_TRACES_SAMPLER, sampling every trace by default
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// tracedTransport wraps an HTTP transport so outbound requests get client spans and carry
+// the traceparent header
+func tracedTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return otelhttp.NewTransport(base, otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
+		return r.Method + " " + r.URL.Host
+	}))
+}
+
+// startSpan starts a child span of the trace in ctx. Without a trace in ctx it starts
+// nothing, so background work doesn't produce a stream of one-span traces.
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan records err on the span, if any, and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// parseOTLPHeaders reads OTEL_EXPORTER_OTLP_HEADERS, a comma-separated list of
+// URL-encoded key=value pairs
+func parseOTLPHeaders(value string) map[string]string {
+	headers := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		key, val, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		key, _ = url.QueryUnescape(strings.TrimSpace(key))
+		val, _ = url.QueryUnescape(strings.TrimSpace(val))
+		if key != "" {
+			headers[key] = val
+		}
+	}
+	return headers
+}
+
+// otlpHTTPExporter sends spans to an OTLP/HTTP collector in the protocol's JSON encoding
+type otlpHTTPExporter struct {
+	Endpoint   string
+	Headers    map[string]string
+	HTTPClient *http.Client
+}
+
+func (e *otlpHTTPExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	payload, err := json.Marshal(otlpTraceRequest(spans))
+	if err != nil {
+		return fmt.Errorf("failed to encode spans: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create OTLP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := e.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to export spans: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("OTLP collector returned status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (e *otlpHTTPExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// otlpTraceRequest builds an ExportTraceServiceRequest. Spans come from the one tracer
+// provider, so they share a resource and are grouped by instrumentation scope.
+func otlpTraceRequest(spans []sdktrace.ReadOnlySpan) map[string]interface{} {
+	var scopes []interface{}
+	index := map[string]int{}
+	for _, span := range spans {
+		scope := span.InstrumentationScope()
+		key := scope.Name + "@" + scope.Version
+		i, ok := index[key]
+		if !ok {
+			i = len(scopes)
+			index[key] = i
+			scopes = append(scopes, map[string]interface{}{
+				"scope": map[string]interface{}{"name": scope.Name, "version": scope.Version},
+				"spans": []interface{}{},
+			})
+		}
+		group := scopes[i].(map[string]interface{})
+		group["spans"] = append(group["spans"].([]interface{}), otlpSpan(span))
+	}
+
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource":   map[string]interface{}{"attributes": otlpAttributes(spans[0].Resource().Attributes())},
+			"scopeSpans": scopes,
+		}},
+	}
+}
+
+func otlpSpan(span sdktrace.ReadOnlySpan) map[string]interface{} {
+	out := map[string]interface{}{
+		"traceId":           span.SpanContext().TraceID().String(),
+		"spanId":            span.SpanContext().SpanID().String(),
+		"name":              span.Name(),
+		"kind":              int(span.SpanKind()), // the API's kinds share OTLP's numbering
+		"startTimeUnixNano": otlpTime(span.StartTime()),
+		"endTimeUnixNano":   otlpTime(span.EndTime()),
+		"attributes":        otlpAttributes(span.Attributes()),
+	}
+	if span.Parent().IsValid() {
+		out["parentSpanId"] = span.Parent().SpanID().String()
+	}
+
+	// OTLP numbers status codes UNSET, OK, ERROR where the API has Unset, Error, Ok
+	switch status := span.Status(); status.Code {
+	case codes.Ok:
+		out["status"] = map[string]interface{}{"code": 1}
+	case codes.Error:
+		out["status"] = map[string]interface{}{"code": 2, "message": status.Description}
+	}
+
+	var events []interface{}
+	for _, event := range span.Events() {
+		events = append(events, map[string]interface{}{
+			"timeUnixNano": otlpTime(event.Time),
+			"name":         event.Name,
+			"attributes":   otlpAttributes(event.Attributes),
+		})
+	}
+	if len(events) > 0 {
+		out["events"] = events
+	}
+	return out
+}
+
+func otlpTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func otlpAttributes(attrs []attribute.KeyValue) []interface{} {
+	out := make([]interface{}, 0, len(attrs))
+	for _, kv := range attrs {
+		out = append(out, map[string]interface{}{"key": string(kv.Key), "value": otlpValue(kv.Value)})
+	}
+	return out
+}
+
+func otlpValue(v attribute.Value) map[string]interface{} {
+	switch v.Type() {
+	case attribute.BOOL:
+		return map[string]interface{}{"boolValue": v.AsBool()}
+	case attribute.INT64:
+		return map[string]interface{}{"intValue": strconv.FormatInt(v.AsInt64(), 10)}
+	case attribute.FLOAT64:
+		return map[string]interface{}{"doubleValue": v.AsFloat64()}
+	case attribute.BOOLSLICE:
+		var values []interface{}
+		for _, b := range v.AsBoolSlice() {
+			values = append(values, otlpValue(attribute.BoolValue(b)))
+		}
+		return map[string]interface{}{"arrayValue": map[string]interface{}{"values": values}}
+	case attribute.INT64SLICE:
+		var values []interface{}
+		for _, i := range v.AsInt64Slice() {
+			values = append(values, otlpValue(attribute.Int64Value(i)))
+		}
+		return map[string]interface{}{"arrayValue": map[string]interface{}{"values": values}}
+	case attribute.FLOAT64SLICE:
+		var values []interface{}
+		for _, f := range v.AsFloat64Slice() {
+			values = append(values, otlpValue(attribute.Float64Value(f)))
+		}
+		return map[string]interface{}{"arrayValue": map[string]interface{}{"values": values}}
+	case attribute.STRINGSLICE:
+		var values []interface{}
+		for _, s := range v.AsStringSlice() {
+			values = append(values, otlpValue(attribute.StringValue(s)))
+		}
+		return map[string]interface{}{"arrayValue": map[string]interface{}{"values": values}}
+	default:
+		return map[string]interface{}{"stringValue": v.Emit()}
+	}
+}
+
+// tracedObjectStore records a span for each object store call made within a trace
+type tracedObjectStore struct {
+	store   ObjectStore
+	backend string
+}
+
+// traceObjectStore wraps store so its calls show up in request traces
+func traceObjectStore(store ObjectStore, backend string) ObjectStore {
+	return &tracedObjectStore{store: store, backend: backend}
+}
+
+func (s *tracedObjectStore) start(ctx context.Context, operation, objectName string) (context.Context, trace.Span) {
+	return startSpan(ctx, "objectstore."+operation,
+		attribute.String("objectstore.backend", s.backend),
+		attribute.String("objectstore.object", objectName),
+	)
+}
+
+func (s *tracedObjectStore) UploadFile(ctx context.Context, objectName string, data []byte, contentType string) error {
+	ctx, span := s.start(ctx, "upload", objectName)
+	span.SetAttributes(attribute.Int("objectstore.size", len(data)))
+	err := s.store.UploadFile(ctx, objectName, data, contentType)
+	endSpan(span, err)
+	return err
+}
+
+func (s *tracedObjectStore) UploadFileFromPath(ctx context.Context, localPath, objectName string) error {
+	ctx, span := s.start(ctx, "upload", objectName)
+	err := s.store.UploadFileFromPath(ctx, localPath, objectName)
+	endSpan(span, err)
+	return err
+}
+
+func (s *tracedObjectStore) DownloadFile(ctx context.Context, objectName string) ([]byte, error) {
+	ctx, span := s.start(ctx, "download", objectName)
+	data, err := s.store.DownloadFile(ctx, objectName)
+	endSpan(span, err)
+	return data, err
+}
+
+// NewRangeReader's span covers opening the object, not reading it
+func (s *tracedObjectStore) NewRangeReader(ctx context.Context, objectName string, offset, length int64) (io.ReadCloser, error) {
+	ctx, span := s.start(ctx, "open_range", objectName)
+	span.SetAttributes(attribute.Int64("objectstore.offset", offset), attribute.Int64("objectstore.length", length))
+	reader, err := s.store.NewRangeReader(ctx, objectName, offset, length)
+	endSpan(span, err)
+	return reader, err
+}
+
+func (s *tracedObjectStore) GetFileInfo(ctx context.Context, objectName string) (*FileInfo, error) {
+	ctx, span := s.start(ctx, "stat", objectName)
+	info, err := s.store.GetFileInfo(ctx, objectName)
+	endSpan(span, err)
+	return info, err
+}
+
+func (s *tracedObjectStore) ListFiles(ctx context.Context, prefix string) ([]FileInfo, error) {
+	ctx, span := s.start(ctx, "list", prefix)
+	files, err := s.store.ListFiles(ctx, prefix)
+	span.SetAttributes(attribute.Int("objectstore.count", len(files)))
+	endSpan(span, err)
+	return files, err
+}
+
+func (s *tracedObjectStore) DeleteFile(ctx context.Context, objectName string) error {
+	ctx, span := s.start(ctx, "delete", objectName)
+	err := s.store.DeleteFile(ctx, objectName)
+	endSpan(span, err)
+	return err
+}
+
+func (s *tracedObjectStore) FileExists(ctx context.Context, objectName string) (bool, error) {
+	ctx, span := s.start(ctx, "exists", objectName)
+	exists, err := s.store.FileExists(ctx, objectName)
+	endSpan(span, err)
+	return exists, err
+}
+
+func (s *tracedObjectStore) GenerateSignedURL(ctx context.Context, objectName string, expiration time.Duration) (string, error) {
+	ctx, span := s.start(ctx, "sign_url", objectName)
+	signed, err := s.store.GenerateSignedURL(ctx, objectName, expiration)
+	endSpan(span, err)
+	return signed, err
+}
+
+func (s *tracedObjectStore) GenerateSignedURLWithParams(ctx context.Context, objectName string, expiration time.Duration, params url.Values) (string, error) {
+	ctx, span := s.start(ctx, "sign_url", objectName)
+	signed, err := s.store.GenerateSignedURLWithParams(ctx, objectName, expiration, params)
+	endSpan(span, err)
+	return signed, err
+}
+
+func (s *tracedObjectStore) UploadJSON(ctx context.Context, objectName string, data interface{}) error {
+	ctx, span := s.start(ctx, "upload", objectName)
+	err := s.store.UploadJSON(ctx, objectName, data)
+	endSpan(span, err)
+	return err
+}
+
+func (s *tracedObjectStore) DownloadJSON(ctx context.Context, objectName string, target interface{}) error {
+	ctx, span := s.start(ctx, "download", objectName)
+	err := s.store.DownloadJSON(ctx, objectName, target)
+	endSpan(span, err)
+	return err
+}
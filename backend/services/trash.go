@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/joshndala/cantrip/utils"
+)
+
+// TrashRetention is how long soft-deleted items stay restorable
+const TrashRetention = 30 * 24 * time.Hour
+
+// ErrInTrash is returned when a soft-deleted item is requested
+var ErrInTrash = errors.New("item is in the trash")
+
+// TrashItem summarizes a soft-deleted item
+type TrashItem struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"` // itinerary, packing
+	Title     string    `json:"title"`
+	DeletedAt time.Time `json:"deleted_at"`
+	PurgeAt   time.Time `json:"purge_at"`
+}
+
+// ListTrash lists soft-deleted items, optionally filtered by type
+func ListTrash(ctx context.Context, itemType string) ([]TrashItem, error) {
+	items := []TrashItem{}
+
+	if itemType == "" || itemType == "itinerary" {
+		ids, err := listDocumentIDs(ctx, ItineraryCollection)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			itinerary, err := loadItinerary(ctx, id)
+			if err != nil || itinerary.DeletedAt == nil {
+				continue
+			}
+			items = append(items, TrashItem{
+				ID:        id,
+				Type:      "itinerary",
+				Title:     fmt.Sprintf("Itinerary for %s", itinerary.Metadata.City),
+				DeletedAt: *itinerary.DeletedAt,
+				PurgeAt:   itinerary.DeletedAt.Add(TrashRetention),
+			})
+		}
+	}
+
+	if itemType == "" || itemType == "packing" {
+		ids, err := listDocumentIDs(ctx, PackingListCollection)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			packingList, err := loadPackingList(ctx, id)
+			if err != nil || packingList.DeletedAt == nil {
+				continue
+			}
+			items = append(items, TrashItem{
+				ID:        id,
+				Type:      "packing",
+				Title:     fmt.Sprintf("Packing list for %s", packingList.Destination),
+				DeletedAt: *packingList.DeletedAt,
+				PurgeAt:   packingList.DeletedAt.Add(TrashRetention),
+			})
+		}
+	}
+
+	// Most recently deleted first
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].DeletedAt.After(items[j].DeletedAt)
+	})
+
+	return items, nil
+}
+
+// RestoreItinerary takes an itinerary out of the trash
+func RestoreItinerary(ctx context.Context, id string) (*ItineraryResponse, error) {
+	itinerary, err := loadItinerary(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if itinerary.DeletedAt == nil {
+		return nil, fmt.Errorf("itinerary with ID '%s' is not in the trash", id)
+	}
+
+	itinerary.DeletedAt = nil
+	if err := SaveItinerary(ctx, itinerary); err != nil {
+		return nil, fmt.Errorf("failed to restore itinerary: %w", err)
+	}
+
+	return itinerary, nil
+}
+
+// RestorePackingList takes a packing list out of the trash
+func RestorePackingList(ctx context.Context, id string) (PackingResponse, error) {
+	packingList, err := loadPackingList(ctx, id)
+	if err != nil {
+		return PackingResponse{}, err
+	}
+	if packingList.DeletedAt == nil {
+		return PackingResponse{}, fmt.Errorf("packing list with ID '%s' is not in the trash", id)
+	}
+
+	packingList.DeletedAt = nil
+	if err := SavePackingList(ctx, &packingList); err != nil {
+		return PackingResponse{}, fmt.Errorf("failed to restore packing list: %w", err)
+	}
+
+	return packingList, nil
+}
+
+// PurgeExpiredTrash permanently deletes items that have been in the trash past the retention period
+func PurgeExpiredTrash(ctx context.Context) (int, error) {
+	items, err := ListTrash(ctx, "")
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	cutoff := time.Now().Add(-TrashRetention)
+	for _, item := range items {
+		if item.DeletedAt.After(cutoff) {
+			continue
+		}
+
+		collection := ItineraryCollection
+		if item.Type == "packing" {
+			collection = PackingListCollection
+		}
+
+		if err := deleteDocument(ctx, collection, item.ID); err != nil {
+			utils.LogError(fmt.Sprintf("Failed to purge %s %s", item.Type, item.ID), err)
+			continue
+		}
+		if item.Type == "itinerary" {
+			deleteItineraryRevisions(ctx, item.ID)
+		}
+
+		if err := RecordAudit(ctx, "system", AuditActionPurge, item.Type, item.ID, item, nil); err != nil {
+			utils.LogError("Failed to record purge audit entry", err)
+		}
+		purged++
+	}
+
+	return purged, nil
+}
+
+// StartTrashPurger runs PurgeExpiredTrash in the background at the given interval
+func StartTrashPurger(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			ForEachTenant(context.Background(), func(ctx context.Context) {
+				purged, err := PurgeExpiredTrash(ctx)
+				if err != nil {
+					utils.LogError("Trash purge failed for tenant "+TenantFromContext(ctx).ID, err)
+					return
+				}
+				if purged > 0 {
+					utils.LogInfo(fmt.Sprintf("Purged %d expired trash items for tenant %s", purged, TenantFromContext(ctx).ID))
+				}
+			})
+		}
+	}()
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/joshndala/cantrip/models"
+)
+
+// ErrInvalidTripDates is returned for trip dates the date policy doesn't accept
+var ErrInvalidTripDates = errors.New("invalid trip dates")
+
+// MaxTripDays is the longest trip that can be planned
+const MaxTripDays = 90
+
+// TripSpan is how long a trip lasts. Both ends count as days: a trip from the 15th to the
+// 17th is 3 days and 2 nights, and one that starts and ends on the same day is 1 day and
+// no nights.
+type TripSpan struct {
+	Days   int
+	Nights int
+}
+
+// tripSpan measures a trip between YYYY-MM-DD dates, rejecting an end before the start and
+// trips longer than MaxTripDays
+func tripSpan(startDate, endDate string) (TripSpan, error) {
+	start, err := time.Parse(models.DateLayout, startDate)
+	if err != nil {
+		return TripSpan{}, fmt.Errorf("%w: start date must be YYYY-MM-DD", ErrInvalidTripDates)
+	}
+	end, err := time.Parse(models.DateLayout, endDate)
+	if err != nil {
+		return TripSpan{}, fmt.Errorf("%w: end date must be YYYY-MM-DD", ErrInvalidTripDates)
+	}
+	nights := models.DaysBetween(start, end)
+	if nights < 0 {
+		return TripSpan{}, fmt.Errorf("%w: end date must not be before start date", ErrInvalidTripDates)
+	}
+	if nights+1 > MaxTripDays {
+		return TripSpan{}, fmt.Errorf("%w: trips can be at most %d days", ErrInvalidTripDates, MaxTripDays)
+	}
+	return TripSpan{Days: nights + 1, Nights: nights}, nil
+}
+
+// PastTripsAllowed reports whether ALLOW_PAST_TRIPS=true lets travelers plan trips that
+// have already started or ended, such as to journal past travel
+func PastTripsAllowed() bool {
+	return os.Getenv("ALLOW_PAST_TRIPS") == "true"
+}
+
+// CityToday is the current date in a city's timezone, as YYYY-MM-DD
+func CityToday(ctx context.Context, city string) string {
+	return time.Now().In(cityLocation(ctx, city)).Format(models.DateLayout)
+}
+
+// CheckTripDates applies the trip date policy. The end can't come before the start, the
+// trip can't run past MaxTripDays, and unless past trips are allowed the start can't be
+// before today in the destination's timezone, so a trip starting today is accepted
+// wherever the traveler is sending it from. An edit may keep a start date that has
+// passed, so an ongoing trip can still be changed; keptStart is the trip's current start
+// date, or empty for a new trip.
+func CheckTripDates(ctx context.Context, city string, start, end models.Date, keptStart string) error {
+	if start.IsZero() || end.IsZero() {
+		return fmt.Errorf("%w: start and end dates are required", ErrInvalidTripDates)
+	}
+	if _, err := tripSpan(start.String(), end.String()); err != nil {
+		return err
+	}
+	if PastTripsAllowed() {
+		return nil
+	}
+
+	today := CityToday(ctx, city)
+	if end.String() < today {
+		return fmt.Errorf("%w: the trip ended before today (%s in %s)", ErrInvalidTripDates, today, city)
+	}
+	if start.String() < today && start.String() != keptStart {
+		return fmt.Errorf("%w: start date cannot be before today (%s in %s)", ErrInvalidTripDates, today, city)
+	}
+	return nil
+}
+
+// TripStartDate is the day an itinerary starts, as YYYY-MM-DD, or empty when it has no dates
+func TripStartDate(itinerary *ItineraryResponse) string {
+	if itinerary == nil {
+		return ""
+	}
+	startDate, _ := itinerary.Itinerary["start_date"].(string)
+	return startDate
+}
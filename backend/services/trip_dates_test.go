@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/joshndala/cantrip/models"
+)
+
+func TestTripSpanAcrossDST(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end string
+		want       TripSpan
+		wantErr    bool
+	}{
+		// Clocks in Canada jump forward on 2026-03-08 and back on 2026-11-01
+		{name: "over the spring change", start: "2026-03-07", end: "2026-03-09", want: TripSpan{Days: 3, Nights: 2}},
+		{name: "over the fall change", start: "2026-10-31", end: "2026-11-02", want: TripSpan{Days: 3, Nights: 2}},
+		{name: "on the spring change", start: "2026-03-08", end: "2026-03-08", want: TripSpan{Days: 1}},
+		{name: "on the fall change", start: "2026-11-01", end: "2026-11-01", want: TripSpan{Days: 1}},
+		{name: "starting on the spring change", start: "2026-03-08", end: "2026-03-09", want: TripSpan{Days: 2, Nights: 1}},
+		{name: "ending on the fall change", start: "2026-10-31", end: "2026-11-01", want: TripSpan{Days: 2, Nights: 1}},
+		{name: "longest trip over the spring change", start: "2026-03-01", end: "2026-05-29", want: TripSpan{Days: MaxTripDays, Nights: MaxTripDays - 1}},
+		{name: "one day too long over the spring change", start: "2026-03-01", end: "2026-05-30", wantErr: true},
+		{name: "longest trip over the fall change", start: "2026-09-15", end: "2026-12-13", want: TripSpan{Days: MaxTripDays, Nights: MaxTripDays - 1}},
+		{name: "end before start over the fall change", start: "2026-11-02", end: "2026-10-31", wantErr: true},
+		{name: "not a date", start: "2026-03-08T02:30", end: "2026-03-09", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tripSpan(tt.start, tt.end)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidTripDates) {
+					t.Errorf("tripSpan(%s, %s) error = %v, want ErrInvalidTripDates", tt.start, tt.end, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("tripSpan(%s, %s): %v", tt.start, tt.end, err)
+			}
+			if got != tt.want {
+				t.Errorf("tripSpan(%s, %s) = %+v, want %+v", tt.start, tt.end, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckTripDates(t *testing.T) {
+	ctx := context.Background()
+	const city = "Toronto"
+	today, err := time.Parse(models.DateLayout, CityToday(ctx, city))
+	if err != nil {
+		t.Fatalf("CityToday: %v", err)
+	}
+	day := func(offset int) models.Date { return models.NewDate(today.AddDate(0, 0, offset)) }
+
+	tests := []struct {
+		name        string
+		start, end  models.Date
+		keptStart   string
+		pastAllowed bool
+		wantErr     bool
+	}{
+		{name: "starting today", start: day(0), end: day(2)},
+		{name: "starting later", start: day(30), end: day(33)},
+		{name: "started yesterday", start: day(-1), end: day(2), wantErr: true},
+		{name: "keeping a start that passed", start: day(-1), end: day(2), keptStart: day(-1).String()},
+		{name: "moving a passed start earlier", start: day(-2), end: day(2), keptStart: day(-1).String(), wantErr: true},
+		{name: "moving a kept start to today", start: day(0), end: day(2), keptStart: day(-1).String()},
+		{name: "ended yesterday though kept", start: day(-3), end: day(-1), keptStart: day(-3).String(), wantErr: true},
+		{name: "past trip when allowed", start: day(-10), end: day(-8), pastAllowed: true},
+		{name: "end before start when allowed", start: day(-8), end: day(-10), pastAllowed: true, wantErr: true},
+		{name: "too long", start: day(0), end: day(MaxTripDays), wantErr: true},
+		{name: "missing end", start: day(0), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.pastAllowed {
+				t.Setenv("ALLOW_PAST_TRIPS", "true")
+			} else {
+				t.Setenv("ALLOW_PAST_TRIPS", "")
+			}
+			err := CheckTripDates(ctx, city, tt.start, tt.end, tt.keptStart)
+			if tt.wantErr && !errors.Is(err, ErrInvalidTripDates) {
+				t.Errorf("CheckTripDates(%s, %s, kept %q) error = %v, want ErrInvalidTripDates", tt.start, tt.end, tt.keptStart, err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("CheckTripDates(%s, %s, kept %q): %v", tt.start, tt.end, tt.keptStart, err)
+			}
+		})
+	}
+}
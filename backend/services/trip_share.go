@@ -0,0 +1,265 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/joshndala/cantrip/utils"
+)
+
+// tripShareCollection stores share links, keyed by token
+const tripShareCollection = "trip_shares"
+
+// MaxShareExpiryHours is the longest a share link can be set to last, 90 days
+const MaxShareExpiryHours = 90 * 24
+
+// ErrShareNotFound is returned for a share token that doesn't exist, has expired or whose
+// trip is gone
+var ErrShareNotFound = errors.New("shared trip not found")
+
+// ErrInvalidShare is returned for share options the trip can't satisfy
+var ErrInvalidShare = errors.New("invalid share")
+
+// TripShare is a read-only link to a trip. The token is its only credential.
+type TripShare struct {
+	Token        string     `json:"token"`
+	TripID       string     `json:"trip_id"`
+	CreatedBy    string     `json:"created_by,omitempty"`
+	CoverPhotoID string     `json:"cover_photo_id,omitempty"` // a photo attachment shown when the link unfurls
+	CreatedAt    time.Time  `json:"created_at"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"` // nil for links that last until revoked
+}
+
+// TripShareInput are the options for a new share link
+type TripShareInput struct {
+	ExpiresInHours int    `json:"expires_in_hours,omitempty"` // 0 for no expiry
+	CoverPhotoID   string `json:"cover_photo_id,omitempty"`
+}
+
+// sharePagePath is where a share link's public page is served
+func sharePagePath(token string) string {
+	return "/share/trip/" + token
+}
+
+// CreateTripShare creates a read-only link to a trip
+func CreateTripShare(ctx context.Context, itinerary *ItineraryResponse, createdBy string, input TripShareInput) (*TripShare, error) {
+	if input.ExpiresInHours < 0 || input.ExpiresInHours > MaxShareExpiryHours {
+		return nil, fmt.Errorf("%w: expires_in_hours must be between 0 and %d", ErrInvalidShare, MaxShareExpiryHours)
+	}
+	if input.CoverPhotoID != "" {
+		attachment, err := GetAttachment(ctx, itinerary.ID, input.CoverPhotoID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: photo %s is not attached to the trip", ErrInvalidShare, input.CoverPhotoID)
+		}
+		if attachment.Kind != AttachmentPhoto || attachment.Quarantined {
+			return nil, fmt.Errorf("%w: attachment %s is not a photo", ErrInvalidShare, input.CoverPhotoID)
+		}
+	}
+
+	share := &TripShare{
+		Token:        utils.GenerateID(),
+		TripID:       itinerary.ID,
+		CreatedBy:    createdBy,
+		CoverPhotoID: input.CoverPhotoID,
+		CreatedAt:    time.Now().UTC(),
+	}
+	if input.ExpiresInHours > 0 {
+		expiresAt := share.CreatedAt.Add(time.Duration(input.ExpiresInHours) * time.Hour)
+		share.ExpiresAt = &expiresAt
+	}
+	if err := saveDocument(ctx, tripShareCollection, share.Token, share); err != nil {
+		return nil, fmt.Errorf("failed to save share: %w", err)
+	}
+	return share, nil
+}
+
+// ResolveTripShare returns a live share link and the trip it shows
+func ResolveTripShare(ctx context.Context, token string) (*TripShare, *ItineraryResponse, error) {
+	var share TripShare
+	if err := loadDocument(ctx, tripShareCollection, token, &share); err != nil {
+		if errors.Is(err, ErrDocumentNotFound) {
+			return nil, nil, ErrShareNotFound
+		}
+		return nil, nil, err
+	}
+	if share.ExpiresAt != nil && time.Now().After(*share.ExpiresAt) {
+		return nil, nil, ErrShareNotFound
+	}
+	// Trips in the trash aren't shown
+	itinerary, err := GetItinerary(ctx, share.TripID)
+	if err != nil {
+		return nil, nil, ErrShareNotFound
+	}
+	return &share, itinerary, nil
+}
+
+// RevokeTripShare deletes one of a trip's share links
+func RevokeTripShare(ctx context.Context, tripID, token string) (*TripShare, error) {
+	var share TripShare
+	if err := loadDocument(ctx, tripShareCollection, token, &share); err != nil {
+		return nil, err
+	}
+	if share.TripID != tripID {
+		return nil, fmt.Errorf("share %s on trip %s: %w", token, tripID, ErrDocumentNotFound)
+	}
+	if err := deleteDocument(ctx, tripShareCollection, token); err != nil {
+		return nil, fmt.Errorf("failed to delete share: %w", err)
+	}
+	return &share, nil
+}
+
+// SharedTripPage is a shared trip arranged for its public page
+type SharedTripPage struct {
+	Title       string
+	Description string
+	URL         string // absolute URL of the page
+	ImageURL    string // absolute URL of the cover photo, if any
+	City        string
+	Dates       string
+	Summary     string
+	Days        []SharedTripDay
+}
+
+// SharedTripDay is one day of a shared trip
+type SharedTripDay struct {
+	Day        int
+	Date       string
+	Activities []SharedTripActivity
+	Notes      string
+}
+
+// SharedTripActivity is one activity of a shared trip
+type SharedTripActivity struct {
+	Time     string
+	Name     string
+	Location string
+}
+
+// BuildSharedTripPage arranges a shared trip for its page, with absolute URLs under
+// baseURL for the Open Graph tags. Only the plan is shown; owners, bookings and
+// attachments other than the cover stay private.
+func BuildSharedTripPage(share *TripShare, itinerary *ItineraryResponse, baseURL string) *SharedTripPage {
+	city, start, end, hasDates := itineraryTripDates(itinerary)
+	if city == "" {
+		city = itinerary.Metadata.City
+	}
+	plans, _ := itinerary.Itinerary["days"].([]interface{})
+	days := max(tripDayCount(itinerary), len(plans))
+
+	page := &SharedTripPage{
+		Title:   fmt.Sprintf("%d days in %s", days, city),
+		URL:     strings.TrimSuffix(baseURL, "/") + sharePagePath(share.Token),
+		City:    city,
+		Summary: stringField(itinerary.Itinerary, "summary", ""),
+	}
+	if days == 1 {
+		page.Title = "A day in " + city
+	}
+	if hasDates {
+		page.Dates = fmt.Sprintf("%s – %s", start.Format("January 2"), end.Format("January 2, 2006"))
+	}
+	if share.CoverPhotoID != "" {
+		page.ImageURL = strings.TrimSuffix(baseURL, "/") + ImagePreviewURLs(share.CoverPhotoID)[ImageSizeMedium]
+	}
+
+	var highlights []string
+	for d, raw := range plans {
+		plan, _ := raw.(map[string]interface{})
+		day := SharedTripDay{Day: d + 1, Notes: stringField(plan, "notes", "")}
+		day.Date, _ = plan["date"].(string)
+		activities, _ := plan["activities"].([]interface{})
+		for _, rawActivity := range activities {
+			activity, ok := rawActivity.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name := stringField(activity, "name", "")
+			if name == "" {
+				continue
+			}
+			day.Activities = append(day.Activities, SharedTripActivity{
+				Time:     stringField(activity, "start_time", ""),
+				Name:     name,
+				Location: stringField(activity, "location", ""),
+			})
+			if len(highlights) < 3 {
+				highlights = append(highlights, name)
+			}
+		}
+		page.Days = append(page.Days, day)
+	}
+
+	page.Description = page.Summary
+	if page.Description == "" {
+		page.Description = page.Title
+	}
+	if len(highlights) > 0 {
+		page.Description += ". Highlights: " + strings.Join(highlights, ", ")
+	}
+	return page
+}
+
+// RenderSharedTripHTML renders a shared trip's public page
+func RenderSharedTripHTML(page *SharedTripPage) ([]byte, error) {
+	tmpl, err := template.ParseFiles(filepath.Join(TemplatesDir, "shared_trip.html"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load shared trip template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, page); err != nil {
+		return nil, fmt.Errorf("failed to render shared trip: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// MaxEmbedHighlights is how many activities a trip card lists
+const MaxEmbedHighlights = 5
+
+// EmbedTrip is the trip card third-party sites embed from a share link: the plan's
+// outline only, with nothing about who made or owns it
+type EmbedTrip struct {
+	Title      string     `json:"title"`
+	City       string     `json:"city"`
+	StartDate  string     `json:"start_date,omitempty"`
+	EndDate    string     `json:"end_date,omitempty"`
+	Days       int        `json:"days"`
+	Summary    string     `json:"summary,omitempty"`
+	Highlights []string   `json:"highlights"`
+	ImageURL   string     `json:"image_url,omitempty"`
+	URL        string     `json:"url"` // the trip's public page
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+// BuildEmbedTrip trims a shared trip to its card
+func BuildEmbedTrip(share *TripShare, itinerary *ItineraryResponse, baseURL string) *EmbedTrip {
+	page := BuildSharedTripPage(share, itinerary, baseURL)
+	embed := &EmbedTrip{
+		Title:      page.Title,
+		City:       page.City,
+		Days:       max(tripDayCount(itinerary), len(page.Days)),
+		Summary:    page.Summary,
+		Highlights: []string{},
+		ImageURL:   page.ImageURL,
+		URL:        page.URL,
+		ExpiresAt:  share.ExpiresAt,
+	}
+	if _, start, end, ok := itineraryTripDates(itinerary); ok {
+		embed.StartDate = start.Format("2006-01-02")
+		embed.EndDate = end.Format("2006-01-02")
+	}
+	for _, day := range page.Days {
+		for _, activity := range day.Activities {
+			if len(embed.Highlights) < MaxEmbedHighlights {
+				embed.Highlights = append(embed.Highlights, activity.Name)
+			}
+		}
+	}
+	return embed
+}
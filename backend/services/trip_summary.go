@@ -0,0 +1,419 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joshndala/cantrip/utils"
+)
+
+// tripSummaryCollection stores the latest recap compiled for each trip
+const tripSummaryCollection = "trip_summaries"
+
+// MaxSummaryPhotos is the most journal photos printed in a recap document
+const MaxSummaryPhotos = 12
+
+// Spending categories, matching the cost breakdown
+const (
+	SpendActivities = "activities"
+	SpendMeals      = "meals"
+	SpendTransit    = "transit"
+	SpendRental     = "rental"
+	SpendLodging    = "lodging"
+	SpendOther      = "other"
+)
+
+// SpendCategories lists the categories expenses can be filed under
+var SpendCategories = []string{SpendActivities, SpendMeals, SpendTransit, SpendRental, SpendLodging, SpendOther}
+
+// ErrInvalidTripSummary is returned for a recap request with unknown activities or
+// malformed expenses
+var ErrInvalidTripSummary = errors.New("invalid trip summary")
+
+// TripExpense is money actually spent on the trip
+type TripExpense struct {
+	Category    string  `json:"category"`           // one of SpendCategories; other when empty
+	Amount      float64 `json:"amount"`             // for the whole group
+	Currency    string  `json:"currency,omitempty"` // the trip's currency when empty
+	Description string  `json:"description,omitempty"`
+}
+
+// TripSummaryRequest describes how the trip actually went
+type TripSummaryRequest struct {
+	// Completed names the planned activities that were done. When omitted, an activity
+	// counts as done if a journal entry for its day mentions it.
+	Completed []string      `json:"completed,omitempty"`
+	Unplanned []string      `json:"unplanned,omitempty"` // things done that weren't in the plan
+	Expenses  []TripExpense `json:"expenses,omitempty"`
+	Budget    float64       `json:"budget,omitempty"` // the planned total cost by default
+	Origin    string        `json:"origin,omitempty"` // home city or airport, to count the journey there and back
+	Mode      string        `json:"mode,omitempty"`   // how the journey from home was made; flight by default
+}
+
+// SummaryDay compares one day's plan with what was done
+type SummaryDay struct {
+	Day            int      `json:"day"`
+	Date           string   `json:"date,omitempty"`
+	Planned        []string `json:"planned"`
+	Completed      []string `json:"completed"`
+	Skipped        []string `json:"skipped"`
+	JournalEntries int      `json:"journal_entries"`
+}
+
+// SpendComparison is planned against actual spending in one category
+type SpendComparison struct {
+	Category   string  `json:"category"`
+	Planned    float64 `json:"planned"`
+	Actual     float64 `json:"actual"`
+	Difference float64 `json:"difference"` // actual less planned; positive is overspend
+}
+
+// DistanceLeg is one journey counted towards the distance traveled
+type DistanceLeg struct {
+	Mode       string  `json:"mode"`
+	From       string  `json:"from"`
+	To         string  `json:"to"`
+	DistanceKm float64 `json:"distance_km"`
+}
+
+// TripSummary is a post-trip recap
+type TripSummary struct {
+	TripID    string `json:"trip_id"`
+	Revision  int    `json:"revision"` // counts the times the recap has been compiled
+	City      string `json:"city"`
+	StartDate string `json:"start_date,omitempty"`
+	EndDate   string `json:"end_date,omitempty"`
+
+	Days                []SummaryDay `json:"days"`
+	PlannedActivities   int          `json:"planned_activities"`
+	CompletedActivities int          `json:"completed_activities"`
+	CompletionRate      float64      `json:"completion_rate"` // percent of planned activities done
+	Unplanned           []string     `json:"unplanned,omitempty"`
+
+	Currency   string            `json:"currency"`
+	Budget     float64           `json:"budget"`
+	Planned    float64           `json:"planned"`
+	Spent      float64           `json:"spent"`
+	Remaining  float64           `json:"remaining"` // negative when over budget
+	OverBudget bool              `json:"over_budget"`
+	Spending   []SpendComparison `json:"spending"`
+
+	DistanceKm float64       `json:"distance_km"`
+	Legs       []DistanceLeg `json:"legs"`
+
+	JournalEntries int      `json:"journal_entries"`
+	PhotoIDs       []string `json:"photo_ids"`
+
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// CompileTripSummary compares a trip's plan with how it went and stores the recap, which
+// GenerateTripSummaryPDF renders
+func CompileTripSummary(ctx context.Context, itinerary *ItineraryResponse, req TripSummaryRequest) (*TripSummary, error) {
+	entries, err := ListJournalEntries(ctx, itinerary.ID, 0)
+	if err != nil {
+		return nil, err
+	}
+	_, planned, err := RecalculateItineraryCosts(ctx, itinerary, CostRecalculation{})
+	if err != nil {
+		return nil, err
+	}
+	costs, err := loadCostOfLiving(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cost-of-living data: %w", err)
+	}
+
+	city, start, end, hasDates := itineraryTripDates(itinerary)
+	if city == "" {
+		city = itinerary.Metadata.City
+	}
+	summary := &TripSummary{
+		TripID:         itinerary.ID,
+		City:           city,
+		Days:           []SummaryDay{},
+		Unplanned:      req.Unplanned,
+		Currency:       planned.Currency,
+		Planned:        planned.Total,
+		Budget:         planned.Total,
+		Legs:           []DistanceLeg{},
+		JournalEntries: len(entries),
+		PhotoIDs:       []string{},
+		GeneratedAt:    time.Now().UTC(),
+	}
+	if hasDates {
+		summary.StartDate = start.Format("2006-01-02")
+		summary.EndDate = end.Format("2006-01-02")
+	}
+	if req.Budget < 0 || math.IsNaN(req.Budget) || math.IsInf(req.Budget, 0) {
+		return nil, fmt.Errorf("%w: budget must be a positive amount", ErrInvalidTripSummary)
+	}
+	if req.Budget > 0 {
+		summary.Budget = req.Budget
+	}
+
+	if err := summarizeActivities(summary, itinerary, entries, req.Completed); err != nil {
+		return nil, err
+	}
+	if err := summarizeSpending(summary, planned, costs, req.Expenses); err != nil {
+		return nil, err
+	}
+
+	emissions, err := EstimateTripEmissions(ctx, itinerary, EmissionsQuery{Origin: req.Origin, Mode: req.Mode})
+	if errors.Is(err, ErrInvalidEmissionsQuery) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTripSummary, err)
+	} else if err != nil {
+		return nil, err
+	}
+	for _, leg := range emissions.Legs {
+		summary.Legs = append(summary.Legs, DistanceLeg{Mode: leg.Mode, From: leg.From, To: leg.To, DistanceKm: leg.DistanceKm})
+		summary.DistanceKm += leg.DistanceKm
+	}
+
+	for _, entry := range entries {
+		summary.PhotoIDs = append(summary.PhotoIDs, entry.PhotoIDs...)
+	}
+
+	unlock := lockDocument(tripSummaryCollection, itinerary.ID)
+	defer unlock()
+	var previous TripSummary
+	if err := loadDocument(ctx, tripSummaryCollection, itinerary.ID, &previous); err != nil && !errors.Is(err, ErrDocumentNotFound) {
+		return nil, err
+	}
+	summary.Revision = previous.Revision + 1
+	if err := saveDocument(ctx, tripSummaryCollection, itinerary.ID, summary); err != nil {
+		return nil, fmt.Errorf("failed to save trip summary: %w", err)
+	}
+	// What was done and skipped tells recommendations what the traveler enjoyed
+	if err := recordTripOutcome(ctx, itinerary, summary); err != nil {
+		utils.LogError("Failed to record trip outcome", err)
+	}
+	return summary, nil
+}
+
+// GetTripSummary loads the latest recap compiled for a trip
+func GetTripSummary(ctx context.Context, tripID string) (*TripSummary, error) {
+	var summary TripSummary
+	if err := loadDocument(ctx, tripSummaryCollection, tripID, &summary); err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// summarizeActivities sorts each day's planned activities into completed and skipped
+func summarizeActivities(summary *TripSummary, itinerary *ItineraryResponse, entries []JournalEntry, completed []string) error {
+	plans, _ := itinerary.Itinerary["days"].([]interface{})
+
+	// Named activities are matched case-insensitively against the whole plan
+	done := map[string]bool{}
+	for _, name := range completed {
+		done[strings.ToLower(strings.TrimSpace(name))] = false
+	}
+	for d, plan := range plans {
+		day := SummaryDay{Day: d + 1, Planned: plannedActivityNames(plan), Completed: []string{}, Skipped: []string{}}
+		if date, _ := plan.(map[string]interface{})["date"].(string); date != "" {
+			day.Date = date
+		}
+
+		var journal []string
+		for _, entry := range entries {
+			if entry.Day == day.Day {
+				day.JournalEntries++
+				journal = append(journal, strings.ToLower(entry.Title+"\n"+entry.Text))
+			}
+		}
+
+		for _, name := range day.Planned {
+			key := strings.ToLower(name)
+			var isDone bool
+			if completed != nil {
+				_, isDone = done[key]
+				if isDone {
+					done[key] = true
+				}
+			} else {
+				for _, text := range journal {
+					if strings.Contains(text, key) {
+						isDone = true
+						break
+					}
+				}
+			}
+			if isDone {
+				day.Completed = append(day.Completed, name)
+			} else {
+				day.Skipped = append(day.Skipped, name)
+			}
+		}
+
+		summary.PlannedActivities += len(day.Planned)
+		summary.CompletedActivities += len(day.Completed)
+		summary.Days = append(summary.Days, day)
+	}
+
+	var unknown []string
+	for name, matched := range done {
+		if !matched && name != "" {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("%w: not planned activities, list them as unplanned: %s", ErrInvalidTripSummary, strings.Join(unknown, ", "))
+	}
+
+	if summary.PlannedActivities > 0 {
+		summary.CompletionRate = math.Round(float64(summary.CompletedActivities)/float64(summary.PlannedActivities)*1000) / 10
+	}
+	return nil
+}
+
+// summarizeSpending totals expenses by category, in the trip's currency, against the plan
+func summarizeSpending(summary *TripSummary, planned *CostBreakdown, costs *CostOfLiving, expenses []TripExpense) error {
+	actual := map[string]float64{}
+	for i, expense := range expenses {
+		category := strings.ToLower(firstNonEmpty(strings.TrimSpace(expense.Category), SpendOther))
+		if !utils.Contains(SpendCategories, category) {
+			return fmt.Errorf("%w: expense %d: category must be one of %s", ErrInvalidTripSummary, i+1, strings.Join(SpendCategories, ", "))
+		}
+		if expense.Amount < 0 || math.IsNaN(expense.Amount) || math.IsInf(expense.Amount, 0) {
+			return fmt.Errorf("%w: expense %d: amount must not be negative", ErrInvalidTripSummary, i+1)
+		}
+		currency := strings.ToUpper(firstNonEmpty(expense.Currency, summary.Currency))
+		if _, ok := costs.ExchangeRates[currency]; !ok {
+			return fmt.Errorf("%w: expense %d: unsupported currency %q", ErrInvalidTripSummary, i+1, expense.Currency)
+		}
+		actual[category] += costs.convert(expense.Amount, currency, summary.Currency)
+	}
+
+	plannedBy := map[string]float64{
+		SpendActivities: planned.Activities,
+		SpendMeals:      planned.Meals,
+		SpendTransit:    planned.Transit,
+		SpendRental:     planned.Rental,
+		SpendLodging:    planned.Lodging,
+	}
+	summary.Spending = []SpendComparison{}
+	for _, category := range SpendCategories {
+		spent, hasSpend := actual[category]
+		if !hasSpend && plannedBy[category] == 0 {
+			continue
+		}
+		summary.Spending = append(summary.Spending, SpendComparison{
+			Category:   category,
+			Planned:    roundCost(plannedBy[category]),
+			Actual:     roundCost(spent),
+			Difference: roundCost(spent - plannedBy[category]),
+		})
+		summary.Spent += spent
+	}
+	summary.Spent = roundCost(summary.Spent)
+	summary.Remaining = roundCost(summary.Budget - summary.Spent)
+	summary.OverBudget = summary.Spent > summary.Budget
+	return nil
+}
+
+// GenerateTripSummaryPDF renders a trip's latest recap as a shareable document
+func GenerateTripSummaryPDF(ctx context.Context, id, format string, includeImages bool, customization map[string]interface{}) (*PDFMetadata, error) {
+	summary, err := GetTripSummary(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trip summary: %w", err)
+	}
+
+	dates := ""
+	if summary.StartDate != "" {
+		dates = fmt.Sprintf("%s to %s", summary.StartDate, summary.EndDate)
+	}
+	pdf := newStructuredPDF("Trip Recap", strings.TrimSpace(summary.City+"  "+dates))
+	addPDFCoverPage(pdf, "Trip Recap", []string{
+		summary.City,
+		dates,
+		fmt.Sprintf("%d of %d planned activities", summary.CompletedActivities, summary.PlannedActivities),
+		fmt.Sprintf("%.0f km traveled", summary.DistanceKm),
+	})
+	contents := newPDFContents(pdf)
+
+	heading := func(title string, newPage bool) {
+		contents.section(title, 0, newPage)
+		pdf.SetFont("Arial", "B", 14)
+		pdf.Cell(0, 10, title)
+		pdf.Ln(12)
+	}
+	line := func(style, text string) {
+		pdf.SetFont("Arial", style, 10)
+		pdf.Cell(0, 5, text)
+		pdf.Ln(6)
+	}
+
+	heading("At a Glance", true)
+	line("", fmt.Sprintf("Activities: %d of %d planned (%.0f%%)", summary.CompletedActivities, summary.PlannedActivities, summary.CompletionRate))
+	line("", fmt.Sprintf("Spent: %.2f of a %.2f %s budget", summary.Spent, summary.Budget, summary.Currency))
+	if summary.OverBudget {
+		line("B", fmt.Sprintf("Over budget by %.2f %s", -summary.Remaining, summary.Currency))
+	} else {
+		line("", fmt.Sprintf("Left over: %.2f %s", summary.Remaining, summary.Currency))
+	}
+	line("", fmt.Sprintf("Distance traveled: %.0f km", summary.DistanceKm))
+	line("", fmt.Sprintf("Journal: %d entries, %d photos", summary.JournalEntries, len(summary.PhotoIDs)))
+	pdf.Ln(6)
+
+	heading("Planned vs Actual", false)
+	for _, day := range summary.Days {
+		title := fmt.Sprintf("Day %d", day.Day)
+		if day.Date != "" {
+			title += " - " + day.Date
+		}
+		line("B", title)
+		for _, name := range day.Completed {
+			line("", "Done: "+name)
+		}
+		for _, name := range day.Skipped {
+			line("I", "Skipped: "+name)
+		}
+		pdf.Ln(2)
+	}
+	if len(summary.Unplanned) > 0 {
+		line("B", "Also did")
+		for _, name := range summary.Unplanned {
+			line("", "• "+name)
+		}
+	}
+	pdf.Ln(6)
+
+	heading("Spending", false)
+	pdf.SetFont("Arial", "B", 10)
+	for _, header := range []string{"Category", "Planned", "Actual", "Difference"} {
+		pdf.CellFormat(40, 7, header, "B", 0, "L", false, 0, "")
+	}
+	pdf.Ln(8)
+	pdf.SetFont("Arial", "", 10)
+	for _, row := range summary.Spending {
+		pdf.CellFormat(40, 6, strings.Title(row.Category), "", 0, "L", false, 0, "")
+		pdf.CellFormat(40, 6, fmt.Sprintf("%.2f", row.Planned), "", 0, "L", false, 0, "")
+		pdf.CellFormat(40, 6, fmt.Sprintf("%.2f", row.Actual), "", 0, "L", false, 0, "")
+		pdf.CellFormat(40, 6, fmt.Sprintf("%+.2f", row.Difference), "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(6)
+
+	if len(summary.Legs) > 0 {
+		heading("Distance", false)
+		for _, leg := range summary.Legs {
+			line("", fmt.Sprintf("%s to %s by %s: %.0f km", leg.From, leg.To, leg.Mode, leg.DistanceKm))
+		}
+		pdf.Ln(6)
+	}
+
+	if includeImages && len(summary.PhotoIDs) > 0 {
+		heading("Photos", true)
+		writeJournalPhotosPDF(ctx, pdf, summary.TripID, summary.PhotoIDs[:min(len(summary.PhotoIDs), MaxSummaryPhotos)])
+	}
+
+	contents.write()
+
+	source := PDFSource{Type: "summary", ID: id, Version: summary.Revision}
+	return storePDF(ctx, pdf, source, customization)
+}
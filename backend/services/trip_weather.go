@@ -0,0 +1,276 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joshndala/cantrip/utils"
+)
+
+// TripWeatherCollection stores the last forecast seen for each upcoming trip
+const TripWeatherCollection = "trip_weather"
+
+// TripWeatherWindow is how far ahead trips are monitored for forecast changes
+const TripWeatherWindow = 7 * 24 * time.Hour
+
+// TripWeatherTempShift is the change in daily high or low, in °C, that counts as material
+const TripWeatherTempShift = 7.0
+
+// NotificationTripWeatherChange is sent when an upcoming trip's forecast changes materially
+const NotificationTripWeatherChange = "trip_weather_change"
+
+// TripWeatherSnapshot is the forecast last seen for a trip
+type TripWeatherSnapshot struct {
+	ItineraryID string            `json:"itinerary_id"`
+	City        string            `json:"city"`
+	Forecast    []WeatherForecast `json:"forecast"`
+	CheckedAt   time.Time         `json:"checked_at"`
+}
+
+// TripWeatherChange describes a material change for one day of a trip
+type TripWeatherChange struct {
+	Date    string `json:"date"`
+	Summary string `json:"summary"`
+}
+
+// Weather severity levels used to decide whether a change matters
+var conditionSeverity = map[string]int{
+	"clear":        0,
+	"clouds":       1,
+	"mist":         1,
+	"fog":          1,
+	"haze":         1,
+	"drizzle":      2,
+	"rain":         3,
+	"heavy rain":   4,
+	"snow":         4,
+	"heavy snow":   5,
+	"thunderstorm": 5,
+}
+
+// CheckTripWeather re-checks forecasts for owned trips starting within the next 7 days and
+// notifies owners when conditions change materially. Only provider forecasts are compared;
+// seasonal estimates never trigger alerts.
+func CheckTripWeather(ctx context.Context) (int, error) {
+	ids, err := listDocumentIDs(ctx, ItineraryCollection)
+	if err != nil {
+		return 0, err
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	horizon := today.Add(TripWeatherWindow)
+	alerts := 0
+
+	for _, id := range ids {
+		itinerary, err := GetItinerary(ctx, id)
+		if err != nil || itinerary.OwnerID == "" {
+			continue
+		}
+
+		city, start, end, ok := itineraryTripDates(itinerary)
+		if !ok || start.Before(today) || start.After(horizon) {
+			continue
+		}
+
+		forecast, err := getForecastFromAPI(ctx, city, start, end)
+		if err != nil || len(forecast) == 0 {
+			continue
+		}
+		sort.Slice(forecast, func(i, j int) bool { return forecast[i].Date < forecast[j].Date })
+
+		var previous TripWeatherSnapshot
+		hadSnapshot := loadDocument(ctx, TripWeatherCollection, id, &previous) == nil
+
+		snapshot := TripWeatherSnapshot{ItineraryID: id, City: city, Forecast: forecast, CheckedAt: time.Now().UTC()}
+		if err := saveDocument(ctx, TripWeatherCollection, id, snapshot); err != nil {
+			utils.LogError("Failed to save trip weather snapshot", err)
+			continue
+		}
+		if !hadSnapshot {
+			continue
+		}
+
+		changes := CompareForecasts(previous.Forecast, forecast)
+		if len(changes) == 0 {
+			continue
+		}
+
+		if _, err := Notify(ctx, tripWeatherNotification(ctx, itinerary, city, changes, forecast)); err != nil {
+			utils.LogError("Failed to send trip weather alert", err)
+			continue
+		}
+		alerts++
+	}
+
+	return alerts, nil
+}
+
+// CompareForecasts returns the material differences between two forecasts for the same days
+func CompareForecasts(previous, current []WeatherForecast) []TripWeatherChange {
+	before := make(map[string]WeatherForecast, len(previous))
+	for _, day := range previous {
+		before[day.Date] = day
+	}
+
+	var changes []TripWeatherChange
+	for _, day := range current {
+		old, ok := before[day.Date]
+		if !ok {
+			continue
+		}
+
+		var parts []string
+		oldCondition, newCondition := forecastCondition(old), forecastCondition(day)
+		oldSeverity, newSeverity := conditionSeverity[oldCondition], conditionSeverity[newCondition]
+		if oldCondition != newCondition && (math.Abs(float64(newSeverity-oldSeverity)) >= 2 || newSeverity >= 4) {
+			parts = append(parts, fmt.Sprintf("now %s (was %s)", newCondition, oldCondition))
+		}
+		if shift := day.HighTemp - old.HighTemp; math.Abs(shift) >= TripWeatherTempShift {
+			parts = append(parts, fmt.Sprintf("high %.0f°C (was %.0f°C)", day.HighTemp, old.HighTemp))
+		}
+		if shift := day.LowTemp - old.LowTemp; math.Abs(shift) >= TripWeatherTempShift {
+			parts = append(parts, fmt.Sprintf("low %.0f°C (was %.0f°C)", day.LowTemp, old.LowTemp))
+		}
+
+		// Rain becoming likely matters even when the condition is still only cloudy
+		if day.PrecipProbability >= UmbrellaPrecipProbability && old.PrecipProbability < UmbrellaPrecipProbability {
+			parts = append(parts, fmt.Sprintf("%.0f%% chance of precipitation (was %.0f%%)", day.PrecipProbability*100, old.PrecipProbability*100))
+		}
+
+		if len(parts) > 0 {
+			changes = append(changes, TripWeatherChange{Date: day.Date, Summary: strings.Join(parts, ", ")})
+		}
+	}
+	return changes
+}
+
+// forecastCondition normalizes a day's condition, distinguishing heavy rain and snow by amount
+func forecastCondition(day WeatherForecast) string {
+	condition := strings.ToLower(day.Condition)
+	switch {
+	case strings.Contains(condition, "thunder"):
+		return "thunderstorm"
+	case strings.Contains(condition, "snow"):
+		if day.Precipitation >= 10 {
+			return "heavy snow"
+		}
+		return "snow"
+	case strings.Contains(condition, "rain"):
+		if day.Precipitation >= 15 {
+			return "heavy rain"
+		}
+		return "rain"
+	}
+	if _, known := conditionSeverity[condition]; known {
+		return condition
+	}
+	return "clouds"
+}
+
+// tripWeatherNotification builds the alert, including packing suggestions for the new forecast
+func tripWeatherNotification(ctx context.Context, itinerary *ItineraryResponse, city string, changes []TripWeatherChange, forecast []WeatherForecast) Notification {
+	lines := make([]string, 0, len(changes))
+	for _, change := range changes {
+		lines = append(lines, fmt.Sprintf("%s: %s", change.Date, change.Summary))
+	}
+
+	body := fmt.Sprintf("The forecast for your %s trip has changed. %s.", city, strings.Join(lines, "; "))
+	if items := revisedPackingItems(ctx, forecast); len(items) > 0 {
+		body += " Consider packing: " + strings.Join(items, ", ") + "."
+	}
+
+	return Notification{
+		UserID: itinerary.OwnerID,
+		Type:   NotificationTripWeatherChange,
+		Title:  fmt.Sprintf("Weather update for %s", city),
+		Body:   body,
+		Data: map[string]string{
+			"itinerary_id": itinerary.ID,
+			"city":         city,
+		},
+	}
+}
+
+// revisedPackingItems suggests items for the coldest day and any rain or snow in a forecast,
+// counting a likely chance of precipitation as rain
+func revisedPackingItems(ctx context.Context, forecast []WeatherForecast) []string {
+	rules, err := loadPackingRules(ctx)
+	if err != nil || len(forecast) == 0 {
+		return nil
+	}
+
+	coldest := forecast[0].LowTemp
+	wet, snowy := false, false
+	for _, day := range forecast {
+		coldest = math.Min(coldest, day.LowTemp)
+		if day.PrecipProbability >= UmbrellaPrecipProbability {
+			wet = true
+		}
+		switch forecastCondition(day) {
+		case "rain", "heavy rain", "drizzle", "thunderstorm":
+			wet = true
+		case "snow", "heavy snow":
+			snowy = true
+		}
+	}
+
+	var items []string
+	for _, item := range getWeatherItems(rules, getWeatherCategory(coldest)) {
+		items = append(items, item.Name)
+		if len(items) == 4 {
+			break
+		}
+	}
+	if wet {
+		items = append(items, "Rain jacket", "Umbrella", "Waterproof shoes")
+	}
+	if snowy {
+		items = append(items, "Insulated waterproof boots", "Ice cleats")
+	}
+	return utils.RemoveDuplicates(items)
+}
+
+// itineraryTripDates extracts the city and dates of a saved itinerary
+func itineraryTripDates(itinerary *ItineraryResponse) (string, time.Time, time.Time, bool) {
+	city, _ := itinerary.Itinerary["city"].(string)
+	if city == "" {
+		city = itinerary.Metadata.City
+	}
+	startDate, _ := itinerary.Itinerary["start_date"].(string)
+	endDate, _ := itinerary.Itinerary["end_date"].(string)
+
+	start, err := time.Parse("2006-01-02", startDate)
+	if err != nil || city == "" {
+		return "", time.Time{}, time.Time{}, false
+	}
+	end, err := time.Parse("2006-01-02", endDate)
+	if err != nil || end.Before(start) {
+		end = start
+	}
+	return city, start, end, true
+}
+
+// StartTripWeatherMonitor runs CheckTripWeather in the background at the given interval
+func StartTripWeatherMonitor(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			ForEachTenant(context.Background(), func(ctx context.Context) {
+				alerts, err := CheckTripWeather(ctx)
+				if err != nil {
+					utils.LogError("Trip weather check failed for tenant "+TenantFromContext(ctx).ID, err)
+					return
+				}
+				if alerts > 0 {
+					utils.LogInfo(fmt.Sprintf("Sent %d trip weather alerts for tenant %s", alerts, TenantFromContext(ctx).ID))
+				}
+			})
+		}
+	}()
+}
@@ -0,0 +1,288 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/joshndala/cantrip/models"
+)
+
+// Text-to-speech provider endpoints
+const (
+	OpenAITTSBaseURL = "https://api.openai.com/v1"
+	OpenAITTSModel   = "tts-1"
+	OpenAITTSVoice   = "alloy"
+	GoogleTTSBaseURL = "https://texttospeech.googleapis.com/v1"
+)
+
+// SpeechProviderOpenAI selects OpenAI speech via TTS_PROVIDER, which also accepts
+// SpeechProviderGoogle
+const SpeechProviderOpenAI = "openai"
+
+// maxSpeechChars keeps replies within the providers' input limits (4096 for OpenAI)
+const maxSpeechChars = 4000
+
+// speechAudioPrefix is where synthesized replies are cached in the object store
+const speechAudioPrefix = "tts/"
+
+// ErrSpeechSynthesisUnavailable is returned when no text-to-speech provider is configured
+var ErrSpeechSynthesisUnavailable = errors.New("text-to-speech is not configured")
+
+// markdownMarks are stripped before synthesis so they aren't read aloud
+var markdownMarks = regexp.MustCompile("[*_#`>]+")
+
+// SpeechAudio is a synthesized reply
+type SpeechAudio struct {
+	Data        []byte
+	ContentType string
+}
+
+// ChatAudio points a chat reply at its spoken version
+type ChatAudio = models.ChatAudio
+
+// TextToSpeechProvider synthesizes speech from text
+type TextToSpeechProvider interface {
+	Name() string
+	Synthesize(ctx context.Context, text, language string) (*SpeechAudio, error)
+}
+
+// newTTSProvider picks the provider from TTS_PROVIDER, or the first with an API key; the
+// mock agent mode gets silent audio
+func newTTSProvider() TextToSpeechProvider {
+	client := &http.Client{Timeout: SpeechTimeout, Transport: upstreamHTTPClient().Transport}
+	openAIKey, googleKey := os.Getenv("OPENAI_API_KEY"), os.Getenv("GOOGLE_TTS_API_KEY")
+
+	switch provider := os.Getenv("TTS_PROVIDER"); {
+	case provider == SpeechProviderOpenAI && openAIKey != "",
+		provider == "" && openAIKey != "":
+		voice := os.Getenv("TTS_VOICE")
+		if voice == "" {
+			voice = OpenAITTSVoice
+		}
+		return &OpenAITTSClient{APIKey: openAIKey, BaseURL: OpenAITTSBaseURL, Model: OpenAITTSModel, Voice: voice, HTTPClient: client}
+	case provider == SpeechProviderGoogle && googleKey != "",
+		provider == "" && googleKey != "":
+		return &GoogleTTSClient{APIKey: googleKey, BaseURL: GoogleTTSBaseURL, Voice: os.Getenv("TTS_VOICE"), HTTPClient: client}
+	}
+	if os.Getenv("AI_MODE") == AIModeMock {
+		return &mockTTSProvider{}
+	}
+	return nil
+}
+
+// SpeakReply synthesizes a chat reply, reusing the cached audio when the same text was
+// spoken before, and returns where to fetch it
+func SpeakReply(ctx context.Context, text, language string) (*ChatAudio, error) {
+	provider := GetTextToSpeechProvider()
+	if provider == nil {
+		return nil, ErrSpeechSynthesisUnavailable
+	}
+
+	text = speakableText(text)
+	if text == "" {
+		return nil, fmt.Errorf("reply has no text to speak")
+	}
+
+	sum := sha256.Sum256([]byte(provider.Name() + "\n" + language + "\n" + text))
+	id := hex.EncodeToString(sum[:16])
+	store := blobStore()
+
+	unlock := lockDocument("tts", id)
+	defer unlock()
+
+	for _, ext := range []string{".mp3", ".wav"} {
+		if exists, _ := store.FileExists(ctx, speechAudioPrefix+id+ext); exists {
+			return chatAudio(id+ext, provider), nil
+		}
+	}
+
+	audio, err := provider.Synthesize(ctx, text, language)
+	if err != nil {
+		return nil, err
+	}
+	name := id + ".mp3"
+	if audio.ContentType == "audio/wav" {
+		name = id + ".wav"
+	}
+	if err := store.UploadFile(ctx, speechAudioPrefix+name, audio.Data, audio.ContentType); err != nil {
+		return nil, fmt.Errorf("failed to cache speech: %w", err)
+	}
+	return chatAudio(name, provider), nil
+}
+
+func chatAudio(name string, provider TextToSpeechProvider) *ChatAudio {
+	return &ChatAudio{
+		URL:         "/api/v1/chat/audio/" + name,
+		ContentType: getContentTypeFromExtension(name[strings.LastIndex(name, "."):]),
+		Provider:    provider.Name(),
+	}
+}
+
+// OpenSpeechAudio opens a cached reply for streaming
+func OpenSpeechAudio(ctx context.Context, name string) (io.ReadSeekCloser, *FileInfo, error) {
+	if strings.ContainsAny(name, "/\\") || strings.HasPrefix(name, ".") {
+		return nil, nil, fmt.Errorf("speech %s: %w", name, ErrDocumentNotFound)
+	}
+	store := blobStore()
+	object := speechAudioPrefix + name
+	info, err := store.GetFileInfo(ctx, object)
+	if err != nil {
+		return nil, nil, fmt.Errorf("speech %s: %w", name, ErrDocumentNotFound)
+	}
+	return &objectReader{ctx: ctx, store: store, object: object, size: info.Size}, info, nil
+}
+
+// speakableText drops markdown marks and collapses whitespace, cutting long replies at a
+// sentence boundary
+func speakableText(text string) string {
+	text = strings.Join(strings.Fields(markdownMarks.ReplaceAllString(text, "")), " ")
+	if len(text) <= maxSpeechChars {
+		return text
+	}
+	cut := text[:maxSpeechChars]
+	if i := strings.LastIndexAny(cut, ".!?"); i > maxSpeechChars/2 {
+		return cut[:i+1]
+	}
+	return cut
+}
+
+// OpenAITTSClient is a TextToSpeechProvider backed by the OpenAI speech API
+type OpenAITTSClient struct {
+	APIKey     string
+	BaseURL    string
+	Model      string
+	Voice      string
+	HTTPClient *http.Client
+}
+
+func (c *OpenAITTSClient) Name() string {
+	return SpeechProviderOpenAI
+}
+
+// Synthesize returns MP3 audio; the voice detects the language from the text
+func (c *OpenAITTSClient) Synthesize(ctx context.Context, text, language string) (*SpeechAudio, error) {
+	payload, err := json.Marshal(map[string]string{
+		"model":           c.Model,
+		"voice":           c.Voice,
+		"input":           text,
+		"response_format": "mp3",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/audio/speech", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OpenAI speech request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch from OpenAI speech: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OpenAI speech API returned status: %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OpenAI speech response: %w", err)
+	}
+	return &SpeechAudio{Data: data, ContentType: "audio/mpeg"}, nil
+}
+
+// GoogleTTSClient is a TextToSpeechProvider backed by Google Cloud Text-to-Speech
+type GoogleTTSClient struct {
+	APIKey     string
+	BaseURL    string
+	Voice      string // voice name such as "en-CA-Wavenet-A"; empty for the language default
+	HTTPClient *http.Client
+}
+
+func (c *GoogleTTSClient) Name() string {
+	return SpeechProviderGoogle
+}
+
+// Synthesize returns MP3 audio in the requested language, Canadian English by default
+func (c *GoogleTTSClient) Synthesize(ctx context.Context, text, language string) (*SpeechAudio, error) {
+	if language == "" {
+		language = "en-CA"
+	}
+	voice := map[string]string{"languageCode": language}
+	if c.Voice != "" {
+		voice["name"] = c.Voice
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"input":       map[string]string{"text": text},
+		"voice":       voice,
+		"audioConfig": map[string]string{"audioEncoding": "MP3"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		AudioContent string `json:"audioContent"`
+	}
+	endpoint := c.BaseURL + "/text:synthesize?key=" + url.QueryEscape(c.APIKey)
+	if err := postUpstream(ctx, c.HTTPClient, "Google Text-to-Speech", endpoint, "application/json", bytes.NewReader(payload), nil, &result); err != nil {
+		return nil, err
+	}
+	data, err := base64.StdEncoding.DecodeString(result.AudioContent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode Google Text-to-Speech audio: %w", err)
+	}
+	return &SpeechAudio{Data: data, ContentType: "audio/mpeg"}, nil
+}
+
+// mockTTSProvider returns silence lasting about as long as the text takes to read
+type mockTTSProvider struct{}
+
+func (p *mockTTSProvider) Name() string {
+	return "mock"
+}
+
+func (p *mockTTSProvider) Synthesize(ctx context.Context, text, language string) (*SpeechAudio, error) {
+	const sampleRate = 8000
+	// Roughly 15 characters a second of speech, 8-bit mono
+	samples := len(text) * sampleRate / 15
+	header := struct {
+		Riff          [4]byte
+		Size          uint32
+		Wave, Fmt     [4]byte
+		FmtSize       uint32
+		Format        uint16
+		Channels      uint16
+		SampleRate    uint32
+		ByteRate      uint32
+		BlockAlign    uint16
+		BitsPerSample uint16
+		Data          [4]byte
+		DataSize      uint32
+	}{
+		Riff: [4]byte{'R', 'I', 'F', 'F'}, Size: uint32(36 + samples),
+		Wave: [4]byte{'W', 'A', 'V', 'E'}, Fmt: [4]byte{'f', 'm', 't', ' '},
+		FmtSize: 16, Format: 1, Channels: 1, SampleRate: sampleRate, ByteRate: sampleRate, BlockAlign: 1, BitsPerSample: 8,
+		Data: [4]byte{'d', 'a', 't', 'a'}, DataSize: uint32(samples),
+	}
+	var wav bytes.Buffer
+	binary.Write(&wav, binary.LittleEndian, header)
+	wav.Write(bytes.Repeat([]byte{128}, samples)) // 8-bit PCM silence is the midpoint
+	return &SpeechAudio{Data: wav.Bytes(), ContentType: "audio/wav"}, nil
+}
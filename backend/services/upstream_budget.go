@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Upstreams with their own timeout, each overridable with <NAME>_TIMEOUT in the
+// environment as a duration, e.g. WEATHER_TIMEOUT=4s or AGENT_STREAM_TIMEOUT=10m
+const (
+	UpstreamWeather     = "weather"
+	UpstreamEvents      = "events"
+	UpstreamAgent       = "agent"
+	UpstreamAgentStream = "agent_stream"
+)
+
+// Deadline budgets
+const (
+	DefaultStreamTimeout = 5 * time.Minute
+	// UpstreamBudgetReserve of a request's remaining budget is held back from upstream
+	// calls, so the handler still has time to fall back and respond when one times out
+	UpstreamBudgetReserve = 500 * time.Millisecond
+	// MinUpstreamBudget is the least time worth starting an upstream call with
+	MinUpstreamBudget = 250 * time.Millisecond
+)
+
+// ErrBudgetExhausted is returned instead of starting an upstream call too late in a request
+var ErrBudgetExhausted = errors.New("request deadline budget exhausted")
+
+var defaultUpstreamTimeouts = map[string]time.Duration{
+	UpstreamWeather:     UpstreamTimeout,
+	UpstreamEvents:      UpstreamTimeout,
+	UpstreamAgent:       DefaultTimeout,
+	UpstreamAgentStream: DefaultStreamTimeout,
+}
+
+// UpstreamTimeoutFor returns the configured timeout for one upstream
+func UpstreamTimeoutFor(upstream string) time.Duration {
+	if value := os.Getenv(strings.ToUpper(upstream) + "_TIMEOUT"); value != "" {
+		if timeout, err := time.ParseDuration(value); err == nil && timeout > 0 {
+			return timeout
+		}
+	}
+	if timeout, ok := defaultUpstreamTimeouts[upstream]; ok {
+		return timeout
+	}
+	return UpstreamTimeout
+}
+
+// WithUpstreamBudget bounds an upstream call by the upstream's timeout or what is left of
+// the request's deadline, less the reserve, whichever is sooner. It returns
+// ErrBudgetExhausted when too little is left to be worth calling.
+func WithUpstreamBudget(ctx context.Context, upstream string) (context.Context, context.CancelFunc, error) {
+	timeout := UpstreamTimeoutFor(upstream)
+	if deadline, ok := ctx.Deadline(); ok {
+		remaining := time.Until(deadline) - UpstreamBudgetReserve
+		if remaining < MinUpstreamBudget {
+			return ctx, func() {}, fmt.Errorf("%s: %w", upstream, ErrBudgetExhausted)
+		}
+		if remaining < timeout {
+			timeout = remaining
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, cancel, nil
+}
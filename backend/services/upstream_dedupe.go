@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// UpstreamDedupeStats counts, for one kind of upstream call, how many requests were made
+// and how many of them shared another request's call instead of making their own
+type UpstreamDedupeStats struct {
+	Requests      int64 `json:"requests"`
+	UpstreamCalls int64 `json:"upstream_calls"`
+	Deduplicated  int64 `json:"deduplicated"`
+}
+
+// upstreamFlight shares one upstream call between concurrent identical requests. The call
+// runs with the first caller's context minus its cancellation, bounded by the upstream's
+// own timeout, so one caller giving up doesn't fail the others waiting on it. Each caller
+// waits only as long as its own deadline budget allows.
+type upstreamFlight struct {
+	group    singleflight.Group
+	upstream string
+
+	mu    sync.Mutex
+	stats UpstreamDedupeStats
+}
+
+var (
+	upstreamFlightsMu sync.Mutex
+	upstreamFlights   = map[string]*upstreamFlight{}
+)
+
+// flightFor returns the flight for a kind of call, such as "weather.current"
+func flightFor(kind string) *upstreamFlight {
+	upstreamFlightsMu.Lock()
+	defer upstreamFlightsMu.Unlock()
+	flight, ok := upstreamFlights[kind]
+	if !ok {
+		upstream, _, _ := strings.Cut(kind, ".")
+		flight = &upstreamFlight{upstream: upstream}
+		upstreamFlights[kind] = flight
+	}
+	return flight
+}
+
+func (f *upstreamFlight) do(ctx context.Context, key string, call func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	f.mu.Lock()
+	f.stats.Requests++
+	f.mu.Unlock()
+
+	result := f.group.DoChan(key, func() (interface{}, error) {
+		f.mu.Lock()
+		f.stats.UpstreamCalls++
+		f.mu.Unlock()
+		shared, cancel := context.WithTimeout(context.WithoutCancel(ctx), UpstreamTimeoutFor(f.upstream))
+		defer cancel()
+		return call(shared)
+	})
+	select {
+	case r := <-result:
+		return r.Val, r.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// GetUpstreamDedupeStats reports deduplication per kind of upstream call since startup
+func GetUpstreamDedupeStats() map[string]UpstreamDedupeStats {
+	upstreamFlightsMu.Lock()
+	kinds := make([]string, 0, len(upstreamFlights))
+	for kind := range upstreamFlights {
+		kinds = append(kinds, kind)
+	}
+	upstreamFlightsMu.Unlock()
+	sort.Strings(kinds)
+
+	stats := make(map[string]UpstreamDedupeStats, len(kinds))
+	for _, kind := range kinds {
+		flight := flightFor(kind)
+		flight.mu.Lock()
+		snapshot := flight.stats
+		flight.mu.Unlock()
+		snapshot.Deduplicated = snapshot.Requests - snapshot.UpstreamCalls
+		stats[kind] = snapshot
+	}
+	return stats
+}
+
+// upstreamKey normalizes a city query for use as a flight key
+func upstreamKey(city string) string {
+	return strings.ToLower(strings.TrimSpace(city))
+}
+
+// dedupedWeatherProvider shares concurrent identical weather lookups
+type dedupedWeatherProvider struct {
+	provider WeatherProvider
+}
+
+// dedupeWeatherProvider wraps provider so concurrent requests for the same city share a call
+func dedupeWeatherProvider(provider WeatherProvider) WeatherProvider {
+	return &dedupedWeatherProvider{provider: provider}
+}
+
+func (p *dedupedWeatherProvider) CurrentWeather(ctx context.Context, city string) (WeatherInfo, error) {
+	value, err := flightFor("weather.current").do(ctx, upstreamKey(city), func(ctx context.Context) (interface{}, error) {
+		return p.provider.CurrentWeather(ctx, city)
+	})
+	if err != nil {
+		return WeatherInfo{}, err
+	}
+	return value.(WeatherInfo), nil
+}
+
+// Forecast shares the decoded response, which callers only read
+func (p *dedupedWeatherProvider) Forecast(ctx context.Context, query ForecastQuery) (WeatherForecastResponse, error) {
+	key := upstreamKey(query.City)
+	if query.City == "" {
+		// The precision the provider is queried with
+		key = fmt.Sprintf("%.4f,%.4f", query.Lat, query.Lon)
+	}
+	value, err := flightFor("weather.forecast").do(ctx, key, func(ctx context.Context) (interface{}, error) {
+		return p.provider.Forecast(ctx, query)
+	})
+	if err != nil {
+		return WeatherForecastResponse{}, err
+	}
+	return value.(WeatherForecastResponse), nil
+}
+
+// dedupedEventProvider shares concurrent identical event searches
+type dedupedEventProvider struct {
+	provider EventProvider
+}
+
+// dedupeEventProvider wraps provider so concurrent searches for the same city share a call
+func dedupeEventProvider(provider EventProvider) EventProvider {
+	return &dedupedEventProvider{provider: provider}
+}
+
+func (p *dedupedEventProvider) Name() string {
+	return p.provider.Name()
+}
+
+// SearchEvents returns each caller its own copy, since callers tag and annotate the events
+func (p *dedupedEventProvider) SearchEvents(ctx context.Context, city string) ([]Event, error) {
+	value, err := flightFor("events."+p.provider.Name()).do(ctx, upstreamKey(city), func(ctx context.Context) (interface{}, error) {
+		return p.provider.SearchEvents(ctx, city)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]Event(nil), value.([]Event)...), nil
+}
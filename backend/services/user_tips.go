@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joshndala/cantrip/utils"
+)
+
+// userTipCollection stores tips submitted by travelers, one document per tip
+const userTipCollection = "user_tips"
+
+// NotificationTipReviewed is sent when an admin approves or rejects a submitted tip
+const NotificationTipReviewed = "tip_reviewed"
+
+// Submitted tip statuses
+const (
+	TipStatusPending  = "pending"
+	TipStatusApproved = "approved"
+	TipStatusRejected = "rejected"
+)
+
+// Limits on submitted tips
+const (
+	MaxPendingTipsPerUser = 5
+	maxTipTitleLength     = 80
+	minTipDescription     = 20
+	maxTipDescription     = 1000
+	maxTipTags            = 5
+)
+
+// defaultTipAuthor credits a tip whose author gave no display name
+const defaultTipAuthor = "A Cantrip traveler"
+
+// userTipCategories are the tips.json categories travelers may contribute to
+var userTipCategories = []string{"cultural", "tipping", "safety", "customs", "health"}
+
+var (
+	// ErrInvalidTip is returned for a submission missing a field or outside the limits
+	ErrInvalidTip = errors.New("invalid tip")
+	// ErrTipNotAllowed is returned for a submission the moderation rules block
+	ErrTipNotAllowed = errors.New("tip was blocked by moderation")
+	// ErrTooManyPendingTips is returned once a user has MaxPendingTipsPerUser tips awaiting review
+	ErrTooManyPendingTips = errors.New("too many tips awaiting review")
+	// ErrTipAlreadyReviewed is returned when reviewing a tip that isn't pending
+	ErrTipAlreadyReviewed = errors.New("tip was already reviewed")
+)
+
+// TipSubmission is what a traveler sends to suggest a tip
+type TipSubmission struct {
+	Destination string   `json:"destination" binding:"required"`
+	Category    string   `json:"category" binding:"required"`
+	Title       string   `json:"title" binding:"required"`
+	Description string   `json:"description" binding:"required"`
+	Tags        []string `json:"tags"`
+	AuthorName  string   `json:"author_name"` // how the tip is credited, "A Cantrip traveler" when empty
+}
+
+// UserTip is a submitted tip and where it is in review
+type UserTip struct {
+	ID              string     `json:"id"`
+	Destination     string     `json:"destination"` // the city as tips.json names it
+	Category        string     `json:"category"`
+	Title           string     `json:"title"`
+	Description     string     `json:"description"`
+	Tags            []string   `json:"tags,omitempty"`
+	AuthorID        string     `json:"author_id"`
+	AuthorName      string     `json:"author_name"`
+	Status          string     `json:"status"`
+	Flagged         bool       `json:"flagged,omitempty"` // the moderation rules want a closer look
+	FlagReason      string     `json:"flag_reason,omitempty"`
+	ReviewedBy      string     `json:"reviewed_by,omitempty"`
+	ReviewedAt      *time.Time `json:"reviewed_at,omitempty"`
+	RejectionReason string     `json:"rejection_reason,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// approvedTips caches each tenant's approved tips, reset whenever a tip is reviewed
+var (
+	userTipsMu   sync.Mutex
+	approvedTips sync.Map // tenant ID -> []UserTip
+)
+
+// SubmitTip validates a traveler's tip, screens it with the moderation rules and queues
+// it for review
+func SubmitTip(ctx context.Context, userID string, submission TipSubmission) (*UserTip, error) {
+	tip := UserTip{
+		ID:          utils.GenerateID(),
+		Category:    strings.ToLower(strings.TrimSpace(submission.Category)),
+		Title:       strings.TrimSpace(submission.Title),
+		Description: strings.TrimSpace(submission.Description),
+		Tags:        normalizeInterests(submission.Tags),
+		AuthorID:    userID,
+		AuthorName:  firstNonEmpty(strings.TrimSpace(submission.AuthorName), defaultTipAuthor),
+		Status:      TipStatusPending,
+		CreatedAt:   time.Now().UTC(),
+	}
+
+	switch {
+	case !utils.Contains(userTipCategories, tip.Category):
+		return nil, fmt.Errorf("%w: category must be one of %s", ErrInvalidTip, strings.Join(userTipCategories, ", "))
+	case tip.Title == "" || len(tip.Title) > maxTipTitleLength:
+		return nil, fmt.Errorf("%w: title must be 1-%d characters", ErrInvalidTip, maxTipTitleLength)
+	case len(tip.Description) < minTipDescription || len(tip.Description) > maxTipDescription:
+		return nil, fmt.Errorf("%w: description must be %d-%d characters", ErrInvalidTip, minTipDescription, maxTipDescription)
+	case len(tip.Tags) > maxTipTags:
+		return nil, fmt.Errorf("%w: at most %d tags", ErrInvalidTip, maxTipTags)
+	case len(tip.AuthorName) > maxTipTitleLength:
+		return nil, fmt.Errorf("%w: author_name must be at most %d characters", ErrInvalidTip, maxTipTitleLength)
+	}
+
+	destination, err := tipDestination(ctx, submission.Destination)
+	if err != nil {
+		return nil, err
+	}
+	tip.Destination = destination
+
+	verdict := moderate(ctx, strings.Join([]string{tip.Title, tip.Description, tip.AuthorName}, "\n"), ModerationInput)
+	switch verdict.Action {
+	case ModerationBlock:
+		return nil, fmt.Errorf("%w: %s", ErrTipNotAllowed, strings.Join(verdict.Categories, ", "))
+	case ModerationFlag:
+		tip.Flagged = true
+		tip.FlagReason = strings.Join(verdict.Categories, ", ")
+	}
+
+	userTipsMu.Lock()
+	defer userTipsMu.Unlock()
+
+	submitted, err := ListUserTips(ctx, UserTipFilter{AuthorID: userID, Status: TipStatusPending})
+	if err != nil {
+		return nil, err
+	}
+	if len(submitted) >= MaxPendingTipsPerUser {
+		return nil, fmt.Errorf("%w: wait for your %d pending tips to be reviewed", ErrTooManyPendingTips, len(submitted))
+	}
+
+	if err := saveDocument(ctx, userTipCollection, tip.ID, tip); err != nil {
+		return nil, fmt.Errorf("failed to save tip: %w", err)
+	}
+	return &tip, nil
+}
+
+// tipDestination resolves a city to the name its tips.json section uses, falling back to
+// the city metadata's spelling for cities without a section
+func tipDestination(ctx context.Context, destination string) (string, error) {
+	key := NormalizeCityName(destination)
+	if data, err := loadTipsData(ctx); err == nil {
+		for name := range data.Cities {
+			if NormalizeCityName(name) == key {
+				return name, nil
+			}
+		}
+	}
+	index, err := GetCityIndex(ctx)
+	if err != nil {
+		return "", err
+	}
+	city, ok := index.Find(destination)
+	if !ok {
+		return "", fmt.Errorf("%w: unknown destination %q", ErrInvalidTip, destination)
+	}
+	return city.Name, nil
+}
+
+// UserTipFilter narrows a listing of submitted tips; empty fields match every tip
+type UserTipFilter struct {
+	AuthorID    string
+	Status      string
+	FlaggedOnly bool
+}
+
+// ListUserTips returns submitted tips matching the filter, newest first
+func ListUserTips(ctx context.Context, filter UserTipFilter) ([]UserTip, error) {
+	ids, err := listDocumentIDs(ctx, userTipCollection)
+	if err != nil {
+		return nil, err
+	}
+
+	tips := []UserTip{}
+	for _, id := range ids {
+		var tip UserTip
+		if err := loadDocument(ctx, userTipCollection, id, &tip); err != nil {
+			utils.LogError("Failed to load submitted tip", err)
+			continue
+		}
+		if (filter.AuthorID != "" && tip.AuthorID != filter.AuthorID) ||
+			(filter.Status != "" && tip.Status != filter.Status) ||
+			(filter.FlaggedOnly && !tip.Flagged) {
+			continue
+		}
+		tips = append(tips, tip)
+	}
+	sort.Slice(tips, func(i, j int) bool { return tips[i].CreatedAt.After(tips[j].CreatedAt) })
+	return tips, nil
+}
+
+// ReviewTip approves or rejects a pending tip and tells its author
+func ReviewTip(ctx context.Context, id, reviewer string, approve bool, reason string) (*UserTip, error) {
+	userTipsMu.Lock()
+	defer userTipsMu.Unlock()
+
+	var tip UserTip
+	if err := loadDocument(ctx, userTipCollection, id, &tip); err != nil {
+		return nil, err
+	}
+	if tip.Status != TipStatusPending {
+		return nil, fmt.Errorf("%w: tip is %s", ErrTipAlreadyReviewed, tip.Status)
+	}
+
+	now := time.Now().UTC()
+	tip.Status = TipStatusRejected
+	if approve {
+		tip.Status = TipStatusApproved
+	}
+	tip.ReviewedBy = reviewer
+	tip.ReviewedAt = &now
+	tip.RejectionReason = strings.TrimSpace(reason)
+	if err := saveDocument(ctx, userTipCollection, tip.ID, tip); err != nil {
+		return nil, fmt.Errorf("failed to save tip: %w", err)
+	}
+	approvedTips.Delete(TenantFromContext(ctx).ID)
+
+	notification := Notification{
+		UserID: tip.AuthorID,
+		Type:   NotificationTipReviewed,
+		Title:  "Your tip was published",
+		Body:   fmt.Sprintf("%q is now shown to travelers visiting %s. Thanks for sharing!", tip.Title, tip.Destination),
+		Data:   map[string]string{"tip_id": tip.ID, "status": tip.Status},
+	}
+	if !approve {
+		notification.Title = "Your tip wasn't published"
+		notification.Body = fmt.Sprintf("%q wasn't added to the %s tips.", tip.Title, tip.Destination)
+		if tip.RejectionReason != "" {
+			notification.Body += " Reason: " + tip.RejectionReason
+		}
+	}
+	if _, err := Notify(ctx, notification); err != nil {
+		utils.LogError("Failed to notify tip author", err)
+	}
+	return &tip, nil
+}
+
+// approvedUserTips returns a destination's approved tips in a category as tips credited
+// to their authors
+func approvedUserTips(ctx context.Context, destination, category string) []Tip {
+	tenant := TenantFromContext(ctx).ID
+	cached, ok := approvedTips.Load(tenant)
+	if !ok {
+		approved, err := ListUserTips(ctx, UserTipFilter{Status: TipStatusApproved})
+		if err != nil {
+			utils.LogError("Failed to load approved tips", err)
+			return nil
+		}
+		cached, _ = approvedTips.LoadOrStore(tenant, approved)
+	}
+
+	key := NormalizeCityName(destination)
+	var tips []Tip
+	for _, tip := range cached.([]UserTip) {
+		if NormalizeCityName(tip.Destination) != key || tip.Category != category {
+			continue
+		}
+		tips = append(tips, Tip{
+			Title:       tip.Title,
+			Description: tip.Description,
+			Category:    tip.Category,
+			Priority:    "low",
+			Tags:        tip.Tags,
+			Author:      tip.AuthorName,
+		})
+	}
+	return tips
+}
@@ -3,34 +3,20 @@ package services
 //COMPLETED
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"math"
 	"math/rand"
-	"net/http"
-	"os"
-	"strings"
+	"sort"
 	"time"
+
+	"github.com/joshndala/cantrip/models"
 )
 
 // WeatherInfo represents weather information for a location
-type WeatherInfo struct {
-	Temperature float64 `json:"temperature"`
-	Condition   string  `json:"condition"`
-	Humidity    int     `json:"humidity"`
-	WindSpeed   float64 `json:"wind_speed"`
-}
+type WeatherInfo = models.WeatherInfo
 
 // WeatherForecast represents a weather forecast for a specific date
-type WeatherForecast struct {
-	Date          string  `json:"date"`
-	HighTemp      float64 `json:"high_temp"`
-	LowTemp       float64 `json:"low_temp"`
-	Condition     string  `json:"condition"`
-	Humidity      int     `json:"humidity"`
-	WindSpeed     float64 `json:"wind_speed"`
-	Precipitation float64 `json:"precipitation"`
-}
+type WeatherForecast = models.WeatherForecast
 
 // WeatherForecastResponse represents the response from OpenWeatherMap forecast API
 type WeatherForecastResponse struct {
@@ -42,8 +28,9 @@ type WeatherForecastResponse struct {
 type ForecastItem struct {
 	Dt   int64 `json:"dt"`
 	Main struct {
-		Temp     float64 `json:"temp"`
-		Humidity int     `json:"humidity"`
+		Temp      float64  `json:"temp"`
+		FeelsLike *float64 `json:"feels_like,omitempty"`
+		Humidity  int      `json:"humidity"`
 	} `json:"main"`
 	Weather []struct {
 		Main        string `json:"main"`
@@ -66,21 +53,23 @@ type CityInfo struct {
 
 // CityMetadata represents the structure of city_metadata.json
 type CityMetadata struct {
-	Cities []City `json:"cities"`
+	SchemaVersion int    `json:"schema_version"`
+	Cities        []City `json:"cities"`
 }
 
 // City represents a single city in the metadata
 type City struct {
-	Name          string            `json:"name"`
-	Province      string            `json:"province"`
-	Country       string            `json:"country"`
-	Coordinates   Coordinates       `json:"coordinates"`
-	Timezone      string            `json:"timezone"`
-	Population    int               `json:"population"`
-	Description   string            `json:"description"`
-	Seasons       map[string]Season `json:"seasons"`
-	Attractions   []string          `json:"attractions"`
-	Neighborhoods []string          `json:"neighborhoods"`
+	Name           string            `json:"name"`
+	Province       string            `json:"province"`
+	Country        string            `json:"country"`
+	Coordinates    Coordinates       `json:"coordinates"`
+	Timezone       string            `json:"timezone"`
+	Population     int               `json:"population"`
+	Description    string            `json:"description"`
+	Seasons        map[string]Season `json:"seasons"`
+	Attractions    []string          `json:"attractions"`
+	Neighborhoods  []Neighborhood    `json:"neighborhoods"`
+	Sustainability Sustainability    `json:"sustainability"`
 }
 
 // Coordinates represents latitude and longitude
@@ -96,19 +85,19 @@ type Season struct {
 	Activities []string `json:"activities"`
 }
 
-// GetWeather retrieves weather information for a city
-func GetWeather(city string) (WeatherInfo, error) {
+// GetWeather retrieves weather information for a city within the context's deadline budget
+func GetWeather(ctx context.Context, city string) (WeatherInfo, error) {
 	// First, try to get weather from a real API (if configured)
-	if weather, err := getWeatherFromAPI(city); err == nil {
+	if weather, err := getWeatherFromAPI(ctx, city); err == nil {
 		return weather, nil
 	}
 
 	// Fallback to using city metadata for seasonal weather
-	return getWeatherFromMetadata(city)
+	return getWeatherFromMetadata(ctx, city)
 }
 
 // GetWeatherForecast retrieves weather forecast for a city and trip dates
-func GetWeatherForecast(city string, startDate, endDate string) ([]WeatherForecast, error) {
+func GetWeatherForecast(ctx context.Context, city string, startDate, endDate string) ([]WeatherForecast, error) {
 	// Parse trip dates
 	start, err := time.Parse("2006-01-02", startDate)
 	if err != nil {
@@ -122,16 +111,17 @@ func GetWeatherForecast(city string, startDate, endDate string) ([]WeatherForeca
 
 	// Calculate days from today using city timezone (will be updated when we get API response)
 	today := time.Now().Truncate(24 * time.Hour)
-	daysFromToday := int(start.Sub(today).Hours() / 24)
+	daysFromToday := models.DaysBetween(today, start)
 
 	// If trip is within 5 days, get forecast from API
 	if daysFromToday <= 5 {
 		// Try to get real forecast for the entire trip or first 5 days
-		realForecast, err := getForecastFromAPI(city, start, end)
-		if err == nil {
-			// If trip extends beyond 5 days, add seasonal data for remaining days
-			if end.After(start.AddDate(0, 0, 5)) {
-				seasonalForecast, err := getSeasonalForecast(city, start.AddDate(0, 0, 6), end)
+		realForecast, err := getForecastFromAPI(ctx, city, start, end)
+		if err == nil && len(realForecast) > 0 {
+			// Fill the days past the end of the real forecast with seasonal data
+			lastDay, _ := time.Parse("2006-01-02", realForecast[len(realForecast)-1].Date)
+			if end.After(lastDay) {
+				seasonalForecast, err := getSeasonalForecast(ctx, city, lastDay.AddDate(0, 0, 1), end)
 				if err == nil {
 					return append(realForecast, seasonalForecast...), nil
 				}
@@ -141,12 +131,12 @@ func GetWeatherForecast(city string, startDate, endDate string) ([]WeatherForeca
 	}
 
 	// If trip is beyond 5 days or API failed, use seasonal data
-	return getSeasonalForecast(city, start, end)
+	return getSeasonalForecast(ctx, city, start, end)
 }
 
 // GetWeatherForecastWithNotes retrieves weather forecast with helpful notes
-func GetWeatherForecastWithNotes(city string, startDate, endDate string) ([]WeatherForecast, []string, error) {
-	forecasts, err := GetWeatherForecast(city, startDate, endDate)
+func GetWeatherForecastWithNotes(ctx context.Context, city string, startDate, endDate string) ([]WeatherForecast, []string, error) {
+	forecasts, err := GetWeatherForecast(ctx, city, startDate, endDate)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -156,7 +146,7 @@ func GetWeatherForecastWithNotes(city string, startDate, endDate string) ([]Weat
 	end, _ := time.Parse("2006-01-02", endDate)
 
 	today := time.Now().Truncate(24 * time.Hour)
-	daysFromToday := int(start.Sub(today).Hours() / 24)
+	daysFromToday := models.DaysBetween(today, start)
 
 	var notes []string
 
@@ -196,45 +186,31 @@ func resolveCityCoordinates(city string) (float64, float64, error) {
 }
 
 // getForecastByCoordinates gets forecast using lat/lon instead of city name
-func getForecastByCoordinates(lat, lon float64, start, end time.Time) ([]WeatherForecast, error) {
-	apiKey := os.Getenv("WEATHER_API_KEY")
-	if apiKey == "" {
+func getForecastByCoordinates(ctx context.Context, lat, lon float64, start, end time.Time) ([]WeatherForecast, error) {
+	provider := GetWeatherProvider()
+	if provider == nil {
 		return nil, fmt.Errorf("no weather API key configured")
 	}
-
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-
-	// Use coordinates for more precise location
-	url := fmt.Sprintf("http://api.openweathermap.org/data/2.5/forecast?lat=%.4f&lon=%.4f&appid=%s&units=metric", lat, lon, apiKey)
-
-	req, err := http.NewRequestWithContext(context.Background(), "GET", url, nil)
+	ctx, cancel, err := WithUpstreamBudget(ctx, UpstreamWeather)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
+	defer cancel()
 
-	resp, err := client.Do(req)
+	// Use coordinates for more precise location
+	forecastResp, err := provider.Forecast(ctx, ForecastQuery{Lat: lat, Lon: lon})
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch forecast: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("forecast API returned status: %d", resp.StatusCode)
+		return nil, err
 	}
 
-	var forecastResp WeatherForecastResponse
-	if err := json.NewDecoder(resp.Body).Decode(&forecastResp); err != nil {
-		return nil, fmt.Errorf("failed to decode forecast response: %w", err)
+	// Bucket by local date in the zone of the nearest known city
+	var city *City
+	if index, err := GetCityIndex(ctx); err == nil {
+		if nearest, distance, ok := index.Nearest(Coordinates{Lat: lat, Lng: lon}); ok && distance <= forecastCityRadiusKm {
+			city = nearest
+		}
 	}
-
-	// Convert trip dates to city's timezone for proper comparison
-	tz := time.FixedZone("city", forecastResp.City.Timezone)
-	startLocal := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, tz)
-	endLocal := time.Date(end.Year(), end.Month(), end.Day(), 23, 59, 59, 0, tz)
-
-	return aggregateForecastData(forecastResp, startLocal, endLocal)
+	return aggregateForecastData(forecastResp, start, end, forecastLocation(city, forecastResp.City.Timezone))
 }
 
 // getSeasonalWeatherNotes generates helpful notes based on seasonal weather patterns
@@ -267,52 +243,27 @@ func getSeasonalWeatherNotes(city string, start, end time.Time) []string {
 }
 
 // getWeatherFromAPI attempts to get weather from a real weather API
-func getWeatherFromAPI(city string) (WeatherInfo, error) {
-	// Check if API key is configured (you can set this as an environment variable)
-	apiKey := os.Getenv("WEATHER_API_KEY")
-	if apiKey == "" {
+func getWeatherFromAPI(ctx context.Context, city string) (WeatherInfo, error) {
+	provider := GetWeatherProvider()
+	if provider == nil {
 		return WeatherInfo{}, fmt.Errorf("no weather API key configured")
 	}
-
-	// Example using OpenWeatherMap API
-	url := fmt.Sprintf("http://api.openweathermap.org/data/2.5/weather?q=%s&appid=%s&units=metric", city, apiKey)
-
-	resp, err := http.Get(url)
+	ctx, cancel, err := WithUpstreamBudget(ctx, UpstreamWeather)
 	if err != nil {
-		return WeatherInfo{}, fmt.Errorf("failed to fetch weather from API: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return WeatherInfo{}, fmt.Errorf("weather API returned status: %d", resp.StatusCode)
-	}
-
-	// Parse the API response (simplified - you'd need to implement full parsing)
-	var apiResponse map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&apiResponse); err != nil {
-		return WeatherInfo{}, fmt.Errorf("failed to decode API response: %w", err)
-	}
-
-	// Extract weather data from API response
-	// This is a simplified example - actual implementation would depend on the API
-	if main, ok := apiResponse["main"].(map[string]interface{}); ok {
-		if temp, ok := main["temp"].(float64); ok {
-			if weather, ok := apiResponse["weather"].([]interface{}); ok && len(weather) > 0 {
-				if weatherObj, ok := weather[0].(map[string]interface{}); ok {
-					if condition, ok := weatherObj["main"].(string); ok {
-						return WeatherInfo{
-							Temperature: temp,
-							Condition:   condition,
-							Humidity:    int(main["humidity"].(float64)),
-							WindSpeed:   getWindSpeedFromAPI(apiResponse),
-						}, nil
-					}
-				}
-			}
-		}
+		return WeatherInfo{}, err
 	}
+	defer cancel()
 
-	return WeatherInfo{}, fmt.Errorf("failed to parse API response")
+	return provider.CurrentWeather(ctx, city)
+}
+
+// forecastItemFeelsLike prefers the provider's feels-like temperature, computing it from the
+// period's readings when the payload has none
+func forecastItemFeelsLike(item ForecastItem) float64 {
+	if item.Main.FeelsLike != nil {
+		return *item.Main.FeelsLike
+	}
+	return FeelsLike(item.Main.Temp, item.Main.Humidity, metersPerSecondToKmh(item.Wind.Speed))
 }
 
 // getWindSpeedFromAPI extracts wind speed from API response
@@ -326,9 +277,9 @@ func getWindSpeedFromAPI(response map[string]interface{}) float64 {
 }
 
 // getWeatherFromMetadata gets weather information from city metadata
-func getWeatherFromMetadata(city string) (WeatherInfo, error) {
+func getWeatherFromMetadata(ctx context.Context, city string) (WeatherInfo, error) {
 	// Load city metadata
-	metadata, err := loadCityMetadata()
+	metadata, err := loadCityMetadata(ctx)
 	if err != nil {
 		return WeatherInfo{}, fmt.Errorf("failed to load city metadata: %w", err)
 	}
@@ -354,28 +305,38 @@ func getWeatherFromMetadata(city string) (WeatherInfo, error) {
 	return weather, nil
 }
 
-// loadCityMetadata loads the city metadata from JSON file
-func loadCityMetadata() (*CityMetadata, error) {
-	data, err := os.ReadFile("data/city_metadata.json")
+// loadCityMetadata returns the city metadata from the city index, parsed once rather than
+// per call. The metadata is shared and must not be modified.
+func loadCityMetadata(ctx context.Context) (*CityMetadata, error) {
+	index, err := GetCityIndex(ctx)
 	if err != nil {
 		return nil, err
 	}
-
-	var metadata CityMetadata
-	if err := json.Unmarshal(data, &metadata); err != nil {
-		return nil, err
-	}
-
-	return &metadata, nil
+	return index.Metadata(), nil
 }
 
-// findCity finds a city in the metadata by name (case-insensitive)
+// findCity finds a city in the metadata by name, ignoring case and accents. Indexed
+// metadata is looked up directly; other metadata is searched.
 func findCity(metadata *CityMetadata, cityName string) (*City, error) {
-	cityNameLower := strings.ToLower(cityName)
+	cityIndexMu.RLock()
+	var index *CityIndex
+	for _, candidate := range cityIndexes {
+		if candidate.metadata == metadata {
+			index = candidate
+		}
+	}
+	cityIndexMu.RUnlock()
+	if index != nil {
+		if city, ok := index.Find(cityName); ok {
+			return city, nil
+		}
+		return nil, fmt.Errorf("city '%s' not found in metadata", cityName)
+	}
 
-	for _, city := range metadata.Cities {
-		if strings.ToLower(city.Name) == cityNameLower {
-			return &city, nil
+	key := NormalizeCityName(cityName)
+	for i := range metadata.Cities {
+		if NormalizeCityName(metadata.Cities[i].Name) == key {
+			return &metadata.Cities[i], nil
 		}
 	}
 
@@ -418,9 +379,11 @@ func generateSeasonalWeather(season Season, seasonName string) WeatherInfo {
 
 	return WeatherInfo{
 		Temperature: temperature,
+		FeelsLike:   FeelsLike(temperature, humidity, windSpeed),
 		Condition:   condition,
 		Humidity:    humidity,
 		WindSpeed:   windSpeed,
+		Estimated:   true,
 	}
 }
 
@@ -496,81 +459,85 @@ func getWindSpeedForSeason(season string) float64 {
 }
 
 // getForecastFromAPI gets weather forecast from OpenWeatherMap API
-func getForecastFromAPI(city string, start, end time.Time) ([]WeatherForecast, error) {
-	apiKey := os.Getenv("WEATHER_API_KEY")
-	if apiKey == "" {
+func getForecastFromAPI(ctx context.Context, city string, start, end time.Time) ([]WeatherForecast, error) {
+	provider := GetWeatherProvider()
+	if provider == nil {
 		return nil, fmt.Errorf("no weather API key configured")
 	}
-
-	// Create HTTP client with timeout
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-
-	// Get forecast data (5 days, 3-hour intervals)
-	url := fmt.Sprintf("http://api.openweathermap.org/data/2.5/forecast?q=%s&appid=%s&units=metric", city, apiKey)
-
-	req, err := http.NewRequestWithContext(context.Background(), "GET", url, nil)
+	ctx, cancel, err := WithUpstreamBudget(ctx, UpstreamWeather)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
+	defer cancel()
 
-	resp, err := client.Do(req)
+	// Get forecast data (5 days, 3-hour intervals)
+	forecastResp, err := provider.Forecast(ctx, ForecastQuery{City: city})
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch forecast: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("forecast API returned status: %d", resp.StatusCode)
+		return nil, err
 	}
 
-	var forecastResp WeatherForecastResponse
-	if err := json.NewDecoder(resp.Body).Decode(&forecastResp); err != nil {
-		return nil, fmt.Errorf("failed to decode forecast response: %w", err)
+	var cityData *City
+	if metadata, err := loadCityMetadata(ctx); err == nil {
+		cityData, _ = findCity(metadata, city)
 	}
 
-	// Convert trip dates to city's timezone for proper comparison
-	tz := time.FixedZone("city", forecastResp.City.Timezone)
-	startLocal := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, tz)
-	endLocal := time.Date(end.Year(), end.Month(), end.Day(), 23, 59, 59, 0, tz)
-
 	// Aggregate 3-hour forecasts into daily forecasts
-	return aggregateForecastData(forecastResp, startLocal, endLocal)
+	return aggregateForecastData(forecastResp, start, end, forecastLocation(cityData, forecastResp.City.Timezone))
 }
 
-// aggregateForecastData aggregates 3-hour forecasts into daily forecasts
-func aggregateForecastData(forecastResp WeatherForecastResponse, start, end time.Time) ([]WeatherForecast, error) {
-	// Group forecasts by date
-	dailyForecasts := make(map[string][]ForecastItem)
+// forecastCityRadiusKm is how far forecast coordinates may be from a known city to use its timezone
+const forecastCityRadiusKm = 150
 
-	for _, item := range forecastResp.List {
-		// Convert timestamp to city's timezone
-		itemTime := time.Unix(item.Dt, 0).UTC()
-		if forecastResp.City.Timezone != 0 {
-			itemTime = itemTime.Add(time.Duration(forecastResp.City.Timezone) * time.Second)
+// forecastLocation returns the zone a city's forecast is bucketed in. The city's IANA zone
+// follows a DST change inside the forecast window; the API's offset, which is the offset
+// now, is the fallback for cities the metadata doesn't know.
+func forecastLocation(city *City, offsetSeconds int) *time.Location {
+	if city != nil && city.Timezone != "" {
+		if location, err := time.LoadLocation(city.Timezone); err == nil {
+			return location
 		}
+	}
+	if offsetSeconds != 0 {
+		return time.FixedZone("city", offsetSeconds)
+	}
+	return time.UTC
+}
+
+// aggregateForecastData aggregates 3-hour forecasts into daily forecasts for the local
+// dates from start to end, inclusive, in date order. Only the calendar dates of start and
+// end are used.
+func aggregateForecastData(forecastResp WeatherForecastResponse, start, end time.Time, location *time.Location) ([]WeatherForecast, error) {
+	first, last := start.Format("2006-01-02"), end.Format("2006-01-02")
 
-		dateStr := itemTime.Format("2006-01-02")
+	// Group forecasts by local civil date
+	dailyForecasts := make(map[string][]ForecastItem)
+	for _, item := range forecastResp.List {
+		dateStr := time.Unix(item.Dt, 0).In(location).Format("2006-01-02")
 
 		// Only include forecasts within our trip dates (compare by date, not timestamp)
-		if itemTime.Before(start) || itemTime.After(end) {
+		if dateStr < first || dateStr > last {
 			continue
 		}
 
 		dailyForecasts[dateStr] = append(dailyForecasts[dateStr], item)
 	}
 
+	// Maps iterate in random order, so walk the dates sorted
+	dates := make([]string, 0, len(dailyForecasts))
+	for dateStr := range dailyForecasts {
+		dates = append(dates, dateStr)
+	}
+	sort.Strings(dates)
+
 	var forecasts []WeatherForecast
 
 	// Generate daily forecast for each date
-	for dateStr, items := range dailyForecasts {
-		if len(items) == 0 {
-			continue
-		}
+	for _, dateStr := range dates {
+		items := dailyForecasts[dateStr]
 
 		// Calculate daily aggregates
 		var temps []float64
+		var feelsLike []float64
 		var humidities []int
 		var windSpeeds []float64
 		var precipitations []float64
@@ -579,6 +546,7 @@ func aggregateForecastData(forecastResp WeatherForecastResponse, start, end time
 
 		for _, item := range items {
 			temps = append(temps, item.Main.Temp)
+			feelsLike = append(feelsLike, forecastItemFeelsLike(item))
 			humidities = append(humidities, item.Main.Humidity)
 			windSpeeds = append(windSpeeds, item.Wind.Speed)
 
@@ -611,17 +579,20 @@ func aggregateForecastData(forecastResp WeatherForecastResponse, start, end time
 		avgWindSpeed := averageFloat64(windSpeeds)
 		totalPrecipitation := sumFloat64(precipitations)
 		mostCommonCondition := mostCommonString(conditions)
-		// Note: avgPOP is calculated but not currently used in WeatherForecast struct
-		// Could be added to the struct if needed for future features
+		// One likely-wet period is enough to need an umbrella, so the day takes the maximum
+		precipProbability := maxFloat64(pops)
 
 		forecasts = append(forecasts, WeatherForecast{
-			Date:          dateStr,
-			HighTemp:      highTemp,
-			LowTemp:       lowTemp,
-			Condition:     mostCommonCondition,
-			Humidity:      avgHumidity,
-			WindSpeed:     avgWindSpeed,
-			Precipitation: totalPrecipitation,
+			Date:              dateStr,
+			HighTemp:          highTemp,
+			LowTemp:           lowTemp,
+			FeelsLikeHigh:     maxFloat64(feelsLike),
+			FeelsLikeLow:      minFloat64(feelsLike),
+			Condition:         mostCommonCondition,
+			Humidity:          avgHumidity,
+			WindSpeed:         avgWindSpeed,
+			Precipitation:     totalPrecipitation,
+			PrecipProbability: precipProbability,
 		})
 	}
 
@@ -629,9 +600,9 @@ func aggregateForecastData(forecastResp WeatherForecastResponse, start, end time
 }
 
 // getSeasonalForecast generates forecast based on seasonal data
-func getSeasonalForecast(city string, start, end time.Time) ([]WeatherForecast, error) {
+func getSeasonalForecast(ctx context.Context, city string, start, end time.Time) ([]WeatherForecast, error) {
 	// Load city metadata
-	metadata, err := loadCityMetadata()
+	metadata, err := loadCityMetadata(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load city metadata: %w", err)
 	}
@@ -664,6 +635,8 @@ func getSeasonalForecast(city string, start, end time.Time) ([]WeatherForecast,
 			Date:          d.Format("2006-01-02"),
 			HighTemp:      highTemp,
 			LowTemp:       lowTemp,
+			FeelsLikeHigh: FeelsLike(highTemp, weather.Humidity, weather.WindSpeed),
+			FeelsLikeLow:  FeelsLike(lowTemp, weather.Humidity, weather.WindSpeed),
 			Condition:     weather.Condition,
 			Humidity:      weather.Humidity,
 			WindSpeed:     weather.WindSpeed,
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+// threeHourForecast is an OpenWeatherMap-style forecast with a period every three hours
+// from start, for days days
+func threeHourForecast(start time.Time, days int) WeatherForecastResponse {
+	var response WeatherForecastResponse
+	for i := 0; i < days*8; i++ {
+		var item ForecastItem
+		item.Dt = start.Add(time.Duration(i) * 3 * time.Hour).Unix()
+		item.Main.Temp = 10 + float64(i%8)
+		item.Main.Humidity = 60 + i%20
+		item.Wind.Speed = 3.5
+		pop := float64(i%5) / 10
+		item.POP = &pop
+		if i%3 == 0 {
+			item.Rain = map[string]float64{"3h": 0.4}
+		}
+		item.Weather = append(item.Weather, struct {
+			Main        string `json:"main"`
+			Description string `json:"description"`
+		}{Main: []string{"Clouds", "Rain", "Clear"}[i%3]})
+		response.List = append(response.List, item)
+	}
+	return response
+}
+
+func BenchmarkAggregateForecastData(b *testing.B) {
+	toronto, err := time.LoadLocation("America/Toronto")
+	if err != nil {
+		b.Skipf("timezone data: %v", err)
+	}
+	start := time.Date(2026, time.July, 1, 0, 0, 0, 0, toronto)
+	response := threeHourForecast(start, 5)
+	end := start.AddDate(0, 0, 4)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := aggregateForecastData(response, start, end, toronto); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// hourlyForecast has a period every hour from start, each as warm as its hours since start,
+// so a day's low and high are the first and last hours bucketed into it
+func hourlyForecast(start time.Time, hours int) WeatherForecastResponse {
+	var response WeatherForecastResponse
+	for i := 0; i < hours; i++ {
+		var item ForecastItem
+		item.Dt = start.Add(time.Duration(i) * time.Hour).Unix()
+		item.Main.Temp = float64(i)
+		response.List = append(response.List, item)
+	}
+	return response
+}
+
+func TestAggregateForecastDataAcrossDST(t *testing.T) {
+	zone := func(name string) *time.Location {
+		location, err := time.LoadLocation(name)
+		if err != nil {
+			t.Skipf("timezone data: %v", err)
+		}
+		return location
+	}
+	toronto, vancouver := zone("America/Toronto"), zone("America/Vancouver")
+
+	type day struct {
+		date      string
+		low, high float64 // first and last hour of the day, in hours since the forecast starts
+	}
+	tests := []struct {
+		name       string
+		location   *time.Location
+		from       time.Time // first forecast period, local midnight of the first date
+		hours      int
+		start, end string
+		want       []day
+	}{
+		{
+			name:     "spring forward, a 23 hour day",
+			location: toronto,
+			from:     time.Date(2026, time.March, 7, 0, 0, 0, 0, toronto),
+			hours:    72, start: "2026-03-07", end: "2026-03-09",
+			want: []day{{"2026-03-07", 0, 23}, {"2026-03-08", 24, 46}, {"2026-03-09", 47, 70}},
+		},
+		{
+			name:     "fall back, a 25 hour day",
+			location: toronto,
+			from:     time.Date(2026, time.October, 31, 0, 0, 0, 0, toronto),
+			hours:    74, start: "2026-10-31", end: "2026-11-02",
+			want: []day{{"2026-10-31", 0, 23}, {"2026-11-01", 24, 48}, {"2026-11-02", 49, 72}},
+		},
+		{
+			name:     "trip ending on the spring forward day",
+			location: vancouver,
+			from:     time.Date(2026, time.March, 7, 0, 0, 0, 0, vancouver),
+			hours:    72, start: "2026-03-07", end: "2026-03-08",
+			want: []day{{"2026-03-07", 0, 23}, {"2026-03-08", 24, 46}},
+		},
+		{
+			name:     "trip starting on the fall back day",
+			location: vancouver,
+			from:     time.Date(2026, time.October, 31, 0, 0, 0, 0, vancouver),
+			hours:    74, start: "2026-11-01", end: "2026-11-02",
+			want: []day{{"2026-11-01", 24, 48}, {"2026-11-02", 49, 72}},
+		},
+		{
+			name:     "a fixed offset doesn't change at the boundary",
+			location: time.FixedZone("city", -5*60*60),
+			from:     time.Date(2026, time.March, 7, 0, 0, 0, 0, time.FixedZone("city", -5*60*60)),
+			hours:    72, start: "2026-03-07", end: "2026-03-09",
+			want: []day{{"2026-03-07", 0, 23}, {"2026-03-08", 24, 47}, {"2026-03-09", 48, 71}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, _ := time.ParseInLocation("2006-01-02", tt.start, tt.location)
+			end, _ := time.ParseInLocation("2006-01-02", tt.end, tt.location)
+			forecasts, err := aggregateForecastData(hourlyForecast(tt.from, tt.hours), start, end, tt.location)
+			if err != nil {
+				t.Fatalf("aggregate: %v", err)
+			}
+			if len(forecasts) != len(tt.want) {
+				t.Fatalf("%d days %+v, want %d", len(forecasts), forecasts, len(tt.want))
+			}
+			for i, want := range tt.want {
+				got := forecasts[i]
+				if got.Date != want.date || got.LowTemp != want.low || got.HighTemp != want.high {
+					t.Errorf("day %d = %s hours %v-%v, want %s hours %v-%v", i, got.Date, got.LowTemp, got.HighTemp, want.date, want.low, want.high)
+				}
+			}
+		})
+	}
+}
+
+func TestForecastLocation(t *testing.T) {
+	july := time.Date(2026, time.July, 1, 12, 0, 0, 0, time.UTC)
+	january := time.Date(2026, time.January, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		city          *City
+		offsetSeconds int
+		wantJuly      int // offset in July, hours
+		wantJanuary   int
+	}{
+		{name: "city zone follows DST", city: &City{Timezone: "America/Toronto"}, offsetSeconds: -4 * 3600, wantJuly: -4, wantJanuary: -5},
+		{name: "unknown zone uses the API offset", city: &City{Timezone: "Mars/Olympus"}, offsetSeconds: -4 * 3600, wantJuly: -4, wantJanuary: -4},
+		{name: "no city uses the API offset", offsetSeconds: -7 * 3600, wantJuly: -7, wantJanuary: -7},
+		{name: "nothing known is UTC"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			location := forecastLocation(tt.city, tt.offsetSeconds)
+			_, julyOffset := july.In(location).Zone()
+			_, januaryOffset := january.In(location).Zone()
+			if julyOffset != tt.wantJuly*3600 || januaryOffset != tt.wantJanuary*3600 {
+				t.Errorf("offsets %dh, %dh, want %dh, %dh", julyOffset/3600, januaryOffset/3600, tt.wantJuly, tt.wantJanuary)
+			}
+		})
+	}
+}
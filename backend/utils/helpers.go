@@ -13,6 +13,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/joshndala/cantrip/models"
 )
 
 // GenerateID creates a unique identifier
@@ -156,10 +158,10 @@ func GetWeatherCategory(temp float64) string {
 	}
 }
 
-// CalculateDuration calculates the duration between two dates
+// CalculateDuration counts the calendar days between two dates, unaffected by daylight
+// saving changes between them
 func CalculateDuration(start, end time.Time) int {
-	duration := end.Sub(start)
-	return int(duration.Hours() / 24)
+	return models.DaysBetween(start, end)
 }
 
 // ValidateEmail validates email format (basic)